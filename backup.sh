@@ -105,8 +105,10 @@ sqlite3 "$DATABASE_PATH" ".backup '${BACKUP_DIR}/${BACKUP_FILENAME}'"
 DATA_DIR=$(dirname "$DATABASE_PATH")
 if [[ -d "$DATA_DIR" ]]; then
   echo "Backing up additional data directory contents..."
-  # Copy any other files in the data directory (excluding the main db which we already backed up)
-  find "$DATA_DIR" -type f ! -name "$(basename "$DATABASE_PATH")" -exec cp {} "$BACKUP_DIR/" \; 2>/dev/null || true
+  # Copy any other files in the data directory (excluding the main db which we already backed up,
+  # and its WAL files whose contents the .backup above already includes)
+  DB_NAME=$(basename "$DATABASE_PATH")
+  find "$DATA_DIR" -type f ! -name "$DB_NAME" ! -name "$DB_NAME-wal" ! -name "$DB_NAME-shm" -exec cp {} "$BACKUP_DIR/" \; 2>/dev/null || true
 fi
 
 # Create a compressed tarball of the backups.
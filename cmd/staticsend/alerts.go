@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+)
+
+// alerter tells the administrators about problems sending email, by email
+// and, when one is configured, a webhook
+type alerter struct {
+	db    *database.Database
+	email *email.EmailService
+	// webhookURL, if set, is posted each alert as JSON
+	webhookURL string
+	client     *http.Client
+}
+
+// newAlerter creates an alerter
+func newAlerter(db *database.Database, emailService *email.EmailService, webhookURL string) *alerter {
+	return &alerter{
+		db:         db,
+		email:      emailService,
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// alert emails the administrators and posts to the webhook. The email is
+// sent straight away rather than queued, since the queue may be what's
+// backing up.
+func (a *alerter) alert(subject, body string) {
+	log.Printf("Alert: %s", subject)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if a.webhookURL != "" {
+		if err := a.postWebhook(ctx, subject, body); err != nil {
+			log.Printf("Failed to post the alert to the webhook: %v", err)
+		}
+	}
+
+	users, err := models.GetAllUsersContext(ctx, a.db.Connection)
+	if err != nil {
+		log.Printf("Failed to find the administrators to alert: %v", err)
+		return
+	}
+	var admins []string
+	for _, user := range users {
+		if user.IsAdmin() {
+			admins = append(admins, user.Email)
+		}
+	}
+	if len(admins) == 0 {
+		return
+	}
+	if err := a.email.Send(admins, subject, body); err != nil {
+		log.Printf("Failed to email the alert to the administrators: %v", err)
+	}
+}
+
+// postWebhook posts an alert as {"subject": ..., "text": ...}, where text
+// holds both, for chat webhooks that only show text
+func (a *alerter) postWebhook(ctx context.Context, subject, body string) error {
+	payload, err := json.Marshal(map[string]string{
+		"subject": subject,
+		"text":    subject + "\n\n" + body,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// failover alerts when emails switch between the primary and fallback SMTP
+// servers. It's called while an email is being sent, so alerts in the
+// background.
+func (a *alerter) failover(failover email.Failover) {
+	subject := fmt.Sprintf("staticSend: emails are going through %s", failover.To)
+	var body string
+	if failover.Fallback {
+		body = fmt.Sprintf("The SMTP server %s failed several sends in a row, so emails are going through the fallback server %s.\n\nThe last error was: %v\n\n%s will be tried again later, and emails will go back through it once it works.\n", failover.From, failover.To, failover.Err, failover.From)
+	} else {
+		body = fmt.Sprintf("The SMTP server %s is working again, so emails are going through it instead of the fallback server %s.\n", failover.To, failover.From)
+	}
+	go a.alert(subject, body)
+}
+
+// queue alerts when the email queue starts backing up, and once it's
+// recovered
+func (a *alerter) queue(problems []string) {
+	if len(problems) == 0 {
+		a.alert("staticSend: the email queue has recovered", "The email queue is back under every alert threshold.\n")
+		return
+	}
+	a.alert("staticSend: the email queue is backing up", "Notification emails aren't keeping up:\n\n- "+strings.Join(problems, "\n- ")+"\n\nCheck the SMTP server and the logs, and the email queue on the admin overview.\n")
+}
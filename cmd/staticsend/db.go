@@ -0,0 +1,215 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"staticsend/pkg/config"
+	"staticsend/pkg/database"
+)
+
+const dbUsage = `usage: staticsend db <command>
+
+commands:
+  migrate         apply pending migrations
+  status          list migrations and whether each is applied
+  backup <file>   copy the SQLite database to a new file (.gz to compress)
+  restore <file>  replace the SQLite database with a backup (.gz or .db)`
+
+// runCommand runs a command given on the command line instead of the server
+func runCommand(cfg *config.Config, args []string, out io.Writer) error {
+	switch args[0] {
+	case "db":
+		return runDB(cfg, args[1:], out)
+	case "seed-demo":
+		if len(args) > 1 {
+			return errors.New("usage: staticsend seed-demo")
+		}
+		return seedDemo(cfg, out)
+	case "import":
+		return importSubmissions(cfg, args[1:], out)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runDB runs a database command, for deployment scripts to upgrade, check
+// and roll back the database
+func runDB(cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(dbUsage)
+	}
+	command, files := args[0], args[1:]
+	wantFiles := 0
+	if command == "backup" || command == "restore" {
+		wantFiles = 1
+	}
+	if len(files) != wantFiles {
+		return errors.New(dbUsage)
+	}
+
+	ctx := context.Background()
+	dsn, sqliteOptions := databaseSource(cfg)
+	db, err := database.OpenWithOptions(dsn, sqliteOptions)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	switch command {
+	case "migrate":
+		before, _, err := db.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if err := db.Migrate(); err != nil {
+			return err
+		}
+		after, _, err := db.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if before == after {
+			fmt.Fprintf(out, "Database is up to date at version %03d\n", after)
+		} else {
+			fmt.Fprintf(out, "Migrated database from version %03d to %03d\n", before, after)
+		}
+		return nil
+
+	case "status":
+		states, err := db.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		pending := 0
+		for _, state := range states {
+			status := "applied"
+			if !state.Applied {
+				status = "pending"
+				pending++
+			}
+			fmt.Fprintf(out, "%03d  %-8s %s\n", state.Version, status, state.Name)
+		}
+		fmt.Fprintf(out, "%d of %d migrations applied, %d pending\n", len(states)-pending, len(states), pending)
+		return nil
+
+	case "backup":
+		if err := backupDatabase(ctx, db, files[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Backed up database to %s\n", files[0])
+		return nil
+
+	case "restore":
+		if err := restoreDatabase(ctx, db, files[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Restored database from %s; migrations run when staticSend next starts\n", files[0])
+		return nil
+	}
+	return errors.New(dbUsage)
+}
+
+// databaseSource returns the configured database's DSN and SQLite settings
+func databaseSource(cfg *config.Config) (string, database.SQLiteOptions) {
+	// A DSN selects another engine, e.g. PostgreSQL
+	dsn := cfg.DatabasePath
+	if cfg.DatabaseDSN != "" {
+		dsn = cfg.DatabaseDSN
+	}
+	return dsn, database.SQLiteOptions{
+		JournalMode:  cfg.SQLiteJournalMode,
+		BusyTimeout:  cfg.SQLiteBusyTimeout,
+		Synchronous:  cfg.SQLiteSynchronous,
+		MaxOpenConns: cfg.SQLiteMaxOpenConns,
+	}
+}
+
+// backupDatabase copies the database to path, gzipped if it ends in .gz
+// like the scheduled backups
+func backupDatabase(ctx context.Context, db *database.Database, path string) error {
+	if !strings.HasSuffix(path, ".gz") {
+		return db.BackupTo(ctx, path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	dir, err := os.MkdirTemp("", "staticsend-backup-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if err := db.BackupTo(ctx, snapshot); err != nil {
+		return err
+	}
+
+	src, err := os.Open(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// restoreDatabase replaces the database with the backup at path, which is
+// uncompressed first if it ends in .gz
+func restoreDatabase(ctx context.Context, db *database.Database, path string) error {
+	if !strings.HasSuffix(path, ".gz") {
+		return db.RestoreFrom(ctx, path)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	defer gz.Close()
+
+	dir, err := os.MkdirTemp("", "staticsend-restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, "snapshot.db")
+	file, err := os.OpenFile(snapshot, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to uncompress backup: %w", err)
+	}
+	_, err = io.Copy(file, gz)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to uncompress backup: %w", err)
+	}
+
+	return db.RestoreFrom(ctx, snapshot)
+}
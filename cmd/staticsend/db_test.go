@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/config"
+)
+
+func TestRunDB(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.DatabasePath = filepath.Join(dir, "staticsend.db")
+
+	run := func(args ...string) string {
+		t.Helper()
+		var out strings.Builder
+		if err := runCommand(cfg, append([]string{"db"}, args...), &out); err != nil {
+			t.Fatalf("db %s failed: %v", strings.Join(args, " "), err)
+		}
+		return out.String()
+	}
+
+	if out := run("status"); !strings.Contains(out, "001  pending  initial database") || !strings.Contains(out, "0 of") {
+		t.Errorf("Expected every migration pending on a new database, got:\n%s", out)
+	}
+	if out := run("migrate"); !strings.Contains(out, "from version 000") {
+		t.Errorf("Expected the database to be migrated, got:\n%s", out)
+	}
+	if out := run("migrate"); !strings.Contains(out, "up to date") {
+		t.Errorf("Expected nothing left to migrate, got:\n%s", out)
+	}
+	if out := run("status"); !strings.Contains(out, ", 0 pending") {
+		t.Errorf("Expected no pending migrations, got:\n%s", out)
+	}
+
+	for _, name := range []string{"backup.db", "backup.db.gz"} {
+		backup := filepath.Join(dir, name)
+		run("backup", backup)
+		run("restore", backup)
+		if err := runCommand(cfg, []string{"db", "backup", backup}, &strings.Builder{}); err == nil {
+			t.Errorf("Expected backing up over %s to fail", name)
+		}
+	}
+
+	for _, args := range [][]string{{"db"}, {"db", "backup"}, {"db", "vacuum"}, {"serve"}} {
+		if err := runCommand(cfg, args, &strings.Builder{}); err == nil {
+			t.Errorf("Expected %v to fail", args)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"staticsend/pkg/config"
+	"staticsend/pkg/database"
+	"staticsend/pkg/importer"
+	"staticsend/pkg/models"
+)
+
+const importUsage = `usage: staticsend import <form key> <file> [field=new_name ...]
+
+Imports submissions exported from Formspree or Netlify Forms, as CSV or JSON,
+into an existing form. Fields can be renamed, or left out with field=`
+
+// importSubmissions imports exported submissions into the form with the
+// given key
+func importSubmissions(cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return errors.New(importUsage)
+	}
+	formKey, path := args[0], args[1]
+	mapping, err := importer.ParseMapping(args[2:])
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	submissions, err := importer.Read(file, mapping)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	ctx := context.Background()
+	dsn, sqliteOptions := databaseSource(cfg)
+	db, err := database.ConnectWithOptions(dsn, sqliteOptions)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	form, err := models.GetFormByKeyContext(ctx, db.Connection, formKey)
+	if err != nil {
+		return err
+	}
+	if form == nil {
+		return fmt.Errorf("no form has the key %q", formKey)
+	}
+	if err := models.ImportSubmissionsContext(ctx, db.Connection, form.ID, submissions); err != nil {
+		return fmt.Errorf("failed to import submissions: %w", err)
+	}
+
+	fmt.Fprintf(out, "Imported %d submissions into %s\n", len(submissions), form.Name)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/config"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestImportSubmissions(t *testing.T) {
+	cfg := config.Defaults()
+	dir := t.TempDir()
+	cfg.DatabasePath = filepath.Join(dir, "staticsend.db")
+
+	db, err := database.Init(cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "", "owner@example.com", "import-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	db.Close()
+
+	export := filepath.Join(dir, "formspree.csv")
+	os.WriteFile(export, []byte("_date,Your Email,message\n2024-03-01 09:30:00,ada@example.com,Hello\n"), 0600)
+
+	var out strings.Builder
+	if err := runCommand(cfg, []string{"import", "import-key", export, "Your Email=email"}, &out); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Imported 1 submissions") {
+		t.Errorf("Expected the count to be printed, got %q", out.String())
+	}
+	if err := runCommand(cfg, []string{"import", "missing-key", export}, &strings.Builder{}); err == nil {
+		t.Error("Expected importing into an unknown form to fail")
+	}
+
+	db, err = database.Init(cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	submissions, _ := models.GetSubmissionsByFormID(db.Connection, form.ID)
+	if len(submissions) != 1 {
+		t.Fatalf("Expected 1 submission, got %d", len(submissions))
+	}
+	submission := submissions[0]
+	if submission.Status != "processed" || submission.CreatedAt.Format("2006-01-02 15:04") != "2024-03-01 09:30" {
+		t.Errorf("Expected a processed submission from the export's date, got %s at %s", submission.Status, submission.CreatedAt)
+	}
+	if !strings.Contains(string(submission.SubmittedData), `"email":"ada@example.com"`) {
+		t.Errorf("Expected the renamed field, got %s", submission.SubmittedData)
+	}
+}
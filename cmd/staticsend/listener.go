@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"staticsend/pkg/config"
+)
+
+// systemdFirstFD is the first file descriptor systemd passes to a socket
+// activated service
+const systemdFirstFD = 3
+
+// newListener returns the listener to serve on: a socket passed by systemd
+// socket activation, the configured Unix socket, or the TCP port. activated
+// reports a socket from systemd, which is kept when the configuration
+// changes.
+func newListener(cfg *config.Config) (listener net.Listener, activated bool, err error) {
+	listener, err = systemdListener()
+	if err != nil || listener != nil {
+		return listener, listener != nil, err
+	}
+	if cfg.ListenSocket != "" {
+		listener, err = unixListener(cfg.ListenSocket, cfg.SocketMode)
+		return listener, false, err
+	}
+	listener, err = net.Listen("tcp", ":"+cfg.Port)
+	return listener, false, err
+}
+
+// systemdListener returns the first socket systemd passed in LISTEN_FDS, or
+// nil when the process wasn't socket activated
+func systemdListener() (net.Listener, error) {
+	// LISTEN_PID guards against using variables meant for a parent process
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+	// Child processes shouldn't think the sockets were meant for them
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(systemdFirstFD, "systemd socket")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use the socket from systemd: %w", err)
+	}
+	return listener, nil
+}
+
+// unixListener listens on a Unix socket at path, replacing a socket left by
+// an earlier run, and sets its permissions so a web server can connect
+func unixListener(path, mode string) (net.Listener, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket mode %q: %w", mode, err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove old socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixListener(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "staticsend.sock")
+
+	listener, err := unixListener(path, "0660")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the socket to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0660 {
+		t.Errorf("Expected mode 0660, got %o", info.Mode().Perm())
+	}
+
+	// A socket left behind by a crash is replaced
+	listener.(interface{ SetUnlinkOnClose(bool) }).SetUnlinkOnClose(false)
+	listener.Close()
+	listener, err = unixListener(path, "0600")
+	if err != nil {
+		t.Fatalf("Expected a stale socket to be replaced, got %v", err)
+	}
+	listener.Close()
+
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("keep me"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unixListener(file, "0660"); err == nil {
+		t.Error("Expected a file that isn't a socket not to be replaced")
+	}
+	if _, err := unixListener(filepath.Join(dir, "other.sock"), "rw"); err == nil {
+		t.Error("Expected an invalid mode to be rejected")
+	}
+}
@@ -1,145 +1,943 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/acme/autocert"
+	"staticsend"
+	"staticsend/pkg/akismet"
 	"staticsend/pkg/api"
+	"staticsend/pkg/archive"
+	"staticsend/pkg/assets"
+	"staticsend/pkg/auth"
+	"staticsend/pkg/backup"
+	"staticsend/pkg/bounces"
+	"staticsend/pkg/clientip"
 	"staticsend/pkg/config"
 	"staticsend/pkg/database"
+	"staticsend/pkg/debug"
+	"staticsend/pkg/events"
 	"staticsend/pkg/email"
+	"staticsend/pkg/export"
+	"staticsend/pkg/geoip"
+	"staticsend/pkg/integrity"
+	"staticsend/pkg/livesettings"
+	"staticsend/pkg/logfile"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+	"staticsend/pkg/plugins"
+	"staticsend/pkg/outbound"
+	"staticsend/pkg/redact"
+	"staticsend/pkg/sheets"
+	"staticsend/pkg/snippet"
+	"staticsend/pkg/storage"
 	"staticsend/pkg/templates"
+	"staticsend/pkg/turnstile"
+	"staticsend/pkg/unsubscribe"
+	"staticsend/pkg/uploads"
+	"staticsend/pkg/velocity"
+	"staticsend/pkg/version"
 	"staticsend/pkg/web"
 	customMiddleware "staticsend/pkg/middleware"
 )
 
 func main() {
-	// Load configuration from environment variables
-	cfg := config.LoadConfig()
-	
-	// Allow command line overrides
-	port := flag.String("port", cfg.Port, "Port to listen on")
-	dbPath := flag.String("db", cfg.DatabasePath, "Database file path")
-	help := flag.Bool("help", false, "Show help")
-	flag.Parse()
-
-	if *help {
-		flag.Usage()
+	// Flags override the environment, which overrides the config file
+	cfg, err := config.Load(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
 		return
 	}
-	
-	// Update config with command line values
-	cfg.Port = *port
-	cfg.DatabasePath = *dbPath
+	// -version works even when the rest of the configuration is invalid
+	if cfg != nil && cfg.ShowVersion {
+		fmt.Println(version.Get())
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	// Files are embedded in the binary; a configured directory overrides them
+	database.SetMigrationsFS(filesFrom(cfg.MigrationsDir, staticsend.MigrationsFS()))
+
+	// Credentials are encrypted with a key kept apart from the database
+	if err := useSecretsKey(cfg, len(cfg.Args) == 0); err != nil {
+		log.Fatalf("Failed to load the secrets key: %v", err)
+	}
+
+	if len(cfg.Args) > 0 {
+		if err := runCommand(cfg, cfg.Args, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// OUTBOUND_PROXY carries requests to Cloudflare and other services
+	outbound.UseProxy(proxyURL(cfg))
+
+	// Clients' addresses come from forwarding headers only behind
+	// TRUSTED_PROXIES, which the configuration has already checked
+	trustedProxies, _ := clientip.ParseProxies(cfg.TrustedProxies)
+	clientip.SetTrustedProxies(trustedProxies)
+
+	// LOG_FILE sends logs to a file, which SIGHUP reopens after rotation
+	var logs *logfile.File
+	if cfg.LogFile != "" {
+		logs, err = logfile.Open(cfg.LogFile)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		log.SetOutput(logs)
+	}
+
+	log.Printf("Starting %s", version.Get())
 
 	// Initialize database
-	if err := database.Init(cfg.DatabasePath); err != nil {
+	db, err := database.ConnectWithOptions(databaseSource(cfg))
+	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer database.Close()
+	defer db.Close()
+	db.LogSlowQueries(cfg.SlowQueryThreshold)
+	encryptSecrets(context.Background(), db)
 
-	// Use JWT secret from config
+	// Use JWT secret from config, or one generated on the first run
 	secretKey := []byte(cfg.JWTSecretKey)
+	if len(secretKey) == 0 {
+		key, created, err := auth.LoadOrCreateSecretKey(cfg.JWTSecretPath)
+		if err != nil {
+			log.Fatalf("Failed to load JWT secret: %v", err)
+		}
+		if created {
+			log.Printf("Generated a JWT secret in %s", cfg.JWTSecretPath)
+		}
+		log.Printf("Warning: JWT_SECRET_KEY is not set; using the generated secret in %s. Set JWT_SECRET_KEY explicitly, especially when running more than one instance", cfg.JWTSecretPath)
+		secretKey = key
+	}
 
 	// Use Turnstile configuration from config
 	authTurnstilePublicKey := cfg.TurnstilePublicKey
 	authTurnstileSecretKey := cfg.TurnstileSecretKey
+	// One client verifies every form's and the sign in pages' tokens, reusing
+	// its connections to Cloudflare
+	turnstileClient := turnstile.NewClient(cfg.TurnstileVerifyURL, cfg.TurnstileTimeout)
 	
 	// Create template manager and web handlers
-	tm := templates.NewTemplateManager()
-	webHandler := web.NewWebHandler(database.DB, tm, authTurnstilePublicKey)
-	webAuthHandler := web.NewWebAuthHandler(&database.Database{Connection: database.DB}, secretKey, tm, authTurnstilePublicKey, authTurnstileSecretKey)
-	settingsHandler := web.NewSettingsHandler(&database.Database{Connection: database.DB}, tm)
+	// In development templates are read from disk and reloaded on change
+	templatesDir := cfg.TemplatesDir
+	if cfg.DevMode && templatesDir == "" {
+		templatesDir = "templates"
+	}
+	tm := templates.NewTemplateManagerFS(filesFrom(templatesDir, staticsend.TemplatesFS()))
+	if err := tm.Err(); err != nil && !cfg.DevMode {
+		log.Fatalf("Failed to load templates: %v", err)
+	}
+	tm.SetBasePath(cfg.BasePath)
+	if cfg.DevMode {
+		log.Printf("Development mode: reloading templates from %s on change", templatesDir)
+		tm.SetDevMode(true)
+		tm.StartWatching(templates.DefaultWatchInterval)
+		defer tm.StopWatching()
+	}
+	// Configuration was validated, so the special fields parse
+	specialFields, _ := models.ParseSpecialFields(cfg.SpecialFields)
+	webHandler := web.NewWebHandler(db, tm, authTurnstilePublicKey)
+	webHandler.Honeypot = specialFields.Honeypot
+	formScript, err := web.NewFormScriptHandler(version.Version)
+	if err != nil {
+		log.Fatalf("Failed to generate the form script: %v", err)
+	}
+	webHandler.FormScript = formScript
+	webAuthHandler := web.NewWebAuthHandler(db, secretKey, tm, authTurnstilePublicKey, authTurnstileSecretKey)
+	settingsHandler := web.NewSettingsHandler(db, tm)
+	ipRulesHandler := web.NewIPRulesHandler(db, tm)
+	quotasHandler := web.NewQuotasHandler(db, tm)
+	inboxHandler := web.NewInboxHandler(db, tm)
 	
 	// Create email service from config
-	emailConfig := email.EmailConfig{
-		Host:     cfg.EmailHost,
-		Port:     cfg.EmailPort,
-		Username: cfg.EmailUsername,
-		Password: cfg.EmailPassword,
-		From:     cfg.EmailFrom,
-		UseTLS:   cfg.EmailUseTLS,
-	}
-	emailService := email.NewEmailService(emailConfig, 100, 10, 5)
+	emailService := email.NewEmailService(emailConfig(cfg), 100, 10, 5)
+	alerts := newAlerter(db, emailService, cfg.AlertWebhookURL)
+	emailService.OnFailover = alerts.failover
+	if cfg.EmailAlertInterval > 0 {
+		queueMonitor := email.NewMonitor(emailService, email.QueueThresholds{
+			Depth:          cfg.EmailAlertQueueDepth,
+			OldestAge:      cfg.EmailAlertOldestAge,
+			FailurePercent: cfg.EmailAlertFailurePercent,
+		}, alerts.queue)
+		queueMonitor.Start(cfg.EmailAlertInterval)
+		defer queueMonitor.Stop()
+	}
+
+	// Settings saved in the setup wizard and on the settings page take the
+	// place of the environment, and are reloaded when they change
+	liveSettings := livesettings.NewStore(db, livesettings.Settings{
+		BaseURL:            cfg.BaseURL,
+		TurnstilePublicKey: cfg.TurnstilePublicKey,
+		TurnstileSecretKey: cfg.TurnstileSecretKey,
+	})
+	if err := liveSettings.Reload(context.Background()); err != nil {
+		log.Fatalf("Failed to load settings: %v", err)
+	}
+	applySettings := func(settings livesettings.Settings) {
+		if settings.Email.Host != "" {
+			web.ApplyEmailSettings(emailService, &settings.Email)
+		}
+		if settings.BaseURL != "" {
+			tm.SetBaseURL(settings.BaseURL)
+		}
+	}
+	applySettings(liveSettings.Current())
+	liveSettings.OnChange(applySettings)
+	if cfg.SettingsReloadInterval > 0 {
+		liveSettings.Start(cfg.SettingsReloadInterval)
+		defer liveSettings.Stop()
+	}
+	webHandler.Settings = liveSettings
+	webAuthHandler.Settings = liveSettings
+	webAuthHandler.Turnstile = turnstileClient
+	settingsHandler.Settings = liveSettings
+	if err := web.LoadBranding(context.Background(), db, tm); err != nil {
+		log.Fatalf("Failed to load branding: %v", err)
+	}
+
+	// Forms created before field catalogs have theirs built from their
+	// submissions, in the background as it reads every submission once
+	go func() {
+		built, err := models.BackfillFormFieldsContext(context.Background(), db.Connection)
+		if err != nil {
+			log.Printf("Failed to catalog forms' fields: %v", err)
+		}
+		if built > 0 {
+			log.Printf("Cataloged the fields of %d forms", built)
+		}
+	}()
 	
+	// Scheduled backups of the SQLite database
+	storageCfg := storageConfig(cfg, secretKey)
+	backups, err := backupManager(cfg, db, storageCfg)
+	if err != nil {
+		log.Fatalf("Failed to configure backups: %v", err)
+	}
+	if backups != nil && cfg.BackupInterval > 0 {
+		log.Printf("Backing up the database to %s every %s", backups.Store(), cfg.BackupInterval)
+		backups.Start(cfg.BackupInterval)
+		defer backups.Stop()
+	}
+	backupsHandler := web.NewBackupsHandler(backups, tm)
+	backupsHandler.LinkTTL = cfg.StorageLinkTTL
+
+	// Archival of old submissions; archives stay exportable when it's disabled
+	archiveStore, err := storage.New(storageCfg, cfg.ArchiveDir, "archives/", "/files/archives")
+	if err != nil {
+		log.Fatalf("Failed to configure archives: %v", err)
+	}
+	archiver := archive.NewArchiver(db, archiveStore, time.Duration(cfg.ArchiveAfterDays)*24*time.Hour)
+	if cfg.ArchiveAfterDays > 0 && cfg.ArchiveInterval > 0 {
+		log.Printf("Archiving submissions older than %d days to %s every %s", cfg.ArchiveAfterDays, archiveStore, cfg.ArchiveInterval)
+		archiver.Start(cfg.ArchiveInterval)
+		defer archiver.Stop()
+	}
+	archivesHandler := web.NewArchivesHandler(db, archiver)
+
+	// Exports of submissions are written in the background and emailed
+	exportStore, err := storage.New(storageCfg, cfg.ExportDir, "exports/", "/files/exports")
+	if err != nil {
+		log.Fatalf("Failed to configure exports: %v", err)
+	}
+	exporter := export.NewExporter(db, exportStore, emailService, secretKey, cfg.ExportLinkTTL, tm.BaseURL)
+	if cfg.ExportInterval > 0 {
+		exporter.Start(cfg.ExportInterval)
+		defer exporter.Stop()
+	}
+	exportsHandler := web.NewExportsHandler(db, tm, exporter)
+	exportsHandler.LinkTTL = cfg.StorageLinkTTL
+
+	// Files uploaded with submissions; ones no submission refers to, such as
+	// those of deleted submissions, are swept every hour
+	uploadStore, err := storage.New(storageCfg, cfg.UploadDir, "uploads/", "/files/uploads")
+	if err != nil {
+		log.Fatalf("Failed to configure uploads: %v", err)
+	}
+	uploader := uploads.NewUploader(db, uploadStore, secretKey)
+	uploader.Start(time.Hour)
+	defer uploader.Stop()
+	uploadsHandler := web.NewUploadsHandler(db, uploader)
+	uploadsHandler.LinkTTL = cfg.StorageLinkTTL
+
+	// Notification emails carry signed links their recipients mute forms with
+	unsubscribeLinks := unsubscribe.NewLinks(secretKey, tm.BaseURL)
+	webHandler.UnsubscribeLinks = unsubscribeLinks
+
+	// Notifications are delivered through each form's channels in the background
+	notifier := notify.NewDispatcher(db, emailService, tm.BaseURL, 100, 5, 3)
+	notifier.SetUnsubscribeLinks(unsubscribeLinks)
+	defer notifier.Shutdown()
+	notificationsHandler := web.NewNotificationsHandler(db, tm, notifier)
+
+	// Spikes in submissions, such as a spam flood, are emailed to the form's
+	// owner and sent through its channels
+	analyzer := velocity.NewAnalyzer(db, emailService, notifier, tm.BaseURL)
+	analyzer.Window = cfg.VelocityWindow
+	analyzer.Factor = cfg.VelocityFactor
+	analyzer.MinSubmissions = cfg.VelocityMinSubmissions
+	if cfg.VelocityCheckInterval > 0 {
+		analyzer.Start(cfg.VelocityCheckInterval)
+		defer analyzer.Stop()
+	}
+
+	// Submissions are appended to forms' Google Sheets in the background
+	sheetsSyncer := sheets.NewSyncer(db, sheets.NewClient(&http.Client{Timeout: 30 * time.Second}))
+	if cfg.SheetsSyncInterval > 0 {
+		sheetsSyncer.Start(cfg.SheetsSyncInterval)
+		defer sheetsSyncer.Stop()
+	}
+	sheetsHandler := web.NewSheetsHandler(db, tm, sheetsSyncer)
+
+	// Notification channels, Google Sheets, dashboard counts and the audit
+	// log follow submissions, forms and accounts through events, rather
+	// than each handler calling them. The bus is drained before the
+	// notifier shuts down.
+	bus := events.NewBus()
+	defer bus.Wait()
+	bus.SubscribeAll(events.Log)
+	bus.Subscribe(events.SubmissionCreated, func(ctx context.Context, event events.Event) {
+		if err := notifier.NotifySubmission(ctx, event.Form, event.Submission); err != nil {
+			log.Printf("Failed to queue notifications for submission %d: %v", event.Submission.ID, err)
+		}
+	})
+	bus.Subscribe(events.SubmissionCreated, func(ctx context.Context, event events.Event) {
+		sheetsSyncer.Wake()
+	})
+	bus.Subscribe(events.SubmissionCreated, webHandler.ForgetCounts)
+	bus.Subscribe(events.FormDeleted, webHandler.ForgetCounts)
+	webAuthHandler.Events = bus
+
+	// Bounces and complaints, from the email provider's webhooks or the
+	// bounce mailbox, mark forms' forward addresses as failing
+	bounceHandler := api.NewBounceHandler(db, cfg.BounceWebhookToken)
+	if cfg.BounceIMAPAddr != "" && cfg.BounceIMAPInterval > 0 {
+		bouncePoller := bounces.NewPoller(db, cfg.BounceIMAPAddr, cfg.BounceIMAPUsername, cfg.BounceIMAPPassword)
+		bouncePoller.Mailbox = cfg.BounceIMAPMailbox
+		bouncePoller.TLS = cfg.BounceIMAPTLS
+		bouncePoller.Proxy = proxyURL(cfg)
+		bouncePoller.Start(cfg.BounceIMAPInterval)
+		defer bouncePoller.Stop()
+	}
+
+	// Automation tools follow submissions through REST hooks, using API keys
+	hooksHandler := api.NewHooksHandler(db, notifier)
+	apiKeysHandler := web.NewAPIKeysHandler(db, tm)
+
+	// Integrity checks for rows orphaned while foreign keys weren't enforced
+	checker := integrity.NewChecker(db, cfg.IntegrityAutoRepair)
+	if cfg.IntegrityCheckInterval > 0 {
+		checker.Start(cfg.IntegrityCheckInterval)
+		defer checker.Stop()
+	}
+	integrityHandler := web.NewIntegrityHandler(checker, tm)
+	importsHandler := web.NewImportsHandler(db, tm)
+	transfersHandler := web.NewTransfersHandler(db, tm)
+
 	// Create API handlers
-	formHandler := api.NewFormHandler(database.DB)
-	submissionHandler := api.NewSubmissionHandler(database.DB, emailService)
+	formHandler := api.NewFormHandler(db, webHandler)
+	formHandler.Notifier = notifier
+	formHandler.Events = bus
+	submissionHandler := api.NewSubmissionHandler(db, emailService)
+	submissionHandler.Notifier = notifier
+	submissionHandler.Events = bus
+	submissionHandler.Uploads = uploader
+	submissionHandler.MaxUploadSize = int64(cfg.UploadMaxSizeMB) << 20
+	submissionHandler.FileLinkTTL = cfg.UploadEmailLinkTTL
+	submissionHandler.BaseURL = tm.BaseURL
+	submissionHandler.Unsubscribe = unsubscribeLinks
+	submissionHandler.Turnstile = turnstileClient
+	submissionHandler.SpecialFields = specialFields
+	// Compiled in hooks and hook commands may change or reject submissions
+	if hooks := plugins.NewRunner(cfg.SubmissionHookCommands, cfg.SubmissionHookTimeout); hooks.Len() > 0 {
+		log.Printf("Running %d submission hooks", hooks.Len())
+		submissionHandler.Plugins = hooks
+	}
+	if cfg.GeoIPDatabase != "" {
+		geoDB, err := geoip.Open(cfg.GeoIPDatabase)
+		if err != nil {
+			log.Fatalf("Failed to open GeoIP database: %v", err)
+		}
+		log.Printf("Looking up submissions' countries in %s (%s)", cfg.GeoIPDatabase, geoDB.Type)
+		submissionHandler.GeoIP = geoDB
+	}
+	// Forms can have their submissions checked with Akismet once there's a key
+	var akismetClient *akismet.Client
+	if cfg.AkismetAPIKey != "" {
+		akismetClient = akismet.NewClient(cfg.AkismetAPIKey, &http.Client{Timeout: 10 * time.Second})
+		submissionHandler.Akismet = akismetClient
+	}
+	if err := submissionHandler.PrepareStatements(context.Background()); err != nil {
+		log.Fatalf("Failed to prepare submission statements: %v", err)
+	}
+	defer submissionHandler.Close()
+	healthHandler := api.NewHealthHandler(db, emailService, uint64(cfg.HealthMinFreeDiskMB)*1024*1024)
+	healthHandler.CheckEmail = cfg.HealthCheckEmail
+	metricsHandler := api.NewMetricsHandler(emailService, cfg.MetricsToken)
+	submissionDetailHandler := web.NewSubmissionDetailHandler(db, tm, emailService)
+	submissionDetailHandler.Notifier = notifier
+	submissionDetailHandler.Files = uploadStore
+	submissionDetailHandler.LinkTTL = cfg.StorageLinkTTL
+	submissionDetailHandler.Uploads = uploader
+	submissionDetailHandler.FileLinkTTL = cfg.UploadEmailLinkTTL
+	submissionDetailHandler.Akismet = akismetClient
+	submissionDetailHandler.Unsubscribe = unsubscribeLinks
+	adminHandler := web.NewAdminHandler(db, tm, emailService)
+	dataRequestsHandler := web.NewDataRequestsHandler(db, tm)
+	dataRequestsHandler.Files = uploadStore
+	setupHandler := web.NewSetupHandler(db, secretKey, tm, emailService)
+	setupHandler.Events = bus
+	setupHandler.Settings = liveSettings
+	brandingHandler := web.NewBrandingHandler(db, tm)
+
+	// Select the rate limiter backend
+	newLimiterStore, err := rateLimiterFactory(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure rate limiter: %v", err)
+	}
+	// Every limiter is registered, so the debug routes can list them
+	debugHandler := debug.NewHandler(tm)
+	adminHandler.Limiters = debugHandler.Limiters
+	adminHandler.Profiling = cfg.Debug
+	newRateLimiter := func(name string, rate time.Duration, burst int) customMiddleware.LimiterStore {
+		store := newLimiterStore(name, rate, burst)
+		debugHandler.AddLimiter(debug.Limiter{Name: name, Rate: rate, Burst: burst, Store: store})
+		return store
+	}
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(customMiddleware.RedactingLogger(redact.New(cfg.LogRedactKeys...)))
 	r.Use(middleware.Recoverer)
-	
-	// Serve static files
-	staticDir := "./static"
-	if _, err := os.Stat(staticDir); err == nil {
-		r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
+	// Cancels the request context so outbound calls give up once the deadline
+	// passes. Downloads stream for longer, so get a deadline of their own.
+	r.Use(customMiddleware.Timeout(cfg.HandlerTimeout, cfg.DownloadTimeout,
+		"/forms/{id}/archive",
+		"/forms/{id}/transfer",
+		"/forms/{id}/submissions/{submissionID}/files/{fileID}",
+		"/uploads/{submissionID}/{fileID}",
+		"/exports/{id}/download",
+		"/settings/backups/{name}/download",
+		"POST /settings/data-requests/export",
+		"/files/*",
+	))
+	if cfg.CompressionLevel > 0 {
+		r.Use(customMiddleware.Compress(cfg.CompressionLevel))
 	}
+	r.Use(customMiddleware.MaintenanceMode(customMiddleware.MaintenanceConfig{
+		SecretKey:    secretKey,
+		DB:           db,
+		Templates:    tm,
+		AllowedPaths: []string{"/static", "/favicon.ico", "/branding", "/health", "/healthz", "/readyz", "/api/v1/version", "/login", "/auth/login", "/auth/logout"},
+	}))
+	// Until the first account exists every page leads to the setup wizard
+	r.Use(customMiddleware.RequireSetup(customMiddleware.SetupConfig{
+		DB:           db,
+		SetupPath:    "/setup",
+		AllowedPaths: []string{"/static", "/favicon.ico", "/branding", "/health", "/healthz", "/readyz", "/api/v1/version"},
+	}))
+
+	// Serve static files
+	staticFiles := filesFrom(cfg.StaticDir, staticsend.StaticFS())
+	staticAssets := assets.NewHandler(staticFiles)
+	tm.SetAssetURLFunc(staticAssets.URL)
+	// The form script is generated rather than a file
+	r.Get(snippet.ScriptPath, formScript.ServeHTTP)
+	r.Handle("/static/*", http.StripPrefix("/static/", staticAssets))
 	
 	// Serve favicon
 	r.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "./static/favicon.svg")
+		http.ServeFileFS(w, r, staticFiles, "favicon.svg")
 	})
 
+	// Uploaded logo, shown on public pages like sign in
+	r.Get("/branding/logo", brandingHandler.Logo)
+
 	// Public routes
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
+	r.Get("/health/ready", healthHandler.Ready)
+	// Kubernetes style liveness and readiness probes
+	r.Get("/healthz", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
+	// Email queue metrics for Prometheus, with METRICS_TOKEN
+	r.Get("/metrics", metricsHandler.Metrics)
+	// Which build is running, for bug reports
+	r.Get("/api/v1/version", api.Version)
+	// Read-only status pages owners share for reporting
+	r.Get("/status/{token}", webHandler.StatusPage)
+	// Forms' hosted pages, for owners without a site to put them on
+	r.Get("/f/{formKey}", webHandler.HostedForm)
+	// Token-guarded feeds of submissions for feed readers
+	r.Get("/feeds/{token}/atom", webHandler.AtomFeed)
+	r.Get("/feeds/{token}/rss", webHandler.RSSFeed)
+	// Signed links to finished exports, sent by email
+	r.Get("/exports/{id}/download", exportsHandler.Download)
+	// Signed links to uploaded files, sent in notification emails
+	r.Get("/uploads/{submissionID}/{fileID}", uploadsHandler.Download)
+	// Signed links in notification emails to mute a form's notifications,
+	// which mail clients may also post to in one click
+	r.Get("/unsubscribe", webHandler.UnsubscribePage)
+	r.Post("/unsubscribe", webHandler.Unsubscribe)
+	// Bounce and complaint webhooks from the email provider
+	r.Post("/webhooks/bounces/{provider}/{token}", bounceHandler.Receive)
+	// Large downloads share a cap so they can't tie up the database and disk
+	var downloadLimits []func(http.Handler) http.Handler
+	if cfg.MaxConcurrentDownloads > 0 {
+		downloadLimits = append(downloadLimits, customMiddleware.ConcurrencyLimit(cfg.MaxConcurrentDownloads, 0, 0))
+	}
+
+	// Signed, expiring links to files kept on disk
+	serveLocalStores(r.With(downloadLimits...), archiveStore, exportStore, uploadStore)
+	if backups != nil {
+		serveLocalStores(r.With(downloadLimits...), backups.Store())
+	}
 	
 	// Form submission endpoint (public) with rate limiting
-	r.With(customMiddleware.IPRateLimit(time.Minute, 10)).Post("/api/v1/submit/{formKey}", submissionHandler.SubmitForm)
+	// Concurrent submissions are capped to protect the database writer during spikes
+	submitLimits := []func(http.Handler) http.Handler{
+		customMiddleware.IPRateLimitWithStore(newRateLimiter("submit", time.Minute, 10)),
+	}
+	if cfg.MaxConcurrentSubmissions > 0 {
+		submitLimits = append(submitLimits, customMiddleware.ConcurrencyLimit(cfg.MaxConcurrentSubmissions, cfg.MaxQueuedSubmissions, cfg.SubmissionQueueTimeout))
+	}
+	r.With(submitLimits...).Post("/api/v1/submit/{formKey}", submissionHandler.SubmitForm)
+
+	// REST hooks and polling for Zapier, Make and similar tools (API key required)
+	r.Route("/api/v1/hooks", func(r chi.Router) {
+		r.Use(customMiddleware.APIKeyAuth(db))
+		r.Get("/me", hooksHandler.Me)
+		r.With(customMiddleware.RequirePermission(auth.PermissionFormsRead)).Get("/forms", hooksHandler.Forms)
+		r.Group(func(r chi.Router) {
+			r.Use(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead))
+			r.Get("/forms/{id}/submissions", hooksHandler.Submissions)
+			r.Get("/forms/{id}/sample", hooksHandler.Sample)
+			r.Post("/subscribe", hooksHandler.Subscribe)
+			r.Post("/unsubscribe", hooksHandler.UnsubscribeByBody)
+			r.Delete("/{hookID}", hooksHandler.Unsubscribe)
+		})
+	})
 
 	// Web pages
 	r.Get("/login", webHandler.LoginPage)
 	r.Get("/register", webHandler.RegisterPage)
 
-	// Form-based authentication routes with rate limiting
-	r.With(customMiddleware.IPRateLimit(time.Minute, 5)).Post("/auth/register", webAuthHandler.RegisterForm)
-	r.With(customMiddleware.IPRateLimit(time.Minute, 10)).Post("/auth/login", webAuthHandler.LoginForm)
+	// Form-based authentication routes with rate limiting. Each IP gets 5
+	// sign in attempts, then one every 12 seconds, and 3 registrations, then
+	// one every 10 minutes. Each account gets 5 attempts, then one a minute,
+	// whichever IPs they come from.
+	webAuthHandler.AccountLimiter = newRateLimiter("login-account", time.Minute, 5)
+	r.With(customMiddleware.IPRateLimitWithStore(newRateLimiter("register", 10*time.Minute, 3))).Post("/auth/register", webAuthHandler.RegisterForm)
+	r.With(customMiddleware.IPRateLimitWithStore(newRateLimiter("login", 12*time.Second, 5))).Post("/auth/login", webAuthHandler.LoginForm)
 	r.Get("/auth/logout", webAuthHandler.Logout)
 
+	// First-run setup; creating the account only works while there are no users
+	r.Get("/setup", setupHandler.SetupPage)
+	r.With(customMiddleware.IPRateLimitWithStore(newRateLimiter("setup", time.Minute, 5))).Post("/setup/account", setupHandler.CreateAccount)
+
 	// Protected routes (require authentication)
 	r.Group(func(r chi.Router) {
 		r.Use(customMiddleware.AuthMiddleware(customMiddleware.AuthConfig{
 			SecretKey: secretKey,
-			DB:        &database.Database{Connection: database.DB},
+			DB:        db,
 			PublicPaths: []string{"/login", "/register", "/health"},
 		}))
 
 		r.Get("/", webHandler.Dashboard) // Root route now protected
 		r.Get("/dashboard", webHandler.Dashboard)
-		r.Get("/settings", settingsHandler.SettingsPage)
-		r.Post("/settings/update", settingsHandler.UpdateSettings)
-		r.Get("/forms/new", webHandler.CreateFormModal)
-		r.Get("/forms/{id}/view", webHandler.ViewFormModal)
-		r.Get("/forms/{id}/edit", webHandler.EditFormModal)
-		r.Get("/forms/{id}/submissions", webHandler.FormSubmissions)
-		
-		// Form API routes
-		r.Post("/forms", formHandler.CreateForm)
-		r.Get("/forms/{id}", formHandler.GetForm)
-		r.Put("/forms/{id}", formHandler.UpdateForm)
-		r.Delete("/forms/{id}", formHandler.DeleteForm)
-		r.Get("/api/forms", formHandler.GetUserForms)
-	})
+		r.Get("/dashboard/forms", webHandler.DashboardForms)
+		r.Get("/dashboard/stats", webHandler.ActivityStats)
+		r.Get("/dashboard/exports", exportsHandler.DashboardExports)
+		r.Post("/account/theme", webHandler.UpdateTheme)
+		r.Get("/account/api-keys", apiKeysHandler.APIKeys)
+		r.Post("/account/api-keys", apiKeysHandler.CreateAPIKey)
+		r.Delete("/account/api-keys/{keyID}", apiKeysHandler.DeleteAPIKey)
+
+		// Application-wide settings (administrators only)
+		r.Group(func(r chi.Router) {
+			r.Use(customMiddleware.RequirePermission(auth.PermissionSettingsWrite))
+			r.Get("/admin", adminHandler.Overview)
+			r.Get("/admin/debug", adminHandler.Debug)
+			// Profiling and diagnostics, only when STATICSEND_DEBUG is on
+			if cfg.Debug {
+				r.Mount("/debug", debugHandler.Routes())
+			}
+			r.Get("/setup/email", setupHandler.EmailPage)
+			r.Post("/setup/email", setupHandler.SaveEmail)
+			r.Get("/setup/site", setupHandler.SitePage)
+			r.Post("/setup/site", setupHandler.SaveSite)
+			r.Get("/settings", settingsHandler.SettingsPage)
+			r.Post("/settings/update", settingsHandler.UpdateSettings)
+			r.Get("/settings/logo", brandingHandler.ShowLogo)
+			r.Post("/settings/logo", brandingHandler.UploadLogo)
+			r.Delete("/settings/logo", brandingHandler.DeleteLogo)
+			r.Get("/settings/ip-rules", ipRulesHandler.GlobalIPRules)
+			r.Post("/settings/ip-rules", ipRulesHandler.CreateGlobalIPRule)
+			r.Delete("/settings/ip-rules/{ruleID}", ipRulesHandler.DeleteGlobalIPRule)
+			r.Get("/settings/quotas", quotasHandler.ListQuotas)
+			r.Post("/settings/quotas/{userID}", quotasHandler.UpdateQuota)
+			r.Get("/settings/data-requests", dataRequestsHandler.ShowDataRequests)
+			r.Post("/settings/data-requests/search", dataRequestsHandler.FindSubmissions)
+			r.Post("/settings/data-requests/export", dataRequestsHandler.ExportSubmissions)
+			r.Post("/settings/data-requests/erase", dataRequestsHandler.EraseSubmissions)
+			r.Get("/settings/backups", backupsHandler.ListBackups)
+			r.Post("/settings/backups", backupsHandler.BackupNow)
+			r.With(downloadLimits...).Get("/settings/backups/{name}/download", backupsHandler.Download)
+			r.Get("/settings/integrity", integrityHandler.ShowIntegrity)
+			r.Post("/settings/integrity/check", integrityHandler.CheckNow)
+			r.Post("/settings/integrity/repair", integrityHandler.RepairNow)
+		})
 
-	// Test endpoint for rate limiting
-	r.With(customMiddleware.IPRateLimit(time.Second, 2)).Get("/test-rate-limit", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Rate limited endpoint - you should see this only 2 times per second per IP"))
+		// Viewing forms and their submissions
+		r.Group(func(r chi.Router) {
+			r.Use(customMiddleware.RequirePermission(auth.PermissionFormsRead))
+			r.Get("/forms/{id}/view", webHandler.ViewFormModal)
+			r.Get("/forms/{id}/code", webHandler.FormCode)
+			r.Get("/forms/{id}/status-page", webHandler.StatusPageSettings)
+			r.Get("/forms/{id}", formHandler.GetForm)
+			r.Get("/api/forms", formHandler.GetUserForms)
+			r.Get("/api/stats", formHandler.GetStats)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/submissions", webHandler.FormSubmissions)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/submissions/table", webHandler.SubmissionsTable)
+			// Column choices are a viewing preference, so reading is enough
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Post("/forms/{id}/submissions/columns", webHandler.UpdateSubmissionColumns)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).With(downloadLimits...).Get("/forms/{id}/archive", archivesHandler.ExportArchive)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/exports", exportsHandler.FormExports)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Post("/forms/{id}/exports", exportsHandler.CreateExport)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/submissions/{submissionID}", submissionDetailHandler.ViewSubmission)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/submissions/{submissionID}/inbox", inboxHandler.SubmissionInbox)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/submissions/{submissionID}/files/{fileID}", submissionDetailHandler.DownloadFile)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/spam", submissionDetailHandler.SpamQueue)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/spam/table", submissionDetailHandler.SpamTable)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/akismet", submissionDetailHandler.ShowAkismet)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/feed", webHandler.FeedSettings)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/fields", webHandler.FormFields)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Get("/forms/{id}/transfer", transfersHandler.ExportForm)
+		})
+
+		// Working submissions as an inbox and acting on them
+		r.Group(func(r chi.Router) {
+			r.Use(customMiddleware.RequirePermission(auth.PermissionSubmissionsWrite))
+			r.Post("/forms/{id}/submissions/{submissionID}/assignment", inboxHandler.UpdateAssignment)
+			r.Post("/forms/{id}/submissions/{submissionID}/notes", inboxHandler.CreateNote)
+			r.Delete("/forms/{id}/submissions/{submissionID}/notes/{noteID}", inboxHandler.DeleteNote)
+			r.Post("/forms/{id}/submissions/{submissionID}/spam", submissionDetailHandler.MarkSpam)
+			r.Post("/forms/{id}/submissions/{submissionID}/not-spam", submissionDetailHandler.NotSpam)
+			r.Delete("/forms/{id}/spam", submissionDetailHandler.DeleteAllSpam)
+			r.Post("/forms/{id}/submissions/{submissionID}/resend", submissionDetailHandler.ResendEmail)
+			r.Delete("/forms/{id}/submissions/{submissionID}", submissionDetailHandler.DeleteSubmission)
+			r.Post("/forms/{id}/import", importsHandler.ImportSubmissions)
+		})
+
+		// Managing forms
+		r.Group(func(r chi.Router) {
+			r.Use(customMiddleware.RequirePermission(auth.PermissionFormsWrite))
+			r.Get("/forms/new", webHandler.CreateFormModal)
+			r.Get("/forms/{id}/edit", webHandler.EditFormModal)
+			r.Post("/forms/{id}/email-bounce/clear", webHandler.ClearEmailBounce)
+			r.Post("/forms/{id}/mutes/unmute", webHandler.UnmuteRecipient)
+			r.Get("/forms/{id}/routes", webHandler.FormRoutes)
+			r.Post("/forms/{id}/routes", webHandler.CreateFormRoute)
+			r.Post("/forms/{id}/routes/{routeID}/move", webHandler.MoveFormRoute)
+			r.Delete("/forms/{id}/routes/{routeID}", webHandler.DeleteFormRoute)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsWrite)).Get("/forms/import", transfersHandler.ImportFormModal)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsWrite)).Post("/forms/import", transfersHandler.ImportForm)
+			r.Get("/forms/{id}/ip-rules", ipRulesHandler.FormIPRules)
+			r.Post("/forms/{id}/ip-rules", ipRulesHandler.CreateFormIPRule)
+			r.Delete("/forms/{id}/ip-rules/{ruleID}", ipRulesHandler.DeleteFormIPRule)
+			r.Get("/forms/{id}/channels", notificationsHandler.FormChannels)
+			r.Post("/forms/{id}/channels", notificationsHandler.CreateChannel)
+			r.Post("/forms/{id}/channels/{channelID}/toggle", notificationsHandler.ToggleChannel)
+			r.Post("/forms/{id}/channels/{channelID}/events", notificationsHandler.UpdateChannelEvents)
+			r.Post("/forms/{id}/akismet", submissionDetailHandler.UpdateAkismet)
+			r.Post("/forms/{id}/channels/{channelID}/test", notificationsHandler.TestChannel)
+			r.Post("/forms/{id}/channels/{channelID}/rotate", notificationsHandler.RotateSecret)
+			r.Delete("/forms/{id}/channels/{channelID}", notificationsHandler.DeleteChannel)
+			r.Get("/forms/{id}/sheet", sheetsHandler.FormSheet)
+			r.Post("/forms/{id}/sheet", sheetsHandler.SaveSheet)
+			r.Delete("/forms/{id}/sheet", sheetsHandler.RemoveSheet)
+			r.Post("/forms/{id}/sheet/backfill", sheetsHandler.Backfill)
+			r.Post("/forms/{id}/sheet/credentials", sheetsHandler.SaveCredentials)
+			r.Delete("/forms/{id}/sheet/credentials", sheetsHandler.DeleteCredentials)
+			r.Post("/forms/{id}/status-page", webHandler.EnableStatusPage)
+			r.Delete("/forms/{id}/status-page", webHandler.DisableStatusPage)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Post("/forms/{id}/feed", webHandler.EnableFeed)
+			r.With(customMiddleware.RequirePermission(auth.PermissionSubmissionsRead)).Delete("/forms/{id}/feed", webHandler.DisableFeed)
+
+			// Form API routes
+			r.Post("/forms", formHandler.CreateForm)
+			r.Post("/forms/{id}/duplicate", formHandler.DuplicateForm)
+			r.Put("/forms/{id}", formHandler.UpdateForm)
+			r.Delete("/forms/{id}", formHandler.DeleteForm)
+			// Revealing a secret takes the password, so guesses are limited
+			r.With(customMiddleware.IPRateLimitWithStore(newRateLimiter("reveal-secret", 12*time.Second, 5))).Post("/forms/{id}/secret", formHandler.RevealFormSecret)
+		})
 	})
 
-	log.Printf("Server starting on port %s", cfg.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.Port, r))
+	server := newHTTPServer(cfg, customMiddleware.BasePath(cfg.BasePath)(r))
+	listener, activated, err := newListener(cfg)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	log.Printf("Server starting on %s", listener.Addr())
+	go server.serve(listener)
+
+	// SIGHUP reloads the configuration, templates and log file
+	reloader := &reloader{
+		args:      os.Args[1:],
+		cfg:       cfg,
+		templates: tm,
+		settings:  liveSettings,
+		email:     emailService,
+		logs:      logs,
+		server:    server,
+		listener:  listener,
+		activated: activated,
+	}
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for {
+		select {
+		case err := <-server.errs:
+			log.Fatal(err)
+		case <-hangups:
+			reloader.reload()
+		}
+	}
+}
+
+// emailConfig returns the configured SMTP settings
+func emailConfig(cfg *config.Config) email.EmailConfig {
+	return email.EmailConfig{
+		Host:          cfg.EmailHost,
+		Port:          cfg.EmailPort,
+		Username:      cfg.EmailUsername,
+		Password:      cfg.EmailPassword,
+		From:          cfg.EmailFrom,
+		UseTLS:        cfg.EmailUseTLS,
+		Timeout:       cfg.EmailTimeout,
+		Proxy:         proxyURL(cfg),
+		HeloName:      cfg.EmailHeloName,
+		EnvelopeFrom:  cfg.EmailEnvelopeFrom,
+		DKIM:          dkimSigner(cfg),
+		Fallback:      fallbackEmailConfig(cfg),
+		FailoverAfter: cfg.EmailFailoverAfter,
+		FailbackAfter: cfg.EmailFailbackAfter,
+	}
+}
+
+// fallbackEmailConfig returns the SMTP server to fail over to, or nil for none
+func fallbackEmailConfig(cfg *config.Config) *email.EmailConfig {
+	if cfg.EmailFallbackHost == "" {
+		return nil
+	}
+	return &email.EmailConfig{
+		Host:     cfg.EmailFallbackHost,
+		Port:     cfg.EmailFallbackPort,
+		Username: cfg.EmailFallbackUsername,
+		Password: cfg.EmailFallbackPassword,
+		UseTLS:   cfg.EmailFallbackUseTLS,
+	}
+}
+
+// dkimSigner returns the configured DKIM signer, or nil to send unsigned
+func dkimSigner(cfg *config.Config) *email.DKIMSigner {
+	if cfg.EmailDKIMPrivateKey == "" {
+		return nil
+	}
+	// Checked when the configuration was loaded
+	signer, _ := email.NewDKIMSigner(cfg.EmailDKIMDomain, cfg.EmailDKIMSelector, []byte(cfg.EmailDKIMPrivateKey))
+	return signer
+}
+
+// proxyURL returns the configured outbound proxy, or nil for none
+func proxyURL(cfg *config.Config) *url.URL {
+	if cfg.OutboundProxy == "" {
+		return nil
+	}
+	// Checked when the configuration was loaded
+	proxyURL, _ := outbound.ParseProxy(cfg.OutboundProxy)
+	return proxyURL
+}
+
+// httpServer serves the app over HTTP, or HTTPS with the configured
+// certificate or ones from Let's Encrypt. It can serve on more than one
+// listener at a time while a reload moves it to another port.
+type httpServer struct {
+	*http.Server
+	// https is set when serving with a certificate file or Let's Encrypt
+	https    bool
+	certFile string
+	keyFile  string
+	// errs receives the error that stopped serving on a listener, other
+	// than one closed by a reload
+	errs chan error
+}
+
+// newHTTPServer creates the server for handler
+func newHTTPServer(cfg *config.Config, handler http.Handler) *httpServer {
+	server := &httpServer{
+		Server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+		https:    cfg.TLSCert != "" || len(cfg.AutocertDomains) > 0,
+		certFile: cfg.TLSCert,
+		keyFile:  cfg.TLSKey,
+		errs:     make(chan error, 1),
+	}
+	if cfg.TLSCert != "" {
+		log.Printf("Serving HTTPS with %s", cfg.TLSCert)
+		return server
+	}
+	if len(cfg.AutocertDomains) == 0 {
+		return server
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+		Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		Email:      cfg.AutocertEmail,
+	}
+	server.TLSConfig = manager.TLSConfig()
+
+	// Let's Encrypt checks HTTP-01 challenges on port 80, which otherwise
+	// redirects to HTTPS
+	challenges := &http.Server{
+		Addr:              ":80",
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	go func() {
+		if err := challenges.ListenAndServe(); err != nil {
+			log.Fatalf("Failed to serve ACME challenges on port 80: %v", err)
+		}
+	}()
+	log.Printf("Serving HTTPS with Let's Encrypt certificates for %v", cfg.AutocertDomains)
+	return server
+}
+
+// serve serves on listener until it's closed
+func (s *httpServer) serve(listener net.Listener) {
+	var err error
+	if s.https {
+		err = s.ServeTLS(listener, s.certFile, s.keyFile)
+	} else {
+		err = s.Serve(listener)
+	}
+	if errors.Is(err, net.ErrClosed) {
+		return
+	}
+	s.errs <- err
+}
+
+// rateLimiterFactory returns a constructor for rate limiter stores backed by
+// the configured RATE_LIMIT_STORE (memory or redis)
+func rateLimiterFactory(cfg *config.Config) (func(name string, rate time.Duration, burst int) customMiddleware.LimiterStore, error) {
+	switch cfg.RateLimitStore {
+	case "", "memory":
+		return func(name string, rate time.Duration, burst int) customMiddleware.LimiterStore {
+			return customMiddleware.NewRateLimiter(rate, burst)
+		}, nil
+	case "redis":
+		if cfg.RedisURL == "" {
+			return nil, fmt.Errorf("REDIS_URL is required when RATE_LIMIT_STORE=redis")
+		}
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		client := redis.NewClient(opts)
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			// Not fatal: the limiter fails open until Redis becomes reachable
+			log.Printf("Warning: Redis is not reachable: %v", err)
+		}
+		log.Printf("Using Redis rate limiter store at %s", opts.Addr)
+		return func(name string, rate time.Duration, burst int) customMiddleware.LimiterStore {
+			return customMiddleware.NewRedisRateLimiter(client, name, rate, burst)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q (expected memory or redis)", cfg.RateLimitStore)
+	}
+}
+
+// backupManager returns a backup manager writing to the configured S3 bucket
+// or local directory, or nil when the database isn't SQLite
+func backupManager(cfg *config.Config, db *database.Database, storageCfg storage.Config) (*backup.Manager, error) {
+	if db.Dialect != database.SQLite {
+		return nil, nil
+	}
+
+	store, err := storage.New(storageCfg, cfg.BackupDir, "", "/files/backups")
+	if err != nil {
+		return nil, err
+	}
+	return backup.NewManager(db, store, cfg.BackupRetention), nil
+}
+
+// storageConfig returns where files are kept: in the S3 bucket when one is
+// configured, and in local directories otherwise
+func storageConfig(cfg *config.Config, secretKey []byte) storage.Config {
+	backend := cfg.StorageBackend
+	if backend == "" && cfg.StorageS3Bucket != "" {
+		backend = "s3"
+	}
+	return storage.Config{
+		Backend: backend,
+		S3: storage.S3Config{
+			Endpoint:  cfg.StorageS3Endpoint,
+			Region:    cfg.StorageS3Region,
+			Bucket:    cfg.StorageS3Bucket,
+			Prefix:    cfg.StorageS3Prefix,
+			AccessKey: cfg.StorageS3AccessKey,
+			SecretKey: cfg.StorageS3SecretKey,
+			Insecure:  cfg.StorageS3Insecure,
+		},
+		Secret: secretKey,
+	}
+}
+
+// serveLocalStores serves the signed download links of stores kept on disk;
+// links to files in a bucket go straight to the bucket
+func serveLocalStores(r chi.Router, stores ...storage.Store) {
+	for _, store := range stores {
+		if local, ok := store.(*storage.LocalStore); ok && local.Route() != "" {
+			r.Get(local.Route()+"/{name}", local.ServeHTTP)
+		}
+	}
 }
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// filesFrom returns the directory on disk when one is configured, so templates
+// or assets can be customised without rebuilding, and the embedded files otherwise
+func filesFrom(dir string, embedded fs.FS) fs.FS {
+	if dir == "" {
+		return embedded
 	}
-	return fallback
+	log.Printf("Using files from %s", dir)
+	return os.DirFS(dir)
 }
@@ -0,0 +1,22 @@
+package main
+
+// Submission hooks written in Go are compiled in by importing their package
+// here for its side effects. The package registers its hooks from an init
+// function:
+//
+//	func init() {
+//		plugins.Register("crm", plugins.HookFunc(func(ctx context.Context, submission *plugins.Submission) error {
+//			if submission.Fields["email"] == "" {
+//				return plugins.Reject("An email address is required")
+//			}
+//			submission.Fields["crm_id"] = lookUp(submission.Fields["email"])
+//			return nil
+//		}))
+//	}
+//
+// and is imported with a blank identifier:
+//
+//	import _ "example.com/staticsend-crm"
+//
+// Hooks run on every submission in the order they're registered, before
+// any commands named by SUBMISSION_HOOK_COMMANDS.
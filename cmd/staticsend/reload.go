@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"staticsend/pkg/config"
+	"staticsend/pkg/email"
+	"staticsend/pkg/livesettings"
+	"staticsend/pkg/logfile"
+	"staticsend/pkg/templates"
+)
+
+// reloadable lists the settings a reload applies; the rest take effect at
+// the next restart
+var reloadable = map[string]bool{
+	"port":                    true,
+	"listen_socket":           true,
+	"socket_mode":             true,
+	"base_url":                true,
+	"turnstile_public_key":    true,
+	"turnstile_secret_key":    true,
+	"email_host":              true,
+	"email_port":              true,
+	"email_username":          true,
+	"email_password":          true,
+	"email_from":              true,
+	"email_use_tls":           true,
+	"email_timeout":           true,
+	"email_helo_name":         true,
+	"email_envelope_from":     true,
+	"email_dkim_domain":       true,
+	"email_dkim_selector":     true,
+	"email_dkim_private_key":  true,
+	"email_fallback_host":     true,
+	"email_fallback_port":     true,
+	"email_fallback_username": true,
+	"email_fallback_password": true,
+	"email_fallback_use_tls":  true,
+	"email_failover_after":    true,
+	"email_failback_after":    true,
+	"log_file":                true,
+}
+
+// reloader applies a changed configuration while the server runs, when it's
+// sent SIGHUP
+type reloader struct {
+	args      []string
+	cfg       *config.Config
+	templates *templates.TemplateManager
+	settings  *livesettings.Store
+	email     *email.EmailService
+	logs      *logfile.File
+	server    *httpServer
+	listener  net.Listener
+	// activated is set when listening on a socket from systemd, which is
+	// kept whatever the configuration says
+	activated bool
+}
+
+// reload reopens the log file, reloads the templates and reads the
+// configuration again, applying what it can and logging what changed
+func (rl *reloader) reload() {
+	if rl.logs != nil {
+		if err := rl.logs.Reopen(); err != nil {
+			log.Printf("Failed to reopen log file: %v", err)
+		}
+	}
+	log.Printf("Reloading configuration")
+
+	if err := rl.templates.Reload(); err != nil {
+		log.Printf("Failed to reload templates, keeping the current ones: %v", err)
+	}
+
+	cfg, err := config.Load(rl.args, io.Discard)
+	if err != nil {
+		log.Printf("Configuration not reloaded:\n%v", err)
+		return
+	}
+	changed := config.Changed(rl.cfg, cfg)
+	if len(changed) == 0 {
+		log.Printf("Reloaded templates; no settings changed")
+		return
+	}
+
+	var applied, pending []string
+	for _, key := range changed {
+		if reloadable[key] {
+			applied = append(applied, key)
+		} else {
+			pending = append(pending, key)
+		}
+	}
+	if !rl.applyLogFile(cfg) || !rl.applyListener(cfg) {
+		return
+	}
+	rl.applySettings(cfg)
+	rl.cfg = cfg
+
+	if len(applied) > 0 {
+		log.Printf("Applied changes to %s", strings.Join(applied, ", "))
+	}
+	if len(pending) > 0 {
+		log.Printf("Changes to %s take effect after a restart", strings.Join(pending, ", "))
+	}
+}
+
+// applyLogFile switches logging to a new LOG_FILE, reporting whether the
+// rest of the configuration can be applied
+func (rl *reloader) applyLogFile(cfg *config.Config) bool {
+	switch {
+	case cfg.LogFile == rl.cfg.LogFile:
+	case cfg.LogFile == "":
+		log.SetOutput(os.Stderr)
+		rl.logs.Close()
+		rl.logs = nil
+	case rl.logs == nil:
+		logs, err := logfile.Open(cfg.LogFile)
+		if err != nil {
+			log.Printf("Configuration not reloaded: %v", err)
+			return false
+		}
+		rl.logs = logs
+		log.SetOutput(logs)
+	default:
+		if err := rl.logs.OpenPath(cfg.LogFile); err != nil {
+			log.Printf("Configuration not reloaded: %v", err)
+			return false
+		}
+	}
+	return true
+}
+
+// applyListener moves the server to a new port or Unix socket. The old
+// listener is only closed once the new one is serving, and connections
+// already open on it carry on, so no requests are dropped.
+func (rl *reloader) applyListener(cfg *config.Config) bool {
+	moved := cfg.Port != rl.cfg.Port || cfg.ListenSocket != rl.cfg.ListenSocket
+	if rl.activated {
+		if moved {
+			log.Printf("Keeping the socket from systemd; change the socket unit to listen elsewhere")
+		}
+		return true
+	}
+
+	if !moved {
+		if cfg.ListenSocket != "" && cfg.SocketMode != rl.cfg.SocketMode {
+			mode, _ := strconv.ParseUint(cfg.SocketMode, 8, 32)
+			if err := os.Chmod(cfg.ListenSocket, os.FileMode(mode)); err != nil {
+				log.Printf("Failed to set socket permissions: %v", err)
+			}
+		}
+		return true
+	}
+
+	listener, _, err := newListener(cfg)
+	if err != nil {
+		log.Printf("Configuration not reloaded, still serving on %s: %v", rl.listener.Addr(), err)
+		return false
+	}
+	go rl.server.serve(listener)
+	rl.listener.Close()
+	log.Printf("Moved from %s to %s", rl.listener.Addr(), listener.Addr())
+	rl.listener = listener
+	return true
+}
+
+// applySettings applies the new base URL, Turnstile keys and SMTP settings
+// where they aren't overridden by ones saved on the settings page
+func (rl *reloader) applySettings(cfg *config.Config) {
+	rl.settings.SetDefaults(livesettings.Settings{
+		BaseURL:            cfg.BaseURL,
+		TurnstilePublicKey: cfg.TurnstilePublicKey,
+		TurnstileSecretKey: cfg.TurnstileSecretKey,
+	})
+	if err := rl.settings.Reload(context.Background()); err != nil {
+		log.Printf("Failed to reload settings: %v", err)
+	}
+	if rl.settings.Current().Email.Host == "" {
+		rl.email.SetConfig(emailConfig(cfg))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"staticsend/pkg/config"
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/livesettings"
+	"staticsend/pkg/templates"
+)
+
+// freePort returns a port nothing is listening on
+func freePort(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestReloader(t *testing.T) {
+	for _, name := range []string{"PORT", "STATICSEND_PORT", "BASE_URL", "STATICSEND_BASE_URL", "BACKUP_INTERVAL", config.FileEnv} {
+		t.Setenv(name, "")
+	}
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "staticsend.yaml")
+	writeConfig := func(port, baseURL, backupInterval string) {
+		t.Helper()
+		content := fmt.Sprintf("port: %q\nbase_url: %s\nbackup_interval: %s\n", port, baseURL, backupInterval)
+		if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"-config", configPath}
+	oldPort := freePort(t)
+	writeConfig(oldPort, "https://old.example.com", "24h")
+	cfg, err := config.Load(args, nil)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.Init(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	settings := livesettings.NewStore(db, livesettings.Settings{BaseURL: cfg.BaseURL})
+
+	server := newHTTPServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	listener, _, err := newListener(cfg)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.serve(listener)
+	defer server.Close()
+
+	rl := &reloader{
+		args:      args,
+		cfg:       cfg,
+		templates: templates.NewTemplateManager(),
+		settings:  settings,
+		email:     email.NewEmailService(emailConfig(cfg), 10, 0, 0),
+		server:    server,
+		listener:  listener,
+	}
+
+	newPort := freePort(t)
+	writeConfig(newPort, "https://new.example.com", "1h")
+	rl.reload()
+
+	if settings.Current().BaseURL != "https://new.example.com" {
+		t.Errorf("Expected the new base URL to apply, got %q", settings.Current().BaseURL)
+	}
+	resp, err := http.Get("http://127.0.0.1:" + newPort + "/")
+	if err != nil {
+		t.Fatalf("Expected the server to move to the new port: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := net.Dial("tcp", "127.0.0.1:"+oldPort); err == nil {
+		t.Error("Expected the old port to be closed")
+	}
+	if rl.cfg.BackupInterval.String() != "1h0m0s" {
+		t.Errorf("Expected the new configuration to be kept, got a backup interval of %s", rl.cfg.BackupInterval)
+	}
+
+	// An invalid configuration leaves everything as it was
+	writeConfig("not-a-port", "https://broken.example.com", "1h")
+	rl.reload()
+	if rl.cfg.Port != newPort || settings.Current().BaseURL != "https://new.example.com" {
+		t.Errorf("Expected an invalid configuration to be ignored, got port %s and %q", rl.cfg.Port, settings.Current().BaseURL)
+	}
+
+	select {
+	case err := <-server.errs:
+		t.Errorf("Expected closing the old listener not to be reported, got %v", err)
+	default:
+	}
+}
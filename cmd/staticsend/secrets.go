@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/config"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/secrets"
+)
+
+// useSecretsKey sets the master key third-party credentials are encrypted
+// with in the database: SECRETS_KEY, or the key kept at SECRETS_KEY_PATH.
+// The server generates that key on its first run; commands only read it, so
+// running one from another directory doesn't make a key that can't open the
+// secrets.
+func useSecretsKey(cfg *config.Config, generate bool) error {
+	key := []byte(cfg.SecretsKey)
+	switch {
+	case len(key) > 0:
+	case generate:
+		loaded, created, err := auth.LoadOrCreateSecretKey(cfg.SecretsKeyPath)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Printf("Generated a secrets key in %s; back it up apart from the database, whose secrets can't be read without it", cfg.SecretsKeyPath)
+		}
+		key = loaded
+	default:
+		data, err := os.ReadFile(cfg.SecretsKeyPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key = bytes.TrimSpace(data)
+	}
+
+	box, err := secrets.NewBox(key)
+	if err != nil {
+		return err
+	}
+	secrets.SetDefault(box)
+	return nil
+}
+
+// encryptSecrets encrypts the secrets saved before there was a key
+func encryptSecrets(ctx context.Context, db *database.Database) {
+	count, err := models.EncryptSecretsContext(ctx, db.Connection)
+	if err != nil {
+		log.Printf("Failed to encrypt saved secrets: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Encrypted %d saved secrets", count)
+	}
+}
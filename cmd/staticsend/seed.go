@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/config"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/utils"
+)
+
+// Sign in details for the demo account
+const (
+	demoEmail    = "demo@example.com"
+	demoPassword = "staticsend-demo"
+)
+
+// demoDays is how far back demo submissions go, matching the dashboard's
+// 30 day chart
+const demoDays = 30
+
+// demoForms are the forms created for the demo account
+var demoForms = []struct {
+	name, domain string
+	tags         []string
+	submissions  int
+}{
+	{"Contact", "www.example.com", []string{"demo", "website"}, 36},
+	{"Newsletter signup", "blog.example.com", []string{"demo"}, 18},
+}
+
+// demoPeople fill in the demo submissions
+var demoPeople = []struct{ name, email string }{
+	{"Ada Lovelace", "ada@example.com"},
+	{"Grace Hopper", "grace@example.org"},
+	{"Alan Turing", "alan@example.net"},
+	{"Katherine Johnson", "katherine@example.com"},
+	{"Linus Torvalds", "linus@example.org"},
+	{"Margaret Hamilton", "margaret@example.net"},
+}
+
+var demoMessages = []string{
+	"Hi, I'd like a quote for a new website.",
+	"Do you ship internationally?",
+	"Loved the latest post, thanks for writing it.",
+	"Can we book a call next week?",
+	"The contact link in your footer is broken.",
+	"Is the workshop still running in March?",
+}
+
+// seedDemo creates a demo account with forms and a month of synthetic
+// submissions, so the dashboard can be tried without a real site. It
+// refuses to run twice on the same database.
+func seedDemo(cfg *config.Config, out io.Writer) error {
+	ctx := context.Background()
+	dsn, sqliteOptions := databaseSource(cfg)
+	db, err := database.ConnectWithOptions(dsn, sqliteOptions)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	exists, err := models.UserExistsContext(ctx, db.Connection, demoEmail)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("the demo account already exists")
+	}
+
+	hash, err := auth.HashPassword(demoPassword)
+	if err != nil {
+		return err
+	}
+	user, err := models.CreateUserContext(ctx, db.Connection, demoEmail, hash)
+	if err != nil {
+		return fmt.Errorf("failed to create demo account: %w", err)
+	}
+
+	// A fixed seed gives every demo the same data
+	random := rand.New(rand.NewSource(1))
+	now := time.Now()
+	total := 0
+	for _, demo := range demoForms {
+		formKey, err := utils.GenerateFormKey()
+		if err != nil {
+			return err
+		}
+		form, err := models.CreateFormContext(ctx, db.Connection, user.ID, demo.name, demo.domain, "", demoEmail, formKey)
+		if err != nil {
+			return fmt.Errorf("failed to create form %q: %w", demo.name, err)
+		}
+		if err := models.SetFormTagsContext(ctx, db.Connection, user.ID, form.ID, demo.tags); err != nil {
+			return err
+		}
+
+		for i := 0; i < demo.submissions; i++ {
+			if err := seedSubmission(ctx, db, form, random, now); err != nil {
+				return fmt.Errorf("failed to create submission: %w", err)
+			}
+			total++
+		}
+	}
+
+	fmt.Fprintf(out, "Created %d forms with %d submissions\n", len(demoForms), total)
+	fmt.Fprintf(out, "Sign in as %s with the password %s\n", demoEmail, demoPassword)
+	return nil
+}
+
+// seedSubmission saves a random submission to form from the past month.
+// About one in ten is held as spam and a few failed to send.
+func seedSubmission(ctx context.Context, db *database.Database, form *models.Form, random *rand.Rand, now time.Time) error {
+	person := demoPeople[random.Intn(len(demoPeople))]
+	data := map[string]string{"name": person.name, "email": person.email}
+	if form.Name == "Contact" {
+		data["message"] = demoMessages[random.Intn(len(demoMessages))]
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	ip := fmt.Sprintf("203.0.113.%d", random.Intn(254)+1)
+	userAgent := "Mozilla/5.0 (demo)"
+	referrer := "https://" + form.Domain + "/"
+
+	var submission *models.Submission
+	roll := random.Intn(10)
+	if roll == 0 {
+		submission, err = models.CreateSpamSubmissionContext(ctx, db.Connection, form.ID, ip, userAgent, referrer, body, models.SpamReasonHoneypot)
+	} else {
+		submission, err = models.CreateReferredSubmissionContext(ctx, db.Connection, form.ID, ip, userAgent, referrer, body)
+	}
+	if err != nil {
+		return err
+	}
+	if roll != 0 {
+		status := "processed"
+		if roll == 1 {
+			status = "failed"
+		}
+		if err := models.UpdateSubmissionStatusContext(ctx, db.Connection, submission.ID, status); err != nil {
+			return err
+		}
+	}
+
+	age := time.Duration(random.Int63n(int64(demoDays * 24 * time.Hour)))
+	return models.SetSubmissionCreatedAtContext(ctx, db.Connection, submission.ID, now.Add(-age))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/config"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestSeedDemo(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.DatabasePath = filepath.Join(t.TempDir(), "staticsend.db")
+
+	var out strings.Builder
+	if err := runCommand(cfg, []string{"seed-demo"}, &out); err != nil {
+		t.Fatalf("seed-demo failed: %v", err)
+	}
+	if !strings.Contains(out.String(), demoEmail) {
+		t.Errorf("Expected the demo sign in to be printed, got %q", out.String())
+	}
+	if err := runCommand(cfg, []string{"seed-demo"}, &strings.Builder{}); err == nil {
+		t.Error("Expected seeding twice to fail")
+	}
+
+	db, err := database.Init(cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, err := models.GetUserByEmail(db.Connection, demoEmail)
+	if err != nil || user == nil {
+		t.Fatalf("Expected the demo account, got %v, %v", user, err)
+	}
+	forms, err := models.GetFormsByUserID(db.Connection, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forms) != len(demoForms) {
+		t.Fatalf("Expected %d forms, got %d", len(demoForms), len(forms))
+	}
+	submissions, err := models.GetSubmissionsByFormID(db.Connection, forms[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(submissions) == 0 {
+		t.Error("Expected demo submissions")
+	}
+}
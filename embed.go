@@ -0,0 +1,42 @@
+// Package staticsend embeds the files the server needs at runtime, so the
+// binary works from any directory without a copy of the repository.
+package staticsend
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+//go:embed templates
+var templateFiles embed.FS
+
+//go:embed static
+var staticFiles embed.FS
+
+// MigrationsFS returns the embedded migrations directory
+func MigrationsFS() fs.FS {
+	return subFS(migrationFiles, "migrations")
+}
+
+// TemplatesFS returns the embedded templates directory
+func TemplatesFS() fs.FS {
+	return subFS(templateFiles, "templates")
+}
+
+// StaticFS returns the embedded static assets directory
+func StaticFS() fs.FS {
+	return subFS(staticFiles, "static")
+}
+
+// subFS roots an embedded file system at dir, which always exists because
+// the go:embed directives above would fail to compile otherwise
+func subFS(fsys embed.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
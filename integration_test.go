@@ -1,6 +1,8 @@
-package main
+package staticsend_test
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -13,8 +15,10 @@ import (
 	"staticsend/pkg/api"
 	"staticsend/pkg/database"
 	"staticsend/pkg/email"
+	"staticsend/pkg/httperr"
 	"staticsend/pkg/middleware"
 	"staticsend/pkg/models"
+	"staticsend/pkg/turnstile"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -35,65 +39,12 @@ func SetupIntegrationTest(t *testing.T) *IntegrationTestSuite {
 	tempDir := t.TempDir()
 	dbPath := filepath.Join(tempDir, "integration_test.db")
 	
-	err := database.Init(dbPath)
+	// Migrations are embedded in the binary, so the full schema is always available
+	dbWrapper, err := database.Init(dbPath)
 	if err != nil {
-		// If migration files don't exist, create a minimal schema
-		db, err := database.DB.Begin()
-		if err != nil {
-			t.Fatalf("Failed to create test database: %v", err)
-		}
-		
-		// Create minimal schema for testing
-		schema := `
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-		
-		CREATE TABLE IF NOT EXISTS forms (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			form_key TEXT UNIQUE NOT NULL,
-			domain TEXT NOT NULL,
-			turnstile_public_key TEXT,
-			turnstile_secret_key TEXT,
-			notification_email TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		);
-		
-		CREATE TABLE IF NOT EXISTS submissions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			form_id INTEGER NOT NULL,
-			data TEXT NOT NULL,
-			ip_address TEXT,
-			user_agent TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (form_id) REFERENCES forms(id)
-		);
-		
-		CREATE TABLE IF NOT EXISTS app_settings (
-			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`
-		
-		_, err = db.Exec(schema)
-		if err != nil {
-			t.Fatalf("Failed to create test schema: %v", err)
-		}
-		
-		err = db.Commit()
-		if err != nil {
-			t.Fatalf("Failed to commit test schema: %v", err)
-		}
+		t.Fatalf("Failed to create test database: %v", err)
 	}
 	
-	dbWrapper := &database.Database{Connection: database.DB}
-	
 	// Create email service
 	emailConfig := email.EmailConfig{
 		Host:     "localhost",
@@ -106,7 +57,22 @@ func SetupIntegrationTest(t *testing.T) *IntegrationTestSuite {
 	emailService := email.NewEmailService(emailConfig, 10, 1, 1)
 	
 	// Create handlers
-	apiHandler := api.NewSubmissionHandler(database.DB, emailService)
+	apiHandler := api.NewSubmissionHandler(dbWrapper, emailService)
+	if err := apiHandler.PrepareStatements(context.Background()); err != nil {
+		t.Fatalf("Failed to prepare statements: %v", err)
+	}
+	t.Cleanup(func() { apiHandler.Close() })
+	
+	// Stand in for Cloudflare, rejecting every token but the valid one
+	turnstileServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("response") == "valid-token" {
+			json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: true})
+			return
+		}
+		json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: false, ErrorCodes: []string{"invalid-input-response"}})
+	}))
+	t.Cleanup(turnstileServer.Close)
+	apiHandler.Turnstile = turnstile.NewClient(turnstileServer.URL, time.Second)
 	
 	// Create router
 	r := chi.NewRouter()
@@ -129,19 +95,19 @@ func SetupIntegrationTest(t *testing.T) *IntegrationTestSuite {
 	server := httptest.NewServer(r)
 	
 	// Create test user
-	testUser, err := models.CreateUser(database.DB, "test@example.com", "$2a$12$LQv3c1yqBWVHxkd0LHAkCOYz6TtxMQJqhN8/LewdBPj/VcSAg/9qm") // "password123"
+	testUser, err := models.CreateUser(dbWrapper.Connection, "test@example.com", "$2a$12$LQv3c1yqBWVHxkd0LHAkCOYz6TtxMQJqhN8/LewdBPj/VcSAg/9qm") // "password123"
 	if err != nil {
 		t.Fatalf("Failed to create test user: %v", err)
 	}
 	
 	// Create test form
-	testForm, err := models.CreateForm(database.DB, testUser.ID, "Test Form", "example.com", "test-public", "test-secret", "admin@example.com")
+	testForm, err := models.CreateForm(dbWrapper.Connection, testUser.ID, "Test Form", "example.com", "test-public", "test-secret", "admin@example.com")
 	if err != nil {
 		t.Fatalf("Failed to create test form: %v", err)
 	}
 	
 	// Enable registration for tests
-	models.UpdateAppSetting(database.DB, "registration_enabled", "true")
+	models.UpdateAppSetting(dbWrapper.Connection, "registration_enabled", "true")
 	
 	return &IntegrationTestSuite{
 		Server:       server,
@@ -157,7 +123,7 @@ func SetupIntegrationTest(t *testing.T) *IntegrationTestSuite {
 func (suite *IntegrationTestSuite) Cleanup() {
 	suite.Server.Close()
 	suite.EmailService.Shutdown()
-	database.Close()
+	suite.DB.Close()
 }
 
 // TestFormSubmissionFlow tests the complete form submission workflow
@@ -185,15 +151,18 @@ func TestFormSubmissionFlow(t *testing.T) {
 		defer resp.Body.Close()
 		
 		// Note: This will fail with Turnstile validation, but we can check the error
-		body, _ := io.ReadAll(resp.Body)
+		var envelope httperr.Envelope
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatalf("Failed to decode error response: %v", err)
+		}
 		
 		// Should get Turnstile validation error (expected in test environment)
 		if resp.StatusCode != http.StatusBadRequest {
 			t.Errorf("Expected status 400 (Turnstile validation failure), got %d", resp.StatusCode)
 		}
 		
-		if !strings.Contains(string(body), "Invalid Turnstile token") {
-			t.Errorf("Expected Turnstile validation error, got: %s", string(body))
+		if envelope.Error != httperr.TurnstileFailed || envelope.Message != "Invalid Turnstile token" {
+			t.Errorf("Expected Turnstile validation error, got: %+v", envelope)
 		}
 	})
 	
@@ -242,6 +211,65 @@ func TestFormSubmissionFlow(t *testing.T) {
 			t.Errorf("Expected Turnstile required error, got: %s", string(body))
 		}
 	})
+	
+	t.Run("honeypot filled in", func(t *testing.T) {
+		formData := url.Values{}
+		formData.Set("name", "Spam Bot")
+		formData.Set(models.HoneypotField, "http://spam.example.com")
+		formData.Set("cf-turnstile-response", "valid-token")
+		
+		resp, err := http.Post(
+			suite.Server.URL+"/api/v1/submit/"+suite.TestForm.FormKey,
+			"application/x-www-form-urlencoded",
+			strings.NewReader(formData.Encode()),
+		)
+		if err != nil {
+			t.Fatalf("Failed to submit form: %v", err)
+		}
+		defer resp.Body.Close()
+		
+		// Bots are told it worked, but the submission is held as spam
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", resp.StatusCode)
+		}
+		submissions, err := models.GetSubmissionsPage(suite.DB.Connection, suite.TestForm.ID, models.SubmissionFilter{Spam: true}, 10, 0)
+		if err != nil {
+			t.Fatalf("Failed to list spam: %v", err)
+		}
+		if len(submissions) != 1 || submissions[0].SpamReason != models.SpamReasonHoneypot || submissions[0].Status != "pending" {
+			t.Fatalf("Expected one pending submission held by the honeypot, got %+v", submissions)
+		}
+		models.DeleteSpamSubmissions(suite.DB.Connection, suite.TestForm.ID)
+	})
+	
+	t.Run("monthly submission limit reached", func(t *testing.T) {
+		models.UpdateAppSetting(suite.DB.Connection, models.SettingDefaultMaxMonthlySubmissions, "1")
+		defer models.UpdateAppSetting(suite.DB.Connection, models.SettingDefaultMaxMonthlySubmissions, "0")
+		if _, err := models.CreateSubmission(suite.DB.Connection, suite.TestForm.ID, "127.0.0.1", "test", []byte(`{}`)); err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+		
+		formData := url.Values{}
+		formData.Set("name", "John Doe")
+		formData.Set("cf-turnstile-response", "fake-token")
+		
+		resp, err := http.Post(
+			suite.Server.URL+"/api/v1/submit/"+suite.TestForm.FormKey,
+			"application/x-www-form-urlencoded",
+			strings.NewReader(formData.Encode()),
+		)
+		if err != nil {
+			t.Fatalf("Failed to submit form: %v", err)
+		}
+		defer resp.Body.Close()
+		
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") == "" {
+			t.Error("Expected a Retry-After header")
+		}
+	})
 }
 
 // TestHealthCheck tests the health check endpoint
@@ -0,0 +1,27 @@
+-- Add IP allow/deny rules and an audit log of blocked submissions
+-- Rules without a form_id apply to submissions for every form
+
+CREATE TABLE ip_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    form_id INTEGER,
+    cidr TEXT NOT NULL,
+    action TEXT NOT NULL CHECK(action IN ('allow', 'deny')),
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE TABLE blocked_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    form_id INTEGER,
+    rule_id INTEGER,
+    ip_address TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE,
+    FOREIGN KEY (rule_id) REFERENCES ip_rules (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_ip_rules_form_id ON ip_rules(form_id);
+CREATE INDEX idx_blocked_attempts_form_id ON blocked_attempts(form_id);
+CREATE INDEX idx_blocked_attempts_created_at ON blocked_attempts(created_at);
@@ -0,0 +1,2 @@
+-- Remove user roles
+ALTER TABLE users DROP COLUMN role;
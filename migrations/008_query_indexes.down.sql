@@ -0,0 +1,4 @@
+-- Drop the composite query indexes
+DROP INDEX IF EXISTS idx_blocked_attempts_form_id_created_at;
+DROP INDEX IF EXISTS idx_forms_user_id_created_at;
+DROP INDEX IF EXISTS idx_submissions_form_id_created_at;
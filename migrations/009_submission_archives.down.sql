@@ -0,0 +1,2 @@
+-- Drop the submission archive manifest
+DROP TABLE IF EXISTS submission_archives;
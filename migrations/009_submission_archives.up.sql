@@ -0,0 +1,15 @@
+-- Manifest of archive files holding submissions moved out of the live table
+
+CREATE TABLE submission_archives (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    form_id INTEGER NOT NULL,
+    name TEXT NOT NULL UNIQUE,
+    submission_count INTEGER NOT NULL,
+    first_submission_at DATETIME NOT NULL,
+    last_submission_at DATETIME NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_submission_archives_form_id ON submission_archives(form_id);
@@ -0,0 +1,3 @@
+-- Remove usage quotas
+DROP TABLE IF EXISTS user_quotas;
+DELETE FROM app_settings WHERE key IN ('default_max_forms', 'default_max_monthly_submissions', 'default_max_storage_mb');
@@ -0,0 +1,16 @@
+-- Add usage quotas with instance-wide defaults and per-user overrides
+-- NULL overrides use the default from app_settings; 0 means unlimited
+
+CREATE TABLE user_quotas (
+    user_id INTEGER PRIMARY KEY,
+    max_forms INTEGER,
+    max_monthly_submissions INTEGER,
+    max_storage_bytes INTEGER,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT INTO app_settings (key, value, description) VALUES
+('default_max_forms', '0', 'Default maximum number of forms per user (0 for unlimited)'),
+('default_max_monthly_submissions', '0', 'Default maximum submissions per user each calendar month (0 for unlimited)'),
+('default_max_storage_mb', '0', 'Default maximum size of stored submissions per user in MB (0 for unlimited)');
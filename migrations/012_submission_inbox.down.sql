@@ -0,0 +1,3 @@
+-- Drop submission notes and assignments
+DROP TABLE IF EXISTS submission_notes;
+DROP TABLE IF EXISTS submission_assignments;
@@ -0,0 +1,2 @@
+-- Remove user themes
+ALTER TABLE users DROP COLUMN theme;
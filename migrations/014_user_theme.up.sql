@@ -0,0 +1,2 @@
+-- Add each user's colour theme; system follows the browser's preference
+ALTER TABLE users ADD COLUMN theme TEXT NOT NULL DEFAULT 'system' CHECK (theme IN ('system', 'light', 'dark'));
@@ -0,0 +1,11 @@
+-- Add branding: a primary colour setting and uploaded images such as the logo
+
+CREATE TABLE site_assets (
+    name TEXT PRIMARY KEY,
+    content_type TEXT NOT NULL,
+    data BLOB NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO app_settings (key, value, description) VALUES
+('primary_color', '#2563eb', 'Colour of buttons and links as a hex code, e.g. #2563eb');
@@ -0,0 +1,21 @@
+-- Exports of a form's submissions written in the background
+
+CREATE TABLE export_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    form_id INTEGER NOT NULL,
+    format TEXT NOT NULL DEFAULT 'csv' CHECK(format IN ('csv', 'jsonl')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'running', 'done', 'failed')),
+    file_name TEXT NOT NULL DEFAULT '',
+    row_count INTEGER NOT NULL DEFAULT 0,
+    size INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    expires_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_export_jobs_user_id ON export_jobs(user_id);
+CREATE INDEX idx_export_jobs_status ON export_jobs(status);
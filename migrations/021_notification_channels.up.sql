@@ -0,0 +1,32 @@
+-- Notification channels configured per form and the log of their deliveries
+
+CREATE TABLE notification_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    form_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    config TEXT NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_channels_form_id ON notification_channels(form_id);
+
+CREATE TABLE notification_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel_id INTEGER NOT NULL,
+    submission_id INTEGER,
+    event TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME,
+    FOREIGN KEY (channel_id) REFERENCES notification_channels (id) ON DELETE CASCADE,
+    FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_deliveries_channel_id ON notification_deliveries(channel_id, created_at);
+CREATE INDEX idx_notification_deliveries_submission_id ON notification_deliveries(submission_id);
@@ -0,0 +1,3 @@
+-- Drop Google Sheets sync
+DROP TABLE IF EXISTS form_sheets;
+DROP TABLE IF EXISTS google_credentials;
@@ -0,0 +1,23 @@
+-- Google Sheets sync: service account keys per user and the sheet each form
+-- appends its submissions to
+
+CREATE TABLE google_credentials (
+    user_id INTEGER PRIMARY KEY,
+    client_email TEXT NOT NULL,
+    credentials TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE form_sheets (
+    form_id INTEGER PRIMARY KEY,
+    spreadsheet_id TEXT NOT NULL,
+    sheet_name TEXT NOT NULL DEFAULT '',
+    mapping TEXT NOT NULL DEFAULT '[]',
+    last_submission_id INTEGER NOT NULL DEFAULT 0,
+    header_written BOOLEAN NOT NULL DEFAULT 0,
+    synced_at DATETIME,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
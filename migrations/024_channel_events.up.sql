@@ -0,0 +1,3 @@
+-- Events each notification channel is sent, comma separated; existing
+-- channels keep getting new submissions only
+ALTER TABLE notification_channels ADD COLUMN events TEXT NOT NULL DEFAULT 'submission.created';
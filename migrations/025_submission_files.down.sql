@@ -0,0 +1,2 @@
+-- Drop submission files
+DROP TABLE IF EXISTS submission_files;
@@ -0,0 +1,16 @@
+-- Files uploaded with a submission; the files themselves are kept in the
+-- uploads store under storage_name
+
+CREATE TABLE submission_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    submission_id INTEGER NOT NULL,
+    field TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL DEFAULT '',
+    size INTEGER NOT NULL DEFAULT 0,
+    storage_name TEXT NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_submission_files_submission_id ON submission_files(submission_id);
@@ -0,0 +1,3 @@
+-- Remove Akismet checks and spam scores
+ALTER TABLE submissions DROP COLUMN spam_score;
+ALTER TABLE forms DROP COLUMN akismet_enabled;
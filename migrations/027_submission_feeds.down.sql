@@ -0,0 +1,2 @@
+-- Remove submission feeds
+DROP TABLE IF EXISTS form_feeds;
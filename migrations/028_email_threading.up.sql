@@ -0,0 +1,3 @@
+-- Forms can thread their notification emails by the submitter's email
+-- address instead of keeping them all in one thread
+ALTER TABLE forms ADD COLUMN thread_by_sender BOOLEAN NOT NULL DEFAULT 0;
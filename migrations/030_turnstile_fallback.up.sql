@@ -0,0 +1,3 @@
+-- What a form does with submissions while Turnstile can't be reached:
+-- reject them (closed), hold them as spam (spam) or rely on the honeypot
+ALTER TABLE forms ADD COLUMN turnstile_fallback TEXT NOT NULL DEFAULT 'closed';
@@ -0,0 +1,3 @@
+-- Forms keep their Turnstile site key, the public half of the secret, for
+-- the embed code
+ALTER TABLE forms ADD COLUMN turnstile_site_key TEXT NOT NULL DEFAULT '';
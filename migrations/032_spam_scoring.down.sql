@@ -0,0 +1,6 @@
+-- Remove spam scoring
+DROP INDEX IF EXISTS idx_blocked_attempts_ip_address_created_at;
+DROP INDEX IF EXISTS idx_submissions_ip_address_created_at;
+ALTER TABLE forms DROP COLUMN spam_reject_threshold;
+ALTER TABLE forms DROP COLUMN spam_flag_threshold;
+ALTER TABLE submissions DROP COLUMN spam_signals;
@@ -0,0 +1,4 @@
+-- Remove submissions' countries and forms' country lists
+ALTER TABLE submissions DROP COLUMN country;
+ALTER TABLE forms DROP COLUMN allowed_countries;
+ALTER TABLE forms DROP COLUMN blocked_countries;
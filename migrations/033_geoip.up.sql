@@ -0,0 +1,5 @@
+-- Submissions record the country their IP address is in, and forms can
+-- accept or refuse submissions by country
+ALTER TABLE submissions ADD COLUMN country TEXT NOT NULL DEFAULT '';
+ALTER TABLE forms ADD COLUMN allowed_countries TEXT NOT NULL DEFAULT '';
+ALTER TABLE forms ADD COLUMN blocked_countries TEXT NOT NULL DEFAULT '';
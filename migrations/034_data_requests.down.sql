@@ -0,0 +1,2 @@
+-- Drop the record of data subject requests
+DROP TABLE IF EXISTS data_requests;
@@ -0,0 +1,21 @@
+-- Catalog of the fields each form has received, updated on submission.
+-- A field has a row for each type its values were inferred to be.
+
+CREATE TABLE form_fields (
+    form_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    submission_count INTEGER NOT NULL DEFAULT 0,
+    max_length INTEGER NOT NULL DEFAULT 0,
+    first_seen_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    PRIMARY KEY (form_id, name, type),
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+-- How many submissions each form's catalog was built from
+CREATE TABLE form_field_totals (
+    form_id INTEGER PRIMARY KEY,
+    submission_count INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
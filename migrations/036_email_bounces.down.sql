@@ -0,0 +1,2 @@
+-- Drop the record of bounced addresses
+DROP TABLE IF EXISTS email_bounces;
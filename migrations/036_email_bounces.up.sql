@@ -0,0 +1,12 @@
+-- Addresses that notification emails bounced from or were reported as spam
+-- by, as told by the sending provider's webhooks or bounce messages
+
+CREATE TABLE email_bounces (
+    address TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    provider TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    bounce_count INTEGER NOT NULL DEFAULT 0,
+    first_bounced_at DATETIME NOT NULL,
+    last_bounced_at DATETIME NOT NULL
+);
@@ -0,0 +1,10 @@
+-- Recipients who muted a form's notification emails with the unsubscribe
+-- link in one, by address
+
+CREATE TABLE notification_mutes (
+    form_id INTEGER NOT NULL,
+    address TEXT NOT NULL,
+    muted_at DATETIME NOT NULL,
+    PRIMARY KEY (form_id, address),
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- The file a form's notification emails attach its submission as: json,
+-- csv or none (empty)
+ALTER TABLE forms ADD COLUMN email_attachment TEXT NOT NULL DEFAULT '';
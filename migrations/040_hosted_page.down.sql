@@ -0,0 +1,2 @@
+-- Stop serving forms' hosted pages
+ALTER TABLE forms DROP COLUMN hosted_page;
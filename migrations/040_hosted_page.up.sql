@@ -0,0 +1,3 @@
+-- Whether a form has a public page at /f/{form_key} for people without a
+-- site of their own to put it on
+ALTER TABLE forms ADD COLUMN hosted_page BOOLEAN NOT NULL DEFAULT 0;
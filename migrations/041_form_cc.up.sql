@@ -0,0 +1,3 @@
+-- Whether submissions' emails are copied to the addresses in their _cc
+-- field. Off by default, so forms can't be used to mail anyone.
+ALTER TABLE forms ADD COLUMN allow_cc BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,51 @@
+-- Initial database schema for staticSend (MySQL/MariaDB)
+-- Creates tables for users, forms, submissions, and email tracking
+
+CREATE TABLE users (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE forms (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    redirect_url TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE submissions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    form_id BIGINT NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    submitted_data MEDIUMTEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    processed_at DATETIME NULL,
+    status VARCHAR(16) DEFAULT 'pending' CHECK (status IN ('pending', 'processed', 'failed')),
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE TABLE submission_emails (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    submission_id BIGINT NOT NULL,
+    sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    status VARCHAR(16) DEFAULT 'sent' CHECK (status IN ('sent', 'failed')),
+    error_message TEXT,
+    FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_users_email ON users(email);
+CREATE INDEX idx_forms_user_id ON forms(user_id);
+CREATE INDEX idx_submissions_form_id ON submissions(form_id);
+CREATE INDEX idx_submissions_created_at ON submissions(created_at);
+CREATE INDEX idx_submission_emails_submission_id ON submission_emails(submission_id);
@@ -0,0 +1,2 @@
+-- Remove app_settings table
+DROP TABLE IF EXISTS app_settings;
@@ -0,0 +1,12 @@
+-- Revert forms table to the original structure
+DROP INDEX idx_forms_form_key ON forms;
+
+ALTER TABLE forms
+    DROP INDEX forms_form_key_key,
+    DROP COLUMN domain,
+    DROP COLUMN turnstile_secret,
+    DROP COLUMN forward_email,
+    DROP COLUMN form_key,
+    ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN description TEXT,
+    ADD COLUMN redirect_url TEXT;
@@ -0,0 +1,27 @@
+-- Update forms table to match ContactForm structure from SDD (MySQL/MariaDB)
+-- Remove old columns and add new ones
+
+ALTER TABLE forms
+    DROP COLUMN title,
+    DROP COLUMN description,
+    DROP COLUMN redirect_url,
+    ADD COLUMN domain VARCHAR(255),
+    ADD COLUMN turnstile_secret VARCHAR(255),
+    ADD COLUMN forward_email VARCHAR(255),
+    ADD COLUMN form_key VARCHAR(255);
+
+-- Fill the new columns for existing forms
+UPDATE forms SET
+    domain = 'example.com',
+    turnstile_secret = CONCAT('turnstile_secret_', id),
+    forward_email = 'admin@example.com',
+    form_key = CONCAT('form_key_', id);
+
+ALTER TABLE forms
+    MODIFY domain VARCHAR(255) NOT NULL,
+    MODIFY turnstile_secret VARCHAR(255) NOT NULL,
+    MODIFY forward_email VARCHAR(255) NOT NULL,
+    MODIFY form_key VARCHAR(255) NOT NULL,
+    ADD CONSTRAINT forms_form_key_key UNIQUE (form_key);
+
+CREATE INDEX idx_forms_form_key ON forms(form_key);
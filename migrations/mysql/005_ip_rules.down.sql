@@ -0,0 +1,3 @@
+-- Drop IP rules and blocked attempt audit tables
+DROP TABLE IF EXISTS blocked_attempts;
+DROP TABLE IF EXISTS ip_rules;
@@ -0,0 +1,27 @@
+-- Add IP allow/deny rules and an audit log of blocked submissions (MySQL/MariaDB)
+-- Rules without a form_id apply to submissions for every form
+
+CREATE TABLE ip_rules (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    form_id BIGINT NULL,
+    cidr VARCHAR(64) NOT NULL,
+    action VARCHAR(8) NOT NULL CHECK (action IN ('allow', 'deny')),
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE TABLE blocked_attempts (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    form_id BIGINT NULL,
+    rule_id BIGINT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE,
+    FOREIGN KEY (rule_id) REFERENCES ip_rules (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_ip_rules_form_id ON ip_rules(form_id);
+CREATE INDEX idx_blocked_attempts_form_id ON blocked_attempts(form_id);
+CREATE INDEX idx_blocked_attempts_created_at ON blocked_attempts(created_at);
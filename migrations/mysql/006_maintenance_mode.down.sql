@@ -0,0 +1,2 @@
+-- Remove maintenance mode settings
+DELETE FROM app_settings WHERE "key" IN ('maintenance_mode', 'maintenance_message');
@@ -0,0 +1,4 @@
+-- Add maintenance mode settings
+INSERT INTO app_settings ("key", value, description) VALUES
+('maintenance_mode', 'false', 'Whether the site is in maintenance mode; only signed-in users can use it (true/false)'),
+('maintenance_message', 'staticSend is undergoing scheduled maintenance and will be back shortly.', 'The message shown to visitors during maintenance');
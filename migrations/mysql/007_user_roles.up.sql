@@ -0,0 +1,5 @@
+-- Add roles to users; the earliest account becomes the administrator
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('admin', 'user'));
+
+-- MySQL can't select from the table being updated, so go through a derived table
+UPDATE users SET role = 'admin' WHERE id = (SELECT min_id FROM (SELECT MIN(id) AS min_id FROM users) AS first_user);
@@ -0,0 +1,4 @@
+-- Drop the composite query indexes
+DROP INDEX idx_blocked_attempts_form_id_created_at ON blocked_attempts;
+DROP INDEX idx_forms_user_id_created_at ON forms;
+DROP INDEX idx_submissions_form_id_created_at ON submissions;
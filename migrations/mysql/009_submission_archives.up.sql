@@ -0,0 +1,15 @@
+-- Manifest of archive files holding submissions moved out of the live table (MySQL/MariaDB)
+
+CREATE TABLE submission_archives (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    form_id BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    submission_count INT NOT NULL,
+    first_submission_at DATETIME NOT NULL,
+    last_submission_at DATETIME NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_submission_archives_form_id ON submission_archives(form_id);
@@ -0,0 +1,24 @@
+-- Add internal notes and assignment so submissions can be worked as an inbox (MySQL/MariaDB)
+-- Submissions without an assignment row are new and unassigned
+
+CREATE TABLE submission_assignments (
+    submission_id BIGINT PRIMARY KEY,
+    assignee_id BIGINT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'new' CHECK (status IN ('new', 'in-progress', 'done')),
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE,
+    FOREIGN KEY (assignee_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE submission_notes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    submission_id BIGINT NOT NULL,
+    user_id BIGINT NULL,
+    body TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_submission_assignments_assignee_id ON submission_assignments(assignee_id);
+CREATE INDEX idx_submission_notes_submission_id ON submission_notes(submission_id);
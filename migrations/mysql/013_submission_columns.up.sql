@@ -0,0 +1,14 @@
+-- Remember which submitted fields each user shows as columns of a form's submissions (MySQL/MariaDB)
+-- field_names holds a JSON array of the submitted fields to show
+
+CREATE TABLE submission_columns (
+    user_id BIGINT NOT NULL,
+    form_id BIGINT NOT NULL,
+    field_names TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, form_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_submission_columns_form_id ON submission_columns(form_id);
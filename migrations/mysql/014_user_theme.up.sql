@@ -0,0 +1,2 @@
+-- Add each user's colour theme; system follows the browser's preference (MySQL/MariaDB)
+ALTER TABLE users ADD COLUMN theme VARCHAR(16) NOT NULL DEFAULT 'system' CHECK (theme IN ('system', 'light', 'dark'));
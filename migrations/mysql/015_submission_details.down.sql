@@ -0,0 +1,3 @@
+-- Remove submission referrers and spam marks
+ALTER TABLE submissions DROP COLUMN spam_at;
+ALTER TABLE submissions DROP COLUMN referrer;
@@ -0,0 +1,2 @@
+-- Remove first-run setup settings
+DELETE FROM app_settings WHERE "key" IN ('base_url', 'smtp_host', 'smtp_port', 'smtp_username', 'smtp_password', 'smtp_from', 'smtp_use_tls');
@@ -0,0 +1,9 @@
+-- Add settings configured by the first-run setup wizard (MySQL/MariaDB)
+INSERT INTO app_settings ("key", value, description) VALUES
+('base_url', '', 'URL the application is served from, used in form code and links (empty uses STATICSEND_BASE_URL)'),
+('smtp_host', '', 'SMTP server host (empty uses the EMAIL_* environment variables)'),
+('smtp_port', '587', 'SMTP server port'),
+('smtp_username', '', 'SMTP username'),
+('smtp_password', '', 'SMTP password'),
+('smtp_from', '', 'Address notification emails are sent from'),
+('smtp_use_tls', 'true', 'Whether to require STARTTLS when sending email (true/false)');
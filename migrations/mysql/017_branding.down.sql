@@ -0,0 +1,3 @@
+-- Remove branding
+DELETE FROM app_settings WHERE "key" = 'primary_color';
+DROP TABLE IF EXISTS site_assets;
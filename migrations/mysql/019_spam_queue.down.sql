@@ -0,0 +1,4 @@
+-- Remove spam reasons and the spam blocklist
+DELETE FROM app_settings WHERE "key" = 'spam_blocklist';
+DROP INDEX idx_submissions_form_spam ON submissions;
+ALTER TABLE submissions DROP COLUMN spam_reason;
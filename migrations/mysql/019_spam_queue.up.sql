@@ -0,0 +1,8 @@
+-- Record why a submission was held as spam, and a blocklist of words that hold it (MySQL/MariaDB)
+
+ALTER TABLE submissions ADD COLUMN spam_reason VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_submissions_form_spam ON submissions(form_id, spam_at);
+
+INSERT INTO app_settings ("key", value, description) VALUES
+('spam_blocklist', '', 'Words, addresses or links, one per line or comma separated, that hold a submission as spam');
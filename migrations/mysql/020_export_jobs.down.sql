@@ -0,0 +1,2 @@
+-- Drop background exports
+DROP TABLE IF EXISTS export_jobs;
@@ -0,0 +1,21 @@
+-- Exports of a form's submissions written in the background (MySQL/MariaDB)
+
+CREATE TABLE export_jobs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    form_id BIGINT NOT NULL,
+    format VARCHAR(16) NOT NULL DEFAULT 'csv' CHECK (format IN ('csv', 'jsonl')),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    row_count INT NOT NULL DEFAULT 0,
+    size BIGINT NOT NULL DEFAULT 0,
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME NULL,
+    expires_at DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_export_jobs_user_id ON export_jobs(user_id);
+CREATE INDEX idx_export_jobs_status ON export_jobs(status);
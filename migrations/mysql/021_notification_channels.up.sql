@@ -0,0 +1,32 @@
+-- Notification channels configured per form and the log of their deliveries (MySQL/MariaDB)
+
+CREATE TABLE notification_channels (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    form_id BIGINT NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    config TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_channels_form_id ON notification_channels(form_id);
+
+CREATE TABLE notification_deliveries (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    channel_id BIGINT NOT NULL,
+    submission_id BIGINT NULL,
+    event VARCHAR(64) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME NULL,
+    FOREIGN KEY (channel_id) REFERENCES notification_channels (id) ON DELETE CASCADE,
+    FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_deliveries_channel_id ON notification_deliveries(channel_id, created_at);
+CREATE INDEX idx_notification_deliveries_submission_id ON notification_deliveries(submission_id);
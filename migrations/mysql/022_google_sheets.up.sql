@@ -0,0 +1,23 @@
+-- Google Sheets sync: service account keys per user and the sheet each form
+-- appends its submissions to (MySQL/MariaDB)
+
+CREATE TABLE google_credentials (
+    user_id BIGINT PRIMARY KEY,
+    client_email VARCHAR(255) NOT NULL,
+    credentials TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE form_sheets (
+    form_id BIGINT PRIMARY KEY,
+    spreadsheet_id VARCHAR(255) NOT NULL,
+    sheet_name VARCHAR(255) NOT NULL DEFAULT '',
+    mapping TEXT NOT NULL,
+    last_submission_id BIGINT NOT NULL DEFAULT 0,
+    header_written BOOLEAN NOT NULL DEFAULT FALSE,
+    synced_at DATETIME NULL,
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
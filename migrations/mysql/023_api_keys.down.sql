@@ -0,0 +1,2 @@
+-- Drop API keys
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,2 @@
+-- Remove notification channel events
+ALTER TABLE notification_channels DROP COLUMN events;
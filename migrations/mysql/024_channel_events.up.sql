@@ -0,0 +1,3 @@
+-- Events each notification channel is sent, comma separated; existing
+-- channels keep getting new submissions only (MySQL/MariaDB)
+ALTER TABLE notification_channels ADD COLUMN events VARCHAR(255) NOT NULL DEFAULT 'submission.created';
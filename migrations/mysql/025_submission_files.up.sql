@@ -0,0 +1,16 @@
+-- Files uploaded with a submission; the files themselves are kept in the
+-- uploads store under storage_name (MySQL/MariaDB)
+
+CREATE TABLE submission_files (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    submission_id BIGINT NOT NULL,
+    field VARCHAR(255) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    storage_name VARCHAR(64) NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_submission_files_submission_id ON submission_files(submission_id);
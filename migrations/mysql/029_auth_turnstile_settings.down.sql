@@ -0,0 +1,2 @@
+-- Remove the sign in Turnstile settings
+DELETE FROM app_settings WHERE "key" IN ('turnstile_public_key', 'turnstile_secret_key');
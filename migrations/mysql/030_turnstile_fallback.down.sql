@@ -0,0 +1,2 @@
+-- Remove the Turnstile outage policy
+ALTER TABLE forms DROP COLUMN turnstile_fallback;
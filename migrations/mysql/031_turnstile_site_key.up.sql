@@ -0,0 +1,3 @@
+-- Forms keep their Turnstile site key, the public half of the secret, for
+-- the embed code (MySQL/MariaDB)
+ALTER TABLE forms ADD COLUMN turnstile_site_key VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Submissions record the country their IP address is in, and forms can
+-- accept or refuse submissions by country (MySQL/MariaDB)
+ALTER TABLE submissions ADD COLUMN country VARCHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE forms ADD COLUMN allowed_countries VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE forms ADD COLUMN blocked_countries VARCHAR(1024) NOT NULL DEFAULT '';
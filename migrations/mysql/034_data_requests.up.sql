@@ -0,0 +1,16 @@
+-- Record data subject requests answered by administrators: exports,
+-- redactions and deletions of a visitor's submissions. Emails are copied
+-- rather than referenced so the record outlives the accounts involved.
+-- (MySQL/MariaDB)
+
+CREATE TABLE data_requests (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(320) NOT NULL,
+    action VARCHAR(16) NOT NULL CHECK (action IN ('export', 'redact', 'delete')),
+    owner_email VARCHAR(255) NOT NULL DEFAULT '',
+    admin_email VARCHAR(255) NOT NULL,
+    submission_count INT NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_data_requests_created_at ON data_requests(created_at);
@@ -0,0 +1,13 @@
+-- Addresses that notification emails bounced from or were reported as spam
+-- by, as told by the sending provider's webhooks or bounce messages
+-- (MySQL/MariaDB)
+
+CREATE TABLE email_bounces (
+    address VARCHAR(255) PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    provider VARCHAR(32) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    bounce_count INT NOT NULL DEFAULT 0,
+    first_bounced_at DATETIME NOT NULL,
+    last_bounced_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Drop the record of muted recipients
+DROP TABLE IF EXISTS notification_mutes;
@@ -0,0 +1,11 @@
+-- Recipients who muted a form's notification emails with the unsubscribe
+-- link in one, by address
+-- (MySQL/MariaDB)
+
+CREATE TABLE notification_mutes (
+    form_id BIGINT NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    muted_at DATETIME NOT NULL,
+    PRIMARY KEY (form_id, address),
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+-- Routing rules that email a form's submissions to another address when a
+-- field matches, tried in position order
+-- (MySQL/MariaDB)
+
+CREATE TABLE form_routes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    form_id BIGINT NOT NULL,
+    position INT NOT NULL,
+    field VARCHAR(255) NOT NULL,
+    operator VARCHAR(16) NOT NULL CHECK (operator IN ('equals', 'not_equals', 'contains')),
+    value VARCHAR(255) NOT NULL DEFAULT '',
+    recipient VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_form_routes_form_id ON form_routes(form_id);
@@ -0,0 +1,4 @@
+-- The file a form's notification emails attach its submission as: json,
+-- csv or none (empty)
+-- (MySQL/MariaDB)
+ALTER TABLE forms ADD COLUMN email_attachment VARCHAR(8) NOT NULL DEFAULT '';
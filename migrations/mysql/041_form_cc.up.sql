@@ -0,0 +1,4 @@
+-- Whether submissions' emails are copied to the addresses in their _cc
+-- field. Off by default, so forms can't be used to mail anyone.
+-- (MySQL/MariaDB)
+ALTER TABLE forms ADD COLUMN allow_cc BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- A single row that account creation locks, so concurrent sign-ups take
+-- turns and only the first account becomes an administrator
+-- (MySQL/MariaDB)
+
+CREATE TABLE signup_lock (
+    id INTEGER PRIMARY KEY,
+    locked_at DATETIME
+);
+INSERT INTO signup_lock (id) VALUES (1);
@@ -0,0 +1,5 @@
+-- Drop all tables in reverse order of creation
+DROP TABLE IF EXISTS submission_emails;
+DROP TABLE IF EXISTS submissions;
+DROP TABLE IF EXISTS forms;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,48 @@
+-- Initial database schema for staticSend (PostgreSQL)
+-- Creates tables for users, forms, submissions, and email tracking
+
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE forms (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    redirect_url TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE submissions (
+    id BIGSERIAL PRIMARY KEY,
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    ip_address TEXT,
+    user_agent TEXT,
+    submitted_data TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'processed', 'failed'))
+);
+
+CREATE TABLE submission_emails (
+    id BIGSERIAL PRIMARY KEY,
+    submission_id BIGINT NOT NULL REFERENCES submissions (id) ON DELETE CASCADE,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    status TEXT DEFAULT 'sent' CHECK (status IN ('sent', 'failed')),
+    error_message TEXT
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_users_email ON users(email);
+CREATE INDEX idx_forms_user_id ON forms(user_id);
+CREATE INDEX idx_submissions_form_id ON submissions(form_id);
+CREATE INDEX idx_submissions_created_at ON submissions(created_at);
+CREATE INDEX idx_submission_emails_submission_id ON submission_emails(submission_id);
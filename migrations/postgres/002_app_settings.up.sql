@@ -0,0 +1,18 @@
+-- Add app_settings table for application configuration (PostgreSQL)
+CREATE TABLE app_settings (
+    id BIGSERIAL PRIMARY KEY,
+    key TEXT UNIQUE NOT NULL,
+    value TEXT NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Insert default settings
+INSERT INTO app_settings (key, value, description) VALUES
+('registration_enabled', 'true', 'Whether new user registration is enabled (true/false)'),
+('site_title', 'staticSend', 'The title of the application'),
+('site_description', 'A simple contact form service', 'The description of the application');
+
+-- Create index for faster lookups
+CREATE INDEX idx_app_settings_key ON app_settings(key);
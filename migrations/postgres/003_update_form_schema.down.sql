@@ -0,0 +1,12 @@
+-- Revert forms table to the original structure
+DROP INDEX IF EXISTS idx_forms_form_key;
+
+ALTER TABLE forms
+    DROP CONSTRAINT IF EXISTS forms_form_key_key,
+    DROP COLUMN domain,
+    DROP COLUMN turnstile_secret,
+    DROP COLUMN forward_email,
+    DROP COLUMN form_key,
+    ADD COLUMN title TEXT NOT NULL DEFAULT '',
+    ADD COLUMN description TEXT,
+    ADD COLUMN redirect_url TEXT;
@@ -0,0 +1,27 @@
+-- Update forms table to match ContactForm structure from SDD (PostgreSQL)
+-- Remove old columns and add new ones
+
+ALTER TABLE forms
+    DROP COLUMN title,
+    DROP COLUMN description,
+    DROP COLUMN redirect_url,
+    ADD COLUMN domain TEXT,
+    ADD COLUMN turnstile_secret TEXT,
+    ADD COLUMN forward_email TEXT,
+    ADD COLUMN form_key TEXT;
+
+-- Fill the new columns for existing forms
+UPDATE forms SET
+    domain = 'example.com',
+    turnstile_secret = 'turnstile_secret_' || id,
+    forward_email = 'admin@example.com',
+    form_key = 'form_key_' || id;
+
+ALTER TABLE forms
+    ALTER COLUMN domain SET NOT NULL,
+    ALTER COLUMN turnstile_secret SET NOT NULL,
+    ALTER COLUMN forward_email SET NOT NULL,
+    ALTER COLUMN form_key SET NOT NULL,
+    ADD CONSTRAINT forms_form_key_key UNIQUE (form_key);
+
+CREATE INDEX idx_forms_form_key ON forms(form_key);
@@ -0,0 +1,24 @@
+-- Add IP allow/deny rules and an audit log of blocked submissions (PostgreSQL)
+-- Rules without a form_id apply to submissions for every form
+
+CREATE TABLE ip_rules (
+    id BIGSERIAL PRIMARY KEY,
+    form_id BIGINT REFERENCES forms (id) ON DELETE CASCADE,
+    cidr TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('allow', 'deny')),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE blocked_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    form_id BIGINT REFERENCES forms (id) ON DELETE CASCADE,
+    rule_id BIGINT REFERENCES ip_rules (id) ON DELETE SET NULL,
+    ip_address TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ip_rules_form_id ON ip_rules(form_id);
+CREATE INDEX idx_blocked_attempts_form_id ON blocked_attempts(form_id);
+CREATE INDEX idx_blocked_attempts_created_at ON blocked_attempts(created_at);
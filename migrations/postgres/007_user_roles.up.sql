@@ -0,0 +1,4 @@
+-- Add roles to users; the earliest account becomes the administrator
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('admin', 'user'));
+
+UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users);
@@ -0,0 +1,5 @@
+-- Composite indexes for the per-request lookups that filter on one column and
+-- sort on created_at, so listings don't need a separate sort step
+CREATE INDEX idx_submissions_form_id_created_at ON submissions(form_id, created_at);
+CREATE INDEX idx_forms_user_id_created_at ON forms(user_id, created_at);
+CREATE INDEX idx_blocked_attempts_form_id_created_at ON blocked_attempts(form_id, created_at);
@@ -0,0 +1,14 @@
+-- Manifest of archive files holding submissions moved out of the live table (PostgreSQL)
+
+CREATE TABLE submission_archives (
+    id BIGSERIAL PRIMARY KEY,
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    name TEXT NOT NULL UNIQUE,
+    submission_count INTEGER NOT NULL,
+    first_submission_at TIMESTAMP NOT NULL,
+    last_submission_at TIMESTAMP NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_submission_archives_form_id ON submission_archives(form_id);
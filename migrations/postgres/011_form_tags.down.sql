@@ -0,0 +1,3 @@
+-- Drop form tags
+DROP TABLE IF EXISTS form_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,17 @@
+-- Add tags so users can organize their forms; a form can have many tags (PostgreSQL)
+
+CREATE TABLE tags (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE form_tags (
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (form_id, tag_id)
+);
+
+CREATE INDEX idx_form_tags_tag_id ON form_tags(tag_id);
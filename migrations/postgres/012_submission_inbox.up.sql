@@ -0,0 +1,20 @@
+-- Add internal notes and assignment so submissions can be worked as an inbox (PostgreSQL)
+-- Submissions without an assignment row are new and unassigned
+
+CREATE TABLE submission_assignments (
+    submission_id BIGINT PRIMARY KEY REFERENCES submissions (id) ON DELETE CASCADE,
+    assignee_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'new' CHECK (status IN ('new', 'in-progress', 'done')),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE submission_notes (
+    id BIGSERIAL PRIMARY KEY,
+    submission_id BIGINT NOT NULL REFERENCES submissions (id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_submission_assignments_assignee_id ON submission_assignments(assignee_id);
+CREATE INDEX idx_submission_notes_submission_id ON submission_notes(submission_id);
@@ -0,0 +1,2 @@
+-- Remove submission column preferences
+DROP TABLE IF EXISTS submission_columns;
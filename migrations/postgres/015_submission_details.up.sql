@@ -0,0 +1,3 @@
+-- Record where each submission came from and when it was marked as spam (PostgreSQL)
+ALTER TABLE submissions ADD COLUMN referrer TEXT NOT NULL DEFAULT '';
+ALTER TABLE submissions ADD COLUMN spam_at TIMESTAMP;
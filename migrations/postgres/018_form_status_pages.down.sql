@@ -0,0 +1,2 @@
+-- Remove form status pages
+DROP TABLE IF EXISTS form_status_pages;
@@ -0,0 +1,9 @@
+-- Add shareable, read-only status pages for forms (PostgreSQL)
+-- A form has a status page while it has a row here; the token is its URL
+
+CREATE TABLE form_status_pages (
+    form_id BIGINT PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+-- Exports of a form's submissions written in the background (PostgreSQL)
+
+CREATE TABLE export_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    format TEXT NOT NULL DEFAULT 'csv' CHECK (format IN ('csv', 'jsonl')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    file_name TEXT NOT NULL DEFAULT '',
+    row_count INTEGER NOT NULL DEFAULT 0,
+    size BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX idx_export_jobs_user_id ON export_jobs(user_id);
+CREATE INDEX idx_export_jobs_status ON export_jobs(status);
@@ -0,0 +1,3 @@
+-- Drop notification channels and their deliveries
+DROP TABLE IF EXISTS notification_deliveries;
+DROP TABLE IF EXISTS notification_channels;
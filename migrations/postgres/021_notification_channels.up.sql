@@ -0,0 +1,29 @@
+-- Notification channels configured per form and the log of their deliveries (PostgreSQL)
+
+CREATE TABLE notification_channels (
+    id BIGSERIAL PRIMARY KEY,
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    config TEXT NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_notification_channels_form_id ON notification_channels(form_id);
+
+CREATE TABLE notification_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    channel_id BIGINT NOT NULL REFERENCES notification_channels (id) ON DELETE CASCADE,
+    submission_id BIGINT REFERENCES submissions (id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX idx_notification_deliveries_channel_id ON notification_deliveries(channel_id, created_at);
+CREATE INDEX idx_notification_deliveries_submission_id ON notification_deliveries(submission_id);
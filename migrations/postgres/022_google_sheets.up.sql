@@ -0,0 +1,21 @@
+-- Google Sheets sync: service account keys per user and the sheet each form
+-- appends its submissions to (PostgreSQL)
+
+CREATE TABLE google_credentials (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    client_email TEXT NOT NULL,
+    credentials TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE form_sheets (
+    form_id BIGINT PRIMARY KEY REFERENCES forms (id) ON DELETE CASCADE,
+    spreadsheet_id TEXT NOT NULL,
+    sheet_name TEXT NOT NULL DEFAULT '',
+    mapping TEXT NOT NULL DEFAULT '[]',
+    last_submission_id BIGINT NOT NULL DEFAULT 0,
+    header_written BOOLEAN NOT NULL DEFAULT FALSE,
+    synced_at TIMESTAMP,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
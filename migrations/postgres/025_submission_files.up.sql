@@ -0,0 +1,15 @@
+-- Files uploaded with a submission; the files themselves are kept in the
+-- uploads store under storage_name (PostgreSQL)
+
+CREATE TABLE submission_files (
+    id BIGSERIAL PRIMARY KEY,
+    submission_id BIGINT NOT NULL REFERENCES submissions (id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    storage_name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_submission_files_submission_id ON submission_files(submission_id);
@@ -0,0 +1,4 @@
+-- Forms can have submissions checked with Akismet, which scores them
+-- from 0 (ham) to 100 (blatant spam) (PostgreSQL)
+ALTER TABLE forms ADD COLUMN akismet_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE submissions ADD COLUMN spam_score INTEGER;
@@ -0,0 +1,9 @@
+-- Add token-guarded feeds of forms' submissions (PostgreSQL)
+-- A form has a feed while it has a row here; the token is in its URL
+
+CREATE TABLE form_feeds (
+    form_id BIGINT PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (form_id) REFERENCES forms (id) ON DELETE CASCADE
+);
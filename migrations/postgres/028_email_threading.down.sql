@@ -0,0 +1,2 @@
+-- Remove threading by submitter (PostgreSQL)
+ALTER TABLE forms DROP COLUMN thread_by_sender;
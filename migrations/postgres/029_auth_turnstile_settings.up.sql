@@ -0,0 +1,4 @@
+-- Let the Turnstile keys for the sign in pages be changed without a restart (PostgreSQL)
+INSERT INTO app_settings (key, value, description) VALUES
+('turnstile_public_key', '', 'Turnstile site key for the sign in and registration pages (empty uses TURNSTILE_PUBLIC_KEY)'),
+('turnstile_secret_key', '', 'Turnstile secret key for the sign in and registration pages');
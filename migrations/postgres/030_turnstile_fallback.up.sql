@@ -0,0 +1,4 @@
+-- What a form does with submissions while Turnstile can't be reached:
+-- reject them (closed), hold them as spam (spam) or rely on the honeypot
+-- (PostgreSQL)
+ALTER TABLE forms ADD COLUMN turnstile_fallback TEXT NOT NULL DEFAULT 'closed';
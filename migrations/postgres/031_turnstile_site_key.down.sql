@@ -0,0 +1,2 @@
+-- Remove forms' Turnstile site keys
+ALTER TABLE forms DROP COLUMN turnstile_site_key;
@@ -0,0 +1,10 @@
+-- Every submission gets a combined spam score, stored with the signals
+-- behind it, and forms choose the scores that flag or reject a submission
+-- (PostgreSQL)
+ALTER TABLE submissions ADD COLUMN spam_signals TEXT;
+ALTER TABLE forms ADD COLUMN spam_flag_threshold INTEGER NOT NULL DEFAULT 80;
+ALTER TABLE forms ADD COLUMN spam_reject_threshold INTEGER NOT NULL DEFAULT 0;
+
+-- The sender's history is looked up by IP address on every submission
+CREATE INDEX idx_submissions_ip_address_created_at ON submissions(ip_address, created_at);
+CREATE INDEX idx_blocked_attempts_ip_address_created_at ON blocked_attempts(ip_address, created_at);
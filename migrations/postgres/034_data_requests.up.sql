@@ -0,0 +1,16 @@
+-- Record data subject requests answered by administrators: exports,
+-- redactions and deletions of a visitor's submissions. Emails are copied
+-- rather than referenced so the record outlives the accounts involved.
+-- (PostgreSQL)
+
+CREATE TABLE data_requests (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('export', 'redact', 'delete')),
+    owner_email TEXT NOT NULL DEFAULT '',
+    admin_email TEXT NOT NULL,
+    submission_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_data_requests_created_at ON data_requests(created_at);
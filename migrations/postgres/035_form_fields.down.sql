@@ -0,0 +1,3 @@
+-- Drop the catalog of forms' fields
+DROP TABLE IF EXISTS form_field_totals;
+DROP TABLE IF EXISTS form_fields;
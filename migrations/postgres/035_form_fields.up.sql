@@ -0,0 +1,20 @@
+-- Catalog of the fields each form has received, updated on submission.
+-- A field has a row for each type its values were inferred to be.
+-- (PostgreSQL)
+
+CREATE TABLE form_fields (
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    submission_count INTEGER NOT NULL DEFAULT 0,
+    max_length INTEGER NOT NULL DEFAULT 0,
+    first_seen_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (form_id, name, type)
+);
+
+-- How many submissions each form's catalog was built from
+CREATE TABLE form_field_totals (
+    form_id BIGINT PRIMARY KEY REFERENCES forms (id) ON DELETE CASCADE,
+    submission_count INTEGER NOT NULL DEFAULT 0
+);
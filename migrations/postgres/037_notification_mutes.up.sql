@@ -0,0 +1,10 @@
+-- Recipients who muted a form's notification emails with the unsubscribe
+-- link in one, by address
+-- (PostgreSQL)
+
+CREATE TABLE notification_mutes (
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    address TEXT NOT NULL,
+    muted_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (form_id, address)
+);
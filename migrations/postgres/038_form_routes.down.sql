@@ -0,0 +1,2 @@
+-- Drop forms' routing rules
+DROP TABLE IF EXISTS form_routes;
@@ -0,0 +1,16 @@
+-- Routing rules that email a form's submissions to another address when a
+-- field matches, tried in position order
+-- (PostgreSQL)
+
+CREATE TABLE form_routes (
+    id BIGSERIAL PRIMARY KEY,
+    form_id BIGINT NOT NULL REFERENCES forms (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    field TEXT NOT NULL,
+    operator TEXT NOT NULL CHECK (operator IN ('equals', 'not_equals', 'contains')),
+    value TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_form_routes_form_id ON form_routes(form_id);
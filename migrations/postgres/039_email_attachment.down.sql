@@ -0,0 +1,2 @@
+-- Stop attaching submissions to notification emails
+ALTER TABLE forms DROP COLUMN email_attachment;
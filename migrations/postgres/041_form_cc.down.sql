@@ -0,0 +1,2 @@
+-- Stop copying submissions' emails to their _cc addresses
+ALTER TABLE forms DROP COLUMN allow_cc;
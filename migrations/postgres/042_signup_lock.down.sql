@@ -0,0 +1,2 @@
+-- Drop the account creation lock
+DROP TABLE IF EXISTS signup_lock;
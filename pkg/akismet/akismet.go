@@ -0,0 +1,219 @@
+// Package akismet checks form submissions for spam with Akismet's API, and
+// tells Akismet about the ones it got wrong so it learns from them.
+package akismet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// akismetAPI is the Akismet API, replaced in tests
+var akismetAPI = "https://rest.akismet.com/1.1"
+
+// Scores given to submissions by what Akismet says about them, from 0 for
+// ham to 100 for spam so blatant Akismet suggests discarding it
+const (
+	ScoreHam     = 0
+	ScoreSpam    = 80
+	ScoreDiscard = 100
+)
+
+// maxContentLength caps the submitted text sent to Akismet
+const maxContentLength = 10000
+
+// Fields a submission's author and message are taken from, in order
+var (
+	authorFields  = []string{"name", "full_name", "fullname", "your-name", "author"}
+	nameFields    = []string{"first_name", "last_name"}
+	emailFields   = []string{"email", "_replyto", "email_address", "your-email", "mail"}
+	contentFields = []string{"message", "comments", "comment", "body", "enquiry", "inquiry", "text", "content"}
+)
+
+// Comment is a submission as Akismet sees it
+type Comment struct {
+	// Blog is the front page of the site the form is on
+	Blog        string
+	UserIP      string
+	UserAgent   string
+	Referrer    string
+	Author      string
+	AuthorEmail string
+	Content     string
+	// CreatedAt is when the submission was sent, for feedback on older ones
+	CreatedAt time.Time
+}
+
+// NewComment builds the comment Akismet checks from a submission sent to a
+// form on domain. The author, email and message come from the usual field
+// names; without a message field every other field is sent as the content.
+func NewComment(domain string, fields map[string]string, ip, userAgent, referrer string) Comment {
+	comment := Comment{
+		Blog:        blogURL(domain),
+		UserIP:      ip,
+		UserAgent:   userAgent,
+		Referrer:    referrer,
+		Author:      firstField(fields, authorFields),
+		AuthorEmail: firstField(fields, emailFields),
+		Content:     firstField(fields, contentFields),
+	}
+	if comment.Author == "" {
+		comment.Author = strings.TrimSpace(firstField(fields, nameFields[:1]) + " " + firstField(fields, nameFields[1:]))
+	}
+	if comment.Content == "" {
+		comment.Content = otherFields(fields)
+	}
+	if len(comment.Content) > maxContentLength {
+		comment.Content = comment.Content[:maxContentLength]
+	}
+	return comment
+}
+
+// otherFields joins the values of the fields that aren't the author's name
+// or email, in field order
+func otherFields(fields map[string]string) string {
+	skip := make(map[string]bool)
+	for _, names := range [][]string{authorFields, nameFields, emailFields} {
+		for _, name := range names {
+			skip[name] = true
+		}
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if !skip[strings.ToLower(name)] && strings.TrimSpace(fields[name]) != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = fields[name]
+	}
+	return strings.Join(values, "\n")
+}
+
+// blogURL is the front page of a form's site
+func blogURL(domain string) string {
+	if strings.Contains(domain, "://") {
+		return domain
+	}
+	return "https://" + domain
+}
+
+// firstField returns the first of the named fields that was filled in,
+// matching names without regard to case
+func firstField(fields map[string]string, names []string) string {
+	for _, name := range names {
+		for field, value := range fields {
+			if strings.EqualFold(field, name) && strings.TrimSpace(value) != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return ""
+}
+
+// Client calls the Akismet API with an API key
+type Client struct {
+	key  string
+	http *http.Client
+}
+
+// NewClient creates a client using key, making requests with httpClient
+func NewClient(key string, httpClient *http.Client) *Client {
+	return &Client{key: key, http: httpClient}
+}
+
+// Check asks Akismet whether a comment is spam, returning its score
+func (c *Client) Check(ctx context.Context, comment Comment) (int, error) {
+	resp, body, err := c.post(ctx, "comment-check", comment)
+	if err != nil {
+		return 0, err
+	}
+	switch body {
+	case "true":
+		if resp.Header.Get("X-akismet-pro-tip") == "discard" {
+			return ScoreDiscard, nil
+		}
+		return ScoreSpam, nil
+	case "false":
+		return ScoreHam, nil
+	default:
+		return 0, apiError(resp, body)
+	}
+}
+
+// SubmitSpam tells Akismet about spam it missed
+func (c *Client) SubmitSpam(ctx context.Context, comment Comment) error {
+	return c.submit(ctx, "submit-spam", comment)
+}
+
+// SubmitHam tells Akismet about a submission it wrongly called spam
+func (c *Client) SubmitHam(ctx context.Context, comment Comment) error {
+	return c.submit(ctx, "submit-ham", comment)
+}
+
+// submit sends feedback about a comment
+func (c *Client) submit(ctx context.Context, method string, comment Comment) error {
+	resp, body, err := c.post(ctx, method, comment)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(body, "Thanks") {
+		return apiError(resp, body)
+	}
+	return nil
+}
+
+// post calls one of the API's methods with a comment, returning the
+// response and its body
+func (c *Client) post(ctx context.Context, method string, comment Comment) (*http.Response, string, error) {
+	form := url.Values{
+		"api_key":              {c.key},
+		"blog":                 {comment.Blog},
+		"user_ip":              {comment.UserIP},
+		"user_agent":           {comment.UserAgent},
+		"referrer":             {comment.Referrer},
+		"comment_type":         {"contact-form"},
+		"comment_author":       {comment.Author},
+		"comment_author_email": {comment.AuthorEmail},
+		"comment_content":      {comment.Content},
+		"blog_charset":         {"UTF-8"},
+	}
+	if !comment.CreatedAt.IsZero() {
+		form.Set("comment_date_gmt", comment.CreatedAt.UTC().Format(time.RFC3339))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, akismetAPI+"/"+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, strings.TrimSpace(string(body)), nil
+}
+
+// apiError describes an answer Akismet gives when it can't handle a call,
+// such as "invalid" for a bad API key
+func apiError(resp *http.Response, body string) error {
+	if help := resp.Header.Get("X-akismet-debug-help"); help != "" {
+		return fmt.Errorf("akismet: %s (%s)", body, help)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("akismet: status %d: %s", resp.StatusCode, body)
+	}
+	return fmt.Errorf("akismet: unexpected answer %q", body)
+}
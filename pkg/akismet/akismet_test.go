@@ -0,0 +1,101 @@
+package akismet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewComment(t *testing.T) {
+	comment := NewComment("example.com", map[string]string{
+		"Name":    "Ada Lovelace",
+		"email":   "ada@example.com",
+		"message": "Hello there",
+		"phone":   "555",
+	}, "203.0.113.7", "Browser", "https://example.com/contact")
+	if comment.Blog != "https://example.com" || comment.Author != "Ada Lovelace" || comment.AuthorEmail != "ada@example.com" || comment.Content != "Hello there" {
+		t.Errorf("Expected the author, email and message, got %+v", comment)
+	}
+
+	comment = NewComment("https://example.com", map[string]string{
+		"first_name": "Ada",
+		"last_name":  "Lovelace",
+		"budget":     "1000",
+		"topic":      "Engines",
+	}, "", "", "")
+	if comment.Blog != "https://example.com" || comment.Author != "Ada Lovelace" {
+		t.Errorf("Expected the author from first and last names, got %+v", comment)
+	}
+	if comment.Content != "1000\nEngines" {
+		t.Errorf("Expected the other fields as the content, got %q", comment.Content)
+	}
+}
+
+func TestClient(t *testing.T) {
+	var got map[string]string
+	answer, tip := "false", ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = map[string]string{"path": r.URL.Path}
+		for name := range r.PostForm {
+			got[name] = r.PostForm.Get(name)
+		}
+		if tip != "" {
+			w.Header().Set("X-akismet-pro-tip", tip)
+		}
+		if r.PostForm.Get("api_key") != "key" {
+			w.Header().Set("X-akismet-debug-help", "We were unable to parse your blog URI")
+			w.Write([]byte("invalid"))
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/submit-") {
+			w.Write([]byte("Thanks for making the web a better place."))
+			return
+		}
+		w.Write([]byte(answer))
+	}))
+	defer server.Close()
+	akismetAPI = server.URL
+	defer func() { akismetAPI = "https://rest.akismet.com/1.1" }()
+
+	client := NewClient("key", server.Client())
+	ctx := context.Background()
+	comment := Comment{Blog: "https://example.com", UserIP: "203.0.113.7", Author: "Ada", Content: "Hello"}
+
+	for _, tc := range []struct {
+		answer, tip string
+		want        int
+	}{
+		{"false", "", ScoreHam},
+		{"true", "", ScoreSpam},
+		{"true", "discard", ScoreDiscard},
+	} {
+		answer, tip = tc.answer, tc.tip
+		score, err := client.Check(ctx, comment)
+		if err != nil || score != tc.want {
+			t.Errorf("Expected score %d for %s %q, got %d (%v)", tc.want, tc.answer, tc.tip, score, err)
+		}
+	}
+	if got["path"] != "/comment-check" || got["user_ip"] != "203.0.113.7" || got["comment_type"] != "contact-form" || got["comment_content"] != "Hello" {
+		t.Errorf("Expected the comment to be sent, got %v", got)
+	}
+
+	comment.CreatedAt = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := client.SubmitSpam(ctx, comment); err != nil || got["path"] != "/submit-spam" {
+		t.Errorf("Expected spam to be submitted, got %v (%v)", got["path"], err)
+	}
+	if got["comment_date_gmt"] != "2026-03-01T12:00:00Z" {
+		t.Errorf("Expected the submission's date, got %q", got["comment_date_gmt"])
+	}
+	if err := client.SubmitHam(ctx, comment); err != nil || got["path"] != "/submit-ham" {
+		t.Errorf("Expected ham to be submitted, got %v (%v)", got["path"], err)
+	}
+
+	_, err := NewClient("wrong", server.Client()).Check(ctx, comment)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse") {
+		t.Errorf("Expected Akismet's error for a bad key, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"staticsend/pkg/auth"
 	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
 	"staticsend/pkg/models"
 )
 
@@ -33,54 +34,49 @@ type AuthResponse struct {
 	User  *models.User    `json:"user"`
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 // Register handles user registration
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if req.Email == "" || req.Password == "" {
-		http.Error(w, "Email and password are required", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Email and password are required")
 		return
 	}
 
 	// Check if user already exists
-	exists, err := models.UserExists(h.DB.Connection, req.Email)
+	exists, err := models.UserExistsContext(r.Context(), h.DB.Connection, req.Email)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Internal server error")
 		return
 	}
 	if exists {
-		http.Error(w, "User already exists", http.StatusConflict)
+		httperr.Write(w, r, http.StatusConflict, httperr.Conflict, "User already exists")
 		return
 	}
 
 	// Hash password
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
-		http.Error(w, "Failed to process password", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to process password")
 		return
 	}
 
 	// Create user
-	user, err := models.CreateUser(h.DB.Connection, req.Email, passwordHash)
+	user, err := models.CreateUserContext(r.Context(), h.DB.Connection, req.Email, passwordHash)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to create user")
 		return
 	}
 
 	// Generate JWT token
 	token, err := auth.GenerateToken(user, h.SecretKey)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to generate token")
 		return
 	}
 
@@ -99,37 +95,37 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if req.Email == "" || req.Password == "" {
-		http.Error(w, "Email and password are required", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Email and password are required")
 		return
 	}
 
 	// Get user by email
-	user, err := models.GetUserByEmail(h.DB.Connection, req.Email)
+	user, err := models.GetUserByEmailContext(r.Context(), h.DB.Connection, req.Email)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Internal server error")
 		return
 	}
 	if user == nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Invalid email or password")
 		return
 	}
 
 	// Check password
 	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Invalid email or password")
 		return
 	}
 
 	// Generate JWT token
 	token, err := auth.GenerateToken(user, h.SecretKey)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to generate token")
 		return
 	}
 
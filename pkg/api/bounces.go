@@ -0,0 +1,118 @@
+package api
+
+import (
+	"crypto/subtle"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/bounces"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+)
+
+// maxBounceWebhookSize caps the body of a bounce webhook
+const maxBounceWebhookSize = 1 << 20
+
+// BounceHandler receives bounce and complaint webhooks from the email
+// provider, at /webhooks/bounces/{provider}/{token}, and records the
+// addresses mail is failing to
+type BounceHandler struct {
+	DB *database.Database
+	// Token is the secret in the webhook URLs. Webhooks are refused when
+	// it's empty.
+	Token string
+	// Client confirms Amazon SNS subscriptions
+	Client *http.Client
+}
+
+// NewBounceHandler creates a bounce webhook handler
+func NewBounceHandler(db *database.Database, token string) *BounceHandler {
+	return &BounceHandler{
+		DB:     db,
+		Token:  token,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Receive records the bounces and complaints in a provider's webhook
+func (h *BounceHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if h.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBounceWebhookSize))
+	if err != nil {
+		httperr.Write(w, r, http.StatusRequestEntityTooLarge, httperr.TooLarge, "Request body too large")
+		return
+	}
+
+	provider := chi.URLParam(r, "provider")
+	var found []bounces.Bounce
+	switch provider {
+	case "ses":
+		var subscribeURL string
+		found, subscribeURL, err = bounces.ParseSES(body)
+		if err == nil && subscribeURL != "" {
+			h.confirmSubscription(w, r, body, subscribeURL)
+			return
+		}
+	case "sendgrid":
+		found, err = bounces.ParseSendGrid(body)
+	case "mailgun":
+		found, err = bounces.ParseMailgun(body)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid webhook payload")
+		return
+	}
+
+	if err := bounces.Record(r.Context(), h.DB.Connection, provider, found); err != nil {
+		log.Printf("Failed to record bounces from %s: %v", provider, err)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to record bounces")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// confirmSubscription confirms an Amazon SNS topic's subscription to the
+// webhook, which SNS asks for before sending notifications. Only messages
+// signed by SNS are confirmed, and only at an SNS URL.
+func (h *BounceHandler) confirmSubscription(w http.ResponseWriter, r *http.Request, body []byte, subscribeURL string) {
+	if !bounces.ValidSNSURL(subscribeURL) {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid subscribe URL")
+		return
+	}
+	if err := bounces.VerifySNS(r.Context(), h.Client, body); err != nil {
+		log.Printf("Refused an Amazon SNS subscription: %v", err)
+		httperr.Write(w, r, http.StatusForbidden, httperr.Forbidden, "Invalid message signature")
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid subscribe URL")
+		return
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		log.Printf("Failed to confirm the Amazon SNS subscription: %v", err)
+		httperr.Write(w, r, http.StatusBadGateway, httperr.Unavailable, "Failed to confirm subscription")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to confirm the Amazon SNS subscription: %s", resp.Status)
+		httperr.Write(w, r, http.StatusBadGateway, httperr.Unavailable, "Failed to confirm subscription")
+		return
+	}
+	log.Printf("Confirmed the Amazon SNS subscription for bounce webhooks")
+	w.WriteHeader(http.StatusNoContent)
+}
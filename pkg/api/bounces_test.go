@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+// roundTripFunc adapts a function to an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBounceHandler_Receive(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	key, certPEM := snsCertificate(t)
+	var confirmed string
+	h := NewBounceHandler(db, "hook-secret")
+	h.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, ".pem") {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(certPEM))}, nil
+		}
+		confirmed = req.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("<ConfirmSubscriptionResponse/>"))}, nil
+	})}
+	r := chi.NewRouter()
+	r.Post("/webhooks/bounces/{provider}/{token}", h.Receive)
+
+	serve := func(path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return rr
+	}
+
+	bounce := `[{"email":"Owner@example.com","event":"bounce","type":"bounce","reason":"550 no such user"}]`
+	if rr := serve("/webhooks/bounces/sendgrid/wrong", bounce); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a wrong token to be refused, got %d", rr.Code)
+	}
+	if rr := serve("/webhooks/bounces/postmark/hook-secret", bounce); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown provider to be refused, got %d", rr.Code)
+	}
+	if rr := serve("/webhooks/bounces/sendgrid/hook-secret", "not json"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid payload to be refused, got %d", rr.Code)
+	}
+	if recorded, _ := models.GetEmailBounce(db.Connection, "owner@example.com"); recorded != nil {
+		t.Fatalf("Expected nothing recorded from refused webhooks, got %+v", recorded)
+	}
+
+	if rr := serve("/webhooks/bounces/sendgrid/hook-secret", bounce); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected the webhook accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	recorded, err := models.GetEmailBounce(db.Connection, "owner@example.com")
+	if err != nil || recorded == nil || recorded.Provider != "sendgrid" || recorded.Reason != "550 no such user" {
+		t.Errorf("Expected the bounce recorded, got %+v (%v)", recorded, err)
+	}
+
+	subscribeURL := "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=t"
+	unsigned := `{"Type":"SubscriptionConfirmation","SubscribeURL":"` + subscribeURL + `"}`
+	if rr := serve("/webhooks/bounces/ses/hook-secret", unsigned); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected an unsigned subscription refused, got %d", rr.Code)
+	}
+	forged := signSNSSubscription(t, key, subscribeURL)
+	forged = strings.Replace(forged, "Token=t", "Token=u", 1)
+	if rr := serve("/webhooks/bounces/ses/hook-secret", forged); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a tampered subscription refused, got %d", rr.Code)
+	}
+	if confirmed != "" {
+		t.Fatalf("Expected refused subscriptions not to be confirmed, got %q", confirmed)
+	}
+
+	if rr := serve("/webhooks/bounces/ses/hook-secret", signSNSSubscription(t, key, subscribeURL)); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected the subscription confirmed, got %d", rr.Code)
+	}
+	if confirmed != "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=t" {
+		t.Errorf("Expected the subscribe URL fetched, got %q", confirmed)
+	}
+
+	h.Token = ""
+	if rr := serve("/webhooks/bounces/sendgrid/", bounce); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected webhooks refused without a token configured, got %d", rr.Code)
+	}
+}
+
+// snsCertificate returns a throwaway key and certificate standing in for
+// the one Amazon SNS signs with
+func snsCertificate(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// signSNSSubscription returns a subscription confirmation signed with key
+// as Amazon SNS signs them
+func signSNSSubscription(t *testing.T, key *rsa.PrivateKey, subscribeURL string) string {
+	t.Helper()
+	message := map[string]string{
+		"Type":             "SubscriptionConfirmation",
+		"MessageId":        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		"Token":            "t",
+		"TopicArn":         "arn:aws:sns:us-east-1:123456789012:ses-bounces",
+		"Message":          "You have chosen to subscribe to the topic",
+		"SubscribeURL":     subscribeURL,
+		"Timestamp":        "2026-10-17T00:00:00.000Z",
+		"SignatureVersion": "2",
+		"SigningCertURL":   "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-abc.pem",
+	}
+	var signed strings.Builder
+	for _, name := range []string{"Message", "MessageId", "SubscribeURL", "Timestamp", "Token", "TopicArn", "Type"} {
+		signed.WriteString(name + "\n" + message[name] + "\n")
+	}
+	digest := sha256.Sum256([]byte(signed.String()))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	message["Signature"] = base64.StdEncoding.EncodeToString(signature)
+	body, _ := json.Marshal(message)
+	return string(body)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/auth"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+)
+
+// formResponse is a form as the JSON API returns it. Fields are listed
+// rather than embedding models.Form, so secrets added to forms stay out of
+// responses unless they're added here; the Turnstile secret is only given
+// by RevealFormSecret.
+type formResponse struct {
+	ID                  int64     `json:"id"`
+	UserID              int64     `json:"user_id"`
+	Name                string    `json:"name"`
+	Domain              string    `json:"domain"`
+	HasTurnstileSecret  bool      `json:"has_turnstile_secret"`
+	TurnstileSiteKey    string    `json:"turnstile_site_key"`
+	ForwardEmail        string    `json:"forward_email"`
+	FormKey             string    `json:"form_key"`
+	SubmissionCount     int       `json:"submission_count"`
+	Tags                []string  `json:"tags"`
+	AkismetEnabled      bool      `json:"akismet_enabled"`
+	ThreadBySender      bool      `json:"thread_by_sender"`
+	TurnstileFallback   string    `json:"turnstile_fallback"`
+	SpamFlagThreshold   int       `json:"spam_flag_threshold"`
+	SpamRejectThreshold int       `json:"spam_reject_threshold"`
+	AllowedCountries    string    `json:"allowed_countries"`
+	BlockedCountries    string    `json:"blocked_countries"`
+	EmailAttachment     string    `json:"email_attachment"`
+	HostedPage          bool      `json:"hosted_page"`
+	AllowCC             bool      `json:"allow_cc"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// newFormResponse returns a form's response, without its secrets
+func newFormResponse(form *models.Form) formResponse {
+	return formResponse{
+		ID:                  form.ID,
+		UserID:              form.UserID,
+		Name:                form.Name,
+		Domain:              form.Domain,
+		HasTurnstileSecret:  form.TurnstileSecret != "",
+		TurnstileSiteKey:    form.TurnstileSiteKey,
+		ForwardEmail:        form.ForwardEmail,
+		FormKey:             form.FormKey,
+		SubmissionCount:     form.SubmissionCount,
+		Tags:                form.Tags,
+		AkismetEnabled:      form.AkismetEnabled,
+		ThreadBySender:      form.ThreadBySender,
+		TurnstileFallback:   form.TurnstileFallback,
+		SpamFlagThreshold:   form.SpamFlagThreshold,
+		SpamRejectThreshold: form.SpamRejectThreshold,
+		AllowedCountries:    form.AllowedCountries,
+		BlockedCountries:    form.BlockedCountries,
+		EmailAttachment:     form.EmailAttachment,
+		HostedPage:          form.HostedPage,
+		AllowCC:             form.AllowCC,
+		CreatedAt:           form.CreatedAt,
+		UpdatedAt:           form.UpdatedAt,
+	}
+}
+
+// RevealFormSecret returns a form's Turnstile secret to its owner, who must
+// give their password again, so a stolen session or a left open browser
+// isn't enough to read it
+func (h *FormHandler) RevealFormSecret(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+
+	formID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
+		return
+	}
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
+		return
+	}
+	if form == nil {
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return
+	}
+
+	password := r.FormValue("password")
+	if password == "" || auth.CheckPassword(password, user.PasswordHash) != nil {
+		httperr.Write(w, r, http.StatusForbidden, httperr.Forbidden, "Enter your password to reveal the secret")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Secrets shouldn't linger in caches
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"turnstile_secret": form.TurnstileSecret})
+}
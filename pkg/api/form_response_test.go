@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+)
+
+func TestFormResponses_HideSecret(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	hash, _ := auth.HashPassword("correct horse")
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", hash)
+	other, _ := models.CreateUser(db.Connection, "other@example.com", hash)
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "0x4AAA-secret", "to@example.com", "secret-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	h := NewFormHandler(db, nil)
+	serve := func(handler http.HandlerFunc, method string, as *models.User, values url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, "/forms/1", strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "1")
+		ctx := context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx)
+		r = r.WithContext(context.WithValue(ctx, middleware.UserKey, as))
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		return rr
+	}
+
+	for name, rr := range map[string]*httptest.ResponseRecorder{
+		"one":  serve(h.GetForm, "GET", user, nil),
+		"list": serve(h.GetUserForms, "GET", user, nil),
+	} {
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", name, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "0x4AAA-secret") || strings.Contains(rr.Body.String(), `"turnstile_secret"`) {
+			t.Errorf("%s: expected no secret, got %s", name, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), `"has_turnstile_secret":true`) {
+			t.Errorf("%s: expected to be told a secret is set, got %s", name, rr.Body.String())
+		}
+	}
+
+	if rr := serve(h.RevealFormSecret, "POST", user, url.Values{"password": {"wrong"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a wrong password to be refused, got %d", rr.Code)
+	}
+	if rr := serve(h.RevealFormSecret, "POST", other, url.Values{"password": {"correct horse"}}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected other users to be refused, got %d", rr.Code)
+	}
+	rr := serve(h.RevealFormSecret, "POST", user, url.Values{"password": {"correct horse"}})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"turnstile_secret":"0x4AAA-secret"`) {
+		t.Errorf("Expected the secret with the password, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Cache-Control") != "no-store" {
+		t.Error("Expected the secret not to be cached")
+	}
+
+	// Saving without the secret, which isn't shown any more, keeps it
+	rr = serve(h.UpdateForm, "PUT", user, url.Values{"name": {"Renamed"}, "domain": {"example.com"}, "turnstile_secret": {""}, "forward_email": {"to@example.com"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the update to succeed, got %d %s", rr.Code, rr.Body.String())
+	}
+	if updated, _ := models.GetFormByID(db.Connection, form.ID); updated.Name != "Renamed" || updated.TurnstileSecret != "0x4AAA-secret" {
+		t.Errorf("Expected the saved secret kept, got %+v", updated)
+	}
+}
@@ -1,39 +1,69 @@
 package api
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/events"
+	"staticsend/pkg/flash"
+	"staticsend/pkg/httperr"
 	"staticsend/pkg/middleware"
 	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
 	"staticsend/pkg/utils"
 )
 
+// FormPartials renders the dashboard fragments that replace a full page
+// reload after an HTMX request changes a form
+type FormPartials interface {
+	FormCreated(w http.ResponseWriter, r *http.Request, form *models.Form, message string)
+	FormUpdated(w http.ResponseWriter, r *http.Request, form *models.Form, message string)
+	FormDeleted(w http.ResponseWriter, r *http.Request, form *models.Form, message string)
+}
+
 // FormHandler handles form-related API requests
 type FormHandler struct {
-	DB *sql.DB
+	DB *database.Database
+	// Partials is optional; without it HTMX requests reload the dashboard
+	Partials FormPartials
+	// Notifier tells the owner's channels about forms being created and
+	// updated, when set
+	Notifier *notify.Dispatcher
+	// Events is told about forms being deleted, when set
+	Events *events.Bus
 }
 
 // NewFormHandler creates a new form handler
-func NewFormHandler(db *sql.DB) *FormHandler {
+func NewFormHandler(db *database.Database, partials FormPartials) *FormHandler {
 	return &FormHandler{
-		DB: db,
+		DB:       db,
+		Partials: partials,
 	}
 }
 
+// usePartials reports whether the response should be a dashboard fragment
+func (h *FormHandler) usePartials(r *http.Request) bool {
+	return h.Partials != nil && r.Header.Get("HX-Request") == "true"
+}
+
 // CreateForm handles form creation
 func (h *FormHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
 		return
 	}
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form data")
 		return
 	}
 
@@ -43,158 +73,304 @@ func (h *FormHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
 	forwardEmail := r.FormValue("forward_email")
 
 	if name == "" || domain == "" || turnstileSecret == "" || forwardEmail == "" {
-		http.Error(w, "Name, domain, secret key, and forward email are required", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Name, domain, secret key, and forward email are required")
+		return
+	}
+
+	// Enforce the user's form limit
+	if !h.checkFormLimit(w, r, user) {
 		return
 	}
 
 	// Auto-generate unique form key
 	formKey, err := utils.GenerateFormKey()
 	if err != nil {
-		http.Error(w, "Failed to generate form key", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to generate form key")
 		return
 	}
 
 	// Check if form name already exists for this user
-	exists, err := models.FormExists(h.DB, user.ID, name)
+	exists, err := models.FormExistsContext(r.Context(), h.DB.Connection, user.ID, name)
 	if err != nil {
-		http.Error(w, "Failed to check form existence", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to check form existence")
 		return
 	}
 	if exists {
-		http.Error(w, "Form with this name already exists", http.StatusConflict)
+		httperr.Write(w, r, http.StatusConflict, httperr.Conflict, "Form with this name already exists")
+		return
+	}
+
+	form, err := models.CreateFormContext(r.Context(), h.DB.Connection, user.ID, name, domain, turnstileSecret, forwardEmail, formKey)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to create form")
+		return
+	}
+
+	if tags := models.ParseTags(r.FormValue("tags")); len(tags) > 0 {
+		if err := models.SetFormTagsContext(r.Context(), h.DB.Connection, user.ID, form.ID, tags); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save tags")
+			return
+		}
+	}
+	if siteKey := strings.TrimSpace(r.FormValue("turnstile_site_key")); siteKey != "" {
+		if err := models.SetFormTurnstileSiteKeyContext(r.Context(), h.DB.Connection, form.ID, siteKey); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save Turnstile site key")
+			return
+		}
+		form.TurnstileSiteKey = siteKey
+	}
+
+	h.notifyForm(notify.EventFormCreated, form.ID)
+
+	message := fmt.Sprintf("Form %q created", form.Name)
+	if h.usePartials(r) {
+		h.Partials.FormCreated(w, r, form, message)
+		return
+	}
+
+	// Use HX-Redirect for HTMX to properly handle the redirect
+	flash.Set(w, message)
+	w.Header().Set("HX-Redirect", "/dashboard")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// DuplicateForm creates a copy of a form's settings, tags and IP rules
+// with a new form key, named e.g. "Contact copy"
+func (h *FormHandler) DuplicateForm(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+
+	formID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
 		return
 	}
 
-	_, err = models.CreateForm(h.DB, user.ID, name, domain, turnstileSecret, forwardEmail, formKey)
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
 	if err != nil {
-		http.Error(w, "Failed to create form", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
+		return
+	}
+	if form == nil {
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return
+	}
+
+	// Enforce the user's form limit
+	if !h.checkFormLimit(w, r, user) {
+		return
+	}
+
+	formKey, err := utils.GenerateFormKey()
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to generate form key")
+		return
+	}
+	name, err := models.CopyNameContext(r.Context(), h.DB.Connection, user.ID, form.Name)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to check form existence")
+		return
+	}
+
+	duplicate, err := models.DuplicateFormContext(r.Context(), h.DB.Connection, form, name, formKey)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to duplicate form")
+		return
+	}
+
+	h.notifyForm(notify.EventFormCreated, duplicate.ID)
+
+	message := fmt.Sprintf("Form %q created from %q", name, form.Name)
+	if h.usePartials(r) {
+		h.Partials.FormCreated(w, r, duplicate, message)
 		return
 	}
 
 	// Use HX-Redirect for HTMX to properly handle the redirect
+	flash.Set(w, message)
 	w.Header().Set("HX-Redirect", "/dashboard")
 	w.WriteHeader(http.StatusCreated)
 }
 
+// notifyForm tells the owner's channels subscribed to form events about a
+// change to a form, in the background so the request isn't held up
+func (h *FormHandler) notifyForm(event string, formID int64) {
+	if h.Notifier == nil {
+		return
+	}
+	go func() {
+		form, err := models.GetFormByIDContext(context.Background(), h.DB.Connection, formID)
+		if err != nil || form == nil {
+			log.Printf("Failed to load form %d to notify of %s: %v", formID, event, err)
+			return
+		}
+		if err := h.Notifier.NotifyForm(context.Background(), event, form); err != nil {
+			log.Printf("Failed to queue %s notifications for form %d: %v", event, formID, err)
+		}
+	}()
+}
+
+// checkFormLimit reports whether the user may create another form. If not,
+// or the limit can't be checked, it writes an error response.
+func (h *FormHandler) checkFormLimit(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	quota, err := models.GetUserQuotaContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to check usage limits")
+		return false
+	}
+	if quota.MaxForms == 0 {
+		return true
+	}
+
+	usage, err := models.GetUsageContext(r.Context(), h.DB.Connection, user.ID, time.Now())
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to check usage limits")
+		return false
+	}
+	if quota.FormsExceeded(usage) {
+		httperr.Write(w, r, http.StatusPaymentRequired, httperr.QuotaExceeded, fmt.Sprintf("You have reached your limit of %d forms", quota.MaxForms))
+		return false
+	}
+	return true
+}
+
 // GetForm handles retrieving a single form
 func (h *FormHandler) GetForm(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
 		return
 	}
 
 	formIDStr := chi.URLParam(r, "id")
 	formID, err := strconv.ParseInt(formIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
 		return
 	}
 
-	form, err := models.GetFormByID(h.DB, formID)
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
 	if err != nil {
-		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
 		return
 	}
 	if form == nil {
-		http.Error(w, "Form not found", http.StatusNotFound)
-		return
-	}
-
-	// Verify user owns this form
-	if form.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
 		return
 	}
 
 	// Get submission count
-	count, err := models.GetSubmissionCountByFormID(h.DB, form.ID)
+	count, err := models.GetSubmissionCountByFormIDContext(r.Context(), h.DB.Connection, form.ID)
 	if err == nil {
 		form.SubmissionCount = count
 	}
 
+	form.Tags, err = models.GetFormTagsContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch tags")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(form)
+	json.NewEncoder(w).Encode(newFormResponse(form))
 }
 
 // DeleteForm handles form deletion
 func (h *FormHandler) DeleteForm(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
 		return
 	}
 
 	formIDStr := chi.URLParam(r, "id")
 	formID, err := strconv.ParseInt(formIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
 		return
 	}
 
-	form, err := models.GetFormByID(h.DB, formID)
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
 	if err != nil {
-		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
 		return
 	}
 	if form == nil {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
 		return
 	}
 
-	// Verify user owns this form
-	if form.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Delete the form and everything that belongs to it
+	if err := models.DeleteFormContext(r.Context(), h.DB.Connection, formID); err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to delete form")
 		return
 	}
+	if h.Events != nil {
+		h.Events.Publish(events.Event{Name: events.FormDeleted, Form: form, User: user})
+	}
 
-	// Delete form from database
-	_, err = h.DB.Exec("DELETE FROM forms WHERE id = ?", formID)
-	if err != nil {
-		http.Error(w, "Failed to delete form", http.StatusInternalServerError)
+	message := fmt.Sprintf("Form %q deleted", form.Name)
+	if h.usePartials(r) {
+		h.Partials.FormDeleted(w, r, form, message)
 		return
 	}
 
 	// Tell HTMX to refresh the page content
+	flash.Set(w, message)
 	w.Header().Set("HX-Refresh", "true")
 	w.WriteHeader(http.StatusOK)
 }
 
+// spamThreshold reads an optional spam score threshold from 0 to 100,
+// returning current when the request doesn't include it. An empty value
+// turns the threshold off.
+func spamThreshold(r *http.Request, field string, current int) (int, bool, error) {
+	if _, ok := r.Form[field]; !ok {
+		return current, false, nil
+	}
+	value := strings.TrimSpace(r.FormValue(field))
+	if value == "" {
+		return 0, true, nil
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 0 || threshold > 100 {
+		return 0, false, fmt.Errorf("Spam thresholds must be numbers from 0 to 100")
+	}
+	return threshold, true, nil
+}
+
 // UpdateForm handles form updates
 func (h *FormHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
 		return
 	}
 
 	formIDStr := chi.URLParam(r, "id")
 	formID, err := strconv.ParseInt(formIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
 		return
 	}
 
 	// Fetch form from database to verify ownership
-	form, err := models.GetFormByID(h.DB, formID)
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
 	if err != nil {
-		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
 		return
 	}
 	if form == nil {
-		http.Error(w, "Form not found", http.StatusNotFound)
-		return
-	}
-
-	// Verify user owns this form
-	if form.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
 		return
 	}
 
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form data")
 		return
 	}
 
@@ -202,20 +378,134 @@ func (h *FormHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 	domain := r.FormValue("domain")
 	turnstileSecret := r.FormValue("turnstile_secret")
 	forwardEmail := r.FormValue("forward_email")
+	// The secret isn't shown again, so leaving it blank keeps the saved one
+	if turnstileSecret == "" {
+		turnstileSecret = form.TurnstileSecret
+	}
 
 	if name == "" || domain == "" || turnstileSecret == "" || forwardEmail == "" {
-		http.Error(w, "Name, domain, secret key, and forward email are required", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Name, domain, secret key, and forward email are required")
 		return
 	}
+	if _, ok := r.Form["turnstile_fallback"]; ok && !models.ValidTurnstileFallback(r.FormValue("turnstile_fallback")) {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Unknown Turnstile fallback")
+		return
+	}
+	if _, ok := r.Form["email_attachment"]; ok && !models.ValidEmailAttachment(r.FormValue("email_attachment")) {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Unknown email attachment")
+		return
+	}
+	flagAt, ok, err := spamThreshold(r, "spam_flag_threshold", form.SpamFlagThreshold)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, err.Error())
+		return
+	}
+	rejectAt, rejectOK, err := spamThreshold(r, "spam_reject_threshold", form.SpamRejectThreshold)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, err.Error())
+		return
+	}
+	setThresholds := ok || rejectOK
+	allowedCountries, allowedErr := models.ParseCountries(r.FormValue("allowed_countries"))
+	blockedCountries, blockedErr := models.ParseCountries(r.FormValue("blocked_countries"))
+	if allowedErr != nil || blockedErr != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Countries must be two letter codes such as NZ, separated by commas")
+		return
+	}
+	_, setAllowed := r.Form["allowed_countries"]
+	_, setBlocked := r.Form["blocked_countries"]
+	if !setAllowed {
+		allowedCountries, _ = models.ParseCountries(form.AllowedCountries)
+	}
+	if !setBlocked {
+		blockedCountries, _ = models.ParseCountries(form.BlockedCountries)
+	}
 
 	// Update form
-	err = models.UpdateForm(h.DB, formID, name, domain, turnstileSecret, forwardEmail)
+	err = models.UpdateFormContext(r.Context(), h.DB.Connection, formID, name, domain, turnstileSecret, forwardEmail)
 	if err != nil {
-		http.Error(w, "Failed to update form", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to update form")
+		return
+	}
+
+	// Only replace the tags when the request includes them
+	if _, ok := r.Form["tags"]; ok {
+		if err := models.SetFormTagsContext(r.Context(), h.DB.Connection, user.ID, formID, models.ParseTags(r.FormValue("tags"))); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save tags")
+			return
+		}
+	}
+	// Likewise for the Turnstile site key
+	if _, ok := r.Form["turnstile_site_key"]; ok {
+		if err := models.SetFormTurnstileSiteKeyContext(r.Context(), h.DB.Connection, formID, strings.TrimSpace(r.FormValue("turnstile_site_key"))); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save Turnstile site key")
+			return
+		}
+	}
+	// Likewise for email threading
+	if _, ok := r.Form["email_thread"]; ok {
+		if err := models.SetFormThreadingContext(r.Context(), h.DB.Connection, formID, r.FormValue("email_thread") == "sender"); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save email threading")
+			return
+		}
+	}
+	// And what happens to submissions while Turnstile is down
+	if _, ok := r.Form["turnstile_fallback"]; ok {
+		if err := models.SetFormTurnstileFallbackContext(r.Context(), h.DB.Connection, formID, r.FormValue("turnstile_fallback")); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save Turnstile fallback")
+			return
+		}
+	}
+	// And the file notification emails attach the submission as
+	if _, ok := r.Form["email_attachment"]; ok {
+		if err := models.SetFormEmailAttachmentContext(r.Context(), h.DB.Connection, formID, r.FormValue("email_attachment")); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save email attachment")
+			return
+		}
+	}
+	// And whether the form has a hosted page
+	if _, ok := r.Form["hosted_page"]; ok {
+		if err := models.SetFormHostedPageContext(r.Context(), h.DB.Connection, formID, r.FormValue("hosted_page") == "on"); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save hosted page")
+			return
+		}
+	}
+	// And whether submissions can copy the email to other addresses
+	if _, ok := r.Form["allow_cc"]; ok {
+		if err := models.SetFormAllowCCContext(r.Context(), h.DB.Connection, formID, r.FormValue("allow_cc") == "on"); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save copies")
+			return
+		}
+	}
+	// And the spam scores that flag or reject submissions
+	if setThresholds {
+		if err := models.SetFormSpamThresholdsContext(r.Context(), h.DB.Connection, formID, flagAt, rejectAt); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save spam thresholds")
+			return
+		}
+	}
+	// And the countries submissions are accepted from
+	if setAllowed || setBlocked {
+		if err := models.SetFormCountriesContext(r.Context(), h.DB.Connection, formID, allowedCountries, blockedCountries); err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save countries")
+			return
+		}
+	}
+
+	h.notifyForm(notify.EventFormUpdated, formID)
+
+	if h.usePartials(r) {
+		updated, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
+		if err != nil || updated == nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
+			return
+		}
+		h.Partials.FormUpdated(w, r, updated, "Form updated")
 		return
 	}
 
 	// Use HX-Redirect for HTMX to properly handle the redirect
+	flash.Set(w, "Form updated")
 	w.Header().Set("HX-Redirect", "/dashboard")
 	w.WriteHeader(http.StatusOK)
 }
@@ -224,26 +514,45 @@ func (h *FormHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 func (h *FormHandler) GetUserForms(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
 		return
 	}
 
-	forms, err := models.GetFormsByUserID(h.DB, user.ID)
+	// Optionally only list forms with a tag
+	var forms []models.Form
+	var err error
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		forms, err = models.GetFormsByUserIDAndTagContext(r.Context(), h.DB.Connection, user.ID, strings.ToLower(tag))
+	} else {
+		forms, err = models.GetFormsByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	}
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch forms")
+		return
+	}
+
+	formTags, err := models.GetFormTagsByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch tags")
+		return
+	}
+	counts, err := models.GetSubmissionCountsByUserIDContext(r.Context(), h.DB.Connection, user.ID)
 	if err != nil {
-		http.Error(w, "Failed to fetch forms", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch submission counts")
 		return
 	}
 
-	// Get submission counts for each form
-	formPtrs := make([]*models.Form, len(forms))
+	// Add submission counts and tags to each form
+	responses := make([]formResponse, len(forms))
 	for i := range forms {
-		formPtrs[i] = &forms[i]
-		count, err := models.GetSubmissionCountByFormID(h.DB, formPtrs[i].ID)
-		if err == nil {
-			formPtrs[i].SubmissionCount = count
+		forms[i].SubmissionCount = counts[forms[i].ID]
+		forms[i].Tags = formTags[forms[i].ID]
+		if forms[i].Tags == nil {
+			forms[i].Tags = []string{}
 		}
+		responses[i] = newFormResponse(&forms[i])
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(formPtrs)
+	json.NewEncoder(w).Encode(responses)
 }
\ No newline at end of file
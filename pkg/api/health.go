@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+)
+
+// Health statuses reported for the service and each of its checks
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+)
+
+// healthCheckTimeout bounds how long the readiness checks can take, so a
+// stuck database fails the probe instead of hanging it
+const healthCheckTimeout = 2 * time.Second
+
+// HealthHandler reports whether the service and its dependencies are healthy
+type HealthHandler struct {
+	DB           *database.Database
+	EmailService *email.EmailService
+	// MinFreeDisk is the free space, in bytes, below which the disk holding
+	// the SQLite database is reported as degraded. Zero disables the check.
+	MinFreeDisk uint64
+	// CheckEmail makes readiness connect and sign in to the SMTP server, so
+	// an instance that can't send email is taken out of rotation
+	CheckEmail bool
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(db *database.Database, emailService *email.EmailService, minFreeDisk uint64) *HealthHandler {
+	return &HealthHandler{
+		DB:           db,
+		EmailService: emailService,
+		MinFreeDisk:  minFreeDisk,
+	}
+}
+
+// readiness is the body of a readiness response
+type readiness struct {
+	Status     string         `json:"status"`
+	Database   databaseHealth `json:"database"`
+	Migrations migrationState `json:"migrations"`
+	EmailQueue queueHealth    `json:"email_queue"`
+	Disk       *diskHealth    `json:"disk,omitempty"`
+	Email      *emailHealth   `json:"email,omitempty"`
+}
+
+type databaseHealth struct {
+	Status    string `json:"status"`
+	Dialect   string `json:"dialect"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type migrationState struct {
+	Status  string `json:"status"`
+	Version int    `json:"version"`
+	Latest  int    `json:"latest"`
+	Error   string `json:"error,omitempty"`
+}
+
+type queueHealth struct {
+	Status   string `json:"status"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+}
+
+type emailHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type diskHealth struct {
+	Status     string `json:"status"`
+	FreeBytes  uint64 `json:"free_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Live reports that the process is up and serving requests. It checks no
+// dependencies, so an orchestrator only restarts an instance that's stuck.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": healthOK})
+}
+
+// Ready checks the database, migrations, email queue, free disk space and,
+// with CheckEmail, the SMTP server. It responds 200 when everything is
+// healthy and 503 with the details otherwise, so load balancers stop routing
+// to a degraded instance.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	report := readiness{
+		Database:   h.checkDatabase(ctx),
+		EmailQueue: h.checkEmailQueue(),
+		Disk:       h.checkDisk(),
+	}
+	if h.CheckEmail {
+		report.Email = h.checkEmail(ctx)
+	}
+	// Migrations can't be checked without a database
+	if report.Database.Status == healthOK {
+		report.Migrations = h.checkMigrations(ctx)
+	} else {
+		report.Migrations = migrationState{Status: healthDegraded, Error: "database unavailable"}
+	}
+
+	report.Status = healthOK
+	status := http.StatusOK
+	if report.Database.Status != healthOK || report.Migrations.Status != healthOK ||
+		report.EmailQueue.Status != healthOK || (report.Disk != nil && report.Disk.Status != healthOK) ||
+		(report.Email != nil && report.Email.Status != healthOK) {
+		report.Status = healthDegraded
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// checkDatabase pings the database
+func (h *HealthHandler) checkDatabase(ctx context.Context) databaseHealth {
+	result := databaseHealth{Status: healthOK, Dialect: string(h.DB.Dialect)}
+	start := time.Now()
+	err := h.DB.Connection.PingContext(ctx)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Status = healthDegraded
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkMigrations reports the schema version, which is degraded while
+// migrations are pending
+func (h *HealthHandler) checkMigrations(ctx context.Context) migrationState {
+	current, latest, err := h.DB.SchemaVersion(ctx)
+	if err != nil {
+		return migrationState{Status: healthDegraded, Error: err.Error()}
+	}
+	result := migrationState{Status: healthOK, Version: current, Latest: latest}
+	if current < latest {
+		result.Status = healthDegraded
+	}
+	return result
+}
+
+// checkEmailQueue reports how many emails are waiting to be sent. A full
+// queue means new submissions can't be forwarded.
+func (h *HealthHandler) checkEmailQueue() queueHealth {
+	result := queueHealth{Status: healthOK}
+	if h.EmailService == nil {
+		return result
+	}
+	result.Depth = h.EmailService.QueueSize()
+	result.Capacity = h.EmailService.QueueCapacity()
+	if result.Capacity > 0 && result.Depth >= result.Capacity {
+		result.Status = healthDegraded
+	}
+	return result
+}
+
+// checkEmail connects and signs in to the SMTP server, giving up when ctx
+// is done
+func (h *HealthHandler) checkEmail(ctx context.Context) *emailHealth {
+	if h.EmailService == nil {
+		return nil
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- h.EmailService.TestConnection()
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return &emailHealth{Status: healthDegraded, Error: err.Error()}
+		}
+		return &emailHealth{Status: healthOK}
+	case <-ctx.Done():
+		return &emailHealth{Status: healthDegraded, Error: "SMTP server didn't respond in time"}
+	}
+}
+
+// checkDisk reports the free space next to the SQLite database, or nil when
+// it isn't available for this engine or platform
+func (h *HealthHandler) checkDisk() *diskHealth {
+	free, total, err := h.DB.DiskSpace()
+	if errors.Is(err, database.ErrDiskSpaceUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return &diskHealth{Status: healthDegraded, Error: err.Error()}
+	}
+	result := &diskHealth{Status: healthOK, FreeBytes: free, TotalBytes: total}
+	if h.MinFreeDisk > 0 && free < h.MinFreeDisk {
+		result.Status = healthDegraded
+	}
+	return result
+}
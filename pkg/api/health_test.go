@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+)
+
+func TestHealthHandler_Ready(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	emailService := email.NewEmailService(email.EmailConfig{}, 10, 0, 0)
+	handler := NewHealthHandler(db, emailService, 1)
+
+	ready := func() (int, readiness) {
+		rr := httptest.NewRecorder()
+		handler.Ready(rr, httptest.NewRequest("GET", "/health/ready", nil))
+		var report readiness
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return rr.Code, report
+	}
+
+	code, report := ready()
+	if code != http.StatusOK || report.Status != healthOK {
+		t.Fatalf("Expected a healthy instance, got %d %+v", code, report)
+	}
+	if report.Migrations.Version != report.Migrations.Latest {
+		t.Errorf("Expected no pending migrations, got %+v", report.Migrations)
+	}
+	if report.EmailQueue.Capacity != 10 {
+		t.Errorf("Expected an email queue capacity of 10, got %d", report.EmailQueue.Capacity)
+	}
+
+	// Demand more free space than any disk has
+	handler.MinFreeDisk = 1 << 62
+	code, report = ready()
+	if report.Disk != nil {
+		if code != http.StatusServiceUnavailable || report.Disk.Status != healthDegraded {
+			t.Errorf("Expected low disk space to be degraded, got %d %+v", code, report.Disk)
+		}
+	}
+	handler.MinFreeDisk = 1
+
+	db.Connection.Close()
+	code, report = ready()
+	if code != http.StatusServiceUnavailable || report.Database.Status != healthDegraded {
+		t.Errorf("Expected an unreachable database to be degraded, got %d %+v", code, report.Database)
+	}
+}
+
+func TestHealthHandler_Live(t *testing.T) {
+	handler := NewHealthHandler(nil, nil, 0)
+
+	rr := httptest.NewRecorder()
+	handler.Live(rr, httptest.NewRequest("GET", "/healthz", nil))
+	var body map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rr.Code != http.StatusOK || body["status"] != healthOK {
+		t.Errorf("Expected a live instance, got %d %v", rr.Code, body)
+	}
+}
+
+func TestHealthHandler_ReadyChecksEmail(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Nothing listens on port 1
+	emailService := email.NewEmailService(email.EmailConfig{Host: "127.0.0.1", Port: 1}, 10, 0, 0)
+	handler := NewHealthHandler(db, emailService, 0)
+
+	ready := func() (int, readiness) {
+		rr := httptest.NewRecorder()
+		handler.Ready(rr, httptest.NewRequest("GET", "/readyz", nil))
+		var report readiness
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return rr.Code, report
+	}
+
+	code, report := ready()
+	if code != http.StatusOK || report.Email != nil {
+		t.Errorf("Expected email not to be checked by default, got %d %+v", code, report.Email)
+	}
+
+	handler.CheckEmail = true
+	code, report = ready()
+	if code != http.StatusServiceUnavailable || report.Email == nil || report.Email.Status != healthDegraded {
+		t.Errorf("Expected an unreachable SMTP server to be degraded, got %d %+v", code, report.Email)
+	}
+}
@@ -0,0 +1,377 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+)
+
+const (
+	// defaultPollLimit is how many submissions the polling trigger lists
+	// when the request doesn't say
+	defaultPollLimit = 25
+	// maxPollLimit caps the submissions listed in one poll
+	maxPollLimit = 100
+)
+
+// HooksHandler lets automation tools such as Zapier and Make follow forms'
+// new submissions, either by subscribing a REST hook or by polling. Requests
+// are authenticated with an API key.
+type HooksHandler struct {
+	DB *database.Database
+	// Notifier builds the messages hooks are sent, so polled submissions
+	// look the same as those pushed to subscribed hooks
+	Notifier *notify.Dispatcher
+}
+
+// NewHooksHandler creates a new REST hooks handler
+func NewHooksHandler(db *database.Database, notifier *notify.Dispatcher) *HooksHandler {
+	return &HooksHandler{
+		DB:       db,
+		Notifier: notifier,
+	}
+}
+
+// writeJSON writes v as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Me describes the API key's user, which tools call to test a connection
+func (h *HooksHandler) Me(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": user.ID, "email": user.Email})
+}
+
+// Forms lists the user's forms, for tools to offer when setting up a trigger
+func (h *HooksHandler) Forms(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+
+	forms, err := models.GetFormsByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch forms")
+		return
+	}
+	list := make([]map[string]interface{}, len(forms))
+	for i, form := range forms {
+		list[i] = map[string]interface{}{"id": form.ID, "name": form.Name, "domain": form.Domain}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// subscription is a request to subscribe or unsubscribe a hook. Zapier sends
+// the hook's address as target_url and Make as url; hookUrl is also taken.
+type subscription struct {
+	ID        json.Number `json:"id"`
+	FormID    json.Number `json:"form_id"`
+	Event     string      `json:"event"`
+	Name      string      `json:"name"`
+	TargetURL string      `json:"target_url"`
+	HookURL   string      `json:"hookUrl"`
+	URL       string      `json:"url"`
+}
+
+// target returns the hook's address, whichever name it was sent under
+func (s subscription) target() string {
+	for _, value := range []string{s.TargetURL, s.HookURL, s.URL} {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// readSubscription reads a subscription sent as JSON or as form values
+func readSubscription(w http.ResponseWriter, r *http.Request) (subscription, error) {
+	var sub subscription
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&sub)
+		return sub, err
+	}
+	if err := r.ParseForm(); err != nil {
+		return sub, err
+	}
+	sub.ID = json.Number(r.FormValue("id"))
+	sub.FormID = json.Number(r.FormValue("form_id"))
+	sub.Event = r.FormValue("event")
+	sub.Name = r.FormValue("name")
+	sub.TargetURL = r.FormValue("target_url")
+	sub.HookURL = r.FormValue("hookUrl")
+	sub.URL = r.FormValue("url")
+	return sub, nil
+}
+
+// Subscribe adds a REST hook to a form, which is sent each event of the
+// type subscribed to, new submissions by default, until it's unsubscribed
+// or answers 410 Gone
+func (h *HooksHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+	sub, err := readSubscription(w, r)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid subscription")
+		return
+	}
+	event := sub.Event
+	if event == "" {
+		event = notify.EventSubmissionCreated
+	}
+	if !notify.IsEvent(event) {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Unsupported event: "+event)
+		return
+	}
+
+	formID, err := sub.FormID.Int64()
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "form_id is required")
+		return
+	}
+	form, ok := h.ownedForm(w, r, user, formID)
+	if !ok {
+		return
+	}
+
+	config := map[string]string{"url": sub.target()}
+	kind := notify.KindByName(notify.RESTHookKind)
+	if err := kind.Validate(config); err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, err.Error())
+		return
+	}
+	name := strings.TrimSpace(sub.Name)
+	if name == "" {
+		name = kind.Label
+	}
+	channel, err := models.CreateNotificationChannelContext(r.Context(), h.DB.Connection, form.ID, notify.RESTHookKind, name, config, []string{event})
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to subscribe")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":         channel.ID,
+		"form_id":    form.ID,
+		"event":      event,
+		"target_url": config["url"],
+	})
+}
+
+// Unsubscribe removes the REST hook in the URL
+func (h *HooksHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "hookID"), 10, 64)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid hook ID")
+		return
+	}
+	h.unsubscribe(w, r, user, id, "")
+}
+
+// UnsubscribeByBody removes the REST hook with the id, or the address, in
+// the request body, for tools that can't send a DELETE to the hook's URL
+func (h *HooksHandler) UnsubscribeByBody(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+	sub, err := readSubscription(w, r)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid subscription")
+		return
+	}
+	id, _ := sub.ID.Int64()
+	if id == 0 && sub.target() == "" {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "id or target_url is required")
+		return
+	}
+	h.unsubscribe(w, r, user, id, sub.target())
+}
+
+// unsubscribe removes the user's REST hook with id, or else every one of
+// them sending to target
+func (h *HooksHandler) unsubscribe(w http.ResponseWriter, r *http.Request, user *models.User, id int64, target string) {
+	forms, err := models.GetFormsByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch forms")
+		return
+	}
+
+	removed := 0
+	for _, form := range forms {
+		channels, err := models.GetNotificationChannelsByFormIDContext(r.Context(), h.DB.Connection, form.ID, false)
+		if err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch hooks")
+			return
+		}
+		for _, channel := range channels {
+			if channel.Type != notify.RESTHookKind {
+				continue
+			}
+			if (id != 0 && channel.ID != id) || (id == 0 && channel.Config["url"] != target) {
+				continue
+			}
+			if err := models.DeleteNotificationChannelContext(r.Context(), h.DB.Connection, channel.ID); err != nil {
+				httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to unsubscribe")
+				return
+			}
+			removed++
+		}
+	}
+	if removed == 0 {
+		httperr.Write(w, r, http.StatusNotFound, httperr.NotFound, "Hook not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Submissions lists a form's newest submissions in the shape hooks are sent,
+// for tools that poll for new submissions instead of subscribing. Spam isn't
+// listed. Tools tell submissions apart by their id.
+func (h *HooksHandler) Submissions(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.formFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultPollLimit
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, maxPollLimit)
+	}
+	submissions, err := models.GetSubmissionsPageContext(r.Context(), h.DB.Connection, form.ID, models.SubmissionFilter{}, limit, 0)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch submissions")
+		return
+	}
+
+	list := make([]map[string]interface{}, len(submissions))
+	for i := range submissions {
+		list[i] = notify.HookPayload(h.Notifier.SubmissionMessage(form, &submissions[i]))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Sample returns a submission to show while setting up a trigger: the
+// form's newest one, or one made up from the fields it has been sent. The
+// event query parameter picks the event to show, new submissions by default.
+func (h *HooksHandler) Sample(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.formFromURL(w, r)
+	if !ok {
+		return
+	}
+	event := r.URL.Query().Get("event")
+	if event == "" {
+		event = notify.EventSubmissionCreated
+	}
+	if !notify.IsEvent(event) {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Unsupported event: "+event)
+		return
+	}
+	if event == notify.EventFormCreated || event == notify.EventFormUpdated || event == notify.EventSubmissionSpike {
+		writeJSON(w, http.StatusOK, []map[string]interface{}{notify.HookPayload(h.Notifier.FormMessage(event, form))})
+		return
+	}
+
+	submissions, err := models.GetSubmissionsPageContext(r.Context(), h.DB.Connection, form.ID, models.SubmissionFilter{}, 1, 0)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch submissions")
+		return
+	}
+	sample := &models.Submission{ID: 1, FormID: form.ID, CreatedAt: time.Now().UTC()}
+	if len(submissions) > 0 {
+		sample = &submissions[0]
+	} else {
+		names, err := models.GetSubmissionFieldNamesContext(r.Context(), h.DB.Connection, form.ID, maxPollLimit)
+		if err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch fields")
+			return
+		}
+		sample.SubmittedData = sampleData(names)
+	}
+	msg := h.Notifier.SubmissionMessage(form, sample)
+	msg.Event = event
+	msg.Detail = sampleDetails[event]
+	writeJSON(w, http.StatusOK, []map[string]interface{}{notify.HookPayload(msg)})
+}
+
+// sampleDetails are the details shown in samples of events that have one
+var sampleDetails = map[string]string{
+	notify.EventSubmissionSpamFlagged: "Held as spam: " + models.SpamReasonBlocklist,
+	notify.EventEmailFailed:           "Email to the form's recipient failed: connection refused",
+}
+
+// sampleData makes up submitted data with the named fields, or typical
+// contact form fields when there aren't any
+func sampleData(names []string) json.RawMessage {
+	values := map[string]string{}
+	for _, name := range names {
+		if name != models.HoneypotField {
+			values[name] = "Example " + name
+		}
+	}
+	if len(values) == 0 {
+		values = map[string]string{
+			"name":    "Ada Lovelace",
+			"email":   "ada@example.com",
+			"message": "Hello from the contact form",
+		}
+	}
+	data, _ := json.Marshal(values)
+	return data
+}
+
+// formFromURL returns the form in the URL if the user owns it, writing an
+// error response if not
+func (h *HooksHandler) formFromURL(w http.ResponseWriter, r *http.Request) (*models.Form, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return nil, false
+	}
+	formID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
+		return nil, false
+	}
+	return h.ownedForm(w, r, user, formID)
+}
+
+// ownedForm returns a form if the user owns it, writing an error response
+// if not. Other users' forms aren't found, so IDs can't be probed.
+func (h *HooksHandler) ownedForm(w http.ResponseWriter, r *http.Request, user *models.User, formID int64) (*models.Form, bool) {
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
+		return nil, false
+	}
+	if form == nil {
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return nil, false
+	}
+	return form, true
+}
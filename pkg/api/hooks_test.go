@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+)
+
+func TestHooks(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "zapier@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "hooks-key")
+	otherForm, _ := models.CreateForm(db.Connection, other.ID, "Other", "example.com", "secret", "to@example.com", "other-key")
+	key, prefix, hash, _ := auth.GenerateAPIKey()
+	models.CreateAPIKey(db.Connection, user.ID, "Zapier", prefix, hash)
+
+	notifier := notify.NewDispatcher(db, nil, func() string { return "https://forms.example.com" }, 10, 0, 0)
+	defer notifier.Shutdown()
+	h := NewHooksHandler(db, notifier)
+	r := chi.NewRouter()
+	r.Route("/api/v1/hooks", func(r chi.Router) {
+		r.Use(middleware.APIKeyAuth(db))
+		r.Get("/me", h.Me)
+		r.Get("/forms", h.Forms)
+		r.Get("/forms/{id}/submissions", h.Submissions)
+		r.Get("/forms/{id}/sample", h.Sample)
+		r.Post("/subscribe", h.Subscribe)
+		r.Post("/unsubscribe", h.UnsubscribeByBody)
+		r.Delete("/{hookID}", h.Unsubscribe)
+	})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("GET", "/api/v1/hooks/me", ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "zapier@example.com") {
+		t.Errorf("Expected the key's user, got %d %s", rr.Code, rr.Body.String())
+	}
+	var forms []map[string]interface{}
+	json.Unmarshal(serve("GET", "/api/v1/hooks/forms", "").Body.Bytes(), &forms)
+	if len(forms) != 1 || forms[0]["name"] != "Contact" {
+		t.Errorf("Expected only the user's form, got %v", forms)
+	}
+
+	t.Run("sample", func(t *testing.T) {
+		var sample []map[string]interface{}
+		json.Unmarshal(serve("GET", "/api/v1/hooks/forms/"+itoa(form.ID)+"/sample", "").Body.Bytes(), &sample)
+		if len(sample) != 1 || sample[0]["email"] != "ada@example.com" || sample[0]["form_name"] != "Contact" {
+			t.Errorf("Expected a made up sample, got %v", sample)
+		}
+	})
+
+	t.Run("polling", func(t *testing.T) {
+		models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"email":"first@example.com"}`))
+		second, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"email":"second@example.com"}`))
+		spam, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"email":"spam@example.com"}`))
+		models.SetSubmissionSpam(db.Connection, spam.ID, true)
+
+		var list []map[string]interface{}
+		json.Unmarshal(serve("GET", "/api/v1/hooks/forms/"+itoa(form.ID)+"/submissions?limit=5", "").Body.Bytes(), &list)
+		if len(list) != 2 || list[0]["id"] != float64(second.ID) || list[0]["email"] != "second@example.com" {
+			t.Errorf("Expected the submissions that aren't spam newest first, got %v", list)
+		}
+		var sample []map[string]interface{}
+		json.Unmarshal(serve("GET", "/api/v1/hooks/forms/"+itoa(form.ID)+"/sample", "").Body.Bytes(), &sample)
+		if len(sample) != 1 || sample[0]["id"] != float64(second.ID) {
+			t.Errorf("Expected the newest submission as the sample, got %v", sample)
+		}
+		json.Unmarshal(serve("GET", "/api/v1/hooks/forms/"+itoa(form.ID)+"/sample?event=form.updated", "").Body.Bytes(), &sample)
+		if len(sample) != 1 || sample[0]["event"] != "form.updated" || sample[0]["form_id"] != float64(form.ID) {
+			t.Errorf("Expected a form event as the sample, got %v", sample)
+		}
+		if rr := serve("GET", "/api/v1/hooks/forms/"+itoa(otherForm.ID)+"/submissions", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected another user's form not to be found, got %d", rr.Code)
+		}
+	})
+
+	t.Run("subscribe", func(t *testing.T) {
+		rr := serve("POST", "/api/v1/hooks/subscribe", `{"target_url":"https://hooks.zapier.com/1","form_id":"`+itoa(form.ID)+`","event":"submission.created"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected the hook to be subscribed, got %d %s", rr.Code, rr.Body.String())
+		}
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &created)
+		channel, _ := models.GetNotificationChannelByID(db.Connection, created.ID)
+		if channel == nil || channel.Type != notify.RESTHookKind || channel.Config["url"] != "https://hooks.zapier.com/1" {
+			t.Fatalf("Expected a REST hook channel, got %+v", channel)
+		}
+
+		if rr := serve("POST", "/api/v1/hooks/subscribe", `{"url":"https://hook.make.com/2","form_id":`+itoa(otherForm.ID)+`}`); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected subscribing to another user's form to fail, got %d", rr.Code)
+		}
+		if rr := serve("POST", "/api/v1/hooks/subscribe", `{"url":"not a url","form_id":`+itoa(form.ID)+`}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected an invalid hook URL to be rejected, got %d", rr.Code)
+		}
+		if rr := serve("POST", "/api/v1/hooks/subscribe", `{"url":"https://hook.make.com/3","form_id":`+itoa(form.ID)+`,"event":"form.deleted"}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected an unknown event to be rejected, got %d", rr.Code)
+		}
+
+		rr = serve("POST", "/api/v1/hooks/subscribe", `{"url":"https://hook.make.com/3","form_id":`+itoa(form.ID)+`,"event":"email.failed"}`)
+		var failures struct {
+			ID int64 `json:"id"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &failures)
+		channel, _ = models.GetNotificationChannelByID(db.Connection, failures.ID)
+		if channel == nil || !channel.Subscribes(notify.EventEmailFailed) || channel.Subscribes(notify.EventSubmissionCreated) {
+			t.Fatalf("Expected a hook for email failures only, got %+v", channel)
+		}
+		serve("DELETE", "/api/v1/hooks/"+itoa(failures.ID), "")
+
+		if rr := serve("DELETE", "/api/v1/hooks/"+itoa(created.ID), ""); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected the hook to be unsubscribed, got %d", rr.Code)
+		}
+		if rr := serve("DELETE", "/api/v1/hooks/"+itoa(created.ID), ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected the hook to be gone, got %d", rr.Code)
+		}
+
+		serve("POST", "/api/v1/hooks/subscribe", `{"url":"https://hook.make.com/2","form_id":`+itoa(form.ID)+`}`)
+		if rr := serve("POST", "/api/v1/hooks/unsubscribe", `{"url":"https://hook.make.com/2"}`); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected the hook to be unsubscribed by its URL, got %d", rr.Code)
+		}
+		if channels, _ := models.GetNotificationChannelsByFormID(db.Connection, form.ID, false); len(channels) != 0 {
+			t.Errorf("Expected no hooks left, got %+v", channels)
+		}
+	})
+}
+
+// itoa formats an ID for a URL or JSON body
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
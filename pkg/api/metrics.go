@@ -0,0 +1,65 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"staticsend/pkg/email"
+	"staticsend/pkg/httperr"
+)
+
+// MetricsHandler serves the email queue's metrics at /metrics, in the
+// Prometheus text format, to scrapers holding the token
+type MetricsHandler struct {
+	EmailService *email.EmailService
+	// Token is sent by scrapers as a bearer token. Metrics aren't served
+	// when it's empty.
+	Token string
+}
+
+// NewMetricsHandler creates a metrics handler
+func NewMetricsHandler(emailService *email.EmailService, token string) *MetricsHandler {
+	return &MetricsHandler{
+		EmailService: emailService,
+		Token:        token,
+	}
+}
+
+// Metrics writes the metrics
+func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if h.Token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+
+	stats := h.EmailService.Stats()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	metric(w, "staticsend_email_queue_depth", "gauge", "Emails waiting in the queue", stats.Depth)
+	metric(w, "staticsend_email_priority_queue_depth", "gauge", "High priority emails, such as ones to account holders, waiting in the queue", stats.PriorityDepth)
+	metric(w, "staticsend_email_queue_capacity", "gauge", "Emails the queue holds before refusing more", stats.Capacity)
+	metric(w, "staticsend_email_pending", "gauge", "Queued emails not yet sent or given up on, including retries", stats.Pending)
+	metric(w, "staticsend_email_oldest_pending_seconds", "gauge", "How long the oldest pending email has waited", stats.OldestAge.Seconds())
+	metric(w, "staticsend_email_sent_total", "counter", "Emails sent from the queue", stats.Sent)
+	metric(w, "staticsend_email_failed_total", "counter", "Emails given up on after every retry", stats.Failed)
+	metric(w, "staticsend_email_retries_total", "counter", "Failed attempts that were retried", stats.Retried)
+	metric(w, "staticsend_email_failure_ratio", "gauge", "Share of recently finished emails that failed", stats.FailureRate)
+	fallback := 0
+	if h.EmailService.UsingFallback() {
+		fallback = 1
+	}
+	metric(w, "staticsend_email_using_fallback", "gauge", "1 while emails go through the fallback SMTP server", fallback)
+}
+
+// metric writes a metric with its help and type
+func metric(w http.ResponseWriter, name, kind, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
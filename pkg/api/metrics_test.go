@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/email"
+)
+
+func TestMetricsHandler_Metrics(t *testing.T) {
+	service := email.NewEmailService(email.EmailConfig{Host: "127.0.0.1", Port: 1}, 10, 0, 0)
+	defer service.Shutdown()
+	service.SendAsync([]string{"admin@example.com"}, "Test", "Body")
+	h := NewMetricsHandler(service, "scrape-secret")
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		h.Metrics(rr, req)
+		return rr
+	}
+
+	if rr := serve(""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a missing token to be refused, got %d", rr.Code)
+	}
+	if rr := serve("Bearer wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be refused, got %d", rr.Code)
+	}
+
+	rr := serve("Bearer scrape-secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the metrics served, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"# TYPE staticsend_email_queue_depth gauge\nstaticsend_email_queue_depth 1\n",
+		"staticsend_email_queue_capacity 10\n",
+		"staticsend_email_oldest_pending_seconds ",
+		"# TYPE staticsend_email_failed_total counter\nstaticsend_email_failed_total 0\n",
+		"staticsend_email_using_fallback 0\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the metrics, got:\n%s", want, body)
+		}
+	}
+
+	h.Token = ""
+	if rr := serve("Bearer "); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected metrics not served without a token configured, got %d", rr.Code)
+	}
+}
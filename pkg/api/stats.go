@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+)
+
+// maxStatsDays is the longest range GetStats returns daily counts for
+const maxStatsDays = 90
+
+// statsResponse is the body returned by GetStats
+type statsResponse struct {
+	Days   int                 `json:"days"`
+	FormID int64               `json:"form_id,omitempty"`
+	Daily  []models.DailyCount `json:"daily"`
+}
+
+// GetStats returns daily submission and blocked attempt counts for the
+// user's forms, or for one form with ?form_id=. ?days= sets the range,
+// 30 days by default.
+func (h *FormHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return
+	}
+
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > maxStatsDays {
+			httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid range")
+			return
+		}
+	}
+
+	var formID int64
+	if value := r.URL.Query().Get("form_id"); value != "" {
+		var err error
+		formID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
+			return
+		}
+		form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
+		if err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
+			return
+		}
+		if form == nil {
+			httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+			return
+		}
+	}
+
+	daily, err := models.GetDailyCountsContext(r.Context(), h.DB.Connection, user.ID, formID, days, time.Now())
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statsResponse{
+		Days:   days,
+		FormID: formID,
+		Daily:  daily,
+	})
+}
@@ -2,138 +2,824 @@ package api
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"mime"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"strings"
 	"time"
 
+	"staticsend/pkg/akismet"
+	"staticsend/pkg/clientip"
+	"staticsend/pkg/database"
 	"staticsend/pkg/email"
+	"staticsend/pkg/events"
+	"staticsend/pkg/geoip"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/ipfilter"
 	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+	"staticsend/pkg/plugins"
+	"staticsend/pkg/spamscore"
 	"staticsend/pkg/turnstile"
+	"staticsend/pkg/unsubscribe"
+	"staticsend/pkg/uploads"
+)
+
+// maxReferrerLength caps the referring page stored with a submission
+const maxReferrerLength = 2048
+
+const (
+	// defaultMaxUploadSize caps a submission with files unless the
+	// handler's MaxUploadSize says otherwise
+	defaultMaxUploadSize = 10 << 20
+	// multipartMemory is how much of a submission with files is held in
+	// memory; the rest is written to temporary files
+	multipartMemory = 1 << 20
+	// akismetTimeout caps how long a submission waits for Akismet
+	akismetTimeout = 5 * time.Second
+	// maxSubjectLength caps an email subject set by a submission, in characters
+	maxSubjectLength = 200
+	// maxCC caps the addresses a submission can copy its email to
+	maxCC = 5
 )
 
 // SubmissionHandler handles form submission requests
 type SubmissionHandler struct {
-	DB          *sql.DB
+	DB          *database.Database
 	EmailService *email.EmailService
+	// Notifier tells the form's notification channels about submissions
+	// held as spam and emails that failed, when set
+	Notifier *notify.Dispatcher
+	// Events is told about new submissions and their emails being sent,
+	// when set, for the notification channels, Google Sheets and anything
+	// else following them
+	Events *events.Bus
+	// Uploads stores files uploaded with submissions, when set. Without it
+	// file fields are ignored.
+	Uploads *uploads.Uploader
+	// MaxUploadSize caps the size in bytes of a submission sent with files
+	MaxUploadSize int64
+	// FileLinkTTL is how long the links to uploaded files in notification
+	// emails work; 0 leaves them out
+	FileLinkTTL time.Duration
+	// BaseURL returns the address links in emails start with
+	BaseURL func() string
+	// Unsubscribe gives the forward address a link in each submission email
+	// to mute the form's notifications, when set
+	Unsubscribe *unsubscribe.Links
+	// Akismet checks the submissions of forms that have it turned on, when set
+	Akismet    *akismet.Client
+	// Turnstile verifies submissions' tokens, turnstile.DefaultClient when
+	// not set
+	Turnstile  *turnstile.Client
+	// GeoIP finds the country submissions come from, when set, for forms'
+	// country lists
+	GeoIP      *geoip.DB
+	// Plugins run the submission hooks that may change or reject
+	// submissions before they're stored, when set
+	Plugins *plugins.Runner
+	// SpecialFields names the fields that set a submission's email subject,
+	// reply-to address and copies, where the sender is redirected to, and
+	// the honeypot
+	SpecialFields models.SpecialFields
+	statements *models.SubmitStatements
 }
 
 // NewSubmissionHandler creates a new submission handler
-func NewSubmissionHandler(db *sql.DB, emailService *email.EmailService) *SubmissionHandler {
+func NewSubmissionHandler(db *database.Database, emailService *email.EmailService) *SubmissionHandler {
 	return &SubmissionHandler{
 		DB:          db,
 		EmailService: emailService,
+		MaxUploadSize: defaultMaxUploadSize,
+		SpecialFields: models.DefaultSpecialFields,
+	}
+}
+
+// PrepareStatements prepares the queries run on every submission so they
+// aren't re-prepared per request. Without it the handler uses plain queries.
+func (h *SubmissionHandler) PrepareStatements(ctx context.Context) error {
+	statements, err := models.PrepareSubmitStatements(ctx, h.DB.Connection)
+	if err != nil {
+		return err
 	}
+	h.statements = statements
+	return nil
+}
+
+// Close releases the prepared statements
+func (h *SubmissionHandler) Close() error {
+	return h.statements.Close()
 }
 
 // SubmitForm handles form submissions
 func (h *SubmissionHandler) SubmitForm(w http.ResponseWriter, r *http.Request) {
+	// Forms are on other sites, whose scripts need to read the response.
+	// Submissions carry no cookies, so any site may.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
 	// Get form key from URL path
 	formKey := strings.TrimPrefix(r.URL.Path, "/api/v1/submit/")
 	if formKey == "" {
-		http.Error(w, "Form key is required", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Form key is required")
 		return
 	}
 
-	// Parse form data
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+	// Parse form data. Submissions with files are capped at the upload size.
+	if status, message := h.parseForm(w, r); status != 0 {
+		httperr.Write(w, r, status, httperr.StatusCode(status), message)
 		return
 	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
 
 	// Get Turnstile token
 	turnstileToken := r.FormValue("cf-turnstile-response")
 	if turnstileToken == "" {
-		http.Error(w, "Turnstile verification required", http.StatusBadRequest)
+		httperr.Write(w, r, http.StatusBadRequest, httperr.TurnstileFailed, "Turnstile verification required")
 		return
 	}
 
 	// Get form from database
-	form, err := models.GetFormByKey(h.DB, formKey)
+	form, err := h.getFormByKey(r.Context(), formKey)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Internal server error")
 		return
 	}
 	if form == nil {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return
+	}
+
+	remoteIP := clientip.FromRequest(r)
+
+	// Enforce IP allow/deny rules before spending a Turnstile verification
+	if blocked, err := h.checkIPRules(r.Context(), form, remoteIP); err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Internal server error")
+		return
+	} else if blocked {
+		httperr.Write(w, r, http.StatusForbidden, httperr.Blocked, "Submissions from your address are not accepted")
+		return
+	}
+
+	// Enforce the form's country lists. Without a GeoIP database every
+	// country is unknown, and accepted.
+	country := h.GeoIP.Country(remoteIP)
+	if !form.AcceptsCountry(country) {
+		if err := models.CreateBlockedAttemptContext(r.Context(), h.DB.Connection, &form.ID, nil, remoteIP, "country "+country); err != nil {
+			log.Printf("Failed to record blocked attempt: %v", err)
+		}
+		httperr.Write(w, r, http.StatusForbidden, httperr.Blocked, "Submissions from your country are not accepted")
+		return
+	}
+
+	// Enforce the form owner's usage limits
+	if status, message, err := h.checkQuota(r.Context(), form, time.Now()); err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Internal server error")
+		return
+	} else if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(untilNextMonth(time.Now()).Seconds())))
+		}
+		httperr.Write(w, r, status, httperr.QuotaExceeded, message)
 		return
 	}
 
 	// Validate Turnstile token
-	validator := turnstile.NewValidator(form.TurnstileSecret)
-	remoteIP := getClientIP(r)
-	
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	client := h.Turnstile
+	if client == nil {
+		client = turnstile.DefaultClient
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), client.Timeout())
 	defer cancel()
-	
-	verification, err := validator.Verify(ctx, turnstileToken, remoteIP)
+
+	// While Cloudflare can't be reached the form's fallback decides whether
+	// the submission is rejected, held as spam or trusted to the honeypot
+	turnstileDown := false
+	verification, err := client.Verify(ctx, form.TurnstileSecret, turnstileToken, remoteIP)
+	if err != nil {
+		switch form.TurnstileFallback {
+		case models.TurnstileFallbackSpam, models.TurnstileFallbackHoneypot:
+			log.Printf("Turnstile unavailable, accepting submission to form %d with the %s fallback: %v", form.ID, form.TurnstileFallback, err)
+			turnstileDown = true
+		default:
+			if errors.Is(err, turnstile.ErrUnavailable) {
+				httperr.Write(w, r, http.StatusServiceUnavailable, httperr.TurnstileFailed, "Turnstile verification is unavailable")
+				return
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				httperr.Write(w, r, http.StatusGatewayTimeout, httperr.TurnstileFailed, "Turnstile verification timed out")
+				return
+			}
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.TurnstileFailed, "Turnstile verification failed")
+			return
+		}
+	} else if !verification.IsValid() {
+		// Tokens are single use, so a repeat is usually a replayed submission
+		if verification.IsDuplicate() {
+			httperr.Write(w, r, http.StatusBadRequest, httperr.TurnstileFailed, "Turnstile token has expired or was already used")
+			return
+		}
+		httperr.Write(w, r, http.StatusBadRequest, httperr.TurnstileFailed, "Invalid Turnstile token")
+		return
+	}
+
+	// Bots fill in the hidden honeypot field. Tell them the submission
+	// succeeded so they move on, but hold it as spam rather than forward it.
+	// It's only held once it's passed the checks above, so the honeypot
+	// can't be used to get around them.
+	if r.FormValue(h.SpecialFields.Honeypot) != "" {
+		submission, err := h.holdHoneypotSubmission(r, form, remoteIP, country)
+		if err != nil {
+			log.Printf("Failed to hold honeypot submission: %v", err)
+		} else {
+			h.notifySpam(form, submission)
+		}
+		h.submitted(w, r, form, 0)
+		return
+	}
+
+	formData := h.submittedValues(r)
+
+	// The submission is scored from its content, the Turnstile challenge and
+	// the sender's history. The form's thresholds decide whether it's
+	// rejected or held as spam without telling the sender, as are
+	// submissions containing a blocklisted term, that Akismet calls spam or
+	// that couldn't be checked by Turnstile. It's scored before its files
+	// are stored, so a rejected submission leaves nothing behind.
+	signals, err := h.spamSignals(r, form, formData, remoteIP, verification)
 	if err != nil {
-		http.Error(w, "Turnstile verification failed", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Internal server error")
 		return
 	}
-	
-	if !verification.IsValid() {
-		http.Error(w, "Invalid Turnstile token", http.StatusBadRequest)
+	score := signals.Score()
+	action := spamscore.Decide(score, form.SpamFlagThreshold, form.SpamRejectThreshold)
+	if action == spamscore.Reject {
+		if err := models.CreateBlockedAttemptContext(r.Context(), h.DB.Connection, &form.ID, nil, remoteIP, fmt.Sprintf("spam score %d", score)); err != nil {
+			log.Printf("Failed to record blocked attempt: %v", err)
+		}
+		httperr.Write(w, r, http.StatusForbidden, httperr.SpamRejected, "Submission rejected as spam")
 		return
 	}
+	reason := ""
+	switch {
+	case signals.BlocklistTerm != "":
+		reason = models.SpamReasonBlocklist
+	case signals.Akismet != nil && *signals.Akismet >= akismet.ScoreSpam:
+		reason = models.SpamReasonAkismet
+	case action == spamscore.Flag:
+		reason = models.SpamReasonScore
+	case turnstileDown && form.TurnstileFallback == models.TurnstileFallbackSpam:
+		reason = models.SpamReasonTurnstileUnavailable
+	}
 
-	// Extract form data (excluding Turnstile token)
-	formData := make(map[string]string)
-	for key, values := range r.Form {
-		if key != "cf-turnstile-response" && len(values) > 0 {
-			formData[key] = values[0]
+	// Hooks see the submission once it's passed the spam checks, and before
+	// anything is stored, so one turning it away leaves nothing behind
+	if h.Plugins != nil {
+		hooked := plugins.Submission{
+			FormID:    form.ID,
+			FormName:  form.Name,
+			IPAddress: remoteIP,
+			UserAgent: r.UserAgent(),
+			Referrer:  submittedReferrer(r),
+			Country:   country,
+			Fields:    formData,
+		}
+		if err := h.Plugins.Run(r.Context(), &hooked); err != nil {
+			httperr.Write(w, r, http.StatusUnprocessableEntity, httperr.ValidationError, err.Error())
+			return
+		}
+		formData = hooked.Fields
+	}
+
+	// Files are stored before the submission, so a failed upload saves
+	// nothing, and the submission's fields hold their filenames
+	files, err := h.storeFiles(r)
+	if err != nil {
+		log.Printf("Failed to store files uploaded to form %d: %v", form.ID, err)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save uploaded files")
+		return
+	}
+	for _, file := range files {
+		if formData[file.Field] != "" {
+			formData[file.Field] += ", "
 		}
+		formData[file.Field] += file.Filename
 	}
 
 	// Convert form data to JSON for storage
 	formDataJSON, err := json.Marshal(formData)
 	if err != nil {
-		http.Error(w, "Failed to process form data", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to process form data")
+		return
+	}
+
+	if reason != "" {
+		submission, err := models.CreateSpamSubmissionContext(r.Context(), h.DB.Connection, form.ID, remoteIP, r.UserAgent(), submittedReferrer(r), formDataJSON, reason)
+		if err != nil {
+			httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save submission")
+			return
+		}
+		h.recordScore(r.Context(), submission, score, signals)
+		h.recordCountry(r.Context(), submission, country)
+		h.recordFiles(r.Context(), submission.ID, files)
+		h.notifySpam(form, submission)
+		h.submitted(w, r, form, submission.ID)
 		return
 	}
 
 	// Create submission record
-	userAgent := r.UserAgent()
-	submission, err := models.CreateSubmission(h.DB, form.ID, remoteIP, userAgent, formDataJSON)
+	submission, err := h.createSubmission(r.Context(), form.ID, remoteIP, r.UserAgent(), submittedReferrer(r), formDataJSON)
 	if err != nil {
-		http.Error(w, "Failed to save submission", http.StatusInternalServerError)
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to save submission")
 		return
 	}
+	h.recordScore(r.Context(), submission, score, signals)
+	h.recordCountry(r.Context(), submission, country)
+	h.recordFiles(r.Context(), submission.ID, files)
+	h.recordFields(r.Context(), submission)
 
-	// Send email notification asynchronously
+	// Send email notification asynchronously. The status updates don't use
+	// the request context, which is cancelled once the response is sent.
+	// The submission is marked processed once queued, and failed if the
+	// email can't be queued or sent.
 	go func() {
-		if err := h.EmailService.SendFormSubmissionAsync([]string{form.ForwardEmail}, formData); err != nil {
+		h.updateSubmissionStatus(context.Background(), submission.ID, "processed")
+		to := h.recipient(form, formData)
+		if h.muted(form, to) {
+			return
+		}
+		job := email.FormSubmissionJob([]string{to}, formData)
+		job.Thread = email.SubmissionThread(form.ID, form.ThreadBySender, formData)
+		h.addressEmail(&job, form, formData)
+		h.addFileLinks(&job, submission.ID)
+		attachSubmission(&job, form, submission, formData)
+		h.addUnsubscribe(&job, form, to)
+		job.OnFailure = func(err error) { h.emailFailed(form, submission, err) }
+		if h.Events != nil {
+			job.OnSent = func() {
+				h.Events.Publish(events.Event{Name: events.EmailSent, Form: form, Submission: submission})
+			}
+		}
+		if err := h.EmailService.Enqueue(job); err != nil {
 			// Log error but don't fail the request
 			fmt.Printf("Failed to queue email: %v\n", err)
-			// Update submission status to failed
-			models.UpdateSubmissionStatus(h.DB, submission.ID, "failed")
-		} else {
-			// Update submission status to processed
-			models.UpdateSubmissionStatus(h.DB, submission.ID, "processed")
+			h.emailFailed(form, submission, err)
 		}
 	}()
+	if h.Events != nil {
+		h.Events.Publish(events.Event{Name: events.SubmissionCreated, Form: form, Submission: submission})
+	}
 
-	// Return success response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	h.submitted(w, r, form, submission.ID)
+}
+
+// submitted tells the sender their submission was received. Browsers
+// posting the form themselves are redirected to its next page when it has
+// one, and everything else gets JSON. submissionID is 0 for submissions
+// that aren't acknowledged by ID.
+func (h *SubmissionHandler) submitted(w http.ResponseWriter, r *http.Request, form *models.Form, submissionID int64) {
+	next := h.nextURL(r, form)
+	if next != "" && wantsPage(r) {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	response := map[string]interface{}{
 		"success": true,
 		"message": "Form submitted successfully",
-		"submission_id": submission.ID,
-	})
+	}
+	if submissionID != 0 {
+		response["submission_id"] = submissionID
+	}
+	// Scripts follow the redirect themselves, as the form script does
+	if next != "" {
+		response["redirect"] = next
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// nextURL returns the page a submission asks to be redirected to, if it's
+// on the form's domain or one of its subdomains. Other sites are refused so
+// the endpoint can't be used as an open redirect.
+func (h *SubmissionHandler) nextURL(r *http.Request, form *models.Form) string {
+	next := strings.TrimSpace(r.FormValue(h.SpecialFields.Next))
+	if next == "" || form == nil || form.Domain == "" {
+		return ""
+	}
+	u, err := url.Parse(next)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ""
+	}
+	if !spamscore.HostnameMatches(u.Hostname(), form.Domain) {
+		return ""
+	}
+	return u.String()
+}
+
+// wantsPage reports whether a submission was posted by a browser
+// navigating to the endpoint, rather than by a script or HTMX
+func wantsPage(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "" && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// addressEmail sets a submission email's subject, reply-to address and
+// copies from the submission's special fields. Replies go to the sender's
+// own address when the form asks for one under a usual name. Copies are
+// only sent for forms that allow them.
+func (h *SubmissionHandler) addressEmail(job *email.EmailJob, form *models.Form, formData map[string]string) {
+	// Collapsing whitespace also keeps line breaks out of the header
+	if subject := strings.Join(strings.Fields(formData[h.SpecialFields.Subject]), " "); subject != "" {
+		if runes := []rune(subject); len(runes) > maxSubjectLength {
+			subject = string(runes[:maxSubjectLength])
+		}
+		job.Subject = subject
+	}
+	for _, field := range append([]string{h.SpecialFields.ReplyTo}, email.SenderFields...) {
+		if address, err := mail.ParseAddress(formData[field]); err == nil {
+			job.ReplyTo = address.String()
+			break
+		}
+	}
+	if !form.AllowCC {
+		return
+	}
+	if addresses, err := mail.ParseAddressList(formData[h.SpecialFields.CC]); err == nil {
+		for _, address := range addresses {
+			if len(job.Cc) == maxCC {
+				break
+			}
+			job.Cc = append(job.Cc, address.Address)
+		}
+	}
+}
+
+// parseForm parses a submission's fields, and its files when it's sent as
+// multipart/form-data, returning the status and message of a bad request
+func (h *SubmissionHandler) parseForm(w http.ResponseWriter, r *http.Request) (int, string) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := r.ParseForm(); err != nil {
+			return http.StatusBadRequest, "Invalid form data"
+		}
+		return 0, ""
+	}
+
+	if h.MaxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadSize)
+	}
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads are limited to %d MB", h.MaxUploadSize>>20)
+		}
+		return http.StatusBadRequest, "Invalid form data"
+	}
+	return 0, ""
+}
+
+// onHostedPage reports whether Turnstile's hostname is this server's, as it
+// is for submissions from a form's hosted page
+func (h *SubmissionHandler) onHostedPage(form *models.Form, hostname string) bool {
+	if !form.HostedPage || h.BaseURL == nil {
+		return false
+	}
+	base, err := url.Parse(h.BaseURL())
+	if err != nil || base.Hostname() == "" {
+		return false
+	}
+	return spamscore.HostnameMatches(hostname, base.Hostname())
+}
+
+// checkAkismet scores a submission with Akismet when the form has it
+// turned on, returning nil when it isn't checked. Submissions are accepted
+// unscored if Akismet can't be reached.
+func (h *SubmissionHandler) checkAkismet(r *http.Request, form *models.Form, formData map[string]string, remoteIP string) *int {
+	if h.Akismet == nil || !form.AkismetEnabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), akismetTimeout)
+	defer cancel()
+	score, err := h.Akismet.Check(ctx, akismet.NewComment(form.Domain, formData, remoteIP, r.UserAgent(), submittedReferrer(r)))
+	if err != nil {
+		log.Printf("Akismet check for form %d failed: %v", form.ID, err)
+		return nil
+	}
+	return &score
+}
+
+// spamSignals gathers what's known about a submission for its spam score.
+// verification is nil when Turnstile couldn't be reached.
+func (h *SubmissionHandler) spamSignals(r *http.Request, form *models.Form, formData map[string]string, remoteIP string, verification *turnstile.VerificationResponse) (spamscore.Signals, error) {
+	var signals spamscore.Signals
+	ctx := r.Context()
+	db := h.DB.Connection
+
+	blocklist, err := models.GetAppSettingValueContext(ctx, db, models.SettingSpamBlocklist)
+	if err != nil {
+		return signals, err
+	}
+	signals.BlocklistTerm = models.MatchSpamBlocklist(models.ParseSpamBlocklist(blocklist), formData)
+	// Blocklisted submissions are held anyway, so aren't worth an Akismet call
+	if signals.BlocklistTerm == "" {
+		signals.Akismet = h.checkAkismet(r, form, formData, remoteIP)
+	}
+
+	if verification != nil {
+		signals.TurnstileHostname = verification.Hostname
+		signals.TurnstileAction = verification.Action
+		signals.TurnstileCData = verification.CData
+		// Hosted pages are on this server, not the form's domain
+		signals.HostnameMismatch = !spamscore.HostnameMatches(verification.Hostname, form.Domain) &&
+			!h.onHostedPage(form, verification.Hostname)
+	}
+
+	now := time.Now()
+	if signals.RecentSubmissions, err = models.CountRecentSubmissionsByIPContext(ctx, db, form.ID, remoteIP, now.Add(-spamscore.VelocityWindow)); err != nil {
+		return signals, err
+	}
+	if signals.SpamSubmissions, err = models.CountSpamSubmissionsByIPContext(ctx, db, remoteIP, now.Add(-spamscore.ReputationWindow)); err != nil {
+		return signals, err
+	}
+	if signals.BlockedAttempts, err = models.CountBlockedAttemptsByIPContext(ctx, db, remoteIP, now.Add(-spamscore.ReputationWindow)); err != nil {
+		return signals, err
+	}
+	return signals, nil
+}
+
+// recordScore records a submission's spam score and the signals behind it
+func (h *SubmissionHandler) recordScore(ctx context.Context, submission *models.Submission, score int, signals spamscore.Signals) {
+	signalsJSON, err := json.Marshal(signals)
+	if err != nil {
+		log.Printf("Failed to encode spam signals of submission %d: %v", submission.ID, err)
+		return
+	}
+	if err := models.SetSubmissionSpamSignalsContext(ctx, h.DB.Connection, submission.ID, score, signalsJSON); err != nil {
+		log.Printf("Failed to record spam score of submission %d: %v", submission.ID, err)
+		return
+	}
+	submission.SpamScore = &score
+	submission.SpamSignals = signalsJSON
+}
+
+// recordCountry records the country a submission came from, if it's known
+func (h *SubmissionHandler) recordCountry(ctx context.Context, submission *models.Submission, country string) {
+	if country == "" {
+		return
+	}
+	if err := models.SetSubmissionCountryContext(ctx, h.DB.Connection, submission.ID, country); err != nil {
+		log.Printf("Failed to record country of submission %d: %v", submission.ID, err)
+		return
+	}
+	submission.Country = country
+}
+
+// recordFields adds a submission's fields to its form's field catalog
+func (h *SubmissionHandler) recordFields(ctx context.Context, submission *models.Submission) {
+	if err := models.RecordFormFieldsContext(ctx, h.DB.Connection, submission.FormID, submission.SubmittedData, submission.CreatedAt); err != nil {
+		log.Printf("Failed to catalog the fields of submission %d: %v", submission.ID, err)
+	}
+}
+
+// addFileLinks lists signed links to a submission's files in its email,
+// when they're turned on. The email is sent without them if they can't be
+// made.
+func (h *SubmissionHandler) addFileLinks(job *email.EmailJob, submissionID int64) {
+	if h.Uploads == nil || h.FileLinkTTL <= 0 || h.BaseURL == nil {
+		return
+	}
+	links, err := h.Uploads.EmailLinks(context.Background(), submissionID, h.FileLinkTTL, h.BaseURL())
+	if err != nil {
+		log.Printf("Failed to link the files of submission %d in its email: %v", submissionID, err)
+		return
+	}
+	email.AddFileLinks(job, links)
+}
+
+// recipient returns the address a submission is emailed to under its form's
+// routing rules, or the forward address if they can't be read
+func (h *SubmissionHandler) recipient(form *models.Form, formData map[string]string) string {
+	to, err := models.RoutedRecipientContext(context.Background(), h.DB.Connection, form, formData)
+	if err != nil {
+		log.Printf("Failed to route form %d's submission: %v", form.ID, err)
+		return form.ForwardEmail
+	}
+	return to
+}
+
+// muted reports whether a submission's recipient muted the form's
+// notifications with an unsubscribe link. Its submissions are still
+// stored, just not emailed.
+func (h *SubmissionHandler) muted(form *models.Form, to string) bool {
+	unmuted, err := models.UnmutedRecipientsContext(context.Background(), h.DB.Connection, form.ID, []string{to})
+	if err != nil {
+		log.Printf("Failed to check whether form %d's notifications are muted: %v", form.ID, err)
+		return false
+	}
+	return len(unmuted) == 0
+}
+
+// addUnsubscribe gives a submission's recipient its link to mute the form's
+// notifications. Emails copied to other addresses go without, since anyone
+// holding the link could mute the recipient.
+func (h *SubmissionHandler) addUnsubscribe(job *email.EmailJob, form *models.Form, to string) {
+	if h.Unsubscribe == nil || len(job.Cc) > 0 {
+		return
+	}
+	email.AddUnsubscribe(job, h.Unsubscribe.URL(form.ID, to))
+}
+
+// attachSubmission attaches a submission to its email as the file its form
+// asks for, if any, for mail automation to read
+func attachSubmission(job *email.EmailJob, form *models.Form, submission *models.Submission, formData map[string]string) {
+	record := email.SubmissionRecord{ID: submission.ID, FormID: form.ID, CreatedAt: submission.CreatedAt, Fields: formData}
+	if err := email.AttachSubmission(job, form.EmailAttachment, record); err != nil {
+		log.Printf("Failed to attach submission %d to its email: %v", submission.ID, err)
+	}
+}
+
+// storeFiles stores the files uploaded with a submission, if uploads are
+// enabled
+func (h *SubmissionHandler) storeFiles(r *http.Request) ([]uploads.File, error) {
+	if h.Uploads == nil || r.MultipartForm == nil {
+		return nil, nil
+	}
+	return h.Uploads.Put(r.Context(), r.MultipartForm)
+}
+
+// recordFiles records the files stored for a submission. Files that can't
+// be recorded are swept from the store later.
+func (h *SubmissionHandler) recordFiles(ctx context.Context, submissionID int64, files []uploads.File) {
+	if len(files) == 0 {
+		return
+	}
+	if err := h.Uploads.Record(ctx, submissionID, files); err != nil {
+		log.Printf("Failed to record files uploaded with submission %d: %v", submissionID, err)
+	}
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check for X-Forwarded-For header (for proxies)
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		if ips := strings.Split(forwarded, ","); len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+// submittedValues returns the submitted fields, without the Turnstile token,
+// the page to redirect to or an empty honeypot field
+func (h *SubmissionHandler) submittedValues(r *http.Request) map[string]string {
+	formData := make(map[string]string)
+	for key, values := range r.Form {
+		if key == "cf-turnstile-response" || key == h.SpecialFields.Next || len(values) == 0 {
+			continue
+		}
+		if key == h.SpecialFields.Honeypot && values[0] == "" {
+			continue
 		}
+		formData[key] = values[0]
 	}
-	
-	// Fall back to remote address
-	return strings.Split(r.RemoteAddr, ":")[0]
-}
\ No newline at end of file
+	return formData
+}
+
+// submittedReferrer returns the page a submission was sent from, truncated
+// to the length stored
+func submittedReferrer(r *http.Request) string {
+	referrer := r.Referer()
+	if len(referrer) > maxReferrerLength {
+		referrer = referrer[:maxReferrerLength]
+	}
+	return referrer
+}
+
+// emailFailed marks a submission whose email couldn't be queued or sent as
+// failed, and tells the form's channels subscribed to email failures
+func (h *SubmissionHandler) emailFailed(form *models.Form, submission *models.Submission, sendErr error) {
+	h.updateSubmissionStatus(context.Background(), submission.ID, "failed")
+	if h.Notifier == nil {
+		return
+	}
+	if err := h.Notifier.NotifySubmissionEvent(context.Background(), notify.EventEmailFailed, form, submission, "Email to the form's recipient failed: "+sendErr.Error()); err != nil {
+		log.Printf("Failed to queue email failure notifications for submission %d: %v", submission.ID, err)
+	}
+}
+
+// notifySpam tells a form's channels subscribed to spam about a submission
+// held as spam, in the background
+func (h *SubmissionHandler) notifySpam(form *models.Form, submission *models.Submission) {
+	if h.Notifier == nil {
+		return
+	}
+	go func() {
+		if err := h.Notifier.NotifySubmissionEvent(context.Background(), notify.EventSubmissionSpamFlagged, form, submission, "Held as spam: "+submission.SpamReason); err != nil {
+			log.Printf("Failed to queue spam notifications for submission %d: %v", submission.ID, err)
+		}
+	}()
+}
+
+// holdHoneypotSubmission saves a submission that filled in the honeypot as
+// spam, so it can be reviewed
+func (h *SubmissionHandler) holdHoneypotSubmission(r *http.Request, form *models.Form, remoteIP, country string) (*models.Submission, error) {
+	formDataJSON, err := json.Marshal(h.submittedValues(r))
+	if err != nil {
+		return nil, err
+	}
+	submission, err := models.CreateSpamSubmissionContext(r.Context(), h.DB.Connection, form.ID, remoteIP, r.UserAgent(), submittedReferrer(r), formDataJSON, models.SpamReasonHoneypot)
+	if err != nil {
+		return nil, err
+	}
+	h.recordCountry(r.Context(), submission, country)
+	return submission, nil
+}
+
+// getFormByKey looks up a form, using the prepared statement when there is one
+func (h *SubmissionHandler) getFormByKey(ctx context.Context, formKey string) (*models.Form, error) {
+	if h.statements != nil {
+		return h.statements.GetFormByKey(ctx, formKey)
+	}
+	return models.GetFormByKeyContext(ctx, h.DB.Connection, formKey)
+}
+
+// createSubmission saves a submission, using the prepared statements when
+// there are some
+func (h *SubmissionHandler) createSubmission(ctx context.Context, formID int64, ipAddress, userAgent, referrer string, submittedData json.RawMessage) (*models.Submission, error) {
+	if h.statements != nil {
+		return h.statements.CreateSubmission(ctx, formID, ipAddress, userAgent, referrer, submittedData)
+	}
+	return models.CreateReferredSubmissionContext(ctx, h.DB.Connection, formID, ipAddress, userAgent, referrer, submittedData)
+}
+
+// updateSubmissionStatus records a submission's delivery status, using the
+// prepared statement when there is one
+func (h *SubmissionHandler) updateSubmissionStatus(ctx context.Context, id int64, status string) error {
+	if h.statements != nil {
+		return h.statements.UpdateSubmissionStatus(ctx, id, status)
+	}
+	return models.UpdateSubmissionStatusContext(ctx, h.DB.Connection, id, status)
+}
+
+// checkQuota checks the form owner's usage against their quota. It returns
+// the status and message to reject the submission with, or a zero status
+// when the submission is allowed.
+func (h *SubmissionHandler) checkQuota(ctx context.Context, form *models.Form, now time.Time) (int, string, error) {
+	quota, err := models.GetUserQuotaContext(ctx, h.DB.Connection, form.UserID)
+	if err != nil {
+		return 0, "", err
+	}
+	// Skip counting usage for the common case of no limits
+	if quota.MaxMonthlySubmissions <= 0 && quota.MaxStorageBytes <= 0 {
+		return 0, "", nil
+	}
+
+	usage, err := models.GetUsageContext(ctx, h.DB.Connection, form.UserID, now)
+	if err != nil {
+		return 0, "", err
+	}
+	if quota.SubmissionsExceeded(usage) {
+		return http.StatusTooManyRequests, "This form has reached its monthly submission limit", nil
+	}
+	if quota.StorageExceeded(usage) {
+		return http.StatusPaymentRequired, "This form has reached its storage limit", nil
+	}
+	return 0, "", nil
+}
+
+// untilNextMonth returns how long until the monthly submission limit resets
+// at the start of the next calendar month in UTC
+func untilNextMonth(now time.Time) time.Duration {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return next.Sub(now)
+}
+
+// checkIPRules evaluates the global and form-specific IP rules for a
+// submission and records an audit entry when the address is blocked
+func (h *SubmissionHandler) checkIPRules(ctx context.Context, form *models.Form, remoteIP string) (bool, error) {
+	globalRules, err := models.GetGlobalIPRulesContext(ctx, h.DB.Connection)
+	if err != nil {
+		return false, err
+	}
+	formRules, err := models.GetIPRulesByFormIDContext(ctx, h.DB.Connection, form.ID)
+	if err != nil {
+		return false, err
+	}
+
+	// Global rules are evaluated first so an instance-wide deny can't be
+	// overridden by a form allowlist
+	for _, rules := range [][]models.IPRule{globalRules, formRules} {
+		decision := ipfilter.Evaluate(rules, remoteIP)
+		if decision.Allowed {
+			continue
+		}
+
+		var ruleID *int64
+		if decision.Rule != nil {
+			ruleID = &decision.Rule.ID
+		}
+		if err := models.CreateBlockedAttemptContext(ctx, h.DB.Connection, &form.ID, ruleID, remoteIP, decision.Reason); err != nil {
+			log.Printf("Failed to record blocked attempt: %v", err)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,621 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"staticsend/pkg/akismet"
+	"staticsend/pkg/clientip"
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/events"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/models"
+	"staticsend/pkg/plugins"
+	"staticsend/pkg/turnstile"
+)
+
+func TestParseForm_Uploads(t *testing.T) {
+	upload := func(size int) *http.Request {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("name", "Ada")
+		part, _ := writer.CreateFormFile("cv", "cv.pdf")
+		part.Write(bytes.Repeat([]byte("a"), size))
+		writer.Close()
+		r := httptest.NewRequest("POST", "/api/v1/submit/key", &body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		return r
+	}
+	h := &SubmissionHandler{MaxUploadSize: 1 << 20}
+
+	r := upload(1000)
+	if status, message := h.parseForm(httptest.NewRecorder(), r); status != 0 {
+		t.Fatalf("Expected a small upload to parse, got %d %s", status, message)
+	}
+	if r.FormValue("name") != "Ada" || len(r.MultipartForm.File["cv"]) != 1 {
+		t.Error("Expected the submission's fields and file")
+	}
+
+	status, message := h.parseForm(httptest.NewRecorder(), upload(2<<20))
+	if status != http.StatusRequestEntityTooLarge || !strings.Contains(message, "1 MB") {
+		t.Errorf("Expected a large upload to be refused, got %d %s", status, message)
+	}
+
+	r = httptest.NewRequest("POST", "/api/v1/submit/key", strings.NewReader("name=Ada"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if status, _ := h.parseForm(httptest.NewRecorder(), r); status != 0 || r.FormValue("name") != "Ada" || r.MultipartForm != nil {
+		t.Error("Expected a plain form to parse without files")
+	}
+}
+
+// akismetAnswer answers every call to Akismet's API with the same body
+type akismetAnswer string
+
+func (answer akismetAnswer) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(string(answer))),
+		Request:    r,
+	}, nil
+}
+
+func TestCheckAkismet(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/v1/submit/key", nil)
+	fields := map[string]string{"message": "buy now"}
+	form := &models.Form{ID: 1, Domain: "example.com"}
+
+	h := &SubmissionHandler{}
+	if score := h.checkAkismet(r, form, fields, "203.0.113.7"); score != nil {
+		t.Errorf("Expected no score without Akismet, got %d", *score)
+	}
+
+	h.Akismet = akismet.NewClient("key", &http.Client{Transport: akismetAnswer("true")})
+	if score := h.checkAkismet(r, form, fields, "203.0.113.7"); score != nil {
+		t.Errorf("Expected no score for a form without Akismet, got %d", *score)
+	}
+
+	form.AkismetEnabled = true
+	if score := h.checkAkismet(r, form, fields, "203.0.113.7"); score == nil || *score != akismet.ScoreSpam {
+		t.Errorf("Expected a spam score, got %v", score)
+	}
+
+	// Submissions are accepted unscored when Akismet fails
+	h.Akismet = akismet.NewClient("key", &http.Client{Transport: akismetAnswer("invalid")})
+	if score := h.checkAkismet(r, form, fields, "203.0.113.7"); score != nil {
+		t.Errorf("Expected no score when Akismet fails, got %d", *score)
+	}
+}
+
+func TestSubmitForm_TurnstileFallback(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "fallback-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	// Nothing listens here, so Cloudflare can't be reached
+	h.Turnstile = turnstile.NewClient("http://127.0.0.1:1", time.Second)
+	submit := func(token string) (int, *models.Submission) {
+		t.Helper()
+		body := strings.NewReader(url.Values{"message": {"hello"}, "cf-turnstile-response": {token}}.Encode())
+		r := httptest.NewRequest("POST", "/api/v1/submit/fallback-key", body)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		var response struct {
+			SubmissionID int64 `json:"submission_id"`
+		}
+		if rr.Code != http.StatusCreated {
+			return rr.Code, nil
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		submission, _ := models.GetSubmissionByID(db.Connection, response.SubmissionID)
+		return rr.Code, submission
+	}
+
+	if code, _ := submit("token-1"); code != http.StatusInternalServerError {
+		t.Errorf("Expected the default fallback to reject the submission, got %d", code)
+	}
+
+	models.SetFormTurnstileFallback(db.Connection, form.ID, models.TurnstileFallbackSpam)
+	if code, submission := submit("token-2"); code != http.StatusCreated || submission == nil || submission.SpamReason != models.SpamReasonTurnstileUnavailable {
+		t.Errorf("Expected the submission to be held as spam, got %d %+v", code, submission)
+	}
+
+	models.SetFormTurnstileFallback(db.Connection, form.ID, models.TurnstileFallbackHoneypot)
+	if code, submission := submit("token-3"); code != http.StatusCreated || submission == nil || submission.SpamReason != "" {
+		t.Errorf("Expected the submission to be accepted, got %d %+v", code, submission)
+	}
+}
+
+func TestSubmitForm_ErrorCodes(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	if _, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "codes-key"); err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: false, ErrorCodes: []string{"invalid-input-response"}})
+	}))
+	defer server.Close()
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	h.Turnstile = turnstile.NewClient(server.URL, time.Second)
+
+	tests := []struct {
+		key    string
+		values url.Values
+		status int
+		code   httperr.Code
+	}{
+		{"missing-key", url.Values{"cf-turnstile-response": {"token"}}, http.StatusNotFound, httperr.FormNotFound},
+		{"codes-key", url.Values{"message": {"hello"}}, http.StatusBadRequest, httperr.TurnstileFailed},
+		{"codes-key", url.Values{"cf-turnstile-response": {"bad-token"}}, http.StatusBadRequest, httperr.TurnstileFailed},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/api/v1/submit/"+tt.key, strings.NewReader(tt.values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		var body httperr.Envelope
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("%s %v: failed to decode response: %v", tt.key, tt.values, err)
+		}
+		if rr.Code != tt.status || body.Error != tt.code || body.Success {
+			t.Errorf("%s %v: expected %d %s, got %d %+v", tt.key, tt.values, tt.status, tt.code, rr.Code, body)
+		}
+	}
+}
+
+func TestSubmitForm_SpoofedForwardedFor(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "deny-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	if _, err := models.CreateIPRule(db.Connection, &form.ID, "203.0.113.0/24", "deny", "abuse"); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	submit := func(remoteAddr, forwardedFor string) int {
+		body := strings.NewReader(url.Values{"message": {"hello"}, "cf-turnstile-response": {"token"}}.Encode())
+		r := httptest.NewRequest("POST", "/api/v1/submit/deny-key", body)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		return rr.Code
+	}
+
+	// Without a trusted proxy the header is the client's own claim
+	if code := submit("203.0.113.7:51234", "198.51.100.1"); code != http.StatusForbidden {
+		t.Errorf("Expected a spoofed X-Forwarded-For not to get past the deny rule, got %d", code)
+	}
+
+	// Behind a trusted proxy the entry it added is the client's address
+	proxies, _ := clientip.ParseProxies([]string{"10.0.0.0/8"})
+	clientip.SetTrustedProxies(proxies)
+	defer clientip.SetTrustedProxies(nil)
+	if code := submit("10.0.0.2:80", "198.51.100.1, 203.0.113.7"); code != http.StatusForbidden {
+		t.Errorf("Expected a prepended X-Forwarded-For entry not to get past the deny rule, got %d", code)
+	}
+}
+
+func TestSubmitForm_HoneypotAfterChecks(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "honeypot-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	if _, err := models.CreateIPRule(db.Connection, &form.ID, "203.0.113.0/24", "deny", "abuse"); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: true, Hostname: "example.com"})
+	}))
+	defer server.Close()
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	h.Turnstile = turnstile.NewClient(server.URL, time.Second)
+	submit := func(remoteAddr string, values url.Values) int {
+		values.Set(models.HoneypotField, "http://spam.example.net")
+		r := httptest.NewRequest("POST", "/api/v1/submit/honeypot-key", strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		return rr.Code
+	}
+
+	if code := submit("198.51.100.1:51234", url.Values{"message": {"buy now"}}); code != http.StatusBadRequest {
+		t.Errorf("Expected a honeypot hit without a Turnstile token to be refused, got %d", code)
+	}
+	if code := submit("203.0.113.7:51234", url.Values{"message": {"buy now"}, "cf-turnstile-response": {"token-1"}}); code != http.StatusForbidden {
+		t.Errorf("Expected a honeypot hit from a denied address to be refused, got %d", code)
+	}
+	if submissions, _ := models.GetSubmissionsByFormID(db.Connection, form.ID); len(submissions) != 0 {
+		t.Errorf("Expected refused honeypot hits not to be stored, got %d", len(submissions))
+	}
+
+	if code := submit("198.51.100.1:51234", url.Values{"message": {"buy now"}, "cf-turnstile-response": {"token-2"}}); code != http.StatusCreated {
+		t.Errorf("Expected the bot to be told it succeeded, got %d", code)
+	}
+	submissions, _ := models.GetSubmissionsByFormID(db.Connection, form.ID)
+	if len(submissions) != 1 || submissions[0].SpamReason != models.SpamReasonHoneypot {
+		t.Errorf("Expected the honeypot hit to be held as spam, got %+v", submissions)
+	}
+}
+
+func TestSubmitForm_SpamScore(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "score-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	// The challenge's hostname comes from the token, so a token ending in
+	// "-elsewhere" was solved on another site
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		hostname := "www.example.com"
+		if strings.HasSuffix(r.FormValue("response"), "-elsewhere") {
+			hostname = "evil.example.net"
+		}
+		if strings.HasSuffix(r.FormValue("response"), "-hosted") {
+			hostname = "forms.example.net"
+		}
+		json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: true, Hostname: hostname, Action: "contact"})
+	}))
+	defer server.Close()
+
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	h.Turnstile = turnstile.NewClient(server.URL, time.Second)
+	submit := func(token string) (int, *models.Submission) {
+		t.Helper()
+		body := strings.NewReader(url.Values{"message": {"hello"}, "cf-turnstile-response": {token}}.Encode())
+		r := httptest.NewRequest("POST", "/api/v1/submit/score-key", body)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		var response struct {
+			SubmissionID int64 `json:"submission_id"`
+		}
+		if rr.Code != http.StatusCreated {
+			return rr.Code, nil
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		submission, _ := models.GetSubmissionByID(db.Connection, response.SubmissionID)
+		return rr.Code, submission
+	}
+
+	code, submission := submit("token-1")
+	if code != http.StatusCreated || submission == nil || submission.SpamReason != "" {
+		t.Fatalf("Expected the submission to be accepted, got %d %+v", code, submission)
+	}
+	if submission.SpamScore == nil || *submission.SpamScore != 0 || !strings.Contains(string(submission.SpamSignals), `"turnstile_action":"contact"`) {
+		t.Errorf("Expected a score of 0 with the Turnstile action, got %v %s", submission.SpamScore, submission.SpamSignals)
+	}
+
+	models.SetFormSpamThresholds(db.Connection, form.ID, 40, 0)
+	code, submission = submit("token-2-elsewhere")
+	if code != http.StatusCreated || submission == nil || submission.SpamReason != models.SpamReasonScore {
+		t.Fatalf("Expected a challenge solved elsewhere to be held as spam, got %d %+v", code, submission)
+	}
+	if *submission.SpamScore != 40 || !strings.Contains(string(submission.SpamSignals), `"hostname_mismatch":true`) {
+		t.Errorf("Expected a score of 40 for the hostname mismatch, got %d %s", *submission.SpamScore, submission.SpamSignals)
+	}
+
+	// The held submission now counts against the sender too
+	models.SetFormSpamThresholds(db.Connection, form.ID, 40, 50)
+	if code, _ := submit("token-3-elsewhere"); code != http.StatusForbidden {
+		t.Errorf("Expected the submission to be rejected, got %d", code)
+	}
+	attempts, _ := models.GetRecentBlockedAttempts(db.Connection, 10)
+	if len(attempts) != 1 || attempts[0].Reason != "spam score 55" {
+		t.Errorf("Expected the rejection to be recorded, got %+v", attempts)
+	}
+
+	// Challenges on the form's hosted page are solved on this server
+	h.BaseURL = func() string { return "https://forms.example.net" }
+	models.SetFormHostedPage(db.Connection, form.ID, true)
+	models.SetFormSpamThresholds(db.Connection, form.ID, 0, 0)
+	code, submission = submit("token-4-hosted")
+	if code != http.StatusCreated || submission == nil || strings.Contains(string(submission.SpamSignals), `"hostname_mismatch":true`) {
+		t.Errorf("Expected no hostname mismatch from the hosted page, got %d %+v", code, submission)
+	}
+
+	// Only the accepted submissions' fields are cataloged
+	catalog, err := models.GetFieldCatalog(db.Connection, form.ID)
+	if err != nil || catalog.Submissions != 2 || len(catalog.Fields) != 1 || catalog.Fields[0].Name != "message" {
+		t.Errorf("Expected the accepted submissions' fields cataloged, got %+v (%v)", catalog, err)
+	}
+}
+
+func TestSubmitForm_SpecialFields(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "special-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: true, Hostname: "example.com"})
+	}))
+	defer server.Close()
+
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	h.Turnstile = turnstile.NewClient(server.URL, time.Second)
+	h.SpecialFields.Honeypot = "website"
+	tokens := 0
+	submit := func(values url.Values, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		tokens++
+		values.Set("cf-turnstile-response", fmt.Sprintf("token-%d", tokens))
+		r := httptest.NewRequest("POST", "/api/v1/submit/special-key", strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		return rr
+	}
+
+	browser := "text/html,application/xhtml+xml"
+	rr := submit(url.Values{"message": {"hello"}, "_next": {"https://www.example.com/thanks"}}, browser)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "https://www.example.com/thanks" {
+		t.Errorf("Expected a redirect to the thank you page, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	submissions, _ := models.GetSubmissionsByFormID(db.Connection, form.ID)
+	if len(submissions) != 1 || strings.Contains(string(submissions[0].SubmittedData), "_next") {
+		t.Errorf("Expected the submission to be saved without its redirect, got %+v", submissions)
+	}
+
+	// Scripts get JSON naming the page to go to, and other sites aren't
+	// redirected to
+	rr = submit(url.Values{"message": {"hello"}, "_next": {"https://www.example.com/thanks"}}, "*/*")
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), `"redirect":"https://www.example.com/thanks"`) {
+		t.Errorf("Expected JSON with the redirect for scripts, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("Expected scripts on other sites to be allowed to read the response")
+	}
+	for _, next := range []string{"https://evil.example.net/", "//evil.example.net/", "javascript:alert(1)", "https://example.com.evil.net/"} {
+		if rr := submit(url.Values{"message": {"hello"}, "_next": {next}}, browser); rr.Code != http.StatusCreated || strings.Contains(rr.Body.String(), "redirect") {
+			t.Errorf("Expected no redirect to %q, got %d %q", next, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	// The honeypot can be renamed, and the old name is an ordinary field
+	submit(url.Values{"message": {"buy now"}, "website": {"http://spam.example.net"}}, "*/*")
+	submissions, _ = models.GetSubmissionsByFormID(db.Connection, form.ID)
+	caught := 0
+	for _, submission := range submissions {
+		if submission.SpamReason == models.SpamReasonHoneypot {
+			caught++
+		}
+	}
+	if caught != 1 {
+		t.Errorf("Expected the renamed honeypot to catch one submission, got %d", caught)
+	}
+}
+
+func TestSubmitForm_PublishesEvents(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "events-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: true, Hostname: "example.com"})
+	}))
+	defer server.Close()
+
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	h.Turnstile = turnstile.NewClient(server.URL, time.Second)
+	h.Events = events.NewBus()
+	created := make(chan events.Event, 2)
+	h.Events.Subscribe(events.SubmissionCreated, func(ctx context.Context, event events.Event) {
+		created <- event
+	})
+
+	submit := func(values url.Values) {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/api/v1/submit/events-key", strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	submit(url.Values{"message": {"hello"}, "cf-turnstile-response": {"token-1"}})
+	// Spam isn't published
+	submit(url.Values{"message": {"buy now"}, models.HoneypotField: {"http://spam.example.net"}, "cf-turnstile-response": {"token-2"}})
+	h.Events.Wait()
+
+	if len(created) != 1 {
+		t.Fatalf("Expected one submission.created event, got %d", len(created))
+	}
+	event := <-created
+	if event.Form == nil || event.Form.ID != form.ID || event.Submission == nil || event.Submission.SpamAt != nil {
+		t.Errorf("Expected the event to hold the form and its submission, got %+v", event)
+	}
+}
+
+func TestSubmitForm_Plugins(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hashed_password")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "plugins-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(turnstile.VerificationResponse{Success: true, Hostname: "example.com"})
+	}))
+	defer server.Close()
+
+	// The hook is a command, so the test doesn't register a hook for the
+	// whole package
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte(`#!/bin/sh
+if grep -q '"message":"buy now"'; then
+	echo '{"reject": "No sales pitches, please"}'
+else
+	echo '{"fields": {"message": "hello", "source": "hook"}}'
+fi
+`), 0o755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+
+	h := NewSubmissionHandler(db, email.NewEmailService(email.EmailConfig{}, 10, 0, 0))
+	h.Turnstile = turnstile.NewClient(server.URL, time.Second)
+	h.Plugins = plugins.NewRunner([]string{script}, time.Second)
+	submit := func(message string) *httptest.ResponseRecorder {
+		t.Helper()
+		body := strings.NewReader(url.Values{"message": {message}, "cf-turnstile-response": {"token-" + message}}.Encode())
+		r := httptest.NewRequest("POST", "/api/v1/submit/plugins-key", body)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.SubmitForm(rr, r)
+		return rr
+	}
+
+	if rr := submit("hello"); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := submit("buy now")
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "No sales pitches") {
+		t.Errorf("Expected the hook to reject the submission, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	submissions, _ := models.GetSubmissionsByFormID(db.Connection, form.ID)
+	if len(submissions) != 1 {
+		t.Fatalf("Expected only the accepted submission to be stored, got %d", len(submissions))
+	}
+	if !strings.Contains(string(submissions[0].SubmittedData), `"source":"hook"`) {
+		t.Errorf("Expected the hook's fields to be stored, got %s", submissions[0].SubmittedData)
+	}
+}
+
+func TestAddressEmail(t *testing.T) {
+	h := &SubmissionHandler{SpecialFields: models.DefaultSpecialFields}
+	form := &models.Form{AllowCC: true}
+
+	job := email.FormSubmissionJob([]string{"to@example.com"}, nil)
+	h.addressEmail(&job, form, map[string]string{
+		"_subject": "Quote\r\nBcc: victim@example.net",
+		"_replyto": "Jane <jane@example.com>",
+		"email":    "other@example.com",
+		"_cc":      "a@example.com, not an address, b@example.com",
+	})
+	if job.Subject != "Quote Bcc: victim@example.net" {
+		t.Errorf("Expected the subject on one line, got %q", job.Subject)
+	}
+	if job.ReplyTo != `"Jane" <jane@example.com>` {
+		t.Errorf("Expected replies to go to Jane, got %q", job.ReplyTo)
+	}
+	if len(job.Cc) != 0 {
+		t.Errorf("Expected a list with a bad address to be ignored, got %v", job.Cc)
+	}
+
+	job = email.FormSubmissionJob([]string{"to@example.com"}, nil)
+	h.addressEmail(&job, form, map[string]string{
+		"email": "sender@example.com",
+		"_cc":   "1@example.com, 2@example.com, 3@example.com, 4@example.com, 5@example.com, 6@example.com",
+	})
+	if job.Subject != "New Form Submission" || job.ReplyTo != "<sender@example.com>" {
+		t.Errorf("Expected the usual subject and replies to the sender, got %q and %q", job.Subject, job.ReplyTo)
+	}
+	if len(job.Cc) != maxCC || job.Cc[0] != "1@example.com" {
+		t.Errorf("Expected the first %d copies, got %v", maxCC, job.Cc)
+	}
+
+	// Forms send no copies unless their owner allows them
+	job = email.FormSubmissionJob([]string{"to@example.com"}, nil)
+	h.addressEmail(&job, &models.Form{}, map[string]string{
+		"_subject": "Quote",
+		"_cc":      "victim@example.net",
+	})
+	if len(job.Cc) != 0 {
+		t.Errorf("Expected _cc to be ignored, got %v", job.Cc)
+	}
+	if job.Subject != "Quote" {
+		t.Errorf("Expected the subject to still be set, got %q", job.Subject)
+	}
+}
+
+func TestAttachSubmission(t *testing.T) {
+	submission := &models.Submission{ID: 5, CreatedAt: time.Now()}
+	formData := map[string]string{"name": "Ada"}
+
+	job := email.FormSubmissionJob([]string{"to@example.com"}, formData)
+	attachSubmission(&job, &models.Form{ID: 2}, submission, formData)
+	if len(job.Attachments) != 0 {
+		t.Errorf("Expected no attachment unless the form asks, got %+v", job.Attachments)
+	}
+
+	attachSubmission(&job, &models.Form{ID: 2, EmailAttachment: models.EmailAttachmentJSON}, submission, formData)
+	if len(job.Attachments) != 1 || job.Attachments[0].Filename != "submission-5.json" {
+		t.Errorf("Expected the submission as JSON, got %+v", job.Attachments)
+	}
+}
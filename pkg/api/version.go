@@ -0,0 +1,14 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"staticsend/pkg/version"
+)
+
+// Version responds with the running build's version, commit and build date
+func Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"staticsend/pkg/version"
+)
+
+func TestVersion(t *testing.T) {
+	defer func(v, c, d string) { version.Version, version.Commit, version.Date = v, c, d }(version.Version, version.Commit, version.Date)
+	version.Version, version.Commit, version.Date = "v1.2.0", "0123456789abcdef", "2026-01-02T03:04:05Z"
+
+	rr := httptest.NewRecorder()
+	Version(rr, httptest.NewRequest("GET", "/api/v1/version", nil))
+
+	var info version.Info
+	if err := json.NewDecoder(rr.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Version != "v1.2.0" || info.Commit != "0123456789abcdef" || info.Date != "2026-01-02T03:04:05Z" || info.GoVersion == "" {
+		t.Errorf("Unexpected build info %+v", info)
+	}
+}
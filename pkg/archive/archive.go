@@ -0,0 +1,280 @@
+// Package archive moves old submissions out of the live database into
+// compressed JSON Lines files, keeping a manifest in the database so archived
+// submissions can still be exported.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+const (
+	// namePrefix and nameSuffix identify archive files so other files in the
+	// same directory or bucket are never pruned
+	namePrefix = "submissions-"
+	nameSuffix = ".jsonl.gz"
+	// nameTimeFormat sorts lexically in chronological order
+	nameTimeFormat = "20060102-150405"
+	// batchSize caps the submissions held in memory and written to one file
+	batchSize = 5000
+)
+
+// Archiver moves submissions older than a maximum age into archive files.
+// Archive files are kept in a storage.Store, so they can live in a local
+// directory or an S3-compatible bucket.
+type Archiver struct {
+	db     *database.Database
+	store  storage.Store
+	maxAge time.Duration
+	now    func() time.Time
+
+	// mu ensures only one archival run happens at a time
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewArchiver creates an archiver that moves submissions older than maxAge
+// into files in store
+func NewArchiver(db *database.Database, store storage.Store, maxAge time.Duration) *Archiver {
+	return &Archiver{
+		db:     db,
+		store:  store,
+		maxAge: maxAge,
+		now:    time.Now,
+	}
+}
+
+// Store returns where archive files are kept
+func (a *Archiver) Store() storage.Store {
+	return a.store
+}
+
+// Run archives every submission older than the maximum age and returns the
+// manifest entries for the files it wrote
+func (a *Archiver) Run(ctx context.Context) ([]models.SubmissionArchive, error) {
+	if a.maxAge <= 0 {
+		return nil, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Timestamps are stored to the second, so truncate to match
+	cutoff := a.now().UTC().Add(-a.maxAge).Truncate(time.Second)
+	formIDs, err := models.GetFormIDsWithSubmissionsBeforeContext(ctx, a.db.Connection, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find submissions to archive: %w", err)
+	}
+
+	var archives []models.SubmissionArchive
+	for _, formID := range formIDs {
+		for {
+			archive, more, err := a.archiveBatch(ctx, formID, cutoff)
+			if err != nil {
+				return archives, err
+			}
+			if archive != nil {
+				archives = append(archives, *archive)
+			}
+			if !more {
+				break
+			}
+		}
+	}
+
+	if err := a.prune(ctx); err != nil {
+		log.Printf("Failed to prune unreferenced archives: %v", err)
+	}
+
+	return archives, nil
+}
+
+// archiveBatch writes the form's oldest batch of submissions to an archive
+// file and removes them from the database. It reports whether a full batch
+// was archived, in which case there may be more to do.
+func (a *Archiver) archiveBatch(ctx context.Context, formID int64, cutoff time.Time) (*models.SubmissionArchive, bool, error) {
+	submissions, err := models.GetSubmissionsBeforeContext(ctx, a.db.Connection, formID, cutoff, batchSize)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read submissions: %w", err)
+	}
+	if len(submissions) == 0 {
+		return nil, false, nil
+	}
+
+	last := submissions[len(submissions)-1]
+	archive := &models.SubmissionArchive{
+		FormID:            formID,
+		Name:              fmt.Sprintf("%s%d-%s-%d%s", namePrefix, formID, a.now().UTC().Format(nameTimeFormat), last.ID, nameSuffix),
+		SubmissionCount:   len(submissions),
+		FirstSubmissionAt: submissions[0].CreatedAt,
+		LastSubmissionAt:  submissions[0].CreatedAt,
+	}
+	for _, s := range submissions {
+		if s.CreatedAt.Before(archive.FirstSubmissionAt) {
+			archive.FirstSubmissionAt = s.CreatedAt
+		}
+		if s.CreatedAt.After(archive.LastSubmissionAt) {
+			archive.LastSubmissionAt = s.CreatedAt
+		}
+	}
+
+	// Write to a temporary file first so a slow upload never holds a
+	// database connection
+	file, err := os.CreateTemp("", "staticsend-archive-")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := writeJSONL(file, submissions); err != nil {
+		return nil, false, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if archive.Size, err = file.Seek(0, io.SeekCurrent); err != nil {
+		return nil, false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+
+	if err := a.store.Put(ctx, archive.Name, file); err != nil {
+		return nil, false, fmt.Errorf("failed to store archive: %w", err)
+	}
+
+	// Only delete the submissions once the file is safely stored. If this
+	// fails the file is unreferenced and removed by the next prune.
+	archive, err = models.ArchiveSubmissionsContext(ctx, a.db.Connection, archive, cutoff, last.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record archive: %w", err)
+	}
+
+	return archive, len(submissions) == batchSize, nil
+}
+
+// writeJSONL writes gzip-compressed submissions to w, one JSON object per line
+func writeJSONL(w io.Writer, submissions []models.Submission) error {
+	gz := gzip.NewWriter(w)
+	buffered := bufio.NewWriter(gz)
+	encoder := json.NewEncoder(buffered)
+	for _, s := range submissions {
+		if err := encoder.Encode(s); err != nil {
+			return err
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Export writes every archived submission for a form to w as uncompressed
+// JSON Lines, oldest archive first
+func (a *Archiver) Export(ctx context.Context, w io.Writer, formID int64) error {
+	archives, err := models.GetSubmissionArchivesByFormIDContext(ctx, a.db.Connection, formID)
+	if err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		if err := a.copyArchive(ctx, w, archive.Name); err != nil {
+			return fmt.Errorf("failed to read archive %s: %w", archive.Name, err)
+		}
+	}
+	return nil
+}
+
+// copyArchive decompresses one archive file into w
+func (a *Archiver) copyArchive(ctx context.Context, w io.Writer, name string) error {
+	file, err := a.store.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	_, err = io.Copy(w, gz)
+	return err
+}
+
+// prune deletes archive files that aren't in the manifest, left behind when
+// recording an archive failed or its form was deleted
+func (a *Archiver) prune(ctx context.Context) error {
+	files, err := a.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, namePrefix) || !strings.HasSuffix(f.Name, nameSuffix) {
+			continue
+		}
+		exists, err := models.SubmissionArchiveExistsContext(ctx, a.db.Connection, f.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := a.store.Delete(ctx, f.Name); err != nil {
+			return err
+		}
+		log.Printf("Deleted unreferenced archive %s", f.Name)
+	}
+	return nil
+}
+
+// Start archives old submissions every interval until Stop is called
+func (a *Archiver) Start(interval time.Duration) {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-ticker.C:
+				archives, err := a.Run(context.Background())
+				if err != nil {
+					log.Printf("Scheduled archival failed: %v", err)
+				}
+				if len(archives) > 0 {
+					log.Printf("Archived submissions to %d files in %s", len(archives), a.store)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops scheduled archival, waiting for a running archival to finish
+func (a *Archiver) Stop() {
+	if a.stop == nil {
+		return
+	}
+	close(a.stop)
+	<-a.done
+	a.stop = nil
+}
@@ -0,0 +1,159 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+func setupArchiver(t *testing.T) (*Archiver, *database.Database, string) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "staticsend.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	dir := filepath.Join(t.TempDir(), "archives")
+	store, err := storage.NewLocalStore(dir, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	a := NewArchiver(db, store, 30*24*time.Hour)
+	a.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return a, db, dir
+}
+
+// createSubmission inserts a submission with the given creation time
+func createSubmission(t *testing.T, db *database.Database, formID int64, createdAt time.Time, name string) int64 {
+	t.Helper()
+	data, _ := json.Marshal(map[string]string{"name": name})
+	result, err := db.Connection.Exec(
+		"INSERT INTO submissions (form_id, ip_address, user_agent, submitted_data, created_at) VALUES (?, ?, ?, ?, ?)",
+		formID, "127.0.0.1", "test", string(data), createdAt.UTC().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	if _, err := models.CreateSubmissionEmail(db.Connection, id, "sent", ""); err != nil {
+		t.Fatalf("Failed to create submission email: %v", err)
+	}
+	return id
+}
+
+func TestRun_ArchivesOldSubmissions(t *testing.T) {
+	a, db, dir := setupArchiver(t)
+
+	user, err := models.CreateUser(db.Connection, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "archive-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	old := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	createSubmission(t, db, form.ID, old, "Ada")
+	createSubmission(t, db, form.ID, old.Add(time.Hour), "Grace")
+	recentID := createSubmission(t, db, form.ID, time.Date(2026, 2, 25, 9, 0, 0, 0, time.UTC), "Linus")
+
+	archives, err := a.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Archival failed: %v", err)
+	}
+	if len(archives) != 1 {
+		t.Fatalf("Expected 1 archive, got %d", len(archives))
+	}
+	archive := archives[0]
+	if archive.SubmissionCount != 2 || archive.FormID != form.ID {
+		t.Errorf("Unexpected archive %+v", archive)
+	}
+	if !archive.FirstSubmissionAt.Equal(old) || !archive.LastSubmissionAt.Equal(old.Add(time.Hour)) {
+		t.Errorf("Unexpected archive range %s - %s", archive.FirstSubmissionAt, archive.LastSubmissionAt)
+	}
+	if info, err := os.Stat(filepath.Join(dir, archive.Name)); err != nil || info.Size() != archive.Size {
+		t.Errorf("Expected archive file of %d bytes: %v", archive.Size, err)
+	}
+
+	// Only the recent submission is left in the live table
+	remaining, err := models.GetSubmissionsByFormID(db.Connection, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to list submissions: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].ID != recentID {
+		t.Errorf("Expected only the recent submission to remain, got %+v", remaining)
+	}
+	var emails int
+	db.Connection.QueryRow("SELECT COUNT(*) FROM submission_emails").Scan(&emails)
+	if emails != 1 {
+		t.Errorf("Expected archived submissions' email records to be deleted, got %d left", emails)
+	}
+
+	// Archived submissions can still be exported
+	var buf bytes.Buffer
+	if err := a.Export(context.Background(), &buf, form.ID); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	var names []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var s models.Submission
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		var data map[string]string
+		json.Unmarshal(s.SubmittedData, &data)
+		names = append(names, data["name"])
+	}
+	if len(names) != 2 || names[0] != "Ada" || names[1] != "Grace" {
+		t.Errorf("Expected Ada and Grace in the export, got %v", names)
+	}
+
+	// Nothing new to archive on the next run
+	if archives, err := a.Run(context.Background()); err != nil || len(archives) != 0 {
+		t.Errorf("Expected nothing to archive, got %d archives (err %v)", len(archives), err)
+	}
+}
+
+func TestRun_PrunesUnreferencedArchives(t *testing.T) {
+	a, _, dir := setupArchiver(t)
+
+	orphan := filepath.Join(dir, "submissions-99-20260101-000000-1.jsonl.gz")
+	other := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{orphan, other} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	if _, err := a.Run(context.Background()); err != nil {
+		t.Fatalf("Archival failed: %v", err)
+	}
+
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("Expected the unreferenced archive to be deleted")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Error("Expected unrelated files to be kept")
+	}
+}
+
+func TestRun_Disabled(t *testing.T) {
+	a, _, _ := setupArchiver(t)
+	a.maxAge = 0
+
+	if archives, err := a.Run(context.Background()); err != nil || archives != nil {
+		t.Errorf("Expected a disabled archiver to do nothing, got %v (err %v)", archives, err)
+	}
+}
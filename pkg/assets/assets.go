@@ -0,0 +1,168 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// immutableCacheControl is sent for fingerprinted URLs, whose content can never change
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// revalidateCacheControl makes browsers check the ETag before reusing other assets
+	revalidateCacheControl = "public, no-cache"
+	// hashLength is the number of hex characters of the content hash used in ETags and URLs
+	hashLength = 16
+)
+
+// fingerprintPattern matches file names that embed a content hash, e.g. app.3f2a9c1b.js
+var fingerprintPattern = regexp.MustCompile(`\.[0-9a-f]{8,64}\.[A-Za-z0-9]+$`)
+
+// fileHash caches the content hash of a file until it changes on disk
+type fileHash struct {
+	hash    string
+	modTime time.Time
+	size    int64
+}
+
+// Handler serves static files with content-hash ETags, conditional request
+// handling and long-lived caching for fingerprinted URLs
+type Handler struct {
+	files  fs.FS
+	mu     sync.RWMutex
+	hashes map[string]fileHash
+}
+
+// NewHandler creates a static asset handler for the given file system, such
+// as the embedded static files or os.DirFS for a directory on disk
+func NewHandler(files fs.FS) *Handler {
+	return &Handler{
+		files:  files,
+		hashes: make(map[string]fileHash),
+	}
+}
+
+// ServeHTTP serves the requested asset. The request path is relative to the
+// asset root, so mount the handler with http.StripPrefix.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+
+	file, info, err := h.open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	hash, err := h.hashFile(name, content, info)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+hash+`"`)
+	if h.isFingerprinted(name, r.URL.Query().Get("v"), hash) {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", revalidateCacheControl)
+	}
+
+	// ServeContent handles If-None-Match, If-Modified-Since and range requests
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+}
+
+// URL returns the public URL for an asset with its content hash appended, so
+// it can be cached forever and busted automatically when the file changes
+func (h *Handler) URL(name string) string {
+	name = path.Clean("/" + name)
+	url := "/static" + name
+
+	file, info, err := h.open(name)
+	if err != nil {
+		return url
+	}
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		return url
+	}
+
+	hash, err := h.hashFile(name, content, info)
+	if err != nil {
+		return url
+	}
+
+	return url + "?v=" + hash
+}
+
+// open opens a regular file by its slash-separated path below the root
+func (h *Handler) open(name string) (fs.File, fs.FileInfo, error) {
+	file, err := h.files.Open(strings.TrimPrefix(name, "/"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, nil, fs.ErrNotExist
+	}
+	return file, info, nil
+}
+
+// isFingerprinted reports whether the request URL pins the current content
+func (h *Handler) isFingerprinted(name, version, hash string) bool {
+	if version != "" {
+		return version == hash
+	}
+	return fingerprintPattern.MatchString(name)
+}
+
+// hashFile returns the content hash for a file, reusing the cached value
+// while the file's size and modification time are unchanged
+func (h *Handler) hashFile(name string, file io.ReadSeeker, info fs.FileInfo) (string, error) {
+	h.mu.RLock()
+	cached, ok := h.hashes[name]
+	h.mu.RUnlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.hash, nil
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))[:hashLength]
+
+	h.mu.Lock()
+	h.hashes[name] = fileHash{hash: hash, modTime: info.ModTime(), size: info.Size()}
+	h.mu.Unlock()
+
+	return hash, nil
+}
+
+// DefaultURL returns the unversioned URL for an asset, used when no handler is configured
+func DefaultURL(name string) string {
+	return "/static/" + strings.TrimPrefix(name, "/")
+}
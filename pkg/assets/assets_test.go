@@ -0,0 +1,131 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func setupAssets(t *testing.T) (*Handler, string) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "js"), 0755); err != nil {
+		t.Fatalf("Failed to create asset dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "js", "app.js"), []byte("console.log('v1');"), 0644); err != nil {
+		t.Fatalf("Failed to write asset: %v", err)
+	}
+	return NewHandler(os.DirFS(dir)), dir
+}
+
+func serve(h *Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestServeSetsETagAndRevalidates(t *testing.T) {
+	h, _ := setupAssets(t)
+
+	rr := serve(h, "/js/app.js", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+	if got := rr.Header().Get("Cache-Control"); got != revalidateCacheControl {
+		t.Errorf("Expected Cache-Control %q, got %q", revalidateCacheControl, got)
+	}
+
+	rr = serve(h, "/js/app.js", http.Header{"If-None-Match": {etag}})
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for matching ETag, got %d", rr.Code)
+	}
+}
+
+func TestVersionedURLIsImmutable(t *testing.T) {
+	h, _ := setupAssets(t)
+
+	url := h.URL("js/app.js")
+	if !strings.HasPrefix(url, "/static/js/app.js?v=") {
+		t.Fatalf("Unexpected asset URL %q", url)
+	}
+
+	rr := serve(h, strings.TrimPrefix(url, "/static"), nil)
+	if got := rr.Header().Get("Cache-Control"); got != immutableCacheControl {
+		t.Errorf("Expected Cache-Control %q, got %q", immutableCacheControl, got)
+	}
+
+	// A stale version must not be cached forever
+	rr = serve(h, "/js/app.js?v=0000000000000000", nil)
+	if got := rr.Header().Get("Cache-Control"); got != revalidateCacheControl {
+		t.Errorf("Expected Cache-Control %q for stale version, got %q", revalidateCacheControl, got)
+	}
+}
+
+func TestHashChangesWithContent(t *testing.T) {
+	h, dir := setupAssets(t)
+
+	before := h.URL("js/app.js")
+
+	path := filepath.Join(dir, "js", "app.js")
+	if err := os.WriteFile(path, []byte("console.log('version two');"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite asset: %v", err)
+	}
+	// Make sure the modification time moves even on coarse filesystems
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("Failed to touch asset: %v", err)
+	}
+
+	if after := h.URL("js/app.js"); after == before {
+		t.Errorf("Expected asset URL to change after content change, still %q", after)
+	}
+}
+
+func TestServeMissingAndTraversal(t *testing.T) {
+	h, _ := setupAssets(t)
+
+	for _, target := range []string{"/missing.js", "/js", "/../assets.go"} {
+		rr := serve(h, target, nil)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for %s, got %d", target, rr.Code)
+		}
+	}
+
+	if got := h.URL("missing.js"); got != "/static/missing.js" {
+		t.Errorf("Expected unversioned URL for missing asset, got %q", got)
+	}
+}
+
+func TestServeFromEmbeddedFiles(t *testing.T) {
+	// Embedded files have no modification time, so caching relies on the ETag
+	h := NewHandler(fstest.MapFS{"css/site.css": {Data: []byte("body{}")}})
+
+	rr := serve(h, "/css/site.css", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("Last-Modified") != "" {
+		t.Error("Expected no Last-Modified header for embedded files")
+	}
+
+	rr = serve(h, "/css/site.css", http.Header{"If-None-Match": {rr.Header().Get("ETag")}})
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", rr.Code)
+	}
+
+	if rr := serve(h, "/css", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected directories to 404, got %d", rr.Code)
+	}
+}
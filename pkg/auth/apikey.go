@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so keys are easy to spot in
+	// requests and in leaked secret scans
+	APIKeyPrefix = "ss_"
+	// apiKeyShownLength is how much of a key is kept to tell keys apart
+	apiKeyShownLength = len(APIKeyPrefix) + 6
+)
+
+// GenerateAPIKey creates a random API key, returning the key to show the
+// user once, the prefix to list it by and the hash to store
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+	return key, key[:apiKeyShownLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by. Keys
+// are long and random, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetAPIKeyFromRequest extracts an API key from the X-API-Key header, or
+// from an Authorization: Bearer header holding one
+func GetAPIKeyFromRequest(r *http.Request) (string, bool) {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key, true
+	}
+	if token, err := GetTokenFromRequest(r); err == nil && strings.HasPrefix(token, APIKeyPrefix) {
+		return token, true
+	}
+	return "", false
+}
@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -85,6 +89,47 @@ func GenerateSecretKey() ([]byte, error) {
 	return key, nil
 }
 
+// LoadOrCreateSecretKey reads the JWT signing key kept at path, generating
+// and saving a new one the first time, so sessions survive restarts. created
+// reports whether the key is new.
+func LoadOrCreateSecretKey(path string) (key []byte, created bool, err error) {
+	if data, err := os.ReadFile(path); err == nil {
+		key := bytes.TrimSpace(data)
+		if len(key) == 0 {
+			return nil, false, fmt.Errorf("secret key file %s is empty", path)
+		}
+		return key, false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read secret key: %w", err)
+	}
+
+	raw, err := GenerateSecretKey()
+	if err != nil {
+		return nil, false, err
+	}
+	key = []byte(hex.EncodeToString(raw))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, fmt.Errorf("failed to create secret key directory: %w", err)
+	}
+	// O_EXCL stops two instances starting at once from each saving a key
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return LoadOrCreateSecretKey(path)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save secret key: %w", err)
+	}
+	if _, err := file.Write(append(key, '\n')); err != nil {
+		file.Close()
+		return nil, false, fmt.Errorf("failed to save secret key: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, false, fmt.Errorf("failed to save secret key: %w", err)
+	}
+	return key, true, nil
+}
+
 // GetTokenFromRequest extracts JWT token from Authorization header
 func GetTokenFromRequest(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
@@ -100,6 +145,26 @@ func GetTokenFromRequest(r *http.Request) (string, error) {
 	return parts[1], nil
 }
 
+// SessionCookie returns the cookie that holds a session token. It's marked
+// Secure when the request came over HTTPS, as it does when staticSend serves
+// TLS itself.
+func SessionCookie(r *http.Request, token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     "auth_token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+	}
+}
+
+// ExpiredSessionCookie returns a cookie that clears the session cookie
+func ExpiredSessionCookie(r *http.Request) *http.Cookie {
+	cookie := SessionCookie(r, "")
+	cookie.MaxAge = -1
+	return cookie
+}
+
 // GetUserIDFromToken extracts user ID from JWT claims
 func GetUserIDFromToken(claims jwt.MapClaims) (int64, error) {
 	userID, ok := claims["sub"].(float64)
@@ -0,0 +1,53 @@
+package auth
+
+import "staticsend/pkg/models"
+
+// Permission names an action a user may perform, in resource:action form
+type Permission string
+
+const (
+	// PermissionFormsRead allows viewing forms the user owns
+	PermissionFormsRead Permission = "forms:read"
+	// PermissionFormsWrite allows creating, editing and deleting forms the user owns
+	PermissionFormsWrite Permission = "forms:write"
+	// PermissionSubmissionsRead allows viewing submissions to forms the user owns
+	PermissionSubmissionsRead Permission = "submissions:read"
+	// PermissionSubmissionsWrite allows assigning and adding notes to submissions to forms the user owns
+	PermissionSubmissionsWrite Permission = "submissions:write"
+	// PermissionSettingsWrite allows changing application-wide settings
+	PermissionSettingsWrite Permission = "settings:write"
+	// PermissionMaintenanceBypass allows using the site while it's in maintenance mode
+	PermissionMaintenanceBypass Permission = "maintenance:bypass"
+)
+
+// rolePermissions maps each role to the permissions it grants. Ownership of
+// individual forms is still checked by the handlers.
+var rolePermissions = map[string][]Permission{
+	models.RoleAdmin: {
+		PermissionFormsRead,
+		PermissionFormsWrite,
+		PermissionSubmissionsRead,
+		PermissionSubmissionsWrite,
+		PermissionSettingsWrite,
+		PermissionMaintenanceBypass,
+	},
+	models.RoleUser: {
+		PermissionFormsRead,
+		PermissionFormsWrite,
+		PermissionSubmissionsRead,
+		PermissionSubmissionsWrite,
+	},
+}
+
+// HasPermission reports whether the user's role grants a permission
+func HasPermission(user *models.User, permission Permission) bool {
+	if user == nil {
+		return false
+	}
+	for _, p := range rolePermissions[user.Role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+// Package backup takes scheduled snapshots of the SQLite database and keeps
+// them in a storage.Store, a local directory or an S3-compatible bucket.
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/storage"
+)
+
+const (
+	// namePrefix and nameSuffix identify backup files so other files in the
+	// same directory or bucket are never pruned
+	namePrefix = "staticsend-"
+	nameSuffix = ".db.gz"
+	// nameTimeFormat sorts lexically in chronological order
+	nameTimeFormat = "20060102-150405"
+)
+
+// ErrUnsupported is returned when the database engine can't be snapshotted.
+// PostgreSQL and MySQL should be backed up with their own tooling.
+var ErrUnsupported = errors.New("backups are only supported for SQLite databases")
+
+// Backup describes a stored snapshot
+type Backup struct {
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// Manager takes backups and applies the retention policy
+type Manager struct {
+	db        *database.Database
+	store     storage.Store
+	retention int
+	now       func() time.Time
+
+	// mu ensures only one backup runs at a time
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewManager creates a backup manager that keeps the newest retention
+// backups in store. A retention of 0 keeps every backup.
+func NewManager(db *database.Database, store storage.Store, retention int) *Manager {
+	return &Manager{
+		db:        db,
+		store:     store,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Store returns where backups are kept
+func (m *Manager) Store() storage.Store {
+	return m.store
+}
+
+// Run takes a backup now, then prunes old backups
+func (m *Manager) Run(ctx context.Context) (*Backup, error) {
+	if m.db.Dialect != database.SQLite && m.db.Dialect != "" {
+		return nil, ErrUnsupported
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, err := os.MkdirTemp("", "staticsend-backup-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// VACUUM INTO writes a consistent, compacted copy without blocking writers
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if _, err := m.db.Connection.ExecContext(ctx, "VACUUM INTO ?", snapshot); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	file, err := os.Open(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	now := m.now().UTC()
+	backup := &Backup{Name: namePrefix + now.Format(nameTimeFormat) + nameSuffix, CreatedAt: now}
+
+	// Compress while uploading so large databases aren't buffered in memory
+	reader, writer := io.Pipe()
+	counter := &countingReader{r: reader}
+	go func() {
+		gz := gzip.NewWriter(writer)
+		_, err := io.Copy(gz, file)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		writer.CloseWithError(err)
+	}()
+
+	if err := m.store.Put(ctx, backup.Name, counter); err != nil {
+		reader.CloseWithError(err)
+		return nil, fmt.Errorf("failed to store backup: %w", err)
+	}
+	backup.Size = counter.n
+
+	if err := m.prune(ctx); err != nil {
+		log.Printf("Failed to prune old backups: %v", err)
+	}
+
+	return backup, nil
+}
+
+// List returns the stored backups, newest first
+func (m *Manager) List(ctx context.Context) ([]Backup, error) {
+	files, err := m.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, namePrefix) || !strings.HasSuffix(f.Name, nameSuffix) {
+			continue
+		}
+		backup := Backup{Name: f.Name, Size: f.Size, CreatedAt: f.CreatedAt}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(f.Name, namePrefix), nameSuffix)
+		if created, err := time.Parse(nameTimeFormat, stamp); err == nil {
+			backup.CreatedAt = created
+		}
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// prune deletes the backups beyond the retention limit
+func (m *Manager) prune(ctx context.Context) error {
+	if m.retention <= 0 {
+		return nil
+	}
+
+	backups, err := m.List(ctx)
+	if err != nil {
+		return err
+	}
+	for i := m.retention; i < len(backups); i++ {
+		if err := m.store.Delete(ctx, backups[i].Name); err != nil {
+			return err
+		}
+		log.Printf("Deleted old backup %s", backups[i].Name)
+	}
+	return nil
+}
+
+// Start takes a backup every interval until Stop is called. The first backup
+// runs one interval after start, so restarts don't pile up backups.
+func (m *Manager) Start(interval time.Duration) {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				backup, err := m.Run(context.Background())
+				if err != nil {
+					log.Printf("Scheduled backup failed: %v", err)
+					continue
+				}
+				log.Printf("Backed up database to %s (%s, %d bytes)", m.store, backup.Name, backup.Size)
+			}
+		}
+	}()
+}
+
+// Stop stops scheduled backups, waiting for a running backup to finish
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
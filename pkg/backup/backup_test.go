@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"staticsend/pkg/database"
+	"staticsend/pkg/storage"
+)
+
+func setupManager(t *testing.T, retention int) (*Manager, *database.Database, string) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "staticsend.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	store, err := storage.NewLocalStore(dir, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	m := NewManager(db, store, retention)
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.now = func() time.Time {
+		clock = clock.Add(time.Hour)
+		return clock
+	}
+	return m, db, dir
+}
+
+func TestRun_CreatesRestorableSnapshot(t *testing.T) {
+	m, db, dir := setupManager(t, 0)
+
+	if _, err := db.Connection.Exec("INSERT INTO users (email, password_hash) VALUES (?, ?)", "user@example.com", "hash"); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	backup, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if backup.Name != "staticsend-20260102-040405.db.gz" {
+		t.Errorf("Unexpected backup name %q", backup.Name)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, backup.Name))
+	if err != nil {
+		t.Fatalf("Backup file missing: %v", err)
+	}
+	if info.Size() != backup.Size {
+		t.Errorf("Expected size %d, got %d", info.Size(), backup.Size)
+	}
+
+	// Restore the snapshot and check the data is there
+	compressed, err := os.Open(filepath.Join(dir, backup.Name))
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer compressed.Close()
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		t.Fatalf("Backup is not gzip compressed: %v", err)
+	}
+	restoredPath := filepath.Join(t.TempDir(), "restored.db")
+	restoredFile, err := os.Create(restoredPath)
+	if err != nil {
+		t.Fatalf("Failed to create restore file: %v", err)
+	}
+	if _, err := io.Copy(restoredFile, gz); err != nil {
+		t.Fatalf("Failed to decompress backup: %v", err)
+	}
+	restoredFile.Close()
+
+	restored, err := sql.Open("sqlite3", restoredPath)
+	if err != nil {
+		t.Fatalf("Failed to open restored database: %v", err)
+	}
+	defer restored.Close()
+
+	var email string
+	if err := restored.QueryRow("SELECT email FROM users").Scan(&email); err != nil {
+		t.Fatalf("Failed to read restored data: %v", err)
+	}
+	if email != "user@example.com" {
+		t.Errorf("Expected restored user, got %q", email)
+	}
+}
+
+func TestRun_AppliesRetention(t *testing.T) {
+	m, _, dir := setupManager(t, 2)
+
+	// Unrelated files in the directory are left alone
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		backup, err := m.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Backup %d failed: %v", i, err)
+		}
+		names = append(names, backup.Name)
+	}
+
+	backups, err := m.List(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups to be kept, got %d", len(backups))
+	}
+	if backups[0].Name != names[3] || backups[1].Name != names[2] {
+		t.Errorf("Expected the newest backups newest first, got %s and %s", backups[0].Name, backups[1].Name)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("Expected unrelated files to be kept")
+	}
+}
+
+func TestRun_UnsupportedDialect(t *testing.T) {
+	m := NewManager(&database.Database{Dialect: database.Postgres}, nil, 0)
+	if _, err := m.Run(context.Background()); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	m, _, _ := setupManager(t, 0)
+
+	m.Start(10 * time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		backups, _ := m.List(context.Background())
+		if len(backups) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a scheduled backup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+	m.Stop()
+}
@@ -0,0 +1,210 @@
+// Package bounces learns which notification addresses mail is failing to,
+// from the sending provider's bounce and complaint webhooks (Amazon SES,
+// SendGrid and Mailgun) or, when sending over plain SMTP, from the bounce
+// messages that arrive in a mailbox polled over IMAP. Failing addresses
+// are recorded so forms sending to them can warn their owners.
+package bounces
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"staticsend/pkg/models"
+)
+
+// Bounce is mail to an address failing
+type Bounce struct {
+	Address string
+	// Kind is one of the models.BounceKind constants
+	Kind   string
+	Reason string
+}
+
+// maxReasonLength caps the reason stored for a bounce
+const maxReasonLength = 500
+
+// snsEnvelope is how Amazon SNS delivers messages to an HTTPS subscription
+type snsEnvelope struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// sesNotification is an Amazon SES bounce or complaint notification, or
+// event, which names its type differently
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Bounce           struct {
+		BounceType        string `json:"bounceType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			Status         string `json:"status"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplainedRecipients []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+	} `json:"complaint"`
+}
+
+// ParseSES reads an Amazon SES notification, delivered through SNS or
+// without the SNS envelope. A new SNS subscription must be confirmed by
+// fetching the subscribe URL returned, once VerifySNS has checked it.
+func ParseSES(body []byte) (bounces []Bounce, subscribeURL string, err error) {
+	var envelope snsEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, "", err
+	}
+	message := body
+	switch envelope.Type {
+	case "SubscriptionConfirmation":
+		if !ValidSNSURL(envelope.SubscribeURL) {
+			return nil, "", fmt.Errorf("subscribe URL %q isn't Amazon SNS", envelope.SubscribeURL)
+		}
+		return nil, envelope.SubscribeURL, nil
+	case "Notification":
+		message = []byte(envelope.Message)
+	case "":
+		// Raw message delivery
+	default:
+		return nil, "", nil
+	}
+
+	var notification sesNotification
+	if err := json.Unmarshal(message, &notification); err != nil {
+		return nil, "", err
+	}
+	kind := notification.NotificationType
+	if kind == "" {
+		kind = notification.EventType
+	}
+	switch kind {
+	case "Bounce":
+		if notification.Bounce.BounceType != "Permanent" {
+			return nil, "", nil
+		}
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			reason := recipient.DiagnosticCode
+			if reason == "" {
+				reason = recipient.Status
+			}
+			bounces = append(bounces, Bounce{Address: recipient.EmailAddress, Kind: models.BounceKindBounce, Reason: reason})
+		}
+	case "Complaint":
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			bounces = append(bounces, Bounce{Address: recipient.EmailAddress, Kind: models.BounceKindComplaint, Reason: notification.Complaint.ComplaintFeedbackType})
+		}
+	}
+	return bounces, "", nil
+}
+
+// snsHost matches Amazon SNS endpoints, such as sns.us-east-1.amazonaws.com
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// ValidSNSURL reports whether a URL is Amazon SNS's, so confirming a
+// subscription can't be used to make requests elsewhere
+func ValidSNSURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "https" && snsHost.MatchString(u.Hostname()) && u.Port() == ""
+}
+
+// sendGridEvent is one of the events SendGrid's event webhook posts
+type sendGridEvent struct {
+	Email  string `json:"email"`
+	Event  string `json:"event"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// ParseSendGrid reads SendGrid's event webhook. Blocked messages, which
+// SendGrid reports as bounces, are temporary and skipped.
+func ParseSendGrid(body []byte) ([]Bounce, error) {
+	var events []sendGridEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, err
+	}
+	var bounces []Bounce
+	for _, event := range events {
+		switch {
+		case event.Event == "bounce" && event.Type != "blocked", event.Event == "dropped":
+			bounces = append(bounces, Bounce{Address: event.Email, Kind: models.BounceKindBounce, Reason: event.Reason})
+		case event.Event == "spamreport":
+			bounces = append(bounces, Bounce{Address: event.Email, Kind: models.BounceKindComplaint, Reason: "spam report"})
+		}
+	}
+	return bounces, nil
+}
+
+// mailgunWebhook is the payload of Mailgun's webhooks
+type mailgunWebhook struct {
+	EventData struct {
+		Event          string `json:"event"`
+		Severity       string `json:"severity"`
+		Recipient      string `json:"recipient"`
+		DeliveryStatus struct {
+			Message     string `json:"message"`
+			Description string `json:"description"`
+		} `json:"delivery-status"`
+	} `json:"event-data"`
+}
+
+// ParseMailgun reads a Mailgun failed or complained webhook
+func ParseMailgun(body []byte) ([]Bounce, error) {
+	var webhook mailgunWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, err
+	}
+	event := webhook.EventData
+	switch {
+	case event.Event == "failed" && event.Severity == "permanent":
+		reason := event.DeliveryStatus.Message
+		if reason == "" {
+			reason = event.DeliveryStatus.Description
+		}
+		return []Bounce{{Address: event.Recipient, Kind: models.BounceKindBounce, Reason: reason}}, nil
+	case event.Event == "complained":
+		return []Bounce{{Address: event.Recipient, Kind: models.BounceKindComplaint, Reason: "spam complaint"}}, nil
+	}
+	return nil, nil
+}
+
+// Record stores bounces reported by provider, skipping any without an
+// address
+func Record(ctx context.Context, db *sql.DB, provider string, bounces []Bounce) error {
+	var errs []error
+	for _, bounce := range bounces {
+		if !strings.Contains(bounce.Address, "@") {
+			continue
+		}
+		reason := strings.TrimSpace(bounce.Reason)
+		if len(reason) > maxReasonLength {
+			reason = strings.ToValidUTF8(reason[:maxReasonLength], "")
+		}
+		if err := models.RecordEmailBounceContext(ctx, db, bounce.Address, bounce.Kind, provider, reason, time.Now()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("Recorded %s for a notification address from %s", bounce.Kind, provider)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,401 @@
+package bounces
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestParseSES(t *testing.T) {
+	sns := func(message string) string {
+		envelope, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": message})
+		return string(envelope)
+	}
+	tests := []struct {
+		name string
+		body string
+		want []Bounce
+	}{
+		{
+			name: "permanent bounce",
+			body: sns(`{"notificationType":"Bounce","bounce":{"bounceType":"Permanent","bouncedRecipients":[{"emailAddress":"owner@example.com","diagnosticCode":"smtp; 550 5.1.1 user unknown"}]}}`),
+			want: []Bounce{{Address: "owner@example.com", Kind: models.BounceKindBounce, Reason: "smtp; 550 5.1.1 user unknown"}},
+		},
+		{
+			name: "transient bounce",
+			body: sns(`{"notificationType":"Bounce","bounce":{"bounceType":"Transient","bouncedRecipients":[{"emailAddress":"owner@example.com"}]}}`),
+		},
+		{
+			name: "complaint event without the SNS envelope",
+			body: `{"eventType":"Complaint","complaint":{"complainedRecipients":[{"emailAddress":"owner@example.com"}],"complaintFeedbackType":"abuse"}}`,
+			want: []Bounce{{Address: "owner@example.com", Kind: models.BounceKindComplaint, Reason: "abuse"}},
+		},
+		{
+			name: "delivery",
+			body: sns(`{"notificationType":"Delivery"}`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, subscribeURL, err := ParseSES([]byte(tt.body))
+			if err != nil || subscribeURL != "" {
+				t.Fatalf("Unexpected result %q, %v", subscribeURL, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	_, subscribeURL, err := ParseSES([]byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=abc"}`))
+	if err != nil || subscribeURL != "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=abc" {
+		t.Errorf("Expected the subscribe URL, got %q (%v)", subscribeURL, err)
+	}
+	for _, bad := range []string{"http://sns.eu-west-1.amazonaws.com/", "https://sns.eu-west-1.amazonaws.com.example.com/", "https://169.254.169.254/", "https://sns.eu-west-1.amazonaws.com:8443/"} {
+		if _, _, err := ParseSES([]byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"` + bad + `"}`)); err == nil {
+			t.Errorf("Expected subscribe URL %s to be refused", bad)
+		}
+	}
+}
+
+func TestVerifySNS(t *testing.T) {
+	signer := newSNSSigner(t)
+	var fetched []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetched = append(fetched, req.URL.String())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(signer.certPEM))}, nil
+	})}
+	certURL := "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-abc.pem"
+	subscription := func() snsEnvelope {
+		return snsEnvelope{
+			Type:           "SubscriptionConfirmation",
+			MessageID:      "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+			Token:          "2336412f37",
+			TopicArn:       "arn:aws:sns:us-east-1:123456789012:ses-bounces",
+			Message:        "You have chosen to subscribe to the topic",
+			SubscribeURL:   "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=2336412f37",
+			Timestamp:      "2026-10-17T00:00:00.000Z",
+			SigningCertURL: certURL,
+		}
+	}
+
+	for _, version := range []string{"1", "2"} {
+		envelope := subscription()
+		envelope.SignatureVersion = version
+		if err := VerifySNS(context.Background(), client, signer.sign(t, envelope)); err != nil {
+			t.Errorf("Expected a version %s signature to verify, got %v", version, err)
+		}
+	}
+	notification := subscription()
+	notification.Type, notification.Subject, notification.SignatureVersion = "Notification", "Amazon SES Bounce", "2"
+	if err := VerifySNS(context.Background(), client, signer.sign(t, notification)); err != nil {
+		t.Errorf("Expected a notification's signature to verify, got %v", err)
+	}
+	if len(fetched) == 0 || fetched[0] != certURL {
+		t.Errorf("Expected the signing certificate fetched from SNS, got %v", fetched)
+	}
+
+	// Changing the subscribe URL after signing breaks the signature
+	envelope := subscription()
+	envelope.SignatureVersion = "2"
+	tampered := bytes.Replace(signer.sign(t, envelope), []byte("sns.us-east-1"), []byte("sns.us-east-2"), 1)
+	if err := VerifySNS(context.Background(), client, tampered); err == nil {
+		t.Error("Expected a tampered message to be refused")
+	}
+
+	fetched = nil
+	for _, bad := range []string{"https://attacker.example.com/cert.pem", "http://sns.us-east-1.amazonaws.com/cert.pem", "https://sns.us-east-1.amazonaws.com/cert.txt"} {
+		envelope := subscription()
+		envelope.SignatureVersion, envelope.SigningCertURL = "2", bad
+		if err := VerifySNS(context.Background(), client, signer.sign(t, envelope)); err == nil {
+			t.Errorf("Expected signing certificate URL %s to be refused", bad)
+		}
+	}
+	if len(fetched) != 0 {
+		t.Errorf("Expected no certificates fetched from elsewhere, got %v", fetched)
+	}
+}
+
+// roundTripFunc adapts a function to an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// snsSigner signs messages the way Amazon SNS does, with a throwaway key
+type snsSigner struct {
+	key     *rsa.PrivateKey
+	certPEM []byte
+}
+
+func newSNSSigner(t *testing.T) *snsSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return &snsSigner{key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// sign returns the message as SNS would post it
+func (s *snsSigner) sign(t *testing.T, envelope snsEnvelope) []byte {
+	t.Helper()
+	var hash crypto.Hash
+	var digest []byte
+	if envelope.SignatureVersion == "1" {
+		sum := sha1.Sum([]byte(envelope.signedString()))
+		hash, digest = crypto.SHA1, sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(envelope.signedString()))
+		hash, digest = crypto.SHA256, sum[:]
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, hash, digest)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	envelope.Signature = base64.StdEncoding.EncodeToString(signature)
+	body, _ := json.Marshal(envelope)
+	return body
+}
+
+func TestParseSendGrid(t *testing.T) {
+	got, err := ParseSendGrid([]byte(`[
+		{"email":"gone@example.com","event":"bounce","type":"bounce","reason":"550 5.1.1 no such user"},
+		{"email":"busy@example.com","event":"bounce","type":"blocked","reason":"421 try again later"},
+		{"email":"spam@example.com","event":"spamreport"},
+		{"email":"ok@example.com","event":"delivered"}
+	]`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	want := []Bounce{
+		{Address: "gone@example.com", Kind: models.BounceKindBounce, Reason: "550 5.1.1 no such user"},
+		{Address: "spam@example.com", Kind: models.BounceKindComplaint, Reason: "spam report"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestParseMailgun(t *testing.T) {
+	tests := []struct {
+		body string
+		want []Bounce
+	}{
+		{
+			body: `{"signature":{},"event-data":{"event":"failed","severity":"permanent","recipient":"gone@example.com","delivery-status":{"message":"550 No such mailbox"}}}`,
+			want: []Bounce{{Address: "gone@example.com", Kind: models.BounceKindBounce, Reason: "550 No such mailbox"}},
+		},
+		{
+			body: `{"event-data":{"event":"failed","severity":"temporary","recipient":"busy@example.com"}}`,
+		},
+		{
+			body: `{"event-data":{"event":"complained","recipient":"spam@example.com"}}`,
+			want: []Bounce{{Address: "spam@example.com", Kind: models.BounceKindComplaint, Reason: "spam complaint"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseMailgun([]byte(tt.body))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Expected %v, got %v", tt.want, got)
+		}
+	}
+}
+
+// dsn is a bounce message for two recipients, one delayed
+const dsn = "From: MAILER-DAEMON@mail.example.net\r\n" +
+	"To: bounces@example.com\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message couldn't be delivered.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mail.example.net\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; owner@example.com\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 <owner@example.com>: Recipient address rejected\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; slow@example.com\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.4.1\r\n" +
+	"\r\n" +
+	"--b1--\r\n"
+
+// arf is a spam complaint about a notification email
+const arf = "From: feedback@isp.example\r\n" +
+	"To: bounces@example.com\r\n" +
+	"Subject: Abuse report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report; boundary=\"b2\"\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"This is an email abuse report.\r\n" +
+	"--b2\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: ISP-FBL/1.0\r\n" +
+	"Version: 1\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"\r\n" +
+	"From: noreply@example.com\r\n" +
+	"To: complainer@example.org\r\n" +
+	"Subject: New submission\r\n" +
+	"\r\n" +
+	"Name: Ada\r\n" +
+	"--b2--\r\n"
+
+func TestParseReport(t *testing.T) {
+	got, err := ParseReport(strings.NewReader(dsn))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	want := []Bounce{{Address: "owner@example.com", Kind: models.BounceKindBounce, Reason: "550 5.1.1 <owner@example.com>: Recipient address rejected"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	got, err = ParseReport(strings.NewReader(arf))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	want = []Bounce{{Address: "complainer@example.org", Kind: models.BounceKindComplaint, Reason: "spam complaint"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	got, err = ParseReport(strings.NewReader("From: someone@example.com\r\nSubject: Hi\r\n\r\nNot a bounce\r\n"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("Expected no bounces in an ordinary message, got %v (%v)", got, err)
+	}
+}
+
+// serveIMAP answers a poll for a mailbox holding messages, keyed by UID,
+// sending the commands it was given to commands
+func serveIMAP(listener net.Listener, messages map[string]string, commands chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		commands <- line
+		tag, command, _ := strings.Cut(line, " ")
+		switch {
+		case strings.HasPrefix(command, "SELECT"):
+			fmt.Fprintf(conn, "* %d EXISTS\r\n%s OK [READ-WRITE] SELECT completed\r\n", len(messages), tag)
+		case command == "UID SEARCH UNSEEN":
+			var uids []string
+			for uid := range messages {
+				uids = append(uids, uid)
+			}
+			fmt.Fprintf(conn, "* SEARCH %s\r\n%s OK SEARCH completed\r\n", strings.Join(uids, " "), tag)
+		case strings.HasPrefix(command, "UID FETCH "):
+			uid := strings.Fields(command)[2]
+			fmt.Fprintf(conn, "* 1 FETCH (UID %s BODY[] {%d}\r\n%s)\r\n%s OK FETCH completed\r\n", uid, len(messages[uid]), messages[uid], tag)
+		case command == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+			return
+		default:
+			fmt.Fprintf(conn, "%s OK completed\r\n", tag)
+		}
+	}
+}
+
+func TestPoller_Run(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	commands := make(chan string, 20)
+	go serveIMAP(listener, map[string]string{"7": dsn, "8": "Subject: Out of office\r\n\r\nBack Monday\r\n"}, commands)
+
+	poller := NewPoller(db, listener.Addr().String(), "bounces", `pa"ss`)
+	poller.TLS = false
+	poller.Timeout = 5 * time.Second
+	recorded, err := poller.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if recorded != 1 {
+		t.Errorf("Expected one bounce recorded, got %d", recorded)
+	}
+	bounce, err := models.GetEmailBounce(db.Connection, "owner@example.com")
+	if err != nil || bounce == nil || bounce.Provider != "imap" {
+		t.Errorf("Expected the bounce recorded from the mailbox, got %+v (%v)", bounce, err)
+	}
+
+	close(commands)
+	var sent []string
+	for command := range commands {
+		sent = append(sent, command)
+	}
+	log := strings.Join(sent, "\n")
+	for _, want := range []string{`LOGIN "bounces" "pa\"ss"`, `SELECT "INBOX"`, "UID STORE 7 +FLAGS.SILENT (\\Seen)", "UID STORE 8 +FLAGS.SILENT (\\Seen)"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("Expected %q to be sent, got:\n%s", want, log)
+		}
+	}
+}
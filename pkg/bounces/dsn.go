@@ -0,0 +1,137 @@
+package bounces
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"staticsend/pkg/models"
+)
+
+// ParseReport reads a bounce message (a delivery status notification, RFC
+// 3464) or a spam complaint (an abuse feedback report, RFC 5965). Messages
+// that are neither have no bounces.
+func ParseReport(r io.Reader) ([]Bounce, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["boundary"] == "" {
+		return nil, nil
+	}
+
+	var bounces []Bounce
+	var complaint bool
+	var complainedTo []string
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			found, err := parseDeliveryStatus(part)
+			if err != nil {
+				return nil, err
+			}
+			bounces = append(bounces, found...)
+		case "message/feedback-report":
+			complaint = true
+			fields, err := readFields(bufio.NewReader(part))
+			if err != nil && len(fields) == 0 {
+				return nil, err
+			}
+			complainedTo = append(complainedTo, addresses(fields.Values("Original-Rcpt-To"))...)
+		case "message/rfc822", "text/rfc822-headers":
+			// The complaint was about mail to the original message's recipient
+			if complaint && len(complainedTo) == 0 {
+				if original, err := mail.ReadMessage(part); err == nil {
+					if to, err := original.Header.AddressList("To"); err == nil {
+						for _, address := range to {
+							complainedTo = append(complainedTo, address.Address)
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, address := range complainedTo {
+		bounces = append(bounces, Bounce{Address: address, Kind: models.BounceKindComplaint, Reason: "spam complaint"})
+	}
+	return bounces, nil
+}
+
+// parseDeliveryStatus reads the fields of a delivery status: a group for
+// the message, then a group for each recipient
+func parseDeliveryStatus(r io.Reader) ([]Bounce, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	groups := bytes.Split(bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")), []byte("\n\n"))
+	var bounces []Bounce
+	for _, group := range groups[min(1, len(groups)):] {
+		if len(bytes.TrimSpace(group)) == 0 {
+			continue
+		}
+		fields, err := readFields(bufio.NewReader(bytes.NewReader(append(group, '\n', '\n'))))
+		if err != nil && len(fields) == 0 {
+			continue
+		}
+		// Delayed and relayed recipients may still be delivered to
+		if !strings.EqualFold(fields.Get("Action"), "failed") || strings.HasPrefix(fields.Get("Status"), "4") {
+			continue
+		}
+		recipient := addresses([]string{fields.Get("Final-Recipient")})
+		if len(recipient) == 0 {
+			recipient = addresses([]string{fields.Get("Original-Recipient")})
+		}
+		if len(recipient) == 0 {
+			continue
+		}
+		reason := fields.Get("Diagnostic-Code")
+		if _, code, found := strings.Cut(reason, ";"); found {
+			reason = strings.TrimSpace(code)
+		}
+		if reason == "" {
+			reason = fields.Get("Status")
+		}
+		bounces = append(bounces, Bounce{Address: recipient[0], Kind: models.BounceKindBounce, Reason: reason})
+	}
+	return bounces, nil
+}
+
+// readFields reads a group of header-style fields
+func readFields(r *bufio.Reader) (textproto.MIMEHeader, error) {
+	return textproto.NewReader(r).ReadMIMEHeader()
+}
+
+// addresses returns the addresses of typed address fields, such as
+// "rfc822; owner@example.com"
+func addresses(values []string) []string {
+	var found []string
+	for _, value := range values {
+		if addressType, address, ok := strings.Cut(value, ";"); ok {
+			if !strings.EqualFold(strings.TrimSpace(addressType), "rfc822") && !strings.EqualFold(strings.TrimSpace(addressType), "utf-8") {
+				continue
+			}
+			value = address
+		}
+		value = strings.Trim(strings.TrimSpace(value), "<>")
+		if strings.Contains(value, "@") {
+			found = append(found, value)
+		}
+	}
+	return found
+}
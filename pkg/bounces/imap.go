@@ -0,0 +1,280 @@
+package bounces
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/outbound"
+)
+
+const (
+	// maxMessagesPerPoll caps the messages read from the mailbox in one poll
+	maxMessagesPerPoll = 100
+	// maxMessageSize caps the size of a message read from the mailbox
+	maxMessageSize = 10 << 20
+	// defaultIMAPTimeout bounds a poll when no timeout is configured
+	defaultIMAPTimeout = time.Minute
+)
+
+// Poller reads bounce messages and complaints sent to a mailbox, such as
+// the envelope sender's, over IMAP. Unread messages are read and marked
+// read, so the mailbox should only get bounces.
+type Poller struct {
+	db *database.Database
+	// Addr is the IMAP server's host and port
+	Addr     string
+	Username string
+	Password string
+	// Mailbox is the folder read, INBOX by default
+	Mailbox string
+	// TLS connects with TLS, as IMAP servers on port 993 expect
+	TLS bool
+	// Proxy, if set, is an HTTP or SOCKS5 proxy to reach the server through
+	Proxy *url.URL
+	// Timeout bounds a whole poll. Defaults to a minute.
+	Timeout time.Duration
+
+	// mu ensures only one poll happens at a time
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPoller creates a poller that signs in to the IMAP server at addr
+func NewPoller(db *database.Database, addr, username, password string) *Poller {
+	return &Poller{
+		db:       db,
+		Addr:     addr,
+		Username: username,
+		Password: password,
+		Mailbox:  "INBOX",
+		TLS:      true,
+	}
+}
+
+// Run reads the mailbox's unread messages, records the bounces and
+// complaints among them and returns how many it recorded
+func (p *Poller) Run(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultIMAPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := p.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.close()
+
+	if _, err := client.command("LOGIN %s %s", quote(p.Username), quote(p.Password)); err != nil {
+		return 0, fmt.Errorf("failed to sign in: %w", err)
+	}
+	mailbox := p.Mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if _, err := client.command("SELECT %s", quote(mailbox)); err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", mailbox, err)
+	}
+	response, err := client.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return 0, fmt.Errorf("failed to search %s: %w", mailbox, err)
+	}
+	var uids []string
+	for _, line := range response.lines {
+		if rest, ok := strings.CutPrefix(line, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+	if len(uids) > maxMessagesPerPoll {
+		uids = uids[:maxMessagesPerPoll]
+	}
+
+	recorded := 0
+	for _, uid := range uids {
+		if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+			return recorded, fmt.Errorf("invalid message UID %q", uid)
+		}
+		response, err := client.command("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return recorded, fmt.Errorf("failed to read message %s: %w", uid, err)
+		}
+		if len(response.literals) > 0 {
+			found, err := ParseReport(bytes.NewReader(response.literals[0]))
+			if err != nil {
+				log.Printf("Skipping unreadable message %s in the bounce mailbox: %v", uid, err)
+			}
+			if err := Record(ctx, p.db.Connection, "imap", found); err != nil {
+				return recorded, err
+			}
+			recorded += len(found)
+		}
+		if _, err := client.command("UID STORE %s +FLAGS.SILENT (\\Seen)", uid); err != nil {
+			return recorded, fmt.Errorf("failed to mark message %s read: %w", uid, err)
+		}
+	}
+	client.command("LOGOUT")
+	return recorded, nil
+}
+
+// Start polls the mailbox every interval until Stop is called
+func (p *Poller) Start(interval time.Duration) {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if _, err := p.Run(context.Background()); err != nil {
+					log.Printf("Polling the bounce mailbox failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops polling, waiting for a running poll to finish
+func (p *Poller) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+}
+
+// dial connects and reads the server's greeting
+func (p *Poller) dial(ctx context.Context) (*imapClient, error) {
+	conn, err := outbound.Dial(ctx, p.Proxy, "tcp", p.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if p.TLS {
+		host, _, _ := net.SplitHostPort(p.Addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	client := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := client.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting %q", greeting)
+	}
+	return client, nil
+}
+
+// imapClient speaks just enough IMAP (RFC 9051) to read a mailbox
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapResponse is the untagged lines sent in reply to a command, and the
+// literals (such as messages) they carried
+type imapResponse struct {
+	lines    []string
+	literals [][]byte
+}
+
+// command sends a command and reads the response, returning an error if
+// the server didn't reply OK
+func (c *imapClient) command(format string, args ...interface{}) (*imapResponse, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+
+	response := &imapResponse{}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("server replied %q", status)
+			}
+			return response, nil
+		}
+		// A line ending {n} is followed by n bytes, then the rest of the line
+		for {
+			open := strings.LastIndex(line, "{")
+			if open < 0 || !strings.HasSuffix(line, "}") {
+				break
+			}
+			size, err := strconv.Atoi(strings.TrimSuffix(line[open+1:len(line)-1], "+"))
+			if err != nil || size < 0 || size > maxMessageSize {
+				return nil, fmt.Errorf("invalid literal in %q", line)
+			}
+			literal := make([]byte, size)
+			if _, err := io.ReadFull(c.r, literal); err != nil {
+				return nil, err
+			}
+			response.literals = append(response.literals, literal)
+			rest, err := c.readLine()
+			if err != nil {
+				return nil, err
+			}
+			line = line[:open] + rest
+		}
+		response.lines = append(response.lines, line)
+	}
+}
+
+// readLine reads a line, without its line ending
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// close closes the connection
+func (c *imapClient) close() error {
+	return c.conn.Close()
+}
+
+// quote returns s as an IMAP quoted string
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,132 @@
+package bounces
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxCertificateSize caps the signing certificate fetched from Amazon SNS
+const maxCertificateSize = 64 << 10
+
+// VerifySNS checks an Amazon SNS message's signature with the certificate
+// it was signed with, which is fetched from SNS, so a subscription can only
+// be confirmed for messages Amazon sent
+func VerifySNS(ctx context.Context, client *http.Client, body []byte) error {
+	var envelope snsEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return err
+	}
+
+	var hash crypto.Hash
+	var digest []byte
+	signed := []byte(envelope.signedString())
+	switch envelope.SignatureVersion {
+	case "1":
+		sum := sha1.Sum(signed)
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256(signed)
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return fmt.Errorf("unsupported signature version %q", envelope.SignatureVersion)
+	}
+	signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	cert, err := fetchSNSCertificate(ctx, client, envelope.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate doesn't have an RSA key")
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+		return errors.New("signature doesn't match the message")
+	}
+	return nil
+}
+
+// signedString returns the fields of the message SNS signs, in the order
+// it signs them
+func (e snsEnvelope) signedString() string {
+	var fields [][2]string
+	if e.Type == "Notification" {
+		fields = append(fields, [2]string{"Message", e.Message}, [2]string{"MessageId", e.MessageID})
+		if e.Subject != "" {
+			fields = append(fields, [2]string{"Subject", e.Subject})
+		}
+		fields = append(fields, [2]string{"Timestamp", e.Timestamp}, [2]string{"TopicArn", e.TopicArn}, [2]string{"Type", e.Type})
+	} else {
+		fields = [][2]string{
+			{"Message", e.Message},
+			{"MessageId", e.MessageID},
+			{"SubscribeURL", e.SubscribeURL},
+			{"Timestamp", e.Timestamp},
+			{"Token", e.Token},
+			{"TopicArn", e.TopicArn},
+			{"Type", e.Type},
+		}
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+// fetchSNSCertificate downloads the certificate at certURL, which must be
+// a .pem file served by Amazon SNS, and checks it's current
+func fetchSNSCertificate(ctx context.Context, client *http.Client, certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || !ValidSNSURL(certURL) || !strings.HasSuffix(u.Path, ".pem") {
+		return nil, fmt.Errorf("signing certificate URL %q isn't Amazon SNS", certURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing certificate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signing certificate: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCertificateSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("signing certificate isn't PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %w", err)
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, errors.New("signing certificate isn't current")
+	}
+	return cert, nil
+}
@@ -0,0 +1,111 @@
+// Package clientip finds the address a request came from. Any client can
+// send forwarding headers, so they're only believed when the connection
+// comes from a trusted proxy.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	mu      sync.RWMutex
+	trusted []*net.IPNet
+)
+
+// ParseProxies parses the addresses and CIDR ranges of trusted proxies
+func ParseProxies(values []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", value)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// SetTrustedProxies sets the proxies whose forwarding headers are believed,
+// once at startup. Without any, requests' own addresses are used.
+func SetTrustedProxies(proxies []*net.IPNet) {
+	mu.Lock()
+	defer mu.Unlock()
+	trusted = proxies
+}
+
+// FromRequest returns the address of the client that sent a request. From
+// a trusted proxy it's taken from CF-Connecting-IP, then the nearest
+// X-Forwarded-For entry that isn't a trusted proxy, then X-Real-IP.
+// Connections over a Unix socket come from a local proxy, so are trusted
+// too. Otherwise it's the connection's address, without its port.
+func FromRequest(r *http.Request) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); ip != nil && !isTrusted(ip) {
+		return host
+	}
+
+	if ip := headerIP(r.Header.Get("CF-Connecting-IP")); ip != "" {
+		return ip
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		entries := strings.Split(forwarded, ",")
+		// Proxies append the address they got the request from, so only
+		// entries up to the first untrusted one are reliable
+		for i := len(entries) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(entries[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !isTrusted(ip) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := headerIP(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	return host
+}
+
+// headerIP returns a header's address, or "" when it isn't one
+func headerIP(value string) string {
+	if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// isTrusted reports whether an address is a trusted proxy's
+func isTrusted(ip net.IP) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
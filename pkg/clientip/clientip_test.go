@@ -0,0 +1,66 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseProxies(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", " 192.0.2.1 ", "", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("Failed to parse proxies: %v", err)
+	}
+	if len(proxies) != 3 || proxies[1].String() != "192.0.2.1/32" || proxies[2].String() != "2001:db8::1/128" {
+		t.Errorf("Expected three proxies, got %v", proxies)
+	}
+	if _, err := ParseProxies([]string{"proxy.example.com"}); err == nil {
+		t.Error("Expected a host name to be refused")
+	}
+	if _, err := ParseProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected a bad range to be refused")
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	proxies, _ := ParseProxies([]string{"10.0.0.0/8"})
+	SetTrustedProxies(proxies)
+	defer SetTrustedProxies(nil)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     map[string]string
+		expected   string
+	}{
+		{"direct", "203.0.113.7:51234", nil, "203.0.113.7"},
+		{"spoofed headers from a client", "203.0.113.7:51234", map[string]string{
+			"CF-Connecting-IP": "198.51.100.1",
+			"X-Forwarded-For":  "198.51.100.2",
+			"X-Real-IP":        "198.51.100.3",
+		}, "203.0.113.7"},
+		{"Cloudflare through a proxy", "10.0.0.2:80", map[string]string{
+			"CF-Connecting-IP": "198.51.100.1",
+			"X-Forwarded-For":  "198.51.100.2",
+		}, "198.51.100.1"},
+		{"forwarded through a proxy", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "198.51.100.2"}, "198.51.100.2"},
+		// The client can prepend anything; only the entry the proxy added
+		// counts
+		{"prepended entries", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "192.0.2.9, 198.51.100.2"}, "198.51.100.2"},
+		{"chained proxies", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "198.51.100.2, 10.0.0.3"}, "198.51.100.2"},
+		{"real IP through a proxy", "10.0.0.2:80", map[string]string{"X-Real-IP": "198.51.100.3"}, "198.51.100.3"},
+		{"proxy without headers", "10.0.0.2:80", nil, "10.0.0.2"},
+		{"unix socket", "@", map[string]string{"X-Forwarded-For": "198.51.100.2"}, "198.51.100.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			if ip := FromRequest(req); ip != tt.expected {
+				t.Errorf("Expected IP %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
@@ -1,67 +1,489 @@
+// Package config loads staticSend's settings. Each setting can come from a
+// command line flag, an environment variable, a YAML or TOML config file or
+// its default, in that order of precedence.
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
+	"time"
+
+	"staticsend/pkg/clientip"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+	"staticsend/pkg/outbound"
+	"staticsend/pkg/plugins"
+	"staticsend/pkg/turnstile"
+	"staticsend/pkg/velocity"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Port                string
-	DatabasePath        string
-	EmailHost          string
-	EmailPort          int
-	EmailUsername      string
-	EmailPassword      string
-	EmailFrom          string
-	EmailUseTLS        bool
-	TurnstilePublicKey string
-	TurnstileSecretKey string
-	JWTSecretKey       string
-	RegistrationEnabled bool
+	// Args are the command line arguments that aren't flags, such as a
+	// command to run instead of the server
+	Args []string
+	// ShowVersion is set by -version, to print the version and exit
+	ShowVersion bool
+
+	Port                     string
+	ListenSocket             string
+	SocketMode               string
+	BaseURL                  string
+	BasePath                 string
+	TLSCert                  string
+	TLSKey                   string
+	AutocertDomains          []string
+	AutocertEmail            string
+	AutocertCacheDir         string
+	DatabasePath             string
+	DatabaseDSN              string
+	SQLiteJournalMode        string
+	SQLiteBusyTimeout        time.Duration
+	SQLiteSynchronous        string
+	SQLiteMaxOpenConns       int
+	SlowQueryThreshold       time.Duration
+	EmailHost                string
+	EmailPort                int
+	EmailUsername            string
+	EmailPassword            string
+	EmailFrom                string
+	EmailUseTLS              bool
+	TurnstilePublicKey       string
+	TurnstileSecretKey       string
+	TurnstileVerifyURL       string
+	TurnstileTimeout         time.Duration
+	JWTSecretKey             string
+	JWTSecretPath            string
+	SecretsKey               string
+	SecretsKeyPath           string
+	RegistrationEnabled      bool
+	RateLimitStore           string
+	RedisURL                 string
+	LogRedactKeys            []string
+	TrustedProxies           []string
+	CompressionLevel         int
+	MaxConcurrentSubmissions int
+	MaxQueuedSubmissions     int
+	SubmissionQueueTimeout   time.Duration
+	MaxConcurrentDownloads   int
+	ReadTimeout              time.Duration
+	WriteTimeout             time.Duration
+	IdleTimeout              time.Duration
+	HandlerTimeout           time.Duration
+	DownloadTimeout          time.Duration
+	EmailTimeout             time.Duration
+	EmailHeloName            string
+	EmailEnvelopeFrom        string
+	EmailDKIMDomain          string
+	EmailDKIMSelector        string
+	EmailDKIMPrivateKey      string
+	EmailFallbackHost        string
+	EmailFallbackPort        int
+	EmailFallbackUsername    string
+	EmailFallbackPassword    string
+	EmailFallbackUseTLS      bool
+	EmailFailoverAfter       int
+	EmailFailbackAfter       time.Duration
+	EmailAlertQueueDepth     int
+	EmailAlertOldestAge      time.Duration
+	EmailAlertFailurePercent int
+	EmailAlertInterval       time.Duration
+	AlertWebhookURL          string
+	MetricsToken             string
+	BounceWebhookToken       string
+	BounceIMAPAddr           string
+	BounceIMAPUsername       string
+	BounceIMAPPassword       string
+	BounceIMAPMailbox        string
+	BounceIMAPTLS            bool
+	BounceIMAPInterval       time.Duration
+	TemplatesDir             string
+	DevMode                  bool
+	Debug                    bool
+	MigrationsDir            string
+	StaticDir                string
+	BackupInterval           time.Duration
+	BackupDir                string
+	BackupRetention          int
+	StorageBackend           string
+	StorageS3Bucket          string
+	StorageS3Endpoint        string
+	StorageS3Region          string
+	StorageS3Prefix          string
+	StorageS3AccessKey       string
+	StorageS3SecretKey       string
+	StorageS3Insecure        bool
+	StorageLinkTTL           time.Duration
+	UploadDir                string
+	UploadMaxSizeMB          int
+	UploadEmailLinkTTL       time.Duration
+	ArchiveAfterDays         int
+	ArchiveInterval          time.Duration
+	ArchiveDir               string
+	ExportDir                string
+	ExportInterval           time.Duration
+	ExportLinkTTL            time.Duration
+	SheetsSyncInterval       time.Duration
+	HealthMinFreeDiskMB      int
+	HealthCheckEmail         bool
+	IntegrityCheckInterval   time.Duration
+	IntegrityAutoRepair      bool
+	VelocityCheckInterval    time.Duration
+	VelocityWindow           time.Duration
+	VelocityFactor           int
+	VelocityMinSubmissions   int
+	AkismetAPIKey            string
+	GeoIPDatabase            string
+	SpecialFields            []string
+	SubmissionHookCommands   []string
+	SubmissionHookTimeout    time.Duration
+	SettingsReloadInterval   time.Duration
+	LogFile                  string
+	OutboundProxy            string
 }
 
-// LoadConfig loads configuration from environment variables with defaults
-func LoadConfig() *Config {
+// Defaults returns the configuration used when nothing is set
+func Defaults() *Config {
 	return &Config{
-		Port:                getEnv("PORT", "8080"),
-		DatabasePath:        getEnv("DATABASE_PATH", "./data/staticsend.db"),
-		EmailHost:          getEnv("EMAIL_HOST", "localhost"),
-		EmailPort:          getEnvAsInt("EMAIL_PORT", 587),
-		EmailUsername:      getEnv("EMAIL_USERNAME", ""),
-		EmailPassword:      getEnv("EMAIL_PASSWORD", ""),
-		EmailFrom:          getEnv("EMAIL_FROM", "noreply@example.com"),
-		EmailUseTLS:        getEnvAsBool("EMAIL_USE_TLS", true),
-		TurnstilePublicKey: getEnv("TURNSTILE_PUBLIC_KEY", ""),
-		TurnstileSecretKey: getEnv("TURNSTILE_SECRET_KEY", ""),
-		JWTSecretKey:       getEnv("JWT_SECRET_KEY", "change-this-secret-key"),
-		RegistrationEnabled: getEnvAsBool("REGISTRATION_ENABLED", true),
+		Port:                     "8080",
+		SocketMode:               "0660",
+		AutocertCacheDir:         "./data/autocert",
+		DatabasePath:             "./data/staticsend.db",
+		SQLiteJournalMode:        "WAL",
+		SQLiteBusyTimeout:        5 * time.Second,
+		SQLiteSynchronous:        "NORMAL",
+		SQLiteMaxOpenConns:       1,
+		SlowQueryThreshold:       250 * time.Millisecond,
+		EmailHost:                "localhost",
+		EmailPort:                587,
+		EmailFrom:                "noreply@example.com",
+		EmailUseTLS:              true,
+		JWTSecretPath:            "./data/jwt_secret",
+		SecretsKeyPath:           "./data/secrets_key",
+		RegistrationEnabled:      true,
+		RateLimitStore:           "memory",
+		CompressionLevel:         5,
+		MaxConcurrentSubmissions: 10,
+		MaxQueuedSubmissions:     50,
+		SubmissionQueueTimeout:   2 * time.Second,
+		MaxConcurrentDownloads:   4,
+		ReadTimeout:              15 * time.Second,
+		WriteTimeout:             60 * time.Second,
+		IdleTimeout:              120 * time.Second,
+		HandlerTimeout:           30 * time.Second,
+		DownloadTimeout:          30 * time.Minute,
+		EmailTimeout:             30 * time.Second,
+		EmailFallbackPort:        587,
+		EmailFallbackUseTLS:      true,
+		EmailFailoverAfter:       3,
+		EmailFailbackAfter:       15 * time.Minute,
+		EmailAlertQueueDepth:     50,
+		EmailAlertOldestAge:      10 * time.Minute,
+		EmailAlertFailurePercent: 50,
+		EmailAlertInterval:       time.Minute,
+		BounceIMAPMailbox:        "INBOX",
+		BounceIMAPTLS:            true,
+		BounceIMAPInterval:       5 * time.Minute,
+		TurnstileVerifyURL:       turnstile.DefaultVerifyURL,
+		TurnstileTimeout:         turnstile.DefaultTimeout,
+		BackupInterval:           24 * time.Hour,
+		BackupDir:                "./data/backups",
+		BackupRetention:          7,
+		StorageS3Endpoint:        "s3.amazonaws.com",
+		StorageLinkTTL:           5 * time.Minute,
+		UploadDir:                "./data/uploads",
+		UploadMaxSizeMB:          10,
+		ArchiveInterval:          24 * time.Hour,
+		ArchiveDir:               "./data/archives",
+		ExportDir:                "./data/exports",
+		ExportInterval:           time.Minute,
+		ExportLinkTTL:            24 * time.Hour,
+		SheetsSyncInterval:       time.Minute,
+		HealthMinFreeDiskMB:      100,
+		IntegrityCheckInterval:   24 * time.Hour,
+		VelocityCheckInterval:    time.Minute,
+		VelocityWindow:           velocity.DefaultWindow,
+		VelocityFactor:           velocity.DefaultFactor,
+		VelocityMinSubmissions:   velocity.DefaultMinSubmissions,
+		SettingsReloadInterval:   30 * time.Second,
+		SubmissionHookTimeout:    plugins.DefaultTimeout,
+	}
+}
+
+// FileEnv names the environment variable with the config file's path, for
+// when the -config flag isn't given
+const FileEnv = "CONFIG_FILE"
+
+// Load reads the configuration from the command line arguments, the
+// environment and the config file, over the defaults. The error lists every
+// problem found, one per line, rather than stopping at the first. It's
+// flag.ErrHelp when the arguments ask for help, after usage is written to
+// output.
+func Load(args []string, output io.Writer) (*Config, error) {
+	cfg := Defaults()
+	list := settings(cfg)
+
+	flags := flag.NewFlagSet("staticsend", flag.ContinueOnError)
+	flags.SetOutput(output)
+	configFile := flags.String("config", os.Getenv(FileEnv), "YAML or TOML config file (env "+FileEnv+")")
+	flags.BoolVar(&cfg.ShowVersion, "version", false, "Print the version and exit")
+	given := map[string]string{}
+	for _, s := range list {
+		for _, name := range s.flags() {
+			_, boolean := s.value.(boolValue)
+			env := s.env[0]
+			if s.secret {
+				env += " or " + env + "_FILE"
+			}
+			flags.Var(flagValue{name: s.key, given: given, boolean: boolean}, name, s.usage+" (env "+env+")")
+		}
+	}
+	// Flags can come before, after or between a command's arguments
+	for rest := args; ; {
+		if err := flags.Parse(rest); err != nil {
+			return nil, err
+		}
+		if rest = flags.Args(); len(rest) == 0 {
+			break
+		}
+		cfg.Args = append(cfg.Args, rest[0])
+		rest = rest[1:]
+	}
+
+	var problems []error
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, apply(list, values, *configFile)...)
+	}
+	for _, s := range list {
+		for _, name := range s.env {
+			value, err := lookupEnv(name, s.secret)
+			if err != nil {
+				problems = append(problems, err)
+				break
+			}
+			if value != "" {
+				if err := s.value.Set(value); err != nil {
+					problems = append(problems, fmt.Errorf("%s: %w", name, err))
+				}
+				break
+			}
+		}
+	}
+	for _, s := range list {
+		if value, ok := given[s.key]; ok {
+			if err := s.value.Set(value); err != nil {
+				problems = append(problems, fmt.Errorf("-%s: %w", s.flags()[0], err))
+			}
+		}
+	}
+
+	// "/forms/" and "/forms" are the same base path, and "/" is none
+	cfg.BasePath = strings.TrimRight(cfg.BasePath, "/")
+
+	problems = append(problems, cfg.validate()...)
+	return cfg, errors.Join(problems...)
+}
+
+// apply sets the values read from a config file, reporting keys that
+// aren't settings
+func apply(list []setting, values map[string]string, file string) []error {
+	byKey := make(map[string]setting, len(list))
+	for _, s := range list {
+		byKey[s.key] = s
+	}
+
+	var problems []error
+	for _, key := range sortedKeys(values) {
+		value := values[key]
+		s, ok := byKey[key]
+		if !ok {
+			s, ok = byKey[strings.TrimSuffix(key, "_file")]
+			if !ok || !s.secret || !strings.HasSuffix(key, "_file") {
+				problems = append(problems, fmt.Errorf("%s: unknown setting %q", file, key))
+				continue
+			}
+			if _, both := values[s.key]; both {
+				problems = append(problems, fmt.Errorf("%s: set %s or %s, not both", file, s.key, key))
+				continue
+			}
+			secret, err := readSecret(value)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %s: %w", file, key, err))
+				continue
+			}
+			value = secret
+		}
+		if err := s.value.Set(value); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %s: %w", file, key, err))
+		}
+	}
+	return problems
+}
+
+// lookupEnv returns an environment variable's value. A secret's value can
+// instead be kept in a file, such as a Docker or Kubernetes secret, named by
+// the variable with _FILE added, so it isn't shown in process listings.
+func lookupEnv(name string, secret bool) (string, error) {
+	value := os.Getenv(name)
+	if !secret {
+		return value, nil
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("set %s or %s_FILE, not both", name, name)
+	}
+	secretValue, err := readSecret(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", name, err)
 	}
+	return secretValue, nil
 }
 
-// getEnv gets an environment variable with a fallback value
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// readSecret reads a secret from a file, without the line ending editors
+// and echo leave at its end
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
 	}
-	return fallback
+	return strings.TrimRight(string(data), "\r\n"), nil
 }
 
-// getEnvAsInt gets an environment variable as integer with a fallback value
-func getEnvAsInt(key string, fallback int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
+// validate checks settings that depend on each other or must be one of a
+// few values
+func (c *Config) validate() []error {
+	var problems []error
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Errorf("PORT: %q isn't a port number", c.Port))
+	}
+	if mode, err := strconv.ParseUint(c.SocketMode, 8, 32); err != nil || mode > 0777 {
+		problems = append(problems, fmt.Errorf("SOCKET_MODE: %q isn't an octal file mode such as 0660", c.SocketMode))
+	}
+	if c.EmailPort < 1 || c.EmailPort > 65535 {
+		problems = append(problems, fmt.Errorf("EMAIL_PORT: %d isn't a port number", c.EmailPort))
+	}
+	if c.EmailAlertFailurePercent < 0 || c.EmailAlertFailurePercent > 100 {
+		problems = append(problems, fmt.Errorf("EMAIL_ALERT_FAILURE_PERCENT: %d isn't a percentage", c.EmailAlertFailurePercent))
+	}
+	if c.AlertWebhookURL != "" {
+		if u, err := url.Parse(c.AlertWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("ALERT_WEBHOOK_URL: isn't an http or https URL"))
 		}
 	}
-	return fallback
+	if c.EmailFallbackHost != "" {
+		if c.EmailFallbackPort < 1 || c.EmailFallbackPort > 65535 {
+			problems = append(problems, fmt.Errorf("EMAIL_FALLBACK_PORT: %d isn't a port number", c.EmailFallbackPort))
+		}
+		if c.EmailFailoverAfter < 1 {
+			problems = append(problems, fmt.Errorf("EMAIL_FAILOVER_AFTER: %d must be at least 1", c.EmailFailoverAfter))
+		}
+		if c.EmailFailbackAfter <= 0 {
+			problems = append(problems, fmt.Errorf("EMAIL_FAILBACK_AFTER: must be more than 0"))
+		}
+	}
+	if c.BaseURL != "" {
+		if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("BASE_URL: %q isn't an http or https URL", c.BaseURL))
+		}
+	}
+	if u, err := url.Parse(c.TurnstileVerifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Errorf("TURNSTILE_VERIFY_URL: %q isn't an http or https URL", c.TurnstileVerifyURL))
+	}
+	if c.TurnstileTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("TURNSTILE_TIMEOUT: must be more than 0"))
+	}
+	if c.SubmissionHookTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("SUBMISSION_HOOK_TIMEOUT: must be more than 0"))
+	}
+	if c.VelocityWindow <= 0 {
+		problems = append(problems, fmt.Errorf("VELOCITY_WINDOW: must be more than 0"))
+	}
+	if c.VelocityFactor < 2 {
+		problems = append(problems, fmt.Errorf("VELOCITY_FACTOR: %d must be at least 2", c.VelocityFactor))
+	}
+	if c.VelocityMinSubmissions < 1 {
+		problems = append(problems, fmt.Errorf("VELOCITY_MIN_SUBMISSIONS: %d must be at least 1", c.VelocityMinSubmissions))
+	}
+	if _, err := models.ParseSpecialFields(c.SpecialFields); err != nil {
+		problems = append(problems, fmt.Errorf("SPECIAL_FIELDS: %v", err))
+	}
+	if c.BasePath != "" && (!strings.HasPrefix(c.BasePath, "/") || path.Clean(c.BasePath) != c.BasePath || strings.ContainsAny(c.BasePath, "?#")) {
+		problems = append(problems, fmt.Errorf("BASE_PATH: %q isn't a path such as /forms", c.BasePath))
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		problems = append(problems, fmt.Errorf("TLS_CERT and TLS_KEY must be set together"))
+	}
+	if c.TLSCert != "" && len(c.AutocertDomains) > 0 {
+		problems = append(problems, fmt.Errorf("set TLS_CERT or AUTOCERT_DOMAINS, not both"))
+	}
+	if c.EmailDKIMPrivateKey != "" {
+		if _, err := email.NewDKIMSigner(c.EmailDKIMDomain, c.EmailDKIMSelector, []byte(c.EmailDKIMPrivateKey)); err != nil {
+			problems = append(problems, fmt.Errorf("EMAIL_DKIM_PRIVATE_KEY: %v", err))
+		}
+	} else if c.EmailDKIMSelector != "" || c.EmailDKIMDomain != "" {
+		problems = append(problems, fmt.Errorf("EMAIL_DKIM_PRIVATE_KEY is required to sign with DKIM"))
+	}
+	if c.BounceIMAPAddr != "" {
+		if _, _, err := net.SplitHostPort(c.BounceIMAPAddr); err != nil {
+			problems = append(problems, fmt.Errorf("BOUNCE_IMAP_ADDR: %q isn't a host and port such as imap.example.com:993", c.BounceIMAPAddr))
+		}
+	}
+	if c.OutboundProxy != "" {
+		if _, err := outbound.ParseProxy(c.OutboundProxy); err != nil {
+			problems = append(problems, fmt.Errorf("OUTBOUND_PROXY: %v", err))
+		}
+	}
+	switch c.RateLimitStore {
+	case "", "memory":
+	case "redis":
+		if c.RedisURL == "" {
+			problems = append(problems, fmt.Errorf("REDIS_URL is required when RATE_LIMIT_STORE is redis"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("RATE_LIMIT_STORE: unknown store %q (expected memory or redis)", c.RateLimitStore))
+	}
+	switch c.StorageBackend {
+	case "", "local":
+	case "s3":
+		if c.StorageS3Bucket == "" {
+			problems = append(problems, fmt.Errorf("STORAGE_S3_BUCKET is required when STORAGE_BACKEND is s3"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("STORAGE_BACKEND: unknown backend %q (expected local or s3)", c.StorageBackend))
+	}
+	if _, err := clientip.ParseProxies(c.TrustedProxies); err != nil {
+		problems = append(problems, fmt.Errorf("TRUSTED_PROXIES: %v", err))
+	}
+	if c.CompressionLevel > 9 {
+		problems = append(problems, fmt.Errorf("COMPRESSION_LEVEL: %d is over the highest level, 9", c.CompressionLevel))
+	}
+	return problems
 }
 
-// getEnvAsBool gets an environment variable as boolean with a fallback value
-func getEnvAsBool(key string, fallback bool) bool {
-	if value := os.Getenv(key); value != "" {
-		return strings.ToLower(value) == "true" || value == "1"
+// Changed lists the keys of the settings that differ between two
+// configurations, e.g. to report what reloading the configuration changed
+func Changed(before, after *Config) []string {
+	old, updated := settings(before), settings(after)
+	var keys []string
+	for i, s := range old {
+		if s.value.String() != updated[i].value.String() {
+			keys = append(keys, s.key)
+		}
 	}
-	return fallback
+	return keys
 }
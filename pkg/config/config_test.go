@@ -0,0 +1,315 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every setting's environment variables for the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv(FileEnv, "")
+	for _, s := range settings(Defaults()) {
+		for _, name := range s.env {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := Load(nil, io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Port != "8080" || cfg.EmailPort != 587 || !cfg.EmailUseTLS || cfg.BackupInterval != 24*time.Hour {
+		t.Errorf("Unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "staticsend.yaml", "port: 7000\nemail_host: file.example.com\nemail_from: file@example.com\n")
+	t.Setenv("EMAIL_HOST", "env.example.com")
+	t.Setenv("EMAIL_FROM", "env@example.com")
+
+	cfg, err := Load([]string{"-config", path, "-email-from", "flag@example.com"}, io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Port != "7000" {
+		t.Errorf("Expected the file's port, got %q", cfg.Port)
+	}
+	if cfg.EmailHost != "env.example.com" {
+		t.Errorf("Expected the environment's host, got %q", cfg.EmailHost)
+	}
+	if cfg.EmailFrom != "flag@example.com" {
+		t.Errorf("Expected the flag's from address, got %q", cfg.EmailFrom)
+	}
+}
+
+func TestLoad_YAML(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "staticsend.yml", `
+email:
+  host: smtp.example.com
+  port: 2525
+  use_tls: false
+backup_interval: 6h
+log_redact_keys: [phone, address]
+`)
+	t.Setenv(FileEnv, path)
+
+	cfg, err := Load(nil, io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.EmailHost != "smtp.example.com" || cfg.EmailPort != 2525 || cfg.EmailUseTLS {
+		t.Errorf("Unexpected email settings: %q %d %v", cfg.EmailHost, cfg.EmailPort, cfg.EmailUseTLS)
+	}
+	if cfg.BackupInterval != 6*time.Hour {
+		t.Errorf("Expected a 6h backup interval, got %s", cfg.BackupInterval)
+	}
+	if strings.Join(cfg.LogRedactKeys, ",") != "phone,address" {
+		t.Errorf("Unexpected redact keys: %v", cfg.LogRedactKeys)
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "staticsend.toml", `
+# Server
+port = 9000
+base_url = "https://forms.example.com" # where links point
+log_redact_keys = ["phone", 'a#b']
+
+[email]
+host = "smtp.example.com"
+use_tls = true
+
+[storage.s3]
+bucket = "uploads"
+`)
+
+	cfg, err := Load([]string{"-config=" + path}, io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Port != "9000" || cfg.BaseURL != "https://forms.example.com" {
+		t.Errorf("Unexpected server settings: %q %q", cfg.Port, cfg.BaseURL)
+	}
+	if cfg.EmailHost != "smtp.example.com" || !cfg.EmailUseTLS {
+		t.Errorf("Unexpected email settings: %q %v", cfg.EmailHost, cfg.EmailUseTLS)
+	}
+	if cfg.StorageS3Bucket != "uploads" {
+		t.Errorf("Expected the bucket from [storage.s3], got %q", cfg.StorageS3Bucket)
+	}
+	if strings.Join(cfg.LogRedactKeys, ",") != "phone,a#b" {
+		t.Errorf("Unexpected redact keys: %v", cfg.LogRedactKeys)
+	}
+}
+
+func TestLoad_LegacyNames(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("STATICSEND_PORT", "3000")
+	t.Setenv("STATICSEND_SMTP_HOST", "legacy.example.com")
+	t.Setenv("EMAIL_HOST", "smtp.example.com")
+
+	cfg, err := Load([]string{"-db", "/tmp/forms.db", "-dev-mode"}, io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Port != "3000" {
+		t.Errorf("Expected STATICSEND_PORT to set the port, got %q", cfg.Port)
+	}
+	if cfg.EmailHost != "smtp.example.com" {
+		t.Errorf("Expected EMAIL_HOST to win over STATICSEND_SMTP_HOST, got %q", cfg.EmailHost)
+	}
+	if cfg.DatabasePath != "/tmp/forms.db" {
+		t.Errorf("Expected -db to set the database path, got %q", cfg.DatabasePath)
+	}
+	if !cfg.DevMode {
+		t.Error("Expected -dev-mode alone to turn on development mode")
+	}
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "staticsend.yaml", "emial_host: typo.example.com\nbackup_interval: often\n")
+	t.Setenv("EMAIL_PORT", "smtp")
+	t.Setenv("STORAGE_BACKEND", "s3")
+	t.Setenv("EMAIL_DKIM_SELECTOR", "mail")
+
+	_, err := Load([]string{"-config", path, "-port", "70000", "-dev-mode=maybe"}, io.Discard)
+	if err == nil {
+		t.Fatal("Expected an invalid configuration to fail")
+	}
+	for _, want := range []string{"emial_host", "backup_interval", "EMAIL_PORT", "-dev-mode", `PORT: "70000"`, "STORAGE_S3_BUCKET", "EMAIL_DKIM_PRIVATE_KEY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %s, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	clearEnv(t)
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"unknown extension", "staticsend.ini", "port = 8080\n"},
+		{"bad yaml", "staticsend.yaml", "port: [8080\n"},
+		{"bad toml", "staticsend.toml", "port 8080\n"},
+		{"duplicate key", "staticsend.toml", "email_host = \"a\"\n[email]\nhost = \"b\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.file, tt.content)
+			if _, err := Load([]string{"-config", path}, io.Discard); err == nil {
+				t.Error("Expected the config file to be rejected")
+			}
+		})
+	}
+
+	if _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, io.Discard); err == nil {
+		t.Error("Expected a missing config file to fail")
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	clearEnv(t)
+
+	var usage strings.Builder
+	if _, err := Load([]string{"-help"}, &usage); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("Expected flag.ErrHelp, got %v", err)
+	}
+	if !strings.Contains(usage.String(), "-email-host") {
+		t.Errorf("Expected usage to list -email-host, got:\n%s", usage.String())
+	}
+}
+
+func TestLoad_Version(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SOCKET_MODE", "not octal")
+
+	// -version is still reported when the rest of the configuration is invalid
+	cfg, err := Load([]string{"-version"}, io.Discard)
+	if err == nil {
+		t.Error("Expected the invalid socket mode to be reported")
+	}
+	if cfg == nil || !cfg.ShowVersion {
+		t.Errorf("Expected ShowVersion to be set, got %+v", cfg)
+	}
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write secret: %v", err)
+		}
+		return path
+	}
+	t.Setenv("STATICSEND_SMTP_PASS_FILE", write("smtp", "hunter2\n"))
+	t.Setenv("JWT_SECRET_KEY_FILE", write("jwt", "signing-key"))
+	path := writeConfig(t, "staticsend.yaml", "akismet_api_key_file: "+write("akismet", "abc123\r\n")+"\n")
+
+	cfg, err := Load([]string{"-config", path}, io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.EmailPassword != "hunter2" {
+		t.Errorf("Expected the SMTP password from its file, got %q", cfg.EmailPassword)
+	}
+	if cfg.JWTSecretKey != "signing-key" {
+		t.Errorf("Expected the JWT secret from its file, got %q", cfg.JWTSecretKey)
+	}
+	if cfg.AkismetAPIKey != "abc123" {
+		t.Errorf("Expected the Akismet key from its file, got %q", cfg.AkismetAPIKey)
+	}
+
+	t.Run("not both", func(t *testing.T) {
+		t.Setenv("JWT_SECRET_KEY", "inline")
+		if _, err := Load(nil, io.Discard); err == nil || !strings.Contains(err.Error(), "JWT_SECRET_KEY_FILE") {
+			t.Errorf("Expected a variable and its file to conflict, got %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("JWT_SECRET_KEY_FILE", filepath.Join(dir, "missing"))
+		if _, err := Load(nil, io.Discard); err == nil {
+			t.Error("Expected a missing secret file to fail")
+		}
+	})
+
+	t.Run("not a secret", func(t *testing.T) {
+		path := writeConfig(t, "staticsend.yaml", "email_host_file: "+write("host", "smtp.example.com")+"\n")
+		if _, err := Load([]string{"-config", path}, io.Discard); err == nil {
+			t.Error("Expected _file to be refused for settings that aren't secrets")
+		}
+	})
+}
+
+func TestLoad_Listen(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		valid bool
+	}{
+		{"certificate", []string{"-tls-cert", "cert.pem", "-tls-key", "key.pem"}, true},
+		{"autocert", []string{"-autocert-domains", "forms.example.com,www.example.com"}, true},
+		{"certificate without key", []string{"-tls-cert", "cert.pem"}, false},
+		{"certificate and autocert", []string{"-tls-cert", "cert.pem", "-tls-key", "key.pem", "-autocert-domains", "forms.example.com"}, false},
+		{"base path", []string{"-base-path", "/forms/"}, true},
+		{"relative base path", []string{"-base-path", "forms"}, false},
+		{"unix socket", []string{"-listen-socket", "/run/staticsend.sock", "-socket-mode", "0600"}, true},
+		{"socket mode that isn't octal", []string{"-listen-socket", "/run/staticsend.sock", "-socket-mode", "rw-rw----"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			_, err := Load(tt.args, io.Discard)
+			if tt.valid && err != nil {
+				t.Errorf("Expected the settings to be valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected the settings to be rejected")
+			}
+		})
+	}
+}
+
+func TestLoad_Args(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := Load([]string{"-port", "9000", "db", "backup", "-db", "forms.db", "out.db.gz"}, io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if strings.Join(cfg.Args, " ") != "db backup out.db.gz" {
+		t.Errorf("Expected the command's arguments, got %v", cfg.Args)
+	}
+	if cfg.Port != "9000" || cfg.DatabasePath != "forms.db" {
+		t.Errorf("Expected flags on either side of the command, got %q and %q", cfg.Port, cfg.DatabasePath)
+	}
+}
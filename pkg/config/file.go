@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readFile reads a YAML or TOML config file, chosen by its extension, into
+// setting keys and their text. Nested tables are joined to their keys with
+// underscores, so email.host and email_host are the same setting, and lists
+// are joined with commas.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		tree, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("%s: config files must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten(values, "", tree); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten adds tree's values to values, with prefix before their keys
+func flatten(values map[string]string, prefix string, tree map[string]interface{}) error {
+	for key, item := range tree {
+		key = strings.ToLower(prefix + key)
+		if table, ok := item.(map[string]interface{}); ok {
+			if err := flatten(values, key+"_", table); err != nil {
+				return err
+			}
+			continue
+		}
+
+		text, ok := scalar(item)
+		if list, isList := item.([]interface{}); isList {
+			texts := make([]string, len(list))
+			ok = true
+			for i, element := range list {
+				if texts[i], ok = scalar(element); !ok {
+					break
+				}
+			}
+			text = strings.Join(texts, ",")
+		}
+		if !ok {
+			return fmt.Errorf("%s: unsupported value", key)
+		}
+		if _, dup := values[key]; dup {
+			return fmt.Errorf("%s is set twice", key)
+		}
+		values[key] = text
+	}
+	return nil
+}
+
+// scalar formats a plain file value as a setting's text
+func scalar(item interface{}) (string, bool) {
+	switch item := item.(type) {
+	case nil:
+		return "", true
+	case string:
+		return item, true
+	case bool:
+		return strconv.FormatBool(item), true
+	case int:
+		return strconv.Itoa(item), true
+	case int64:
+		return strconv.FormatInt(item, 10), true
+	case float64:
+		return strconv.FormatFloat(item, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// parseTOML reads the part of TOML that config files need: tables, strings,
+// numbers, booleans and single line arrays of those
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	table := root
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") || strings.HasPrefix(text, "[[") {
+				return nil, fmt.Errorf("line %d: unsupported table header %q", line, text)
+			}
+			table = root
+			for _, part := range strings.Split(strings.Trim(text, "[]"), ".") {
+				name, err := tomlKey(part)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				next, ok := table[name].(map[string]interface{})
+				if !ok {
+					if _, taken := table[name]; taken {
+						return nil, fmt.Errorf("line %d: %s is already a value", line, name)
+					}
+					next = map[string]interface{}{}
+					table[name] = next
+				}
+				table = next
+			}
+			continue
+		}
+
+		eq := strings.Index(text, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		key, err := tomlKey(text[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if _, taken := table[key]; taken {
+			return nil, fmt.Errorf("line %d: %s is set twice", line, key)
+		}
+		item, err := tomlValue(strings.TrimSpace(text[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", line, key, err)
+		}
+		table[key] = item
+	}
+	return root, scanner.Err()
+}
+
+// stripComment drops a # comment that isn't inside a string
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tomlKey reads a bare or quoted key
+func tomlKey(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		item, err := tomlValue(text)
+		if err != nil {
+			return "", err
+		}
+		key, _ := item.(string)
+		return key, nil
+	}
+	if text == "" || strings.ContainsAny(text, " \t.\"'") {
+		return "", fmt.Errorf("invalid key %q", text)
+	}
+	return text, nil
+}
+
+// tomlValue reads a string, number, boolean or array
+func tomlValue(text string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		if len(text) < 2 || !strings.HasSuffix(text, `"`) {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		return strconv.Unquote(text)
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") || strings.Contains(text[1:len(text)-1], "'") {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		return text[1 : len(text)-1], nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("arrays must be on one line")
+		}
+		items := []interface{}{}
+		for _, part := range splitArray(text[1 : len(text)-1]) {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			item, err := tomlValue(part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case text == "true":
+		return true, nil
+	case text == "false":
+		return false, nil
+	}
+	number := strings.ReplaceAll(text, "_", "")
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported value %q", text)
+}
+
+// splitArray splits an array's contents at the commas outside strings
+func splitArray(text string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, text[start:])
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting is one configuration value. It's read from key in the config
+// file, from the first of its environment variables that's set, and from
+// the -key flag with underscores as dashes. Later environment variables are
+// older names that still work.
+type setting struct {
+	key   string
+	env   []string
+	usage string
+	// alias is a shorter flag name kept from before every setting had one
+	alias string
+	// secret settings can also be read from a file named by their
+	// environment variable with _FILE added, or their key with _file
+	secret bool
+	value  value
+}
+
+// flags returns the setting's flag names
+func (s setting) flags() []string {
+	names := []string{strings.ReplaceAll(s.key, "_", "-")}
+	if s.alias != "" {
+		names = append(names, s.alias)
+	}
+	return names
+}
+
+// settings lists every setting, bound to its field of cfg
+func settings(cfg *Config) []setting {
+	return []setting{
+		{key: "port", env: []string{"PORT", "STATICSEND_PORT"}, usage: "Port to listen on", value: stringValue{&cfg.Port}},
+		{key: "listen_socket", env: []string{"LISTEN_SOCKET"}, usage: "Unix socket to listen on instead of the port", value: stringValue{&cfg.ListenSocket}},
+		{key: "socket_mode", env: []string{"SOCKET_MODE"}, usage: "Octal permissions for the Unix socket", value: stringValue{&cfg.SocketMode}},
+		{key: "base_url", env: []string{"BASE_URL", "STATICSEND_BASE_URL"}, usage: "URL the app is served from, for links in emails", value: stringValue{&cfg.BaseURL}},
+		{key: "base_path", env: []string{"BASE_PATH", "STATICSEND_BASE_PATH"}, usage: "Path prefix the app is served under behind a reverse proxy, e.g. /forms", value: stringValue{&cfg.BasePath}},
+		{key: "tls_cert", env: []string{"TLS_CERT"}, usage: "Certificate file to serve HTTPS with", value: stringValue{&cfg.TLSCert}},
+		{key: "tls_key", env: []string{"TLS_KEY"}, usage: "Private key file for the TLS certificate", secret: true, value: stringValue{&cfg.TLSKey}},
+		{key: "autocert_domains", env: []string{"AUTOCERT_DOMAINS"}, usage: "Comma separated domains to get Let's Encrypt certificates for", value: listValue{&cfg.AutocertDomains}},
+		{key: "autocert_email", env: []string{"AUTOCERT_EMAIL"}, usage: "Contact address for the Let's Encrypt account", value: stringValue{&cfg.AutocertEmail}},
+		{key: "autocert_cache_dir", env: []string{"AUTOCERT_CACHE_DIR"}, usage: "Directory Let's Encrypt certificates are kept in", value: stringValue{&cfg.AutocertCacheDir}},
+		{key: "database_path", env: []string{"DATABASE_PATH", "STATICSEND_DB_PATH"}, alias: "db", usage: "SQLite database file path", value: stringValue{&cfg.DatabasePath}},
+		{key: "database_dsn", env: []string{"STATICSEND_DB_DSN"}, usage: "PostgreSQL or MySQL connection string, used instead of SQLite", secret: true, value: stringValue{&cfg.DatabaseDSN}},
+		{key: "sqlite_journal_mode", env: []string{"SQLITE_JOURNAL_MODE"}, usage: "SQLite journal mode", value: stringValue{&cfg.SQLiteJournalMode}},
+		{key: "sqlite_busy_timeout", env: []string{"SQLITE_BUSY_TIMEOUT"}, usage: "How long SQLite waits for a lock", value: durationValue{&cfg.SQLiteBusyTimeout}},
+		{key: "sqlite_synchronous", env: []string{"SQLITE_SYNCHRONOUS"}, usage: "SQLite synchronous mode", value: stringValue{&cfg.SQLiteSynchronous}},
+		{key: "sqlite_max_open_conns", env: []string{"SQLITE_MAX_OPEN_CONNS"}, usage: "Most open SQLite connections", value: intValue{&cfg.SQLiteMaxOpenConns}},
+		{key: "slow_query_threshold", env: []string{"SLOW_QUERY_THRESHOLD"}, usage: "Queries slower than this are logged", value: durationValue{&cfg.SlowQueryThreshold}},
+		{key: "email_host", env: []string{"EMAIL_HOST", "STATICSEND_SMTP_HOST"}, usage: "SMTP server host", value: stringValue{&cfg.EmailHost}},
+		{key: "email_port", env: []string{"EMAIL_PORT", "STATICSEND_SMTP_PORT"}, usage: "SMTP server port", value: intValue{&cfg.EmailPort}},
+		{key: "email_username", env: []string{"EMAIL_USERNAME", "STATICSEND_SMTP_USER"}, usage: "SMTP username", value: stringValue{&cfg.EmailUsername}},
+		{key: "email_password", env: []string{"EMAIL_PASSWORD", "STATICSEND_SMTP_PASS"}, usage: "SMTP password", secret: true, value: stringValue{&cfg.EmailPassword}},
+		{key: "email_from", env: []string{"EMAIL_FROM", "STATICSEND_SMTP_FROM"}, usage: "Address emails are sent from", value: stringValue{&cfg.EmailFrom}},
+		{key: "email_use_tls", env: []string{"EMAIL_USE_TLS", "STATICSEND_SMTP_USE_TLS"}, usage: "Require STARTTLS for SMTP", value: boolValue{&cfg.EmailUseTLS}},
+		{key: "email_timeout", env: []string{"EMAIL_TIMEOUT"}, usage: "Time limit for sending an email", value: durationValue{&cfg.EmailTimeout}},
+		{key: "email_helo_name", env: []string{"EMAIL_HELO_NAME"}, usage: "Host name to greet the SMTP server with in HELO/EHLO", value: stringValue{&cfg.EmailHeloName}},
+		{key: "email_envelope_from", env: []string{"EMAIL_ENVELOPE_FROM"}, usage: "Envelope sender (MAIL FROM) bounces go to, instead of the from address", value: stringValue{&cfg.EmailEnvelopeFrom}},
+		{key: "email_dkim_domain", env: []string{"EMAIL_DKIM_DOMAIN"}, usage: "Domain emails are signed for with DKIM, instead of the from address's", value: stringValue{&cfg.EmailDKIMDomain}},
+		{key: "email_dkim_selector", env: []string{"EMAIL_DKIM_SELECTOR"}, usage: "Selector the DKIM public key is published under", value: stringValue{&cfg.EmailDKIMSelector}},
+		{key: "email_dkim_private_key", env: []string{"EMAIL_DKIM_PRIVATE_KEY"}, usage: "PEM encoded RSA or Ed25519 private key emails are signed with", secret: true, value: stringValue{&cfg.EmailDKIMPrivateKey}},
+		{key: "email_fallback_host", env: []string{"EMAIL_FALLBACK_HOST"}, usage: "SMTP server host emails fail over to when the primary keeps failing", value: stringValue{&cfg.EmailFallbackHost}},
+		{key: "email_fallback_port", env: []string{"EMAIL_FALLBACK_PORT"}, usage: "Fallback SMTP server port", value: intValue{&cfg.EmailFallbackPort}},
+		{key: "email_fallback_username", env: []string{"EMAIL_FALLBACK_USERNAME"}, usage: "Fallback SMTP username", value: stringValue{&cfg.EmailFallbackUsername}},
+		{key: "email_fallback_password", env: []string{"EMAIL_FALLBACK_PASSWORD"}, usage: "Fallback SMTP password", secret: true, value: stringValue{&cfg.EmailFallbackPassword}},
+		{key: "email_fallback_use_tls", env: []string{"EMAIL_FALLBACK_USE_TLS"}, usage: "Require STARTTLS for the fallback SMTP server", value: boolValue{&cfg.EmailFallbackUseTLS}},
+		{key: "email_failover_after", env: []string{"EMAIL_FAILOVER_AFTER"}, usage: "Failed sends in a row before failing over to the fallback SMTP server", value: intValue{&cfg.EmailFailoverAfter}},
+		{key: "email_failback_after", env: []string{"EMAIL_FAILBACK_AFTER"}, usage: "Time after failing over before the primary SMTP server is tried again", value: durationValue{&cfg.EmailFailbackAfter}},
+		{key: "email_alert_queue_depth", env: []string{"EMAIL_ALERT_QUEUE_DEPTH"}, usage: "Emails waiting in the queue that alert the administrators (0 turns it off)", value: intValue{&cfg.EmailAlertQueueDepth}},
+		{key: "email_alert_oldest_age", env: []string{"EMAIL_ALERT_OLDEST_AGE"}, usage: "How long an unsent email waits before alerting the administrators (0 turns it off)", value: durationValue{&cfg.EmailAlertOldestAge}},
+		{key: "email_alert_failure_percent", env: []string{"EMAIL_ALERT_FAILURE_PERCENT"}, usage: "Percentage of recent emails failing that alerts the administrators (0 turns it off)", value: intValue{&cfg.EmailAlertFailurePercent}},
+		{key: "email_alert_interval", env: []string{"EMAIL_ALERT_INTERVAL"}, usage: "Time between checks of the email queue against the alert thresholds (0 turns them off)", value: durationValue{&cfg.EmailAlertInterval}},
+		{key: "alert_webhook_url", env: []string{"ALERT_WEBHOOK_URL"}, usage: "URL administrator alerts are also posted to as JSON", secret: true, value: stringValue{&cfg.AlertWebhookURL}},
+		{key: "metrics_token", env: []string{"METRICS_TOKEN"}, usage: "Bearer token for scraping /metrics, which isn't served without one", secret: true, value: stringValue{&cfg.MetricsToken}},
+		{key: "bounce_webhook_token", env: []string{"BOUNCE_WEBHOOK_TOKEN"}, usage: "Secret in the bounce webhook URLs given to the email provider", secret: true, value: stringValue{&cfg.BounceWebhookToken}},
+		{key: "bounce_imap_addr", env: []string{"BOUNCE_IMAP_ADDR"}, usage: "IMAP server host and port of the mailbox bounces go to", value: stringValue{&cfg.BounceIMAPAddr}},
+		{key: "bounce_imap_username", env: []string{"BOUNCE_IMAP_USERNAME"}, usage: "Username for the bounce mailbox", value: stringValue{&cfg.BounceIMAPUsername}},
+		{key: "bounce_imap_password", env: []string{"BOUNCE_IMAP_PASSWORD"}, usage: "Password for the bounce mailbox", secret: true, value: stringValue{&cfg.BounceIMAPPassword}},
+		{key: "bounce_imap_mailbox", env: []string{"BOUNCE_IMAP_MAILBOX"}, usage: "Folder bounces are read from", value: stringValue{&cfg.BounceIMAPMailbox}},
+		{key: "bounce_imap_tls", env: []string{"BOUNCE_IMAP_TLS"}, usage: "Connect to the IMAP server with TLS", value: boolValue{&cfg.BounceIMAPTLS}},
+		{key: "bounce_imap_interval", env: []string{"BOUNCE_IMAP_INTERVAL"}, usage: "Time between checks of the bounce mailbox", value: durationValue{&cfg.BounceIMAPInterval}},
+		{key: "turnstile_public_key", env: []string{"TURNSTILE_PUBLIC_KEY"}, usage: "Turnstile site key for the login and registration pages", value: stringValue{&cfg.TurnstilePublicKey}},
+		{key: "turnstile_secret_key", env: []string{"TURNSTILE_SECRET_KEY", "STATICSEND_TURNSTILE_SECRET"}, usage: "Turnstile secret key for the login and registration pages", secret: true, value: stringValue{&cfg.TurnstileSecretKey}},
+		{key: "turnstile_verify_url", env: []string{"TURNSTILE_VERIFY_URL", "STATICSEND_TURNSTILE_VERIFY_URL"}, usage: "Endpoint Turnstile tokens are verified with", value: stringValue{&cfg.TurnstileVerifyURL}},
+		{key: "turnstile_timeout", env: []string{"TURNSTILE_TIMEOUT"}, usage: "Time limit for verifying a Turnstile token", value: durationValue{&cfg.TurnstileTimeout}},
+		{key: "jwt_secret_key", env: []string{"JWT_SECRET_KEY", "STATICSEND_JWT_SECRET"}, usage: "Secret that signs sessions and download links", secret: true, value: stringValue{&cfg.JWTSecretKey}},
+		{key: "jwt_secret_path", env: []string{"JWT_SECRET_PATH"}, usage: "Where the generated JWT secret is kept when none is set", value: stringValue{&cfg.JWTSecretPath}},
+		{key: "secrets_key", env: []string{"SECRETS_KEY"}, usage: "Master key that encrypts third-party credentials in the database", secret: true, value: stringValue{&cfg.SecretsKey}},
+		{key: "secrets_key_path", env: []string{"SECRETS_KEY_PATH"}, usage: "Where the generated secrets key is kept when none is set", value: stringValue{&cfg.SecretsKeyPath}},
+		{key: "registration_enabled", env: []string{"REGISTRATION_ENABLED"}, usage: "Allow new accounts to register", value: boolValue{&cfg.RegistrationEnabled}},
+		{key: "trusted_proxies", env: []string{"TRUSTED_PROXIES"}, usage: "Comma separated addresses or CIDR ranges of proxies whose forwarding headers give the client's address", value: listValue{&cfg.TrustedProxies}},
+		{key: "rate_limit_store", env: []string{"RATE_LIMIT_STORE"}, usage: "Where rate limits are counted: memory or redis", value: stringValue{&cfg.RateLimitStore}},
+		{key: "redis_url", env: []string{"REDIS_URL"}, usage: "Redis URL for the redis rate limit store", secret: true, value: stringValue{&cfg.RedisURL}},
+		{key: "log_redact_keys", env: []string{"LOG_REDACT_KEYS"}, usage: "Comma separated extra field names to redact from logs", value: listValue{&cfg.LogRedactKeys}},
+		{key: "compression_level", env: []string{"COMPRESSION_LEVEL"}, usage: "Response compression level, 0 to turn it off", value: intValue{&cfg.CompressionLevel}},
+		{key: "max_concurrent_submissions", env: []string{"MAX_CONCURRENT_SUBMISSIONS"}, usage: "Submissions processed at once", value: intValue{&cfg.MaxConcurrentSubmissions}},
+		{key: "max_queued_submissions", env: []string{"MAX_QUEUED_SUBMISSIONS"}, usage: "Submissions waiting their turn before more are turned away", value: intValue{&cfg.MaxQueuedSubmissions}},
+		{key: "submission_queue_timeout", env: []string{"SUBMISSION_QUEUE_TIMEOUT"}, usage: "How long a submission waits its turn", value: durationValue{&cfg.SubmissionQueueTimeout}},
+		{key: "max_concurrent_downloads", env: []string{"MAX_CONCURRENT_DOWNLOADS"}, usage: "Archive, export and backup downloads streamed at once", value: intValue{&cfg.MaxConcurrentDownloads}},
+		{key: "read_timeout", env: []string{"READ_TIMEOUT"}, usage: "Time limit for reading a request", value: durationValue{&cfg.ReadTimeout}},
+		{key: "write_timeout", env: []string{"WRITE_TIMEOUT"}, usage: "Time limit for writing a response", value: durationValue{&cfg.WriteTimeout}},
+		{key: "idle_timeout", env: []string{"IDLE_TIMEOUT"}, usage: "How long idle connections are kept open", value: durationValue{&cfg.IdleTimeout}},
+		{key: "handler_timeout", env: []string{"HANDLER_TIMEOUT"}, usage: "Time limit for handling a request", value: durationValue{&cfg.HandlerTimeout}},
+		{key: "download_timeout", env: []string{"DOWNLOAD_TIMEOUT"}, usage: "Time limit for streaming a download", value: durationValue{&cfg.DownloadTimeout}},
+		{key: "templates_dir", env: []string{"TEMPLATES_DIR"}, usage: "Templates directory, instead of the built in templates", value: stringValue{&cfg.TemplatesDir}},
+		{key: "dev_mode", env: []string{"DEV_MODE"}, usage: "Reload templates from disk when they change", value: boolValue{&cfg.DevMode}},
+		{key: "debug", env: []string{"STATICSEND_DEBUG"}, usage: "Serve profiling and diagnostics under /debug to administrators", value: boolValue{&cfg.Debug}},
+		{key: "migrations_dir", env: []string{"MIGRATIONS_DIR"}, usage: "Migrations directory, instead of the built in migrations", value: stringValue{&cfg.MigrationsDir}},
+		{key: "static_dir", env: []string{"STATIC_DIR"}, usage: "Static files directory, instead of the built in files", value: stringValue{&cfg.StaticDir}},
+		{key: "backup_interval", env: []string{"BACKUP_INTERVAL"}, usage: "Time between SQLite backups, 0 to turn them off", value: durationValue{&cfg.BackupInterval}},
+		{key: "backup_dir", env: []string{"BACKUP_DIR"}, usage: "Directory for backups without a bucket", value: stringValue{&cfg.BackupDir}},
+		{key: "backup_retention", env: []string{"BACKUP_RETENTION"}, usage: "Backups kept", value: intValue{&cfg.BackupRetention}},
+		{key: "storage_backend", env: []string{"STORAGE_BACKEND"}, usage: "Where files are kept: local or s3", value: stringValue{&cfg.StorageBackend}},
+		{key: "storage_s3_bucket", env: []string{"STORAGE_S3_BUCKET", "BACKUP_S3_BUCKET"}, usage: "S3 bucket for files", value: stringValue{&cfg.StorageS3Bucket}},
+		{key: "storage_s3_endpoint", env: []string{"STORAGE_S3_ENDPOINT", "BACKUP_S3_ENDPOINT"}, usage: "S3 endpoint", value: stringValue{&cfg.StorageS3Endpoint}},
+		{key: "storage_s3_region", env: []string{"STORAGE_S3_REGION", "BACKUP_S3_REGION"}, usage: "S3 region", value: stringValue{&cfg.StorageS3Region}},
+		{key: "storage_s3_prefix", env: []string{"STORAGE_S3_PREFIX", "BACKUP_S3_PREFIX"}, usage: "Prefix for files' keys in the bucket", value: stringValue{&cfg.StorageS3Prefix}},
+		{key: "storage_s3_access_key", env: []string{"STORAGE_S3_ACCESS_KEY", "BACKUP_S3_ACCESS_KEY"}, usage: "S3 access key", secret: true, value: stringValue{&cfg.StorageS3AccessKey}},
+		{key: "storage_s3_secret_key", env: []string{"STORAGE_S3_SECRET_KEY", "BACKUP_S3_SECRET_KEY"}, usage: "S3 secret key", secret: true, value: stringValue{&cfg.StorageS3SecretKey}},
+		{key: "storage_s3_insecure", env: []string{"STORAGE_S3_INSECURE"}, usage: "Connect to S3 over plain HTTP", value: boolValue{&cfg.StorageS3Insecure}},
+		{key: "storage_link_ttl", env: []string{"STORAGE_LINK_TTL"}, usage: "How long download links work", value: durationValue{&cfg.StorageLinkTTL}},
+		{key: "upload_dir", env: []string{"UPLOAD_DIR"}, usage: "Directory for uploaded files without a bucket", value: stringValue{&cfg.UploadDir}},
+		{key: "upload_max_size_mb", env: []string{"UPLOAD_MAX_SIZE_MB"}, usage: "Largest submission with files, in MB", value: intValue{&cfg.UploadMaxSizeMB}},
+		{key: "upload_email_link_ttl", env: []string{"UPLOAD_EMAIL_LINK_TTL"}, usage: "How long links to uploaded files in notification emails work (0 leaves them out)", value: durationValue{&cfg.UploadEmailLinkTTL}},
+		{key: "archive_after_days", env: []string{"ARCHIVE_AFTER_DAYS"}, usage: "Archive submissions older than this many days, 0 to keep them", value: intValue{&cfg.ArchiveAfterDays}},
+		{key: "archive_interval", env: []string{"ARCHIVE_INTERVAL"}, usage: "Time between archive runs", value: durationValue{&cfg.ArchiveInterval}},
+		{key: "archive_dir", env: []string{"ARCHIVE_DIR"}, usage: "Directory for archives without a bucket", value: stringValue{&cfg.ArchiveDir}},
+		{key: "export_dir", env: []string{"EXPORT_DIR"}, usage: "Directory for exports without a bucket", value: stringValue{&cfg.ExportDir}},
+		{key: "export_interval", env: []string{"EXPORT_INTERVAL"}, usage: "How often the export worker looks for jobs", value: durationValue{&cfg.ExportInterval}},
+		{key: "export_link_ttl", env: []string{"EXPORT_LINK_TTL"}, usage: "How long emailed export links work", value: durationValue{&cfg.ExportLinkTTL}},
+		{key: "sheets_sync_interval", env: []string{"SHEETS_SYNC_INTERVAL"}, usage: "Time between Google Sheets syncs", value: durationValue{&cfg.SheetsSyncInterval}},
+		{key: "health_min_free_disk_mb", env: []string{"HEALTH_MIN_FREE_DISK_MB"}, usage: "Free disk space below which readiness fails, in MB", value: intValue{&cfg.HealthMinFreeDiskMB}},
+		{key: "health_check_email", env: []string{"HEALTH_CHECK_EMAIL"}, usage: "Connect to the SMTP server in readiness checks", value: boolValue{&cfg.HealthCheckEmail}},
+		{key: "integrity_check_interval", env: []string{"INTEGRITY_CHECK_INTERVAL"}, usage: "Time between integrity checks, 0 to turn them off", value: durationValue{&cfg.IntegrityCheckInterval}},
+		{key: "integrity_auto_repair", env: []string{"INTEGRITY_AUTO_REPAIR"}, usage: "Repair problems integrity checks find", value: boolValue{&cfg.IntegrityAutoRepair}},
+		{key: "velocity_check_interval", env: []string{"VELOCITY_CHECK_INTERVAL"}, usage: "Time between checks for spikes in submissions, 0 to turn them off", value: durationValue{&cfg.VelocityCheckInterval}},
+		{key: "velocity_window", env: []string{"VELOCITY_WINDOW"}, usage: "Period recent submissions are counted over for spikes", value: durationValue{&cfg.VelocityWindow}},
+		{key: "velocity_factor", env: []string{"VELOCITY_FACTOR"}, usage: "How many times the usual number of submissions is a spike", value: intValue{&cfg.VelocityFactor}},
+		{key: "velocity_min_submissions", env: []string{"VELOCITY_MIN_SUBMISSIONS"}, usage: "Fewest submissions in the window that can be a spike", value: intValue{&cfg.VelocityMinSubmissions}},
+		{key: "akismet_api_key", env: []string{"AKISMET_API_KEY"}, usage: "Akismet API key for per-form spam checks", secret: true, value: stringValue{&cfg.AkismetAPIKey}},
+		{key: "geoip_database", env: []string{"GEOIP_DATABASE"}, usage: "MaxMind GeoLite2 Country or City database for submissions' countries", value: stringValue{&cfg.GeoIPDatabase}},
+		{key: "special_fields", env: []string{"SPECIAL_FIELDS"}, usage: "Comma separated renamed special fields, such as next=_redirect,honeypot=website", value: listValue{&cfg.SpecialFields}},
+		{key: "submission_hook_commands", env: []string{"SUBMISSION_HOOK_COMMANDS"}, usage: "Comma separated commands each submission is given to as JSON before it's stored", value: listValue{&cfg.SubmissionHookCommands}},
+		{key: "submission_hook_timeout", env: []string{"SUBMISSION_HOOK_TIMEOUT"}, usage: "Time limit for each submission hook command", value: durationValue{&cfg.SubmissionHookTimeout}},
+		{key: "outbound_proxy", env: []string{"OUTBOUND_PROXY"}, usage: "HTTP or SOCKS5 proxy for Turnstile, other outbound requests and SMTP", secret: true, value: stringValue{&cfg.OutboundProxy}},
+		{key: "log_file", env: []string{"LOG_FILE"}, usage: "File to append logs to instead of standard error, reopened on SIGHUP", value: stringValue{&cfg.LogFile}},
+		{key: "settings_reload_interval", env: []string{"SETTINGS_RELOAD_INTERVAL"}, usage: "How often settings saved in the database are reloaded, 0 to only reload after saving", value: durationValue{&cfg.SettingsReloadInterval}},
+	}
+}
+
+// value parses text into a setting's field, and formats the field for
+// comparison
+type value interface {
+	Set(text string) error
+	String() string
+}
+
+type stringValue struct{ field *string }
+
+func (v stringValue) Set(text string) error {
+	*v.field = text
+	return nil
+}
+
+func (v stringValue) String() string { return *v.field }
+
+type intValue struct{ field *int }
+
+func (v intValue) Set(text string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return fmt.Errorf("%q isn't a whole number", text)
+	}
+	if n < 0 {
+		return fmt.Errorf("%d can't be negative", n)
+	}
+	*v.field = n
+	return nil
+}
+
+func (v intValue) String() string { return strconv.Itoa(*v.field) }
+
+type boolValue struct{ field *bool }
+
+func (v boolValue) Set(text string) error {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "true", "1", "yes", "on":
+		*v.field = true
+	case "false", "0", "no", "off":
+		*v.field = false
+	default:
+		return fmt.Errorf("%q isn't true or false", text)
+	}
+	return nil
+}
+
+func (v boolValue) String() string { return strconv.FormatBool(*v.field) }
+
+type durationValue struct{ field *time.Duration }
+
+func (v durationValue) Set(text string) error {
+	text = strings.TrimSpace(text)
+	// A bare 0 turns off the intervals that allow it
+	if text == "0" {
+		*v.field = 0
+		return nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("%q isn't a duration such as 30s or 5m", text)
+	}
+	if d < 0 {
+		return fmt.Errorf("%s can't be negative", d)
+	}
+	*v.field = d
+	return nil
+}
+
+func (v durationValue) String() string { return v.field.String() }
+
+type listValue struct{ field *[]string }
+
+func (v listValue) Set(text string) error {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v.field = items
+	return nil
+}
+
+func (v listValue) String() string { return strings.Join(*v.field, ",") }
+
+// flagValue records a flag's text, to be set once the environment and
+// config file have been read
+type flagValue struct {
+	name    string
+	given   map[string]string
+	boolean bool
+}
+
+func (f flagValue) String() string { return "" }
+
+// IsBoolFlag lets true/false settings be given as just -name
+func (f flagValue) IsBoolFlag() bool { return f.boolean }
+
+func (f flagValue) Set(text string) error {
+	f.given[f.name] = text
+	return nil
+}
+
+// sortedKeys returns a map's keys in order, so problems are reported in the
+// same order every time
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrNotSQLite is returned by BackupTo and RestoreFrom for other engines,
+// which should be backed up with their own tooling
+var ErrNotSQLite = errors.New("only SQLite databases can be backed up and restored")
+
+// BackupTo copies the database to a new SQLite file at path with SQLite's
+// online backup API, which gives a consistent copy while the database is in
+// use. It won't overwrite an existing file.
+func (d *Database) BackupTo(ctx context.Context, path string) error {
+	if d.Dialect != SQLite && d.Dialect != "" {
+		return ErrNotSQLite
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	dst, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer dst.Close()
+
+	if err := copySQLite(ctx, dst, d.Connection); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// RestoreFrom replaces the database's contents with the SQLite file at
+// path, after checking the file is an intact database. Older backups are
+// brought up to date by the migrations run at the next start.
+func (d *Database) RestoreFrom(ctx context.Context, path string) error {
+	if d.Dialect != SQLite && d.Dialect != "" {
+		return ErrNotSQLite
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+
+	var result string
+	if err := src.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("%s isn't a SQLite database: %w", path, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%s is damaged: %s", path, result)
+	}
+
+	return copySQLite(ctx, d.Connection, src)
+}
+
+// copySQLite copies every page of src's main database into dst's
+func copySQLite(ctx context.Context, dst, src *sql.DB) error {
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer dstConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dstDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			to, ok := sqliteConn(dstDriver)
+			from, ok2 := sqliteConn(srcDriver)
+			if !ok || !ok2 {
+				return ErrNotSQLite
+			}
+
+			backup, err := to.Backup("main", from, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start copy: %w", err)
+			}
+			// A step of -1 copies every page at once
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return fmt.Errorf("failed to copy database: %w", err)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish copy: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// sqliteConn unwraps the driver's connection from the query logger's
+func sqliteConn(conn interface{}) (*sqlite3.SQLiteConn, bool) {
+	if logged, ok := conn.(*loggingConn); ok {
+		conn = logged.Conn
+	}
+	sqlite, ok := conn.(*sqlite3.SQLiteConn)
+	return sqlite, ok
+}
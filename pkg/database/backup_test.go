@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := Init(filepath.Join(dir, "live.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Connection.Exec("INSERT INTO users (email, password_hash) VALUES ('kept@example.com', 'x')"); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := db.BackupTo(ctx, backupPath); err != nil {
+		t.Fatalf("BackupTo failed: %v", err)
+	}
+	if err := db.BackupTo(ctx, backupPath); err == nil {
+		t.Error("Expected BackupTo not to overwrite an existing file")
+	}
+
+	if _, err := db.Connection.Exec("INSERT INTO users (email, password_hash) VALUES ('lost@example.com', 'x')"); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	if err := db.RestoreFrom(ctx, backupPath); err != nil {
+		t.Fatalf("RestoreFrom failed: %v", err)
+	}
+
+	var emails []string
+	rows, err := db.Connection.Query("SELECT email FROM users ORDER BY email")
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			t.Fatalf("Failed to scan user: %v", err)
+		}
+		emails = append(emails, email)
+	}
+	if len(emails) != 1 || emails[0] != "kept@example.com" {
+		t.Errorf("Expected only the backed up user after restoring, got %v", emails)
+	}
+
+	notDB := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notDB, []byte("not a database, just some text that is long enough"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RestoreFrom(ctx, notDB); err == nil {
+		t.Error("Expected restoring a file that isn't a database to fail")
+	}
+	if err := db.RestoreFrom(ctx, filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("Expected restoring a missing file to fail")
+	}
+
+	if err := (&Database{Dialect: Postgres}).BackupTo(ctx, filepath.Join(dir, "pg.db")); err != ErrNotSQLite {
+		t.Errorf("Expected ErrNotSQLite for PostgreSQL, got %v", err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	db, err := OpenWithOptions(filepath.Join(t.TempDir(), "test.db"), DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	states, err := db.Migrations(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list migrations: %v", err)
+	}
+	if len(states) != len(migrations) {
+		t.Fatalf("Expected %d migrations, got %d", len(migrations), len(states))
+	}
+	for _, state := range states {
+		if state.Applied {
+			t.Errorf("Expected migration %03d to be pending before migrating", state.Version)
+		}
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	states, err = db.Migrations(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list migrations: %v", err)
+	}
+	for _, state := range states {
+		if !state.Applied {
+			t.Errorf("Expected migration %03d (%s) to be applied", state.Version, state.Name)
+		}
+	}
+}
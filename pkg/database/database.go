@@ -1,138 +1,518 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 
 	_ "github.com/mattn/go-sqlite3"
+	"staticsend"
 )
 
-// Database represents the database connection
+// Database represents the database connection. It is created once at
+// startup and passed to the handlers and middleware that need it.
 type Database struct {
 	Connection *sql.DB
+	// Dialect is the engine behind Connection; the zero value means SQLite
+	Dialect Dialect
+	// Path is the SQLite database file; it is empty for other engines
+	Path string
+
+	queryLog *queryLogger
+}
+
+// Init opens a SQLite database with the default settings and runs migrations
+func Init(dbPath string) (*Database, error) {
+	return InitWithOptions(dbPath, DefaultSQLiteOptions())
 }
 
-// DB is the global database connection
-var DB *sql.DB
+// InitWithOptions opens a SQLite database and runs migrations
+func InitWithOptions(dbPath string, opts SQLiteOptions) (*Database, error) {
+	database, err := openSQLite(dbPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run migrations
+	if err := database.Migrate(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return database, nil
+}
 
-// Init initializes the database connection and runs migrations
-func Init(dbPath string) error {
+// openSQLite opens a SQLite database without running migrations
+func openSQLite(dbPath string, opts SQLiteOptions) (*Database, error) {
 	// Ensure the directory exists
 	dir := filepath.Dir(dbPath)
 	log.Printf("Creating database directory: %s", dir)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create database directory: %w", err)
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
 	// Check if directory is writable
 	testFile := filepath.Join(dir, ".write_test")
 	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
-		return fmt.Errorf("database directory is not writable: %w", err)
+		return nil, fmt.Errorf("database directory is not writable: %w", err)
 	}
 	os.Remove(testFile)
 
+	source, err := opts.dataSource(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
 	log.Printf("Opening database at: %s", dbPath)
 	// Open database connection
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	db, queryLog, err := openDB("sqlite3", source)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return fmt.Errorf("failed to enable foreign keys: %w", err)
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+		db.SetMaxIdleConns(opts.MaxOpenConns)
 	}
 
 	// Test connection
 	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	DB = db
-	log.Printf("Database connected: %s", dbPath)
-
-	// Run migrations
-	if err := runMigrations(); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	// WAL isn't available on every file system, so report what SQLite chose
+	var journalMode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read journal mode: %w", err)
 	}
 
-	return nil
+	database := &Database{Connection: db, Dialect: SQLite, Path: dbPath, queryLog: queryLog}
+	log.Printf("Database connected: %s (journal_mode=%s)", dbPath, journalMode)
+	return database, nil
 }
 
-// runMigrations executes database migrations
-func runMigrations() error {
-	// Check if users table exists to determine if migrations are needed
-	var tableName string
-	err := DB.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='users'").Scan(&tableName)
+// Connect opens the database described by a DSN and runs migrations.
+// postgres:// DSNs use PostgreSQL; anything else is a SQLite file path.
+func Connect(dsn string) (*Database, error) {
+	return ConnectWithOptions(dsn, DefaultSQLiteOptions())
+}
 
-	if err == sql.ErrNoRows {
-		// Tables don't exist, run initial migration
-		log.Println("Running initial database migration...")
-		migrationSQL, err := os.ReadFile("migrations/001_initial_schema.up.sql")
-		if err != nil {
-			return fmt.Errorf("failed to read migration file: %w", err)
-		}
+// ConnectWithOptions is like Connect, applying opts when the DSN is SQLite
+func ConnectWithOptions(dsn string, opts SQLiteOptions) (*Database, error) {
+	database, err := OpenWithOptions(dsn, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		if _, err := DB.Exec(string(migrationSQL)); err != nil {
-			return fmt.Errorf("failed to execute migration: %w", err)
-		}
+	if err := database.Migrate(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
 
-		log.Println("Initial migration completed successfully")
-	} else if err != nil {
-		return fmt.Errorf("failed to check for existing tables: %w", err)
+	return database, nil
+}
+
+// OpenWithOptions is like ConnectWithOptions but leaves migrations to the
+// caller, e.g. to report which are pending
+func OpenWithOptions(dsn string, opts SQLiteOptions) (*Database, error) {
+	dialect, source, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == SQLite {
+		return openSQLite(source, opts)
 	}
 
-	// Check if app_settings table exists to determine if we need to run the second migration
-	var settingsTableName string
-	err = DB.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='app_settings'").Scan(&settingsTableName)
+	log.Printf("Opening %s database", dialect)
+	db, queryLog, err := openDB(dialect.DriverName(), source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
 
-	if err == sql.ErrNoRows {
-		// app_settings table doesn't exist, run second migration
-		log.Println("Running app settings migration...")
-		migrationSQL, err := os.ReadFile("migrations/002_app_settings.up.sql")
-		if err != nil {
-			return fmt.Errorf("failed to read migration file: %w", err)
-		}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
 
-		if _, err := DB.Exec(string(migrationSQL)); err != nil {
-			return fmt.Errorf("failed to execute migration: %w", err)
-		}
+	database := &Database{Connection: db, Dialect: dialect, queryLog: queryLog}
+	log.Printf("Database connected: %s", dialect)
+	return database, nil
+}
+
+// migration describes a schema migration file. Check builds a query that
+// returns a row when the migration has already been applied, so databases
+// are upgraded based on their actual schema.
+type migration struct {
+	Version int
+	Name    string
+	File    string
+	Check   func(d Dialect) string
+}
+
+// tableExists checks that a migration's table has been created
+func tableExists(table string) func(d Dialect) string {
+	return func(d Dialect) string { return d.TableExistsQuery(table) }
+}
 
-		log.Println("App settings migration completed successfully")
-	} else if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to check for app_settings table: %w", err)
+// columnExists checks that a migration's column has been added
+func columnExists(table, column string) func(d Dialect) string {
+	return func(d Dialect) string { return d.ColumnExistsQuery(table, column) }
+}
+
+// indexExists checks that a migration's index has been created
+func indexExists(index string) func(d Dialect) string {
+	return func(d Dialect) string { return d.IndexExistsQuery(index) }
+}
+
+// settingExists checks that a migration's app setting has been inserted
+func settingExists(key string) func(d Dialect) string {
+	return func(d Dialect) string {
+		return fmt.Sprintf(`SELECT "key" FROM app_settings WHERE "key" = '%s'`, key)
 	}
+}
 
-	// Check if forms table has the new domain column to determine if we need to run the third migration
-	var domainColumn string
-	err = DB.QueryRow("SELECT name FROM pragma_table_info('forms') WHERE name = 'domain'").Scan(&domainColumn)
-	if err == sql.ErrNoRows {
-		// forms table doesn't have domain column, run third migration
-		log.Println("Running form schema update migration...")
-		migrationSQL, err := os.ReadFile("migrations/003_update_form_schema.up.sql")
+// migrations lists the schema migrations in the order they must be applied
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "initial database",
+		File:    "001_initial_schema.up.sql",
+		Check:   tableExists("users"),
+	},
+	{
+		Version: 2,
+		Name:    "app settings",
+		File:    "002_app_settings.up.sql",
+		Check:   tableExists("app_settings"),
+	},
+	{
+		Version: 3,
+		Name:    "form schema update",
+		File:    "003_update_form_schema.up.sql",
+		Check:   columnExists("forms", "domain"),
+	},
+	{
+		Version: 5,
+		Name:    "IP rules",
+		File:    "005_ip_rules.up.sql",
+		Check:   tableExists("ip_rules"),
+	},
+	{
+		Version: 6,
+		Name:    "maintenance mode",
+		File:    "006_maintenance_mode.up.sql",
+		Check:   settingExists("maintenance_mode"),
+	},
+	{
+		Version: 7,
+		Name:    "user roles",
+		File:    "007_user_roles.up.sql",
+		Check:   columnExists("users", "role"),
+	},
+	{
+		Version: 8,
+		Name:    "query indexes",
+		File:    "008_query_indexes.up.sql",
+		Check:   indexExists("idx_submissions_form_id_created_at"),
+	},
+	{
+		Version: 9,
+		Name:    "submission archives",
+		File:    "009_submission_archives.up.sql",
+		Check:   tableExists("submission_archives"),
+	},
+	{
+		Version: 10,
+		Name:    "user quotas",
+		File:    "010_user_quotas.up.sql",
+		Check:   tableExists("user_quotas"),
+	},
+	{
+		Version: 11,
+		Name:    "form tags",
+		File:    "011_form_tags.up.sql",
+		Check:   tableExists("form_tags"),
+	},
+	{
+		Version: 12,
+		Name:    "submission inbox",
+		File:    "012_submission_inbox.up.sql",
+		Check:   tableExists("submission_notes"),
+	},
+	{
+		Version: 13,
+		Name:    "submission columns",
+		File:    "013_submission_columns.up.sql",
+		Check:   tableExists("submission_columns"),
+	},
+	{
+		Version: 14,
+		Name:    "user theme",
+		File:    "014_user_theme.up.sql",
+		Check:   columnExists("users", "theme"),
+	},
+	{
+		Version: 15,
+		Name:    "submission details",
+		File:    "015_submission_details.up.sql",
+		Check:   columnExists("submissions", "spam_at"),
+	},
+	{
+		Version: 16,
+		Name:    "setup settings",
+		File:    "016_setup_settings.up.sql",
+		Check:   settingExists("smtp_host"),
+	},
+	{
+		Version: 17,
+		Name:    "branding",
+		File:    "017_branding.up.sql",
+		Check:   tableExists("site_assets"),
+	},
+	{
+		Version: 18,
+		Name:    "form status pages",
+		File:    "018_form_status_pages.up.sql",
+		Check:   tableExists("form_status_pages"),
+	},
+	{
+		Version: 19,
+		Name:    "spam queue",
+		File:    "019_spam_queue.up.sql",
+		Check:   columnExists("submissions", "spam_reason"),
+	},
+	{
+		Version: 20,
+		Name:    "export jobs",
+		File:    "020_export_jobs.up.sql",
+		Check:   tableExists("export_jobs"),
+	},
+	{
+		Version: 21,
+		Name:    "notification channels",
+		File:    "021_notification_channels.up.sql",
+		Check:   tableExists("notification_deliveries"),
+	},
+	{
+		Version: 22,
+		Name:    "google sheets",
+		File:    "022_google_sheets.up.sql",
+		Check:   tableExists("form_sheets"),
+	},
+	{
+		Version: 23,
+		Name:    "api keys",
+		File:    "023_api_keys.up.sql",
+		Check:   tableExists("api_keys"),
+	},
+	{
+		Version: 24,
+		Name:    "channel events",
+		File:    "024_channel_events.up.sql",
+		Check:   columnExists("notification_channels", "events"),
+	},
+	{
+		Version: 25,
+		Name:    "submission files",
+		File:    "025_submission_files.up.sql",
+		Check:   tableExists("submission_files"),
+	},
+	{
+		Version: 26,
+		Name:    "akismet",
+		File:    "026_akismet.up.sql",
+		Check:   columnExists("submissions", "spam_score"),
+	},
+	{
+		Version: 27,
+		Name:    "submission feeds",
+		File:    "027_submission_feeds.up.sql",
+		Check:   tableExists("form_feeds"),
+	},
+	{
+		Version: 28,
+		Name:    "email threading",
+		File:    "028_email_threading.up.sql",
+		Check:   columnExists("forms", "thread_by_sender"),
+	},
+	{
+		Version: 29,
+		Name:    "auth turnstile settings",
+		File:    "029_auth_turnstile_settings.up.sql",
+		Check:   settingExists("turnstile_secret_key"),
+	},
+	{
+		Version: 30,
+		Name:    "turnstile fallback",
+		File:    "030_turnstile_fallback.up.sql",
+		Check:   columnExists("forms", "turnstile_fallback"),
+	},
+	{
+		Version: 31,
+		Name:    "turnstile site key",
+		File:    "031_turnstile_site_key.up.sql",
+		Check:   columnExists("forms", "turnstile_site_key"),
+	},
+	{
+		Version: 32,
+		Name:    "spam scoring",
+		File:    "032_spam_scoring.up.sql",
+		Check:   columnExists("submissions", "spam_signals"),
+	},
+	{
+		Version: 33,
+		Name:    "geoip",
+		File:    "033_geoip.up.sql",
+		Check:   columnExists("submissions", "country"),
+	},
+	{
+		Version: 34,
+		Name:    "data requests",
+		File:    "034_data_requests.up.sql",
+		Check:   tableExists("data_requests"),
+	},
+	{
+		Version: 35,
+		Name:    "form fields",
+		File:    "035_form_fields.up.sql",
+		Check:   tableExists("form_field_totals"),
+	},
+	{
+		Version: 36,
+		Name:    "email bounces",
+		File:    "036_email_bounces.up.sql",
+		Check:   tableExists("email_bounces"),
+	},
+	{
+		Version: 37,
+		Name:    "notification mutes",
+		File:    "037_notification_mutes.up.sql",
+		Check:   tableExists("notification_mutes"),
+	},
+	{
+		Version: 38,
+		Name:    "form routes",
+		File:    "038_form_routes.up.sql",
+		Check:   tableExists("form_routes"),
+	},
+	{
+		Version: 39,
+		Name:    "email attachment",
+		File:    "039_email_attachment.up.sql",
+		Check:   columnExists("forms", "email_attachment"),
+	},
+	{
+		Version: 40,
+		Name:    "hosted page",
+		File:    "040_hosted_page.up.sql",
+		Check:   columnExists("forms", "hosted_page"),
+	},
+	{
+		Version: 41,
+		Name:    "form cc",
+		File:    "041_form_cc.up.sql",
+		Check:   columnExists("forms", "allow_cc"),
+	},
+	{
+		Version: 42,
+		Name:    "signup lock",
+		File:    "042_signup_lock.up.sql",
+		Check:   tableExists("signup_lock"),
+	},
+}
+
+// migrationFiles holds the migration files, embedded in the binary unless
+// overridden with SetMigrationsFS
+var migrationFiles fs.FS = staticsend.MigrationsFS()
+
+// SetMigrationsFS replaces the embedded migrations, e.g. with os.DirFS to
+// run migrations from a directory on disk
+func SetMigrationsFS(fsys fs.FS) {
+	migrationFiles = fsys
+}
+
+// Migrate applies any migrations the database is missing
+func (d *Database) Migrate() error {
+	for _, m := range migrations {
+		// Check whether the migration has already been applied
+		var name string
+		err := d.Connection.QueryRow(m.Check(d.Dialect)).Scan(&name)
+		if err == nil {
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to check %s migration: %w", m.Name, err)
+		}
+
+		log.Printf("Running %s migration...", m.Name)
+		migrationSQL, err := fs.ReadFile(migrationFiles, path.Join(d.Dialect.MigrationsDir("."), m.File))
 		if err != nil {
 			return fmt.Errorf("failed to read migration file: %w", err)
 		}
 
-		if _, err := DB.Exec(string(migrationSQL)); err != nil {
+		if _, err := d.Connection.Exec(string(migrationSQL)); err != nil {
 			return fmt.Errorf("failed to execute migration: %w", err)
 		}
 
-		log.Println("Form schema update migration completed successfully")
-	} else if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to check for forms table columns: %w", err)
+		log.Printf("Migration %03d (%s) completed successfully", m.Version, m.Name)
 	}
 
 	return nil
 }
 
+// MigrationState is a migration and whether the database has it
+type MigrationState struct {
+	Version int
+	Name    string
+	Applied bool
+}
+
+// Migrations reports every migration this build knows about, oldest first,
+// and whether each has been applied
+func (d *Database) Migrations(ctx context.Context) ([]MigrationState, error) {
+	states := make([]MigrationState, 0, len(migrations))
+	pending := false
+	for _, m := range migrations {
+		var name string
+		err := d.Connection.QueryRowContext(ctx, m.Check(d.Dialect)).Scan(&name)
+		// A check can fail when an earlier migration that creates its
+		// table is also pending
+		if err != nil && err != sql.ErrNoRows && !pending {
+			return nil, fmt.Errorf("failed to check %s migration: %w", m.Name, err)
+		}
+		pending = pending || err != nil
+		states = append(states, MigrationState{Version: m.Version, Name: m.Name, Applied: err == nil})
+	}
+	return states, nil
+}
+
+// SchemaVersion reports the newest migration applied to the database and
+// the newest migration this build knows about. They differ while migrations
+// are pending.
+func (d *Database) SchemaVersion(ctx context.Context) (current, latest int, err error) {
+	states, err := d.Migrations(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, state := range states {
+		latest = state.Version
+		if state.Applied {
+			current = state.Version
+		}
+	}
+	return current, latest, nil
+}
+
 // Close closes the database connection
-func Close() error {
-	if DB != nil {
-		return DB.Close()
+func (d *Database) Close() error {
+	if d == nil || d.Connection == nil {
+		return nil
 	}
-	return nil
+	return d.Connection.Close()
 }
\ No newline at end of file
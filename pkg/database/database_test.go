@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"path/filepath"
@@ -66,15 +67,94 @@ func TestDatabase_Struct(t *testing.T) {
 }
 
 func TestClose_NilDB(t *testing.T) {
-	// Save original DB
-	originalDB := DB
-	defer func() { DB = originalDB }()
+	var database *Database
+	if err := database.Close(); err != nil {
+		t.Errorf("Close should not return error when DB is nil, got: %v", err)
+	}
+
+	if err := (&Database{}).Close(); err != nil {
+		t.Errorf("Close should not return error without a connection, got: %v", err)
+	}
+}
 
-	// Set DB to nil
-	DB = nil
+func TestInit_IndependentDatabases(t *testing.T) {
+	t.Parallel()
 
-	err := Close()
+	first, err := Init(filepath.Join(t.TempDir(), "first.db"))
 	if err != nil {
-		t.Errorf("Close should not return error when DB is nil, got: %v", err)
+		t.Fatalf("Failed to open first database: %v", err)
+	}
+	defer first.Close()
+
+	second, err := Init(filepath.Join(t.TempDir(), "second.db"))
+	if err != nil {
+		t.Fatalf("Failed to open second database: %v", err)
+	}
+	defer second.Close()
+
+	if _, err := first.Connection.Exec("INSERT INTO users (email, password_hash) VALUES (?, ?)", "user@example.com", "hash"); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	var count int
+	if err := second.Connection.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("Failed to count users: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected the second database to be unaffected, got %d users", count)
+	}
+	if first.Dialect != SQLite {
+		t.Errorf("Expected SQLite dialect, got %q", first.Dialect)
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	db, err := Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	current, latest, err := db.SchemaVersion(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+	if latest != migrations[len(migrations)-1].Version || current != latest {
+		t.Errorf("Expected a fully migrated database, got version %d of %d", current, latest)
+	}
+
+	// Undoing the newest migration leaves it pending
+	if _, err := db.Connection.Exec("DROP TABLE signup_lock"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+	current, _, err = db.SchemaVersion(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+	if current != latest-1 {
+		t.Errorf("Expected version %d, got %d", latest-1, current)
+	}
+}
+
+func TestDiskSpace(t *testing.T) {
+	if _, _, err := (&Database{}).DiskSpace(); err != ErrDiskSpaceUnsupported {
+		t.Errorf("Expected ErrDiskSpaceUnsupported without a file, got %v", err)
+	}
+
+	db, err := Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	free, total, err := db.DiskSpace()
+	if err == ErrDiskSpaceUnsupported {
+		t.Skip("Disk space isn't available on this platform")
+	}
+	if err != nil {
+		t.Fatalf("Failed to read disk space: %v", err)
+	}
+	if total == 0 || free > total {
+		t.Errorf("Expected free space within the total, got %d of %d", free, total)
 	}
 }
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Dialect identifies a supported database engine
+type Dialect string
+
+const (
+	// SQLite is the default embedded database
+	SQLite Dialect = "sqlite"
+	// Postgres is PostgreSQL, selected with a postgres:// DSN
+	Postgres Dialect = "postgres"
+	// MySQL is MySQL or MariaDB, selected with a mysql:// DSN
+	MySQL Dialect = "mysql"
+)
+
+// ParseDSN works out the dialect from a DSN and returns the data source name
+// to hand to the driver. Anything without a recognised scheme is treated as
+// a SQLite file path.
+func ParseDSN(dsn string) (Dialect, string, error) {
+	scheme, rest, found := strings.Cut(dsn, "://")
+	if !found {
+		return SQLite, dsn, nil
+	}
+
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return Postgres, dsn, nil
+	case "mysql", "mariadb":
+		source, err := mysqlDataSource(dsn)
+		if err != nil {
+			return "", "", err
+		}
+		return MySQL, source, nil
+	case "sqlite", "sqlite3", "file":
+		return SQLite, rest, nil
+	default:
+		return "", "", fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+}
+
+// DriverName returns the database/sql driver registered for the dialect
+func (d Dialect) DriverName() string {
+	switch d {
+	case Postgres:
+		return postgresDriverName
+	case MySQL:
+		return mysqlDriverName
+	default:
+		return "sqlite3"
+	}
+}
+
+// MigrationsDir returns the directory holding the dialect's migration files,
+// as a slash-separated path below base
+func (d Dialect) MigrationsDir(base string) string {
+	if d == SQLite || d == "" {
+		return base
+	}
+	return path.Join(base, string(d))
+}
+
+// TableExistsQuery returns a query that yields a row when the table exists
+func (d Dialect) TableExistsQuery(table string) string {
+	switch d {
+	case Postgres:
+		return fmt.Sprintf("SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = '%s'", table)
+	case MySQL:
+		return fmt.Sprintf("SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = '%s'", table)
+	default:
+		return fmt.Sprintf("SELECT name FROM sqlite_master WHERE type='table' AND name='%s'", table)
+	}
+}
+
+// ColumnExistsQuery returns a query that yields a row when the column exists
+func (d Dialect) ColumnExistsQuery(table, column string) string {
+	switch d {
+	case Postgres:
+		return fmt.Sprintf("SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = '%s' AND column_name = '%s'", table, column)
+	case MySQL:
+		return fmt.Sprintf("SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = '%s' AND column_name = '%s'", table, column)
+	default:
+		return fmt.Sprintf("SELECT name FROM pragma_table_info('%s') WHERE name = '%s'", table, column)
+	}
+}
+
+// IndexExistsQuery returns a query that yields a row when the index exists
+func (d Dialect) IndexExistsQuery(index string) string {
+	switch d {
+	case Postgres:
+		return fmt.Sprintf("SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND indexname = '%s'", index)
+	case MySQL:
+		return fmt.Sprintf("SELECT index_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND index_name = '%s' LIMIT 1", index)
+	default:
+		return fmt.Sprintf("SELECT name FROM sqlite_master WHERE type='index' AND name='%s'", index)
+	}
+}
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// ErrDiskSpaceUnsupported is returned by DiskSpace on platforms where free
+// space can't be read, and for engines other than SQLite
+var ErrDiskSpaceUnsupported = errors.New("disk space is not available")
+
+// DiskSpace reports the free and total bytes on the file system holding the
+// SQLite database file
+func (d *Database) DiskSpace() (free, total uint64, err error) {
+	if d.Path == "" {
+		return 0, 0, ErrDiskSpaceUnsupported
+	}
+	return diskSpace(filepath.Dir(d.Path))
+}
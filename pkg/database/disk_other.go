@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package database
+
+// diskSpace isn't implemented on this platform
+func diskSpace(dir string) (free, total uint64, err error) {
+	return 0, 0, ErrDiskSpaceUnsupported
+}
@@ -0,0 +1,312 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresDriverName is the database/sql driver that adapts the models' SQL
+// to PostgreSQL
+const postgresDriverName = "staticsend-postgres"
+
+func init() {
+	sql.Register(postgresDriverName, &postgresDriver{parent: stdlib.GetDefaultDriver()})
+}
+
+// Rebind rewrites ? placeholders as PostgreSQL's numbered $1, $2, ... form,
+// leaving question marks inside quoted strings and identifiers alone
+func Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// insertTable returns the table a single INSERT adds rows to, unless it
+// already has a RETURNING clause
+func insertTable(query string) (string, bool) {
+	q := strings.TrimSpace(query)
+	q = strings.TrimSuffix(q, ";")
+	upper := strings.ToUpper(q)
+	if !strings.HasPrefix(upper, "INSERT") ||
+		strings.Contains(upper, "RETURNING") ||
+		strings.Contains(q, ";") {
+		return "", false
+	}
+
+	// INSERT INTO table ...
+	fields := strings.Fields(q)
+	if len(fields) < 3 || !strings.EqualFold(fields[1], "INTO") {
+		return "", false
+	}
+	return strings.Trim(strings.ToLower(strings.SplitN(fields[2], "(", 2)[0]), `"`), true
+}
+
+// generatedIDQuery reports whether a table has an id column filled in by a
+// sequence or as an identity
+const generatedIDQuery = `SELECT EXISTS (
+	SELECT 1 FROM information_schema.columns
+	WHERE table_schema = current_schema() AND table_name = $1 AND column_name = 'id'
+	AND (column_default LIKE 'nextval(%' OR is_identity = 'YES')
+)`
+
+// withReturningID appends RETURNING id to an INSERT statement
+func withReturningID(query string) string {
+	return strings.TrimSuffix(strings.TrimSpace(query), ";") + " RETURNING id"
+}
+
+// postgresDriver wraps the pgx driver so the SQLite-flavoured queries in
+// pkg/models run unchanged: placeholders are rebound and INSERTs report the
+// new row's id through LastInsertId
+type postgresDriver struct {
+	parent driver.Driver
+}
+
+// Open opens a wrapped connection
+func (d *postgresDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresConn{Conn: conn}, nil
+}
+
+// postgresConn adapts queries before handing them to the pgx connection
+type postgresConn struct {
+	driver.Conn
+
+	// generatedID caches whether each table inserted into has a generated id
+	generatedID map[string]bool
+}
+
+// needsReturningID reports whether a statement is a single INSERT into a
+// table with a generated id, which must be fetched with RETURNING since
+// PostgreSQL drivers don't support LastInsertId
+func (c *postgresConn) needsReturningID(ctx context.Context, query string) (bool, error) {
+	table, ok := insertTable(query)
+	if !ok {
+		return false, nil
+	}
+	if generated, ok := c.generatedID[table]; ok {
+		return generated, nil
+	}
+
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return false, nil
+	}
+	rows, err := queryer.QueryContext(ctx, generatedIDQuery, []driver.NamedValue{{Ordinal: 1, Value: table}})
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s for an id column: %w", table, err)
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		return false, fmt.Errorf("failed to check %s for an id column: %w", table, err)
+	}
+	generated, _ := dest[0].(bool)
+
+	if c.generatedID == nil {
+		c.generatedID = make(map[string]bool)
+	}
+	c.generatedID[table] = generated
+	return generated, nil
+}
+
+// insertResult reports the rows returned by an INSERT ... RETURNING id
+type insertResult struct {
+	id   int64
+	rows int64
+}
+
+// LastInsertId returns the id of the inserted row
+func (r insertResult) LastInsertId() (int64, error) { return r.id, nil }
+
+// RowsAffected returns the number of inserted rows
+func (r insertResult) RowsAffected() (int64, error) { return r.rows, nil }
+
+// scanReturnedID reads the ids produced by RETURNING id, reporting the first
+// and counting them all; an INSERT that skipped its row returns none
+func scanReturnedID(rows driver.Rows) (driver.Result, error) {
+	defer rows.Close()
+
+	var result insertResult
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err := rows.Next(dest); err != nil {
+			if err == io.EOF {
+				return result, nil
+			}
+			return nil, err
+		}
+
+		result.rows++
+		if result.rows > 1 {
+			continue
+		}
+		switch id := dest[0].(type) {
+		case int64:
+			result.id = id
+		case int32:
+			result.id = int64(id)
+		default:
+			return nil, fmt.Errorf("unexpected id type %T", dest[0])
+		}
+	}
+}
+
+// ExecContext rebinds the query, fetching the new id for INSERTs with
+// arguments. Statements without arguments, such as migrations, can change
+// the schema, so they clear what's known about tables' ids.
+func (c *postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) == 0 {
+		c.generatedID = nil
+	} else if returning, err := c.needsReturningID(ctx, query); err != nil {
+		return nil, err
+	} else if returning {
+		queryer, ok := c.Conn.(driver.QueryerContext)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		rows, err := queryer.QueryContext(ctx, Rebind(withReturningID(query)), args)
+		if err != nil {
+			return nil, err
+		}
+		return scanReturnedID(rows)
+	}
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, Rebind(query), args)
+}
+
+// QueryContext rebinds the query
+func (c *postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return queryer.QueryContext(ctx, Rebind(query), args)
+}
+
+// PrepareContext rebinds the query, preparing INSERTs with RETURNING id
+func (c *postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	returning, err := c.needsReturningID(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if returning {
+		query = withReturningID(query)
+	}
+
+	var stmt driver.Stmt
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, Rebind(query))
+	} else {
+		stmt, err = c.Conn.Prepare(Rebind(query))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if returning {
+		return &insertStmt{Stmt: stmt}, nil
+	}
+	return stmt, nil
+}
+
+// BeginTx starts a transaction on the underlying connection
+func (c *postgresConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping checks the underlying connection
+func (c *postgresConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue lets pgx convert argument types itself
+func (c *postgresConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ResetSession resets the underlying connection before it's reused
+func (c *postgresConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the underlying connection can be reused
+func (c *postgresConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// insertStmt is a prepared INSERT ... RETURNING id whose Exec reports the id
+type insertStmt struct {
+	driver.Stmt
+}
+
+// ExecContext runs the insert and returns the generated id and row count
+func (s *insertStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, fmt.Errorf("prepared statement does not support QueryContext")
+	}
+	rows, err := queryer.QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return scanReturnedID(rows)
+}
+
+// QueryContext runs the insert and returns its rows
+func (s *insertStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, fmt.Errorf("prepared statement does not support QueryContext")
+	}
+	return queryer.QueryContext(ctx, args)
+}
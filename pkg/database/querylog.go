@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// queryLogger logs statements that take longer than a threshold, so queries
+// that slow down as tables grow show up in the logs
+type queryLogger struct {
+	threshold atomic.Int64
+	logf      func(format string, args ...interface{})
+}
+
+// observe logs the query if it ran for longer than the threshold
+func (l *queryLogger) observe(query string, start time.Time) {
+	threshold := time.Duration(l.threshold.Load())
+	if threshold <= 0 {
+		return
+	}
+	if elapsed := time.Since(start); elapsed >= threshold {
+		l.logf("Slow query (%s): %s", elapsed.Round(time.Microsecond), compactQuery(query))
+	}
+}
+
+// compactQuery collapses whitespace so multi-line statements log on one line
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// LogSlowQueries logs every statement that takes at least threshold to run.
+// A threshold of 0 turns the log off.
+func (d *Database) LogSlowQueries(threshold time.Duration) {
+	if d == nil || d.queryLog == nil {
+		return
+	}
+	d.queryLog.threshold.Store(int64(threshold))
+}
+
+// openDB opens a connection pool whose statements are timed by a queryLogger.
+// The logger starts disabled until LogSlowQueries sets a threshold.
+func openDB(driverName, source string) (*sql.DB, *queryLogger, error) {
+	// sql.Open doesn't connect; it's only used to look up the driver
+	probe, err := sql.Open(driverName, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	parent := probe.Driver()
+	probe.Close()
+
+	var connector driver.Connector
+	if opener, ok := parent.(driver.DriverContext); ok {
+		if connector, err = opener.OpenConnector(source); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		connector = dsnConnector{driver: parent, name: source}
+	}
+
+	logger := &queryLogger{logf: log.Printf}
+	return sql.OpenDB(&loggingConnector{Connector: connector, log: logger}), logger, nil
+}
+
+// dsnConnector adapts a driver without a Connector of its own
+type dsnConnector struct {
+	driver driver.Driver
+	name   string
+}
+
+// Connect opens a connection with the driver
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+// Driver returns the underlying driver
+func (c dsnConnector) Driver() driver.Driver { return c.driver }
+
+// loggingConnector wraps new connections so their statements are timed
+type loggingConnector struct {
+	driver.Connector
+	log *queryLogger
+}
+
+// Connect opens a timed connection
+func (c *loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &loggingConn{Conn: conn, log: c.log}, nil
+}
+
+// loggingConn times queries before handing them to the driver's connection
+type loggingConn struct {
+	driver.Conn
+	log *queryLogger
+}
+
+// ExecContext runs and times a statement
+func (c *loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.log.observe(query, time.Now())
+	return execer.ExecContext(ctx, query, args)
+}
+
+// QueryContext runs and times a query
+func (c *loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.log.observe(query, time.Now())
+	return queryer.QueryContext(ctx, query, args)
+}
+
+// PrepareContext prepares a statement whose executions are timed
+func (c *loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loggingStmt{Stmt: stmt, conn: c.Conn, query: query, log: c.log}, nil
+}
+
+// BeginTx starts a transaction on the underlying connection
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping checks the underlying connection
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue lets the driver convert argument types itself
+func (c *loggingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ResetSession resets the underlying connection before it's reused
+func (c *loggingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the underlying connection can be reused
+func (c *loggingConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// loggingStmt times executions of a prepared statement
+type loggingStmt struct {
+	driver.Stmt
+	conn  driver.Conn
+	query string
+	log   *queryLogger
+}
+
+// ExecContext runs and times the statement
+func (s *loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer s.log.observe(s.query, time.Now())
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+// QueryContext runs and times the query
+func (s *loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer s.log.observe(s.query, time.Now())
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+// CheckNamedValue lets the statement, or failing that its connection,
+// convert argument types itself
+func (s *loggingStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// namedValues converts arguments for drivers that only accept positional values
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogSlowQueries(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialise database: %v", err)
+	}
+	defer database.Close()
+
+	var logged []string
+	database.queryLog.logf = func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	// Off until a threshold is set
+	database.Connection.Exec("SELECT 1")
+	if len(logged) != 0 {
+		t.Fatalf("Expected no slow query log before a threshold is set, got %v", logged)
+	}
+
+	database.LogSlowQueries(time.Nanosecond)
+	if _, err := database.Connection.Exec("INSERT INTO users (email, password_hash)\n\tVALUES (?, ?)", "user@example.com", "hash"); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	stmt, err := database.Connection.Prepare("SELECT COUNT(*) FROM users WHERE email = ?")
+	if err != nil {
+		t.Fatalf("Failed to prepare statement: %v", err)
+	}
+	var count int
+	if err := stmt.QueryRow("user@example.com").Scan(&count); err != nil || count != 1 {
+		t.Fatalf("Failed to run prepared statement: %v (count %d)", err, count)
+	}
+	stmt.Close()
+
+	if len(logged) != 2 {
+		t.Fatalf("Expected both statements to be logged, got %v", logged)
+	}
+	if !strings.HasSuffix(logged[0], "INSERT INTO users (email, password_hash) VALUES (?, ?)") {
+		t.Errorf("Expected the statement on one line, got %q", logged[0])
+	}
+	if strings.Contains(logged[0], "user@example.com") {
+		t.Errorf("Expected arguments to be left out of the log, got %q", logged[0])
+	}
+
+	database.LogSlowQueries(0)
+	database.Connection.Exec("SELECT 1")
+	if len(logged) != 2 {
+		t.Errorf("Expected no logging once disabled, got %v", logged)
+	}
+
+	// Databases built by hand have no logger to configure
+	(&Database{}).LogSlowQueries(time.Second)
+}
+
+// TestQueryPlansUseIndexes checks that the per-request queries are answered
+// from an index rather than a table scan or a separate sort
+func TestQueryPlansUseIndexes(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialise database: %v", err)
+	}
+	defer database.Close()
+
+	queries := map[string]string{
+		"SELECT id FROM forms WHERE form_key = ?":                                    "form_key",
+		"SELECT id FROM forms WHERE user_id = ? ORDER BY created_at DESC":            "idx_forms_user_id_created_at",
+		"SELECT id FROM submissions WHERE form_id = ? ORDER BY created_at DESC":      "idx_submissions_form_id_created_at",
+		"SELECT COUNT(*) FROM submissions WHERE form_id = ?":                         "form_id",
+		"SELECT id FROM blocked_attempts WHERE form_id = ? ORDER BY created_at DESC": "idx_blocked_attempts_form_id_created_at",
+	}
+
+	for query, index := range queries {
+		rows, err := database.Connection.Query("EXPLAIN QUERY PLAN "+query, 1)
+		if err != nil {
+			t.Fatalf("Failed to explain %q: %v", query, err)
+		}
+		var plan []string
+		for rows.Next() {
+			var id, parent, notUsed int
+			var detail string
+			if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+				t.Fatalf("Failed to scan plan: %v", err)
+			}
+			plan = append(plan, detail)
+		}
+		rows.Close()
+
+		joined := strings.Join(plan, "; ")
+		if !strings.Contains(joined, index) {
+			t.Errorf("Expected %q to use an index on %s, got plan %q", query, index, joined)
+		}
+		if strings.Contains(joined, "TEMP B-TREE") {
+			t.Errorf("Expected %q to avoid a separate sort, got plan %q", query, joined)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteOptions tunes SQLite connections for concurrent use. The settings
+// are applied through the DSN so every connection in the pool gets them.
+type SQLiteOptions struct {
+	// JournalMode is the journal_mode pragma; WAL lets readers carry on while a write is in progress
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock before failing with "database is locked"
+	BusyTimeout time.Duration
+	// Synchronous is the synchronous pragma; NORMAL is safe with WAL and avoids an fsync per commit
+	Synchronous string
+	// MaxOpenConns caps the connection pool. SQLite allows a single writer at a time.
+	MaxOpenConns int
+}
+
+// DefaultSQLiteOptions returns the settings used when none are configured
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode:  "WAL",
+		BusyTimeout:  5 * time.Second,
+		Synchronous:  "NORMAL",
+		MaxOpenConns: 1,
+	}
+}
+
+var (
+	// journalModes are the values SQLite accepts for the journal_mode pragma
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	// synchronousModes are the values SQLite accepts for the synchronous pragma
+	synchronousModes = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// dataSource appends the connection parameters for the options to a SQLite path
+func (o SQLiteOptions) dataSource(path string) (string, error) {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	// Take the write lock when a transaction starts rather than failing
+	// with "database is locked" when a reader later tries to write
+	params.Set("_txlock", "immediate")
+
+	if o.JournalMode != "" {
+		mode := strings.ToUpper(o.JournalMode)
+		if !contains(journalModes, mode) {
+			return "", fmt.Errorf("invalid SQLite journal mode %q", o.JournalMode)
+		}
+		params.Set("_journal_mode", mode)
+	}
+	if o.Synchronous != "" {
+		mode := strings.ToUpper(o.Synchronous)
+		if !contains(synchronousModes, mode) {
+			return "", fmt.Errorf("invalid SQLite synchronous mode %q", o.Synchronous)
+		}
+		params.Set("_synchronous", mode)
+	}
+	if o.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10))
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + params.Encode(), nil
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
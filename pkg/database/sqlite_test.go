@@ -0,0 +1,89 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDataSource(t *testing.T) {
+	source, err := DefaultSQLiteOptions().dataSource("/data/staticsend.db")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, param := range []string{"_journal_mode=WAL", "_busy_timeout=5000", "_synchronous=NORMAL", "_foreign_keys=on", "_txlock=immediate"} {
+		if !strings.Contains(source, param) {
+			t.Errorf("Expected %s in %q", param, source)
+		}
+	}
+	if !strings.HasPrefix(source, "/data/staticsend.db?") {
+		t.Errorf("Expected parameters to follow the path, got %q", source)
+	}
+
+	source, err = SQLiteOptions{}.dataSource("file:test.db?cache=shared")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(source, "file:test.db?cache=shared&") {
+		t.Errorf("Expected parameters to be appended to the existing query, got %q", source)
+	}
+
+	if _, err := (SQLiteOptions{JournalMode: "wal; DROP TABLE users"}).dataSource("test.db"); err == nil {
+		t.Error("Expected error for invalid journal mode")
+	}
+	if _, err := (SQLiteOptions{Synchronous: "sometimes"}).dataSource("test.db"); err == nil {
+		t.Error("Expected error for invalid synchronous mode")
+	}
+}
+
+func TestInitWithOptions_AppliesPragmas(t *testing.T) {
+	opts := DefaultSQLiteOptions()
+	opts.MaxOpenConns = 4
+	database, err := InitWithOptions(filepath.Join(t.TempDir(), "test.db"), opts)
+	if err != nil {
+		t.Fatalf("Failed to initialise database: %v", err)
+	}
+	defer database.Close()
+	db := database.Connection
+
+	var journalMode string
+	var busyTimeout, synchronous, foreignKeys int
+	db.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout)
+	db.QueryRow("PRAGMA synchronous").Scan(&synchronous)
+	db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys)
+
+	if journalMode != "wal" {
+		t.Errorf("Expected WAL journal mode, got %q", journalMode)
+	}
+	if busyTimeout != int(opts.BusyTimeout/time.Millisecond) {
+		t.Errorf("Expected busy_timeout %d, got %d", opts.BusyTimeout.Milliseconds(), busyTimeout)
+	}
+	if synchronous != 1 {
+		t.Errorf("Expected synchronous NORMAL (1), got %d", synchronous)
+	}
+	if foreignKeys != 1 {
+		t.Error("Expected foreign keys to be enabled")
+	}
+
+	// Concurrent writers wait for the lock instead of failing
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := db.Exec("INSERT INTO users (email, password_hash) VALUES (?, ?)", "user"+string(rune('a'+i))+"@example.com", "hash")
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent insert failed: %v", err)
+		}
+	}
+}
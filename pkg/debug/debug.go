@@ -0,0 +1,113 @@
+// Package debug serves routes for diagnosing a running instance: Go's
+// profiler, the rate limiters and the templates loaded. They're only mounted
+// when STATICSEND_DEBUG is on, and only for administrators.
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/templates"
+)
+
+// Limiter is a rate limiter registered for introspection
+type Limiter struct {
+	Name  string
+	Rate  time.Duration
+	Burst int
+	Store middleware.LimiterStore
+}
+
+// LimiterStatus describes a rate limiter. Buckets is how many clients it's
+// tracking, or -1 when its store can't say, as with Redis.
+type LimiterStatus struct {
+	Name    string `json:"name"`
+	Rate    string `json:"rate"`
+	Burst   int    `json:"burst"`
+	Buckets int    `json:"buckets"`
+}
+
+// bucketCounter is implemented by stores that can count their buckets
+type bucketCounter interface {
+	Len() int
+}
+
+// Handler serves the debug routes
+type Handler struct {
+	Templates *templates.TemplateManager
+
+	mu       sync.Mutex
+	limiters []Limiter
+}
+
+// NewHandler creates a new debug handler
+func NewHandler(tm *templates.TemplateManager) *Handler {
+	return &Handler{Templates: tm}
+}
+
+// AddLimiter registers a rate limiter to be listed
+func (h *Handler) AddLimiter(limiter Limiter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.limiters = append(h.limiters, limiter)
+}
+
+// Limiters describes the registered rate limiters, in the order they were
+// added
+func (h *Handler) Limiters() []LimiterStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	statuses := make([]LimiterStatus, 0, len(h.limiters))
+	for _, limiter := range h.limiters {
+		status := LimiterStatus{Name: limiter.Name, Rate: limiter.Rate.String(), Burst: limiter.Burst, Buckets: -1}
+		if counter, ok := limiter.Store.(bucketCounter); ok {
+			status.Buckets = counter.Len()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Routes returns the debug routes, to be mounted at /debug
+func (h *Handler) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/ratelimits", h.RateLimits)
+	r.Get("/templates", h.TemplateNames)
+
+	// The profiler's index links to its profiles relative to itself
+	r.Get("/pprof", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+	})
+	r.Get("/pprof/", pprof.Index)
+	r.Get("/pprof/cmdline", pprof.Cmdline)
+	r.Get("/pprof/profile", pprof.Profile)
+	r.Get("/pprof/symbol", pprof.Symbol)
+	r.Post("/pprof/symbol", pprof.Symbol)
+	r.Get("/pprof/trace", pprof.Trace)
+	r.Get("/pprof/{profile}", func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(chi.URLParam(r, "profile")).ServeHTTP(w, r)
+	})
+	return r
+}
+
+// RateLimits lists the rate limiters as JSON
+func (h *Handler) RateLimits(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.Limiters())
+}
+
+// TemplateNames lists the loaded templates as JSON
+func (h *Handler) TemplateNames(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.Templates.Names())
+}
+
+// writeJSON writes value as the JSON response
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
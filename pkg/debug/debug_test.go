@@ -0,0 +1,85 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/templates"
+)
+
+// countlessStore is a store that can't count its buckets
+type countlessStore struct{}
+
+func (countlessStore) Limit(key string) bool { return false }
+
+func TestHandler_RateLimits(t *testing.T) {
+	handler := NewHandler(templates.NewTemplateManager())
+	login := middleware.NewRateLimiter(time.Minute, 5)
+	login.Limit("203.0.113.1")
+	login.Limit("203.0.113.2")
+	handler.AddLimiter(Limiter{Name: "login", Rate: time.Minute, Burst: 5, Store: login})
+	handler.AddLimiter(Limiter{Name: "submit", Rate: time.Second, Burst: 10, Store: countlessStore{}})
+
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/ratelimits", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var statuses []LimiterStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	want := []LimiterStatus{
+		{Name: "login", Rate: "1m0s", Burst: 5, Buckets: 2},
+		{Name: "submit", Rate: "1s", Burst: 10, Buckets: -1},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, statuses)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], statuses[i])
+		}
+	}
+}
+
+func TestHandler_TemplateNames(t *testing.T) {
+	handler := NewHandler(templates.NewTemplateManager())
+
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/templates", nil))
+	var names []string
+	if err := json.Unmarshal(rr.Body.Bytes(), &names); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	found := false
+	for _, name := range names {
+		if name == "auth/login.html" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected auth/login.html among %v", names)
+	}
+}
+
+func TestHandler_Pprof(t *testing.T) {
+	handler := NewHandler(templates.NewTemplateManager())
+
+	for path, status := range map[string]int{
+		"/pprof":           http.StatusMovedPermanently,
+		"/pprof/":          http.StatusOK,
+		"/pprof/goroutine": http.StatusOK,
+		"/pprof/cmdline":   http.StatusOK,
+	} {
+		rr := httptest.NewRecorder()
+		handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != status {
+			t.Errorf("%s: expected status %d, got %d", path, status, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats a submission can be attached to its email in
+const (
+	AttachJSON = "json"
+	AttachCSV  = "csv"
+)
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SubmissionRecord is a submission as it's attached to its email
+type SubmissionRecord struct {
+	ID        int64             `json:"id"`
+	FormID    int64             `json:"form_id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// AttachSubmission attaches a submission to its email as a JSON file, or a
+// CSV file with a header and one row, for mail automation to read instead
+// of the body. Other formats attach nothing. Values are as submitted.
+func AttachSubmission(job *EmailJob, format string, record SubmissionRecord) error {
+	record.CreatedAt = record.CreatedAt.UTC()
+	switch format {
+	case AttachJSON:
+		data, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return err
+		}
+		job.Attachments = append(job.Attachments, Attachment{
+			Filename:    fmt.Sprintf("submission-%d.json", record.ID),
+			ContentType: "application/json",
+			Data:        append(data, '\n'),
+		})
+	case AttachCSV:
+		// Fields follow the columns the exports start with, by name
+		names := make([]string, 0, len(record.Fields))
+		for name := range record.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		row := []string{strconv.FormatInt(record.ID, 10), record.CreatedAt.Format(time.RFC3339)}
+		for _, name := range names {
+			row = append(row, record.Fields[name])
+		}
+
+		var data bytes.Buffer
+		writer := csv.NewWriter(&data)
+		writer.Write(append([]string{"id", "created_at"}, names...))
+		writer.Write(row)
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		job.Attachments = append(job.Attachments, Attachment{
+			Filename:    fmt.Sprintf("submission-%d.csv", record.ID),
+			ContentType: "text/csv",
+			Data:        data.Bytes(),
+		})
+	}
+	return nil
+}
+
+// writeMultipart writes the Content-Type header and body of an email with
+// attachments: the text, then each file in base64
+func writeMultipart(msg *strings.Builder, job EmailJob) {
+	parts := multipart.NewWriter(msg)
+	msg.WriteString(fmt.Sprintf("Content-Type: %s\r\n\r\n", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": parts.Boundary()})))
+
+	// Writing to a strings.Builder doesn't fail
+	text, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=UTF-8"}})
+	text.Write([]byte(job.Body))
+	for _, attachment := range job.Attachments {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", mime.FormatMediaType(attachment.ContentType, map[string]string{"name": attachment.Filename}))
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+		header.Set("Content-Transfer-Encoding", "base64")
+		file, _ := parts.CreatePart(header)
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > 76 {
+			file.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		file.Write([]byte(encoded + "\r\n"))
+	}
+	parts.Close()
+}
@@ -0,0 +1,104 @@
+package email
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachSubmission(t *testing.T) {
+	record := SubmissionRecord{
+		ID:        7,
+		FormID:    3,
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Fields:    map[string]string{"name": "Ada", "message": "Hi, \"there\""},
+	}
+
+	var job EmailJob
+	if err := AttachSubmission(&job, AttachJSON, record); err != nil {
+		t.Fatalf("Failed to attach JSON: %v", err)
+	}
+	if len(job.Attachments) != 1 || job.Attachments[0].Filename != "submission-7.json" {
+		t.Fatalf("Expected submission-7.json, got %+v", job.Attachments)
+	}
+	var decoded SubmissionRecord
+	if err := json.Unmarshal(job.Attachments[0].Data, &decoded); err != nil {
+		t.Fatalf("Expected the JSON to parse: %v", err)
+	}
+	if decoded.ID != 7 || decoded.FormID != 3 || decoded.Fields["message"] != `Hi, "there"` {
+		t.Errorf("Unexpected JSON %+v", decoded)
+	}
+
+	job = EmailJob{}
+	if err := AttachSubmission(&job, AttachCSV, record); err != nil {
+		t.Fatalf("Failed to attach CSV: %v", err)
+	}
+	want := "id,created_at,message,name\n7,2024-05-01T12:00:00Z,\"Hi, \"\"there\"\"\",Ada\n"
+	if len(job.Attachments) != 1 || string(job.Attachments[0].Data) != want {
+		t.Errorf("Expected CSV %q, got %+v", want, job.Attachments)
+	}
+
+	job = EmailJob{}
+	if err := AttachSubmission(&job, "", record); err != nil || len(job.Attachments) != 0 {
+		t.Errorf("Expected no attachment without a format, got %+v, %v", job.Attachments, err)
+	}
+}
+
+func TestBuildMessage_Attachments(t *testing.T) {
+	service := &EmailService{config: EmailConfig{From: "noreply@example.com"}}
+	data := []byte(strings.Repeat("attached ", 20))
+
+	message := service.buildMessage(EmailJob{
+		To:          []string{"to@example.com"},
+		Subject:     "Submission",
+		Body:        "Hello",
+		Attachments: []Attachment{{Filename: "submission-1.csv", ContentType: "text/csv", Data: data}},
+	})
+
+	parsed, err := mail.ReadMessage(strings.NewReader(message))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Expected multipart/mixed, got %q", parsed.Header.Get("Content-Type"))
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+
+	text, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("Expected a text part: %v", err)
+	}
+	body, _ := io.ReadAll(text)
+	if string(body) != "Hello" {
+		t.Errorf("Expected the body first, got %q", body)
+	}
+
+	file, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("Expected an attachment part: %v", err)
+	}
+	if file.FileName() != "submission-1.csv" {
+		t.Errorf("Expected submission-1.csv, got %q", file.FileName())
+	}
+	encoded, _ := io.ReadAll(file)
+	for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("Expected base64 lines of at most 76 characters, got %d", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if err != nil || string(decoded) != string(data) {
+		t.Errorf("Expected the attachment to decode to its data, got %q, %v", decoded, err)
+	}
+
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("Expected two parts, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package email
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dkimHeaders are the headers signed when a message has them, in the order
+// they're listed in the signature
+var dkimHeaders = []string{
+	"From", "To", "Cc", "Reply-To", "Subject", "Date", "Message-ID",
+	"In-Reply-To", "References", "List-Unsubscribe", "List-Unsubscribe-Post",
+	"MIME-Version", "Content-Type",
+}
+
+// DKIMSigner signs outgoing messages with DKIM (RFC 6376), so receiving
+// servers can check they came from the domain. Headers and body are
+// canonicalized with relaxed/relaxed, which survives relays rewrapping
+// lines or adjusting whitespace.
+type DKIMSigner struct {
+	// Domain is the signing domain (d=), whose DNS publishes the public key.
+	// Empty signs for the domain of the message's From address.
+	Domain string
+	// Selector names the key (s=), published at <selector>._domainkey.<domain>
+	Selector string
+	key      crypto.Signer
+}
+
+// NewDKIMSigner creates a signer from a PEM encoded RSA or Ed25519 private
+// key, in PKCS #1 or PKCS #8 form
+func NewDKIMSigner(domain, selector string, pemKey []byte) (*DKIMSigner, error) {
+	if selector == "" {
+		return nil, errors.New("no DKIM selector")
+	}
+	key, err := parseDKIMKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	return &DKIMSigner{Domain: domain, Selector: selector, key: key}, nil
+}
+
+// parseDKIMKey parses a PEM encoded private key for signing
+func parseDKIMKey(pemKey []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("DKIM private key isn't PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DKIM private key: %w", err)
+	}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("DKIM private keys must be RSA or Ed25519, not %T", parsed)
+	}
+}
+
+// algorithm returns the signature's a= tag for the key
+func (s *DKIMSigner) algorithm() string {
+	if _, ok := s.key.(ed25519.PrivateKey); ok {
+		return "ed25519-sha256"
+	}
+	return "rsa-sha256"
+}
+
+// Sign returns message, with CRLF line endings, led by a DKIM-Signature
+// header
+func (s *DKIMSigner) Sign(message string) (string, error) {
+	message = crlf(message)
+	header, body, found := strings.Cut(message, "\r\n\r\n")
+	if !found {
+		header = strings.TrimSuffix(message, "\r\n")
+		body = ""
+	}
+	fields := splitHeader(header)
+
+	bodyHash := sha256.Sum256([]byte(relaxedBody(body)))
+
+	// Each header is signed once, as only one of each is written
+	domain := s.Domain
+	var names []string
+	var signed strings.Builder
+	for _, name := range dkimHeaders {
+		for _, field := range fields {
+			fieldName, value, _ := strings.Cut(field, ":")
+			if !strings.EqualFold(strings.TrimSpace(fieldName), name) {
+				continue
+			}
+			if name == "From" && domain == "" {
+				domain = messageDomain(strings.TrimSpace(value))
+			}
+			names = append(names, strings.ToLower(name))
+			signed.WriteString(relaxedHeader(field))
+			signed.WriteString("\r\n")
+			break
+		}
+	}
+	if domain == "" {
+		return "", errors.New("no DKIM domain and no From header")
+	}
+
+	signature := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.algorithm(), domain, s.Selector, time.Now().Unix(), strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+	// The signature header itself is signed with an empty b= and no line end
+	signed.WriteString(relaxedHeader("DKIM-Signature: " + signature))
+	digest := sha256.Sum256([]byte(signed.String()))
+
+	var sig []byte
+	var err error
+	if _, ok := s.key.(ed25519.PrivateKey); ok {
+		// Ed25519 signs the hash itself (RFC 8463)
+		sig, err = s.key.Sign(rand.Reader, digest[:], crypto.Hash(0))
+	} else {
+		sig, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	return "DKIM-Signature: " + signature + base64.StdEncoding.EncodeToString(sig) + "\r\n" + message, nil
+}
+
+// crlf ends every line with CRLF, as the message is sent, so the body is
+// signed as the server receives it
+func crlf(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// splitHeader splits a message header into its fields, keeping folded
+// continuation lines with the field they belong to
+func splitHeader(header string) []string {
+	var fields []string
+	for _, line := range strings.Split(header, "\r\n") {
+		if len(fields) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// relaxedHeader canonicalizes a header field with the relaxed algorithm:
+// the name lowercased, the value unfolded, runs of whitespace made a single
+// space and whitespace around the colon and at the end removed
+func relaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.TrimSpace(collapseSpace(value))
+}
+
+// relaxedBody canonicalizes a body with the relaxed algorithm: whitespace
+// at line ends removed, other runs of whitespace made a single space and
+// empty lines at the end removed
+func relaxedBody(body string) string {
+	lines := strings.Split(body, "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseSpace(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// collapseSpace replaces each run of spaces and tabs with a single space
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+package email
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// verifyDKIM checks a message's DKIM-Signature against the public key, as
+// a receiving server would
+func verifyDKIM(message string, public crypto.PublicKey) (map[string]string, error) {
+	first, rest, _ := strings.Cut(message, "\r\n")
+	signatureHeader := strings.TrimPrefix(first, "DKIM-Signature: ")
+	tags := map[string]string{}
+	for _, tag := range strings.Split(signatureHeader, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		tags[name] = value
+	}
+
+	header, body, _ := strings.Cut(rest, "\r\n\r\n")
+	bodyHash := sha256.Sum256([]byte(relaxedBody(body)))
+	if got := base64.StdEncoding.EncodeToString(bodyHash[:]); got != tags["bh"] {
+		return tags, fmt.Errorf("body hash %s, signed %s", got, tags["bh"])
+	}
+
+	fields := splitHeader(header)
+	var signed strings.Builder
+	for _, name := range strings.Split(tags["h"], ":") {
+		for _, field := range fields {
+			if fieldName, _, _ := strings.Cut(field, ":"); strings.EqualFold(fieldName, name) {
+				signed.WriteString(relaxedHeader(field) + "\r\n")
+				break
+			}
+		}
+	}
+	unsigned := strings.TrimSuffix(signatureHeader, tags["b"])
+	signed.WriteString(relaxedHeader("DKIM-Signature: " + unsigned))
+	digest := sha256.Sum256([]byte(signed.String()))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return tags, err
+	}
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return tags, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest[:], sig) {
+			return tags, fmt.Errorf("invalid signature")
+		}
+		return tags, nil
+	}
+	return tags, fmt.Errorf("unknown key %T", public)
+}
+
+func TestRelaxedCanonicalization(t *testing.T) {
+	// The example from RFC 6376, section 3.4.5
+	fields := splitHeader("A: X\r\nB : Y\t\r\n\tZ  ")
+	var header []string
+	for _, field := range fields {
+		header = append(header, relaxedHeader(field))
+	}
+	if got := strings.Join(header, "\r\n"); got != "a:X\r\nb:Y Z" {
+		t.Errorf("Expected the header canonicalized, got %q", got)
+	}
+	if got := relaxedBody(" C \r\nD \t E\r\n\r\n\r\n"); got != " C\r\nD E\r\n" {
+		t.Errorf("Expected the body canonicalized, got %q", got)
+	}
+	if got := relaxedBody("\r\n\r\n"); got != "" {
+		t.Errorf("Expected an empty body to stay empty, got %q", got)
+	}
+}
+
+func TestDKIMSigner_Sign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		pem           []byte
+		public        crypto.PublicKey
+		wantAlgorithm string
+	}{
+		{
+			name:          "rsa",
+			pem:           pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			public:        &rsaKey.PublicKey,
+			wantAlgorithm: "rsa-sha256",
+		},
+		{
+			name:          "ed25519",
+			pem:           pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+			public:        edPublic,
+			wantAlgorithm: "ed25519-sha256",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewDKIMSigner("", "mail", tt.pem)
+			if err != nil {
+				t.Fatalf("Failed to create signer: %v", err)
+			}
+			es := &EmailService{config: EmailConfig{From: "staticSend <noreply@example.com>"}}
+			message := es.buildMessage(EmailJob{
+				To:      []string{"owner@example.com"},
+				Subject: "New submission",
+				Body:    "Name: Ada\nMessage: Hello  there \n\n",
+				Thread:  FormThread(1),
+			})
+
+			signed, err := signer.Sign(message)
+			if err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+			tags, err := verifyDKIM(signed, tt.public)
+			if err != nil {
+				t.Fatalf("Expected the signature to verify, got %v", err)
+			}
+			if tags["a"] != tt.wantAlgorithm || tags["d"] != "example.com" || tags["s"] != "mail" {
+				t.Errorf("Unexpected signature tags %v", tags)
+			}
+			if !strings.HasPrefix(tags["h"], "from:to:subject:date:message-id:in-reply-to:references") {
+				t.Errorf("Expected the addressing headers signed, got %q", tags["h"])
+			}
+
+			// Relaxed canonicalization tolerates relays adjusting whitespace
+			relayed := strings.Replace(signed, "Subject: ", "Subject:   ", 1)
+			relayed = strings.Replace(relayed, "Hello  there \r\n", "Hello there\r\n", 1)
+			if _, err := verifyDKIM(relayed, tt.public); err != nil {
+				t.Errorf("Expected whitespace changes to verify, got %v", err)
+			}
+			tampered := strings.Replace(signed, "Name: Ada", "Name: Eve", 1)
+			if _, err := verifyDKIM(tampered, tt.public); err == nil {
+				t.Error("Expected a changed body to fail verification")
+			}
+			tampered = strings.Replace(signed, "Subject: New submission", "Subject: Urgent", 1)
+			if _, err := verifyDKIM(tampered, tt.public); err == nil {
+				t.Error("Expected a changed subject to fail verification")
+			}
+		})
+	}
+}
+
+func TestNewDKIMSigner_Invalid(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+	if _, err := NewDKIMSigner("example.com", "", key); err == nil {
+		t.Error("Expected a selector to be required")
+	}
+	if _, err := NewDKIMSigner("example.com", "mail", []byte("not a key")); err == nil {
+		t.Error("Expected a key that isn't PEM to be rejected")
+	}
+	if _, err := NewDKIMSigner("example.com", "mail", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")})); err == nil {
+		t.Error("Expected an invalid key to be rejected")
+	}
+	signer, err := NewDKIMSigner("mail.example.org", "mail", key)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	signed, err := signer.Sign("From: noreply@example.com\r\n\r\nHi\r\n")
+	if err != nil || !strings.Contains(signed, "d=mail.example.org;") {
+		t.Errorf("Expected the configured domain to be signed for, got %q (%v)", signed, err)
+	}
+}
@@ -2,13 +2,22 @@ package email
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"mime"
+	"net/mail"
 	"net/smtp"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
+
+	"staticsend/pkg/outbound"
+	"staticsend/pkg/redact"
 )
 
 // EmailConfig holds SMTP configuration
@@ -19,36 +28,121 @@ type EmailConfig struct {
 	Password string
 	From     string
 	UseTLS   bool
+	// Timeout bounds the whole SMTP conversation, from dial to the end of
+	// the message, so a stuck server can't pin a worker. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Proxy, if set, is an HTTP or SOCKS5 proxy to reach the SMTP server
+	// through
+	Proxy *url.URL
+	// HeloName is the host name the client greets the server with, in
+	// HELO or EHLO. Servers that check it want this host's public name;
+	// empty greets as localhost.
+	HeloName string
+	// EnvelopeFrom is the envelope sender (MAIL FROM), where bounces go
+	// and which SPF checks. Empty uses the address in From.
+	EnvelopeFrom string
+	// DKIM, when set, signs each message before it's sent
+	DKIM *DKIMSigner
+	// Fallback, if set, is the SMTP server mail goes through once this
+	// one has failed FailoverAfter sends in a row, such as a provider's
+	// relay. Only its Host, Port, Username, Password and UseTLS are used.
+	Fallback *EmailConfig
+	// FailoverAfter is how many sends in a row must fail before failing
+	// over. Defaults to DefaultFailoverAfter.
+	FailoverAfter int
+	// FailbackAfter is how long after failing over the primary server is
+	// tried again. Defaults to DefaultFailbackAfter.
+	FailbackAfter time.Duration
+}
+
+// envelopeFrom returns the envelope sender address
+func (c EmailConfig) envelopeFrom() string {
+	from := c.EnvelopeFrom
+	if from == "" {
+		from = c.From
+	}
+	// MAIL FROM takes a bare address, not "Name <address>"
+	if address, err := mail.ParseAddress(from); err == nil {
+		return address.Address
+	}
+	return from
 }
 
+// DefaultTimeout is the SMTP timeout used when none is configured
+const DefaultTimeout = 30 * time.Second
+
+// Priority is the lane a job is queued in
+type Priority int
+
+const (
+	// PriorityNormal is for form notifications, the bulk of the queue
+	PriorityNormal Priority = iota
+	// PriorityHigh is for emails someone is waiting on, such as ones to
+	// account holders, which are sent before any normal ones queued
+	PriorityHigh
+)
+
 // EmailJob represents an email sending job
 type EmailJob struct {
 	To      []string
 	Subject string
 	Body    string
 	Retries int
+	// Thread groups the email with others in the same thread in mail
+	// clients, such as FormThread; empty sends it on its own
+	Thread string
+	// ReplyTo, if set, is the address replies go to instead of From
+	ReplyTo string
+	// Cc lists addresses the email is copied to
+	Cc []string
+	// OnFailure, if set, is called with the last error once the job has
+	// failed after every retry
+	OnFailure func(err error)
+	// OnSent, if set, is called once the email has been sent by the queue
+	OnSent func()
+	// Unsubscribe, if set, is the link recipients unsubscribe with, given
+	// in a List-Unsubscribe header so mail clients offer it. HTTPS links
+	// are marked as one-click, so they must unsubscribe on a POST.
+	Unsubscribe string
+	// Priority is the lane the job is queued in, PriorityNormal by default
+	Priority Priority
+	// Attachments are files sent with the body, such as AttachSubmission's
+	Attachments []Attachment
+
+	// id identifies the job in the queue's stats once it's queued
+	id uint64
 }
 
 // EmailService handles email sending with async processing
 type EmailService struct {
-	config     EmailConfig
-	jobQueue   chan EmailJob
-	workerWg   sync.WaitGroup
-	maxRetries int
-	ctx        context.Context
-	cancel     context.CancelFunc
+	mu            sync.RWMutex // guards config
+	config        EmailConfig
+	jobQueue      chan EmailJob
+	priorityQueue chan EmailJob // PriorityHigh jobs, which workers take first
+	workerWg      sync.WaitGroup
+	maxRetries    int
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	// OnFailover, if set, is called when mail starts going through the
+	// fallback server, or back through the primary
+	OnFailover func(Failover)
+	failover   failoverState
+	stats      queueStats
 }
 
-// NewEmailService creates a new email service with the given configuration
+// NewEmailService creates a new email service with the given configuration.
+// Each priority lane of the queue holds queueSize jobs.
 func NewEmailService(config EmailConfig, queueSize, maxWorkers, maxRetries int) *EmailService {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	service := &EmailService{
-		config:     config,
-		jobQueue:   make(chan EmailJob, queueSize),
-		maxRetries: maxRetries,
-		ctx:        ctx,
-		cancel:     cancel,
+		config:        config,
+		jobQueue:      make(chan EmailJob, queueSize),
+		priorityQueue: make(chan EmailJob, queueSize),
+		maxRetries:    maxRetries,
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 
 	// Start email workers
@@ -63,31 +157,49 @@ func NewEmailService(config EmailConfig, queueSize, maxWorkers, maxRetries int)
 // Send sends an email with the given subject and body to the specified recipients
 // This is the synchronous version that blocks until the email is sent
 func (es *EmailService) Send(to []string, subject, body string) error {
-	if len(to) == 0 {
+	return es.SendJob(EmailJob{To: to, Subject: subject, Body: body})
+}
+
+// SendJob sends a job's email right away, in its thread, without retrying
+func (es *EmailService) SendJob(job EmailJob) error {
+	if len(job.To) == 0 {
 		return fmt.Errorf("no recipients specified")
 	}
 
 	// Prepare message
-	message := es.buildMessage(to, subject, body)
-
-	// Connect to SMTP server
-	auth := smtp.PlainAuth("", es.config.Username, es.config.Password, es.config.Host)
-	addr := fmt.Sprintf("%s:%d", es.config.Host, es.config.Port)
-
-	var err error
-	if es.config.UseTLS {
-		err = es.sendWithTLS(addr, auth, es.config.From, to, message)
-	} else {
-		err = smtp.SendMail(addr, auth, es.config.From, to, []byte(message))
+	message := es.buildMessage(job)
+	config := es.Config()
+	if config.DKIM != nil {
+		signed, err := config.DKIM.Sign(message)
+		if err != nil {
+			return err
+		}
+		message = signed
 	}
 
-	return err
+	recipients := append(append([]string{}, job.To...), job.Cc...)
+	return es.sendWithFailover(config, func(server EmailConfig) error {
+		auth := smtp.PlainAuth("", server.Username, server.Password, server.Host)
+		addr := fmt.Sprintf("%s:%d", server.Host, server.Port)
+		return es.sendMail(server, addr, auth, server.envelopeFrom(), recipients, message)
+	})
 }
 
 // SendAsync queues an email for asynchronous sending
 // Returns immediately without waiting for the email to be sent
 func (es *EmailService) SendAsync(to []string, subject, body string) error {
-	if len(to) == 0 {
+	return es.Enqueue(EmailJob{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+		Retries: 0,
+	})
+}
+
+// Enqueue queues an email job for asynchronous sending, for callers that
+// want to hear about it failing through the job's OnFailure
+func (es *EmailService) Enqueue(job EmailJob) error {
+	if len(job.To) == 0 {
 		return fmt.Errorf("no recipients specified")
 	}
 
@@ -98,58 +210,80 @@ func (es *EmailService) SendAsync(to []string, subject, body string) error {
 	default:
 	}
 
-	job := EmailJob{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Retries: 0,
-	}
-
+	job.id = es.stats.add()
 	select {
-	case es.jobQueue <- job:
+	case es.lane(job) <- job:
 		return nil
 	case <-es.ctx.Done():
+		es.stats.remove(job.id)
 		return fmt.Errorf("email service is shutting down")
 	default:
+		es.stats.remove(job.id)
 		return fmt.Errorf("email queue is full")
 	}
 }
 
-// emailWorker processes email jobs from the queue
+// emailWorker processes email jobs from the queue. High priority jobs are
+// always taken before normal ones, so they don't wait behind a backlog.
 func (es *EmailService) emailWorker(workerID int) {
 	defer es.workerWg.Done()
 
 	for {
 		select {
+		case job := <-es.priorityQueue:
+			es.processJob(workerID, job)
+			continue
+		default:
+		}
+
+		select {
+		case job := <-es.priorityQueue:
+			es.processJob(workerID, job)
 		case job := <-es.jobQueue:
-			err := es.Send(job.To, job.Subject, job.Body)
-			if err != nil {
-				if job.Retries < es.maxRetries {
-					// Retry the job with exponential backoff
-					job.Retries++
-					go es.retryJob(job)
-				} else {
-					log.Printf("Email worker %d: failed to send email after %d retries: %v", workerID, es.maxRetries, err)
-				}
-			} else {
-				log.Printf("Email worker %d: successfully sent email to %s", workerID, strings.Join(job.To, ","))
-			}
+			es.processJob(workerID, job)
 		case <-es.ctx.Done():
 			return
 		}
 	}
 }
 
+// processJob sends a job taken from the queue, retrying it with backoff if
+// it fails
+func (es *EmailService) processJob(workerID int, job EmailJob) {
+	err := es.SendJob(job)
+	if err != nil {
+		if job.Retries < es.maxRetries {
+			// Retry the job with exponential backoff
+			job.Retries++
+			es.stats.retry()
+			go es.retryJob(job)
+		} else {
+			log.Printf("Email worker %d: failed to send email after %d retries: %v", workerID, es.maxRetries, err)
+			es.stats.finish(job.id, false)
+			if job.OnFailure != nil {
+				job.OnFailure(err)
+			}
+		}
+	} else {
+		log.Printf("Email worker %d: successfully sent email to %s", workerID, redact.Emails(job.To))
+		es.stats.finish(job.id, true)
+		if job.OnSent != nil {
+			job.OnSent()
+		}
+	}
+}
+
 // retryJob retries a failed email job with exponential backoff
 func (es *EmailService) retryJob(job EmailJob) {
 	backoff := time.Duration(job.Retries*job.Retries) * time.Second
 	time.Sleep(backoff)
 
 	select {
-	case es.jobQueue <- job:
-		log.Printf("Retrying email to %s (attempt %d)", strings.Join(job.To, ","), job.Retries)
+	case es.lane(job) <- job:
+		log.Printf("Retrying email to %s (attempt %d)", redact.Emails(job.To), job.Retries)
 	case <-es.ctx.Done():
-		log.Printf("Cancelled retry for email to %s", strings.Join(job.To, ","))
+		log.Printf("Cancelled retry for email to %s", redact.Emails(job.To))
+		es.stats.remove(job.id)
 	}
 }
 
@@ -159,33 +293,105 @@ func (es *EmailService) Shutdown() {
 	es.cancel()
 	es.workerWg.Wait()
 	close(es.jobQueue)
+	close(es.priorityQueue)
 	log.Println("Email service shutdown complete")
 }
 
-// QueueSize returns the current number of pending jobs in the queue
+// Config returns the SMTP configuration in use
+func (es *EmailService) Config() EmailConfig {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.config
+}
+
+// SetConfig replaces the SMTP configuration. Emails already being sent
+// finish with the old one.
+func (es *EmailService) SetConfig(config EmailConfig) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.config = config
+}
+
+// QueueSize returns the current number of pending jobs in the queue, in
+// both lanes
 func (es *EmailService) QueueSize() int {
-	return len(es.jobQueue)
+	return len(es.jobQueue) + len(es.priorityQueue)
+}
+
+// QueueCapacity returns how many jobs the queue holds before SendAsync
+// fails. High priority jobs have a lane of the same size to themselves.
+func (es *EmailService) QueueCapacity() int {
+	return cap(es.jobQueue)
 }
 
-// sendWithTLS sends email using TLS connection
-func (es *EmailService) sendWithTLS(addr string, auth smtp.Auth, from string, to []string, message string) error {
+// lane returns the queue a job goes in
+func (es *EmailService) lane(job EmailJob) chan EmailJob {
+	if job.Priority == PriorityHigh {
+		return es.priorityQueue
+	}
+	return es.jobQueue
+}
+
+// dial connects to the SMTP server with the configured timeout applied to
+// both the connection attempt and the rest of the conversation
+func (es *EmailService) dial(config EmailConfig, addr string) (*smtp.Client, error) {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := outbound.Dial(ctx, config.Proxy, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if config.HeloName != "" {
+		if err := client.Hello(config.HeloName); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// sendMail sends an email over a single SMTP connection. With UseTLS the
+// connection must be upgraded with STARTTLS; otherwise, like smtp.SendMail,
+// TLS and authentication are used when the server offers them.
+func (es *EmailService) sendMail(config EmailConfig, addr string, auth smtp.Auth, from string, to []string, message string) error {
 	// Connect to SMTP server
-	client, err := smtp.Dial(addr)
+	client, err := es.dial(config, addr)
 	if err != nil {
 		return fmt.Errorf("failed to dial SMTP server: %w", err)
 	}
 	defer client.Close()
 
 	// Start TLS if configured
-	if es.config.UseTLS {
-		if err = client.StartTLS(&tls.Config{ServerName: es.config.Host}); err != nil {
+	if config.UseTLS {
+		if err = client.StartTLS(&tls.Config{ServerName: config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	} else if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: config.Host}); err != nil {
 			return fmt.Errorf("failed to start TLS: %w", err)
 		}
 	}
 
 	// Authenticate
-	if err = client.Auth(auth); err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+	if ok, _ := client.Extension("AUTH"); config.UseTLS || ok {
+		if err = client.Auth(auth); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
 	}
 
 	// Set sender
@@ -196,7 +402,7 @@ func (es *EmailService) sendWithTLS(addr string, auth smtp.Auth, from string, to
 	// Set recipients
 	for _, recipient := range to {
 		if err = client.Rcpt(recipient); err != nil {
-			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
+			return fmt.Errorf("failed to set recipient %s: %w", redact.Email(recipient), err)
 		}
 	}
 
@@ -205,39 +411,118 @@ func (es *EmailService) sendWithTLS(addr string, auth smtp.Auth, from string, to
 	if err != nil {
 		return fmt.Errorf("failed to get data writer: %w", err)
 	}
-	defer w.Close()
-
 	_, err = w.Write([]byte(message))
 	if err != nil {
+		w.Close()
 		return fmt.Errorf("failed to write message: %w", err)
 	}
 
-	return nil
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return client.Quit()
 }
 
-// buildMessage constructs the email message with proper headers
-func (es *EmailService) buildMessage(to []string, subject, body string) string {
+// buildMessage constructs the email message with proper headers. Messages
+// in a thread all refer to the same, made up, first message, which is
+// enough for mail clients to group them. Subjects that aren't plain ASCII
+// are encoded, since they may come from submissions.
+func (es *EmailService) buildMessage(job EmailJob) string {
 	var msg strings.Builder
+	from := es.Config().From
+	domain := messageDomain(from)
 
 	// Headers
-	msg.WriteString(fmt.Sprintf("From: %s\r\n", es.config.From))
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(to, ",")))
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(job.To, ",")))
+	if len(job.Cc) > 0 {
+		msg.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(job.Cc, ",")))
+	}
+	if job.ReplyTo != "" {
+		msg.WriteString(fmt.Sprintf("Reply-To: %s\r\n", job.ReplyTo))
+	}
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", job.Subject)))
 	msg.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	msg.WriteString(fmt.Sprintf("Message-ID: <%s@%s>\r\n", messageID(), domain))
+	if job.Thread != "" {
+		msg.WriteString(fmt.Sprintf("In-Reply-To: <%s@%s>\r\n", job.Thread, domain))
+		msg.WriteString(fmt.Sprintf("References: <%s@%s>\r\n", job.Thread, domain))
+	}
+	if job.Unsubscribe != "" {
+		msg.WriteString(fmt.Sprintf("List-Unsubscribe: <%s>\r\n", job.Unsubscribe))
+		if strings.HasPrefix(job.Unsubscribe, "https://") {
+			msg.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+		}
+	}
 	msg.WriteString("MIME-Version: 1.0\r\n")
+	if len(job.Attachments) > 0 {
+		writeMultipart(&msg, job)
+		return msg.String()
+	}
 	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
 	msg.WriteString("\r\n")
 
 	// Body
-	msg.WriteString(body)
+	msg.WriteString(job.Body)
 
 	return msg.String()
 }
 
-// SendFormSubmission sends a form submission email
-func (es *EmailService) SendFormSubmission(to []string, formData map[string]string) error {
-	subject := "New Form Submission"
+// messageDomain is the domain of the sending address, which message IDs end
+// with so they're unique to this server
+func messageDomain(from string) string {
+	if address, err := mail.ParseAddress(from); err == nil {
+		from = address.Address
+	}
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		return from[at+1:]
+	}
+	return "staticsend.localhost"
+}
+
+// messageID returns a random ID for a message
+func messageID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// SenderFields are the fields a submitter's email address is looked for
+// in, in order
+var SenderFields = []string{"email", "_replyto", "email_address", "your-email", "mail"}
+
+// FormThread is the thread all of a form's emails are in
+func FormThread(formID int64) string {
+	return fmt.Sprintf("form-%d", formID)
+}
+
+// SenderThread is the thread of a form's emails about submissions from one
+// email address. The address is hashed so it isn't repeated in headers.
+func SenderThread(formID int64, address string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(address))))
+	return fmt.Sprintf("form-%d-sender-%s", formID, hex.EncodeToString(sum[:8]))
+}
+
+// SubmissionThread is the thread of a submission's email: the sender's
+// thread when the form threads by sender and the submission has a valid
+// email address, or else the form's
+func SubmissionThread(formID int64, bySender bool, formData map[string]string) string {
+	if bySender {
+		for _, field := range SenderFields {
+			if address, err := mail.ParseAddress(formData[field]); err == nil {
+				return SenderThread(formID, address.Address)
+			}
+		}
+	}
+	return FormThread(formID)
+}
 
+// FormSubmissionJob builds the email of a form submission, ready to be
+// queued with Enqueue
+func FormSubmissionJob(to []string, formData map[string]string) EmailJob {
 	var body strings.Builder
 	body.WriteString("You have received a new form submission:\n\n")
 
@@ -245,44 +530,81 @@ func (es *EmailService) SendFormSubmission(to []string, formData map[string]stri
 		body.WriteString(fmt.Sprintf("%s: %s\n", key, value))
 	}
 
-	body.WriteString("\n---\n")
-	body.WriteString("This email was sent automatically by staticSend")
+	body.WriteString(submissionFooter)
 
-	return es.Send(to, subject, body.String())
+	return EmailJob{
+		To:      to,
+		Subject: "New Form Submission",
+		Body:    body.String(),
+	}
 }
 
-// SendFormSubmissionAsync sends a form submission email asynchronously
-func (es *EmailService) SendFormSubmissionAsync(to []string, formData map[string]string) error {
-	subject := "New Form Submission"
+// submissionFooter ends the email of a form submission
+const submissionFooter = "\n---\nThis email was sent automatically by staticSend"
 
-	var body strings.Builder
-	body.WriteString("You have received a new form submission:\n\n")
+// FileLink links to a file uploaded with a submission
+type FileLink struct {
+	Field    string
+	Filename string
+	URL      string
+}
 
-	for key, value := range formData {
-		body.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+// AddFileLinks lists links to download a submission's files in its email,
+// after the fields
+func AddFileLinks(job *EmailJob, links []FileLink) {
+	if len(links) == 0 {
+		return
+	}
+	var section strings.Builder
+	section.WriteString("\nFiles:\n")
+	for _, link := range links {
+		section.WriteString(fmt.Sprintf("%s (%s): %s\n", link.Filename, link.Field, link.URL))
 	}
+	job.Body = strings.TrimSuffix(job.Body, submissionFooter) + section.String() + submissionFooter
+}
+
+// AddUnsubscribe gives an email the link its recipient stops getting such
+// emails with, in the List-Unsubscribe header and at the end of the body.
+// It's added last, after any file links.
+func AddUnsubscribe(job *EmailJob, link string) {
+	job.Unsubscribe = link
+	job.Body += fmt.Sprintf("\n\nStop getting these emails: %s\n", link)
+}
 
-	body.WriteString("\n---\n")
-	body.WriteString("This email was sent automatically by staticSend")
+// SendFormSubmission sends a form submission email
+func (es *EmailService) SendFormSubmission(to []string, formData map[string]string) error {
+	job := FormSubmissionJob(to, formData)
+	return es.Send(job.To, job.Subject, job.Body)
+}
+
+// SendFormSubmissionAsync sends a form submission email asynchronously
+func (es *EmailService) SendFormSubmissionAsync(to []string, formData map[string]string) error {
+	return es.Enqueue(FormSubmissionJob(to, formData))
+}
 
-	return es.SendAsync(to, subject, body.String())
+// SendTest sends a test email to check an SMTP configuration before it's
+// used, without queueing or retrying
+func SendTest(config EmailConfig, to string) error {
+	es := &EmailService{config: config}
+	return es.Send([]string{to}, "staticSend test email", "This is a test email from staticSend. Your email settings work.\n")
 }
 
 // TestConnection tests the SMTP connection and authentication
 func (es *EmailService) TestConnection() error {
-	client, err := smtp.Dial(fmt.Sprintf("%s:%d", es.config.Host, es.config.Port))
+	config := es.Config()
+	client, err := es.dial(config, fmt.Sprintf("%s:%d", config.Host, config.Port))
 	if err != nil {
 		return fmt.Errorf("failed to connect to SMTP server: %w", err)
 	}
 	defer client.Close()
 
-	if es.config.UseTLS {
-		if err := client.StartTLS(&tls.Config{ServerName: es.config.Host}); err != nil {
+	if config.UseTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: config.Host}); err != nil {
 			return fmt.Errorf("failed to start TLS: %w", err)
 		}
 	}
 
-	auth := smtp.PlainAuth("", es.config.Username, es.config.Password, es.config.Host)
+	auth := smtp.PlainAuth("", config.Username, config.Password, config.Host)
 	if err := client.Auth(auth); err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
@@ -1,6 +1,8 @@
 package email
 
 import (
+	"net"
+	"net/textproto"
 	"strings"
 	"testing"
 	"time"
@@ -105,6 +107,35 @@ func TestSendFormSubmissionAsync(t *testing.T) {
 	}
 }
 
+func TestEnqueue_OnFailure(t *testing.T) {
+	// Nothing listens on a port that was just released
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	service := NewEmailService(EmailConfig{Host: "127.0.0.1", Port: port, From: "noreply@example.com", Timeout: time.Second}, 10, 1, 0)
+	defer service.Shutdown()
+
+	failed := make(chan error, 1)
+	job := FormSubmissionJob([]string{"admin@example.com"}, map[string]string{"name": "John"})
+	job.OnFailure = func(err error) { failed <- err }
+	if err := service.Enqueue(job); err != nil {
+		t.Fatalf("Enqueue should succeed: %v", err)
+	}
+
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Error("Expected the send error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnFailure wasn't called")
+	}
+}
+
 func TestShutdown(t *testing.T) {
 	config := EmailConfig{
 		Host:     "smtp.example.com",
@@ -133,4 +164,125 @@ func TestShutdown(t *testing.T) {
 	if err == nil {
 		t.Error("SendAsync should fail after shutdown")
 	}
-}
\ No newline at end of file
+}
+
+// acceptSMTP accepts one SMTP conversation on listener, agreeing to every
+// command and discarding the message. Commands are sent to commands, unless
+// it's nil.
+func acceptSMTP(listener net.Listener, commands chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	inData := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		if commands != nil && !inData {
+			commands <- line
+		}
+		switch {
+		case inData:
+			if line == "." {
+				inData = false
+				text.PrintfLine("250 OK")
+			}
+		case strings.HasPrefix(line, "EHLO"):
+			text.PrintfLine("250 localhost")
+		case line == "DATA":
+			inData = true
+			text.PrintfLine("354 Go ahead")
+		case line == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestEnqueue_OnSent(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go acceptSMTP(listener, nil)
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	service := NewEmailService(EmailConfig{Host: "127.0.0.1", Port: port, From: "noreply@example.com", Timeout: time.Second}, 10, 1, 0)
+	defer service.Shutdown()
+
+	sent := make(chan struct{}, 1)
+	job := FormSubmissionJob([]string{"admin@example.com"}, map[string]string{"name": "John"})
+	job.OnSent = func() { sent <- struct{}{} }
+	job.OnFailure = func(err error) { t.Errorf("Expected the email to be sent, got %v", err) }
+	if err := service.Enqueue(job); err != nil {
+		t.Fatalf("Enqueue should succeed: %v", err)
+	}
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnSent wasn't called")
+	}
+}
+
+func TestEnqueue_Priority(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	commands := make(chan string, 100)
+	go func() {
+		for i := 0; i < 3; i++ {
+			acceptSMTP(listener, commands)
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// Queue a backlog before a worker starts
+	service := NewEmailService(EmailConfig{Host: "127.0.0.1", Port: port, From: "noreply@example.com", Timeout: time.Second}, 10, 0, 0)
+	defer service.Shutdown()
+	sent := make(chan struct{}, 3)
+	for _, job := range []EmailJob{
+		{To: []string{"first@example.com"}, Subject: "Notification"},
+		{To: []string{"second@example.com"}, Subject: "Notification"},
+		{To: []string{"owner@example.com"}, Subject: "Your export is ready", Priority: PriorityHigh},
+	} {
+		job.OnSent = func() { sent <- struct{}{} }
+		if err := service.Enqueue(job); err != nil {
+			t.Fatalf("Failed to queue: %v", err)
+		}
+	}
+	if stats := service.Stats(); stats.Depth != 3 || stats.PriorityDepth != 1 {
+		t.Errorf("Expected 3 queued with 1 high priority, got %+v", stats)
+	}
+
+	service.workerWg.Add(1)
+	go service.emailWorker(0)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-sent:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the emails")
+		}
+	}
+
+	close(commands)
+	var recipients []string
+	for command := range commands {
+		if strings.HasPrefix(command, "RCPT TO:") {
+			recipients = append(recipients, command)
+		}
+	}
+	if len(recipients) != 3 || recipients[0] != "RCPT TO:<owner@example.com>" {
+		t.Errorf("Expected the high priority email sent first, got %v", recipients)
+	}
+}
@@ -1,8 +1,10 @@
 package email
 
 import (
+	"net"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewEmailService(t *testing.T) {
@@ -54,7 +56,7 @@ func TestBuildMessage(t *testing.T) {
 	subject := "Test Subject"
 	body := "Test body content"
 
-	message := service.buildMessage(to, subject, body)
+	message := service.buildMessage(EmailJob{To: to, Subject: subject, Body: body})
 
 	// Check that all required headers are present
 	headers := []string{
@@ -73,6 +75,176 @@ func TestBuildMessage(t *testing.T) {
 	}
 }
 
+func TestBuildMessage_ReplyToAndCc(t *testing.T) {
+	service := &EmailService{config: EmailConfig{From: "noreply@example.com"}}
+
+	message := service.buildMessage(EmailJob{
+		To:      []string{"to@example.com"},
+		Cc:      []string{"one@example.com", "two@example.com"},
+		ReplyTo: "Jane <jane@example.com>",
+		Subject: "Café enquiry",
+		Body:    "Body",
+	})
+	for _, header := range []string{
+		"Cc: one@example.com,two@example.com",
+		"Reply-To: Jane <jane@example.com>",
+		"Subject: =?UTF-8?q?Caf=C3=A9_enquiry?=",
+	} {
+		if !strings.Contains(message, header+"\r\n") {
+			t.Errorf("Expected %q in the message", header)
+		}
+	}
+
+	plain := service.buildMessage(EmailJob{To: []string{"to@example.com"}, Subject: "Subject", Body: "Body"})
+	if strings.Contains(plain, "Cc:") || strings.Contains(plain, "Reply-To:") {
+		t.Error("Expected no Cc or Reply-To headers unless set")
+	}
+}
+
+func TestBuildMessage_Unsubscribe(t *testing.T) {
+	service := &EmailService{config: EmailConfig{From: "noreply@example.com"}}
+
+	message := service.buildMessage(EmailJob{To: []string{"to@example.com"}, Subject: "Digest", Body: "Hello", Unsubscribe: "https://forms.example.com/unsubscribe/abc"})
+	if !strings.Contains(message, "List-Unsubscribe: <https://forms.example.com/unsubscribe/abc>\r\n") {
+		t.Errorf("Expected a List-Unsubscribe header, got %q", message)
+	}
+	if !strings.Contains(message, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n") {
+		t.Error("Expected HTTPS links to be one-click")
+	}
+
+	message = service.buildMessage(EmailJob{To: []string{"to@example.com"}, Subject: "Digest", Body: "Hello", Unsubscribe: "mailto:unsubscribe@example.com"})
+	if strings.Contains(message, "List-Unsubscribe-Post") {
+		t.Error("Expected mailto links not to be one-click")
+	}
+
+	message = service.buildMessage(EmailJob{To: []string{"to@example.com"}, Subject: "Submission", Body: "Hello"})
+	if strings.Contains(message, "List-Unsubscribe") {
+		t.Error("Expected no List-Unsubscribe header without a link")
+	}
+}
+
+func TestSend_HeloAndEnvelopeFrom(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   EmailConfig
+		wantHelo string
+		wantMail string
+	}{
+		{
+			name:     "defaults",
+			config:   EmailConfig{From: "staticSend <noreply@example.com>"},
+			wantHelo: "EHLO localhost",
+			wantMail: "MAIL FROM:<noreply@example.com>",
+		},
+		{
+			name:     "configured",
+			config:   EmailConfig{From: "noreply@example.com", HeloName: "forms.example.com", EnvelopeFrom: "bounces@example.com"},
+			wantHelo: "EHLO forms.example.com",
+			wantMail: "MAIL FROM:<bounces@example.com>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			defer listener.Close()
+			commands := make(chan string, 20)
+			go acceptSMTP(listener, commands)
+
+			config := tt.config
+			config.Host = "127.0.0.1"
+			config.Port = listener.Addr().(*net.TCPAddr).Port
+			config.Timeout = time.Second
+			if err := SendTest(config, "to@example.com"); err != nil {
+				t.Fatalf("Failed to send: %v", err)
+			}
+
+			var got []string
+			for len(commands) > 0 {
+				got = append(got, <-commands)
+			}
+			if len(got) < 2 || got[0] != tt.wantHelo {
+				t.Errorf("Expected %q first, got %v", tt.wantHelo, got)
+			}
+			found := false
+			for _, command := range got {
+				found = found || strings.HasPrefix(command, tt.wantMail)
+			}
+			if !found {
+				t.Errorf("Expected %q, got %v", tt.wantMail, got)
+			}
+		})
+	}
+}
+
+func TestBuildMessage_Thread(t *testing.T) {
+	service := &EmailService{config: EmailConfig{From: "staticSend <noreply@example.com>"}}
+
+	message := service.buildMessage(EmailJob{To: []string{"to@example.com"}, Subject: "Subject", Body: "Body", Thread: FormThread(3)})
+	for _, header := range []string{"In-Reply-To: <form-3@example.com>", "References: <form-3@example.com>"} {
+		if !strings.Contains(message, header+"\r\n") {
+			t.Errorf("Expected %q in the message", header)
+		}
+	}
+	if !strings.Contains(message, "Message-ID: <") || !strings.Contains(message, "@example.com>\r\n") {
+		t.Error("Expected a message ID from the sender's domain")
+	}
+
+	// Messages on their own don't refer to a thread, and IDs aren't reused
+	single := service.buildMessage(EmailJob{To: []string{"to@example.com"}, Subject: "Subject", Body: "Body"})
+	if strings.Contains(single, "References:") {
+		t.Error("Expected no thread headers")
+	}
+	id := func(message string) string {
+		start := strings.Index(message, "Message-ID: ")
+		return message[start : start+strings.Index(message[start:], "\r\n")]
+	}
+	if id(message) == id(single) {
+		t.Error("Expected a new message ID for each message")
+	}
+}
+
+func TestSubmissionThread(t *testing.T) {
+	fields := map[string]string{"email": "Ann@Example.com", "message": "Hi"}
+	if got := SubmissionThread(3, false, fields); got != "form-3" {
+		t.Errorf("Expected the form's thread, got %s", got)
+	}
+
+	byAnn := SubmissionThread(3, true, fields)
+	if !strings.HasPrefix(byAnn, "form-3-sender-") || strings.Contains(byAnn, "example") {
+		t.Errorf("Expected a sender thread without the address, got %s", byAnn)
+	}
+	if again := SubmissionThread(3, true, map[string]string{"_replyto": " ann@example.com"}); again != byAnn {
+		t.Errorf("Expected the same thread for the same address, got %s and %s", byAnn, again)
+	}
+	if other := SubmissionThread(3, true, map[string]string{"email": "bob@example.com"}); other == byAnn {
+		t.Error("Expected another sender to get their own thread")
+	}
+
+	// Without a valid address the form's thread is used
+	if got := SubmissionThread(3, true, map[string]string{"email": "not an address"}); got != "form-3" {
+		t.Errorf("Expected the form's thread, got %s", got)
+	}
+}
+
+func TestAddFileLinks(t *testing.T) {
+	job := FormSubmissionJob([]string{"to@example.com"}, map[string]string{"name": "Ann"})
+	AddFileLinks(&job, []FileLink{{Field: "cv", Filename: "CV.pdf", URL: "https://forms.example.com/uploads/1/2?signature=abc"}})
+
+	want := "name: Ann\n\nFiles:\nCV.pdf (cv): https://forms.example.com/uploads/1/2?signature=abc\n\n---\n"
+	if !strings.Contains(job.Body, want) || !strings.HasSuffix(job.Body, "sent automatically by staticSend") {
+		t.Errorf("Expected the links after the fields and before the footer, got:\n%s", job.Body)
+	}
+
+	unchanged := job.Body
+	AddFileLinks(&job, nil)
+	if job.Body != unchanged {
+		t.Error("Expected no links to leave the email unchanged")
+	}
+}
+
 func TestSendFormSubmission(t *testing.T) {
 	config := EmailConfig{
 		Host:     "smtp.example.com",
@@ -206,3 +378,52 @@ func TestEmailConfig_Validation(t *testing.T) {
 		})
 	}
 }
+
+func TestSend_Timeout(t *testing.T) {
+	// A server that accepts connections but never sends a greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	service := NewEmailService(EmailConfig{
+		Host:    "127.0.0.1",
+		Port:    addr.Port,
+		From:    "noreply@example.com",
+		Timeout: 100 * time.Millisecond,
+	}, 10, 1, 0)
+	defer service.Shutdown()
+
+	start := time.Now()
+	err = service.Send([]string{"test@example.com"}, "Test", "Test")
+	if err == nil {
+		t.Fatal("Expected error from unresponsive server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected send to give up after the timeout, took %v", elapsed)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	service := NewEmailService(EmailConfig{Host: "smtp.example.com", From: "old@example.com"}, 10, 0, 0)
+	defer service.Shutdown()
+
+	service.SetConfig(EmailConfig{Host: "mail.example.com", Port: 2525, From: "new@example.com"})
+	if config := service.Config(); config.Host != "mail.example.com" || config.Port != 2525 {
+		t.Errorf("Expected the new configuration, got %+v", config)
+	}
+	if message := service.buildMessage(EmailJob{To: []string{"to@example.com"}, Subject: "Subject", Body: "Body"}); !strings.Contains(message, "From: new@example.com") {
+		t.Errorf("Expected messages from the new sender, got %q", message)
+	}
+}
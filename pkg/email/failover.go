@@ -0,0 +1,175 @@
+package email
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailoverAfter is how many sends in a row must fail before
+	// failing over when FailoverAfter isn't set
+	DefaultFailoverAfter = 3
+	// DefaultFailbackAfter is how long mail goes through the fallback
+	// server before the primary is tried again, when FailbackAfter isn't set
+	DefaultFailbackAfter = 15 * time.Minute
+	// maxFailovers caps the switches remembered for Failovers
+	maxFailovers = 20
+)
+
+// Failover is a switch between the primary SMTP server and the fallback
+type Failover struct {
+	// From and To are the hosts of the servers switched from and to
+	From string
+	To   string
+	// Fallback is true when mail now goes through the fallback server,
+	// and false when it's back on the primary
+	Fallback bool
+	// Err is the primary server's last error, when failing over
+	Err error
+	At  time.Time
+}
+
+// failoverState tracks which server mail goes through
+type failoverState struct {
+	mu sync.Mutex
+	// failures counts the primary's failed sends in a row
+	failures int
+	// active is set while mail goes through the fallback server
+	active bool
+	// retryAt is when the primary is next tried while failed over
+	retryAt time.Time
+	// history is the recent switches, oldest first
+	history []Failover
+}
+
+// fallbackServer returns the configuration for sending through the
+// fallback server
+func (c EmailConfig) fallbackServer() EmailConfig {
+	server := c
+	server.Host = c.Fallback.Host
+	server.Port = c.Fallback.Port
+	server.Username = c.Fallback.Username
+	server.Password = c.Fallback.Password
+	server.UseTLS = c.Fallback.UseTLS
+	server.Fallback = nil
+	return server
+}
+
+// sendWithFailover sends through the primary server, or the fallback one
+// once the primary has failed FailoverAfter times in a row. While failed
+// over the primary is tried again every FailbackAfter, and mail goes back
+// to it once it works. Any failed send counts, including a recipient being
+// refused.
+func (es *EmailService) sendWithFailover(config EmailConfig, send func(EmailConfig) error) error {
+	if config.Fallback == nil {
+		es.failover.mu.Lock()
+		es.failover.failures, es.failover.active = 0, false
+		es.failover.mu.Unlock()
+		return send(config)
+	}
+	fallback := config.fallbackServer()
+
+	if !es.tryPrimary(config) {
+		return send(fallback)
+	}
+	err := send(config)
+	if !es.primaryResult(config, err) {
+		return err
+	}
+	// Failed over, or still failed over: the fallback gets this email too
+	return send(fallback)
+}
+
+// tryPrimary returns whether the next email goes through the primary
+// server. While failed over only one email tries the primary each
+// FailbackAfter.
+func (es *EmailService) tryPrimary(config EmailConfig) bool {
+	es.failover.mu.Lock()
+	defer es.failover.mu.Unlock()
+	if !es.failover.active {
+		return true
+	}
+	now := time.Now()
+	if now.Before(es.failover.retryAt) {
+		return false
+	}
+	es.failover.retryAt = now.Add(failbackAfter(config))
+	return true
+}
+
+// primaryResult records the result of sending through the primary server
+// and returns whether the email should be sent through the fallback
+func (es *EmailService) primaryResult(config EmailConfig, err error) bool {
+	es.failover.mu.Lock()
+	var switched *Failover
+	if err == nil {
+		es.failover.failures = 0
+		if es.failover.active {
+			es.failover.active = false
+			switched = es.recordFailover(Failover{From: config.Fallback.Host, To: config.Host})
+		}
+	} else {
+		es.failover.failures++
+		threshold := config.FailoverAfter
+		if threshold <= 0 {
+			threshold = DefaultFailoverAfter
+		}
+		if !es.failover.active && es.failover.failures >= threshold {
+			es.failover.active = true
+			es.failover.retryAt = time.Now().Add(failbackAfter(config))
+			switched = es.recordFailover(Failover{From: config.Host, To: config.Fallback.Host, Fallback: true, Err: err})
+		}
+	}
+	fallback, failures := es.failover.active, es.failover.failures
+	es.failover.mu.Unlock()
+
+	if switched != nil {
+		if switched.Fallback {
+			log.Printf("Email: %s failed %d sends in a row, failing over to %s: %v", switched.From, failures, switched.To, err)
+		} else {
+			log.Printf("Email: %s is working again, switching back from %s", switched.To, switched.From)
+		}
+		if es.OnFailover != nil {
+			es.OnFailover(*switched)
+		}
+	}
+	return fallback && err != nil
+}
+
+// recordFailover adds a switch to the history. The caller holds the lock.
+func (es *EmailService) recordFailover(failover Failover) *Failover {
+	failover.At = time.Now()
+	es.failover.history = append(es.failover.history, failover)
+	if len(es.failover.history) > maxFailovers {
+		es.failover.history = es.failover.history[len(es.failover.history)-maxFailovers:]
+	}
+	return &failover
+}
+
+// UsingFallback returns whether mail is going through the fallback server
+func (es *EmailService) UsingFallback() bool {
+	es.failover.mu.Lock()
+	defer es.failover.mu.Unlock()
+	return es.failover.active
+}
+
+// Failovers returns the recent switches between the primary and fallback
+// servers, newest first
+func (es *EmailService) Failovers() []Failover {
+	es.failover.mu.Lock()
+	defer es.failover.mu.Unlock()
+	failovers := make([]Failover, len(es.failover.history))
+	for i, failover := range es.failover.history {
+		failovers[len(failovers)-1-i] = failover
+	}
+	return failovers
+}
+
+// failbackAfter returns how long to wait before trying the primary again
+func failbackAfter(config EmailConfig) time.Duration {
+	if config.FailbackAfter > 0 {
+		return config.FailbackAfter
+	}
+	return DefaultFailbackAfter
+}
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSendJob_Failover(t *testing.T) {
+	// Nothing listens on the primary's port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	fallback, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer fallback.Close()
+	fallbackCommands := make(chan string, 100)
+	go func() {
+		for i := 0; i < 2; i++ {
+			acceptSMTP(fallback, fallbackCommands)
+		}
+	}()
+
+	config := EmailConfig{
+		Host:          "127.0.0.1",
+		Port:          closedPort,
+		From:          "noreply@example.com",
+		Timeout:       time.Second,
+		Fallback:      &EmailConfig{Host: "127.0.0.1", Port: fallback.Addr().(*net.TCPAddr).Port},
+		FailoverAfter: 2,
+		FailbackAfter: 50 * time.Millisecond,
+	}
+	service := &EmailService{config: config}
+	var failovers []Failover
+	service.OnFailover = func(failover Failover) {
+		failovers = append(failovers, failover)
+	}
+
+	if err := service.Send([]string{"admin@example.com"}, "First", "Body"); err == nil {
+		t.Fatal("Expected the first failure to be returned")
+	}
+	if service.UsingFallback() || len(failovers) != 0 {
+		t.Fatal("Expected no failover after one failure")
+	}
+	if err := service.Send([]string{"admin@example.com"}, "Second", "Body"); err != nil {
+		t.Fatalf("Expected the second email sent through the fallback, got %v", err)
+	}
+	if !service.UsingFallback() || len(failovers) != 1 || !failovers[0].Fallback || failovers[0].Err == nil {
+		t.Fatalf("Expected a failover recorded, got %+v", failovers)
+	}
+	if err := service.Send([]string{"admin@example.com"}, "Third", "Body"); err != nil {
+		t.Fatalf("Expected the third email sent straight through the fallback, got %v", err)
+	}
+
+	// The primary works again by the time it's retried
+	primary, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer primary.Close()
+	go acceptSMTP(primary, nil)
+	config.Port = primary.Addr().(*net.TCPAddr).Port
+	service.SetConfig(config)
+	time.Sleep(60 * time.Millisecond)
+
+	if err := service.Send([]string{"admin@example.com"}, "Fourth", "Body"); err != nil {
+		t.Fatalf("Expected the fourth email sent through the primary, got %v", err)
+	}
+	if service.UsingFallback() || len(failovers) != 2 || failovers[1].Fallback {
+		t.Fatalf("Expected the switch back recorded, got %+v", failovers)
+	}
+	if recorded := service.Failovers(); len(recorded) != 2 || recorded[0].Fallback || !recorded[1].Fallback {
+		t.Errorf("Expected both switches listed newest first, got %+v", recorded)
+	}
+}
@@ -0,0 +1,109 @@
+package email
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// minFailureSample is how many jobs must have finished before the failure
+// rate is checked, so one early failure doesn't raise an alert
+const minFailureSample = 10
+
+// QueueThresholds are the limits past which the queue counts as backing
+// up. A zero limit isn't checked.
+type QueueThresholds struct {
+	// Depth is the number of jobs waiting in the queue
+	Depth int
+	// OldestAge is how long the oldest pending email has waited
+	OldestAge time.Duration
+	// FailurePercent is the share of recently finished jobs that failed
+	FailurePercent int
+}
+
+// Problems describes each threshold stats is past
+func (t QueueThresholds) Problems(stats QueueStats) []string {
+	var problems []string
+	if t.Depth > 0 && stats.Depth >= t.Depth {
+		problems = append(problems, fmt.Sprintf("%d emails are waiting in the queue, which holds %d", stats.Depth, stats.Capacity))
+	}
+	if t.OldestAge > 0 && stats.OldestAge >= t.OldestAge {
+		problems = append(problems, fmt.Sprintf("The oldest unsent email has waited %s", stats.OldestAge))
+	}
+	if t.FailurePercent > 0 && stats.Recent >= minFailureSample && stats.FailurePercent() >= float64(t.FailurePercent) {
+		problems = append(problems, fmt.Sprintf("%.0f%% of the last %d emails failed after every retry", stats.FailurePercent(), stats.Recent))
+	}
+	return problems
+}
+
+// Monitor checks the email queue against thresholds, alerting when it
+// starts backing up and again once it's recovered
+type Monitor struct {
+	service    *EmailService
+	Thresholds QueueThresholds
+	// Alert is called with the problems when the queue starts backing up,
+	// and with none once it's back under every threshold
+	Alert func(problems []string)
+
+	mu       sync.Mutex
+	alerting bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewMonitor creates a monitor for service's queue
+func NewMonitor(service *EmailService, thresholds QueueThresholds, alert func(problems []string)) *Monitor {
+	return &Monitor{
+		service:    service,
+		Thresholds: thresholds,
+		Alert:      alert,
+	}
+}
+
+// Check compares the queue with the thresholds, alerting if it's started
+// backing up or recovered since the last check, and returns the problems
+func (m *Monitor) Check() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	problems := m.Thresholds.Problems(m.service.Stats())
+	backingUp := len(problems) > 0
+	if backingUp != m.alerting {
+		m.alerting = backingUp
+		if m.Alert != nil {
+			m.Alert(problems)
+		}
+	}
+	return problems
+}
+
+// Start checks the queue every interval until Stop is called
+func (m *Monitor) Start(interval time.Duration) {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.Check()
+			}
+		}
+	}()
+}
+
+// Stop stops checking, waiting for a running check to finish
+func (m *Monitor) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
@@ -0,0 +1,78 @@
+package email
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats_Depth(t *testing.T) {
+	// Without workers, queued jobs wait
+	service := NewEmailService(EmailConfig{Host: "127.0.0.1", Port: 1}, 5, 0, 0)
+	defer service.Shutdown()
+	for i := 0; i < 3; i++ {
+		if err := service.SendAsync([]string{"admin@example.com"}, "Test", "Body"); err != nil {
+			t.Fatalf("Failed to queue: %v", err)
+		}
+	}
+
+	stats := service.Stats()
+	if stats.Depth != 3 || stats.Capacity != 5 || stats.Pending != 3 {
+		t.Errorf("Expected 3 of 5 queued and pending, got %+v", stats)
+	}
+
+	monitor := NewMonitor(service, QueueThresholds{Depth: 3, OldestAge: time.Hour}, nil)
+	problems := monitor.Check()
+	if len(problems) != 1 || !strings.Contains(problems[0], "3 emails are waiting") {
+		t.Errorf("Expected the depth flagged, got %v", problems)
+	}
+}
+
+func TestMonitor_FailureRate(t *testing.T) {
+	// Nothing listens on the server's port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	service := NewEmailService(EmailConfig{Host: "127.0.0.1", Port: port, Timeout: time.Second}, 20, 2, 0)
+	defer service.Shutdown()
+	failed := make(chan error, minFailureSample)
+	for i := 0; i < minFailureSample; i++ {
+		job := EmailJob{To: []string{"admin@example.com"}, Subject: "Test", Body: "Body", OnFailure: func(err error) { failed <- err }}
+		if err := service.Enqueue(job); err != nil {
+			t.Fatalf("Failed to queue: %v", err)
+		}
+	}
+	for i := 0; i < minFailureSample; i++ {
+		select {
+		case <-failed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the emails to fail")
+		}
+	}
+
+	stats := service.Stats()
+	if stats.Failed != minFailureSample || stats.Pending != 0 || stats.FailureRate != 1 || stats.Recent != minFailureSample {
+		t.Errorf("Expected every email failed, got %+v", stats)
+	}
+
+	var alerts [][]string
+	monitor := NewMonitor(service, QueueThresholds{FailurePercent: 50}, func(problems []string) {
+		alerts = append(alerts, problems)
+	})
+	monitor.Check()
+	monitor.Check()
+	if len(alerts) != 1 || len(alerts[0]) != 1 || !strings.Contains(alerts[0][0], "100% of the last 10 emails failed") {
+		t.Fatalf("Expected one alert about the failures, got %v", alerts)
+	}
+
+	monitor.Thresholds.FailurePercent = 0
+	monitor.Check()
+	if len(alerts) != 2 || len(alerts[1]) != 0 {
+		t.Errorf("Expected a recovery alert, got %v", alerts)
+	}
+}
@@ -0,0 +1,130 @@
+package email
+
+import (
+	"sync"
+	"time"
+)
+
+// recentJobs is how many of the last finished jobs FailureRate covers
+const recentJobs = 100
+
+// QueueStats is a snapshot of the email queue, for metrics and alerts
+type QueueStats struct {
+	// Depth is the number of jobs waiting in the queue, in both lanes, and
+	// Capacity how many it holds before SendAsync fails
+	Depth    int
+	Capacity int
+	// PriorityDepth is the number of PriorityHigh jobs waiting
+	PriorityDepth int
+	// Pending counts the queued emails not yet sent or given up on,
+	// including ones being sent and ones waiting to be retried
+	Pending int
+	// OldestAge is how long the oldest pending email has waited, to the
+	// second
+	OldestAge time.Duration
+	// Sent, Failed and Retried count, since the service started, the
+	// emails sent, the ones given up on after every retry, and retries
+	Sent    uint64
+	Failed  uint64
+	Retried uint64
+	// FailureRate is the share, from 0 to 1, of the last Recent finished
+	// jobs that failed
+	FailureRate float64
+	Recent      int
+}
+
+// queueStats tracks the queued jobs and how they went
+type queueStats struct {
+	mu     sync.Mutex
+	nextID uint64
+	// pending holds when each unfinished job was queued
+	pending map[uint64]time.Time
+	sent    uint64
+	failed  uint64
+	retried uint64
+	// recent holds whether each of the last finished jobs failed, as a ring
+	recent []bool
+	next   int
+}
+
+// add records a job being queued and returns its id
+func (s *queueStats) add() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[uint64]time.Time)
+	}
+	s.nextID++
+	s.pending[s.nextID] = time.Now()
+	return s.nextID
+}
+
+// remove forgets a job that was never sent, such as one the full queue
+// refused
+func (s *queueStats) remove(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+}
+
+// retry counts a failed attempt that will be retried
+func (s *queueStats) retry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retried++
+}
+
+// finish records a job being sent, or given up on
+func (s *queueStats) finish(id uint64, sent bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+	if sent {
+		s.sent++
+	} else {
+		s.failed++
+	}
+	if len(s.recent) < recentJobs {
+		s.recent = append(s.recent, !sent)
+	} else {
+		s.recent[s.next] = !sent
+		s.next = (s.next + 1) % recentJobs
+	}
+}
+
+// Stats returns a snapshot of the queue
+func (es *EmailService) Stats() QueueStats {
+	es.stats.mu.Lock()
+	defer es.stats.mu.Unlock()
+
+	stats := QueueStats{
+		Depth:         es.QueueSize(),
+		Capacity:      es.QueueCapacity(),
+		PriorityDepth: len(es.priorityQueue),
+		Pending:       len(es.stats.pending),
+		Sent:          es.stats.sent,
+		Failed:        es.stats.failed,
+		Retried:       es.stats.retried,
+		Recent:        len(es.stats.recent),
+	}
+	now := time.Now()
+	for _, queuedAt := range es.stats.pending {
+		stats.OldestAge = max(stats.OldestAge, now.Sub(queuedAt))
+	}
+	failures := 0
+	for _, failed := range es.stats.recent {
+		if failed {
+			failures++
+		}
+	}
+	stats.OldestAge = stats.OldestAge.Round(time.Second)
+	if stats.Recent > 0 {
+		stats.FailureRate = float64(failures) / float64(stats.Recent)
+	}
+	return stats
+}
+
+// FailurePercent returns FailureRate as a percentage
+func (s QueueStats) FailurePercent() float64 {
+	return s.FailureRate * 100
+}
@@ -0,0 +1,123 @@
+// Package events is an in-process event bus. Handlers publish what happened,
+// such as a submission arriving, and the subsystems that care, such as
+// notification channels and Google Sheets, subscribe to it, so a new one
+// can follow submissions without the handlers knowing about it.
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"staticsend/pkg/models"
+)
+
+// Name names a kind of event
+type Name string
+
+const (
+	// SubmissionCreated is published when a submission is saved, except
+	// one held as spam. It has a Form and Submission.
+	SubmissionCreated Name = "submission.created"
+	// EmailSent is published when a submission's email has been sent. It
+	// has a Form and Submission.
+	EmailSent Name = "email.sent"
+	// FormDeleted is published once a form is deleted. It has the Form as
+	// it was, and the User who deleted it.
+	FormDeleted Name = "form.deleted"
+	// UserRegistered is published when an account is created. It has the
+	// User.
+	UserRegistered Name = "user.registered"
+)
+
+// Event is something that happened. Only the fields its Name says are set.
+type Event struct {
+	Name       Name
+	At         time.Time
+	Form       *models.Form
+	Submission *models.Submission
+	User       *models.User
+}
+
+// Handler handles an event. The context isn't the request's, which may
+// have finished by the time it runs.
+type Handler func(ctx context.Context, event Event)
+
+// Bus delivers published events to their subscribers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Name][]Handler
+	all      []Handler
+	running  sync.WaitGroup
+}
+
+// NewBus creates an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[Name][]Handler)}
+}
+
+// Subscribe calls handler with every event named name
+func (b *Bus) Subscribe(name Name, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[name] = append(b.handlers[name], handler)
+}
+
+// SubscribeAll calls handler with every event
+func (b *Bus) SubscribeAll(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.all = append(b.all, handler)
+}
+
+// Publish delivers an event to its subscribers, each in its own goroutine,
+// so a slow subscriber holds up neither the publisher nor the others. At is
+// set to now if it isn't already.
+func (b *Bus) Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := append(append([]Handler{}, b.handlers[event.Name]...), b.all...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		b.running.Add(1)
+		go func() {
+			defer b.running.Done()
+			// A subscriber's bug shouldn't take the server down with it
+			defer func() {
+				if err := recover(); err != nil {
+					log.Printf("Event %s: subscriber panicked: %v\n%s", event.Name, err, debug.Stack())
+				}
+			}()
+			handler(context.Background(), event)
+		}()
+	}
+}
+
+// Wait waits for the subscribers handling published events to return
+func (b *Bus) Wait() {
+	b.running.Wait()
+}
+
+// Log records each event in the log by the IDs it refers to, as an audit
+// trail. Addresses and submitted values are left out.
+func Log(ctx context.Context, event Event) {
+	var details []string
+	if event.Form != nil {
+		details = append(details, fmt.Sprintf("form %d", event.Form.ID))
+	}
+	if event.Submission != nil {
+		details = append(details, fmt.Sprintf("submission %d", event.Submission.ID))
+	}
+	if event.User != nil {
+		details = append(details, fmt.Sprintf("user %d", event.User.ID))
+	}
+	log.Printf("Event %s: %s", event.Name, strings.Join(details, ", "))
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"staticsend/pkg/models"
+)
+
+func TestBus_Publish(t *testing.T) {
+	bus := NewBus()
+
+	var mu sync.Mutex
+	var got []string
+	record := func(label string) Handler {
+		return func(ctx context.Context, event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, label+":"+string(event.Name))
+			if event.At.IsZero() {
+				t.Error("Expected the event's time to be set")
+			}
+		}
+	}
+	bus.Subscribe(SubmissionCreated, record("created"))
+	bus.Subscribe(FormDeleted, record("deleted"))
+	bus.SubscribeAll(record("all"))
+
+	bus.Publish(Event{Name: SubmissionCreated, Submission: &models.Submission{ID: 1}})
+	bus.Wait()
+
+	if len(got) != 2 {
+		t.Fatalf("Expected the submission and catch-all subscribers to run, got %v", got)
+	}
+	for _, want := range []string{"created:submission.created", "all:submission.created"} {
+		found := false
+		for _, g := range got {
+			found = found || g == want
+		}
+		if !found {
+			t.Errorf("Expected %q in %v", want, got)
+		}
+	}
+}
+
+func TestBus_PublishRecoversPanics(t *testing.T) {
+	bus := NewBus()
+	ran := make(chan struct{}, 1)
+	bus.Subscribe(UserRegistered, func(ctx context.Context, event Event) {
+		panic("broken subscriber")
+	})
+	bus.Subscribe(UserRegistered, func(ctx context.Context, event Event) {
+		ran <- struct{}{}
+	})
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	bus.Publish(Event{Name: UserRegistered, User: &models.User{ID: 1}})
+	bus.Wait()
+
+	select {
+	case <-ran:
+	default:
+		t.Error("Expected the other subscriber to run")
+	}
+	if !strings.Contains(logged.String(), "broken subscriber") {
+		t.Errorf("Expected the panic to be logged, got %q", logged.String())
+	}
+}
+
+func TestLog(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	Log(context.Background(), Event{
+		Name: FormDeleted,
+		Form: &models.Form{ID: 3, Name: "Contact"},
+		User: &models.User{ID: 7, Email: "owner@example.com"},
+	})
+
+	if !strings.Contains(logged.String(), "Event form.deleted: form 3, user 7") {
+		t.Errorf("Expected the event to be logged by ID, got %q", logged.String())
+	}
+	if strings.Contains(logged.String(), "owner@example.com") {
+		t.Error("Expected addresses to be left out of the log")
+	}
+}
@@ -0,0 +1,446 @@
+// Package export writes exports of form submissions in the background, so
+// large forms never hold up a request. Export files are kept in a local
+// directory or an S3-compatible bucket and downloaded through signed links
+// that expire.
+package export
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+const (
+	// namePrefix identifies export files so other files in the same
+	// directory or bucket are never pruned
+	namePrefix = "export-"
+	// nameTimeFormat sorts lexically in chronological order
+	nameTimeFormat = "20060102-150405"
+	// batchSize caps the submissions held in memory while writing
+	batchSize = 1000
+)
+
+// Exporter writes queued export jobs to files in a storage.Store and emails
+// their owners a link to download them
+type Exporter struct {
+	db           *database.Database
+	store        storage.Store
+	emailService *email.EmailService
+	secret       []byte
+	linkTTL      time.Duration
+	baseURL      func() string
+	now          func() time.Time
+
+	// mu ensures only one run happens at a time
+	mu   sync.Mutex
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewExporter creates an exporter keeping files in store. Download links
+// are signed with secret, last for linkTTL and are emailed with baseURL in
+// front of them.
+func NewExporter(db *database.Database, store storage.Store, emailService *email.EmailService, secret []byte, linkTTL time.Duration, baseURL func() string) *Exporter {
+	return &Exporter{
+		db:           db,
+		store:        store,
+		emailService: emailService,
+		secret:       secret,
+		linkTTL:      linkTTL,
+		baseURL:      baseURL,
+		now:          time.Now,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Store returns where export files are kept
+func (e *Exporter) Store() storage.Store {
+	return e.store
+}
+
+// Wake asks the worker started by Start to look for queued jobs now rather
+// than on its next tick
+func (e *Exporter) Wake() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run writes every queued export and removes expired ones, returning how
+// many exports it wrote
+func (e *Exporter) Run(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	written := 0
+	for {
+		job, err := models.ClaimExportJobContext(ctx, e.db.Connection)
+		if err != nil {
+			return written, fmt.Errorf("failed to claim export job: %w", err)
+		}
+		if job == nil {
+			break
+		}
+		if err := e.runJob(ctx, job); err != nil {
+			log.Printf("Export %d of form %d failed: %v", job.ID, job.FormID, err)
+			if err := models.FailExportJobContext(ctx, e.db.Connection, job.ID, err.Error(), e.now().Add(e.linkTTL)); err != nil {
+				return written, fmt.Errorf("failed to record failed export: %w", err)
+			}
+			job.Status = models.ExportStatusFailed
+			e.notify(ctx, job)
+			continue
+		}
+		written++
+	}
+
+	if err := e.cleanup(ctx); err != nil {
+		log.Printf("Failed to clean up expired exports: %v", err)
+	}
+	return written, nil
+}
+
+// runJob writes a claimed job's submissions to the store and records the file
+func (e *Exporter) runJob(ctx context.Context, job *models.ExportJob) error {
+	name := fmt.Sprintf("%s%d-%s.%s", namePrefix, job.ID, e.now().UTC().Format(nameTimeFormat), job.Format)
+
+	// Write to a temporary file first so a slow upload never holds a
+	// database connection
+	file, err := os.CreateTemp("", "staticsend-export-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	var rows int
+	if job.Format == models.ExportFormatCSV {
+		rows, err = e.writeCSV(ctx, buffered, job.FormID)
+	} else {
+		rows, err = e.writeJSONL(ctx, buffered, job.FormID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := e.store.Put(ctx, name, file); err != nil {
+		return fmt.Errorf("failed to store export: %w", err)
+	}
+
+	// If this fails the file is unreferenced and removed by the next cleanup
+	expiresAt := e.now().Add(e.linkTTL)
+	if err := models.CompleteExportJobContext(ctx, e.db.Connection, job.ID, name, rows, size, expiresAt); err != nil {
+		return fmt.Errorf("failed to record export: %w", err)
+	}
+
+	done, err := models.GetExportJobByIDContext(ctx, e.db.Connection, job.ID)
+	if err != nil {
+		log.Printf("Failed to reload export %d: %v", job.ID, err)
+		return nil
+	}
+	if done != nil {
+		e.notify(ctx, done)
+	}
+	return nil
+}
+
+// eachBatch calls fn with a form's submissions, except spam, a batch at a
+// time in ID order
+func (e *Exporter) eachBatch(ctx context.Context, formID int64, fn func([]models.Submission) error) error {
+	var afterID int64
+	for {
+		submissions, err := models.GetSubmissionsAfterIDContext(ctx, e.db.Connection, formID, afterID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(submissions) == 0 {
+			return nil
+		}
+		if err := fn(submissions); err != nil {
+			return err
+		}
+		afterID = submissions[len(submissions)-1].ID
+	}
+}
+
+// writeJSONL writes a form's submissions to w, one JSON object per line
+func (e *Exporter) writeJSONL(ctx context.Context, w io.Writer, formID int64) (int, error) {
+	encoder := json.NewEncoder(w)
+	rows := 0
+	err := e.eachBatch(ctx, formID, func(submissions []models.Submission) error {
+		for _, s := range submissions {
+			if err := encoder.Encode(s); err != nil {
+				return err
+			}
+			rows++
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// csvColumns are written before the submitted fields
+var csvColumns = []string{"id", "created_at", "status", "ip_address", "user_agent", "referrer"}
+
+// writeCSV writes a form's submissions to w with a column for every field
+// submitted. It reads the submissions twice, first to find the fields.
+// Fields are in the order the form's field catalog first saw them, which
+// is usually the form's own order; fields it hasn't seen, such as those of
+// spam, follow by name.
+func (e *Exporter) writeCSV(ctx context.Context, w io.Writer, formID int64) (int, error) {
+	seen := make(map[string]bool)
+	err := e.eachBatch(ctx, formID, func(submissions []models.Submission) error {
+		for _, s := range submissions {
+			for name := range submittedFields(s) {
+				seen[name] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	catalog, err := models.GetFieldCatalogContext(ctx, e.db.Connection, formID)
+	if err != nil {
+		return 0, err
+	}
+	fields := make([]string, 0, len(seen))
+	for _, name := range catalog.Names() {
+		if seen[name] {
+			fields = append(fields, name)
+			delete(seen, name)
+		}
+	}
+	unseen := make([]string, 0, len(seen))
+	for name := range seen {
+		unseen = append(unseen, name)
+	}
+	sort.Strings(unseen)
+	fields = append(fields, unseen...)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append(append([]string{}, csvColumns...), fields...)); err != nil {
+		return 0, err
+	}
+	rows := 0
+	err = e.eachBatch(ctx, formID, func(submissions []models.Submission) error {
+		for _, s := range submissions {
+			values := submittedFields(s)
+			record := []string{
+				strconv.FormatInt(s.ID, 10),
+				s.CreatedAt.UTC().Format(time.RFC3339),
+				s.Status,
+				s.IPAddress,
+				csvSafe(s.UserAgent),
+				csvSafe(s.Referrer),
+			}
+			for _, name := range fields {
+				record = append(record, csvSafe(values[name]))
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+			rows++
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	writer.Flush()
+	return rows, writer.Error()
+}
+
+// submittedFields returns a submission's fields as text, with values that
+// aren't strings as JSON
+func submittedFields(s models.Submission) map[string]string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(s.SubmittedData, &raw); err != nil {
+		return nil
+	}
+	fields := make(map[string]string, len(raw))
+	for name, value := range raw {
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			text = string(value)
+		}
+		fields[name] = text
+	}
+	return fields
+}
+
+// csvSafe stops spreadsheets treating submitted text as a formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// notify emails the owner of a finished job
+func (e *Exporter) notify(ctx context.Context, job *models.ExportJob) {
+	if e.emailService == nil {
+		return
+	}
+	user, err := models.GetUserByIDContext(ctx, e.db.Connection, job.UserID)
+	if err != nil || user == nil {
+		log.Printf("Failed to find the owner of export %d: %v", job.ID, err)
+		return
+	}
+
+	var subject, body string
+	if job.Status == models.ExportStatusDone {
+		subject = fmt.Sprintf("Your export of %s is ready", job.FormName)
+		body = fmt.Sprintf("Your export of %d submissions to %s is ready.\n\nDownload it before %s:\n%s%s\n",
+			job.RowCount, job.FormName, job.ExpiresAt.UTC().Format("Jan 2, 2006 15:04 MST"), e.baseURL(), e.DownloadPath(job))
+	} else {
+		subject = fmt.Sprintf("Your export of %s failed", job.FormName)
+		body = fmt.Sprintf("Your export of submissions to %s couldn't be written. Please try again from the dashboard.\n", job.FormName)
+	}
+	// The owner is waiting on it, so it doesn't queue behind notifications
+	message := email.EmailJob{To: []string{user.Email}, Subject: subject, Body: body, Priority: email.PriorityHigh}
+	if err := e.emailService.Enqueue(message); err != nil {
+		log.Printf("Failed to queue export email for export %d: %v", job.ID, err)
+	}
+}
+
+// DownloadPath returns the signed path a finished job is downloaded from,
+// or "" if it has no file
+func (e *Exporter) DownloadPath(job *models.ExportJob) string {
+	if job.Status != models.ExportStatusDone || job.ExpiresAt == nil {
+		return ""
+	}
+	expires := job.ExpiresAt.Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {e.sign(job.ID, expires)},
+	}
+	return fmt.Sprintf("/exports/%d/download?%s", job.ID, query.Encode())
+}
+
+// Verify reports whether a download link's signature is valid for the job
+// and the link hasn't expired
+func (e *Exporter) Verify(jobID int64, expires, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || e.now().Unix() >= expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(e.sign(jobID, expiresAt)))
+}
+
+// sign returns the signature of a job's download link
+func (e *Exporter) sign(jobID, expires int64) string {
+	mac := hmac.New(sha256.New, e.secret)
+	fmt.Fprintf(mac, "export:%d:%d", jobID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// cleanup deletes expired exports and their files, and export files no job
+// refers to, left behind when recording an export failed
+func (e *Exporter) cleanup(ctx context.Context) error {
+	names, err := models.DeleteExpiredExportJobsContext(ctx, e.db.Connection, e.now())
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := e.store.Delete(ctx, name); err != nil {
+			log.Printf("Failed to delete expired export %s: %v", name, err)
+		}
+	}
+
+	files, err := e.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, namePrefix) {
+			continue
+		}
+		exists, err := models.ExportFileExistsContext(ctx, e.db.Connection, f.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := e.store.Delete(ctx, f.Name); err != nil {
+			return err
+		}
+		log.Printf("Deleted unreferenced export %s", f.Name)
+	}
+	return nil
+}
+
+// Start writes queued exports every interval, or as soon as Wake is called,
+// until Stop is called. Jobs left running by a restart are queued again.
+func (e *Exporter) Start(interval time.Duration) {
+	if err := models.ResetRunningExportJobs(e.db.Connection); err != nil {
+		log.Printf("Failed to requeue interrupted exports: %v", err)
+	}
+
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+			case <-e.wake:
+			}
+			written, err := e.Run(context.Background())
+			if err != nil {
+				log.Printf("Scheduled export failed: %v", err)
+			}
+			if written > 0 {
+				log.Printf("Wrote %d exports to %s", written, e.store)
+			}
+		}
+	}()
+}
+
+// Stop stops the worker, waiting for a running export to finish
+func (e *Exporter) Stop() {
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+	e.stop = nil
+}
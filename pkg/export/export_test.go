@@ -0,0 +1,191 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+func setupExporter(t *testing.T) (*Exporter, *database.Database, *email.EmailService) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "staticsend.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "exports"), "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// No workers, so queued emails stay in the queue
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25}, 10, 0, 0)
+	e := NewExporter(db, store, emailService, []byte("secret"), time.Hour, func() string { return "https://forms.example.com" })
+	return e, db, emailService
+}
+
+// readExport returns the contents of a finished export's file
+func readExport(t *testing.T, e *Exporter, job *models.ExportJob) string {
+	t.Helper()
+	file, err := e.store.Open(context.Background(), job.FileName)
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	return string(data)
+}
+
+func TestRun_WritesExports(t *testing.T) {
+	e, db, emailService := setupExporter(t)
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "export-key")
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"name":"Ann","message":"=1+1"}`))
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"name":"Bob","tags":["a","b"]}`))
+	models.CreateSpamSubmission(db.Connection, form.ID, "127.0.0.1", "bot", "", []byte(`{"name":"Spam"}`), models.SpamReasonHoneypot)
+
+	csvJob, _ := models.CreateExportJob(db.Connection, user.ID, form.ID, models.ExportFormatCSV)
+	jsonlJob, _ := models.CreateExportJob(db.Connection, user.ID, form.ID, models.ExportFormatJSONL)
+
+	written, err := e.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if written != 2 {
+		t.Fatalf("Expected 2 exports, got %d", written)
+	}
+	if emailService.QueueSize() != 2 {
+		t.Errorf("Expected an email per export, got %d", emailService.QueueSize())
+	}
+
+	job, _ := models.GetExportJobByID(db.Connection, csvJob.ID)
+	if job.Status != models.ExportStatusDone || job.RowCount != 2 {
+		t.Fatalf("Expected a finished export of 2 submissions, got %+v", job)
+	}
+	records, err := csv.NewReader(strings.NewReader(readExport(t, e, job))).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	header := strings.Join(records[0], ",")
+	if header != "id,created_at,status,ip_address,user_agent,referrer,message,name,tags" {
+		t.Errorf("Unexpected header %q", header)
+	}
+	if len(records) != 3 || records[1][6] != "'=1+1" || records[1][7] != "Ann" || records[2][8] != `["a","b"]` {
+		t.Errorf("Unexpected rows %v", records[1:])
+	}
+
+	job, _ = models.GetExportJobByID(db.Connection, jsonlJob.ID)
+	if lines := strings.Count(readExport(t, e, job), "\n"); lines != 2 {
+		t.Errorf("Expected 2 JSON lines, got %d", lines)
+	}
+	if int64(len(readExport(t, e, job))) != job.Size {
+		t.Errorf("Expected the recorded size to match the file")
+	}
+}
+
+func TestRun_OrdersColumnsByFieldCatalog(t *testing.T) {
+	e, db, _ := setupExporter(t)
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "export-key")
+	first, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"name":"Ann"}`))
+	if err := models.RecordFormFields(db.Connection, form.ID, first.SubmittedData, first.CreatedAt); err != nil {
+		t.Fatalf("Failed to catalog fields: %v", err)
+	}
+	data := []byte(`{"name":"Bob","email":"bob@example.com"}`)
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", data)
+	if err := models.RecordFormFields(db.Connection, form.ID, data, first.CreatedAt.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to catalog fields: %v", err)
+	}
+	// Fields the catalog hasn't seen follow by name
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"zip":"2000","city":"Sydney"}`))
+
+	csvJob, _ := models.CreateExportJob(db.Connection, user.ID, form.ID, models.ExportFormatCSV)
+	if _, err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	job, _ := models.GetExportJobByID(db.Connection, csvJob.ID)
+	records, err := csv.NewReader(strings.NewReader(readExport(t, e, job))).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if header := strings.Join(records[0], ","); header != "id,created_at,status,ip_address,user_agent,referrer,name,email,city,zip" {
+		t.Errorf("Unexpected header %q", header)
+	}
+}
+
+func TestDownloadPath(t *testing.T) {
+	e, db, _ := setupExporter(t)
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "export-key")
+	queued, _ := models.CreateExportJob(db.Connection, user.ID, form.ID, models.ExportFormatCSV)
+	if path := e.DownloadPath(queued); path != "" {
+		t.Errorf("Expected no link for a queued export, got %q", path)
+	}
+	e.Run(context.Background())
+	job, _ := models.GetExportJobByID(db.Connection, queued.ID)
+
+	link, err := url.Parse(e.DownloadPath(job))
+	if err != nil || link.Path != "/exports/"+strconv.FormatInt(job.ID, 10)+"/download" {
+		t.Fatalf("Unexpected download link %v", link)
+	}
+	expires, signature := link.Query().Get("expires"), link.Query().Get("signature")
+	if !e.Verify(job.ID, expires, signature) {
+		t.Error("Expected the link to verify")
+	}
+	if e.Verify(job.ID+1, expires, signature) {
+		t.Error("Expected the link not to verify for another export")
+	}
+	later, _ := strconv.ParseInt(expires, 10, 64)
+	if e.Verify(job.ID, strconv.FormatInt(later+3600, 10), signature) {
+		t.Error("Expected a changed expiry not to verify")
+	}
+
+	e.now = func() time.Time { return job.ExpiresAt.Add(time.Second) }
+	if e.Verify(job.ID, expires, signature) {
+		t.Error("Expected an expired link not to verify")
+	}
+}
+
+func TestRun_CleansUpExpiredExports(t *testing.T) {
+	e, db, _ := setupExporter(t)
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "export-key")
+	queued, _ := models.CreateExportJob(db.Connection, user.ID, form.ID, models.ExportFormatJSONL)
+	e.Run(context.Background())
+	job, _ := models.GetExportJobByID(db.Connection, queued.ID)
+
+	// Unreferenced export files are pruned, other files are left alone
+	e.store.Put(context.Background(), "export-999-20260101-000000.csv", bytes.NewReader(nil))
+	e.store.Put(context.Background(), "notes.txt", bytes.NewReader(nil))
+	e.Run(context.Background())
+	files, _ := e.store.List(context.Background())
+	if len(files) != 2 {
+		t.Fatalf("Expected the export and unrelated file to remain, got %v", files)
+	}
+
+	e.now = func() time.Time { return job.ExpiresAt.Add(time.Second) }
+	e.Run(context.Background())
+	if job, _ := models.GetExportJobByID(db.Connection, job.ID); job != nil {
+		t.Error("Expected the expired export to be deleted")
+	}
+	files, _ = e.store.List(context.Background())
+	if len(files) != 1 || files[0].Name != "notes.txt" {
+		t.Errorf("Expected only the unrelated file to remain, got %v", files)
+	}
+}
@@ -0,0 +1,49 @@
+// Package flash carries one-off confirmation messages, such as "Form
+// created", across a redirect in a short-lived cookie.
+package flash
+
+import (
+	"encoding/base64"
+	"net/http"
+)
+
+// cookieName is the cookie holding the pending message
+const cookieName = "flash"
+
+// maxAge is how long a message waits to be shown, in seconds. It only has
+// to survive the redirect that follows the action.
+const maxAge = 60
+
+// Set stores a message to show on the next page rendered for the user
+func Set(w http.ResponseWriter, message string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(message)),
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Pop returns the pending message, if any, and clears it so it's only
+// shown once
+func Pop(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	message, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return string(message)
+}
@@ -0,0 +1,45 @@
+package flash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetAndPop(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Set(rr, `Form "Contact; café" created`)
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge != maxAge {
+		t.Fatalf("Expected a short-lived flash cookie, got %+v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	if got := Pop(rr, req); got != `Form "Contact; café" created` {
+		t.Errorf("Unexpected message %q", got)
+	}
+	// Popping clears the cookie so the message is only shown once
+	cleared := rr.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Errorf("Expected the flash cookie to be cleared, got %+v", cleared)
+	}
+}
+
+func TestPopWithoutMessage(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if got := Pop(rr, httptest.NewRequest("GET", "/", nil)); got != "" {
+		t.Errorf("Expected no message, got %q", got)
+	}
+	if len(rr.Result().Cookies()) != 0 {
+		t.Error("Expected no cookie to be set")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: "not base64!"})
+	if got := Pop(httptest.NewRecorder(), req); got != "" {
+		t.Errorf("Expected a malformed message to be ignored, got %q", got)
+	}
+}
@@ -0,0 +1,368 @@
+// Package geoip finds the country an IP address is in using a MaxMind
+// database, such as the free GeoLite2 Country or City databases. It reads
+// the MaxMind DB format itself, keeping the whole file in memory.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+)
+
+// metadataMarker starts the metadata at the end of a MaxMind DB file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSeparator is the zeroed bytes between the search tree and the data
+const dataSeparator = 16
+
+// ErrInvalidDatabase is returned for files that aren't MaxMind databases
+var ErrInvalidDatabase = errors.New("not a MaxMind DB file")
+
+// DB is an opened MaxMind database
+type DB struct {
+	tree      []byte
+	data      []byte
+	nodeCount uint
+	// recordSize is the size of each of a node's two records, in bits
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node IPv4 addresses are looked up from in an IPv6
+	// database, found by following 96 zero bits from the root
+	ipv4Start uint
+	// Type is the database's type, such as GeoLite2-Country
+	Type string
+}
+
+// Open reads the MaxMind database at path
+func Open(path string) (*DB, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := New(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// New reads a MaxMind database from its contents
+func New(file []byte) (*DB, error) {
+	// The marker may appear in the data too, so the last one is used
+	at := bytes.LastIndex(file, metadataMarker)
+	if at < 0 {
+		return nil, ErrInvalidDatabase
+	}
+	metadata, _, err := (&decoder{buf: file[at+len(metadataMarker):]}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	fields, ok := metadata.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidDatabase
+	}
+
+	db := &DB{
+		nodeCount:  uintField(fields, "node_count"),
+		recordSize: uintField(fields, "record_size"),
+		ipVersion:  uintField(fields, "ip_version"),
+	}
+	db.Type, _ = fields["database_type"].(string)
+	if db.recordSize != 24 && db.recordSize != 28 && db.recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, db.recordSize)
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, db.ipVersion)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSeparator > uint(at) {
+		return nil, fmt.Errorf("%w: search tree is larger than the file", ErrInvalidDatabase)
+	}
+	db.tree = file[:treeSize]
+	db.data = file[treeSize+dataSeparator : at]
+
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// uintField reads a number from the metadata
+func uintField(fields map[string]interface{}, name string) uint {
+	n, _ := fields[name].(uint64)
+	return uint(n)
+}
+
+// Country returns the ISO 3166-1 code of the country an IP address is in,
+// such as "NZ", or "" if the database doesn't know. Addresses without a
+// country of their own, such as satellite providers, get the country
+// they're registered in.
+func (db *DB) Country(ip string) string {
+	if db == nil {
+		return ""
+	}
+	record, err := db.Lookup(net.ParseIP(ip))
+	if err != nil || record == nil {
+		return ""
+	}
+	for _, field := range []string{"country", "registered_country"} {
+		if country, ok := record[field].(map[string]interface{}); ok {
+			if code, ok := country["iso_code"].(string); ok && code != "" {
+				return strings.ToUpper(code)
+			}
+		}
+	}
+	return ""
+}
+
+// Lookup returns the record for an IP address, or nil if there isn't one
+func (db *DB) Lookup(ip net.IP) (map[string]interface{}, error) {
+	if ip == nil {
+		return nil, nil
+	}
+	node, bits := uint(0), 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		node = db.ipv4Start
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < bits && node < db.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+	if node <= db.nodeCount {
+		// node_count itself means the address isn't in the database
+		return nil, nil
+	}
+
+	offset := node - db.nodeCount - dataSeparator
+	if offset >= uint(len(db.data)) {
+		return nil, fmt.Errorf("%w: record points outside the data", ErrInvalidDatabase)
+	}
+	value, _, err := (&decoder{buf: db.data}).decode(offset)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+// record reads one of a node's records: 0 for the left, 1 for the right
+func (db *DB) record(node, side uint) uint {
+	size := db.recordSize / 4
+	n := db.tree[node*size : node*size+size]
+	switch db.recordSize {
+	case 24:
+		n = n[side*3:]
+		return uint(n[0])<<16 | uint(n[1])<<8 | uint(n[2])
+	case 28:
+		// The middle byte holds the high nibble of each record
+		if side == 0 {
+			return uint(n[3]&0xf0)<<20 | uint(n[0])<<16 | uint(n[1])<<8 | uint(n[2])
+		}
+		return uint(n[3]&0x0f)<<24 | uint(n[4])<<16 | uint(n[5])<<8 | uint(n[6])
+	default:
+		return uint(binary.BigEndian.Uint32(n[side*4:]))
+	}
+}
+
+// Data types of the MaxMind DB format
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth stops pointers that loop from recursing forever
+const maxDepth = 32
+
+// decoder reads values from a data section, where pointers are offsets
+// from its start
+type decoder struct {
+	buf   []byte
+	depth int
+}
+
+// decode reads the value at offset, returning it and the offset after it
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	if d.depth++; d.depth > maxDepth {
+		return nil, 0, errors.New("data is nested too deeply")
+	}
+	defer func() { d.depth-- }()
+
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	control := d.buf[offset]
+	offset++
+	kind := uint(control >> 5)
+
+	if kind == typePointer {
+		target, next, err := d.pointer(control, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Decoding carries on after the pointer, not after what it points at
+		value, _, err := d.decode(target)
+		return value, next, err
+	}
+	if kind == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errors.New("unexpected end of data")
+		}
+		kind = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.size(control, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key isn't a string")
+			}
+			if m[name], offset, err = d.decode(next); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, size)
+		for i := range a {
+			if a[i], offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+	switch kind {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("double isn't 8 bytes")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("float isn't 4 bytes")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("unsigned integer is too long")
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("signed integer is too long")
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int32(n), offset, nil
+	case typeUint128:
+		// Too big for a Go integer, and not needed for countries
+		return append([]byte(nil), b...), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", kind)
+}
+
+// size reads a value's size from its control byte and the bytes after it
+func (d *decoder) size(control byte, offset uint) (uint, uint, error) {
+	size := uint(control & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+	extra := size - 28
+	if offset+extra > uint(len(d.buf)) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+	var n uint
+	for _, c := range d.buf[offset : offset+extra] {
+		n = n<<8 | uint(c)
+	}
+	switch size {
+	case 29:
+		return 29 + n, offset + extra, nil
+	case 30:
+		return 285 + n, offset + extra, nil
+	default:
+		return 65821 + n, offset + extra, nil
+	}
+}
+
+// pointer reads where a pointer points, returning it and the offset after
+// the pointer
+func (d *decoder) pointer(control byte, offset uint) (uint, uint, error) {
+	length := uint(control>>3)&0x3 + 1
+	if offset+length > uint(len(d.buf)) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+	b := d.buf[offset : offset+length]
+	var n uint
+	if length < 4 {
+		n = uint(control & 0x7)
+	}
+	for _, c := range b {
+		n = n<<8 | uint(c)
+	}
+	switch length {
+	case 2:
+		n += 2048
+	case 3:
+		n += 526336
+	}
+	return n, offset + length, nil
+}
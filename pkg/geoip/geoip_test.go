@@ -0,0 +1,204 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildDB writes a MaxMind database mapping networks to records with the
+// given country codes, in the format Open reads
+func buildDB(t *testing.T, ipVersion, recordSize int, networks map[string]string) []byte {
+	t.Helper()
+	bits := 32
+	if ipVersion == 6 {
+		bits = 128
+	}
+
+	// Records below 0 are placeholders: -1 for no data, and -2-i for the
+	// ith data record
+	nodes := [][2]int{{-1, -1}}
+	var data bytes.Buffer
+	offsets := map[string]int{}
+	for cidr, country := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("Invalid network %q: %v", cidr, err)
+		}
+		ip := network.IP
+		ones, _ := network.Mask.Size()
+		if ip4 := ip.To4(); ip4 != nil && bits == 128 {
+			ip, ones = append(make(net.IP, 12), ip4...), ones+96
+		}
+
+		if _, ok := offsets[country]; !ok {
+			offsets[country] = data.Len()
+			writeCountry(&data, country)
+		}
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = -2 - offsets[country]
+				break
+			}
+			if nodes[node][bit] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	var file bytes.Buffer
+	count := len(nodes)
+	for _, node := range nodes {
+		var records [2]uint32
+		for side, record := range node {
+			switch {
+			case record == -1:
+				records[side] = uint32(count)
+			case record < -1:
+				records[side] = uint32(count + dataSeparator + (-2 - record))
+			default:
+				records[side] = uint32(record)
+			}
+		}
+		switch recordSize {
+		case 24:
+			for _, r := range records {
+				file.Write([]byte{byte(r >> 16), byte(r >> 8), byte(r)})
+			}
+		case 28:
+			file.Write([]byte{byte(records[0] >> 16), byte(records[0] >> 8), byte(records[0]),
+				byte(records[0]>>20)&0xf0 | byte(records[1]>>24)&0x0f,
+				byte(records[1] >> 16), byte(records[1] >> 8), byte(records[1])})
+		default:
+			binary.Write(&file, binary.BigEndian, records)
+		}
+	}
+	file.Write(make([]byte, dataSeparator))
+	file.Write(data.Bytes())
+	file.Write(metadataMarker)
+	writeMap(&file, 4)
+	writeString(&file, "node_count")
+	writeUint(&file, typeUint32, uint32(count))
+	writeString(&file, "record_size")
+	writeUint(&file, typeUint16, uint32(recordSize))
+	writeString(&file, "ip_version")
+	writeUint(&file, typeUint16, uint32(ipVersion))
+	writeString(&file, "database_type")
+	writeString(&file, "Test-Country")
+	return file.Bytes()
+}
+
+func writeCountry(b *bytes.Buffer, code string) {
+	writeMap(b, 1)
+	writeString(b, "country")
+	writeMap(b, 2)
+	writeString(b, "iso_code")
+	writeString(b, code)
+	writeString(b, "geoname_id")
+	// Extended type: uint32 doesn't need it, but int32 does
+	b.Write([]byte{4, typeInt32 - 7, 0, 0, 0x7, 0xd0})
+}
+
+func writeMap(b *bytes.Buffer, size int) {
+	b.WriteByte(typeMap<<5 | byte(size))
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteByte(typeString<<5 | byte(len(s)))
+	b.WriteString(s)
+}
+
+func writeUint(b *bytes.Buffer, kind byte, n uint32) {
+	b.WriteByte(kind<<5 | 4)
+	binary.Write(b, binary.BigEndian, n)
+}
+
+func TestCountry(t *testing.T) {
+	networks := map[string]string{
+		"203.0.113.0/24":  "nz",
+		"198.51.100.0/25": "AU",
+		"2001:db8:1::/48": "DE",
+		"192.0.2.128/26":  "NZ",
+	}
+	for _, tt := range []struct {
+		ipVersion, recordSize int
+	}{{4, 24}, {6, 24}, {6, 28}, {6, 32}} {
+		db, err := New(buildDB(t, tt.ipVersion, tt.recordSize, networks))
+		if err != nil {
+			t.Fatalf("IPv%d database with %d-bit records: %v", tt.ipVersion, tt.recordSize, err)
+		}
+		if db.Type != "Test-Country" {
+			t.Errorf("Expected the database type to be read, got %q", db.Type)
+		}
+		want := map[string]string{
+			"203.0.113.7":    "NZ",
+			"198.51.100.1":   "AU",
+			"198.51.100.200": "",
+			"192.0.2.130":    "NZ",
+			"192.0.2.1":      "",
+			"not an ip":      "",
+			"2001:db8:1::5":  "DE",
+			"2001:db8:2::5":  "",
+		}
+		if tt.ipVersion == 4 {
+			want["2001:db8:1::5"] = ""
+		}
+		for ip, country := range want {
+			if got := db.Country(ip); got != country {
+				t.Errorf("IPv%d database with %d-bit records: Country(%q) = %q, want %q", tt.ipVersion, tt.recordSize, ip, got, country)
+			}
+		}
+	}
+
+	var none *DB
+	if got := none.Country("203.0.113.7"); got != "" {
+		t.Errorf("Expected no country without a database, got %q", got)
+	}
+}
+
+func TestDecodePointer(t *testing.T) {
+	// "hi", then a pointer to it, then a pointer 2048 bytes on
+	buf := []byte{typeString<<5 | 2, 'h', 'i', typePointer << 5, 0, typePointer<<5 | 1<<3, 0, 0}
+	value, next, err := (&decoder{buf: buf}).decode(3)
+	if err != nil || value != "hi" || next != 5 {
+		t.Errorf("Expected the pointer to be followed, got %v, %d, %v", value, next, err)
+	}
+	if _, _, err := (&decoder{buf: buf}).decode(5); err == nil {
+		t.Error("Expected a pointer past the end of the data to fail")
+	}
+	loop := []byte{typePointer << 5, 0}
+	if _, _, err := (&decoder{buf: loop}).decode(0); err == nil {
+		t.Error("Expected a pointer to itself to fail")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.mmdb")
+	if err := os.WriteFile(path, buildDB(t, 6, 24, map[string]string{"203.0.113.0/24": "NZ"}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got := db.Country("203.0.113.7"); got != "NZ" {
+		t.Errorf("Expected NZ, got %q", got)
+	}
+
+	notDB := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notDB, []byte("not a database"), 0600)
+	if _, err := Open(notDB); err == nil {
+		t.Error("Expected opening a file that isn't a database to fail")
+	}
+	if _, err := Open(filepath.Join(dir, "missing.mmdb")); err == nil {
+		t.Error("Expected opening a missing file to fail")
+	}
+}
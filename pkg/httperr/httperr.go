@@ -0,0 +1,102 @@
+// Package httperr writes error responses with a machine-readable code, so
+// client libraries can branch on what went wrong rather than parse
+// messages, which may be reworded.
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Code says what kind of error a response is. Codes are part of the API,
+// so they aren't renamed once added.
+type Code string
+
+// Error codes
+const (
+	// ValidationError is a request with missing or invalid data
+	ValidationError Code = "validation_error"
+	Unauthorized    Code = "unauthorized"
+	Forbidden       Code = "forbidden"
+	NotFound        Code = "not_found"
+	// FormNotFound is a form key or ID that doesn't exist or, for
+	// signed-in users, belongs to another user
+	FormNotFound Code = "form_not_found"
+	Conflict     Code = "conflict"
+	TooLarge     Code = "too_large"
+	// TurnstileFailed is a submission without a valid Turnstile token, or
+	// one that Cloudflare couldn't verify
+	TurnstileFailed Code = "turnstile_failed"
+	// Blocked is a submission refused for the address or country it came
+	// from
+	Blocked Code = "blocked"
+	// SpamRejected is a submission scored as certain spam
+	SpamRejected Code = "spam_rejected"
+	RateLimited  Code = "rate_limited"
+	// QuotaExceeded is a request over the user's submission, storage or
+	// form limit
+	QuotaExceeded Code = "quota_exceeded"
+	Maintenance   Code = "maintenance"
+	// Unavailable is a server too busy to take the request, or a service
+	// it depends on that can't be reached
+	Unavailable Code = "unavailable"
+	Internal    Code = "internal_error"
+)
+
+// Header carries the code of every error response, plain text ones too
+const Header = "X-Error-Code"
+
+// Envelope is the body of JSON error responses
+type Envelope struct {
+	Success bool   `json:"success"`
+	Error   Code   `json:"error"`
+	Message string `json:"message"`
+}
+
+// Write writes an error response: the JSON envelope to API clients, or the
+// message as plain text, as http.Error does, to browsers
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code, message string) {
+	w.Header().Set(Header, string(code))
+	if !WantsJSON(r) {
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{Error: code, Message: message})
+}
+
+// WantsJSON reports whether a request expects a JSON response
+func WantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// StatusCode returns the code for an error status without a more specific
+// one
+func StatusCode(status int) Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ValidationError
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusRequestEntityTooLarge:
+		return TooLarge
+	case http.StatusTooManyRequests:
+		return RateLimited
+	case http.StatusPaymentRequired:
+		return QuotaExceeded
+	case http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return Unavailable
+	default:
+		return Internal
+	}
+}
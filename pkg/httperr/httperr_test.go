@@ -0,0 +1,62 @@
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	// API requests get the JSON envelope
+	rr := httptest.NewRecorder()
+	Write(rr, httptest.NewRequest("POST", "/api/v1/submit/key", nil), http.StatusNotFound, FormNotFound, "Form not found")
+	var body Envelope
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rr.Code != http.StatusNotFound || body.Success || body.Error != FormNotFound || body.Message != "Form not found" {
+		t.Errorf("Expected the envelope with the code, got %d %+v", rr.Code, body)
+	}
+	if rr.Header().Get("Content-Type") != "application/json" || rr.Header().Get(Header) != "form_not_found" {
+		t.Errorf("Expected JSON with the code header, got %v", rr.Header())
+	}
+
+	// So do other requests asking for JSON
+	r := httptest.NewRequest("GET", "/forms/1/view", nil)
+	r.Header.Set("Accept", "application/json")
+	rr = httptest.NewRecorder()
+	Write(rr, r, http.StatusTooManyRequests, RateLimited, "Rate limit exceeded")
+	if !strings.Contains(rr.Body.String(), `"error":"rate_limited"`) {
+		t.Errorf("Expected the envelope, got %s", rr.Body.String())
+	}
+
+	// Browsers get the message as text, with the code in the header
+	r = httptest.NewRequest("GET", "/forms/1/view", nil)
+	r.Header.Set("Accept", "text/html")
+	rr = httptest.NewRecorder()
+	Write(rr, r, http.StatusNotFound, FormNotFound, "Form not found")
+	if strings.TrimSpace(rr.Body.String()) != "Form not found" || rr.Header().Get(Header) != "form_not_found" {
+		t.Errorf("Expected the plain message with the code header, got %q %v", rr.Body.String(), rr.Header())
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := map[int]Code{
+		http.StatusBadRequest:          ValidationError,
+		http.StatusUnprocessableEntity: ValidationError,
+		http.StatusUnauthorized:        Unauthorized,
+		http.StatusNotFound:            NotFound,
+		http.StatusTooManyRequests:     RateLimited,
+		http.StatusPaymentRequired:     QuotaExceeded,
+		http.StatusGatewayTimeout:      Unavailable,
+		http.StatusInternalServerError: Internal,
+		http.StatusTeapot:              Internal,
+	}
+	for status, want := range tests {
+		if got := StatusCode(status); got != want {
+			t.Errorf("StatusCode(%d) = %q, expected %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Package importer reads submissions exported from Formspree and Netlify
+// Forms, as CSV or JSON, so a form's history can move to staticSend with it.
+// Each submission keeps the time it was sent, and the sender's IP address,
+// user agent and referring page when the export has them.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"staticsend/pkg/models"
+)
+
+// Columns, in lower case, that hold a submission's details rather than its
+// fields
+var (
+	timeColumns      = map[string]bool{"_date": true, "date": true, "created_at": true, "submitted_at": true, "timestamp": true}
+	ipColumns        = map[string]bool{"ip": true, "_ip": true, "ip_address": true}
+	userAgentColumns = map[string]bool{"user_agent": true, "_user_agent": true, "useragent": true}
+	referrerColumns  = map[string]bool{"referrer": true, "_referrer": true, "referer": true}
+	// ignoredColumns are the services' own bookkeeping
+	ignoredColumns = map[string]bool{"id": true, "_id": true, "number": true, "form_id": true, "form_name": true, "site_id": true, "site_url": true}
+)
+
+// timeFormats are the formats submission times are read in. Times without
+// a zone are UTC.
+var timeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"1/2/2006 15:04:05",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 15:04",
+	"2006-01-02",
+}
+
+// Mapping renames fields as they're imported. Fields renamed to "" are left
+// out. A field renamed to created_at becomes the submission's time.
+type Mapping map[string]string
+
+// ParseMapping reads "from=to" entries, such as "Your Email=email", into a
+// Mapping. An entry with nothing after the = leaves the field out.
+func ParseMapping(entries []string) (Mapping, error) {
+	mapping := Mapping{}
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(entry, "=")
+		from = strings.TrimSpace(from)
+		if !ok || from == "" {
+			return nil, fmt.Errorf("%q isn't a field mapping, such as from=to", entry)
+		}
+		mapping[from] = strings.TrimSpace(to)
+	}
+	return mapping, nil
+}
+
+// Read reads exported submissions, telling CSV from JSON by the first
+// character. JSON can be an array of submissions, as Netlify's API lists
+// them, or an object with a "submissions" array, as Formspree exports them.
+func Read(r io.Reader, mapping Mapping) ([]models.ImportedSubmission, error) {
+	reader := bufio.NewReader(r)
+	// Spreadsheets often start their CSV files with a byte order mark
+	if bom, err := reader.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		reader.Discard(3)
+	}
+	for {
+		c, err := reader.Peek(1)
+		if err == io.EOF {
+			return nil, errors.New("the file is empty")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch c[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.Discard(1)
+			continue
+		case '[', '{':
+			return readJSON(reader, mapping)
+		}
+		return readCSV(reader, mapping)
+	}
+}
+
+// readCSV reads a CSV export, whose first row names the fields
+func readCSV(r io.Reader, mapping Mapping) ([]models.ImportedSubmission, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header row: %w", err)
+	}
+
+	var submissions []models.ImportedSubmission
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return submissions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := make(map[string]string, len(header))
+		for i, name := range header {
+			fields[name] = record[i]
+		}
+		submission, err := newSubmission(fields, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		submissions = append(submissions, submission)
+	}
+}
+
+// readJSON reads a JSON export. Netlify's submissions hold their fields in
+// "data", alongside details of the form that are left out.
+func readJSON(r io.Reader, mapping Mapping) ([]models.ImportedSubmission, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var export interface{}
+	if err := decoder.Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if object, ok := export.(map[string]interface{}); ok {
+		export = object["submissions"]
+	}
+	list, ok := export.([]interface{})
+	if !ok {
+		return nil, errors.New(`expected a list of submissions, or an object with a "submissions" list`)
+	}
+
+	submissions := make([]models.ImportedSubmission, 0, len(list))
+	for i, item := range list {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("submission %d isn't an object", i+1)
+		}
+		values := object
+		if data, ok := object["data"].(map[string]interface{}); ok {
+			values = data
+			if _, ok := data["created_at"]; !ok {
+				data["created_at"] = object["created_at"]
+			}
+		}
+
+		fields := make(map[string]string, len(values))
+		for name, value := range values {
+			fields[name] = text(value)
+		}
+		submission, err := newSubmission(fields, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("submission %d: %w", i+1, err)
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, nil
+}
+
+// text turns a JSON value into a field's text. Lists, such as checkboxes,
+// are joined with commas.
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := text(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// newSubmission builds a submission from an exported one's fields, after
+// renaming them. Empty fields are left out, as they are from submissions.
+func newSubmission(fields map[string]string, mapping Mapping) (models.ImportedSubmission, error) {
+	var submission models.ImportedSubmission
+	data := make(map[string]string, len(fields))
+	for name, value := range fields {
+		if to, ok := mapping[name]; ok {
+			name = to
+		}
+		value = strings.TrimSpace(value)
+		if name == "" || value == "" {
+			continue
+		}
+
+		column := strings.ToLower(name)
+		switch {
+		case timeColumns[column]:
+			createdAt, err := parseTime(value)
+			if err != nil {
+				return submission, err
+			}
+			submission.CreatedAt = createdAt
+		case ipColumns[column]:
+			submission.IPAddress = value
+		case userAgentColumns[column]:
+			submission.UserAgent = value
+		case referrerColumns[column]:
+			submission.Referrer = value
+		case ignoredColumns[column]:
+		default:
+			data[name] = value
+		}
+	}
+	if submission.CreatedAt.IsZero() {
+		return submission, errors.New("no date, which can be mapped to created_at")
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return submission, err
+	}
+	submission.SubmittedData = body
+	return submission, nil
+}
+
+// parseTime reads a submission's time in any of timeFormats, or as a Unix
+// time in seconds or milliseconds
+func parseTime(value string) (time.Time, error) {
+	for _, format := range timeFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+		if n > 1e12 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%q isn't a date", value)
+}
@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fields(t *testing.T, data json.RawMessage) map[string]string {
+	t.Helper()
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		t.Fatalf("Invalid submitted data %s: %v", data, err)
+	}
+	return values
+}
+
+func TestReadFormspreeCSV(t *testing.T) {
+	export := "\xef\xbb\xbf" + `_date,email,message,_replyto,Phone
+2024-03-01T09:30:00Z,ada@example.com,"Hello, there",,
+2024-03-02 10:00:00,grace@example.org,Second,grace@example.org,555 1234
+`
+	submissions, err := Read(strings.NewReader(export), Mapping{"Phone": "phone"})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(submissions) != 2 {
+		t.Fatalf("Expected 2 submissions, got %d", len(submissions))
+	}
+
+	if want := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC); !submissions[0].CreatedAt.Equal(want) {
+		t.Errorf("Expected the first submission's date to be kept, got %s", submissions[0].CreatedAt)
+	}
+	first := fields(t, submissions[0].SubmittedData)
+	if len(first) != 2 || first["email"] != "ada@example.com" || first["message"] != "Hello, there" {
+		t.Errorf("Expected the first submission's filled in fields, got %v", first)
+	}
+	second := fields(t, submissions[1].SubmittedData)
+	if second["phone"] != "555 1234" || second["_replyto"] != "grace@example.org" {
+		t.Errorf("Expected Phone to be renamed, got %v", second)
+	}
+}
+
+func TestReadNetlifyJSON(t *testing.T) {
+	export := `[{
+		"id": "5f1c", "number": 4, "form_name": "contact", "site_url": "https://example.netlify.app",
+		"created_at": "2023-11-20T16:05:42.123Z",
+		"name": "Alan", "summary": "<strong>Alan</strong>",
+		"data": {"name": "Alan", "email": "alan@example.net", "topics": ["sales", "support"], "age": 41,
+			"ip": "203.0.113.5", "user_agent": "Mozilla/5.0", "referrer": "https://example.netlify.app/contact"}
+	}]`
+	submissions, err := Read(strings.NewReader(export), nil)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(submissions) != 1 {
+		t.Fatalf("Expected 1 submission, got %d", len(submissions))
+	}
+	submission := submissions[0]
+	if want := time.Date(2023, 11, 20, 16, 5, 42, 123e6, time.UTC); !submission.CreatedAt.Equal(want) {
+		t.Errorf("Expected %s, got %s", want, submission.CreatedAt)
+	}
+	if submission.IPAddress != "203.0.113.5" || submission.UserAgent != "Mozilla/5.0" || submission.Referrer != "https://example.netlify.app/contact" {
+		t.Errorf("Expected the sender's details, got %+v", submission)
+	}
+	data := fields(t, submission.SubmittedData)
+	if len(data) != 4 || data["topics"] != "sales, support" || data["age"] != "41" || data["email"] != "alan@example.net" {
+		t.Errorf("Expected only the form's fields, got %v", data)
+	}
+}
+
+func TestReadFormspreeJSON(t *testing.T) {
+	export := `{"fields": ["email", "_date"], "submissions": [{"email": "katherine@example.com", "_date": 1700000000}]}`
+	submissions, err := Read(strings.NewReader(export), nil)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(submissions) != 1 || !submissions[0].CreatedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected one submission from the Unix time, got %+v", submissions)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for name, export := range map[string]string{
+		"empty":       "  \n",
+		"no date":     "email,message\nada@example.com,Hi\n",
+		"bad date":    "date,email\nyesterday,ada@example.com\n",
+		"bad JSON":    `[{"email": }]`,
+		"not a list":  `{"email": "ada@example.com"}`,
+		"short row":   "date,email\n2024-01-01\n",
+		"not objects": `["ada@example.com"]`,
+	} {
+		if _, err := Read(strings.NewReader(export), nil); err == nil {
+			t.Errorf("Expected %s to fail", name)
+		}
+	}
+
+	// A column of dates under another name can be mapped to created_at
+	submissions, err := Read(strings.NewReader("When,email\n2024-01-01,ada@example.com\n"), Mapping{"When": "created_at"})
+	if err != nil || len(submissions) != 1 || submissions[0].CreatedAt.Year() != 2024 {
+		t.Errorf("Expected the mapped date to be used, got %+v (%v)", submissions, err)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	mapping, err := ParseMapping([]string{"Your Email = email", "", "internal="})
+	if err != nil {
+		t.Fatalf("ParseMapping failed: %v", err)
+	}
+	if len(mapping) != 2 || mapping["Your Email"] != "email" || mapping["internal"] != "" {
+		t.Errorf("Unexpected mapping %v", mapping)
+	}
+	if _, err := ParseMapping([]string{"email"}); err == nil {
+		t.Error("Expected an entry without = to be refused")
+	}
+}
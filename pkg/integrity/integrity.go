@@ -0,0 +1,150 @@
+// Package integrity finds rows left behind by missing foreign key
+// enforcement, such as email records of deleted submissions, and repairs them.
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+// Problem is a foreign key with rows pointing at a missing parent
+type Problem struct {
+	models.ForeignKey
+	// Count is how many orphaned rows were found
+	Count int
+	// Repaired is how many of them were deleted or cleared
+	Repaired int64
+}
+
+// Report is the outcome of an integrity check
+type Report struct {
+	CheckedAt time.Time
+	Problems  []Problem
+	// Repaired is true when the problems found were repaired
+	Repaired bool
+}
+
+// Orphans returns the total number of orphaned rows found
+func (r *Report) Orphans() int {
+	total := 0
+	for _, p := range r.Problems {
+		total += p.Count
+	}
+	return total
+}
+
+// Checker checks the database for orphaned rows, on demand or on a schedule
+type Checker struct {
+	db     *database.Database
+	repair bool
+	now    func() time.Time
+
+	// mu ensures only one check runs at a time and guards last
+	mu   sync.Mutex
+	last *Report
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewChecker creates a checker. When repair is true, scheduled checks also
+// repair the problems they find; checks run with Check never do.
+func NewChecker(db *database.Database, repair bool) *Checker {
+	return &Checker{
+		db:     db,
+		repair: repair,
+		now:    time.Now,
+	}
+}
+
+// Check reports orphaned rows without changing anything
+func (c *Checker) Check(ctx context.Context) (*Report, error) {
+	return c.run(ctx, false)
+}
+
+// Repair deletes orphaned rows, or clears their foreign key when it is
+// nullable, and reports what was changed
+func (c *Checker) Repair(ctx context.Context) (*Report, error) {
+	return c.run(ctx, true)
+}
+
+// Last returns the report of the most recent check, or nil if none has run
+func (c *Checker) Last() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+func (c *Checker) run(ctx context.Context, repair bool) (*Report, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := &Report{CheckedAt: c.now().UTC(), Repaired: repair}
+	for _, fk := range models.ForeignKeys {
+		count, err := models.CountOrphansContext(ctx, c.db.Connection, fk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s.%s: %w", fk.Table, fk.Column, err)
+		}
+		if count == 0 {
+			continue
+		}
+
+		problem := Problem{ForeignKey: fk, Count: count}
+		if repair {
+			if problem.Repaired, err = models.RepairOrphansContext(ctx, c.db.Connection, fk); err != nil {
+				return nil, fmt.Errorf("failed to repair %s.%s: %w", fk.Table, fk.Column, err)
+			}
+		}
+		report.Problems = append(report.Problems, problem)
+	}
+
+	c.last = report
+	return report, nil
+}
+
+// Start checks the database every interval until Stop is called
+func (c *Checker) Start(interval time.Duration) {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				report, err := c.run(context.Background(), c.repair)
+				if err != nil {
+					log.Printf("Scheduled integrity check failed: %v", err)
+					continue
+				}
+				for _, p := range report.Problems {
+					if report.Repaired {
+						log.Printf("Repaired %d orphaned rows in %s.%s", p.Repaired, p.Table, p.Column)
+					} else {
+						log.Printf("Found %d orphaned rows in %s.%s referencing missing %s", p.Count, p.Table, p.Column, p.References)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops scheduled checks, waiting for a running check to finish
+func (c *Checker) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
+}
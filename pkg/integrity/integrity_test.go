@@ -0,0 +1,102 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestChecker(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "form-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	kept, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	deleted, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	for _, id := range []int64{kept.ID, deleted.ID} {
+		if _, err := models.CreateSubmissionEmail(db.Connection, id, "sent", ""); err != nil {
+			t.Fatalf("Failed to create email record: %v", err)
+		}
+		if err := models.SetAssignment(db.Connection, id, &user.ID, models.InboxStatusNew); err != nil {
+			t.Fatalf("Failed to set assignment: %v", err)
+		}
+	}
+
+	// Delete rows the way older versions could, with foreign keys off
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"PRAGMA foreign_keys = OFF", nil},
+		{"DELETE FROM submissions WHERE id = ?", []interface{}{deleted.ID}},
+		{"UPDATE submission_assignments SET assignee_id = 999 WHERE submission_id = ?", []interface{}{kept.ID}},
+		{"PRAGMA foreign_keys = ON", nil},
+	} {
+		if _, err := db.Connection.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("Failed to run %q: %v", stmt.query, err)
+		}
+	}
+
+	checker := NewChecker(db, false)
+	if checker.Last() != nil {
+		t.Error("Expected no report before the first check")
+	}
+
+	report, err := checker.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	found := make(map[string]int)
+	for _, p := range report.Problems {
+		found[p.Table+"."+p.Column] = p.Count
+	}
+	if found["submission_emails.submission_id"] != 1 || found["submission_assignments.submission_id"] != 1 || found["submission_assignments.assignee_id"] != 1 {
+		t.Errorf("Expected the orphaned email record and assignment, got %v", found)
+	}
+	if report.Orphans() != 3 || report.Repaired {
+		t.Errorf("Expected 3 unrepaired orphans, got %d (repaired %v)", report.Orphans(), report.Repaired)
+	}
+	if checker.Last() != report {
+		t.Error("Expected the report to be kept as the last check")
+	}
+
+	report, err = checker.Repair(context.Background())
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if !report.Repaired || report.Orphans() != 3 {
+		t.Errorf("Expected 3 repaired orphans, got %+v", report)
+	}
+
+	// The kept submission's records survive, with the missing assignee cleared
+	email, err := models.GetSubmissionEmailBySubmissionID(db.Connection, kept.ID)
+	if err != nil || email == nil {
+		t.Errorf("Expected the kept submission's email record to remain, got %v (%v)", email, err)
+	}
+	assignment, err := models.GetAssignment(db.Connection, kept.ID)
+	if err != nil {
+		t.Fatalf("Failed to get assignment: %v", err)
+	}
+	if assignment.AssigneeID != nil {
+		t.Errorf("Expected the missing assignee to be cleared, got %d", *assignment.AssigneeID)
+	}
+
+	report, err = checker.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Problems) != 0 {
+		t.Errorf("Expected no problems after repair, got %+v", report.Problems)
+	}
+}
@@ -0,0 +1,118 @@
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"staticsend/pkg/models"
+)
+
+const (
+	// ActionAllow permits matching addresses. Once any allow rule exists in a
+	// scope, addresses that match none of them are rejected.
+	ActionAllow = "allow"
+	// ActionDeny rejects matching addresses
+	ActionDeny = "deny"
+)
+
+// ErrInvalidAction is returned when a rule action is neither allow nor deny
+var ErrInvalidAction = errors.New("action must be allow or deny")
+
+// Decision is the outcome of evaluating an address against a set of rules
+type Decision struct {
+	Allowed bool
+	// Rule is the rule that caused a rejection, nil when allowed or when the
+	// address was rejected for not matching any allow rule
+	Rule   *models.IPRule
+	Reason string
+}
+
+// NormalizeCIDR validates an IP address or CIDR range and returns it in CIDR
+// notation (single addresses become /32 or /128)
+func NormalizeCIDR(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("IP address or CIDR range is required")
+	}
+
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR range %q", value)
+		}
+		return network.String(), nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address %q", value)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
+
+// ValidateAction checks that a rule action is supported
+func ValidateAction(action string) error {
+	if action != ActionAllow && action != ActionDeny {
+		return ErrInvalidAction
+	}
+	return nil
+}
+
+// Evaluate checks an address against a set of rules. Deny rules always win;
+// if there are allow rules, the address must match at least one of them.
+func Evaluate(rules []models.IPRule, address string) Decision {
+	ip := ParseIP(address)
+	if ip == nil {
+		// Without a usable address only an allowlist can make a decision
+		for _, rule := range rules {
+			if rule.Action == ActionAllow {
+				return Decision{Allowed: false, Reason: "address could not be parsed"}
+			}
+		}
+		return Decision{Allowed: true}
+	}
+
+	hasAllowRules := false
+	matchedAllow := false
+	for i := range rules {
+		rule := &rules[i]
+		_, network, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			continue
+		}
+
+		if rule.Action == ActionAllow {
+			hasAllowRules = true
+		}
+		if !network.Contains(ip) {
+			continue
+		}
+
+		switch rule.Action {
+		case ActionDeny:
+			return Decision{Allowed: false, Rule: rule, Reason: "matched deny rule " + rule.CIDR}
+		case ActionAllow:
+			matchedAllow = true
+		}
+	}
+
+	if hasAllowRules && !matchedAllow {
+		return Decision{Allowed: false, Reason: "not in allowlist"}
+	}
+
+	return Decision{Allowed: true}
+}
+
+// ParseIP extracts an IP from an address that may include a port
+func ParseIP(address string) net.IP {
+	address = strings.TrimSpace(address)
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return net.ParseIP(strings.Trim(address, "[]"))
+}
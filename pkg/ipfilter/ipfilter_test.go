@@ -0,0 +1,96 @@
+package ipfilter
+
+import (
+	"testing"
+
+	"staticsend/pkg/models"
+)
+
+func TestNormalizeCIDR(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"203.0.113.5", "203.0.113.5/32", false},
+		{" 203.0.113.0/24 ", "203.0.113.0/24", false},
+		{"203.0.113.77/24", "203.0.113.0/24", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"", "", true},
+		{"not-an-ip", "", true},
+		{"10.0.0.0/99", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeCIDR(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("NormalizeCIDR(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	deny := models.IPRule{ID: 1, CIDR: "203.0.113.0/24", Action: ActionDeny}
+	allowOffice := models.IPRule{ID: 2, CIDR: "198.51.100.0/24", Action: ActionAllow}
+	allowOverlap := models.IPRule{ID: 3, CIDR: "203.0.113.0/28", Action: ActionAllow}
+
+	tests := []struct {
+		name        string
+		rules       []models.IPRule
+		address     string
+		allowed     bool
+		matchedRule int64
+	}{
+		{"no rules", nil, "192.0.2.1", true, 0},
+		{"deny match", []models.IPRule{deny}, "203.0.113.50", false, 1},
+		{"deny match with port", []models.IPRule{deny}, "203.0.113.50:4312", false, 1},
+		{"deny no match", []models.IPRule{deny}, "192.0.2.1", true, 0},
+		{"allowlist match", []models.IPRule{allowOffice}, "198.51.100.20", true, 0},
+		{"allowlist miss", []models.IPRule{allowOffice}, "192.0.2.1", false, 0},
+		{"deny wins over allow", []models.IPRule{allowOverlap, deny}, "203.0.113.2", false, 1},
+		{"unparseable without allowlist", []models.IPRule{deny}, "unknown", true, 0},
+		{"unparseable with allowlist", []models.IPRule{allowOffice}, "unknown", false, 0},
+		{"ipv6 bracketed", []models.IPRule{{ID: 4, CIDR: "2001:db8::/32", Action: ActionDeny}}, "[2001:db8::1]:443", false, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := Evaluate(tt.rules, tt.address)
+			if decision.Allowed != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v (%s)", tt.allowed, decision.Allowed, decision.Reason)
+			}
+			if tt.matchedRule != 0 {
+				if decision.Rule == nil || decision.Rule.ID != tt.matchedRule {
+					t.Errorf("Expected matched rule %d, got %v", tt.matchedRule, decision.Rule)
+				}
+			}
+			if !decision.Allowed && decision.Reason == "" {
+				t.Error("Expected a reason for blocked decisions")
+			}
+		})
+	}
+}
+
+func TestValidateAction(t *testing.T) {
+	if err := ValidateAction(ActionAllow); err != nil {
+		t.Errorf("Unexpected error for allow: %v", err)
+	}
+	if err := ValidateAction(ActionDeny); err != nil {
+		t.Errorf("Unexpected error for deny: %v", err)
+	}
+	if err := ValidateAction("block"); err != ErrInvalidAction {
+		t.Errorf("Expected ErrInvalidAction, got %v", err)
+	}
+}
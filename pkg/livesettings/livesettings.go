@@ -0,0 +1,162 @@
+// Package livesettings keeps an in-memory snapshot of the settings that can
+// change while staticSend runs: SMTP, the base URL and the Turnstile keys
+// for the sign in pages. The snapshot is reloaded from app_settings after
+// they're saved and on an interval, so a change made on the settings page,
+// or on another instance sharing the database, applies without a restart.
+package livesettings
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+// Settings is a snapshot of the runtime settings
+type Settings struct {
+	BaseURL string
+	// Email's Host is empty until SMTP settings are saved, leaving the
+	// EMAIL_* configuration in use
+	Email              models.EmailSettings
+	TurnstilePublicKey string
+	TurnstileSecretKey string
+}
+
+// Store holds the current settings and tells listeners when they change
+type Store struct {
+	db       *database.Database
+	defaults Settings
+
+	mu        sync.RWMutex
+	current   Settings
+	listeners []func(Settings)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewStore creates a store whose settings start as defaults, the values
+// from the configuration, until Reload reads any saved in the database
+func NewStore(db *database.Database, defaults Settings) *Store {
+	return &Store{db: db, defaults: defaults, current: defaults}
+}
+
+// SetDefaults replaces the defaults, e.g. after the configuration is
+// reloaded. They apply on the next Reload.
+func (s *Store) SetDefaults(defaults Settings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaults = defaults
+}
+
+// Current returns the settings in use
+func (s *Store) Current() Settings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// OnChange calls fn with the new settings whenever Reload finds they've
+// changed
+func (s *Store) OnChange(fn func(Settings)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Reload reads the settings saved in the database over the defaults and
+// tells the listeners if they've changed
+func (s *Store) Reload(ctx context.Context) error {
+	settings, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	changed := settings != s.current
+	s.current = settings
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if changed {
+		for _, fn := range listeners {
+			fn(settings)
+		}
+	}
+	return nil
+}
+
+// load reads the saved settings, using the defaults for any left empty
+func (s *Store) load(ctx context.Context) (Settings, error) {
+	s.mu.RLock()
+	settings := s.defaults
+	s.mu.RUnlock()
+
+	baseURL, err := models.GetAppSettingValueContext(ctx, s.db.Connection, models.SettingBaseURL)
+	if err != nil {
+		return Settings{}, err
+	}
+	if baseURL != "" {
+		settings.BaseURL = baseURL
+	}
+
+	email, err := models.GetEmailSettingsContext(ctx, s.db.Connection)
+	if err != nil {
+		return Settings{}, err
+	}
+	if email != nil {
+		settings.Email = *email
+	}
+
+	// The keys only work as a pair, so a saved site key replaces both
+	publicKey, err := models.GetAppSettingValueContext(ctx, s.db.Connection, models.SettingTurnstilePublicKey)
+	if err != nil {
+		return Settings{}, err
+	}
+	secretKey, err := models.GetAppSettingValueContext(ctx, s.db.Connection, models.SettingTurnstileSecretKey)
+	if err != nil {
+		return Settings{}, err
+	}
+	if publicKey != "" {
+		settings.TurnstilePublicKey = publicKey
+		settings.TurnstileSecretKey = secretKey
+	}
+
+	return settings, nil
+}
+
+// Start reloads the settings every interval until Stop is called
+func (s *Store) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := s.Reload(context.Background()); err != nil {
+					log.Printf("Failed to reload settings: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops reloading settings
+func (s *Store) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
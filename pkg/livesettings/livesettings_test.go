@@ -0,0 +1,74 @@
+package livesettings
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestStore_Reload(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	defaults := Settings{
+		BaseURL:            "http://localhost:8080",
+		TurnstilePublicKey: "config-site-key",
+		TurnstileSecretKey: "config-secret-key",
+	}
+	store := NewStore(db, defaults)
+
+	changes := 0
+	store.OnChange(func(Settings) { changes++ })
+
+	if err := store.Reload(ctx); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if store.Current() != defaults {
+		t.Errorf("Expected the defaults with nothing saved, got %+v", store.Current())
+	}
+	if changes != 0 {
+		t.Errorf("Expected no change to be reported, got %d", changes)
+	}
+
+	if err := models.UpdateAppSettingContext(ctx, db.Connection, models.SettingBaseURL, "https://forms.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.UpdateAppSettingContext(ctx, db.Connection, models.SettingTurnstilePublicKey, "saved-site-key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.SaveEmailSettingsContext(ctx, db.Connection, &models.EmailSettings{Host: "smtp.example.com", Port: 587}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Reload(ctx); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	current := store.Current()
+	if current.BaseURL != "https://forms.example.com" {
+		t.Errorf("Expected the saved base URL, got %q", current.BaseURL)
+	}
+	if current.Email.Host != "smtp.example.com" {
+		t.Errorf("Expected the saved SMTP host, got %q", current.Email.Host)
+	}
+	// A saved site key replaces the configured pair, even without a secret
+	if current.TurnstilePublicKey != "saved-site-key" || current.TurnstileSecretKey != "" {
+		t.Errorf("Expected the saved Turnstile keys, got %q and %q", current.TurnstilePublicKey, current.TurnstileSecretKey)
+	}
+	if changes != 1 {
+		t.Errorf("Expected one change to be reported, got %d", changes)
+	}
+
+	if err := store.Reload(ctx); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("Expected an unchanged reload not to be reported, got %d changes", changes)
+	}
+}
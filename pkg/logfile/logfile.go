@@ -0,0 +1,70 @@
+// Package logfile writes logs to a file that can be reopened, so tools like
+// logrotate can move it aside and have logging carry on in a new file.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is an io.Writer appending to a log file
+type File struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// Open opens the log file at path for appending, creating it if needed
+func Open(path string) (*File, error) {
+	f := &File{path: path}
+	if err := f.Reopen(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the file's path
+func (f *File) Path() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.path
+}
+
+// Write appends p to the file
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Write(p)
+}
+
+// Reopen closes the file and opens its path again. Writes after it go to
+// a new file if the old one was moved.
+func (f *File) Reopen() error {
+	return f.OpenPath(f.Path())
+}
+
+// OpenPath switches to the log file at path. The current file is kept if
+// the new one can't be opened.
+func (f *File) OpenPath(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	f.path = path
+	return nil
+}
+
+// Close closes the file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
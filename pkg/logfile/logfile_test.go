@@ -0,0 +1,45 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "staticsend.log")
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer f.Close()
+	f.Write([]byte("first\n"))
+
+	// Rotate the file the way logrotate does
+	rotated := path + ".1"
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("still first\n"))
+	if err := f.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	f.Write([]byte("second\n"))
+
+	if data, _ := os.ReadFile(rotated); string(data) != "first\nstill first\n" {
+		t.Errorf("Unexpected rotated log %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second\n" {
+		t.Errorf("Unexpected new log %q", data)
+	}
+
+	if err := f.OpenPath(filepath.Join(dir, "missing", "staticsend.log")); err == nil {
+		t.Error("Expected a log file in a missing directory to fail")
+	}
+	f.Write([]byte("third\n"))
+	if data, _ := os.ReadFile(path); string(data) != "second\nthird\n" {
+		t.Errorf("Expected the current file to be kept after a failed switch, got %q", data)
+	}
+}
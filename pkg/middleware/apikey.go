@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/models"
+)
+
+// APIKeyAuth authenticates requests from integrations by an API key in the
+// X-API-Key header or as a bearer token, putting the key's user in the
+// context so RequirePermission applies as it does to signed in users
+func APIKeyAuth(db *database.Database) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := auth.GetAPIKeyFromRequest(r)
+			if !ok {
+				httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized: API key required")
+				return
+			}
+
+			apiKey, err := models.GetAPIKeyByHashContext(r.Context(), db.Connection, auth.HashAPIKey(key))
+			if err != nil {
+				httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to check API key")
+				return
+			}
+			if apiKey == nil {
+				httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized: invalid API key")
+				return
+			}
+			user, err := models.GetUserByIDContext(r.Context(), db.Connection, apiKey.UserID)
+			if err != nil || user == nil {
+				httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized: user not found")
+				return
+			}
+			if err := models.TouchAPIKeyContext(r.Context(), db.Connection, apiKey.ID); err != nil {
+				log.Printf("Failed to record use of API key %d: %v", apiKey.ID, err)
+			}
+
+			ctx := context.WithValue(r.Context(), UserKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	db := setupMaintenanceDB(t)
+	user, _ := models.CreateUser(db, "zapier@example.com", "hash")
+	key, prefix, hash, _ := auth.GenerateAPIKey()
+	apiKey, err := models.CreateAPIKey(db, user.ID, "Zapier", prefix, hash)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	handler := APIKeyAuth(&database.Database{Connection: db})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := GetUserFromContext(r.Context()); !ok || u.ID != user.ID {
+			t.Errorf("Expected the key's user in the context, got %+v", u)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"no key", "", "", http.StatusUnauthorized},
+		{"unknown key", "X-API-Key", "ss_unknown", http.StatusUnauthorized},
+		{"key header", "X-API-Key", key, http.StatusOK},
+		{"bearer key", "Authorization", "Bearer " + key, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/hooks/me", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+
+	keys, _ := models.GetAPIKeysByUserID(db, user.ID)
+	if len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("Expected the key's use to be recorded, got %+v", keys)
+	}
+
+	if deleted, _ := models.DeleteAPIKey(db, user.ID+1, apiKey.ID); deleted {
+		t.Error("Expected another user not to be able to revoke the key")
+	}
+	if deleted, err := models.DeleteAPIKey(db, user.ID, apiKey.ID); err != nil || !deleted {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/api/v1/hooks/me", nil)
+	req.Header.Set("X-API-Key", key)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to be refused, got %d", rr.Code)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"staticsend/pkg/auth"
 	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
 	"staticsend/pkg/models"
 )
 
@@ -51,7 +52,7 @@ func AuthMiddleware(config AuthConfig) func(http.Handler) http.Handler {
 					// For web requests, redirect to login instead of 401 error
 					if r.Header.Get("HX-Request") == "true" {
 						// HTMX request, return 401
-						http.Error(w, "Unauthorized: authentication required", http.StatusUnauthorized)
+						httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized: authentication required")
 					} else {
 						// Regular browser request, redirect to login
 						http.Redirect(w, r, "/login", http.StatusFound)
@@ -63,17 +64,10 @@ func AuthMiddleware(config AuthConfig) func(http.Handler) http.Handler {
 			claims, err := auth.ValidateToken(tokenString, config.SecretKey)
 			if err != nil {
 				// Invalid token - clear the bad cookie and redirect to login
-				http.SetCookie(w, &http.Cookie{
-					Name:     "auth_token",
-					Value:    "",
-					Path:     "/",
-					HttpOnly: true,
-					Secure:   false,
-					MaxAge:   -1,
-				})
+				http.SetCookie(w, auth.ExpiredSessionCookie(r))
 				
 				if r.Header.Get("HX-Request") == "true" {
-					http.Error(w, "Unauthorized: invalid token", http.StatusUnauthorized)
+					httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized: invalid token")
 				} else {
 					http.Redirect(w, r, "/login", http.StatusFound)
 				}
@@ -82,25 +76,18 @@ func AuthMiddleware(config AuthConfig) func(http.Handler) http.Handler {
 
 			userID, err := auth.GetUserIDFromToken(claims)
 			if err != nil {
-				http.Error(w, "Unauthorized: invalid token claims", http.StatusUnauthorized)
+				httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized: invalid token claims")
 				return
 			}
 
 			// Get user from database
-			user, err := models.GetUserByID(config.DB.Connection, userID)
+			user, err := models.GetUserByIDContext(r.Context(), config.DB.Connection, userID)
 			if err != nil || user == nil {
 				// User not found - clear the bad cookie and redirect to login
-				http.SetCookie(w, &http.Cookie{
-					Name:     "auth_token",
-					Value:    "",
-					Path:     "/",
-					HttpOnly: true,
-					Secure:   false,
-					MaxAge:   -1,
-				})
+				http.SetCookie(w, auth.ExpiredSessionCookie(r))
 				
 				if r.Header.Get("HX-Request") == "true" {
-					http.Error(w, "Unauthorized: user not found", http.StatusUnauthorized)
+					httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized: user not found")
 				} else {
 					http.Redirect(w, r, "/login", http.StatusFound)
 				}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// prefixedHeaders hold paths the browser follows, which need the base path
+var prefixedHeaders = []string{"Location", "HX-Redirect", "HX-Location"}
+
+// BasePath returns a middleware that serves the app under a path prefix,
+// e.g. "/forms" behind a reverse proxy forwarding https://example.com/forms/.
+// The prefix is removed from request paths, so routes are matched as usual,
+// and added to redirects and cookie paths. Requests outside it are not
+// found. An empty prefix leaves requests untouched.
+func BasePath(prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if prefix == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == prefix {
+				target := prefix + "/"
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+			path, ok := strings.CutPrefix(r.URL.Path, prefix)
+			if !ok || !strings.HasPrefix(path, "/") {
+				http.NotFound(w, r)
+				return
+			}
+
+			stripped := r.Clone(r.Context())
+			stripped.URL.Path = path
+			if r.URL.RawPath != "" {
+				stripped.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+			}
+			next.ServeHTTP(&basePathResponseWriter{ResponseWriter: w, prefix: prefix}, stripped)
+		})
+	}
+}
+
+// basePathResponseWriter adds the base path to redirects and cookies as the
+// headers are sent
+type basePathResponseWriter struct {
+	http.ResponseWriter
+	prefix      string
+	wroteHeader bool
+}
+
+// WriteHeader rewrites the headers before sending them
+func (bw *basePathResponseWriter) WriteHeader(code int) {
+	if !bw.wroteHeader {
+		bw.wroteHeader = true
+		bw.rewriteHeaders()
+	}
+	bw.ResponseWriter.WriteHeader(code)
+}
+
+// Write sends the headers first if the handler hasn't
+func (bw *basePathResponseWriter) Write(p []byte) (int, error) {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	return bw.ResponseWriter.Write(p)
+}
+
+// Flush forwards to the underlying writer so streamed responses keep flowing
+func (bw *basePathResponseWriter) Flush() {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (bw *basePathResponseWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// rewriteHeaders prefixes the paths in redirect headers and cookies
+func (bw *basePathResponseWriter) rewriteHeaders() {
+	header := bw.Header()
+	for _, name := range prefixedHeaders {
+		if value := header.Get(name); isLocalPath(value) {
+			header.Set(name, bw.prefix+value)
+		}
+	}
+
+	cookies := header.Values("Set-Cookie")
+	if len(cookies) == 0 {
+		return
+	}
+	header.Del("Set-Cookie")
+	for _, line := range cookies {
+		cookie, err := http.ParseSetCookie(line)
+		if err != nil {
+			header.Add("Set-Cookie", line)
+			continue
+		}
+		if cookie.Path == "" || cookie.Path == "/" {
+			cookie.Path = bw.prefix
+		} else if isLocalPath(cookie.Path) {
+			cookie.Path = bw.prefix + cookie.Path
+		}
+		header.Add("Set-Cookie", cookie.String())
+	}
+}
+
+// isLocalPath reports whether value is a path on this host, rather than a
+// full or protocol-relative URL
+func isLocalPath(value string) bool {
+	return strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//")
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	handler := BasePath("/forms")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "token", Path: "/"})
+			w.Header().Set("HX-Redirect", "/dashboard")
+			w.Write([]byte("signed in"))
+		case "/logout":
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		case "/away":
+			http.Redirect(w, r, "https://example.com/", http.StatusFound)
+		default:
+			w.Write([]byte("path " + r.URL.Path))
+		}
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	if rr := serve("/forms/dashboard"); rr.Body.String() != "path /dashboard" {
+		t.Errorf("Expected the base path to be removed, got %q", rr.Body.String())
+	}
+	if rr := serve("/dashboard"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a path outside the base path to be not found, got %d", rr.Code)
+	}
+	if rr := serve("/formsdashboard"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a path that only starts with the base path to be not found, got %d", rr.Code)
+	}
+	if rr := serve("/forms"); rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/forms/" {
+		t.Errorf("Expected the bare base path to redirect to /forms/, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr := serve("/forms/login")
+	if rr.Header().Get("HX-Redirect") != "/forms/dashboard" {
+		t.Errorf("Expected HX-Redirect to include the base path, got %q", rr.Header().Get("HX-Redirect"))
+	}
+	if cookie := rr.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Path=/forms") {
+		t.Errorf("Expected the cookie path to be the base path, got %q", cookie)
+	}
+	if rr := serve("/forms/logout"); rr.Header().Get("Location") != "/forms/login" {
+		t.Errorf("Expected the redirect to include the base path, got %q", rr.Header().Get("Location"))
+	}
+	if rr := serve("/forms/away"); rr.Header().Get("Location") != "https://example.com/" {
+		t.Errorf("Expected an external redirect to be untouched, got %q", rr.Header().Get("Location"))
+	}
+
+	root := BasePath("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("path " + r.URL.Path))
+	}))
+	rr = httptest.NewRecorder()
+	root.ServeHTTP(rr, httptest.NewRequest("GET", "/dashboard", nil))
+	if rr.Body.String() != "path /dashboard" {
+		t.Errorf("Expected an empty base path to leave requests untouched, got %q", rr.Body.String())
+	}
+}
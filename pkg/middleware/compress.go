@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// compressibleTypes lists the content types worth compressing. Images, fonts
+// and archives are already compressed and are passed through untouched.
+var compressibleTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/csv",
+	"text/javascript",
+	"text/xml",
+	"application/javascript",
+	"application/json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+// Compress returns a middleware that compresses responses with brotli or gzip,
+// depending on the client's Accept-Encoding. Handlers that stream (such as
+// exports) can call Flush to push compressed chunks to the client as they go.
+func Compress(level int) func(http.Handler) http.Handler {
+	compressor := middleware.NewCompressor(level, compressibleTypes...)
+	compressor.SetEncoder("br", func(w io.Writer, level int) io.Writer {
+		return brotli.NewWriterLevel(w, level)
+	})
+
+	return func(next http.Handler) http.Handler {
+		// The sniffer sits between the handler and the compressor's writer
+		return compressor.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&sniffingResponseWriter{ResponseWriter: w}, r)
+		}))
+	}
+}
+
+// sniffingResponseWriter fills in a missing Content-Type from the first write
+// so that the compressor can decide whether the response is compressible.
+// Template renders don't set a Content-Type and rely on net/http sniffing,
+// which would otherwise happen too late.
+type sniffingResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader records that headers have been sent
+func (sw *sniffingResponseWriter) WriteHeader(code int) {
+	sw.wroteHeader = true
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Write sniffs the content type on the first write if none was set
+func (sw *sniffingResponseWriter) Write(p []byte) (int, error) {
+	if !sw.wroteHeader {
+		if sw.Header().Get("Content-Type") == "" && len(p) > 0 {
+			sw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		sw.wroteHeader = true
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+// Flush forwards to the underlying writer so streamed responses keep flowing
+func (sw *sniffingResponseWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (sw *sniffingResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+var compressTestBody = strings.Repeat("<p>staticSend dashboard row</p>\n", 200)
+
+func TestCompress_Negotiation(t *testing.T) {
+	handler := Compress(5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No explicit Content-Type, like a template render
+		w.Write([]byte("<!DOCTYPE html>" + compressTestBody))
+	}))
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		expected       string
+	}{
+		{"brotli preferred", "gzip, deflate, br", "br"},
+		{"gzip only", "gzip", "gzip"},
+		{"no compression", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/dashboard", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Content-Encoding"); got != tt.expected {
+				t.Fatalf("Expected Content-Encoding %q, got %q", tt.expected, got)
+			}
+
+			var reader io.Reader = rr.Body
+			switch tt.expected {
+			case "gzip":
+				gz, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatalf("Failed to create gzip reader: %v", err)
+				}
+				reader = gz
+			case "br":
+				reader = brotli.NewReader(rr.Body)
+			}
+
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+			if !strings.HasSuffix(string(body), compressTestBody) {
+				t.Error("Decompressed body does not match original")
+			}
+		})
+	}
+}
+
+func TestCompress_SkipsIncompressibleTypes(t *testing.T) {
+	handler := Compress(5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(compressTestBody))
+	}))
+
+	req := httptest.NewRequest("GET", "/static/logo.png", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Expected no Content-Encoding for image/png, got %q", got)
+	}
+	if rr.Body.String() != compressTestBody {
+		t.Error("Expected body to be passed through unchanged")
+	}
+}
+
+func TestCompress_StreamingFlush(t *testing.T) {
+	flushed := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := Compress(5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("id,name\n1,first row\n"))
+		w.(http.Flusher).Flush()
+		close(flushed)
+		<-release
+		w.Write([]byte("2,second row\n"))
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/export.csv", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	<-flushed
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read flushed gzip header: %v", err)
+	}
+
+	// The first row must be readable before the handler finishes
+	firstRow := make([]byte, len("id,name\n1,first row\n"))
+	if _, err := io.ReadFull(gz, firstRow); err != nil {
+		t.Fatalf("Failed to read flushed data: %v", err)
+	}
+	if string(firstRow) != "id,name\n1,first row\n" {
+		t.Errorf("Unexpected first chunk: %q", firstRow)
+	}
+
+	close(release)
+	rest, _ := io.ReadAll(gz)
+	if string(rest) != "2,second row\n" {
+		t.Errorf("Unexpected remaining data: %q", rest)
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"staticsend/pkg/httperr"
+)
+
+// ConcurrencyLimiter caps the number of requests a route handles at once.
+// Requests over the limit wait briefly in a bounded queue for a free slot and
+// are shed with 503 Service Unavailable when the queue is full or the wait
+// expires, so that spikes can't pile up behind the single SQLite writer.
+type ConcurrencyLimiter struct {
+	slots   chan struct{}
+	queue   chan struct{}
+	maxWait time.Duration
+}
+
+// NewConcurrencyLimiter creates a limiter allowing maxInFlight concurrent
+// requests with up to maxQueued more waiting at most maxWait for a slot
+func NewConcurrencyLimiter(maxInFlight, maxQueued int, maxWait time.Duration) *ConcurrencyLimiter {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	if maxQueued < 0 {
+		maxQueued = 0
+	}
+	return &ConcurrencyLimiter{
+		slots:   make(chan struct{}, maxInFlight),
+		queue:   make(chan struct{}, maxQueued),
+		maxWait: maxWait,
+	}
+}
+
+// InFlight returns the number of requests currently being handled
+func (cl *ConcurrencyLimiter) InFlight() int {
+	return len(cl.slots)
+}
+
+// Queued returns the number of requests waiting for a slot
+func (cl *ConcurrencyLimiter) Queued() int {
+	return len(cl.queue)
+}
+
+// acquire waits for a free slot, returning false if the request should be shed
+func (cl *ConcurrencyLimiter) acquire(r *http.Request) bool {
+	select {
+	case cl.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	// Join the queue, or shed immediately if it's full
+	select {
+	case cl.queue <- struct{}{}:
+	default:
+		return false
+	}
+	defer func() { <-cl.queue }()
+
+	timer := time.NewTimer(cl.maxWait)
+	defer timer.Stop()
+
+	select {
+	case cl.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// release frees a slot
+func (cl *ConcurrencyLimiter) release() {
+	<-cl.slots
+}
+
+// Handler returns a middleware that applies the concurrency limit
+func (cl *ConcurrencyLimiter) Handler(next http.Handler) http.Handler {
+	retryAfter := strconv.Itoa(max(int(cl.maxWait.Round(time.Second)/time.Second), 1))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cl.acquire(r) {
+			w.Header().Set("Retry-After", retryAfter)
+			httperr.Write(w, r, http.StatusServiceUnavailable, httperr.Unavailable, "Server is busy, please try again shortly")
+			return
+		}
+		defer cl.release()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ConcurrencyLimit creates a middleware that caps concurrent requests to a route
+func ConcurrencyLimit(maxInFlight, maxQueued int, maxWait time.Duration) func(http.Handler) http.Handler {
+	return NewConcurrencyLimiter(maxInFlight, maxQueued, maxWait).Handler
+}
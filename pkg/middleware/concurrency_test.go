@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingHandler holds requests until release is closed
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestConcurrencyLimitSheds(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	limiter := NewConcurrencyLimiter(1, 0, 50*time.Millisecond)
+	handler := limiter.Handler(blockingHandler(started, release))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	first := httptest.NewRecorder()
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(first, httptest.NewRequest("POST", "/", nil))
+	}()
+	<-started
+
+	if limiter.InFlight() != 1 {
+		t.Errorf("Expected 1 request in flight, got %d", limiter.InFlight())
+	}
+
+	// No queue, so the second request is shed straight away
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+
+	close(release)
+	wg.Wait()
+	if first.Code != http.StatusOK {
+		t.Errorf("Expected first request to succeed, got %d", first.Code)
+	}
+	if limiter.InFlight() != 0 {
+		t.Errorf("Expected no requests in flight, got %d", limiter.InFlight())
+	}
+}
+
+func TestConcurrencyLimitQueues(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	limiter := NewConcurrencyLimiter(1, 1, time.Second)
+	handler := limiter.Handler(blockingHandler(started, release))
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+			codes[i] = rr.Code
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+
+	// Wait for the second request to join the queue
+	deadline := time.Now().Add(time.Second)
+	for limiter.Queued() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if limiter.Queued() != 1 {
+		t.Fatalf("Expected 1 queued request, got %d", limiter.Queued())
+	}
+
+	// The queue is full, so a third request is shed
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with a full queue, got %d", rr.Code)
+	}
+
+	// Releasing the first request lets the queued one through
+	close(release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Expected request %d to succeed, got %d", i, code)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"staticsend/pkg/redact"
+)
+
+// redactingLogFormatter wraps chi's default formatter and masks sensitive
+// query parameters and path tokens before the request line is logged
+type redactingLogFormatter struct {
+	formatter middleware.LogFormatter
+	redactor  *redact.Redactor
+}
+
+// tokenPaths are the routes that carry a secret token in their path, as the
+// segments leading up to it ("*" matches any segment)
+var tokenPaths = [][]string{
+	{"feeds"},
+	{"status"},
+	{"webhooks", "bounces", "*"},
+}
+
+// NewLogEntry creates a log entry for a copy of the request with sensitive values masked
+func (f *redactingLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	safe := r.Clone(r.Context())
+	safe.URL = f.redactor.URL(r.URL)
+	if path, ok := redactPath(safe.URL.Path); ok {
+		safe.URL.Path, safe.URL.RawPath = path, ""
+	}
+	safe.RequestURI = safe.URL.RequestURI()
+	return f.formatter.NewLogEntry(safe)
+}
+
+// redactPath masks the token in a path matching one of tokenPaths
+func redactPath(path string) (string, bool) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for _, prefix := range tokenPaths {
+		if len(segments) <= len(prefix) {
+			continue
+		}
+		matched := true
+		for i, segment := range prefix {
+			if segment != "*" && segment != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			segments[len(prefix)] = redact.Mask
+			return "/" + strings.Join(segments, "/"), true
+		}
+	}
+	return path, false
+}
+
+// RedactingLogger is a drop-in replacement for chi's Logger middleware that
+// never writes tokens, passwords or other sensitive query values to the log,
+// nor the tokens in feed, status page and bounce webhook paths.
+// Request bodies are never logged.
+func RedactingLogger(redactor *redact.Redactor) func(http.Handler) http.Handler {
+	return redactingLogger(redactor, log.New(os.Stdout, "", log.LstdFlags))
+}
+
+// redactingLogger builds the logging middleware around the given logger
+func redactingLogger(redactor *redact.Redactor, logger middleware.LoggerInterface) func(http.Handler) http.Handler {
+	return middleware.RequestLogger(&redactingLogFormatter{
+		formatter: &middleware.DefaultLogFormatter{
+			Logger:  logger,
+			NoColor: true,
+		},
+		redactor: redactor,
+	})
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/redact"
+)
+
+func TestRedactingLogger_MasksSensitiveQueryValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	var seenToken string
+	handler := redactingLogger(redact.New("email"), logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Handlers must still see the real values
+		seenToken = r.URL.Query().Get("token")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/forms/1/feed?token=supersecret&email=jane@example.com&page=2", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	output := buf.String()
+	for _, leaked := range []string{"supersecret", "jane@example.com", "jane%40example.com"} {
+		if strings.Contains(output, leaked) {
+			t.Errorf("Log output leaked %q: %s", leaked, output)
+		}
+	}
+	if !strings.Contains(output, "page=2") {
+		t.Errorf("Expected non-sensitive parameters to be logged, got: %s", output)
+	}
+	if seenToken != "supersecret" {
+		t.Errorf("Handler should receive the original token, got %q", seenToken)
+	}
+}
+
+func TestRedactingLogger_DoesNotLogBody(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	handler := redactingLogger(redact.New(), logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	body := strings.NewReader("email=jane%40example.com&password=hunter2&message=hello")
+	req := httptest.NewRequest("POST", "/auth/login", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	output := buf.String()
+	for _, leaked := range []string{"hunter2", "jane", "hello"} {
+		if strings.Contains(output, leaked) {
+			t.Errorf("Log output leaked %q: %s", leaked, output)
+		}
+	}
+	if !strings.Contains(output, "/auth/login") {
+		t.Errorf("Expected request path to be logged, got: %s", output)
+	}
+}
+
+func TestRedactingLogger_MasksPathTokens(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	var seenPath string
+	handler := redactingLogger(redact.New(), logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, path := range []string{
+		"/feeds/feedsecret/atom",
+		"/feeds/feedsecret/rss",
+		"/status/feedsecret",
+		"/webhooks/bounces/postmark/feedsecret",
+	} {
+		buf.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+
+		output := buf.String()
+		if strings.Contains(output, "feedsecret") {
+			t.Errorf("Log output leaked the token in %s: %s", path, output)
+		}
+		if seenPath != path {
+			t.Errorf("Handler should receive the original path %s, got %s", path, seenPath)
+		}
+	}
+
+	buf.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/forms/3/submissions", nil))
+	if !strings.Contains(buf.String(), "/forms/3/submissions") {
+		t.Errorf("Expected other paths to be logged as they are, got: %s", buf.String())
+	}
+}
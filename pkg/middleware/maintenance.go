@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// maintenanceRetryAfter is the Retry-After value, in seconds, sent while in maintenance
+const maintenanceRetryAfter = "300"
+
+// defaultMaintenanceMessage is shown when no maintenance message is configured
+const defaultMaintenanceMessage = "staticSend is undergoing scheduled maintenance and will be back shortly."
+
+// MaintenanceConfig holds maintenance mode configuration
+type MaintenanceConfig struct {
+	SecretKey []byte
+	DB        *database.Database
+	// Templates renders the maintenance page; a plain text response is sent when nil
+	Templates *templates.TemplateManager
+	// AllowedPaths stay reachable during maintenance so administrators can sign in
+	AllowedPaths []string
+}
+
+// MaintenanceMode returns a middleware that responds with 503 Service
+// Unavailable while the maintenance_mode setting is enabled. Administrators
+// and allowed paths are let through so the site can still be administered.
+func MaintenanceMode(config MaintenanceConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPublicPath(r.URL.Path, config.AllowedPaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			enabled, err := models.IsMaintenanceModeContext(r.Context(), config.DB.Connection)
+			if err != nil {
+				// Don't take the site down because the setting couldn't be read
+				log.Printf("Failed to check maintenance mode: %v", err)
+			}
+			if !enabled || auth.HasPermission(authenticatedUser(r, config.SecretKey, config.DB), auth.PermissionMaintenanceBypass) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			message, err := models.GetAppSettingValueContext(r.Context(), config.DB.Connection, "maintenance_message")
+			if err != nil || message == "" {
+				message = defaultMaintenanceMessage
+			}
+
+			w.Header().Set("Retry-After", maintenanceRetryAfter)
+			w.Header().Set("Cache-Control", "no-store")
+
+			if httperr.WantsJSON(r) || config.Templates == nil {
+				httperr.Write(w, r, http.StatusServiceUnavailable, httperr.Maintenance, message)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			if err := config.Templates.Render(w, "maintenance.html", templates.TemplateData{
+				Title: "Maintenance",
+				Data:  message,
+			}); err != nil {
+				log.Printf("Failed to render maintenance page: %v", err)
+			}
+		})
+	}
+}
+
+// authenticatedUser returns the user for a valid auth token in the request,
+// or nil when the request isn't signed in
+func authenticatedUser(r *http.Request, secretKey []byte, db *database.Database) *models.User {
+	tokenString, err := auth.GetTokenFromRequest(r)
+	if err != nil {
+		cookie, err := r.Cookie("auth_token")
+		if err != nil {
+			return nil
+		}
+		tokenString = cookie.Value
+	}
+
+	claims, err := auth.ValidateToken(tokenString, secretKey)
+	if err != nil {
+		return nil
+	}
+
+	userID, err := auth.GetUserIDFromToken(claims)
+	if err != nil {
+		return nil
+	}
+
+	user, err := models.GetUserByIDContext(r.Context(), db.Connection, userID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func setupMaintenanceDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, file := range []string{"001_initial_schema.up.sql", "002_app_settings.up.sql", "006_maintenance_mode.up.sql", "007_user_roles.up.sql", "014_user_theme.up.sql", "023_api_keys.up.sql", "042_signup_lock.up.sql"} {
+		migrationSQL, err := os.ReadFile("../../migrations/" + file)
+		if err != nil {
+			t.Fatalf("Failed to read migration file: %v", err)
+		}
+		if _, err := db.Exec(string(migrationSQL)); err != nil {
+			t.Fatalf("Failed to execute migration %s: %v", file, err)
+		}
+	}
+
+	return db
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	db := setupMaintenanceDB(t)
+	secretKey := []byte("test-secret")
+
+	handler := MaintenanceMode(MaintenanceConfig{
+		SecretKey:    secretKey,
+		DB:           &database.Database{Connection: db},
+		AllowedPaths: []string{"/login", "/static"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	serve := func(path string, configure func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if configure != nil {
+			configure(req)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		if rr := serve("/dashboard", nil); rr.Code != http.StatusOK {
+			t.Errorf("Expected status 200 when maintenance is off, got %d", rr.Code)
+		}
+	})
+
+	if err := models.UpdateAppSetting(db, "maintenance_mode", "true"); err != nil {
+		t.Fatalf("Failed to enable maintenance mode: %v", err)
+	}
+
+	t.Run("WebRequest", func(t *testing.T) {
+		rr := serve("/dashboard", nil)
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", rr.Code)
+		}
+		if rr.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header")
+		}
+	})
+
+	t.Run("APIRequest", func(t *testing.T) {
+		rr := serve("/api/v1/submit/abc", nil)
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected status 503, got %d", rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON response, got %q", ct)
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["error"] != "maintenance" {
+			t.Errorf("Expected maintenance error, got %v", body["error"])
+		}
+	})
+
+	t.Run("AllowedPaths", func(t *testing.T) {
+		for _, path := range []string{"/login", "/static/js/app.js"} {
+			if rr := serve(path, nil); rr.Code != http.StatusOK {
+				t.Errorf("Expected status 200 for %s, got %d", path, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Administrator", func(t *testing.T) {
+		user, err := models.CreateUser(db, "admin@example.com", "hash")
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		token, err := auth.GenerateToken(user, secretKey)
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+
+		rr := serve("/dashboard", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+		})
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected administrator to bypass maintenance, got %d", rr.Code)
+		}
+
+		rr = serve("/dashboard", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "auth_token", Value: "invalid"})
+		})
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected invalid token to get 503, got %d", rr.Code)
+		}
+	})
+
+	t.Run("RegularUser", func(t *testing.T) {
+		user, err := models.CreateUser(db, "user@example.com", "hash")
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		token, err := auth.GenerateToken(user, secretKey)
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+
+		rr := serve("/dashboard", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+		})
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected regular user to get 503, got %d", rr.Code)
+		}
+	})
+}
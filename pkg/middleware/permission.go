@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/httperr"
+)
+
+// RequirePermission returns a middleware that only lets through users whose
+// role grants the permission. It must run after AuthMiddleware.
+func RequirePermission(permission auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := GetUserFromContext(r.Context())
+			if !ok {
+				httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+				return
+			}
+
+			if !auth.HasPermission(user, permission) {
+				httperr.Write(w, r, http.StatusForbidden, httperr.Forbidden, "Forbidden: missing permission "+string(permission))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/models"
+)
+
+func TestRequirePermission(t *testing.T) {
+	handler := RequirePermission(auth.PermissionSettingsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name string
+		user *models.User
+		want int
+	}{
+		{"no user", nil, http.StatusUnauthorized},
+		{"regular user", &models.User{ID: 2, Role: models.RoleUser}, http.StatusForbidden},
+		{"administrator", &models.User{ID: 1, Role: models.RoleAdmin}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/settings", nil)
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), UserKey, tt.user))
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
@@ -7,9 +7,18 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"staticsend/pkg/clientip"
+	"staticsend/pkg/httperr"
 )
 
-// RateLimiter implements a token bucket rate limiter
+// LimiterStore tracks token buckets for rate limited keys. The in-memory
+// RateLimiter is the default; RedisRateLimiter shares buckets between replicas.
+type LimiterStore interface {
+	// Limit returns true if the request identified by key should be rate limited
+	Limit(key string) bool
+}
+
+// RateLimiter implements an in-memory token bucket rate limiter
 type RateLimiter struct {
 	mu          sync.Mutex
 	rate        time.Duration
@@ -77,6 +86,14 @@ func (rl *RateLimiter) Limit(key string) bool {
 	return false
 }
 
+// Len returns how many keys have buckets, including idle ones not yet
+// cleaned up
+func (rl *RateLimiter) Len() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.buckets)
+}
+
 // cleanup removes old buckets to prevent memory leaks
 func (rl *RateLimiter) cleanup() {
 	now := time.Now()
@@ -90,16 +107,20 @@ func (rl *RateLimiter) cleanup() {
 
 // IPRateLimit creates a middleware that rate limits by IP address
 func IPRateLimit(rate time.Duration, burst int) func(http.Handler) http.Handler {
-	limiter := NewRateLimiter(rate, burst)
+	return IPRateLimitWithStore(NewRateLimiter(rate, burst))
+}
 
+// IPRateLimitWithStore creates a middleware that rate limits by IP address using the given store
+func IPRateLimitWithStore(limiter LimiterStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get client IP
-			ip := getClientIP(r)
+			// Get client IP, from forwarding headers only behind a trusted
+			// proxy, so clients can't pick a fresh bucket for each request
+			ip := clientip.FromRequest(r)
 
 			// Check rate limit
 			if limiter.Limit(ip) {
-				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				httperr.Write(w, r, http.StatusTooManyRequests, httperr.RateLimited, "Rate limit exceeded")
 				return
 			}
 
@@ -108,23 +129,6 @@ func IPRateLimit(rate time.Duration, burst int) func(http.Handler) http.Handler
 	}
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check Cloudflare headers first
-	if ip := r.Header.Get("CF-Connecting-IP"); ip != "" {
-		return ip
-	}
-	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-		return ip
-	}
-	if ip := r.Header.Get("X-Real-IP"); ip != "" {
-		return ip
-	}
-
-	// Fall back to remote address
-	return r.RemoteAddr
-}
-
 // RateLimitResponse adds rate limit headers to responses
 func RateLimitResponse(limiter *RateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -136,7 +140,7 @@ func RateLimitResponse(limiter *RateLimiter) func(http.Handler) http.Handler {
 
 			// Add rate limit headers for successful requests
 			if ww.Status() >= 200 && ww.Status() < 300 {
-				ip := getClientIP(r)
+				ip := clientip.FromRequest(r)
 
 				limiter.mu.Lock()
 				bucket, exists := limiter.buckets[ip]
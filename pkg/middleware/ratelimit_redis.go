@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript applies the same token bucket algorithm as RateLimiter
+// atomically inside Redis. It returns 1 when the request should be limited.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+
+local toAdd = math.floor((now - last) / rate)
+if toAdd > 0 then
+	tokens = math.min(burst, tokens + toAdd)
+	last = now
+end
+
+local limited = 0
+if tokens <= 0 then
+	limited = 1
+else
+	tokens = tokens - 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', last)
+redis.call('PEXPIRE', KEYS[1], ttl)
+return limited
+`)
+
+// redisTimeout bounds how long a rate limit check may wait on Redis
+const redisTimeout = 500 * time.Millisecond
+
+// RedisRateLimiter implements a token bucket rate limiter backed by Redis so
+// that limits are shared between replicas and survive restarts
+type RedisRateLimiter struct {
+	client redis.UniversalClient
+	prefix string
+	rate   time.Duration
+	burst  int
+}
+
+// NewRedisRateLimiter creates a Redis-backed rate limiter. The prefix keeps
+// buckets for different routes apart when they share a Redis instance.
+func NewRedisRateLimiter(client redis.UniversalClient, prefix string, rate time.Duration, burst int) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		prefix: prefix,
+		rate:   rate,
+		burst:  burst,
+	}
+}
+
+// Limit returns true if the request should be rate limited. Redis errors fail
+// open so an unavailable Redis doesn't take the whole service down with it.
+func (rl *RedisRateLimiter) Limit(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	// Idle buckets expire after an hour, matching the in-memory cleanup
+	limited, err := tokenBucketScript.Run(ctx, rl.client, []string{rl.key(key)},
+		max(rl.rate.Milliseconds(), 1),
+		rl.burst,
+		time.Now().UnixMilli(),
+		time.Hour.Milliseconds(),
+	).Int()
+	if err != nil {
+		log.Printf("Rate limiter: redis check failed, allowing request: %v", err)
+		return false
+	}
+
+	return limited == 1
+}
+
+// key builds the Redis key for a rate limited client
+func (rl *RedisRateLimiter) key(key string) string {
+	return "staticsend:ratelimit:" + rl.prefix + ":" + key
+}
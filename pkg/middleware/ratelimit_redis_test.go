@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func setupTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestRedisRateLimiter_Limit(t *testing.T) {
+	_, client := setupTestRedis(t)
+	limiter := NewRedisRateLimiter(client, "test", 100*time.Millisecond, 2)
+
+	// First two requests should not be limited
+	if limiter.Limit("test-key") {
+		t.Error("First request should not be limited")
+	}
+
+	if limiter.Limit("test-key") {
+		t.Error("Second request should not be limited")
+	}
+
+	// Third request should be limited (burst exceeded)
+	if !limiter.Limit("test-key") {
+		t.Error("Third request should be limited")
+	}
+
+	// Wait for tokens to replenish
+	time.Sleep(150 * time.Millisecond)
+
+	if limiter.Limit("test-key") {
+		t.Error("Request after wait should not be limited")
+	}
+}
+
+func TestRedisRateLimiter_SharedBetweenInstances(t *testing.T) {
+	_, client := setupTestRedis(t)
+
+	// Two limiters with the same prefix simulate two replicas
+	replicaA := NewRedisRateLimiter(client, "submit", time.Minute, 1)
+	replicaB := NewRedisRateLimiter(client, "submit", time.Minute, 1)
+
+	if replicaA.Limit("1.2.3.4") {
+		t.Error("First request should not be limited")
+	}
+
+	if !replicaB.Limit("1.2.3.4") {
+		t.Error("Second request on another replica should be limited")
+	}
+
+	// A different prefix must not share the bucket
+	other := NewRedisRateLimiter(client, "login", time.Minute, 1)
+	if other.Limit("1.2.3.4") {
+		t.Error("Request for a different route should not be limited")
+	}
+}
+
+func TestRedisRateLimiter_ExpiresIdleBuckets(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	limiter := NewRedisRateLimiter(client, "test", time.Minute, 1)
+
+	limiter.Limit("test-key")
+
+	key := limiter.key("test-key")
+	if !mr.Exists(key) {
+		t.Fatal("Bucket should exist in Redis")
+	}
+
+	mr.FastForward(2 * time.Hour)
+
+	if mr.Exists(key) {
+		t.Error("Bucket should have expired")
+	}
+}
+
+func TestRedisRateLimiter_FailsOpen(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	limiter := NewRedisRateLimiter(client, "test", time.Minute, 1)
+
+	mr.Close()
+
+	for i := 0; i < 3; i++ {
+		if limiter.Limit("test-key") {
+			t.Errorf("Request %d should not be limited when Redis is unavailable", i+1)
+		}
+	}
+}
+
+func TestIPRateLimitWithStore_Redis(t *testing.T) {
+	_, client := setupTestRedis(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	middleware := IPRateLimitWithStore(NewRedisRateLimiter(client, "test", time.Second, 1))(handler)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.1:8080"
+
+	rr := httptest.NewRecorder()
+	middleware.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+
+	rr2 := httptest.NewRecorder()
+	middleware.ServeHTTP(rr2, req)
+	if rr2.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", rr2.Code)
+	}
+}
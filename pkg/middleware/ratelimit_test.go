@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"staticsend/pkg/clientip"
 )
 
 func TestNewRateLimiter(t *testing.T) {
@@ -101,58 +103,44 @@ func TestIPRateLimit_Middleware(t *testing.T) {
 	}
 }
 
-func TestGetClientIP(t *testing.T) {
-	tests := []struct {
-		name     string
-		header   map[string]string
-		expected string
-	}{
-		{
-			name:     "CF-Connecting-IP header",
-			header:   map[string]string{"CF-Connecting-IP": "1.2.3.4"},
-			expected: "1.2.3.4",
-		},
-		{
-			name:     "X-Forwarded-For header",
-			header:   map[string]string{"X-Forwarded-For": "5.6.7.8"},
-			expected: "5.6.7.8",
-		},
-		{
-			name:     "X-Real-IP header",
-			header:   map[string]string{"X-Real-IP": "9.10.11.12"},
-			expected: "9.10.11.12",
-		},
-		{
-			name:     "Remote address fallback",
-			header:   map[string]string{},
-			expected: "192.168.1.1:8080",
-		},
-		{
-			name: "Precedence - CF-Connecting-IP first",
-			header: map[string]string{
-				"CF-Connecting-IP": "1.2.3.4",
-				"X-Forwarded-For":  "5.6.7.8",
-				"X-Real-IP":        "9.10.11.12",
-			},
-			expected: "1.2.3.4",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/", nil)
-			req.RemoteAddr = "192.168.1.1:8080"
-
-			// Set headers
-			for key, value := range tt.header {
-				req.Header.Set(key, value)
-			}
-
-			ip := getClientIP(req)
-			if ip != tt.expected {
-				t.Errorf("Expected IP %s, got %s", tt.expected, ip)
-			}
-		})
+func TestIPRateLimit_ClientIP(t *testing.T) {
+	limited := IPRateLimit(time.Hour, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(remoteAddr string, header map[string]string) int {
+		req := httptest.NewRequest("POST", "/auth/login", nil)
+		req.RemoteAddr = remoteAddr
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		rr := httptest.NewRecorder()
+		limited.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := serve("203.0.113.7:50000", nil); code != http.StatusOK {
+		t.Fatalf("Expected the first request through, got %d", code)
+	}
+	// A new connection from the same address shares its bucket
+	if code := serve("203.0.113.7:50001", nil); code != http.StatusTooManyRequests {
+		t.Errorf("Expected another port to be limited, got %d", code)
+	}
+	// As do made up forwarding headers
+	for _, header := range []string{"CF-Connecting-IP", "X-Forwarded-For", "X-Real-IP"} {
+		if code := serve("203.0.113.7:50002", map[string]string{header: "198.51.100.1"}); code != http.StatusTooManyRequests {
+			t.Errorf("Expected a spoofed %s to be limited, got %d", header, code)
+		}
+	}
+
+	// Behind a trusted proxy each client has its own bucket
+	proxies, _ := clientip.ParseProxies([]string{"10.0.0.0/8"})
+	clientip.SetTrustedProxies(proxies)
+	defer clientip.SetTrustedProxies(nil)
+	if code := serve("10.0.0.2:80", map[string]string{"X-Forwarded-For": "198.51.100.2"}); code != http.StatusOK {
+		t.Errorf("Expected a client behind the proxy through, got %d", code)
+	}
+	if code := serve("10.0.0.2:80", map[string]string{"X-Forwarded-For": "198.51.100.2"}); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the same client behind the proxy to be limited, got %d", code)
 	}
 }
 
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/models"
+)
+
+// SetupConfig holds first-run setup configuration
+type SetupConfig struct {
+	DB *database.Database
+	// SetupPath is the setup wizard, where pages redirect until the first
+	// account has been created
+	SetupPath string
+	// AllowedPaths stay reachable before setup, e.g. static files and health checks
+	AllowedPaths []string
+}
+
+// RequireSetup returns a middleware that sends page requests to the setup
+// wizard while the database has no users, so the first account is created
+// there rather than through open registration. API requests are let through.
+// Once an account exists the database is no longer checked.
+func RequireSetup(config SetupConfig) func(http.Handler) http.Handler {
+	var setUp atomic.Bool
+	allowed := append([]string{config.SetupPath}, config.AllowedPaths...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if setUp.Load() || isPublicPath(r.URL.Path, allowed) || httperr.WantsJSON(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hasUsers, err := models.HasUsersContext(r.Context(), config.DB.Connection)
+			if err != nil {
+				log.Printf("Failed to check for users: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if hasUsers {
+				setUp.Store(true)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Header.Get("HX-Request") != "" {
+				w.Header().Set("HX-Redirect", config.SetupPath)
+				return
+			}
+			http.Redirect(w, r, config.SetupPath, http.StatusSeeOther)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestRequireSetup(t *testing.T) {
+	db := setupMaintenanceDB(t)
+
+	handler := RequireSetup(SetupConfig{
+		DB:           &database.Database{Connection: db},
+		SetupPath:    "/setup",
+		AllowedPaths: []string{"/static"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	serve := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("/register", nil); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/setup" {
+		t.Errorf("Expected a redirect to setup, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := serve("/dashboard", map[string]string{"HX-Request": "true"}); rr.Header().Get("HX-Redirect") != "/setup" {
+		t.Errorf("Expected an HTMX redirect to setup, got %q", rr.Header().Get("HX-Redirect"))
+	}
+	for _, path := range []string{"/setup", "/setup/email", "/static/css/theme.css", "/api/forms"} {
+		if rr := serve(path, nil); rr.Code != http.StatusOK {
+			t.Errorf("Expected %s to be reachable before setup, got %d", path, rr.Code)
+		}
+	}
+
+	if _, err := models.CreateUser(db, "admin@example.com", "hash"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if rr := serve("/register", nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected pages to be served once an account exists, got %d", rr.Code)
+	}
+}
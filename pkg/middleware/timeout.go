@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Timeout is chi's Timeout middleware, except for downloads: routes matching
+// one of the download patterns stream responses that can take far longer, so
+// they get downloadTimeout for both handling the request and writing the
+// response, replacing the server's WriteTimeout. A zero timeout disables it.
+// Patterns match GET requests unless they start with a method, as in
+// "POST /settings/data-requests/export".
+func Timeout(timeout, downloadTimeout time.Duration, downloads ...string) func(http.Handler) http.Handler {
+	matcher := chi.NewRouter()
+	for _, pattern := range downloads {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = http.MethodGet, pattern
+		}
+		matcher.MethodFunc(method, path, http.NotFound)
+	}
+
+	return func(next http.Handler) http.Handler {
+		limited := next
+		if timeout > 0 {
+			limited = middleware.Timeout(timeout)(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !matcher.Match(chi.NewRouteContext(), r.Method, r.URL.Path) {
+				limited.ServeHTTP(w, r)
+				return
+			}
+
+			var deadline time.Time
+			if downloadTimeout > 0 {
+				deadline = time.Now().Add(downloadTimeout)
+				ctx, cancel := context.WithDeadline(r.Context(), deadline)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+			// Not every writer can move its deadline; the server's then applies
+			http.NewResponseController(w).SetWriteDeadline(deadline)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	handler := Timeout(50*time.Millisecond, time.Minute, "/forms/{id}/archive", "POST /settings/data-requests/export")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Write([]byte("done"))
+	}))
+
+	// The server's WriteTimeout would cut the download off too
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/forms/3/submissions")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504 for a slow page, got %d", resp.StatusCode)
+	}
+
+	// Patterns are for GET unless they name a method
+	resp, err = http.Post(server.URL+"/forms/3/archive", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504 for a slow POST to a GET download, got %d", resp.StatusCode)
+	}
+
+	downloads := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/forms/3/archive"},
+		{http.MethodPost, "/settings/data-requests/export"},
+	}
+	for _, d := range downloads {
+		req, _ := http.NewRequest(d.method, server.URL+d.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Download of %s failed: %v", d.path, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK || string(body) != "done" {
+			t.Errorf("Expected %s %s to finish, got status %d, body %q, error %v", d.method, d.path, resp.StatusCode, body, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// APIKey lets an integration such as Zapier or Make call the API as a user.
+// Only a hash of the key is stored, so it's shown once when created.
+type APIKey struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	// Prefix is the start of the key, to tell keys apart
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, token_hash, created_at, last_used_at FROM api_keys"
+
+// scanAPIKey reads an API key row, returning nil if there isn't one
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.TokenHash, &key.CreatedAt, &lastUsedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return &key, nil
+}
+
+// CreateAPIKeyContext stores a new API key for a user by the hash of its token
+func CreateAPIKeyContext(ctx context.Context, db *sql.DB, userID int64, name, prefix, tokenHash string) (*APIKey, error) {
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO api_keys (user_id, name, prefix, token_hash) VALUES (?, ?, ?, ?)",
+		userID, name, prefix, tokenHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return scanAPIKey(db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" WHERE id = ?", id))
+}
+
+// CreateAPIKey is like CreateAPIKeyContext but uses context.Background
+func CreateAPIKey(db *sql.DB, userID int64, name, prefix, tokenHash string) (*APIKey, error) {
+	return CreateAPIKeyContext(context.Background(), db, userID, name, prefix, tokenHash)
+}
+
+// GetAPIKeyByHashContext returns the API key with a token hash, or nil if
+// there isn't one
+func GetAPIKeyByHashContext(ctx context.Context, db *sql.DB, tokenHash string) (*APIKey, error) {
+	return scanAPIKey(db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" WHERE token_hash = ?", tokenHash))
+}
+
+// GetAPIKeyByHash is like GetAPIKeyByHashContext but uses context.Background
+func GetAPIKeyByHash(db *sql.DB, tokenHash string) (*APIKey, error) {
+	return GetAPIKeyByHashContext(context.Background(), db, tokenHash)
+}
+
+// GetAPIKeysByUserIDContext returns a user's API keys, newest first
+func GetAPIKeysByUserIDContext(ctx context.Context, db *sql.DB, userID int64) ([]APIKey, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+apiKeyColumns+" WHERE user_id = ? ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeysByUserID is like GetAPIKeysByUserIDContext but uses context.Background
+func GetAPIKeysByUserID(db *sql.DB, userID int64) ([]APIKey, error) {
+	return GetAPIKeysByUserIDContext(context.Background(), db, userID)
+}
+
+// TouchAPIKeyContext records that an API key was just used
+func TouchAPIKeyContext(ctx context.Context, db *sql.DB, id int64) error {
+	_, err := db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", sqlTime(time.Now()), id)
+	return err
+}
+
+// TouchAPIKey is like TouchAPIKeyContext but uses context.Background
+func TouchAPIKey(db *sql.DB, id int64) error {
+	return TouchAPIKeyContext(context.Background(), db, id)
+}
+
+// DeleteAPIKeyContext revokes one of a user's API keys, reporting whether
+// they had a key with that ID
+func DeleteAPIKeyContext(ctx context.Context, db *sql.DB, userID, id int64) (bool, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM api_keys WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteAPIKey is like DeleteAPIKeyContext but uses context.Background
+func DeleteAPIKey(db *sql.DB, userID, id int64) (bool, error) {
+	return DeleteAPIKeyContext(context.Background(), db, userID, id)
+}
@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestAPIKeys(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "keys@example.com", "hashed_password")
+	first, err := CreateAPIKey(db, user.ID, "Zapier", "ss_aaaaaa", "hash-one")
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	second, _ := CreateAPIKey(db, user.ID, "Make", "ss_bbbbbb", "hash-two")
+	if _, err := CreateAPIKey(db, user.ID, "Copy", "ss_aaaaaa", "hash-one"); err == nil {
+		t.Error("Expected a duplicate key hash to be rejected")
+	}
+
+	keys, err := GetAPIKeysByUserID(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to list API keys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != second.ID || keys[1].LastUsedAt != nil {
+		t.Fatalf("Expected both unused keys newest first, got %+v", keys)
+	}
+
+	found, err := GetAPIKeyByHash(db, "hash-one")
+	if err != nil || found == nil || found.ID != first.ID || found.Name != "Zapier" {
+		t.Fatalf("Expected to find the key by its hash, got %+v (%v)", found, err)
+	}
+	if missing, err := GetAPIKeyByHash(db, "hash-three"); err != nil || missing != nil {
+		t.Errorf("Expected no key for an unknown hash, got %+v (%v)", missing, err)
+	}
+
+	if err := TouchAPIKey(db, first.ID); err != nil {
+		t.Fatalf("Failed to record key use: %v", err)
+	}
+	if found, _ := GetAPIKeyByHash(db, "hash-one"); found.LastUsedAt == nil {
+		t.Error("Expected the key's last use to be recorded")
+	}
+
+	if deleted, err := DeleteAPIKey(db, user.ID, first.ID); err != nil || !deleted {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	if keys, _ := GetAPIKeysByUserID(db, user.ID); len(keys) != 1 {
+		t.Errorf("Expected one key left, got %d", len(keys))
+	}
+}
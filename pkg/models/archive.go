@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SubmissionArchive is a manifest entry for a file of archived submissions
+type SubmissionArchive struct {
+	ID                int64     `json:"id"`
+	FormID            int64     `json:"form_id"`
+	Name              string    `json:"name"`
+	SubmissionCount   int       `json:"submission_count"`
+	FirstSubmissionAt time.Time `json:"first_submission_at"`
+	LastSubmissionAt  time.Time `json:"last_submission_at"`
+	Size              int64     `json:"size"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ArchiveSubmissionsContext records an archive file in the manifest and
+// deletes the submissions it holds, along with their email records, notes
+// and assignments, in one transaction. The archived submissions are the
+// form's submissions created before the given time with IDs up to lastID.
+func ArchiveSubmissionsContext(ctx context.Context, db *sql.DB, archive *SubmissionArchive, before time.Time, lastID int64) (*SubmissionArchive, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO submission_archives (form_id, name, submission_count, first_submission_at, last_submission_at, size) VALUES (?, ?, ?, ?, ?, ?)",
+		archive.FormID, archive.Name, archive.SubmissionCount, sqlTime(archive.FirstSubmissionAt), sqlTime(archive.LastSubmissionAt), archive.Size,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{archive.FormID, sqlTime(before), lastID}
+	for _, table := range submissionChildTables {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM "+table+" WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ? AND created_at < ? AND id <= ?)",
+			args...,
+		); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM submissions WHERE form_id = ? AND created_at < ? AND id <= ?",
+		args...,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetSubmissionArchiveByIDContext(ctx, db, id)
+}
+
+// ArchiveSubmissions is like ArchiveSubmissionsContext but uses context.Background
+func ArchiveSubmissions(db *sql.DB, archive *SubmissionArchive, before time.Time, lastID int64) (*SubmissionArchive, error) {
+	return ArchiveSubmissionsContext(context.Background(), db, archive, before, lastID)
+}
+
+// GetSubmissionArchiveByIDContext retrieves a manifest entry by its ID
+func GetSubmissionArchiveByIDContext(ctx context.Context, db *sql.DB, id int64) (*SubmissionArchive, error) {
+	var archive SubmissionArchive
+	err := db.QueryRowContext(ctx,
+		"SELECT id, form_id, name, submission_count, first_submission_at, last_submission_at, size, created_at FROM submission_archives WHERE id = ?",
+		id,
+	).Scan(&archive.ID, &archive.FormID, &archive.Name, &archive.SubmissionCount, &archive.FirstSubmissionAt, &archive.LastSubmissionAt, &archive.Size, &archive.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &archive, nil
+}
+
+// GetSubmissionArchiveByID is like GetSubmissionArchiveByIDContext but uses context.Background
+func GetSubmissionArchiveByID(db *sql.DB, id int64) (*SubmissionArchive, error) {
+	return GetSubmissionArchiveByIDContext(context.Background(), db, id)
+}
+
+// GetSubmissionArchivesByFormIDContext returns a form's archive files,
+// oldest first
+func GetSubmissionArchivesByFormIDContext(ctx context.Context, db *sql.DB, formID int64) ([]SubmissionArchive, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, form_id, name, submission_count, first_submission_at, last_submission_at, size, created_at FROM submission_archives WHERE form_id = ? ORDER BY id",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archives []SubmissionArchive
+	for rows.Next() {
+		var archive SubmissionArchive
+		if err := rows.Scan(&archive.ID, &archive.FormID, &archive.Name, &archive.SubmissionCount, &archive.FirstSubmissionAt, &archive.LastSubmissionAt, &archive.Size, &archive.CreatedAt); err != nil {
+			return nil, err
+		}
+		archives = append(archives, archive)
+	}
+
+	return archives, rows.Err()
+}
+
+// GetSubmissionArchivesByFormID is like GetSubmissionArchivesByFormIDContext but uses context.Background
+func GetSubmissionArchivesByFormID(db *sql.DB, formID int64) ([]SubmissionArchive, error) {
+	return GetSubmissionArchivesByFormIDContext(context.Background(), db, formID)
+}
+
+// GetArchivedSubmissionCountContext returns how many of a form's submissions
+// have been archived
+func GetArchivedSubmissionCountContext(ctx context.Context, db *sql.DB, formID int64) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(submission_count), 0) FROM submission_archives WHERE form_id = ?",
+		formID,
+	).Scan(&count)
+
+	return count, err
+}
+
+// GetArchivedSubmissionCount is like GetArchivedSubmissionCountContext but uses context.Background
+func GetArchivedSubmissionCount(db *sql.DB, formID int64) (int, error) {
+	return GetArchivedSubmissionCountContext(context.Background(), db, formID)
+}
+
+// SubmissionArchiveExistsContext reports whether the manifest lists an
+// archive file
+func SubmissionArchiveExistsContext(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM submission_archives WHERE name = ?)",
+		name,
+	).Scan(&exists)
+
+	return exists, err
+}
+
+// SubmissionArchiveExists is like SubmissionArchiveExistsContext but uses context.Background
+func SubmissionArchiveExists(db *sql.DB, name string) (bool, error) {
+	return SubmissionArchiveExistsContext(context.Background(), db, name)
+}
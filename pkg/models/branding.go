@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Settings holding the instance's branding
+const (
+	SettingSiteTitle       = "site_title"
+	SettingSiteDescription = "site_description"
+	SettingPrimaryColor    = "primary_color"
+)
+
+// SiteAssetLogo is the name of the uploaded logo
+const SiteAssetLogo = "logo"
+
+// Branding is how the instance presents itself on its pages
+type Branding struct {
+	Title        string
+	Description  string
+	PrimaryColor string
+	// LogoUpdatedAt is when the logo was uploaded, or nil without one
+	LogoUpdatedAt *time.Time
+}
+
+// SiteAsset is an uploaded file the pages use, such as the logo
+type SiteAsset struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	UpdatedAt   time.Time
+}
+
+// GetBrandingContext returns the instance's branding settings
+func GetBrandingContext(ctx context.Context, db *sql.DB) (*Branding, error) {
+	branding := &Branding{}
+	for key, value := range map[string]*string{
+		SettingSiteTitle:       &branding.Title,
+		SettingSiteDescription: &branding.Description,
+		SettingPrimaryColor:    &branding.PrimaryColor,
+	} {
+		setting, err := GetAppSettingValueContext(ctx, db, key)
+		if err != nil {
+			return nil, err
+		}
+		*value = setting
+	}
+
+	var updatedAt time.Time
+	err := db.QueryRowContext(ctx,
+		"SELECT updated_at FROM site_assets WHERE name = ?",
+		SiteAssetLogo,
+	).Scan(&updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		branding.LogoUpdatedAt = &updatedAt
+	}
+
+	return branding, nil
+}
+
+// GetBranding is like GetBrandingContext but uses context.Background
+func GetBranding(db *sql.DB) (*Branding, error) {
+	return GetBrandingContext(context.Background(), db)
+}
+
+// GetSiteAssetContext returns an uploaded file by name, or nil if there
+// isn't one
+func GetSiteAssetContext(ctx context.Context, db *sql.DB, name string) (*SiteAsset, error) {
+	asset := &SiteAsset{}
+	err := db.QueryRowContext(ctx,
+		"SELECT name, content_type, data, updated_at FROM site_assets WHERE name = ?",
+		name,
+	).Scan(&asset.Name, &asset.ContentType, &asset.Data, &asset.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// GetSiteAsset is like GetSiteAssetContext but uses context.Background
+func GetSiteAsset(db *sql.DB, name string) (*SiteAsset, error) {
+	return GetSiteAssetContext(context.Background(), db, name)
+}
+
+// SaveSiteAssetContext stores an uploaded file, replacing any with the
+// same name
+func SaveSiteAssetContext(ctx context.Context, db *sql.DB, name, contentType string, data []byte) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM site_assets WHERE name = ?", name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO site_assets (name, content_type, data, updated_at) VALUES (?, ?, ?, ?)",
+		name, contentType, data, sqlTime(time.Now()),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SaveSiteAsset is like SaveSiteAssetContext but uses context.Background
+func SaveSiteAsset(db *sql.DB, name, contentType string, data []byte) error {
+	return SaveSiteAssetContext(context.Background(), db, name, contentType, data)
+}
+
+// DeleteSiteAssetContext removes an uploaded file
+func DeleteSiteAssetContext(ctx context.Context, db *sql.DB, name string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM site_assets WHERE name = ?", name)
+	return err
+}
+
+// DeleteSiteAsset is like DeleteSiteAssetContext but uses context.Background
+func DeleteSiteAsset(db *sql.DB, name string) error {
+	return DeleteSiteAssetContext(context.Background(), db, name)
+}
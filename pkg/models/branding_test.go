@@ -0,0 +1,46 @@
+package models
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBranding(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	branding, err := GetBranding(db)
+	if err != nil {
+		t.Fatalf("Failed to get branding: %v", err)
+	}
+	if branding.Title != "staticSend" || branding.PrimaryColor != "#2563eb" || branding.LogoUpdatedAt != nil {
+		t.Errorf("Expected the default branding, got %+v", branding)
+	}
+
+	logo := []byte("\x89PNG\r\n\x1a\nlogo")
+	if err := SaveSiteAsset(db, SiteAssetLogo, "image/png", logo); err != nil {
+		t.Fatalf("Failed to save logo: %v", err)
+	}
+	// Saving again replaces the logo
+	if err := SaveSiteAsset(db, SiteAssetLogo, "image/png", logo); err != nil {
+		t.Fatalf("Failed to replace logo: %v", err)
+	}
+
+	asset, err := GetSiteAsset(db, SiteAssetLogo)
+	if err != nil || asset == nil {
+		t.Fatalf("Expected the logo, got %v (err %v)", asset, err)
+	}
+	if asset.ContentType != "image/png" || !bytes.Equal(asset.Data, logo) {
+		t.Errorf("Unexpected logo: %+v", asset)
+	}
+	if branding, _ := GetBranding(db); branding.LogoUpdatedAt == nil {
+		t.Error("Expected the branding to include the logo")
+	}
+
+	if err := DeleteSiteAsset(db, SiteAssetLogo); err != nil {
+		t.Fatalf("Failed to delete logo: %v", err)
+	}
+	if asset, err := GetSiteAsset(db, SiteAssetLogo); err != nil || asset != nil {
+		t.Errorf("Expected no logo, got %v (err %v)", asset, err)
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// GetSubmissionColumnsContext retrieves the submitted fields a user shows as
+// columns of a form's submissions, or nil if they haven't picked any
+func GetSubmissionColumnsContext(ctx context.Context, db *sql.DB, userID, formID int64) ([]string, error) {
+	var fieldNames string
+	err := db.QueryRowContext(ctx,
+		"SELECT field_names FROM submission_columns WHERE user_id = ? AND form_id = ?",
+		userID, formID,
+	).Scan(&fieldNames)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []string{}
+	if err := json.Unmarshal([]byte(fieldNames), &columns); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// GetSubmissionColumns is like GetSubmissionColumnsContext but uses context.Background
+func GetSubmissionColumns(db *sql.DB, userID, formID int64) ([]string, error) {
+	return GetSubmissionColumnsContext(context.Background(), db, userID, formID)
+}
+
+// SetSubmissionColumnsContext saves the submitted fields a user shows as
+// columns of a form's submissions
+func SetSubmissionColumnsContext(ctx context.Context, db *sql.DB, userID, formID int64, columns []string) error {
+	if columns == nil {
+		columns = []string{}
+	}
+	fieldNames, err := json.Marshal(columns)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM submission_columns WHERE user_id = ? AND form_id = ?",
+		userID, formID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO submission_columns (user_id, form_id, field_names) VALUES (?, ?, ?)",
+		userID, formID, string(fieldNames),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetSubmissionColumns is like SetSubmissionColumnsContext but uses context.Background
+func SetSubmissionColumns(db *sql.DB, userID, formID int64, columns []string) error {
+	return SetSubmissionColumnsContext(context.Background(), db, userID, formID, columns)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ParseCountries splits a list of ISO 3166 country codes, separated by
+// commas or spaces, into unique uppercase codes such as "NZ"
+func ParseCountries(value string) ([]string, error) {
+	countries := []string{}
+	seen := map[string]bool{}
+	for _, code := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, fmt.Errorf("%q isn't a two letter country code", code)
+		}
+		if !seen[code] {
+			seen[code] = true
+			countries = append(countries, code)
+		}
+	}
+	return countries, nil
+}
+
+// AcceptsCountry reports whether the form takes submissions from a country.
+// Submissions whose country isn't known are always accepted.
+func (f *Form) AcceptsCountry(country string) bool {
+	if country == "" {
+		return true
+	}
+	blocked, _ := ParseCountries(f.BlockedCountries)
+	for _, code := range blocked {
+		if code == country {
+			return false
+		}
+	}
+	allowed, _ := ParseCountries(f.AllowedCountries)
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, code := range allowed {
+		if code == country {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFormCountriesContext sets the countries a form accepts submissions
+// from and those it refuses. An empty allowed list accepts every country
+// not blocked.
+func SetFormCountriesContext(ctx context.Context, db *sql.DB, formID int64, allowed, blocked []string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET allowed_countries = ?, blocked_countries = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		strings.Join(allowed, ","), strings.Join(blocked, ","), formID,
+	)
+	return err
+}
+
+// SetFormCountries is like SetFormCountriesContext but uses
+// context.Background
+func SetFormCountries(db *sql.DB, formID int64, allowed, blocked []string) error {
+	return SetFormCountriesContext(context.Background(), db, formID, allowed, blocked)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCountries(t *testing.T) {
+	countries, err := ParseCountries(" nz, AU,nz\nde ")
+	if err != nil {
+		t.Fatalf("ParseCountries failed: %v", err)
+	}
+	if want := []string{"NZ", "AU", "DE"}; !reflect.DeepEqual(countries, want) {
+		t.Errorf("Expected %v, got %v", want, countries)
+	}
+	if countries, err := ParseCountries(""); err != nil || len(countries) != 0 {
+		t.Errorf("Expected no countries, got %v (%v)", countries, err)
+	}
+	for _, value := range []string{"NZL", "N", "N1", "New Zealand"} {
+		if _, err := ParseCountries(value); err == nil {
+			t.Errorf("Expected %q to be refused", value)
+		}
+	}
+}
+
+func TestFormCountries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "countries@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "countries", "example.com", "secret", "to@example.com")
+	if !form.AcceptsCountry("NZ") {
+		t.Error("Expected new forms to accept every country")
+	}
+
+	if err := SetFormCountries(db, form.ID, []string{"NZ", "AU"}, nil); err != nil {
+		t.Fatalf("Failed to set countries: %v", err)
+	}
+	form, _ = GetFormByID(db, form.ID)
+	if form.AllowedCountries != "NZ,AU" || form.BlockedCountries != "" {
+		t.Errorf("Expected NZ and AU to be allowed, got %q and %q", form.AllowedCountries, form.BlockedCountries)
+	}
+	for country, want := range map[string]bool{"NZ": true, "AU": true, "US": false, "": true} {
+		if got := form.AcceptsCountry(country); got != want {
+			t.Errorf("AcceptsCountry(%q) = %v with an allowlist, want %v", country, got, want)
+		}
+	}
+
+	SetFormCountries(db, form.ID, nil, []string{"US"})
+	form, _ = GetFormByID(db, form.ID)
+	for country, want := range map[string]bool{"NZ": true, "US": false} {
+		if got := form.AcceptsCountry(country); got != want {
+			t.Errorf("AcceptsCountry(%q) = %v with a blocklist, want %v", country, got, want)
+		}
+	}
+
+	submission, _ := CreateSubmission(db, form.ID, "203.0.113.1", "Browser", []byte(`{}`))
+	if submission.Country != "" {
+		t.Errorf("Expected no country until one is recorded, got %q", submission.Country)
+	}
+	if err := SetSubmissionCountry(db, submission.ID, "NZ"); err != nil {
+		t.Fatalf("Failed to record country: %v", err)
+	}
+	if located, _ := GetSubmissionByID(db, submission.ID); located.Country != "NZ" {
+		t.Errorf("Expected NZ, got %q", located.Country)
+	}
+}
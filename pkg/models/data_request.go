@@ -0,0 +1,278 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Actions taken on a data subject request
+const (
+	DataRequestExport = "export"
+	DataRequestRedact = "redact"
+	DataRequestDelete = "delete"
+)
+
+// RedactedValue replaces each field of a redacted submission
+const RedactedValue = "[REDACTED]"
+
+// DataRequest records an administrator answering a data subject request,
+// the audit trail kept for regulators. OwnerEmail is the account whose
+// forms were searched, or "" if every account's were.
+type DataRequest struct {
+	ID              int64     `json:"id"`
+	Email           string    `json:"email"`
+	Action          string    `json:"action"`
+	OwnerEmail      string    `json:"owner_email"`
+	AdminEmail      string    `json:"admin_email"`
+	SubmissionCount int       `json:"submission_count"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// SubjectSubmission is a submission holding a data subject's email address,
+// with the name of its form
+type SubjectSubmission struct {
+	Submission
+	FormName string `json:"form_name"`
+}
+
+// emailPattern finds email addresses in a field's value
+var emailPattern = regexp.MustCompile(`[^\s<>(),;:"'\[\]]+@[^\s<>(),;:"'\[\]]+`)
+
+// likeEscaper escapes LIKE wildcards, such as the _ common in addresses,
+// with the ! named in the query's ESCAPE clause
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// FindSubmissionsByEmailContext returns the submissions with a field holding
+// the email address, ignoring case, oldest first. Only the forms of ownerID
+// are searched, or every form if it's 0. Archived submissions aren't in the
+// database, so aren't found.
+func FindSubmissionsByEmailContext(ctx context.Context, db *sql.DB, ownerID int64, email string) ([]SubjectSubmission, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	query := "SELECT id, form_id, ip_address, user_agent, referrer, submitted_data, created_at, processed_at, status, spam_at, spam_reason, spam_score, spam_signals, country FROM submissions WHERE LOWER(submitted_data) LIKE ? ESCAPE '!'"
+	args := []interface{}{"%" + likeEscaper.Replace(email) + "%"}
+	if ownerID != 0 {
+		query += " AND form_id IN (SELECT id FROM forms WHERE user_id = ?)"
+		args = append(args, ownerID)
+	}
+	// The LIKE narrows the search; the fields are checked for the address
+	// itself, so jo@example.com doesn't find bojo@example.com
+	submissions, err := querySubmissions(ctx, db, query+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []SubjectSubmission
+	formNames := map[int64]string{}
+	for _, submission := range submissions {
+		if !holdsEmail(submission.SubmittedData, email) {
+			continue
+		}
+		name, ok := formNames[submission.FormID]
+		if !ok {
+			form, err := GetFormByIDContext(ctx, db, submission.FormID)
+			if err != nil {
+				return nil, err
+			}
+			if form != nil {
+				name = form.Name
+			}
+			formNames[submission.FormID] = name
+		}
+		matches = append(matches, SubjectSubmission{Submission: submission, FormName: name})
+	}
+	return matches, nil
+}
+
+// FindSubmissionsByEmail is like FindSubmissionsByEmailContext but uses context.Background
+func FindSubmissionsByEmail(db *sql.DB, ownerID int64, email string) ([]SubjectSubmission, error) {
+	return FindSubmissionsByEmailContext(context.Background(), db, ownerID, email)
+}
+
+// holdsEmail reports whether any of a submission's fields holds the email
+// address, which is in lower case
+func holdsEmail(data json.RawMessage, email string) bool {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	for _, value := range fields {
+		if valueHoldsEmail(value, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueHoldsEmail reports whether a field's value, or any of its values,
+// holds the email address
+func valueHoldsEmail(value interface{}, email string) bool {
+	switch v := value.(type) {
+	case string:
+		for _, address := range emailPattern.FindAllString(v, -1) {
+			if strings.ToLower(strings.TrimRight(address, ".")) == email {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if valueHoldsEmail(item, email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EraseSubmissionsContext answers a request to erase a data subject's
+// submissions, recording it in the same transaction. Deleting removes the
+// submissions as DeleteSubmissionContext does. Redacting keeps them, so
+// counts and charts are unchanged, but replaces every field's value with
+// RedactedValue, clears the sender's details and spam signals, and deletes
+// their notes and files. Uploaded files are swept from their store later.
+func EraseSubmissionsContext(ctx context.Context, db *sql.DB, request *DataRequest, submissions []SubjectSubmission) (*DataRequest, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, submission := range submissions {
+		if request.Action == DataRequestDelete {
+			for _, table := range submissionChildTables {
+				if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE submission_id = ?", submission.ID); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM submissions WHERE id = ?", submission.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(submission.SubmittedData, &fields); err != nil {
+			return nil, err
+		}
+		for name := range fields {
+			fields[name] = RedactedValue
+		}
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE submissions SET submitted_data = ?, ip_address = '', user_agent = '', referrer = '', country = '', spam_signals = NULL WHERE id = ?",
+			string(data), submission.ID,
+		); err != nil {
+			return nil, err
+		}
+		for _, table := range []string{"submission_notes", "submission_files"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE submission_id = ?", submission.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	id, err := insertDataRequest(ctx, tx, request, len(submissions))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetDataRequestByIDContext(ctx, db, id)
+}
+
+// EraseSubmissions is like EraseSubmissionsContext but uses context.Background
+func EraseSubmissions(db *sql.DB, request *DataRequest, submissions []SubjectSubmission) (*DataRequest, error) {
+	return EraseSubmissionsContext(context.Background(), db, request, submissions)
+}
+
+// CreateDataRequestContext records a data subject request that didn't
+// change any submissions, such as an export
+func CreateDataRequestContext(ctx context.Context, db *sql.DB, request *DataRequest, submissionCount int) (*DataRequest, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertDataRequest(ctx, tx, request, submissionCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetDataRequestByIDContext(ctx, db, id)
+}
+
+// CreateDataRequest is like CreateDataRequestContext but uses context.Background
+func CreateDataRequest(db *sql.DB, request *DataRequest, submissionCount int) (*DataRequest, error) {
+	return CreateDataRequestContext(context.Background(), db, request, submissionCount)
+}
+
+// insertDataRequest records a data subject request in tx, returning its ID
+func insertDataRequest(ctx context.Context, tx *sql.Tx, request *DataRequest, submissionCount int) (int64, error) {
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO data_requests (email, action, owner_email, admin_email, submission_count) VALUES (?, ?, ?, ?, ?)",
+		strings.ToLower(strings.TrimSpace(request.Email)), request.Action, request.OwnerEmail, request.AdminEmail, submissionCount,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetDataRequestByIDContext retrieves a data subject request by its ID
+func GetDataRequestByIDContext(ctx context.Context, db *sql.DB, id int64) (*DataRequest, error) {
+	var request DataRequest
+	err := db.QueryRowContext(ctx,
+		"SELECT id, email, action, owner_email, admin_email, submission_count, created_at FROM data_requests WHERE id = ?",
+		id,
+	).Scan(&request.ID, &request.Email, &request.Action, &request.OwnerEmail, &request.AdminEmail, &request.SubmissionCount, &request.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetDataRequestByID is like GetDataRequestByIDContext but uses context.Background
+func GetDataRequestByID(db *sql.DB, id int64) (*DataRequest, error) {
+	return GetDataRequestByIDContext(context.Background(), db, id)
+}
+
+// GetDataRequestsContext returns the newest data subject requests, up to
+// limit
+func GetDataRequestsContext(ctx context.Context, db *sql.DB, limit int) ([]DataRequest, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, email, action, owner_email, admin_email, submission_count, created_at FROM data_requests ORDER BY created_at DESC, id DESC LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []DataRequest
+	for rows.Next() {
+		var request DataRequest
+		if err := rows.Scan(&request.ID, &request.Email, &request.Action, &request.OwnerEmail, &request.AdminEmail, &request.SubmissionCount, &request.CreatedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// GetDataRequests is like GetDataRequestsContext but uses context.Background
+func GetDataRequests(db *sql.DB, limit int) ([]DataRequest, error) {
+	return GetDataRequestsContext(context.Background(), db, limit)
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFindSubmissionsByEmail(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, _ := CreateUser(db, "owner@example.com", "hashed_password")
+	other, _ := CreateUser(db, "other@example.com", "hashed_password")
+	contact, _ := CreateForm(db, owner.ID, "Contact", "example.com", "secret", "to@example.com", "gdpr_contact")
+	signup, _ := CreateForm(db, other.ID, "Signup", "example.org", "secret", "to@example.org", "gdpr_signup")
+
+	first, _ := CreateSubmission(db, contact.ID, "203.0.113.1", "Browser", []byte(`{"email":"Jo_Smith@Example.com","message":"Hi"}`))
+	CreateSubmission(db, contact.ID, "203.0.113.2", "Browser", []byte(`{"email":"bojo_smith@example.com"}`))
+	CreateSubmission(db, contact.ID, "203.0.113.3", "Browser", []byte(`{"email":"joxsmith@example.com"}`))
+	second, _ := CreateSubmission(db, signup.ID, "203.0.113.4", "Browser", []byte(`{"message":"Write to me at jo_smith@example.com."}`))
+
+	matches, err := FindSubmissionsByEmail(db, 0, "jo_smith@example.com")
+	if err != nil {
+		t.Fatalf("Failed to find submissions: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != first.ID || matches[1].ID != second.ID {
+		t.Fatalf("Expected the two submissions holding the address, got %+v", matches)
+	}
+	if matches[0].FormName != "Contact" || matches[1].FormName != "Signup" {
+		t.Errorf("Expected the forms' names, got %q and %q", matches[0].FormName, matches[1].FormName)
+	}
+
+	matches, err = FindSubmissionsByEmail(db, owner.ID, "jo_smith@example.com")
+	if err != nil || len(matches) != 1 || matches[0].ID != first.ID {
+		t.Errorf("Expected only the owner's submission, got %+v (%v)", matches, err)
+	}
+}
+
+func TestEraseSubmissions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "owner@example.com", "hashed_password")
+	form, _ := CreateForm(db, user.ID, "Contact", "example.com", "secret", "to@example.com", "gdpr_erase")
+	submission, _ := CreateReferredSubmission(db, form.ID, "203.0.113.1", "Browser", "https://example.com/contact", []byte(`{"email":"jo@example.com","message":"Hi"}`))
+	CreateSubmissionNote(db, submission.ID, user.ID, "Called Jo back")
+	CreateSubmissionEmail(db, submission.ID, "sent", "")
+
+	matches, _ := FindSubmissionsByEmail(db, 0, "jo@example.com")
+	request, err := EraseSubmissions(db, &DataRequest{Email: " Jo@Example.com", Action: DataRequestRedact, AdminEmail: "admin@example.com"}, matches)
+	if err != nil {
+		t.Fatalf("Failed to redact submissions: %v", err)
+	}
+	if request.Email != "jo@example.com" || request.SubmissionCount != 1 || request.OwnerEmail != "" {
+		t.Errorf("Unexpected request record %+v", request)
+	}
+
+	redacted, _ := GetSubmissionByID(db, submission.ID)
+	var fields map[string]string
+	json.Unmarshal(redacted.SubmittedData, &fields)
+	if len(fields) != 2 || fields["email"] != RedactedValue || fields["message"] != RedactedValue {
+		t.Errorf("Expected every field to be redacted, got %v", fields)
+	}
+	if redacted.IPAddress != "" || redacted.UserAgent != "" || redacted.Referrer != "" {
+		t.Errorf("Expected the sender's details to be cleared, got %+v", redacted)
+	}
+	if notes, _ := GetSubmissionNotes(db, submission.ID); len(notes) != 0 {
+		t.Errorf("Expected the notes to be deleted, got %d", len(notes))
+	}
+	if matches, _ := FindSubmissionsByEmail(db, 0, "jo@example.com"); len(matches) != 0 {
+		t.Errorf("Expected a redacted submission not to be found, got %d", len(matches))
+	}
+
+	other, _ := CreateSubmission(db, form.ID, "203.0.113.1", "Browser", []byte(`{"email":"sam@example.com"}`))
+	matches, _ = FindSubmissionsByEmail(db, user.ID, "sam@example.com")
+	if _, err := EraseSubmissions(db, &DataRequest{Email: "sam@example.com", Action: DataRequestDelete, OwnerEmail: user.Email, AdminEmail: "admin@example.com"}, matches); err != nil {
+		t.Fatalf("Failed to delete submissions: %v", err)
+	}
+	if deleted, _ := GetSubmissionByID(db, other.ID); deleted != nil {
+		t.Error("Expected the submission to be deleted")
+	}
+
+	CreateDataRequest(db, &DataRequest{Email: "sam@example.com", Action: DataRequestExport, AdminEmail: "admin@example.com"}, 0)
+	requests, err := GetDataRequests(db, 10)
+	if err != nil || len(requests) != 3 || requests[0].Action != DataRequestExport || requests[2].Action != DataRequestRedact {
+		t.Errorf("Expected the three requests, newest first, got %+v (%v)", requests, err)
+	}
+}
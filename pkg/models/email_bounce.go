@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Why mail to an address is failing
+const (
+	// BounceKindBounce is a permanent delivery failure, such as a mailbox
+	// that doesn't exist
+	BounceKindBounce = "bounce"
+	// BounceKindComplaint is the recipient reporting a message as spam
+	BounceKindComplaint = "complaint"
+)
+
+// EmailBounce records notification emails to an address failing, as told
+// by the sending provider or a bounce message
+type EmailBounce struct {
+	Address string `json:"address"`
+	// Kind is the latest failure, one of the BounceKind constants
+	Kind string `json:"kind"`
+	// Provider is where the failure was reported from, such as ses or imap
+	Provider string `json:"provider"`
+	// Reason is the latest failure's explanation, such as the receiving
+	// server's response
+	Reason         string    `json:"reason"`
+	Count          int       `json:"count"`
+	FirstBouncedAt time.Time `json:"first_bounced_at"`
+	LastBouncedAt  time.Time `json:"last_bounced_at"`
+}
+
+// normalizeAddress returns the form addresses are stored and matched in
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// RecordEmailBounceContext records mail to address failing at the given
+// time, counting it with the address's earlier failures
+func RecordEmailBounceContext(ctx context.Context, db *sql.DB, address, kind, provider, reason string, at time.Time) error {
+	address = normalizeAddress(address)
+	bouncedAt := sqlTime(at)
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO email_bounces (address, kind, provider, reason, bounce_count, first_bounced_at, last_bounced_at) VALUES (?, ?, ?, ?, 1, ?, ?) ON CONFLICT (address) DO UPDATE SET kind = excluded.kind, provider = excluded.provider, reason = excluded.reason, bounce_count = email_bounces.bounce_count + 1, last_bounced_at = excluded.last_bounced_at",
+		address, kind, provider, reason, bouncedAt, bouncedAt,
+	)
+	return err
+}
+
+// RecordEmailBounce is like RecordEmailBounceContext but uses context.Background
+func RecordEmailBounce(db *sql.DB, address, kind, provider, reason string, at time.Time) error {
+	return RecordEmailBounceContext(context.Background(), db, address, kind, provider, reason, at)
+}
+
+// scanEmailBounce scans a row of the columns emailBounceColumns lists
+func scanEmailBounce(row rowScanner) (*EmailBounce, error) {
+	var bounce EmailBounce
+	err := row.Scan(&bounce.Address, &bounce.Kind, &bounce.Provider, &bounce.Reason, &bounce.Count, &bounce.FirstBouncedAt, &bounce.LastBouncedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &bounce, nil
+}
+
+// emailBounceColumns are the columns scanEmailBounce reads
+const emailBounceColumns = "b.address, b.kind, b.provider, b.reason, b.bounce_count, b.first_bounced_at, b.last_bounced_at"
+
+// GetEmailBounceContext retrieves the failures recorded for an address,
+// or nil if mail to it hasn't failed
+func GetEmailBounceContext(ctx context.Context, db *sql.DB, address string) (*EmailBounce, error) {
+	bounce, err := scanEmailBounce(db.QueryRowContext(ctx,
+		"SELECT "+emailBounceColumns+" FROM email_bounces b WHERE b.address = ?",
+		normalizeAddress(address),
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return bounce, err
+}
+
+// GetEmailBounce is like GetEmailBounceContext but uses context.Background
+func GetEmailBounce(db *sql.DB, address string) (*EmailBounce, error) {
+	return GetEmailBounceContext(context.Background(), db, address)
+}
+
+// GetEmailBouncesByUserIDContext retrieves the failures recorded for the
+// forward addresses of every form a user owns, keyed by form ID, so form
+// lists don't need a query per form
+func GetEmailBouncesByUserIDContext(ctx context.Context, db *sql.DB, userID int64) (map[int64]*EmailBounce, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT f.id, "+emailBounceColumns+" FROM forms f JOIN email_bounces b ON b.address = LOWER(f.forward_email) WHERE f.user_id = ?",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bounces := make(map[int64]*EmailBounce)
+	for rows.Next() {
+		var formID int64
+		var bounce EmailBounce
+		if err := rows.Scan(&formID, &bounce.Address, &bounce.Kind, &bounce.Provider, &bounce.Reason, &bounce.Count, &bounce.FirstBouncedAt, &bounce.LastBouncedAt); err != nil {
+			return nil, err
+		}
+		bounces[formID] = &bounce
+	}
+	return bounces, rows.Err()
+}
+
+// GetEmailBouncesByUserID is like GetEmailBouncesByUserIDContext but uses context.Background
+func GetEmailBouncesByUserID(db *sql.DB, userID int64) (map[int64]*EmailBounce, error) {
+	return GetEmailBouncesByUserIDContext(context.Background(), db, userID)
+}
+
+// ClearEmailBounceContext forgets an address's failures, once its owner
+// has fixed it
+func ClearEmailBounceContext(ctx context.Context, db *sql.DB, address string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM email_bounces WHERE address = ?", normalizeAddress(address))
+	return err
+}
+
+// ClearEmailBounce is like ClearEmailBounceContext but uses context.Background
+func ClearEmailBounce(db *sql.DB, address string) error {
+	return ClearEmailBounceContext(context.Background(), db, address)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordEmailBounce(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "user@example.com", "hashed_password")
+	bouncing := CreateTestForm(t, db, user.ID, "Contact", "example.com", "secret", "Owner@Example.com")
+	CreateTestForm(t, db, user.ID, "Quote", "example.com", "secret", "sales@example.com")
+
+	first := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	if err := RecordEmailBounce(db, "owner@example.com", BounceKindBounce, "ses", "550 5.1.1 user unknown", first); err != nil {
+		t.Fatalf("Failed to record bounce: %v", err)
+	}
+	if err := RecordEmailBounce(db, " OWNER@example.com", BounceKindComplaint, "sendgrid", "abuse", first.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to record complaint: %v", err)
+	}
+
+	bounce, err := GetEmailBounce(db, "owner@example.com")
+	if err != nil || bounce == nil {
+		t.Fatalf("Expected the bounce recorded, got %v (%v)", bounce, err)
+	}
+	if bounce.Count != 2 || bounce.Kind != BounceKindComplaint || bounce.Provider != "sendgrid" || bounce.Reason != "abuse" {
+		t.Errorf("Expected the latest failure counted with the first, got %+v", bounce)
+	}
+	if !bounce.FirstBouncedAt.Equal(first) || !bounce.LastBouncedAt.Equal(first.Add(time.Hour)) {
+		t.Errorf("Expected the first and last failure times, got %v and %v", bounce.FirstBouncedAt, bounce.LastBouncedAt)
+	}
+
+	bounces, err := GetEmailBouncesByUserID(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get bounces: %v", err)
+	}
+	if len(bounces) != 1 || bounces[bouncing.ID] == nil {
+		t.Errorf("Expected only the bouncing form's address, got %v", bounces)
+	}
+
+	if err := ClearEmailBounce(db, "Owner@Example.com"); err != nil {
+		t.Fatalf("Failed to clear bounce: %v", err)
+	}
+	if bounce, err := GetEmailBounce(db, "owner@example.com"); err != nil || bounce != nil {
+		t.Errorf("Expected the bounce cleared, got %v (%v)", bounce, err)
+	}
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Export formats
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+)
+
+// Export job statuses
+const (
+	ExportStatusPending = "pending"
+	ExportStatusRunning = "running"
+	ExportStatusDone    = "done"
+	ExportStatusFailed  = "failed"
+)
+
+// ExportJob is an export of a form's submissions written in the background
+type ExportJob struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"user_id"`
+	FormID   int64  `json:"form_id"`
+	FormName string `json:"form_name"`
+	Format   string `json:"format"`
+	Status   string `json:"status"`
+	// FileName is the name of the written file in the export store
+	FileName    string     `json:"file_name"`
+	RowCount    int        `json:"row_count"`
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	// ExpiresAt is when the download link stops working
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// IsValidExportFormat reports whether format is a known export format
+func IsValidExportFormat(format string) bool {
+	return format == ExportFormatCSV || format == ExportFormatJSONL
+}
+
+// Expired reports whether the export's download link has expired
+func (j *ExportJob) Expired(now time.Time) bool {
+	return j.ExpiresAt != nil && !now.Before(*j.ExpiresAt)
+}
+
+const exportJobColumns = "j.id, j.user_id, j.form_id, f.name, j.format, j.status, j.file_name, j.row_count, j.size, j.error, j.created_at, j.completed_at, j.expires_at FROM export_jobs j JOIN forms f ON f.id = j.form_id"
+
+// scanExportJob reads an export job row, returning nil if there isn't one
+func scanExportJob(row rowScanner) (*ExportJob, error) {
+	var job ExportJob
+	var completedAt, expiresAt sql.NullTime
+	err := row.Scan(&job.ID, &job.UserID, &job.FormID, &job.FormName, &job.Format, &job.Status, &job.FileName, &job.RowCount, &job.Size, &job.Error, &job.CreatedAt, &completedAt, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		job.ExpiresAt = &expiresAt.Time
+	}
+	return &job, nil
+}
+
+// CreateExportJobContext queues an export of a form's submissions
+func CreateExportJobContext(ctx context.Context, db *sql.DB, userID, formID int64, format string) (*ExportJob, error) {
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO export_jobs (user_id, form_id, format, status) VALUES (?, ?, ?, ?)",
+		userID, formID, format, ExportStatusPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetExportJobByIDContext(ctx, db, id)
+}
+
+// CreateExportJob is like CreateExportJobContext but uses context.Background
+func CreateExportJob(db *sql.DB, userID, formID int64, format string) (*ExportJob, error) {
+	return CreateExportJobContext(context.Background(), db, userID, formID, format)
+}
+
+// GetExportJobByIDContext retrieves an export job by its ID
+func GetExportJobByIDContext(ctx context.Context, db *sql.DB, id int64) (*ExportJob, error) {
+	return scanExportJob(db.QueryRowContext(ctx, "SELECT "+exportJobColumns+" WHERE j.id = ?", id))
+}
+
+// GetExportJobByID is like GetExportJobByIDContext but uses context.Background
+func GetExportJobByID(db *sql.DB, id int64) (*ExportJob, error) {
+	return GetExportJobByIDContext(context.Background(), db, id)
+}
+
+// GetExportJobsByUserIDContext returns a user's newest export jobs, up to
+// limit, optionally only those of one form when formID isn't 0
+func GetExportJobsByUserIDContext(ctx context.Context, db *sql.DB, userID, formID int64, limit int) ([]ExportJob, error) {
+	query := "SELECT " + exportJobColumns + " WHERE j.user_id = ?"
+	args := []interface{}{userID}
+	if formID != 0 {
+		query += " AND j.form_id = ?"
+		args = append(args, formID)
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY j.id DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []ExportJob
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// GetExportJobsByUserID is like GetExportJobsByUserIDContext but uses context.Background
+func GetExportJobsByUserID(db *sql.DB, userID, formID int64, limit int) ([]ExportJob, error) {
+	return GetExportJobsByUserIDContext(context.Background(), db, userID, formID, limit)
+}
+
+// ClaimExportJobContext marks the oldest pending export job as running and
+// returns it, or nil if none is pending. A job is only claimed once, even
+// with several workers.
+func ClaimExportJobContext(ctx context.Context, db *sql.DB) (*ExportJob, error) {
+	for {
+		var id int64
+		err := db.QueryRowContext(ctx,
+			"SELECT id FROM export_jobs WHERE status = ? ORDER BY id LIMIT 1",
+			ExportStatusPending,
+		).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := db.ExecContext(ctx,
+			"UPDATE export_jobs SET status = ? WHERE id = ? AND status = ?",
+			ExportStatusRunning, id, ExportStatusPending,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// Another worker claimed it first, so try the next one
+		if claimed, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if claimed == 1 {
+			return GetExportJobByIDContext(ctx, db, id)
+		}
+	}
+}
+
+// ClaimExportJob is like ClaimExportJobContext but uses context.Background
+func ClaimExportJob(db *sql.DB) (*ExportJob, error) {
+	return ClaimExportJobContext(context.Background(), db)
+}
+
+// CompleteExportJobContext records a written export and when its download
+// link expires
+func CompleteExportJobContext(ctx context.Context, db *sql.DB, id int64, fileName string, rowCount int, size int64, expiresAt time.Time) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE export_jobs SET status = ?, file_name = ?, row_count = ?, size = ?, completed_at = ?, expires_at = ? WHERE id = ?",
+		ExportStatusDone, fileName, rowCount, size, sqlTime(time.Now()), sqlTime(expiresAt), id,
+	)
+	return err
+}
+
+// CompleteExportJob is like CompleteExportJobContext but uses context.Background
+func CompleteExportJob(db *sql.DB, id int64, fileName string, rowCount int, size int64, expiresAt time.Time) error {
+	return CompleteExportJobContext(context.Background(), db, id, fileName, rowCount, size, expiresAt)
+}
+
+// FailExportJobContext records why an export couldn't be written, keeping
+// the job listed until expiresAt
+func FailExportJobContext(ctx context.Context, db *sql.DB, id int64, message string, expiresAt time.Time) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE export_jobs SET status = ?, error = ?, completed_at = ?, expires_at = ? WHERE id = ?",
+		ExportStatusFailed, message, sqlTime(time.Now()), sqlTime(expiresAt), id,
+	)
+	return err
+}
+
+// FailExportJob is like FailExportJobContext but uses context.Background
+func FailExportJob(db *sql.DB, id int64, message string, expiresAt time.Time) error {
+	return FailExportJobContext(context.Background(), db, id, message, expiresAt)
+}
+
+// ResetRunningExportJobsContext puts jobs left running, e.g. by a restart
+// part way through, back in the queue
+func ResetRunningExportJobsContext(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE export_jobs SET status = ? WHERE status = ?",
+		ExportStatusPending, ExportStatusRunning,
+	)
+	return err
+}
+
+// ResetRunningExportJobs is like ResetRunningExportJobsContext but uses context.Background
+func ResetRunningExportJobs(db *sql.DB) error {
+	return ResetRunningExportJobsContext(context.Background(), db)
+}
+
+// DeleteExpiredExportJobsContext deletes the finished export jobs that
+// expired by now and returns their file names, so the files can be removed
+func DeleteExpiredExportJobsContext(ctx context.Context, db *sql.DB, now time.Time) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	condition := "status IN (?, ?) AND expires_at <= ?"
+	args := []interface{}{ExportStatusDone, ExportStatusFailed, sqlTime(now)}
+	rows, err := tx.QueryContext(ctx, "SELECT file_name FROM export_jobs WHERE "+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM export_jobs WHERE "+condition, args...); err != nil {
+		return nil, err
+	}
+	return names, tx.Commit()
+}
+
+// DeleteExpiredExportJobs is like DeleteExpiredExportJobsContext but uses context.Background
+func DeleteExpiredExportJobs(db *sql.DB, now time.Time) ([]string, error) {
+	return DeleteExpiredExportJobsContext(context.Background(), db, now)
+}
+
+// ExportFileExistsContext reports whether an export job refers to a file
+func ExportFileExistsContext(ctx context.Context, db *sql.DB, fileName string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM export_jobs WHERE file_name = ?", fileName).Scan(&count)
+	return count > 0, err
+}
+
+// ExportFileExists is like ExportFileExistsContext but uses context.Background
+func ExportFileExists(db *sql.DB, fileName string) (bool, error) {
+	return ExportFileExistsContext(context.Background(), db, fileName)
+}
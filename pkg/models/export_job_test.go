@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportJobs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "export@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "export", "example.com", "secret", "to@example.com")
+
+	first, err := CreateExportJob(db, user.ID, form.ID, ExportFormatCSV)
+	if err != nil {
+		t.Fatalf("Failed to create export job: %v", err)
+	}
+	if first.Status != ExportStatusPending || first.FormName != "export" {
+		t.Fatalf("Expected a pending export of the form, got %+v", first)
+	}
+	second, _ := CreateExportJob(db, user.ID, form.ID, ExportFormatJSONL)
+
+	claimed, err := ClaimExportJob(db)
+	if err != nil {
+		t.Fatalf("Failed to claim export job: %v", err)
+	}
+	if claimed == nil || claimed.ID != first.ID || claimed.Status != ExportStatusRunning {
+		t.Fatalf("Expected the oldest job to be claimed, got %+v", claimed)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	if err := CompleteExportJob(db, first.ID, "export-1.csv", 3, 120, expiresAt); err != nil {
+		t.Fatalf("Failed to complete export job: %v", err)
+	}
+	done, _ := GetExportJobByID(db, first.ID)
+	if done.Status != ExportStatusDone || done.FileName != "export-1.csv" || done.RowCount != 3 || done.Size != 120 {
+		t.Fatalf("Expected the export to be recorded, got %+v", done)
+	}
+	if done.ExpiresAt == nil || done.Expired(time.Now()) || !done.Expired(expiresAt.Add(time.Second)) {
+		t.Errorf("Expected the export to expire at %v, got %v", expiresAt, done.ExpiresAt)
+	}
+	if exists, _ := ExportFileExists(db, "export-1.csv"); !exists {
+		t.Error("Expected the export file to be referenced")
+	}
+
+	// A job interrupted while running is queued again
+	ClaimExportJob(db)
+	if err := ResetRunningExportJobs(db); err != nil {
+		t.Fatalf("Failed to reset running jobs: %v", err)
+	}
+	if job, _ := GetExportJobByID(db, second.ID); job.Status != ExportStatusPending {
+		t.Errorf("Expected the interrupted job to be pending, got %s", job.Status)
+	}
+	ClaimExportJob(db)
+	if err := FailExportJob(db, second.ID, "disk full", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Failed to fail export job: %v", err)
+	}
+	if job, _ := ClaimExportJob(db); job != nil {
+		t.Errorf("Expected no pending jobs, got %+v", job)
+	}
+
+	jobs, err := GetExportJobsByUserID(db, user.ID, form.ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to list export jobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != second.ID || jobs[0].Error != "disk full" {
+		t.Fatalf("Expected both jobs, newest first, got %+v", jobs)
+	}
+	if jobs, _ := GetExportJobsByUserID(db, user.ID+1, 0, 10); len(jobs) != 0 {
+		t.Errorf("Expected no jobs for another user, got %d", len(jobs))
+	}
+
+	// Only the expired failure is deleted; it has no file
+	names, err := DeleteExpiredExportJobs(db, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to delete expired jobs: %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("Expected no files to delete, got %v", names)
+	}
+	names, _ = DeleteExpiredExportJobs(db, expiresAt.Add(time.Second))
+	if len(names) != 1 || names[0] != "export-1.csv" {
+		t.Errorf("Expected the expired export's file, got %v", names)
+	}
+	if jobs, _ := GetExportJobsByUserID(db, user.ID, 0, 10); len(jobs) != 0 {
+		t.Errorf("Expected every job to be deleted, got %d", len(jobs))
+	}
+}
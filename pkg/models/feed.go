@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Feed is a form's token-guarded feed of its latest submissions
+type Feed struct {
+	FormID    int64     `json:"form_id"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetFeedByFormIDContext returns a form's feed, or nil if it
+// doesn't have one
+func GetFeedByFormIDContext(ctx context.Context, db *sql.DB, formID int64) (*Feed, error) {
+	return scanFeed(db.QueryRowContext(ctx,
+		"SELECT form_id, token, created_at FROM form_feeds WHERE form_id = ?",
+		formID,
+	))
+}
+
+// GetFeedByFormID is like GetFeedByFormIDContext but uses context.Background
+func GetFeedByFormID(db *sql.DB, formID int64) (*Feed, error) {
+	return GetFeedByFormIDContext(context.Background(), db, formID)
+}
+
+// GetFeedByTokenContext returns the feed with a token, or nil
+// if there isn't one
+func GetFeedByTokenContext(ctx context.Context, db *sql.DB, token string) (*Feed, error) {
+	return scanFeed(db.QueryRowContext(ctx,
+		"SELECT form_id, token, created_at FROM form_feeds WHERE token = ?",
+		token,
+	))
+}
+
+// GetFeedByToken is like GetFeedByTokenContext but uses context.Background
+func GetFeedByToken(db *sql.DB, token string) (*Feed, error) {
+	return GetFeedByTokenContext(context.Background(), db, token)
+}
+
+// EnableFeedContext gives a form a feed at token, replacing
+// any it had so old links stop working
+func EnableFeedContext(ctx context.Context, db *sql.DB, formID int64, token string) (*Feed, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM form_feeds WHERE form_id = ?", formID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO form_feeds (form_id, token) VALUES (?, ?)",
+		formID, token,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetFeedByFormIDContext(ctx, db, formID)
+}
+
+// EnableFeed is like EnableFeedContext but uses context.Background
+func EnableFeed(db *sql.DB, formID int64, token string) (*Feed, error) {
+	return EnableFeedContext(context.Background(), db, formID, token)
+}
+
+// DisableFeedContext removes a form's feed
+func DisableFeedContext(ctx context.Context, db *sql.DB, formID int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM form_feeds WHERE form_id = ?", formID)
+	return err
+}
+
+// DisableFeed is like DisableFeedContext but uses context.Background
+func DisableFeed(db *sql.DB, formID int64) error {
+	return DisableFeedContext(context.Background(), db, formID)
+}
+
+// scanFeed reads a feed row, returning nil if there isn't one
+func scanFeed(row rowScanner) (*Feed, error) {
+	var feed Feed
+	err := row.Scan(&feed.FormID, &feed.Token, &feed.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &feed, nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestFeeds(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "feed@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "feed", "example.com", "secret", "to@example.com")
+
+	if feed, err := GetFeedByFormID(db, form.ID); err != nil || feed != nil {
+		t.Fatalf("Expected no feed, got %v (err %v)", feed, err)
+	}
+	if _, err := EnableFeed(db, form.ID, "first-token"); err != nil {
+		t.Fatalf("Failed to enable feed: %v", err)
+	}
+
+	// Enabling again replaces the token
+	if _, err := EnableFeed(db, form.ID, "second-token"); err != nil {
+		t.Fatalf("Failed to replace feed: %v", err)
+	}
+	if feed, _ := GetFeedByToken(db, "first-token"); feed != nil {
+		t.Error("Expected the old token to stop working")
+	}
+	feed, err := GetFeedByToken(db, "second-token")
+	if err != nil || feed == nil || feed.FormID != form.ID {
+		t.Fatalf("Expected the new token to find the form, got %v (err %v)", feed, err)
+	}
+
+	if err := DisableFeed(db, form.ID); err != nil {
+		t.Fatalf("Failed to disable feed: %v", err)
+	}
+	if feed, _ := GetFeedByFormID(db, form.ID); feed != nil {
+		t.Error("Expected the feed to be removed")
+	}
+
+	// Deleting a form removes its feed
+	EnableFeed(db, form.ID, "third-token")
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	if feed, _ := GetFeedByToken(db, "third-token"); feed != nil {
+		t.Error("Expected the feed to be deleted with the form")
+	}
+}
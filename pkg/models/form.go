@@ -1,8 +1,12 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"time"
+
+	"staticsend/pkg/secrets"
 )
 
 // Form represents a contact form configuration
@@ -12,18 +16,100 @@ type Form struct {
 	Name            string    `json:"name"`
 	Domain          string    `json:"domain"`
 	TurnstileSecret string    `json:"turnstile_secret"` // Private key for validation
+	TurnstileSiteKey string   `json:"turnstile_site_key"` // Public key for the widget
 	ForwardEmail    string    `json:"forward_email"`
 	FormKey         string    `json:"form_key"`         // Generated unique key
 	SubmissionCount int       `json:"submission_count"`
+	Tags            []string  `json:"tags"`
+	// EmailBounce is set when mail to ForwardEmail has been failing
+	EmailBounce     *EmailBounce `json:"email_bounce,omitempty"`
+	// MutedRecipients lists who muted the form's notifications with the
+	// unsubscribe link in one
+	MutedRecipients []NotificationMute `json:"muted_recipients,omitempty"`
+	AkismetEnabled  bool      `json:"akismet_enabled"`  // Check submissions with Akismet
+	ThreadBySender  bool      `json:"thread_by_sender"` // Thread emails by submitter, not form
+	// TurnstileFallback is what happens to submissions while Turnstile
+	// can't be reached, one of the TurnstileFallback constants
+	TurnstileFallback string  `json:"turnstile_fallback"`
+	// Submissions scoring at least SpamFlagThreshold are held as spam, and
+	// at least SpamRejectThreshold are refused. 0 turns a threshold off.
+	SpamFlagThreshold   int   `json:"spam_flag_threshold"`
+	SpamRejectThreshold int   `json:"spam_reject_threshold"`
+	// AllowedCountries and BlockedCountries are comma separated ISO country
+	// codes. With a GeoIP database, submissions from a country not allowed,
+	// when any are, or blocked are refused.
+	AllowedCountries string   `json:"allowed_countries"`
+	BlockedCountries string   `json:"blocked_countries"`
+	// EmailAttachment is the file notification emails attach the
+	// submission as, one of the EmailAttachment constants
+	EmailAttachment string    `json:"email_attachment"`
+	// HostedPage serves the form on a page of its own at /f/{FormKey}
+	HostedPage      bool      `json:"hosted_page"`
+	// AllowCC copies submissions' emails to the addresses in their _cc
+	// field. It's off unless the owner turns it on, as the sender picks them.
+	AllowCC         bool      `json:"allow_cc"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
-// CreateForm creates a new form in the database
-func CreateForm(db *sql.DB, userID int64, name, domain, turnstileSecret, forwardEmail, formKey string) (*Form, error) {
-	result, err := db.Exec(
+// What happens to a form's submissions while Turnstile can't be reached
+const (
+	// TurnstileFallbackClosed rejects them, the default
+	TurnstileFallbackClosed = "closed"
+	// TurnstileFallbackSpam accepts them but holds them as spam for review
+	TurnstileFallbackSpam = "spam"
+	// TurnstileFallbackHoneypot accepts them, relying on the honeypot field
+	TurnstileFallbackHoneypot = "honeypot"
+)
+
+// TurnstileFallbacks lists the Turnstile fallbacks in the order offered
+var TurnstileFallbacks = []string{TurnstileFallbackClosed, TurnstileFallbackSpam, TurnstileFallbackHoneypot}
+
+// ValidTurnstileFallback reports whether fallback is a known fallback
+func ValidTurnstileFallback(fallback string) bool {
+	for _, known := range TurnstileFallbacks {
+		if fallback == known {
+			return true
+		}
+	}
+	return false
+}
+
+// The file a form's notification emails attach the submission as
+const (
+	// EmailAttachmentNone attaches nothing, the default
+	EmailAttachmentNone = ""
+	// EmailAttachmentJSON attaches it as a JSON object
+	EmailAttachmentJSON = "json"
+	// EmailAttachmentCSV attaches it as a CSV file with one row
+	EmailAttachmentCSV = "csv"
+)
+
+// EmailAttachments lists the email attachments in the order offered
+var EmailAttachments = []string{EmailAttachmentNone, EmailAttachmentJSON, EmailAttachmentCSV}
+
+// ValidEmailAttachment reports whether attachment is a known email attachment
+func ValidEmailAttachment(attachment string) bool {
+	for _, known := range EmailAttachments {
+		if attachment == known {
+			return true
+		}
+	}
+	return false
+}
+
+// getFormByKeyQuery looks up the form a submission is for
+const getFormByKeyQuery = "SELECT id, user_id, name, domain, turnstile_secret, turnstile_site_key, forward_email, form_key, akismet_enabled, thread_by_sender, turnstile_fallback, spam_flag_threshold, spam_reject_threshold, allowed_countries, blocked_countries, email_attachment, hosted_page, allow_cc, created_at, updated_at FROM forms WHERE form_key = ?"
+
+// CreateFormContext creates a new form in the database
+func CreateFormContext(ctx context.Context, db *sql.DB, userID int64, name, domain, turnstileSecret, forwardEmail, formKey string) (*Form, error) {
+	sealedSecret, err := secrets.Seal(turnstileSecret)
+	if err != nil {
+		return nil, err
+	}
+	result, err := db.ExecContext(ctx,
 		"INSERT INTO forms (user_id, name, domain, turnstile_secret, forward_email, form_key) VALUES (?, ?, ?, ?, ?, ?)",
-		userID, name, domain, turnstileSecret, forwardEmail, formKey,
+		userID, name, domain, sealedSecret, forwardEmail, formKey,
 	)
 	if err != nil {
 		return nil, err
@@ -34,54 +120,177 @@ func CreateForm(db *sql.DB, userID int64, name, domain, turnstileSecret, forward
 		return nil, err
 	}
 
-	return GetFormByID(db, id)
+	return GetFormByIDContext(ctx, db, id)
 }
 
-// GetFormByID retrieves a form by its ID
-func GetFormByID(db *sql.DB, id int64) (*Form, error) {
-	var form Form
-	err := db.QueryRow(
-		"SELECT id, user_id, name, domain, turnstile_secret, forward_email, form_key, created_at, updated_at FROM forms WHERE id = ?",
-		id,
-	).Scan(&form.ID, &form.UserID, &form.Name, &form.Domain, &form.TurnstileSecret, &form.ForwardEmail, &form.FormKey, &form.CreatedAt, &form.UpdatedAt)
+// CreateForm is like CreateFormContext but uses context.Background
+func CreateForm(db *sql.DB, userID int64, name, domain, turnstileSecret, forwardEmail, formKey string) (*Form, error) {
+	return CreateFormContext(context.Background(), db, userID, name, domain, turnstileSecret, forwardEmail, formKey)
+}
 
+// DuplicateFormContext creates a new form with the same settings, tags, IP
+// rules and routing rules as form, in one transaction. Submissions aren't
+// copied.
+func DuplicateFormContext(ctx context.Context, db *sql.DB, form *Form, name, formKey string) (*Form, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &form, nil
-}
-
-// GetFormsByUserID retrieves all forms for a specific user
-func GetFormsByUserID(db *sql.DB, userID int64) ([]Form, error) {
-	rows, err := db.Query(
-		"SELECT id, user_id, name, domain, turnstile_secret, forward_email, form_key, created_at, updated_at FROM forms WHERE user_id = ? ORDER BY created_at DESC",
-		userID,
+	sealedSecret, err := secrets.Seal(form.TurnstileSecret)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO forms (user_id, name, domain, turnstile_secret, turnstile_site_key, forward_email, form_key, akismet_enabled, thread_by_sender, turnstile_fallback, spam_flag_threshold, spam_reject_threshold, allowed_countries, blocked_countries, email_attachment, hosted_page, allow_cc) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		form.UserID, name, form.Domain, sealedSecret, form.TurnstileSiteKey, form.ForwardEmail, formKey, form.AkismetEnabled, form.ThreadBySender, form.TurnstileFallback, form.SpamFlagThreshold, form.SpamRejectThreshold, form.AllowedCountries, form.BlockedCountries, form.EmailAttachment, form.HostedPage, form.AllowCC,
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
 
-	var forms []Form
-	for rows.Next() {
-		var form Form
-		if err := rows.Scan(&form.ID, &form.UserID, &form.Name, &form.Domain, &form.TurnstileSecret, &form.ForwardEmail, &form.FormKey, &form.CreatedAt, &form.UpdatedAt); err != nil {
-			return nil, err
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO form_tags (form_id, tag_id) SELECT ?, tag_id FROM form_tags WHERE form_id = ?",
+		id, form.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO ip_rules (form_id, cidr, action, note) SELECT ?, cidr, action, note FROM ip_rules WHERE form_id = ? ORDER BY id",
+		id, form.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO form_routes (form_id, position, field, operator, value, recipient) SELECT ?, position, field, operator, value, recipient FROM form_routes WHERE form_id = ? ORDER BY position, id",
+		id, form.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetFormByIDContext(ctx, db, id)
+}
+
+// DuplicateForm is like DuplicateFormContext but uses context.Background
+func DuplicateForm(db *sql.DB, form *Form, name, formKey string) (*Form, error) {
+	return DuplicateFormContext(context.Background(), db, form, name, formKey)
+}
+
+// CopyNameContext returns a name for a copy of the user's form called name,
+// such as "Contact copy" or "Contact copy 2", that no form of theirs has
+func CopyNameContext(ctx context.Context, db *sql.DB, userID int64, name string) (string, error) {
+	base := name + " copy"
+	candidate := base
+	for i := 2; ; i++ {
+		exists, err := FormExistsContext(ctx, db, userID, candidate)
+		if err != nil {
+			return "", err
 		}
-		forms = append(forms, form)
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s %d", base, i)
 	}
+}
 
-	return forms, nil
+// CopyName is like CopyNameContext but uses context.Background
+func CopyName(db *sql.DB, userID int64, name string) (string, error) {
+	return CopyNameContext(context.Background(), db, userID, name)
 }
 
-// FormExists checks if a form with the given name already exists for a user
-func FormExists(db *sql.DB, userID int64, name string) (bool, error) {
+// GetFormByIDContext retrieves a form by its ID
+func GetFormByIDContext(ctx context.Context, db *sql.DB, id int64) (*Form, error) {
+	return scanForm(db.QueryRowContext(ctx,
+		"SELECT id, user_id, name, domain, turnstile_secret, turnstile_site_key, forward_email, form_key, akismet_enabled, thread_by_sender, turnstile_fallback, spam_flag_threshold, spam_reject_threshold, allowed_countries, blocked_countries, email_attachment, hosted_page, allow_cc, created_at, updated_at FROM forms WHERE id = ?",
+		id,
+	))
+}
+
+// GetFormByID is like GetFormByIDContext but uses context.Background
+func GetFormByID(db *sql.DB, id int64) (*Form, error) {
+	return GetFormByIDContext(context.Background(), db, id)
+}
+
+// GetFormByIDForUserContext retrieves a form by its ID if the user owns it,
+// returning nil for other users' forms just as for missing ones. Handlers
+// acting for a user fetch forms with it, so they can't forget to check.
+func GetFormByIDForUserContext(ctx context.Context, db *sql.DB, id, userID int64) (*Form, error) {
+	return scanForm(db.QueryRowContext(ctx,
+		"SELECT id, user_id, name, domain, turnstile_secret, turnstile_site_key, forward_email, form_key, akismet_enabled, thread_by_sender, turnstile_fallback, spam_flag_threshold, spam_reject_threshold, allowed_countries, blocked_countries, email_attachment, hosted_page, allow_cc, created_at, updated_at FROM forms WHERE id = ? AND user_id = ?",
+		id, userID,
+	))
+}
+
+// GetFormByIDForUser is like GetFormByIDForUserContext but uses context.Background
+func GetFormByIDForUser(db *sql.DB, id, userID int64) (*Form, error) {
+	return GetFormByIDForUserContext(context.Background(), db, id, userID)
+}
+
+// GetFormsByUserIDContext retrieves all forms for a specific user
+func GetFormsByUserIDContext(ctx context.Context, db *sql.DB, userID int64) ([]Form, error) {
+	return queryForms(ctx, db,
+		"SELECT id, user_id, name, domain, turnstile_secret, turnstile_site_key, forward_email, form_key, akismet_enabled, thread_by_sender, turnstile_fallback, spam_flag_threshold, spam_reject_threshold, allowed_countries, blocked_countries, email_attachment, hosted_page, allow_cc, created_at, updated_at FROM forms WHERE user_id = ? ORDER BY created_at DESC",
+		userID,
+	)
+}
+
+// GetFormsByUserID is like GetFormsByUserIDContext but uses context.Background
+func GetFormsByUserID(db *sql.DB, userID int64) ([]Form, error) {
+	return GetFormsByUserIDContext(context.Background(), db, userID)
+}
+
+// GetFormsPageByUserIDContext retrieves up to limit of a user's forms, newest
+// first, skipping the first offset. A non-empty tag only includes forms
+// with that tag.
+func GetFormsPageByUserIDContext(ctx context.Context, db *sql.DB, userID int64, tag string, limit, offset int) ([]Form, error) {
+	if tag == "" {
+		return queryForms(ctx, db,
+			"SELECT id, user_id, name, domain, turnstile_secret, turnstile_site_key, forward_email, form_key, akismet_enabled, thread_by_sender, turnstile_fallback, spam_flag_threshold, spam_reject_threshold, allowed_countries, blocked_countries, email_attachment, hosted_page, allow_cc, created_at, updated_at FROM forms WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?",
+			userID, limit, offset,
+		)
+	}
+	return queryForms(ctx, db,
+		`SELECT f.id, f.user_id, f.name, f.domain, f.turnstile_secret, f.turnstile_site_key, f.forward_email, f.form_key, f.akismet_enabled, f.thread_by_sender, f.turnstile_fallback, f.spam_flag_threshold, f.spam_reject_threshold, f.allowed_countries, f.blocked_countries, f.email_attachment, f.hosted_page, f.allow_cc, f.created_at, f.updated_at
+		FROM forms f
+		JOIN form_tags ft ON ft.form_id = f.id
+		JOIN tags t ON t.id = ft.tag_id
+		WHERE f.user_id = ? AND t.name = ?
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT ? OFFSET ?`,
+		userID, tag, limit, offset,
+	)
+}
+
+// GetFormsPageByUserID is like GetFormsPageByUserIDContext but uses context.Background
+func GetFormsPageByUserID(db *sql.DB, userID int64, tag string, limit, offset int) ([]Form, error) {
+	return GetFormsPageByUserIDContext(context.Background(), db, userID, tag, limit, offset)
+}
+
+// GetFormCountByUserIDContext counts a user's forms
+func GetFormCountByUserIDContext(ctx context.Context, db *sql.DB, userID int64) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM forms WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+// GetFormCountByUserID is like GetFormCountByUserIDContext but uses context.Background
+func GetFormCountByUserID(db *sql.DB, userID int64) (int, error) {
+	return GetFormCountByUserIDContext(context.Background(), db, userID)
+}
+
+// FormExistsContext checks if a form with the given name already exists for a user
+func FormExistsContext(ctx context.Context, db *sql.DB, userID int64, name string) (bool, error) {
 	var exists bool
-	err := db.QueryRow(
+	err := db.QueryRowContext(ctx,
 		"SELECT EXISTS(SELECT 1 FROM forms WHERE user_id = ? AND name = ?)",
 		userID, name,
 	).Scan(&exists)
@@ -89,13 +298,40 @@ func FormExists(db *sql.DB, userID int64, name string) (bool, error) {
 	return exists, err
 }
 
-// GetFormByKey retrieves a form by its form_key
-func GetFormByKey(db *sql.DB, formKey string) (*Form, error) {
+// FormExists is like FormExistsContext but uses context.Background
+func FormExists(db *sql.DB, userID int64, name string) (bool, error) {
+	return FormExistsContext(context.Background(), db, userID, name)
+}
+
+// GetFormByKeyContext retrieves a form by its form_key
+func GetFormByKeyContext(ctx context.Context, db *sql.DB, formKey string) (*Form, error) {
+	return scanForm(db.QueryRowContext(ctx, getFormByKeyQuery, formKey))
+}
+
+// queryForms runs a query selecting the columns scanForm expects
+func queryForms(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]Form, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var forms []Form
+	for rows.Next() {
+		form, err := scanForm(rows)
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, *form)
+	}
+
+	return forms, rows.Err()
+}
+
+// scanForm reads a form row, returning nil if there isn't one
+func scanForm(row rowScanner) (*Form, error) {
 	var form Form
-	err := db.QueryRow(
-		"SELECT id, user_id, name, domain, turnstile_secret, forward_email, form_key, created_at, updated_at FROM forms WHERE form_key = ?",
-		formKey,
-	).Scan(&form.ID, &form.UserID, &form.Name, &form.Domain, &form.TurnstileSecret, &form.ForwardEmail, &form.FormKey, &form.CreatedAt, &form.UpdatedAt)
+	err := row.Scan(&form.ID, &form.UserID, &form.Name, &form.Domain, &form.TurnstileSecret, &form.TurnstileSiteKey, &form.ForwardEmail, &form.FormKey, &form.AkismetEnabled, &form.ThreadBySender, &form.TurnstileFallback, &form.SpamFlagThreshold, &form.SpamRejectThreshold, &form.AllowedCountries, &form.BlockedCountries, &form.EmailAttachment, &form.HostedPage, &form.AllowCC, &form.CreatedAt, &form.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -103,15 +339,226 @@ func GetFormByKey(db *sql.DB, formKey string) (*Form, error) {
 		}
 		return nil, err
 	}
+	if form.TurnstileSecret, err = secrets.Open(form.TurnstileSecret); err != nil {
+		return nil, fmt.Errorf("form %d: %w", form.ID, err)
+	}
 
 	return &form, nil
 }
 
-// UpdateForm updates a form in the database
-func UpdateForm(db *sql.DB, formID int64, name, domain, turnstileSecret, forwardEmail string) error {
-	_, err := db.Exec(
+// GetFormByKey is like GetFormByKeyContext but uses context.Background
+func GetFormByKey(db *sql.DB, formKey string) (*Form, error) {
+	return GetFormByKeyContext(context.Background(), db, formKey)
+}
+
+// UpdateFormContext updates a form in the database
+func UpdateFormContext(ctx context.Context, db *sql.DB, formID int64, name, domain, turnstileSecret, forwardEmail string) error {
+	sealedSecret, err := secrets.Seal(turnstileSecret)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
 		"UPDATE forms SET name = ?, domain = ?, turnstile_secret = ?, forward_email = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		name, domain, turnstileSecret, forwardEmail, formID,
+		name, domain, sealedSecret, forwardEmail, formID,
+	)
+	return err
+}
+
+// UpdateForm is like UpdateFormContext but uses context.Background
+func UpdateForm(db *sql.DB, formID int64, name, domain, turnstileSecret, forwardEmail string) error {
+	return UpdateFormContext(context.Background(), db, formID, name, domain, turnstileSecret, forwardEmail)
+}
+
+// SetFormAkismetContext turns checking a form's submissions with Akismet
+// on or off
+func SetFormAkismetContext(ctx context.Context, db *sql.DB, formID int64, enabled bool) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET akismet_enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		enabled, formID,
+	)
+	return err
+}
+
+// SetFormAkismet is like SetFormAkismetContext but uses context.Background
+func SetFormAkismet(db *sql.DB, formID int64, enabled bool) error {
+	return SetFormAkismetContext(context.Background(), db, formID, enabled)
+}
+
+// SetFormThreadingContext sets whether a form's notification emails are
+// threaded by the submitter's email address
+func SetFormThreadingContext(ctx context.Context, db *sql.DB, formID int64, bySubmitter bool) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET thread_by_sender = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		bySubmitter, formID,
+	)
+	return err
+}
+
+// SetFormThreading is like SetFormThreadingContext but uses context.Background
+func SetFormThreading(db *sql.DB, formID int64, bySubmitter bool) error {
+	return SetFormThreadingContext(context.Background(), db, formID, bySubmitter)
+}
+
+// SetFormTurnstileSiteKeyContext sets the Turnstile site key that goes with
+// a form's secret
+func SetFormTurnstileSiteKeyContext(ctx context.Context, db *sql.DB, formID int64, siteKey string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET turnstile_site_key = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		siteKey, formID,
+	)
+	return err
+}
+
+// SetFormTurnstileSiteKey is like SetFormTurnstileSiteKeyContext but uses
+// context.Background
+func SetFormTurnstileSiteKey(db *sql.DB, formID int64, siteKey string) error {
+	return SetFormTurnstileSiteKeyContext(context.Background(), db, formID, siteKey)
+}
+
+// SetFormSpamThresholdsContext sets the spam scores at which a form's
+// submissions are held as spam and refused, from 0 to 100 with 0 turning a
+// threshold off
+func SetFormSpamThresholdsContext(ctx context.Context, db *sql.DB, formID int64, flagAt, rejectAt int) error {
+	if flagAt < 0 || flagAt > 100 || rejectAt < 0 || rejectAt > 100 {
+		return fmt.Errorf("spam thresholds must be from 0 to 100")
+	}
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET spam_flag_threshold = ?, spam_reject_threshold = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		flagAt, rejectAt, formID,
+	)
+	return err
+}
+
+// SetFormSpamThresholds is like SetFormSpamThresholdsContext but uses
+// context.Background
+func SetFormSpamThresholds(db *sql.DB, formID int64, flagAt, rejectAt int) error {
+	return SetFormSpamThresholdsContext(context.Background(), db, formID, flagAt, rejectAt)
+}
+
+// SetFormTurnstileFallbackContext sets what happens to a form's submissions
+// while Turnstile can't be reached
+func SetFormTurnstileFallbackContext(ctx context.Context, db *sql.DB, formID int64, fallback string) error {
+	if !ValidTurnstileFallback(fallback) {
+		return fmt.Errorf("unknown Turnstile fallback %q", fallback)
+	}
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET turnstile_fallback = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		fallback, formID,
+	)
+	return err
+}
+
+// SetFormTurnstileFallback is like SetFormTurnstileFallbackContext but uses
+// context.Background
+func SetFormTurnstileFallback(db *sql.DB, formID int64, fallback string) error {
+	return SetFormTurnstileFallbackContext(context.Background(), db, formID, fallback)
+}
+
+// SetFormEmailAttachmentContext sets the file a form's notification emails
+// attach the submission as
+func SetFormEmailAttachmentContext(ctx context.Context, db *sql.DB, formID int64, attachment string) error {
+	if !ValidEmailAttachment(attachment) {
+		return fmt.Errorf("unknown email attachment %q", attachment)
+	}
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET email_attachment = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		attachment, formID,
+	)
+	return err
+}
+
+// SetFormEmailAttachment is like SetFormEmailAttachmentContext but uses
+// context.Background
+func SetFormEmailAttachment(db *sql.DB, formID int64, attachment string) error {
+	return SetFormEmailAttachmentContext(context.Background(), db, formID, attachment)
+}
+
+// SetFormHostedPageContext turns a form's hosted page on or off
+func SetFormHostedPageContext(ctx context.Context, db *sql.DB, formID int64, enabled bool) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET hosted_page = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		enabled, formID,
+	)
+	return err
+}
+
+// SetFormHostedPage is like SetFormHostedPageContext but uses context.Background
+func SetFormHostedPage(db *sql.DB, formID int64, enabled bool) error {
+	return SetFormHostedPageContext(context.Background(), db, formID, enabled)
+}
+
+// SetFormAllowCCContext sets whether a form's emails are copied to the
+// addresses submissions give
+func SetFormAllowCCContext(ctx context.Context, db *sql.DB, formID int64, allowed bool) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE forms SET allow_cc = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		allowed, formID,
 	)
 	return err
-}
\ No newline at end of file
+}
+
+// SetFormAllowCC is like SetFormAllowCCContext but uses context.Background
+func SetFormAllowCC(db *sql.DB, formID int64, allowed bool) error {
+	return SetFormAllowCCContext(context.Background(), db, formID, allowed)
+}
+
+// DeleteFormContext deletes a form along with its submissions, their email
+// records, notes and assignments and the form's IP rules, blocked attempts,
+// tags, column preferences, status page, feed, exports, notification
+// channels and mutes, routing rules and Google Sheet, in one transaction so
+// a failure never leaves orphaned rows behind
+func DeleteFormContext(ctx context.Context, db *sql.DB, formID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Tags no other form uses go with the form
+	tagIDs, err := formTagIDs(ctx, tx, formID)
+	if err != nil {
+		return err
+	}
+
+	// Children are deleted before their parents rather than relying on
+	// ON DELETE CASCADE, which SQLite only honours with foreign keys enabled
+	statements := []string{
+		"DELETE FROM submission_emails WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ?)",
+		"DELETE FROM submission_notes WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ?)",
+		"DELETE FROM submission_assignments WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ?)",
+		"DELETE FROM submission_files WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ?)",
+		"DELETE FROM notification_deliveries WHERE channel_id IN (SELECT id FROM notification_channels WHERE form_id = ?)",
+		"DELETE FROM notification_deliveries WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ?)",
+		"DELETE FROM submissions WHERE form_id = ?",
+		"DELETE FROM blocked_attempts WHERE form_id = ?",
+		"DELETE FROM ip_rules WHERE form_id = ?",
+		"DELETE FROM submission_archives WHERE form_id = ?",
+		"DELETE FROM form_tags WHERE form_id = ?",
+		"DELETE FROM submission_columns WHERE form_id = ?",
+		"DELETE FROM form_status_pages WHERE form_id = ?",
+		"DELETE FROM form_feeds WHERE form_id = ?",
+		"DELETE FROM export_jobs WHERE form_id = ?",
+		"DELETE FROM notification_channels WHERE form_id = ?",
+		"DELETE FROM form_sheets WHERE form_id = ?",
+		"DELETE FROM form_fields WHERE form_id = ?",
+		"DELETE FROM form_field_totals WHERE form_id = ?",
+		"DELETE FROM notification_mutes WHERE form_id = ?",
+		"DELETE FROM form_routes WHERE form_id = ?",
+		"DELETE FROM forms WHERE id = ?",
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, formID); err != nil {
+			return err
+		}
+	}
+	if err := deleteUnusedTags(ctx, tx, tagIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteForm is like DeleteFormContext but uses context.Background
+func DeleteForm(db *sql.DB, formID int64) error {
+	return DeleteFormContext(context.Background(), db, formID)
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"staticsend/pkg/secrets"
+)
+
+// FormBundle is a form with its settings, tags, IP rules, routing rules and
+// submissions, for moving it to another staticSend instance
+type FormBundle struct {
+	Form        Form                `json:"form"`
+	Tags        []string            `json:"tags"`
+	IPRules     []IPRule            `json:"ip_rules"`
+	Routes      []FormRoute         `json:"routes"`
+	Submissions []BundledSubmission `json:"submissions"`
+}
+
+// BundledSubmission is a submission with the records of its emails
+type BundledSubmission struct {
+	Submission
+	Emails []SubmissionEmail `json:"emails"`
+}
+
+// GetFormBundleContext gathers everything about a form for moving it,
+// returning nil if there's no such form. Submissions are oldest first.
+func GetFormBundleContext(ctx context.Context, db *sql.DB, formID int64) (*FormBundle, error) {
+	form, err := GetFormByIDContext(ctx, db, formID)
+	if err != nil || form == nil {
+		return nil, err
+	}
+	bundle := &FormBundle{Form: *form}
+	if bundle.Tags, err = GetFormTagsContext(ctx, db, formID); err != nil {
+		return nil, err
+	}
+	if bundle.IPRules, err = GetIPRulesByFormIDContext(ctx, db, formID); err != nil {
+		return nil, err
+	}
+	if bundle.Routes, err = GetFormRoutesContext(ctx, db, formID); err != nil {
+		return nil, err
+	}
+
+	submissions, err := querySubmissions(ctx, db,
+		"SELECT id, form_id, ip_address, user_agent, referrer, submitted_data, created_at, processed_at, status, spam_at, spam_reason, spam_score, spam_signals, country FROM submissions WHERE form_id = ? ORDER BY created_at, id",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	emails, err := submissionEmailsByFormID(ctx, db, formID)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Submissions = make([]BundledSubmission, len(submissions))
+	for i, submission := range submissions {
+		bundle.Submissions[i] = BundledSubmission{Submission: submission, Emails: emails[submission.ID]}
+	}
+	return bundle, nil
+}
+
+// GetFormBundle is like GetFormBundleContext but uses context.Background
+func GetFormBundle(db *sql.DB, formID int64) (*FormBundle, error) {
+	return GetFormBundleContext(context.Background(), db, formID)
+}
+
+// submissionEmailsByFormID returns the email records of a form's
+// submissions by submission ID
+func submissionEmailsByFormID(ctx context.Context, db *sql.DB, formID int64) (map[int64][]SubmissionEmail, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT e.id, e.submission_id, e.sent_at, e.status, COALESCE(e.error_message, '') FROM submission_emails e JOIN submissions s ON s.id = e.submission_id WHERE s.form_id = ? ORDER BY e.id",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := map[int64][]SubmissionEmail{}
+	for rows.Next() {
+		var email SubmissionEmail
+		if err := rows.Scan(&email.ID, &email.SubmissionID, &email.SentAt, &email.Status, &email.ErrorMessage); err != nil {
+			return nil, err
+		}
+		emails[email.SubmissionID] = append(emails[email.SubmissionID], email)
+	}
+	return emails, rows.Err()
+}
+
+// CreateFormFromBundleContext creates a form for userID from one moved from
+// another instance, with its IP rules, routing rules, submissions and their
+// email records, in one transaction. The bundle's IDs are ignored; the form
+// gets name and formKey. Its field catalog is built from the submissions.
+// Tags are set separately with SetFormTagsContext.
+func CreateFormFromBundleContext(ctx context.Context, db *sql.DB, userID int64, name, formKey string, bundle *FormBundle) (*Form, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	form := bundle.Form
+	sealedSecret, err := secrets.Seal(form.TurnstileSecret)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO forms (user_id, name, domain, turnstile_secret, turnstile_site_key, forward_email, form_key, akismet_enabled, thread_by_sender, turnstile_fallback, spam_flag_threshold, spam_reject_threshold, allowed_countries, blocked_countries, email_attachment, hosted_page, allow_cc, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		userID, name, form.Domain, sealedSecret, form.TurnstileSiteKey, form.ForwardEmail, formKey, form.AkismetEnabled, form.ThreadBySender, form.TurnstileFallback, form.SpamFlagThreshold, form.SpamRejectThreshold, form.AllowedCountries, form.BlockedCountries, form.EmailAttachment, form.HostedPage, form.AllowCC, sqlTime(form.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+	formID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range bundle.IPRules {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO ip_rules (form_id, cidr, action, note) VALUES (?, ?, ?, ?)",
+			formID, rule.CIDR, rule.Action, rule.Note,
+		); err != nil {
+			return nil, err
+		}
+	}
+	for _, route := range bundle.Routes {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO form_routes (form_id, position, field, operator, value, recipient) VALUES (?, ?, ?, ?, ?, ?)",
+			formID, route.Position, route.Field, route.Operator, route.Value, route.Recipient,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	insertSubmission, err := tx.PrepareContext(ctx,
+		"INSERT INTO submissions (form_id, ip_address, user_agent, referrer, submitted_data, created_at, processed_at, status, spam_at, spam_reason, spam_score, spam_signals, country) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer insertSubmission.Close()
+	insertEmail, err := tx.PrepareContext(ctx,
+		"INSERT INTO submission_emails (submission_id, sent_at, status, error_message) VALUES (?, ?, ?, ?)",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer insertEmail.Close()
+
+	for _, submission := range bundle.Submissions {
+		var spamScore, spamSignals interface{}
+		if submission.SpamScore != nil {
+			spamScore = *submission.SpamScore
+		}
+		if len(submission.SpamSignals) > 0 {
+			spamSignals = string(submission.SpamSignals)
+		}
+		result, err := insertSubmission.ExecContext(ctx,
+			formID, submission.IPAddress, submission.UserAgent, submission.Referrer, string(submission.SubmittedData),
+			sqlTime(submission.CreatedAt), nullSQLTime(submission.ProcessedAt), submission.Status,
+			nullSQLTime(submission.SpamAt), submission.SpamReason, spamScore, spamSignals, submission.Country,
+		)
+		if err != nil {
+			return nil, err
+		}
+		submissionID, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		for _, email := range submission.Emails {
+			if _, err := insertEmail.ExecContext(ctx, submissionID, sqlTime(email.SentAt), email.Status, email.ErrorMessage); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := rebuildFormFields(ctx, tx, formID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetFormByIDContext(ctx, db, formID)
+}
+
+// CreateFormFromBundle is like CreateFormFromBundleContext but uses context.Background
+func CreateFormFromBundle(db *sql.DB, userID int64, name, formKey string, bundle *FormBundle) (*Form, error) {
+	return CreateFormFromBundleContext(context.Background(), db, userID, name, formKey, bundle)
+}
+
+// nullSQLTime formats an optional time for a timestamp column
+func nullSQLTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqlTime(*t)
+}
@@ -0,0 +1,428 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Types inferred from the values of a form's fields
+const (
+	FieldTypeText     = "text"
+	FieldTypeLongText = "long_text"
+	FieldTypeEmail    = "email"
+	FieldTypeURL      = "url"
+	FieldTypePhone    = "phone"
+	FieldTypeNumber   = "number"
+	FieldTypeDate     = "date"
+	FieldTypeCheckbox = "checkbox"
+)
+
+const (
+	// longTextLength is the length above which text is long, even on one line
+	longTextLength = 200
+	// maxFieldNameLength caps the field names cataloged
+	maxFieldNameLength = 255
+	// catalogBatchSize caps the submissions held in memory while rebuilding
+	catalogBatchSize = 1000
+)
+
+// phonePattern matches phone numbers written with digits, spaces and the
+// usual punctuation
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ().-]{7,20}$`)
+
+// FormField is a field a form has received, from its catalog
+type FormField struct {
+	Name string `json:"name"`
+	// SubmissionCount is how many submissions had the field filled in
+	SubmissionCount int `json:"submission_count"`
+	// Types counts the submissions whose value was inferred to be each type
+	Types map[string]int `json:"types"`
+	// MaxLength is the length of the longest value, in characters
+	MaxLength   int       `json:"max_length"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// Type is the type most of the field's values were, or text when no type
+// accounts for nine in ten of them. Long text wins over text, so a field
+// with some long answers is a text area.
+func (f FormField) Type() string {
+	best, bestCount := FieldTypeText, 0
+	for fieldType, count := range f.Types {
+		if count > bestCount || (count == bestCount && fieldType < best) {
+			best, bestCount = fieldType, count
+		}
+	}
+	if f.Types[FieldTypeLongText] > 0 && (best == FieldTypeText || best == FieldTypeLongText) {
+		return FieldTypeLongText
+	}
+	if bestCount*10 < f.SubmissionCount*9 {
+		return FieldTypeText
+	}
+	return best
+}
+
+// FieldCatalog is the fields a form has received, and how many submissions
+// they were cataloged from
+type FieldCatalog struct {
+	Submissions int
+	// Fields are in the order they were first seen, then by name
+	Fields []FormField
+}
+
+// Names returns the catalog's field names in order
+func (c *FieldCatalog) Names() []string {
+	names := make([]string, len(c.Fields))
+	for i, field := range c.Fields {
+		names[i] = field.Name
+	}
+	return names
+}
+
+// InferFieldType guesses the type of a submitted value
+func InferFieldType(value string) string {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	switch {
+	case strings.Contains(value, "\n") || utf8.RuneCountInString(value) > longTextLength:
+		return FieldTypeLongText
+	case lower == "on" || lower == "true" || lower == "false" || lower == "yes" || lower == "no":
+		return FieldTypeCheckbox
+	case isPhone(value):
+		return FieldTypePhone
+	case isNumber(value):
+		return FieldTypeNumber
+	case isEmail(value):
+		return FieldTypeEmail
+	case isURL(value):
+		return FieldTypeURL
+	case isDate(value):
+		return FieldTypeDate
+	}
+	return FieldTypeText
+}
+
+// isNumber reports whether a value is a plain decimal number. Long runs of
+// digits, such as phone numbers, aren't.
+func isNumber(value string) bool {
+	if len(value) > 15 {
+		return false
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil && !strings.ContainsAny(value, "eEnN")
+}
+
+// isEmail reports whether a value is a bare email address
+func isEmail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+// isURL reports whether a value is an absolute http(s) URL
+func isURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isDate reports whether a value is a date as date inputs send them
+func isDate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+// isPhone reports whether a value looks like a phone number: seven or more
+// digits, with punctuation or a leading + or 0 to tell it from a number
+func isPhone(value string) bool {
+	if !phonePattern.MatchString(value) || isDate(value) {
+		return false
+	}
+	digits := 0
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 7 && (digits < len(value) || value[0] == '0')
+}
+
+// RecordFormFieldsContext adds a submission's fields to its form's catalog.
+// Empty values and names longer than the catalog keeps are skipped. A form
+// without a catalog, such as a new one, has it built from its submissions
+// instead, which the submission must already be saved among.
+func RecordFormFieldsContext(ctx context.Context, db *sql.DB, formID int64, submittedData json.RawMessage, at time.Time) error {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM form_field_totals WHERE form_id = ?", formID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		_, err := RebuildFormFieldsContext(ctx, db, formID)
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordFormFields(ctx, tx, formID, submittedData, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordFormFields is like RecordFormFieldsContext but uses context.Background
+func RecordFormFields(db *sql.DB, formID int64, submittedData json.RawMessage, at time.Time) error {
+	return RecordFormFieldsContext(context.Background(), db, formID, submittedData, at)
+}
+
+// recordFormFields adds a submission's fields to its form's catalog in tx.
+// Each row is upserted in one statement, so concurrent submissions adding
+// the same field count it rather than racing to insert it; MySQL's
+// connections rewrite ON CONFLICT into its own syntax.
+func recordFormFields(ctx context.Context, tx *sql.Tx, formID int64, submittedData json.RawMessage, at time.Time) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(submittedData, &fields); err != nil {
+		// Data that isn't a JSON object has no fields to catalog
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO form_field_totals (form_id, submission_count) VALUES (?, 1) ON CONFLICT (form_id) DO UPDATE SET submission_count = form_field_totals.submission_count + 1",
+		formID,
+	); err != nil {
+		return err
+	}
+
+	seenAt := sqlTime(at)
+	for name, value := range fields {
+		text := fieldText(value)
+		if name == "" || text == "" || len(name) > maxFieldNameLength {
+			continue
+		}
+		fieldType := InferFieldType(text)
+		length := utf8.RuneCountInString(text)
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO form_fields (form_id, name, type, submission_count, max_length, first_seen_at, last_seen_at) VALUES (?, ?, ?, 1, ?, ?, ?) ON CONFLICT (form_id, name, type) DO UPDATE SET submission_count = form_fields.submission_count + 1, max_length = CASE WHEN form_fields.max_length < excluded.max_length THEN excluded.max_length ELSE form_fields.max_length END, first_seen_at = CASE WHEN form_fields.first_seen_at > excluded.first_seen_at THEN excluded.first_seen_at ELSE form_fields.first_seen_at END, last_seen_at = CASE WHEN form_fields.last_seen_at < excluded.last_seen_at THEN excluded.last_seen_at ELSE form_fields.last_seen_at END",
+			formID, name, fieldType, length, seenAt, seenAt,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldText returns a submitted value as text, joining lists with commas
+func fieldText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if text := fieldText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// RebuildFormFieldsContext rebuilds a form's catalog from its submissions,
+// except spam, in one transaction, returning how many it read
+func RebuildFormFieldsContext(ctx context.Context, db *sql.DB, formID int64) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	count, err := rebuildFormFields(ctx, tx, formID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// RebuildFormFields is like RebuildFormFieldsContext but uses context.Background
+func RebuildFormFields(db *sql.DB, formID int64) (int, error) {
+	return RebuildFormFieldsContext(context.Background(), db, formID)
+}
+
+// rebuildFormFields rebuilds a form's catalog from its submissions in tx,
+// returning how many it read
+func rebuildFormFields(ctx context.Context, tx *sql.Tx, formID int64) (int, error) {
+	for _, table := range []string{"form_fields", "form_field_totals"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE form_id = ?", formID); err != nil {
+			return 0, err
+		}
+	}
+	// A form without submissions gets an empty catalog, so it isn't
+	// rebuilt again
+	if _, err := tx.ExecContext(ctx, "INSERT INTO form_field_totals (form_id, submission_count) VALUES (?, 0)", formID); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var afterID int64
+	for {
+		submissions, err := submissionsForCatalog(ctx, tx, formID, afterID)
+		if err != nil {
+			return 0, err
+		}
+		if len(submissions) == 0 {
+			return count, nil
+		}
+		for _, submission := range submissions {
+			if err := recordFormFields(ctx, tx, formID, submission.SubmittedData, submission.CreatedAt); err != nil {
+				return 0, err
+			}
+		}
+		count += len(submissions)
+		afterID = submissions[len(submissions)-1].ID
+	}
+}
+
+// submissionsForCatalog reads the next batch of a form's submissions,
+// except spam, after afterID in ID order. Only the columns the catalog
+// needs are read.
+func submissionsForCatalog(ctx context.Context, tx *sql.Tx, formID, afterID int64) ([]Submission, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, submitted_data, created_at FROM submissions WHERE form_id = ? AND id > ? AND spam_at IS NULL ORDER BY id LIMIT ?",
+		formID, afterID, catalogBatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var submissions []Submission
+	for rows.Next() {
+		var submission Submission
+		var submittedData string
+		if err := rows.Scan(&submission.ID, &submittedData, &submission.CreatedAt); err != nil {
+			return nil, err
+		}
+		submission.SubmittedData = json.RawMessage(submittedData)
+		submissions = append(submissions, submission)
+	}
+	return submissions, rows.Err()
+}
+
+// BackfillFormFieldsContext builds the catalogs of forms that don't have
+// one, such as those created before there were catalogs, returning how
+// many forms it built catalogs for
+func BackfillFormFieldsContext(ctx context.Context, db *sql.DB) (int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id FROM forms WHERE id NOT IN (SELECT form_id FROM form_field_totals) ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+	var formIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		formIDs = append(formIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, formID := range formIDs {
+		if _, err := RebuildFormFieldsContext(ctx, db, formID); err != nil {
+			return i, err
+		}
+	}
+	return len(formIDs), nil
+}
+
+// BackfillFormFields is like BackfillFormFieldsContext but uses context.Background
+func BackfillFormFields(db *sql.DB) (int, error) {
+	return BackfillFormFieldsContext(context.Background(), db)
+}
+
+// GetFieldCatalogContext returns the fields a form has received, in the
+// order they were first seen. A form whose catalog hasn't been built has
+// no fields.
+func GetFieldCatalogContext(ctx context.Context, db *sql.DB, formID int64) (*FieldCatalog, error) {
+	catalog := &FieldCatalog{}
+	err := db.QueryRowContext(ctx, "SELECT submission_count FROM form_field_totals WHERE form_id = ?", formID).Scan(&catalog.Submissions)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT name, type, submission_count, max_length, first_seen_at, last_seen_at FROM form_fields WHERE form_id = ?",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := map[string]*FormField{}
+	for rows.Next() {
+		var name, fieldType string
+		var count, maxLength int
+		var firstSeenAt, lastSeenAt time.Time
+		if err := rows.Scan(&name, &fieldType, &count, &maxLength, &firstSeenAt, &lastSeenAt); err != nil {
+			return nil, err
+		}
+		field, ok := fields[name]
+		if !ok {
+			field = &FormField{Name: name, Types: map[string]int{}, FirstSeenAt: firstSeenAt, LastSeenAt: lastSeenAt}
+			fields[name] = field
+		}
+		field.SubmissionCount += count
+		field.Types[fieldType] += count
+		field.MaxLength = max(field.MaxLength, maxLength)
+		if firstSeenAt.Before(field.FirstSeenAt) {
+			field.FirstSeenAt = firstSeenAt
+		}
+		if lastSeenAt.After(field.LastSeenAt) {
+			field.LastSeenAt = lastSeenAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	catalog.Fields = make([]FormField, 0, len(fields))
+	for _, field := range fields {
+		catalog.Fields = append(catalog.Fields, *field)
+	}
+	sort.Slice(catalog.Fields, func(i, j int) bool {
+		a, b := catalog.Fields[i], catalog.Fields[j]
+		if !a.FirstSeenAt.Equal(b.FirstSeenAt) {
+			return a.FirstSeenAt.Before(b.FirstSeenAt)
+		}
+		return a.Name < b.Name
+	})
+	return catalog, nil
+}
+
+// GetFieldCatalog is like GetFieldCatalogContext but uses context.Background
+func GetFieldCatalog(db *sql.DB, formID int64) (*FieldCatalog, error) {
+	return GetFieldCatalogContext(context.Background(), db, formID)
+}
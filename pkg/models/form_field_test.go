@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInferFieldType(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Jo Smith", FieldTypeText},
+		{"First line\nsecond line", FieldTypeLongText},
+		{"jo@example.com", FieldTypeEmail},
+		{"Jo <jo@example.com>", FieldTypeText},
+		{"https://example.com/about", FieldTypeURL},
+		{"example.com", FieldTypeText},
+		{"+61 2 9876 5432", FieldTypePhone},
+		{"(02) 9876-5432", FieldTypePhone},
+		{"0298765432", FieldTypePhone},
+		{"42", FieldTypeNumber},
+		{"3.5", FieldTypeNumber},
+		{"2026-10-17", FieldTypeDate},
+		{"on", FieldTypeCheckbox},
+		{"Yes", FieldTypeCheckbox},
+	}
+	for _, tt := range tests {
+		if got := InferFieldType(tt.value); got != tt.want {
+			t.Errorf("InferFieldType(%q) = %q; want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormFieldType(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FormField
+		want  string
+	}{
+		{"all one type", FormField{SubmissionCount: 10, Types: map[string]int{FieldTypeEmail: 10}}, FieldTypeEmail},
+		{"mostly one type", FormField{SubmissionCount: 10, Types: map[string]int{FieldTypeEmail: 9, FieldTypeText: 1}}, FieldTypeEmail},
+		{"mixed", FormField{SubmissionCount: 10, Types: map[string]int{FieldTypeNumber: 8, FieldTypeText: 2}}, FieldTypeText},
+		{"some long text", FormField{SubmissionCount: 10, Types: map[string]int{FieldTypeText: 9, FieldTypeLongText: 1}}, FieldTypeLongText},
+	}
+	for _, tt := range tests {
+		if got := tt.field.Type(); got != tt.want {
+			t.Errorf("%s: Type() = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordFormFields(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "user@example.com", "hashed_password")
+	form, _ := CreateForm(db, user.ID, "Contact", "example.com", "secret", "to@example.com", "fields_record")
+
+	// A form without a catalog has it built from its submissions, which
+	// already hold the one being recorded
+	data := json.RawMessage(`{"name":"Jo","email":"jo@example.com"}`)
+	first, _ := CreateSubmission(db, form.ID, "203.0.113.1", "Browser", data)
+	start := first.CreatedAt
+	if err := RecordFormFields(db, form.ID, data, start); err != nil {
+		t.Fatalf("Failed to record fields: %v", err)
+	}
+	catalog, err := GetFieldCatalog(db, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to get catalog: %v", err)
+	}
+	if catalog.Submissions != 1 || len(catalog.Fields) != 2 {
+		t.Fatalf("Expected the first submission's two fields, got %+v", catalog)
+	}
+
+	for i, data := range []string{
+		`{"name":"Sam","email":"sam@example.com","message":"Hello"}`,
+		`{"name":"Alex Longname","email":"","message":"Hi\nthere","tags":["a","b"]}`,
+	} {
+		if err := RecordFormFields(db, form.ID, json.RawMessage(data), start.Add(time.Duration(i+1)*time.Hour)); err != nil {
+			t.Fatalf("Failed to record fields: %v", err)
+		}
+	}
+
+	catalog, err = GetFieldCatalog(db, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to get catalog: %v", err)
+	}
+	if catalog.Submissions != 3 {
+		t.Errorf("Expected 3 submissions cataloged, got %d", catalog.Submissions)
+	}
+	// The first submission's fields were seen at the same time, so they're
+	// in name order
+	if want := []string{"email", "name", "message", "tags"}; !reflect.DeepEqual(catalog.Names(), want) {
+		t.Fatalf("Expected fields %v, got %v", want, catalog.Names())
+	}
+	email, name, message := catalog.Fields[0], catalog.Fields[1], catalog.Fields[2]
+	if email.SubmissionCount != 2 || email.Type() != FieldTypeEmail {
+		t.Errorf("Expected the email in 2 submissions, not the empty one, got %+v", email)
+	}
+	if name.SubmissionCount != 3 || name.MaxLength != len("Alex Longname") || !name.LastSeenAt.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Expected the name in every submission, got %+v", name)
+	}
+	if message.Type() != FieldTypeLongText || !message.FirstSeenAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the message to be long text first seen in the second submission, got %+v", message)
+	}
+}
+
+func TestRebuildFormFields(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "user@example.com", "hashed_password")
+	form, _ := CreateForm(db, user.ID, "Contact", "example.com", "secret", "to@example.com", "fields_rebuild")
+	empty, _ := CreateForm(db, user.ID, "Empty", "example.com", "secret", "to@example.com", "fields_empty")
+	CreateSubmission(db, form.ID, "203.0.113.1", "Browser", json.RawMessage(`{"email":"jo@example.com"}`))
+	CreateSubmission(db, form.ID, "203.0.113.2", "Browser", json.RawMessage(`{"email":"sam@example.com","phone":"+61 2 9876 5432"}`))
+	CreateSpamSubmission(db, form.ID, "203.0.113.3", "Bot", "", json.RawMessage(`{"url":"https://spam.example.com"}`), SpamReasonHoneypot)
+
+	built, err := BackfillFormFields(db)
+	if err != nil || built != 2 {
+		t.Fatalf("Expected both forms' catalogs built, got %d (%v)", built, err)
+	}
+	catalog, err := GetFieldCatalog(db, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to get catalog: %v", err)
+	}
+	if catalog.Submissions != 2 || !reflect.DeepEqual(catalog.Names(), []string{"email", "phone"}) {
+		t.Errorf("Expected the fields of the submissions that aren't spam, got %+v", catalog)
+	}
+	if catalog.Fields[1].Type() != FieldTypePhone {
+		t.Errorf("Expected a phone field, got %q", catalog.Fields[1].Type())
+	}
+	if catalog, _ := GetFieldCatalog(db, empty.ID); catalog.Submissions != 0 || len(catalog.Fields) != 0 {
+		t.Errorf("Expected an empty catalog, got %+v", catalog)
+	}
+
+	// Forms with catalogs, even empty ones, aren't built again
+	if built, err := BackfillFormFields(db); err != nil || built != 0 {
+		t.Errorf("Expected no catalogs built, got %d (%v)", built, err)
+	}
+
+	// Rebuilding replaces the catalog rather than adding to it
+	count, err := RebuildFormFields(db, form.ID)
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 submissions read, got %d (%v)", count, err)
+	}
+	if catalog, _ := GetFieldCatalog(db, form.ID); catalog.Submissions != 2 || catalog.Fields[0].SubmissionCount != 2 {
+		t.Errorf("Expected the catalog rebuilt, got %+v", catalog)
+	}
+
+	// Imported submissions are cataloged
+	if err := ImportSubmissions(db, empty.ID, []ImportedSubmission{
+		{SubmittedData: json.RawMessage(`{"company":"Acme"}`), CreatedAt: time.Now()},
+	}); err != nil {
+		t.Fatalf("Failed to import submissions: %v", err)
+	}
+	if catalog, _ := GetFieldCatalog(db, empty.ID); catalog.Submissions != 1 || !reflect.DeepEqual(catalog.Names(), []string{"company"}) {
+		t.Errorf("Expected the imported submission's fields, got %+v", catalog)
+	}
+
+	// Deleting the form deletes its catalog
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	if catalog, _ := GetFieldCatalog(db, form.ID); catalog.Submissions != 0 || len(catalog.Fields) != 0 {
+		t.Errorf("Expected the catalog deleted with the form, got %+v", catalog)
+	}
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Operators a routing rule compares a submission's field with its value by
+const (
+	RouteEquals    = "equals"
+	RouteNotEquals = "not_equals"
+	RouteContains  = "contains"
+)
+
+// RouteOperators lists the operators in the order they're offered
+var RouteOperators = []string{RouteEquals, RouteNotEquals, RouteContains}
+
+// FormRoute is one of a form's routing rules. Submissions whose Field
+// compares with Value by Operator are emailed to Recipient instead of the
+// form's forward address. Rules are tried in Position order and the first
+// that matches wins.
+type FormRoute struct {
+	ID        int64     `json:"id"`
+	FormID    int64     `json:"form_id"`
+	Position  int       `json:"position"`
+	Field     string    `json:"field"`
+	Operator  string    `json:"operator"`
+	Value     string    `json:"value"`
+	Recipient string    `json:"recipient"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidRouteOperator reports whether operator is one of the RouteOperators
+func ValidRouteOperator(operator string) bool {
+	for _, known := range RouteOperators {
+		if operator == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether a submission's fields satisfy the rule. Values
+// are compared ignoring case and surrounding whitespace, and a field that
+// wasn't submitted counts as empty.
+func (r FormRoute) Matches(formData map[string]string) bool {
+	got := strings.ToLower(strings.TrimSpace(formData[r.Field]))
+	want := strings.ToLower(strings.TrimSpace(r.Value))
+	switch r.Operator {
+	case RouteEquals:
+		return got == want
+	case RouteNotEquals:
+		return got != want
+	case RouteContains:
+		return strings.Contains(got, want)
+	}
+	return false
+}
+
+// RouteRecipient returns the recipient of the first rule a submission
+// matches, or fallback when none do
+func RouteRecipient(routes []FormRoute, formData map[string]string, fallback string) string {
+	for _, route := range routes {
+		if route.Matches(formData) {
+			return route.Recipient
+		}
+	}
+	return fallback
+}
+
+// RoutedRecipientContext returns the address a form's submission is emailed
+// to under its routing rules, the forward address when none match
+func RoutedRecipientContext(ctx context.Context, db *sql.DB, form *Form, formData map[string]string) (string, error) {
+	routes, err := GetFormRoutesContext(ctx, db, form.ID)
+	if err != nil {
+		return "", err
+	}
+	return RouteRecipient(routes, formData, form.ForwardEmail), nil
+}
+
+// RoutedRecipient is like RoutedRecipientContext but uses context.Background
+func RoutedRecipient(db *sql.DB, form *Form, formData map[string]string) (string, error) {
+	return RoutedRecipientContext(context.Background(), db, form, formData)
+}
+
+// CreateFormRouteContext adds a routing rule after a form's others
+func CreateFormRouteContext(ctx context.Context, db *sql.DB, formID int64, field, operator, value, recipient string) (*FormRoute, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var last int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(position), 0) FROM form_routes WHERE form_id = ?",
+		formID,
+	).Scan(&last); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO form_routes (form_id, position, field, operator, value, recipient) VALUES (?, ?, ?, ?, ?, ?)",
+		formID, last+1, field, operator, value, recipient,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetFormRouteByIDContext(ctx, db, id)
+}
+
+// CreateFormRoute is like CreateFormRouteContext but uses context.Background
+func CreateFormRoute(db *sql.DB, formID int64, field, operator, value, recipient string) (*FormRoute, error) {
+	return CreateFormRouteContext(context.Background(), db, formID, field, operator, value, recipient)
+}
+
+// GetFormRouteByIDContext retrieves a routing rule by its ID
+func GetFormRouteByIDContext(ctx context.Context, db *sql.DB, id int64) (*FormRoute, error) {
+	route, err := scanFormRoute(db.QueryRowContext(ctx,
+		"SELECT id, form_id, position, field, operator, value, recipient, created_at FROM form_routes WHERE id = ?",
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return route, err
+}
+
+// GetFormRouteByID is like GetFormRouteByIDContext but uses context.Background
+func GetFormRouteByID(db *sql.DB, id int64) (*FormRoute, error) {
+	return GetFormRouteByIDContext(context.Background(), db, id)
+}
+
+// GetFormRoutesContext retrieves a form's routing rules in the order
+// they're tried
+func GetFormRoutesContext(ctx context.Context, db *sql.DB, formID int64) ([]FormRoute, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, form_id, position, field, operator, value, recipient, created_at FROM form_routes WHERE form_id = ? ORDER BY position, id",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []FormRoute
+	for rows.Next() {
+		route, err := scanFormRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, *route)
+	}
+	return routes, rows.Err()
+}
+
+// GetFormRoutes is like GetFormRoutesContext but uses context.Background
+func GetFormRoutes(db *sql.DB, formID int64) ([]FormRoute, error) {
+	return GetFormRoutesContext(context.Background(), db, formID)
+}
+
+// MoveFormRouteContext swaps a routing rule with the one tried before it,
+// or after it when up is false. A rule already first or last stays put.
+func MoveFormRouteContext(ctx context.Context, db *sql.DB, route *FormRoute, up bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT id, position FROM form_routes WHERE form_id = ? AND position > ? ORDER BY position LIMIT 1"
+	if up {
+		query = "SELECT id, position FROM form_routes WHERE form_id = ? AND position < ? ORDER BY position DESC LIMIT 1"
+	}
+	var neighborID int64
+	var neighborPosition int
+	err = tx.QueryRowContext(ctx, query, route.FormID, route.Position).Scan(&neighborID, &neighborPosition)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE form_routes SET position = ? WHERE id = ?", neighborPosition, route.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE form_routes SET position = ? WHERE id = ?", route.Position, neighborID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MoveFormRoute is like MoveFormRouteContext but uses context.Background
+func MoveFormRoute(db *sql.DB, route *FormRoute, up bool) error {
+	return MoveFormRouteContext(context.Background(), db, route, up)
+}
+
+// DeleteFormRouteContext deletes a routing rule
+func DeleteFormRouteContext(ctx context.Context, db *sql.DB, id int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM form_routes WHERE id = ?", id)
+	return err
+}
+
+// DeleteFormRoute is like DeleteFormRouteContext but uses context.Background
+func DeleteFormRoute(db *sql.DB, id int64) error {
+	return DeleteFormRouteContext(context.Background(), db, id)
+}
+
+// scanFormRoute scans a routing rule row
+func scanFormRoute(row rowScanner) (*FormRoute, error) {
+	var route FormRoute
+	if err := row.Scan(&route.ID, &route.FormID, &route.Position, &route.Field, &route.Operator, &route.Value, &route.Recipient, &route.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import "testing"
+
+func TestFormRoutes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "user@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "Contact", "example.com", "secret", "support@example.com")
+
+	sales, err := CreateFormRoute(db, form.ID, "department", RouteEquals, "Sales", "sales@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create route: %v", err)
+	}
+	urgent, _ := CreateFormRoute(db, form.ID, "message", RouteContains, "urgent", "oncall@example.com")
+	if sales.Position != 1 || urgent.Position != 2 {
+		t.Errorf("Expected routes added in order, got positions %d and %d", sales.Position, urgent.Position)
+	}
+
+	tests := []struct {
+		data map[string]string
+		want string
+	}{
+		{map[string]string{"department": " sales ", "message": "Urgent: call me"}, "sales@example.com"},
+		{map[string]string{"department": "billing", "message": "URGENT"}, "oncall@example.com"},
+		{map[string]string{"message": "hello"}, "support@example.com"},
+	}
+	for _, tt := range tests {
+		if got, err := RoutedRecipient(db, form, tt.data); err != nil || got != tt.want {
+			t.Errorf("RoutedRecipient(%v) = %q, %v; want %q", tt.data, got, err, tt.want)
+		}
+	}
+
+	// Moving the urgent rule first lets it win
+	if err := MoveFormRoute(db, urgent, true); err != nil {
+		t.Fatalf("Failed to move route: %v", err)
+	}
+	if got, _ := RoutedRecipient(db, form, tests[0].data); got != "oncall@example.com" {
+		t.Errorf("Expected the moved rule tried first, got %q", got)
+	}
+	routes, _ := GetFormRoutes(db, form.ID)
+	if err := MoveFormRoute(db, &routes[0], true); err != nil {
+		t.Fatalf("Failed to move route: %v", err)
+	}
+	if again, _ := GetFormRoutes(db, form.ID); len(again) != 2 || again[0].ID != urgent.ID {
+		t.Errorf("Expected the first rule to stay first, got %+v", again)
+	}
+
+	if err := DeleteFormRoute(db, urgent.ID); err != nil {
+		t.Fatalf("Failed to delete route: %v", err)
+	}
+	if routes, _ := GetFormRoutes(db, form.ID); len(routes) != 1 || routes[0].ID != sales.ID {
+		t.Errorf("Expected only the sales route left, got %+v", routes)
+	}
+}
+
+func TestFormRoute_Matches(t *testing.T) {
+	notSpam := FormRoute{Field: "topic", Operator: RouteNotEquals, Value: "spam"}
+	if !notSpam.Matches(map[string]string{"topic": "Support"}) || notSpam.Matches(map[string]string{"topic": "SPAM"}) {
+		t.Error("Expected not_equals to match other values only")
+	}
+	if !notSpam.Matches(map[string]string{}) {
+		t.Error("Expected a missing field to count as empty")
+	}
+	if (FormRoute{Field: "topic", Operator: "unknown"}).Matches(map[string]string{}) {
+		t.Error("Expected an unknown operator never to match")
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"testing"
 )
 
@@ -96,6 +98,27 @@ func TestGetFormByID(t *testing.T) {
 	}
 }
 
+func TestGetFormByIDForUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, _ := CreateUser(db, "owner@example.com", "hashed_password")
+	other, _ := CreateUser(db, "other@example.com", "hashed_password")
+	form := CreateTestForm(t, db, owner.ID, "contact", "example.com", "turnstile_secret_456", "admin@example.com")
+
+	found, err := GetFormByIDForUser(db, form.ID, owner.ID)
+	if err != nil || found == nil || found.Name != "contact" {
+		t.Fatalf("Expected the owner to get the form, got %+v, %v", found, err)
+	}
+	// Other users' forms aren't found, just like missing ones
+	if found, err := GetFormByIDForUser(db, form.ID, other.ID); err != nil || found != nil {
+		t.Errorf("Expected nil for another user's form, got %+v, %v", found, err)
+	}
+	if found, err := GetFormByIDForUser(db, 999, owner.ID); err != nil || found != nil {
+		t.Errorf("Expected nil for a missing form, got %+v, %v", found, err)
+	}
+}
+
 func TestGetFormsByUserID(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -198,4 +221,274 @@ func TestFormExists(t *testing.T) {
 	if exists {
 		t.Error("Expected form to not exist for different user")
 	}
-}
\ No newline at end of file
+}
+
+func TestFormContextCancelled(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "user@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form := CreateTestForm(t, db, user.ID, "contact", "example.com", "turnstile_secret_456", "admin@example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := GetFormByIDContext(ctx, db, form.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetFormByIDContext, got %v", err)
+	}
+	if _, err := GetFormsByUserIDContext(ctx, db, user.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetFormsByUserIDContext, got %v", err)
+	}
+	if err := UpdateFormContext(ctx, db, form.ID, "renamed", "example.com", "secret", "admin@example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from UpdateFormContext, got %v", err)
+	}
+}
+
+func TestDeleteForm(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "user@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form := CreateTestForm(t, db, user.ID, "contact", "example.com", "turnstile_secret_456", "admin@example.com")
+	other := CreateTestForm(t, db, user.ID, "newsletter", "example.com", "turnstile_secret_789", "admin@example.com")
+
+	for _, f := range []*Form{form, other} {
+		submission, err := CreateSubmission(db, f.ID, "127.0.0.1", "test", []byte(`{"a":"b"}`))
+		if err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+		if _, err := CreateSubmissionEmail(db, submission.ID, "sent", ""); err != nil {
+			t.Fatalf("Failed to create submission email: %v", err)
+		}
+		rule, err := CreateIPRule(db, &f.ID, "10.0.0.0/8", "deny", "")
+		if err != nil {
+			t.Fatalf("Failed to create IP rule: %v", err)
+		}
+		if err := CreateBlockedAttempt(db, &f.ID, &rule.ID, "10.0.0.1", "ip_rule"); err != nil {
+			t.Fatalf("Failed to create blocked attempt: %v", err)
+		}
+		if err := MuteRecipient(db, f.ID, "admin@example.com"); err != nil {
+			t.Fatalf("Failed to mute recipient: %v", err)
+		}
+		if _, err := CreateFormRoute(db, f.ID, "department", RouteEquals, "sales", "sales@example.com"); err != nil {
+			t.Fatalf("Failed to create route: %v", err)
+		}
+	}
+
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+
+	count := func(query string, formID int64) int {
+		var n int
+		if err := db.QueryRow(query, formID).Scan(&n); err != nil {
+			t.Fatalf("Failed to count rows: %v", err)
+		}
+		return n
+	}
+	queries := []string{
+		"SELECT COUNT(*) FROM forms WHERE id = ?",
+		"SELECT COUNT(*) FROM submissions WHERE form_id = ?",
+		"SELECT COUNT(*) FROM submission_emails WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ?)",
+		"SELECT COUNT(*) FROM ip_rules WHERE form_id = ?",
+		"SELECT COUNT(*) FROM blocked_attempts WHERE form_id = ?",
+		"SELECT COUNT(*) FROM notification_mutes WHERE form_id = ?",
+		"SELECT COUNT(*) FROM form_routes WHERE form_id = ?",
+	}
+	for _, query := range queries {
+		if n := count(query, form.ID); n != 0 {
+			t.Errorf("Expected no rows left for deleted form, got %d: %s", n, query)
+		}
+		if n := count(query, other.ID); n != 1 {
+			t.Errorf("Expected other form's row to be kept, got %d: %s", n, query)
+		}
+	}
+	var orphans int
+	if err := db.QueryRow("SELECT COUNT(*) FROM submission_emails WHERE submission_id NOT IN (SELECT id FROM submissions)").Scan(&orphans); err != nil {
+		t.Fatalf("Failed to count orphaned emails: %v", err)
+	}
+	if orphans != 0 {
+		t.Errorf("Expected no orphaned submission emails, got %d", orphans)
+	}
+
+	// Deleting a form that no longer exists is not an error
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Errorf("Expected deleting a missing form to succeed, got %v", err)
+	}
+}
+
+func TestGetFormsPageByUserID(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "pages@example.com", "hashed_password")
+	var forms []*Form
+	for _, name := range []string{"first", "second", "third"} {
+		forms = append(forms, CreateTestForm(t, db, user.ID, name, "example.com", "secret", "to@example.com"))
+	}
+	if err := SetFormTags(db, user.ID, forms[0].ID, []string{"client"}); err != nil {
+		t.Fatalf("Failed to set tags: %v", err)
+	}
+
+	page, err := GetFormsPageByUserID(db, user.ID, "", 2, 0)
+	if err != nil {
+		t.Fatalf("Failed to get forms: %v", err)
+	}
+	if len(page) != 2 || page[0].Name != "third" || page[1].Name != "second" {
+		t.Errorf("Expected the two newest forms, got %+v", page)
+	}
+
+	page, err = GetFormsPageByUserID(db, user.ID, "", 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to get forms: %v", err)
+	}
+	if len(page) != 1 || page[0].Name != "first" {
+		t.Errorf("Expected the oldest form on the second page, got %+v", page)
+	}
+
+	page, err = GetFormsPageByUserID(db, user.ID, "client", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get forms: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != forms[0].ID {
+		t.Errorf("Expected only the tagged form, got %+v", page)
+	}
+
+	count, err := GetFormCountByUserID(db, user.ID)
+	if err != nil || count != 3 {
+		t.Errorf("Expected 3 forms, got %d (%v)", count, err)
+	}
+}
+
+func TestDuplicateForm(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "duplicate@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "Contact", "example.com", "secret", "to@example.com")
+	if err := SetFormTags(db, user.ID, form.ID, []string{"client-a"}); err != nil {
+		t.Fatalf("Failed to set tags: %v", err)
+	}
+	if _, err := CreateIPRule(db, &form.ID, "203.0.113.0/24", "deny", "spammer"); err != nil {
+		t.Fatalf("Failed to create IP rule: %v", err)
+	}
+	if _, err := CreateFormRoute(db, form.ID, "department", RouteEquals, "sales", "sales@example.com"); err != nil {
+		t.Fatalf("Failed to create route: %v", err)
+	}
+	if _, err := CreateSubmission(db, form.ID, "127.0.0.1", "test", []byte(`{}`)); err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+
+	name, err := CopyName(db, user.ID, form.Name)
+	if err != nil || name != "Contact copy" {
+		t.Fatalf("Expected %q, got %q (%v)", "Contact copy", name, err)
+	}
+	copied, err := DuplicateForm(db, form, name, "copy-key")
+	if err != nil {
+		t.Fatalf("Failed to duplicate form: %v", err)
+	}
+	if copied.ID == form.ID || copied.FormKey != "copy-key" || copied.Domain != form.Domain ||
+		copied.TurnstileSecret != form.TurnstileSecret || copied.ForwardEmail != form.ForwardEmail {
+		t.Errorf("Expected a new form with the same settings, got %+v", copied)
+	}
+
+	tags, _ := GetFormTags(db, copied.ID)
+	if len(tags) != 1 || tags[0] != "client-a" {
+		t.Errorf("Expected the tags to be copied, got %v", tags)
+	}
+	rules, _ := GetIPRulesByFormID(db, copied.ID)
+	if len(rules) != 1 || rules[0].CIDR != "203.0.113.0/24" || rules[0].Note != "spammer" {
+		t.Errorf("Expected the IP rules to be copied, got %+v", rules)
+	}
+	if routes, _ := GetFormRoutes(db, copied.ID); len(routes) != 1 || routes[0].Recipient != "sales@example.com" {
+		t.Errorf("Expected the routing rules to be copied, got %+v", routes)
+	}
+	if count, _ := GetSubmissionCountByFormID(db, copied.ID); count != 0 {
+		t.Errorf("Expected no submissions to be copied, got %d", count)
+	}
+
+	// Further copies are numbered
+	if name, _ := CopyName(db, user.ID, form.Name); name != "Contact copy 2" {
+		t.Errorf("Expected %q, got %q", "Contact copy 2", name)
+	}
+}
+
+func TestSetFormTurnstileFallback(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "fallback@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "fallback", "example.com", "secret", "to@example.com")
+	if form.TurnstileFallback != TurnstileFallbackClosed {
+		t.Fatalf("Expected new forms to reject submissions while Turnstile is down, got %q", form.TurnstileFallback)
+	}
+
+	if err := SetFormTurnstileFallback(db, form.ID, TurnstileFallbackSpam); err != nil {
+		t.Fatalf("Failed to set the fallback: %v", err)
+	}
+	updated, _ := GetFormByKey(db, form.FormKey)
+	if updated.TurnstileFallback != TurnstileFallbackSpam {
+		t.Errorf("Expected the spam fallback, got %q", updated.TurnstileFallback)
+	}
+	if copied, err := DuplicateForm(db, updated, "copy", "fallback-copy"); err != nil || copied.TurnstileFallback != TurnstileFallbackSpam {
+		t.Errorf("Expected copies to keep the fallback, got %+v (%v)", copied, err)
+	}
+
+	if err := SetFormTurnstileFallback(db, form.ID, "open"); err == nil {
+		t.Error("Expected an unknown fallback to be refused")
+	}
+}
+
+func TestSetFormEmailAttachment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "attachment@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "attachment", "example.com", "secret", "to@example.com")
+	if form.EmailAttachment != EmailAttachmentNone {
+		t.Fatalf("Expected new forms to attach nothing, got %q", form.EmailAttachment)
+	}
+
+	if err := SetFormEmailAttachment(db, form.ID, EmailAttachmentCSV); err != nil {
+		t.Fatalf("Failed to set the attachment: %v", err)
+	}
+	updated, _ := GetFormByKey(db, form.FormKey)
+	if updated.EmailAttachment != EmailAttachmentCSV {
+		t.Errorf("Expected the CSV attachment, got %q", updated.EmailAttachment)
+	}
+	if copied, err := DuplicateForm(db, updated, "copy", "attachment-copy"); err != nil || copied.EmailAttachment != EmailAttachmentCSV {
+		t.Errorf("Expected copies to keep the attachment, got %+v (%v)", copied, err)
+	}
+
+	if err := SetFormEmailAttachment(db, form.ID, "xml"); err == nil {
+		t.Error("Expected an unknown attachment to be refused")
+	}
+}
+
+func TestSetFormHostedPage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "hosted@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "hosted", "example.com", "secret", "to@example.com")
+	if form.HostedPage {
+		t.Fatal("Expected new forms to have no hosted page")
+	}
+
+	if err := SetFormHostedPage(db, form.ID, true); err != nil {
+		t.Fatalf("Failed to turn on the hosted page: %v", err)
+	}
+	updated, _ := GetFormByKey(db, form.FormKey)
+	if !updated.HostedPage {
+		t.Error("Expected the hosted page to be on")
+	}
+	if copied, err := DuplicateForm(db, updated, "copy", "hosted-copy"); err != nil || !copied.HostedPage {
+		t.Errorf("Expected copies to keep the hosted page, got %+v (%v)", copied, err)
+	}
+}
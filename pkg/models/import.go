@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// ImportedSubmission is a submission brought in from another form service
+type ImportedSubmission struct {
+	IPAddress     string
+	UserAgent     string
+	Referrer      string
+	SubmittedData json.RawMessage
+	CreatedAt     time.Time
+}
+
+// ImportSubmissionsContext saves submissions brought in from another form
+// service to a form in one transaction, keeping the times they were sent.
+// They're saved as processed, since the service they came from delivered
+// them, so they aren't emailed again. The form's field catalog is rebuilt
+// to include them.
+func ImportSubmissionsContext(ctx context.Context, db *sql.DB, formID int64, submissions []ImportedSubmission) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT INTO submissions (form_id, ip_address, user_agent, referrer, submitted_data, status, processed_at, created_at) VALUES (?, ?, ?, ?, ?, 'processed', ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, submission := range submissions {
+		createdAt := sqlTime(submission.CreatedAt)
+		if _, err := stmt.ExecContext(ctx,
+			formID, submission.IPAddress, submission.UserAgent, submission.Referrer, string(submission.SubmittedData), createdAt, createdAt,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := rebuildFormFields(ctx, tx, formID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ImportSubmissions is like ImportSubmissionsContext but uses context.Background
+func ImportSubmissions(db *sql.DB, formID int64, submissions []ImportedSubmission) error {
+	return ImportSubmissionsContext(context.Background(), db, formID, submissions)
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Inbox statuses track how far along handling a submission is
+const (
+	InboxStatusNew        = "new"
+	InboxStatusInProgress = "in-progress"
+	InboxStatusDone       = "done"
+)
+
+// InboxStatuses lists the inbox statuses in workflow order
+var InboxStatuses = []string{InboxStatusNew, InboxStatusInProgress, InboxStatusDone}
+
+// IsValidInboxStatus reports whether status is one of InboxStatuses
+func IsValidInboxStatus(status string) bool {
+	for _, s := range InboxStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Assignment records who is handling a submission and its inbox status
+type Assignment struct {
+	SubmissionID  int64     `json:"submission_id"`
+	AssigneeID    *int64    `json:"assignee_id"`
+	AssigneeEmail string    `json:"assignee_email,omitempty"`
+	Status        string    `json:"status"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// SubmissionNote is an internal note on a submission, never shown to the
+// person who submitted the form
+type SubmissionNote struct {
+	ID           int64     `json:"id"`
+	SubmissionID int64     `json:"submission_id"`
+	UserID       *int64    `json:"user_id"`
+	AuthorEmail  string    `json:"author_email,omitempty"`
+	Body         string    `json:"body"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// scanAssignment reads an assignment row joined with the assignee's email
+func scanAssignment(row rowScanner) (*Assignment, error) {
+	var assignment Assignment
+	var assigneeID sql.NullInt64
+	var assigneeEmail sql.NullString
+	if err := row.Scan(&assignment.SubmissionID, &assigneeID, &assigneeEmail, &assignment.Status, &assignment.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if assigneeID.Valid {
+		assignment.AssigneeID = &assigneeID.Int64
+	}
+	assignment.AssigneeEmail = assigneeEmail.String
+	return &assignment, nil
+}
+
+// GetAssignmentContext retrieves a submission's assignment. Submissions that
+// were never assigned are new and unassigned.
+func GetAssignmentContext(ctx context.Context, db *sql.DB, submissionID int64) (*Assignment, error) {
+	assignment, err := scanAssignment(db.QueryRowContext(ctx,
+		`SELECT a.submission_id, a.assignee_id, u.email, a.status, a.updated_at
+		FROM submission_assignments a LEFT JOIN users u ON u.id = a.assignee_id
+		WHERE a.submission_id = ?`,
+		submissionID,
+	))
+	if err == sql.ErrNoRows {
+		return &Assignment{SubmissionID: submissionID, Status: InboxStatusNew}, nil
+	}
+	return assignment, err
+}
+
+// GetAssignment is like GetAssignmentContext but uses context.Background
+func GetAssignment(db *sql.DB, submissionID int64) (*Assignment, error) {
+	return GetAssignmentContext(context.Background(), db, submissionID)
+}
+
+// GetAssignmentsByFormIDContext retrieves the assignments of a form's
+// submissions keyed by submission ID. Unassigned submissions are left out.
+func GetAssignmentsByFormIDContext(ctx context.Context, db *sql.DB, formID int64) (map[int64]Assignment, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT a.submission_id, a.assignee_id, u.email, a.status, a.updated_at
+		FROM submission_assignments a
+		JOIN submissions s ON s.id = a.submission_id
+		LEFT JOIN users u ON u.id = a.assignee_id
+		WHERE s.form_id = ?`,
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := make(map[int64]Assignment)
+	for rows.Next() {
+		assignment, err := scanAssignment(rows)
+		if err != nil {
+			return nil, err
+		}
+		assignments[assignment.SubmissionID] = *assignment
+	}
+
+	return assignments, rows.Err()
+}
+
+// GetAssignmentsByFormID is like GetAssignmentsByFormIDContext but uses context.Background
+func GetAssignmentsByFormID(db *sql.DB, formID int64) (map[int64]Assignment, error) {
+	return GetAssignmentsByFormIDContext(context.Background(), db, formID)
+}
+
+// SetAssignmentContext assigns a submission to a user, or to nobody when
+// assigneeID is nil, and sets its inbox status
+func SetAssignmentContext(ctx context.Context, db *sql.DB, submissionID int64, assigneeID *int64, status string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM submission_assignments WHERE submission_id = ?", submissionID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO submission_assignments (submission_id, assignee_id, status) VALUES (?, ?, ?)",
+		submissionID, assigneeID, status,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetAssignment is like SetAssignmentContext but uses context.Background
+func SetAssignment(db *sql.DB, submissionID int64, assigneeID *int64, status string) error {
+	return SetAssignmentContext(context.Background(), db, submissionID, assigneeID, status)
+}
+
+// CreateSubmissionNoteContext adds a note to a submission
+func CreateSubmissionNoteContext(ctx context.Context, db *sql.DB, submissionID, userID int64, body string) (*SubmissionNote, error) {
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO submission_notes (submission_id, user_id, body) VALUES (?, ?, ?)",
+		submissionID, userID, body,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetSubmissionNoteByIDContext(ctx, db, id)
+}
+
+// CreateSubmissionNote is like CreateSubmissionNoteContext but uses context.Background
+func CreateSubmissionNote(db *sql.DB, submissionID, userID int64, body string) (*SubmissionNote, error) {
+	return CreateSubmissionNoteContext(context.Background(), db, submissionID, userID, body)
+}
+
+// scanSubmissionNote reads a note row joined with its author's email
+func scanSubmissionNote(row rowScanner) (*SubmissionNote, error) {
+	var note SubmissionNote
+	var userID sql.NullInt64
+	var authorEmail sql.NullString
+	if err := row.Scan(&note.ID, &note.SubmissionID, &userID, &authorEmail, &note.Body, &note.CreatedAt); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		note.UserID = &userID.Int64
+	}
+	note.AuthorEmail = authorEmail.String
+	return &note, nil
+}
+
+// GetSubmissionNoteByIDContext retrieves a note by its ID
+func GetSubmissionNoteByIDContext(ctx context.Context, db *sql.DB, id int64) (*SubmissionNote, error) {
+	note, err := scanSubmissionNote(db.QueryRowContext(ctx,
+		`SELECT n.id, n.submission_id, n.user_id, u.email, n.body, n.created_at
+		FROM submission_notes n LEFT JOIN users u ON u.id = n.user_id
+		WHERE n.id = ?`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return note, err
+}
+
+// GetSubmissionNoteByID is like GetSubmissionNoteByIDContext but uses context.Background
+func GetSubmissionNoteByID(db *sql.DB, id int64) (*SubmissionNote, error) {
+	return GetSubmissionNoteByIDContext(context.Background(), db, id)
+}
+
+// GetSubmissionNotesContext retrieves a submission's notes, oldest first
+func GetSubmissionNotesContext(ctx context.Context, db *sql.DB, submissionID int64) ([]SubmissionNote, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT n.id, n.submission_id, n.user_id, u.email, n.body, n.created_at
+		FROM submission_notes n LEFT JOIN users u ON u.id = n.user_id
+		WHERE n.submission_id = ?
+		ORDER BY n.created_at, n.id`,
+		submissionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []SubmissionNote
+	for rows.Next() {
+		note, err := scanSubmissionNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *note)
+	}
+
+	return notes, rows.Err()
+}
+
+// GetSubmissionNotes is like GetSubmissionNotesContext but uses context.Background
+func GetSubmissionNotes(db *sql.DB, submissionID int64) ([]SubmissionNote, error) {
+	return GetSubmissionNotesContext(context.Background(), db, submissionID)
+}
+
+// GetSubmissionNoteCountsByFormIDContext counts the notes on each of a
+// form's submissions, keyed by submission ID
+func GetSubmissionNoteCountsByFormIDContext(ctx context.Context, db *sql.DB, formID int64) (map[int64]int, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT n.submission_id, COUNT(*)
+		FROM submission_notes n JOIN submissions s ON s.id = n.submission_id
+		WHERE s.form_id = ?
+		GROUP BY n.submission_id`,
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var submissionID int64
+		var count int
+		if err := rows.Scan(&submissionID, &count); err != nil {
+			return nil, err
+		}
+		counts[submissionID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetSubmissionNoteCountsByFormID is like GetSubmissionNoteCountsByFormIDContext but uses context.Background
+func GetSubmissionNoteCountsByFormID(db *sql.DB, formID int64) (map[int64]int, error) {
+	return GetSubmissionNoteCountsByFormIDContext(context.Background(), db, formID)
+}
+
+// DeleteSubmissionNoteContext deletes a note
+func DeleteSubmissionNoteContext(ctx context.Context, db *sql.DB, id int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM submission_notes WHERE id = ?", id)
+	return err
+}
+
+// DeleteSubmissionNote is like DeleteSubmissionNoteContext but uses context.Background
+func DeleteSubmissionNote(db *sql.DB, id int64) error {
+	return DeleteSubmissionNoteContext(context.Background(), db, id)
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSubmissionAssignment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, _ := CreateUser(db, "owner@example.com", "hashed_password")
+	teammate, _ := CreateUser(db, "teammate@example.com", "hashed_password")
+	form, err := CreateForm(db, owner.ID, "Inbox", "example.com", "secret", "to@example.com", "inbox-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	submission, err := CreateSubmission(db, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+
+	// Submissions start out new and unassigned
+	assignment, err := GetAssignment(db, submission.ID)
+	if err != nil {
+		t.Fatalf("Failed to get assignment: %v", err)
+	}
+	if assignment.Status != InboxStatusNew || assignment.AssigneeID != nil {
+		t.Errorf("Expected a new, unassigned submission, got %+v", assignment)
+	}
+
+	if err := SetAssignment(db, submission.ID, &teammate.ID, InboxStatusInProgress); err != nil {
+		t.Fatalf("Failed to set assignment: %v", err)
+	}
+	assignments, err := GetAssignmentsByFormID(db, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to get assignments: %v", err)
+	}
+	got := assignments[submission.ID]
+	if got.Status != InboxStatusInProgress || got.AssigneeEmail != "teammate@example.com" {
+		t.Errorf("Expected the submission in progress with the teammate, got %+v", got)
+	}
+
+	if err := SetAssignment(db, submission.ID, nil, InboxStatusDone); err != nil {
+		t.Fatalf("Failed to set assignment: %v", err)
+	}
+	assignment, err = GetAssignment(db, submission.ID)
+	if err != nil {
+		t.Fatalf("Failed to get assignment: %v", err)
+	}
+	if assignment.Status != InboxStatusDone || assignment.AssigneeID != nil {
+		t.Errorf("Expected a done, unassigned submission, got %+v", assignment)
+	}
+
+	if err := SetAssignment(db, submission.ID, nil, "archived"); err == nil {
+		t.Error("Expected an invalid status to be rejected")
+	}
+}
+
+func TestSubmissionNotes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "notes@example.com", "hashed_password")
+	form, err := CreateForm(db, user.ID, "Notes", "example.com", "secret", "to@example.com", "notes-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	submission, err := CreateSubmission(db, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+
+	first, err := CreateSubmissionNote(db, submission.ID, user.ID, "Called them back")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if first.AuthorEmail != "notes@example.com" {
+		t.Errorf("Expected the author's email, got %q", first.AuthorEmail)
+	}
+	if _, err := CreateSubmissionNote(db, submission.ID, user.ID, "Waiting on a quote"); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	notes, err := GetSubmissionNotes(db, submission.ID)
+	if err != nil {
+		t.Fatalf("Failed to get notes: %v", err)
+	}
+	if len(notes) != 2 || notes[0].Body != "Called them back" {
+		t.Errorf("Expected 2 notes oldest first, got %+v", notes)
+	}
+
+	counts, err := GetSubmissionNoteCountsByFormID(db, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to count notes: %v", err)
+	}
+	if counts[submission.ID] != 2 {
+		t.Errorf("Expected 2 notes, got %d", counts[submission.ID])
+	}
+
+	if err := DeleteSubmissionNote(db, first.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	note, err := GetSubmissionNoteByID(db, first.ID)
+	if err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if note != nil {
+		t.Error("Expected the note to be deleted")
+	}
+
+	// Deleting the form takes its notes with it
+	if err := SetAssignment(db, submission.ID, &user.ID, InboxStatusDone); err != nil {
+		t.Fatalf("Failed to set assignment: %v", err)
+	}
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	for _, table := range []string{"submission_notes", "submission_assignments"} {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		if count != 0 {
+			t.Errorf("Expected no rows left in %s, got %d", table, count)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ForeignKey describes a column that references another table's id. When
+// foreign key enforcement was off, e.g. SQLite before PRAGMA foreign_keys
+// was set, rows can be left pointing at parents that no longer exist.
+type ForeignKey struct {
+	Table      string
+	Column     string
+	References string
+	// Nullable foreign keys are cleared when their parent is missing, the
+	// same as ON DELETE SET NULL; other orphaned rows are deleted
+	Nullable bool
+}
+
+// ForeignKeys lists every foreign key in the schema, parents before children
+// so repairing them in order leaves no new orphans behind
+var ForeignKeys = []ForeignKey{
+	{Table: "forms", Column: "user_id", References: "users"},
+	{Table: "submissions", Column: "form_id", References: "forms"},
+	{Table: "submission_emails", Column: "submission_id", References: "submissions"},
+	{Table: "ip_rules", Column: "form_id", References: "forms"},
+	{Table: "blocked_attempts", Column: "form_id", References: "forms"},
+	{Table: "blocked_attempts", Column: "rule_id", References: "ip_rules", Nullable: true},
+	{Table: "submission_archives", Column: "form_id", References: "forms"},
+	{Table: "user_quotas", Column: "user_id", References: "users"},
+	{Table: "tags", Column: "user_id", References: "users"},
+	{Table: "form_tags", Column: "form_id", References: "forms"},
+	{Table: "form_tags", Column: "tag_id", References: "tags"},
+	{Table: "submission_assignments", Column: "submission_id", References: "submissions"},
+	{Table: "submission_assignments", Column: "assignee_id", References: "users", Nullable: true},
+	{Table: "submission_notes", Column: "submission_id", References: "submissions"},
+	{Table: "submission_notes", Column: "user_id", References: "users", Nullable: true},
+	{Table: "submission_columns", Column: "user_id", References: "users"},
+	{Table: "submission_columns", Column: "form_id", References: "forms"},
+	{Table: "form_status_pages", Column: "form_id", References: "forms"},
+	{Table: "form_feeds", Column: "form_id", References: "forms"},
+	{Table: "export_jobs", Column: "user_id", References: "users"},
+	{Table: "export_jobs", Column: "form_id", References: "forms"},
+	{Table: "notification_channels", Column: "form_id", References: "forms"},
+	{Table: "notification_deliveries", Column: "channel_id", References: "notification_channels"},
+	{Table: "notification_deliveries", Column: "submission_id", References: "submissions"},
+	{Table: "google_credentials", Column: "user_id", References: "users"},
+	{Table: "form_sheets", Column: "form_id", References: "forms"},
+	{Table: "api_keys", Column: "user_id", References: "users"},
+	{Table: "submission_files", Column: "submission_id", References: "submissions"},
+	{Table: "form_fields", Column: "form_id", References: "forms"},
+	{Table: "form_field_totals", Column: "form_id", References: "forms"},
+	{Table: "notification_mutes", Column: "form_id", References: "forms"},
+	{Table: "form_routes", Column: "form_id", References: "forms"},
+}
+
+// orphanCondition matches the rows whose foreign key points at a missing parent
+func (fk ForeignKey) orphanCondition() string {
+	return fmt.Sprintf("%s IS NOT NULL AND %s NOT IN (SELECT id FROM %s)", fk.Column, fk.Column, fk.References)
+}
+
+// CountOrphansContext counts the rows whose foreign key points at a missing parent
+func CountOrphansContext(ctx context.Context, db *sql.DB, fk ForeignKey) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+fk.Table+" WHERE "+fk.orphanCondition()).Scan(&count)
+	return count, err
+}
+
+// CountOrphans is like CountOrphansContext but uses context.Background
+func CountOrphans(db *sql.DB, fk ForeignKey) (int, error) {
+	return CountOrphansContext(context.Background(), db, fk)
+}
+
+// RepairOrphansContext deletes the rows whose foreign key points at a missing
+// parent, or clears the key when it is nullable, and returns how many rows
+// were changed
+func RepairOrphansContext(ctx context.Context, db *sql.DB, fk ForeignKey) (int64, error) {
+	query := "DELETE FROM " + fk.Table + " WHERE " + fk.orphanCondition()
+	if fk.Nullable {
+		query = "UPDATE " + fk.Table + " SET " + fk.Column + " = NULL WHERE " + fk.orphanCondition()
+	}
+
+	result, err := db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RepairOrphans is like RepairOrphansContext but uses context.Background
+func RepairOrphans(db *sql.DB, fk ForeignKey) (int64, error) {
+	return RepairOrphansContext(context.Background(), db, fk)
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// IPRule represents an IP or CIDR allow/deny rule. Rules without a FormID
+// are global and apply to submissions for every form.
+type IPRule struct {
+	ID        int64     `json:"id"`
+	FormID    *int64    `json:"form_id"`
+	CIDR      string    `json:"cidr"`
+	Action    string    `json:"action"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlockedAttempt records a submission rejected by an IP rule
+type BlockedAttempt struct {
+	ID        int64     `json:"id"`
+	FormID    *int64    `json:"form_id"`
+	RuleID    *int64    `json:"rule_id"`
+	IPAddress string    `json:"ip_address"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateIPRuleContext creates a new IP rule. Pass a nil formID for a global rule.
+func CreateIPRuleContext(ctx context.Context, db *sql.DB, formID *int64, cidr, action, note string) (*IPRule, error) {
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO ip_rules (form_id, cidr, action, note) VALUES (?, ?, ?, ?)",
+		formID, cidr, action, note,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetIPRuleByIDContext(ctx, db, id)
+}
+
+// CreateIPRule is like CreateIPRuleContext but uses context.Background
+func CreateIPRule(db *sql.DB, formID *int64, cidr, action, note string) (*IPRule, error) {
+	return CreateIPRuleContext(context.Background(), db, formID, cidr, action, note)
+}
+
+// GetIPRuleByIDContext retrieves an IP rule by its ID
+func GetIPRuleByIDContext(ctx context.Context, db *sql.DB, id int64) (*IPRule, error) {
+	var rule IPRule
+	var formID sql.NullInt64
+	err := db.QueryRowContext(ctx,
+		"SELECT id, form_id, cidr, action, note, created_at FROM ip_rules WHERE id = ?",
+		id,
+	).Scan(&rule.ID, &formID, &rule.CIDR, &rule.Action, &rule.Note, &rule.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if formID.Valid {
+		rule.FormID = &formID.Int64
+	}
+
+	return &rule, nil
+}
+
+// GetIPRuleByID is like GetIPRuleByIDContext but uses context.Background
+func GetIPRuleByID(db *sql.DB, id int64) (*IPRule, error) {
+	return GetIPRuleByIDContext(context.Background(), db, id)
+}
+
+// GetGlobalIPRulesContext retrieves all rules that apply to every form
+func GetGlobalIPRulesContext(ctx context.Context, db *sql.DB) ([]IPRule, error) {
+	return queryIPRules(ctx, db, "SELECT id, form_id, cidr, action, note, created_at FROM ip_rules WHERE form_id IS NULL ORDER BY created_at, id")
+}
+
+// GetGlobalIPRules is like GetGlobalIPRulesContext but uses context.Background
+func GetGlobalIPRules(db *sql.DB) ([]IPRule, error) {
+	return GetGlobalIPRulesContext(context.Background(), db)
+}
+
+// GetIPRulesByFormIDContext retrieves the rules specific to a form
+func GetIPRulesByFormIDContext(ctx context.Context, db *sql.DB, formID int64) ([]IPRule, error) {
+	return queryIPRules(ctx, db, "SELECT id, form_id, cidr, action, note, created_at FROM ip_rules WHERE form_id = ? ORDER BY created_at, id", formID)
+}
+
+// GetIPRulesByFormID is like GetIPRulesByFormIDContext but uses context.Background
+func GetIPRulesByFormID(db *sql.DB, formID int64) ([]IPRule, error) {
+	return GetIPRulesByFormIDContext(context.Background(), db, formID)
+}
+
+// queryIPRules runs a query returning IP rule rows
+func queryIPRules(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]IPRule, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []IPRule
+	for rows.Next() {
+		var rule IPRule
+		var formID sql.NullInt64
+		if err := rows.Scan(&rule.ID, &formID, &rule.CIDR, &rule.Action, &rule.Note, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		if formID.Valid {
+			rule.FormID = &formID.Int64
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// DeleteIPRuleContext deletes an IP rule
+func DeleteIPRuleContext(ctx context.Context, db *sql.DB, id int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM ip_rules WHERE id = ?", id)
+	return err
+}
+
+// DeleteIPRule is like DeleteIPRuleContext but uses context.Background
+func DeleteIPRule(db *sql.DB, id int64) error {
+	return DeleteIPRuleContext(context.Background(), db, id)
+}
+
+// CreateBlockedAttemptContext records a blocked submission attempt
+func CreateBlockedAttemptContext(ctx context.Context, db *sql.DB, formID, ruleID *int64, ipAddress, reason string) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO blocked_attempts (form_id, rule_id, ip_address, reason) VALUES (?, ?, ?, ?)",
+		formID, ruleID, ipAddress, reason,
+	)
+	return err
+}
+
+// CreateBlockedAttempt is like CreateBlockedAttemptContext but uses context.Background
+func CreateBlockedAttempt(db *sql.DB, formID, ruleID *int64, ipAddress, reason string) error {
+	return CreateBlockedAttemptContext(context.Background(), db, formID, ruleID, ipAddress, reason)
+}
+
+// CountBlockedAttemptsByIPContext counts the attempts from an IP address
+// blocked since a time, across all forms
+func CountBlockedAttemptsByIPContext(ctx context.Context, db *sql.DB, ipAddress string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM blocked_attempts WHERE ip_address = ? AND created_at >= ?",
+		ipAddress, sqlTime(since),
+	).Scan(&count)
+	return count, err
+}
+
+// CountBlockedAttemptsByIP is like CountBlockedAttemptsByIPContext but uses context.Background
+func CountBlockedAttemptsByIP(db *sql.DB, ipAddress string, since time.Time) (int, error) {
+	return CountBlockedAttemptsByIPContext(context.Background(), db, ipAddress, since)
+}
+
+// GetRecentBlockedAttemptsContext retrieves the most recent blocked attempts across all forms
+func GetRecentBlockedAttemptsContext(ctx context.Context, db *sql.DB, limit int) ([]BlockedAttempt, error) {
+	return queryBlockedAttempts(ctx, db, "SELECT id, form_id, rule_id, ip_address, reason, created_at FROM blocked_attempts ORDER BY created_at DESC, id DESC LIMIT ?", limit)
+}
+
+// GetRecentBlockedAttempts is like GetRecentBlockedAttemptsContext but uses context.Background
+func GetRecentBlockedAttempts(db *sql.DB, limit int) ([]BlockedAttempt, error) {
+	return GetRecentBlockedAttemptsContext(context.Background(), db, limit)
+}
+
+// GetBlockedAttemptsByFormIDContext retrieves the most recent blocked attempts for a form
+func GetBlockedAttemptsByFormIDContext(ctx context.Context, db *sql.DB, formID int64, limit int) ([]BlockedAttempt, error) {
+	return queryBlockedAttempts(ctx, db, "SELECT id, form_id, rule_id, ip_address, reason, created_at FROM blocked_attempts WHERE form_id = ? ORDER BY created_at DESC, id DESC LIMIT ?", formID, limit)
+}
+
+// GetBlockedAttemptsByFormID is like GetBlockedAttemptsByFormIDContext but uses context.Background
+func GetBlockedAttemptsByFormID(db *sql.DB, formID int64, limit int) ([]BlockedAttempt, error) {
+	return GetBlockedAttemptsByFormIDContext(context.Background(), db, formID, limit)
+}
+
+// queryBlockedAttempts runs a query returning blocked attempt rows
+func queryBlockedAttempts(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]BlockedAttempt, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []BlockedAttempt
+	for rows.Next() {
+		var attempt BlockedAttempt
+		var formID, ruleID sql.NullInt64
+		if err := rows.Scan(&attempt.ID, &formID, &ruleID, &attempt.IPAddress, &attempt.Reason, &attempt.CreatedAt); err != nil {
+			return nil, err
+		}
+		if formID.Valid {
+			attempt.FormID = &formID.Int64
+		}
+		if ruleID.Valid {
+			attempt.RuleID = &ruleID.Int64
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
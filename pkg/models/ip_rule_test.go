@@ -0,0 +1,135 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestCreateAndGetIPRules(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "user@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form := CreateTestForm(t, db, user.ID, "contact", "example.com", "secret", "admin@example.com")
+
+	// Global rule
+	globalRule, err := CreateIPRule(db, nil, "203.0.113.0/24", "deny", "known spammer")
+	if err != nil {
+		t.Fatalf("Failed to create global rule: %v", err)
+	}
+	if globalRule.FormID != nil {
+		t.Error("Expected global rule to have no form ID")
+	}
+	if globalRule.Note != "known spammer" {
+		t.Errorf("Expected note 'known spammer', got '%s'", globalRule.Note)
+	}
+
+	// Form rule
+	formRule, err := CreateIPRule(db, &form.ID, "198.51.100.7/32", "allow", "")
+	if err != nil {
+		t.Fatalf("Failed to create form rule: %v", err)
+	}
+	if formRule.FormID == nil || *formRule.FormID != form.ID {
+		t.Errorf("Expected form rule for form %d, got %v", form.ID, formRule.FormID)
+	}
+
+	globalRules, err := GetGlobalIPRules(db)
+	if err != nil {
+		t.Fatalf("Failed to get global rules: %v", err)
+	}
+	if len(globalRules) != 1 || globalRules[0].ID != globalRule.ID {
+		t.Errorf("Expected only the global rule, got %v", globalRules)
+	}
+
+	formRules, err := GetIPRulesByFormID(db, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to get form rules: %v", err)
+	}
+	if len(formRules) != 1 || formRules[0].ID != formRule.ID {
+		t.Errorf("Expected only the form rule, got %v", formRules)
+	}
+
+	// Invalid action is rejected by the schema
+	if _, err := CreateIPRule(db, nil, "192.0.2.1/32", "maybe", ""); err == nil {
+		t.Error("Expected error for invalid action")
+	}
+}
+
+func TestDeleteIPRule(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	rule, err := CreateIPRule(db, nil, "203.0.113.0/24", "deny", "")
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	if err := DeleteIPRule(db, rule.ID); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+
+	deleted, err := GetIPRuleByID(db, rule.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if deleted != nil {
+		t.Error("Expected rule to be deleted")
+	}
+}
+
+func TestBlockedAttempts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "user@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form := CreateTestForm(t, db, user.ID, "contact", "example.com", "secret", "admin@example.com")
+	otherForm := CreateTestForm(t, db, user.ID, "support", "example.com", "secret", "admin@example.com")
+
+	rule, err := CreateIPRule(db, nil, "203.0.113.0/24", "deny", "")
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	if err := CreateBlockedAttempt(db, &form.ID, &rule.ID, "203.0.113.9", "matched deny rule"); err != nil {
+		t.Fatalf("Failed to record blocked attempt: %v", err)
+	}
+	if err := CreateBlockedAttempt(db, &otherForm.ID, nil, "192.0.2.1", "not in allowlist"); err != nil {
+		t.Fatalf("Failed to record blocked attempt: %v", err)
+	}
+
+	recent, err := GetRecentBlockedAttempts(db, 10)
+	if err != nil {
+		t.Fatalf("Failed to get blocked attempts: %v", err)
+	}
+	if len(recent) != 2 {
+		t.Fatalf("Expected 2 blocked attempts, got %d", len(recent))
+	}
+
+	forForm, err := GetBlockedAttemptsByFormID(db, form.ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to get blocked attempts for form: %v", err)
+	}
+	if len(forForm) != 1 || forForm[0].IPAddress != "203.0.113.9" {
+		t.Errorf("Unexpected blocked attempts for form: %v", forForm)
+	}
+	if forForm[0].RuleID == nil || *forForm[0].RuleID != rule.ID {
+		t.Errorf("Expected rule ID %d, got %v", rule.ID, forForm[0].RuleID)
+	}
+
+	// Deleting the rule keeps the audit entry
+	if err := DeleteIPRule(db, rule.ID); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	forForm, err = GetBlockedAttemptsByFormID(db, form.ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to get blocked attempts for form: %v", err)
+	}
+	if len(forForm) != 1 || forForm[0].RuleID != nil {
+		t.Errorf("Expected audit entry to remain without a rule, got %v", forForm)
+	}
+}
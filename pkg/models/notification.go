@@ -0,0 +1,308 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"staticsend/pkg/secrets"
+)
+
+// Notification delivery statuses
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+)
+
+// NotificationChannel is somewhere a form's notifications are delivered,
+// such as a webhook or a chat room
+type NotificationChannel struct {
+	ID     int64  `json:"id"`
+	FormID int64  `json:"form_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	// Config holds the channel type's settings, e.g. its webhook URL
+	Config map[string]string `json:"config"`
+	// Events are the events the channel is sent, e.g. submission.created
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribes reports whether the channel is sent an event
+func (c NotificationChannel) Subscribes(event string) bool {
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// joinEvents stores a channel's events as a comma separated list
+func joinEvents(events []string) string {
+	return strings.Join(events, ",")
+}
+
+// splitEvents reads a channel's stored events
+func splitEvents(stored string) []string {
+	if stored == "" {
+		return nil
+	}
+	return strings.Split(stored, ",")
+}
+
+// NotificationDelivery records sending one notification through a channel
+type NotificationDelivery struct {
+	ID        int64 `json:"id"`
+	ChannelID int64 `json:"channel_id"`
+	// SubmissionID is 0 for events that aren't about a submission
+	SubmissionID int64      `json:"submission_id,omitempty"`
+	Event        string     `json:"event"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeliveredAt  *time.Time `json:"delivered_at"`
+}
+
+const notificationChannelColumns = "id, form_id, type, name, config, events, enabled, created_at, updated_at FROM notification_channels"
+
+// scanNotificationChannel reads a channel row, returning nil if there isn't one
+func scanNotificationChannel(row rowScanner) (*NotificationChannel, error) {
+	var channel NotificationChannel
+	var config, events string
+	err := row.Scan(&channel.ID, &channel.FormID, &channel.Type, &channel.Name, &config, &events, &channel.Enabled, &channel.CreatedAt, &channel.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// The config holds webhook URLs and signing secrets, so it's encrypted
+	if config, err = secrets.Open(config); err != nil {
+		return nil, fmt.Errorf("notification channel %d: %w", channel.ID, err)
+	}
+	if err := json.Unmarshal([]byte(config), &channel.Config); err != nil || channel.Config == nil {
+		channel.Config = map[string]string{}
+	}
+	channel.Events = splitEvents(events)
+	return &channel, nil
+}
+
+// sealChannelConfig encodes a channel's config for saving, encrypted
+func sealChannelConfig(config map[string]string) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return secrets.Seal(string(data))
+}
+
+// CreateNotificationChannelContext adds an enabled channel to a form, sent
+// the events listed
+func CreateNotificationChannelContext(ctx context.Context, db *sql.DB, formID int64, channelType, name string, config map[string]string, events []string) (*NotificationChannel, error) {
+	sealed, err := sealChannelConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO notification_channels (form_id, type, name, config, events, enabled) VALUES (?, ?, ?, ?, ?, ?)",
+		formID, channelType, name, sealed, joinEvents(events), true,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetNotificationChannelByIDContext(ctx, db, id)
+}
+
+// CreateNotificationChannel is like CreateNotificationChannelContext but uses context.Background
+func CreateNotificationChannel(db *sql.DB, formID int64, channelType, name string, config map[string]string, events []string) (*NotificationChannel, error) {
+	return CreateNotificationChannelContext(context.Background(), db, formID, channelType, name, config, events)
+}
+
+// GetNotificationChannelByIDContext retrieves a channel by its ID
+func GetNotificationChannelByIDContext(ctx context.Context, db *sql.DB, id int64) (*NotificationChannel, error) {
+	return scanNotificationChannel(db.QueryRowContext(ctx, "SELECT "+notificationChannelColumns+" WHERE id = ?", id))
+}
+
+// GetNotificationChannelByID is like GetNotificationChannelByIDContext but uses context.Background
+func GetNotificationChannelByID(db *sql.DB, id int64) (*NotificationChannel, error) {
+	return GetNotificationChannelByIDContext(context.Background(), db, id)
+}
+
+// GetNotificationChannelsByFormIDContext returns a form's channels in the
+// order they were added, only the enabled ones if enabledOnly is set
+func GetNotificationChannelsByFormIDContext(ctx context.Context, db *sql.DB, formID int64, enabledOnly bool) ([]NotificationChannel, error) {
+	return queryNotificationChannels(ctx, db, "form_id = ?", formID, enabledOnly)
+}
+
+// GetNotificationChannelsByFormID is like GetNotificationChannelsByFormIDContext but uses context.Background
+func GetNotificationChannelsByFormID(db *sql.DB, formID int64, enabledOnly bool) ([]NotificationChannel, error) {
+	return GetNotificationChannelsByFormIDContext(context.Background(), db, formID, enabledOnly)
+}
+
+// GetNotificationChannelsByUserIDContext returns the channels of all of a
+// user's forms in the order they were added, only the enabled ones if
+// enabledOnly is set
+func GetNotificationChannelsByUserIDContext(ctx context.Context, db *sql.DB, userID int64, enabledOnly bool) ([]NotificationChannel, error) {
+	return queryNotificationChannels(ctx, db, "form_id IN (SELECT id FROM forms WHERE user_id = ?)", userID, enabledOnly)
+}
+
+// GetNotificationChannelsByUserID is like GetNotificationChannelsByUserIDContext but uses context.Background
+func GetNotificationChannelsByUserID(db *sql.DB, userID int64, enabledOnly bool) ([]NotificationChannel, error) {
+	return GetNotificationChannelsByUserIDContext(context.Background(), db, userID, enabledOnly)
+}
+
+// queryNotificationChannels returns the channels matching condition, which
+// takes one argument
+func queryNotificationChannels(ctx context.Context, db *sql.DB, condition string, arg interface{}, enabledOnly bool) ([]NotificationChannel, error) {
+	query := "SELECT " + notificationChannelColumns + " WHERE " + condition
+	args := []interface{}{arg}
+	if enabledOnly {
+		query += " AND enabled = ?"
+		args = append(args, true)
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []NotificationChannel
+	for rows.Next() {
+		channel, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, *channel)
+	}
+	return channels, rows.Err()
+}
+
+// UpdateNotificationChannelContext changes a channel's name, settings,
+// events and whether it's enabled
+func UpdateNotificationChannelContext(ctx context.Context, db *sql.DB, id int64, name string, config map[string]string, events []string, enabled bool) error {
+	sealed, err := sealChannelConfig(config)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		"UPDATE notification_channels SET name = ?, config = ?, events = ?, enabled = ?, updated_at = ? WHERE id = ?",
+		name, sealed, joinEvents(events), enabled, sqlTime(time.Now()), id,
+	)
+	return err
+}
+
+// UpdateNotificationChannel is like UpdateNotificationChannelContext but uses context.Background
+func UpdateNotificationChannel(db *sql.DB, id int64, name string, config map[string]string, events []string, enabled bool) error {
+	return UpdateNotificationChannelContext(context.Background(), db, id, name, config, events, enabled)
+}
+
+// DeleteNotificationChannelContext deletes a channel and its delivery log
+func DeleteNotificationChannelContext(ctx context.Context, db *sql.DB, id int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM notification_deliveries WHERE channel_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM notification_channels WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteNotificationChannel is like DeleteNotificationChannelContext but uses context.Background
+func DeleteNotificationChannel(db *sql.DB, id int64) error {
+	return DeleteNotificationChannelContext(context.Background(), db, id)
+}
+
+// CreateNotificationDeliveryContext records a pending delivery of an event
+// through a channel. submissionID is 0 for events that aren't about a
+// submission.
+func CreateNotificationDeliveryContext(ctx context.Context, db *sql.DB, channelID, submissionID int64, event string) (int64, error) {
+	var submission interface{}
+	if submissionID != 0 {
+		submission = submissionID
+	}
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO notification_deliveries (channel_id, submission_id, event, status) VALUES (?, ?, ?, ?)",
+		channelID, submission, event, DeliveryStatusPending,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// CreateNotificationDelivery is like CreateNotificationDeliveryContext but uses context.Background
+func CreateNotificationDelivery(db *sql.DB, channelID, submissionID int64, event string) (int64, error) {
+	return CreateNotificationDeliveryContext(context.Background(), db, channelID, submissionID, event)
+}
+
+// RecordDeliveryAttemptContext counts an attempt at a delivery and records
+// its outcome. A delivery stays pending while it's being retried.
+func RecordDeliveryAttemptContext(ctx context.Context, db *sql.DB, id int64, status, errorMsg string) error {
+	var deliveredAt interface{}
+	if status == DeliveryStatusSent {
+		deliveredAt = sqlTime(time.Now())
+	}
+	_, err := db.ExecContext(ctx,
+		"UPDATE notification_deliveries SET status = ?, attempts = attempts + 1, error = ?, delivered_at = ? WHERE id = ?",
+		status, errorMsg, deliveredAt, id,
+	)
+	return err
+}
+
+// RecordDeliveryAttempt is like RecordDeliveryAttemptContext but uses context.Background
+func RecordDeliveryAttempt(db *sql.DB, id int64, status, errorMsg string) error {
+	return RecordDeliveryAttemptContext(context.Background(), db, id, status, errorMsg)
+}
+
+// GetNotificationDeliveriesContext returns a channel's latest deliveries,
+// up to limit, newest first
+func GetNotificationDeliveriesContext(ctx context.Context, db *sql.DB, channelID int64, limit int) ([]NotificationDelivery, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, channel_id, submission_id, event, status, attempts, error, created_at, delivered_at
+		FROM notification_deliveries WHERE channel_id = ? ORDER BY id DESC LIMIT ?`,
+		channelID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []NotificationDelivery
+	for rows.Next() {
+		var d NotificationDelivery
+		var submissionID sql.NullInt64
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.ChannelID, &submissionID, &d.Event, &d.Status, &d.Attempts, &d.Error, &d.CreatedAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+		d.SubmissionID = submissionID.Int64
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetNotificationDeliveries is like GetNotificationDeliveriesContext but uses context.Background
+func GetNotificationDeliveries(db *sql.DB, channelID int64, limit int) ([]NotificationDelivery, error) {
+	return GetNotificationDeliveriesContext(context.Background(), db, channelID, limit)
+}
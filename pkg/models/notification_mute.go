@@ -0,0 +1,120 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// NotificationMute records a recipient muting a form's notification emails
+// with the unsubscribe link in one
+type NotificationMute struct {
+	FormID  int64     `json:"form_id"`
+	Address string    `json:"address"`
+	MutedAt time.Time `json:"muted_at"`
+}
+
+// MuteRecipientContext stops a form's notification emails going to an
+// address. Muting an address that's already muted does nothing.
+func MuteRecipientContext(ctx context.Context, db *sql.DB, formID int64, address string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	address = normalizeAddress(address)
+	var count int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notification_mutes WHERE form_id = ? AND address = ?",
+		formID, address,
+	).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO notification_mutes (form_id, address, muted_at) VALUES (?, ?, ?)",
+		formID, address, sqlTime(time.Now()),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MuteRecipient is like MuteRecipientContext but uses context.Background
+func MuteRecipient(db *sql.DB, formID int64, address string) error {
+	return MuteRecipientContext(context.Background(), db, formID, address)
+}
+
+// UnmuteRecipientContext sends a form's notification emails to a muted
+// address again
+func UnmuteRecipientContext(ctx context.Context, db *sql.DB, formID int64, address string) error {
+	_, err := db.ExecContext(ctx,
+		"DELETE FROM notification_mutes WHERE form_id = ? AND address = ?",
+		formID, normalizeAddress(address),
+	)
+	return err
+}
+
+// UnmuteRecipient is like UnmuteRecipientContext but uses context.Background
+func UnmuteRecipient(db *sql.DB, formID int64, address string) error {
+	return UnmuteRecipientContext(context.Background(), db, formID, address)
+}
+
+// GetMutedRecipientsContext retrieves the addresses that muted a form's
+// notification emails, most recent first
+func GetMutedRecipientsContext(ctx context.Context, db *sql.DB, formID int64) ([]NotificationMute, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT form_id, address, muted_at FROM notification_mutes WHERE form_id = ? ORDER BY muted_at DESC, address",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mutes []NotificationMute
+	for rows.Next() {
+		var mute NotificationMute
+		if err := rows.Scan(&mute.FormID, &mute.Address, &mute.MutedAt); err != nil {
+			return nil, err
+		}
+		mutes = append(mutes, mute)
+	}
+	return mutes, rows.Err()
+}
+
+// GetMutedRecipients is like GetMutedRecipientsContext but uses context.Background
+func GetMutedRecipients(db *sql.DB, formID int64) ([]NotificationMute, error) {
+	return GetMutedRecipientsContext(context.Background(), db, formID)
+}
+
+// UnmutedRecipientsContext returns the addresses, in order, that haven't
+// muted a form's notification emails
+func UnmutedRecipientsContext(ctx context.Context, db *sql.DB, formID int64, addresses []string) ([]string, error) {
+	mutes, err := GetMutedRecipientsContext(ctx, db, formID)
+	if err != nil {
+		return nil, err
+	}
+	if len(mutes) == 0 {
+		return addresses, nil
+	}
+	muted := make(map[string]bool, len(mutes))
+	for _, mute := range mutes {
+		muted[mute.Address] = true
+	}
+	var unmuted []string
+	for _, address := range addresses {
+		if !muted[normalizeAddress(address)] {
+			unmuted = append(unmuted, address)
+		}
+	}
+	return unmuted, nil
+}
+
+// UnmutedRecipients is like UnmutedRecipientsContext but uses context.Background
+func UnmutedRecipients(db *sql.DB, formID int64, addresses []string) ([]string, error) {
+	return UnmutedRecipientsContext(context.Background(), db, formID, addresses)
+}
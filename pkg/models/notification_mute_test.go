@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMuteRecipient(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "user@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "Contact", "example.com", "secret", "owner@example.com")
+	other := CreateTestForm(t, db, user.ID, "Quote", "example.com", "secret", "sales@example.com")
+
+	for i := 0; i < 2; i++ {
+		if err := MuteRecipient(db, form.ID, " Team@Example.com"); err != nil {
+			t.Fatalf("Failed to mute: %v", err)
+		}
+	}
+	mutes, err := GetMutedRecipients(db, form.ID)
+	if err != nil || len(mutes) != 1 || mutes[0].Address != "team@example.com" {
+		t.Fatalf("Expected the address muted once, got %v (%v)", mutes, err)
+	}
+
+	recipients := []string{"owner@example.com", "TEAM@example.com"}
+	if unmuted, err := UnmutedRecipients(db, form.ID, recipients); err != nil || fmt.Sprint(unmuted) != "[owner@example.com]" {
+		t.Errorf("Expected the muted address left out, got %v (%v)", unmuted, err)
+	}
+	if unmuted, err := UnmutedRecipients(db, other.ID, recipients); err != nil || len(unmuted) != 2 {
+		t.Errorf("Expected the mute to only cover its form, got %v (%v)", unmuted, err)
+	}
+
+	if err := UnmuteRecipient(db, form.ID, "team@example.com"); err != nil {
+		t.Fatalf("Failed to unmute: %v", err)
+	}
+	if mutes, _ := GetMutedRecipients(db, form.ID); len(mutes) != 0 {
+		t.Errorf("Expected the address unmuted, got %v", mutes)
+	}
+}
@@ -0,0 +1,86 @@
+package models
+
+import "testing"
+
+func TestNotificationChannels(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "notify@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "notify", "example.com", "secret", "to@example.com")
+
+	channel, err := CreateNotificationChannel(db, form.ID, "webhook", "Hook", map[string]string{"url": "https://example.com/hook"}, []string{"submission.created", "email.failed"})
+	if err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+	if !channel.Enabled || channel.Config["url"] != "https://example.com/hook" {
+		t.Fatalf("Expected an enabled channel with its settings, got %+v", channel)
+	}
+	if !channel.Subscribes("email.failed") || channel.Subscribes("form.updated") {
+		t.Errorf("Expected the channel's events, got %v", channel.Events)
+	}
+	other, _ := CreateNotificationChannel(db, form.ID, "slack", "Slack", map[string]string{"webhook_url": "https://hooks.slack.com/x"}, []string{"submission.created"})
+
+	if err := UpdateNotificationChannel(db, other.ID, "Team", other.Config, []string{"form.updated"}, false); err != nil {
+		t.Fatalf("Failed to update channel: %v", err)
+	}
+	all, _ := GetNotificationChannelsByFormID(db, form.ID, false)
+	enabled, _ := GetNotificationChannelsByFormID(db, form.ID, true)
+	if len(all) != 2 || all[1].Name != "Team" || all[1].Enabled || !all[1].Subscribes("form.updated") {
+		t.Fatalf("Expected both channels with the update, got %+v", all)
+	}
+	if len(enabled) != 1 || enabled[0].ID != channel.ID {
+		t.Fatalf("Expected only the enabled channel, got %+v", enabled)
+	}
+	otherUser, _ := CreateUser(db, "notify-other@example.com", "hashed_password")
+	otherForm := CreateTestForm(t, db, otherUser.ID, "other", "example.com", "secret", "to@example.com")
+	CreateNotificationChannel(db, otherForm.ID, "webhook", "Theirs", map[string]string{"url": "https://example.com/theirs"}, nil)
+	if owned, _ := GetNotificationChannelsByUserID(db, user.ID, false); len(owned) != 2 {
+		t.Errorf("Expected the channels of the user's forms only, got %+v", owned)
+	}
+
+	submission, _ := CreateSubmission(db, form.ID, "127.0.0.1", "test", []byte(`{"name":"Ann"}`))
+	first, err := CreateNotificationDelivery(db, channel.ID, submission.ID, "submission.created")
+	if err != nil {
+		t.Fatalf("Failed to create delivery: %v", err)
+	}
+	second, _ := CreateNotificationDelivery(db, channel.ID, 0, "test")
+	RecordDeliveryAttempt(db, first, DeliveryStatusPending, "timeout")
+	RecordDeliveryAttempt(db, first, DeliveryStatusSent, "")
+	RecordDeliveryAttempt(db, second, DeliveryStatusFailed, "status 500")
+
+	deliveries, err := GetNotificationDeliveries(db, channel.ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to list deliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].ID != second {
+		t.Fatalf("Expected both deliveries newest first, got %+v", deliveries)
+	}
+	if d := deliveries[1]; d.Status != DeliveryStatusSent || d.Attempts != 2 || d.Error != "" || d.DeliveredAt == nil || d.SubmissionID != submission.ID {
+		t.Errorf("Expected the delivery sent on the second attempt, got %+v", d)
+	}
+	if d := deliveries[0]; d.Status != DeliveryStatusFailed || d.Error != "status 500" || d.DeliveredAt != nil || d.SubmissionID != 0 {
+		t.Errorf("Expected the failed test delivery, got %+v", d)
+	}
+
+	if err := DeleteSubmission(db, submission.ID); err != nil {
+		t.Fatalf("Failed to delete submission: %v", err)
+	}
+	if deliveries, _ := GetNotificationDeliveries(db, channel.ID, 10); len(deliveries) != 1 {
+		t.Errorf("Expected the submission's delivery to be deleted with it, got %d", len(deliveries))
+	}
+
+	if err := DeleteNotificationChannel(db, channel.ID); err != nil {
+		t.Fatalf("Failed to delete channel: %v", err)
+	}
+	if deleted, _ := GetNotificationChannelByID(db, channel.ID); deleted != nil {
+		t.Error("Expected the channel to be deleted")
+	}
+
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	if remaining, _ := GetNotificationChannelsByFormID(db, form.ID, false); len(remaining) != 0 {
+		t.Errorf("Expected the form's channels to be deleted with it, got %d", len(remaining))
+	}
+}
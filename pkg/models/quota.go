@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// Settings holding the instance-wide default quota
+const (
+	SettingDefaultMaxForms              = "default_max_forms"
+	SettingDefaultMaxMonthlySubmissions = "default_max_monthly_submissions"
+	SettingDefaultMaxStorageMB          = "default_max_storage_mb"
+)
+
+// Quota holds the usage limits that apply to a user. A limit of 0 means
+// unlimited.
+type Quota struct {
+	MaxForms              int   `json:"max_forms"`
+	MaxMonthlySubmissions int   `json:"max_monthly_submissions"`
+	MaxStorageBytes       int64 `json:"max_storage_bytes"`
+}
+
+// QuotaOverride holds the limits an administrator has set for one user.
+// Nil limits fall back to the instance-wide default.
+type QuotaOverride struct {
+	MaxForms              *int
+	MaxMonthlySubmissions *int
+	MaxStorageBytes       *int64
+}
+
+// Usage is what a user's forms and submissions count against their quota
+type Usage struct {
+	Forms              int   `json:"forms"`
+	MonthlySubmissions int   `json:"monthly_submissions"`
+	StorageBytes       int64 `json:"storage_bytes"`
+}
+
+// FormsExceeded reports whether the user can't create another form
+func (q *Quota) FormsExceeded(u *Usage) bool {
+	return q.MaxForms > 0 && u.Forms >= q.MaxForms
+}
+
+// SubmissionsExceeded reports whether the user's forms can't accept another
+// submission this month
+func (q *Quota) SubmissionsExceeded(u *Usage) bool {
+	return q.MaxMonthlySubmissions > 0 && u.MonthlySubmissions >= q.MaxMonthlySubmissions
+}
+
+// StorageExceeded reports whether the user's stored submissions have used up
+// their storage
+func (q *Quota) StorageExceeded(u *Usage) bool {
+	return q.MaxStorageBytes > 0 && u.StorageBytes >= q.MaxStorageBytes
+}
+
+// Unlimited reports whether no limit applies
+func (q *Quota) Unlimited() bool {
+	return q.MaxForms <= 0 && q.MaxMonthlySubmissions <= 0 && q.MaxStorageBytes <= 0
+}
+
+// GetDefaultQuotaContext returns the instance-wide default quota
+func GetDefaultQuotaContext(ctx context.Context, db *sql.DB) (*Quota, error) {
+	maxForms, err := getAppSettingInt(ctx, db, SettingDefaultMaxForms)
+	if err != nil {
+		return nil, err
+	}
+	maxMonthlySubmissions, err := getAppSettingInt(ctx, db, SettingDefaultMaxMonthlySubmissions)
+	if err != nil {
+		return nil, err
+	}
+	maxStorageMB, err := getAppSettingInt(ctx, db, SettingDefaultMaxStorageMB)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Quota{
+		MaxForms:              int(maxForms),
+		MaxMonthlySubmissions: int(maxMonthlySubmissions),
+		MaxStorageBytes:       maxStorageMB * 1024 * 1024,
+	}, nil
+}
+
+// GetDefaultQuota is like GetDefaultQuotaContext but uses context.Background
+func GetDefaultQuota(db *sql.DB) (*Quota, error) {
+	return GetDefaultQuotaContext(context.Background(), db)
+}
+
+// getAppSettingInt reads a numeric setting, treating a missing or invalid
+// value as 0
+func getAppSettingInt(ctx context.Context, db *sql.DB, key string) (int64, error) {
+	value, err := GetAppSettingValueContext(ctx, db, key)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n, nil
+}
+
+// GetQuotaOverrideContext returns the limits set for a user. Users without
+// overrides get a QuotaOverride with every limit nil.
+func GetQuotaOverrideContext(ctx context.Context, db *sql.DB, userID int64) (*QuotaOverride, error) {
+	var maxForms, maxMonthlySubmissions, maxStorageBytes sql.NullInt64
+	err := db.QueryRowContext(ctx,
+		"SELECT max_forms, max_monthly_submissions, max_storage_bytes FROM user_quotas WHERE user_id = ?",
+		userID,
+	).Scan(&maxForms, &maxMonthlySubmissions, &maxStorageBytes)
+
+	var override QuotaOverride
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &override, nil
+		}
+		return nil, err
+	}
+
+	if maxForms.Valid {
+		n := int(maxForms.Int64)
+		override.MaxForms = &n
+	}
+	if maxMonthlySubmissions.Valid {
+		n := int(maxMonthlySubmissions.Int64)
+		override.MaxMonthlySubmissions = &n
+	}
+	if maxStorageBytes.Valid {
+		override.MaxStorageBytes = &maxStorageBytes.Int64
+	}
+
+	return &override, nil
+}
+
+// GetQuotaOverride is like GetQuotaOverrideContext but uses context.Background
+func GetQuotaOverride(db *sql.DB, userID int64) (*QuotaOverride, error) {
+	return GetQuotaOverrideContext(context.Background(), db, userID)
+}
+
+// SetQuotaOverrideContext replaces the limits set for a user
+func SetQuotaOverrideContext(ctx context.Context, db *sql.DB, userID int64, override QuotaOverride) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_quotas WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if override.MaxForms != nil || override.MaxMonthlySubmissions != nil || override.MaxStorageBytes != nil {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO user_quotas (user_id, max_forms, max_monthly_submissions, max_storage_bytes) VALUES (?, ?, ?, ?)",
+			userID, override.MaxForms, override.MaxMonthlySubmissions, override.MaxStorageBytes,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SetQuotaOverride is like SetQuotaOverrideContext but uses context.Background
+func SetQuotaOverride(db *sql.DB, userID int64, override QuotaOverride) error {
+	return SetQuotaOverrideContext(context.Background(), db, userID, override)
+}
+
+// GetUserQuotaContext returns the limits that apply to a user: their
+// overrides, falling back to the instance-wide defaults
+func GetUserQuotaContext(ctx context.Context, db *sql.DB, userID int64) (*Quota, error) {
+	quota, err := GetDefaultQuotaContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	override, err := GetQuotaOverrideContext(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if override.MaxForms != nil {
+		quota.MaxForms = *override.MaxForms
+	}
+	if override.MaxMonthlySubmissions != nil {
+		quota.MaxMonthlySubmissions = *override.MaxMonthlySubmissions
+	}
+	if override.MaxStorageBytes != nil {
+		quota.MaxStorageBytes = *override.MaxStorageBytes
+	}
+
+	return quota, nil
+}
+
+// GetUserQuota is like GetUserQuotaContext but uses context.Background
+func GetUserQuota(db *sql.DB, userID int64) (*Quota, error) {
+	return GetUserQuotaContext(context.Background(), db, userID)
+}
+
+// GetUsageContext counts a user's forms, their submissions in the calendar
+// month containing now (in UTC), apart from spam, and the size of their
+// stored submission data
+func GetUsageContext(ctx context.Context, db *sql.DB, userID int64, now time.Time) (*Usage, error) {
+	var usage Usage
+	if err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM forms WHERE user_id = ?",
+		userID,
+	).Scan(&usage.Forms); err != nil {
+		return nil, err
+	}
+
+	now = now.UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM submissions s JOIN forms f ON f.id = s.form_id WHERE f.user_id = ? AND s.created_at >= ? AND s.spam_at IS NULL",
+		userID, sqlTime(monthStart),
+	).Scan(&usage.MonthlySubmissions); err != nil {
+		return nil, err
+	}
+
+	if err := db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(LENGTH(s.submitted_data)), 0) FROM submissions s JOIN forms f ON f.id = s.form_id WHERE f.user_id = ?",
+		userID,
+	).Scan(&usage.StorageBytes); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// GetUsage is like GetUsageContext but uses context.Background
+func GetUsage(db *sql.DB, userID int64, now time.Time) (*Usage, error) {
+	return GetUsageContext(context.Background(), db, userID, now)
+}
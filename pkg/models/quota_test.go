@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGetUserQuota(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "quota@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// Fresh installs have no limits
+	quota, err := GetUserQuota(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get quota: %v", err)
+	}
+	if !quota.Unlimited() {
+		t.Errorf("Expected no limits by default, got %+v", quota)
+	}
+
+	if err := UpdateAppSetting(db, SettingDefaultMaxForms, "3"); err != nil {
+		t.Fatalf("Failed to update setting: %v", err)
+	}
+	if err := UpdateAppSetting(db, SettingDefaultMaxStorageMB, "2"); err != nil {
+		t.Fatalf("Failed to update setting: %v", err)
+	}
+
+	// Overrides replace only the limits they set
+	maxForms := 10
+	if err := SetQuotaOverride(db, user.ID, QuotaOverride{MaxForms: &maxForms}); err != nil {
+		t.Fatalf("Failed to set override: %v", err)
+	}
+	quota, err = GetUserQuota(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get quota: %v", err)
+	}
+	if quota.MaxForms != 10 {
+		t.Errorf("Expected overridden form limit 10, got %d", quota.MaxForms)
+	}
+	if quota.MaxStorageBytes != 2*1024*1024 {
+		t.Errorf("Expected default storage limit of 2 MB, got %d bytes", quota.MaxStorageBytes)
+	}
+
+	// Clearing every override falls back to the defaults
+	if err := SetQuotaOverride(db, user.ID, QuotaOverride{}); err != nil {
+		t.Fatalf("Failed to clear override: %v", err)
+	}
+	quota, err = GetUserQuota(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get quota: %v", err)
+	}
+	if quota.MaxForms != 3 {
+		t.Errorf("Expected default form limit 3, got %d", quota.MaxForms)
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "usage@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form, err := CreateForm(db, user.ID, "Usage Form", "example.com", "secret", "owner@example.com", "usage-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	data := json.RawMessage(`{"message":"hello"}`)
+	for i := 0; i < 3; i++ {
+		if _, err := CreateSubmission(db, form.ID, "127.0.0.1", "test", data); err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+	}
+	// One submission from last month still counts towards storage
+	now := time.Now().UTC()
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Add(-time.Hour)
+	if _, err := db.Exec("UPDATE submissions SET created_at = ? WHERE id = (SELECT MIN(id) FROM submissions)",
+		sqlTime(lastMonth)); err != nil {
+		t.Fatalf("Failed to backdate submission: %v", err)
+	}
+
+	usage, err := GetUsage(db, user.ID, now)
+	if err != nil {
+		t.Fatalf("Failed to get usage: %v", err)
+	}
+	if usage.Forms != 1 {
+		t.Errorf("Expected 1 form, got %d", usage.Forms)
+	}
+	if usage.MonthlySubmissions != 2 {
+		t.Errorf("Expected 2 submissions this month, got %d", usage.MonthlySubmissions)
+	}
+	if usage.StorageBytes != int64(3*len(data)) {
+		t.Errorf("Expected %d bytes stored, got %d", 3*len(data), usage.StorageBytes)
+	}
+
+	quota := &Quota{MaxForms: 1, MaxMonthlySubmissions: 5, MaxStorageBytes: 1024}
+	if !quota.FormsExceeded(usage) {
+		t.Error("Expected the form limit to be reached")
+	}
+	if quota.SubmissionsExceeded(usage) || quota.StorageExceeded(usage) {
+		t.Error("Expected the submission and storage limits not to be reached")
+	}
+}
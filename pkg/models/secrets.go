@@ -0,0 +1,107 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"staticsend/pkg/secrets"
+)
+
+// secretColumn is a column holding third-party credentials, which are
+// encrypted, with the column its rows are found by
+type secretColumn struct {
+	table, key, column string
+	// where, if set, limits the rows to those holding credentials
+	where string
+}
+
+// secretColumns lists the columns encrypted with the secrets key
+var secretColumns = []secretColumn{
+	{table: "forms", key: "id", column: "turnstile_secret"},
+	{table: "notification_channels", key: "id", column: "config"},
+	{table: "google_credentials", key: "user_id", column: "credentials"},
+	{table: "app_settings", key: "id", column: "value", where: `"key" IN (` + secretSettingKeys() + `)`},
+}
+
+// secretSettingKeys lists the secret settings' keys for a query. They're
+// constants, so quoting them in is safe.
+func secretSettingKeys() string {
+	keys := make([]string, 0, len(secretSettings))
+	for key := range secretSettings {
+		keys = append(keys, "'"+key+"'")
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// EncryptSecretsContext encrypts the forms' Turnstile secrets, notification
+// channels' settings, Google credentials and secret app settings saved in
+// plain text, before encryption was set up, returning how many it
+// encrypted. It does nothing without a key.
+func EncryptSecretsContext(ctx context.Context, db *sql.DB) (int, error) {
+	if !secrets.Enabled() {
+		return 0, nil
+	}
+
+	count := 0
+	for _, c := range secretColumns {
+		n, err := encryptColumn(ctx, db, c)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// EncryptSecrets is like EncryptSecretsContext but uses context.Background
+func EncryptSecrets(db *sql.DB) (int, error) {
+	return EncryptSecretsContext(context.Background(), db)
+}
+
+// encryptColumn encrypts the plain values in one column
+func encryptColumn(ctx context.Context, db *sql.DB, c secretColumn) (int, error) {
+	query := "SELECT " + c.key + ", " + c.column + " FROM " + c.table + " WHERE " + c.column + " <> ''"
+	if c.where != "" {
+		query += " AND " + c.where
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	plain := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !secrets.Sealed(stored) {
+			plain[id] = stored
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for id, value := range plain {
+		sealed, err := secrets.Seal(value)
+		if err != nil {
+			return count, err
+		}
+		// Only replace the value read, in case it changed meanwhile
+		if _, err := db.ExecContext(ctx,
+			"UPDATE "+c.table+" SET "+c.column+" = ? WHERE "+c.key+" = ? AND "+c.column+" = ?",
+			sealed, id, value,
+		); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
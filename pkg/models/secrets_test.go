@@ -0,0 +1,124 @@
+package models
+
+import (
+	"testing"
+
+	"staticsend/pkg/secrets"
+)
+
+func TestFormSecretEncryption(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+
+	user, _ := CreateUser(db, "secrets@example.com", "hashed_password")
+	// Saved before a key was set up
+	old := CreateTestForm(t, db, user.ID, "old", "example.com", "old-secret", "to@example.com")
+
+	box, _ := secrets.NewBox([]byte("master key"))
+	secrets.SetDefault(box)
+	form := CreateTestForm(t, db, user.ID, "new", "example.com", "new-secret", "to@example.com")
+	stored := func(id int64) string {
+		var value string
+		db.QueryRow("SELECT turnstile_secret FROM forms WHERE id = ?", id).Scan(&value)
+		return value
+	}
+	if !secrets.Sealed(stored(form.ID)) {
+		t.Errorf("Expected the secret to be saved encrypted, got %q", stored(form.ID))
+	}
+	if form.TurnstileSecret != "new-secret" {
+		t.Errorf("Expected forms to read the secret decrypted, got %q", form.TurnstileSecret)
+	}
+
+	if err := UpdateForm(db, form.ID, "new", "example.com", "changed-secret", "to@example.com"); err != nil {
+		t.Fatalf("Failed to update form: %v", err)
+	}
+	if updated, _ := GetFormByID(db, form.ID); updated.TurnstileSecret != "changed-secret" || !secrets.Sealed(stored(form.ID)) {
+		t.Errorf("Expected the updated secret encrypted, got %q stored as %q", updated.TurnstileSecret, stored(form.ID))
+	}
+
+	// Forms saved before are encrypted once, and still read the same
+	if n, err := EncryptSecrets(db); err != nil || n != 1 {
+		t.Fatalf("Expected one form's secret to be encrypted, got %d, %v", n, err)
+	}
+	if n, _ := EncryptSecrets(db); n != 0 {
+		t.Errorf("Expected nothing left to encrypt, got %d", n)
+	}
+	if reread, _ := GetFormByID(db, old.ID); reread.TurnstileSecret != "old-secret" || !secrets.Sealed(stored(old.ID)) {
+		t.Errorf("Expected the old secret encrypted, got %q stored as %q", reread.TurnstileSecret, stored(old.ID))
+	}
+
+	secrets.SetDefault(nil)
+	if _, err := GetFormByID(db, form.ID); err == nil {
+		t.Error("Expected reading an encrypted secret without the key to fail")
+	}
+}
+
+func TestIntegrationSecretEncryption(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+
+	user, _ := CreateUser(db, "integrations@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "contact", "example.com", "secret", "to@example.com")
+	// Saved before a key was set up
+	old, _ := CreateNotificationChannel(db, form.ID, "slack", "Old", map[string]string{"url": "https://hooks.slack.com/old"}, nil)
+	SaveEmailSettings(db, &EmailSettings{Host: "smtp.example.com", Port: 587, Password: "smtp-password"})
+
+	box, _ := secrets.NewBox([]byte("master key"))
+	secrets.SetDefault(box)
+	stored := func(query string, args ...interface{}) string {
+		var value string
+		db.QueryRow(query, args...).Scan(&value)
+		return value
+	}
+
+	channel, err := CreateNotificationChannel(db, form.ID, "webhook", "Hook", map[string]string{"url": "https://example.com/hook", "secret": "signing-secret"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+	if config := stored("SELECT config FROM notification_channels WHERE id = ?", channel.ID); !secrets.Sealed(config) {
+		t.Errorf("Expected the channel's settings encrypted, got %q", config)
+	}
+	if channel.Config["secret"] != "signing-secret" {
+		t.Errorf("Expected the channel's settings decrypted, got %v", channel.Config)
+	}
+
+	if err := SetGoogleCredentials(db, user.ID, "sheets@example.iam.gserviceaccount.com", `{"private_key": "key"}`); err != nil {
+		t.Fatalf("Failed to save credentials: %v", err)
+	}
+	if creds := stored("SELECT credentials FROM google_credentials WHERE user_id = ?", user.ID); !secrets.Sealed(creds) {
+		t.Errorf("Expected the Google credentials encrypted, got %q", creds)
+	}
+	if creds, _ := GetGoogleCredentials(db, user.ID); creds == nil || creds.Credentials != `{"private_key": "key"}` {
+		t.Errorf("Expected the Google credentials decrypted, got %+v", creds)
+	}
+
+	if err := UpdateAppSetting(db, SettingTurnstileSecretKey, "sign-in-secret"); err != nil {
+		t.Fatalf("Failed to save setting: %v", err)
+	}
+	if value := stored(`SELECT value FROM app_settings WHERE "key" = ?`, SettingTurnstileSecretKey); !secrets.Sealed(value) {
+		t.Errorf("Expected the secret setting encrypted, got %q", value)
+	}
+	if value, _ := GetAppSettingValue(db, SettingTurnstileSecretKey); value != "sign-in-secret" {
+		t.Errorf("Expected the secret setting decrypted, got %q", value)
+	}
+
+	// The form, channel and SMTP password saved before are encrypted, and
+	// other settings are left alone
+	if n, err := EncryptSecrets(db); err != nil || n != 3 {
+		t.Fatalf("Expected three secrets to be encrypted, got %d, %v", n, err)
+	}
+	if value := stored(`SELECT value FROM app_settings WHERE "key" = ?`, SettingSMTPPassword); !secrets.Sealed(value) {
+		t.Errorf("Expected the SMTP password encrypted, got %q", value)
+	}
+	if value := stored(`SELECT value FROM app_settings WHERE "key" = ?`, SettingSMTPHost); value != "smtp.example.com" {
+		t.Errorf("Expected other settings in plain text, got %q", value)
+	}
+	if settings, _ := GetEmailSettings(db); settings == nil || settings.Password != "smtp-password" {
+		t.Errorf("Expected the SMTP password decrypted, got %+v", settings)
+	}
+	if reread, _ := GetNotificationChannelByID(db, old.ID); reread.Config["url"] != "https://hooks.slack.com/old" {
+		t.Errorf("Expected the old channel's settings, got %v", reread.Config)
+	}
+}
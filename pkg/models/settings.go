@@ -1,11 +1,73 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strconv"
 	"time"
+
+	"staticsend/pkg/secrets"
+)
+
+// Settings saved by the first-run setup wizard
+const (
+	SettingBaseURL      = "base_url"
+	SettingSMTPHost     = "smtp_host"
+	SettingSMTPPort     = "smtp_port"
+	SettingSMTPUsername = "smtp_username"
+	SettingSMTPPassword = "smtp_password"
+	SettingSMTPFrom     = "smtp_from"
+	SettingSMTPUseTLS   = "smtp_use_tls"
+)
+
+// Turnstile keys for the sign in and registration pages, set on the
+// settings page in place of TURNSTILE_PUBLIC_KEY and TURNSTILE_SECRET_KEY
+const (
+	SettingTurnstilePublicKey = "turnstile_public_key"
+	SettingTurnstileSecretKey = "turnstile_secret_key"
 )
 
-// AppSetting represents an application-wide setting
+// secretSettings are the settings holding credentials, whose values are
+// encrypted
+var secretSettings = map[string]bool{
+	SettingSMTPPassword:       true,
+	SettingTurnstileSecretKey: true,
+}
+
+// sealSetting encrypts a setting's value for saving, if it's a credential
+func sealSetting(key, value string) (string, error) {
+	if !secretSettings[key] {
+		return value, nil
+	}
+	return secrets.Seal(value)
+}
+
+// openSetting decrypts a setting's saved value, if it's a credential
+func openSetting(key, stored string) (string, error) {
+	if !secretSettings[key] {
+		return stored, nil
+	}
+	value, err := secrets.Open(stored)
+	if err != nil {
+		return "", fmt.Errorf("setting %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// EmailSettings is the SMTP configuration saved in app_settings. It
+// replaces the EMAIL_* environment variables once a host is saved.
+type EmailSettings struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	UseTLS   bool
+}
+
+// AppSetting represents an application-wide setting. The key column is
+// quoted in queries because KEY is a reserved word in MySQL.
 type AppSetting struct {
 	ID          int64     `json:"id"`
 	Key         string    `json:"key"`
@@ -15,11 +77,11 @@ type AppSetting struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
-// GetAppSetting retrieves an application setting by key
-func GetAppSetting(db *sql.DB, key string) (*AppSetting, error) {
+// GetAppSettingContext retrieves an application setting by key
+func GetAppSettingContext(ctx context.Context, db *sql.DB, key string) (*AppSetting, error) {
 	var setting AppSetting
-	err := db.QueryRow(
-		"SELECT id, key, value, description, created_at, updated_at FROM app_settings WHERE key = ?",
+	err := db.QueryRowContext(ctx,
+		`SELECT id, "key", value, description, created_at, updated_at FROM app_settings WHERE "key" = ?`,
 		key,
 	).Scan(&setting.ID, &setting.Key, &setting.Value, &setting.Description, &setting.CreatedAt, &setting.UpdatedAt)
 
@@ -29,15 +91,23 @@ func GetAppSetting(db *sql.DB, key string) (*AppSetting, error) {
 		}
 		return nil, err
 	}
+	if setting.Value, err = openSetting(setting.Key, setting.Value); err != nil {
+		return nil, err
+	}
 
 	return &setting, nil
 }
 
-// GetAppSettingValue retrieves just the value of an application setting by key
-func GetAppSettingValue(db *sql.DB, key string) (string, error) {
+// GetAppSetting is like GetAppSettingContext but uses context.Background
+func GetAppSetting(db *sql.DB, key string) (*AppSetting, error) {
+	return GetAppSettingContext(context.Background(), db, key)
+}
+
+// GetAppSettingValueContext retrieves just the value of an application setting by key
+func GetAppSettingValueContext(ctx context.Context, db *sql.DB, key string) (string, error) {
 	var value string
-	err := db.QueryRow(
-		"SELECT value FROM app_settings WHERE key = ?",
+	err := db.QueryRowContext(ctx,
+		`SELECT value FROM app_settings WHERE "key" = ?`,
 		key,
 	).Scan(&value)
 
@@ -48,12 +118,17 @@ func GetAppSettingValue(db *sql.DB, key string) (string, error) {
 		return "", err
 	}
 
-	return value, nil
+	return openSetting(key, value)
 }
 
-// GetAppSettingBool retrieves a boolean application setting by key
-func GetAppSettingBool(db *sql.DB, key string) (bool, error) {
-	value, err := GetAppSettingValue(db, key)
+// GetAppSettingValue is like GetAppSettingValueContext but uses context.Background
+func GetAppSettingValue(db *sql.DB, key string) (string, error) {
+	return GetAppSettingValueContext(context.Background(), db, key)
+}
+
+// GetAppSettingBoolContext retrieves a boolean application setting by key
+func GetAppSettingBoolContext(ctx context.Context, db *sql.DB, key string) (bool, error) {
+	value, err := GetAppSettingValueContext(ctx, db, key)
 	if err != nil {
 		return false, err
 	}
@@ -61,19 +136,33 @@ func GetAppSettingBool(db *sql.DB, key string) (bool, error) {
 	return value == "true", nil
 }
 
-// UpdateAppSetting updates an application setting
-func UpdateAppSetting(db *sql.DB, key, value string) error {
-	_, err := db.Exec(
-		"UPDATE app_settings SET value = ?, updated_at = CURRENT_TIMESTAMP WHERE key = ?",
+// GetAppSettingBool is like GetAppSettingBoolContext but uses context.Background
+func GetAppSettingBool(db *sql.DB, key string) (bool, error) {
+	return GetAppSettingBoolContext(context.Background(), db, key)
+}
+
+// UpdateAppSettingContext updates an application setting
+func UpdateAppSettingContext(ctx context.Context, db *sql.DB, key, value string) error {
+	value, err := sealSetting(key, value)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`UPDATE app_settings SET value = ?, updated_at = CURRENT_TIMESTAMP WHERE "key" = ?`,
 		value, key,
 	)
 	return err
 }
 
-// GetAllAppSettings retrieves all application settings
-func GetAllAppSettings(db *sql.DB) ([]AppSetting, error) {
-	rows, err := db.Query(
-		"SELECT id, key, value, description, created_at, updated_at FROM app_settings ORDER BY key",
+// UpdateAppSetting is like UpdateAppSettingContext but uses context.Background
+func UpdateAppSetting(db *sql.DB, key, value string) error {
+	return UpdateAppSettingContext(context.Background(), db, key, value)
+}
+
+// GetAllAppSettingsContext retrieves all application settings
+func GetAllAppSettingsContext(ctx context.Context, db *sql.DB) ([]AppSetting, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, "key", value, description, created_at, updated_at FROM app_settings ORDER BY "key"`,
 	)
 	if err != nil {
 		return nil, err
@@ -86,13 +175,106 @@ func GetAllAppSettings(db *sql.DB) ([]AppSetting, error) {
 		if err := rows.Scan(&setting.ID, &setting.Key, &setting.Value, &setting.Description, &setting.CreatedAt, &setting.UpdatedAt); err != nil {
 			return nil, err
 		}
+		value, err := openSetting(setting.Key, setting.Value)
+		if err != nil {
+			return nil, err
+		}
+		setting.Value = value
 		settings = append(settings, setting)
 	}
 
 	return settings, nil
 }
 
-// IsRegistrationEnabled checks if user registration is enabled
+// GetAllAppSettings is like GetAllAppSettingsContext but uses context.Background
+func GetAllAppSettings(db *sql.DB) ([]AppSetting, error) {
+	return GetAllAppSettingsContext(context.Background(), db)
+}
+
+// IsRegistrationEnabledContext checks if user registration is enabled
+func IsRegistrationEnabledContext(ctx context.Context, db *sql.DB) (bool, error) {
+	return GetAppSettingBoolContext(ctx, db, "registration_enabled")
+}
+
+// IsRegistrationEnabled is like IsRegistrationEnabledContext but uses context.Background
 func IsRegistrationEnabled(db *sql.DB) (bool, error) {
-	return GetAppSettingBool(db, "registration_enabled")
-}
\ No newline at end of file
+	return IsRegistrationEnabledContext(context.Background(), db)
+}
+
+// IsMaintenanceModeContext checks if the site is in maintenance mode
+func IsMaintenanceModeContext(ctx context.Context, db *sql.DB) (bool, error) {
+	return GetAppSettingBoolContext(ctx, db, "maintenance_mode")
+}
+
+// IsMaintenanceMode is like IsMaintenanceModeContext but uses context.Background
+func IsMaintenanceMode(db *sql.DB) (bool, error) {
+	return IsMaintenanceModeContext(context.Background(), db)
+}
+
+// GetEmailSettingsContext returns the saved SMTP configuration, or nil if
+// no SMTP host has been saved
+func GetEmailSettingsContext(ctx context.Context, db *sql.DB) (*EmailSettings, error) {
+	values := make(map[string]string)
+	for _, key := range []string{SettingSMTPHost, SettingSMTPPort, SettingSMTPUsername, SettingSMTPPassword, SettingSMTPFrom, SettingSMTPUseTLS} {
+		value, err := GetAppSettingValueContext(ctx, db, key)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	if values[SettingSMTPHost] == "" {
+		return nil, nil
+	}
+
+	port, _ := strconv.Atoi(values[SettingSMTPPort])
+	return &EmailSettings{
+		Host:     values[SettingSMTPHost],
+		Port:     port,
+		Username: values[SettingSMTPUsername],
+		Password: values[SettingSMTPPassword],
+		From:     values[SettingSMTPFrom],
+		UseTLS:   values[SettingSMTPUseTLS] == "true",
+	}, nil
+}
+
+// GetEmailSettings is like GetEmailSettingsContext but uses context.Background
+func GetEmailSettings(db *sql.DB) (*EmailSettings, error) {
+	return GetEmailSettingsContext(context.Background(), db)
+}
+
+// SaveEmailSettingsContext saves an SMTP configuration in one transaction
+func SaveEmailSettingsContext(ctx context.Context, db *sql.DB, settings *EmailSettings) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	values := map[string]string{
+		SettingSMTPHost:     settings.Host,
+		SettingSMTPPort:     strconv.Itoa(settings.Port),
+		SettingSMTPUsername: settings.Username,
+		SettingSMTPPassword: settings.Password,
+		SettingSMTPFrom:     settings.From,
+		SettingSMTPUseTLS:   strconv.FormatBool(settings.UseTLS),
+	}
+	for key, value := range values {
+		value, err := sealSetting(key, value)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE app_settings SET value = ?, updated_at = CURRENT_TIMESTAMP WHERE "key" = ?`,
+			value, key,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveEmailSettings is like SaveEmailSettingsContext but uses context.Background
+func SaveEmailSettings(db *sql.DB, settings *EmailSettings) error {
+	return SaveEmailSettingsContext(context.Background(), db, settings)
+}
@@ -150,4 +150,51 @@ func TestAppSettings(t *testing.T) {
 			}
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	enabled, err := IsMaintenanceMode(db)
+	if err != nil {
+		t.Fatalf("Failed to check maintenance mode: %v", err)
+	}
+	if enabled {
+		t.Error("Expected maintenance mode to be disabled by default")
+	}
+
+	if err := UpdateAppSetting(db, "maintenance_mode", "true"); err != nil {
+		t.Fatalf("Failed to enable maintenance mode: %v", err)
+	}
+
+	enabled, err = IsMaintenanceMode(db)
+	if err != nil {
+		t.Fatalf("Failed to check maintenance mode: %v", err)
+	}
+	if !enabled {
+		t.Error("Expected maintenance mode to be enabled")
+	}
+}
+
+func TestEmailSettings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	settings, err := GetEmailSettings(db)
+	if err != nil || settings != nil {
+		t.Fatalf("Expected no email settings before any are saved, got %+v (err %v)", settings, err)
+	}
+
+	saved := &EmailSettings{Host: "smtp.example.com", Port: 2525, Username: "mailer", Password: "secret", From: "forms@example.com", UseTLS: false}
+	if err := SaveEmailSettings(db, saved); err != nil {
+		t.Fatalf("Failed to save email settings: %v", err)
+	}
+	settings, err = GetEmailSettings(db)
+	if err != nil {
+		t.Fatalf("Failed to get email settings: %v", err)
+	}
+	if settings == nil || *settings != *saved {
+		t.Errorf("Expected %+v, got %+v", saved, settings)
+	}
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"staticsend/pkg/secrets"
+)
+
+// GoogleCredentials is a user's Google service account key, which writes
+// to the spreadsheets they share with its address
+type GoogleCredentials struct {
+	UserID      int64  `json:"user_id"`
+	ClientEmail string `json:"client_email"`
+	// Credentials is the key file as downloaded from Google Cloud
+	Credentials string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SheetColumn maps a submitted field, or a property of the submission such
+// as @created_at, to a column of a Google Sheet
+type SheetColumn struct {
+	Header string `json:"header"`
+	Field  string `json:"field"`
+}
+
+// FormSheet is the Google Sheet a form's submissions are appended to
+type FormSheet struct {
+	FormID        int64         `json:"form_id"`
+	SpreadsheetID string        `json:"spreadsheet_id"`
+	SheetName     string        `json:"sheet_name"`
+	Columns       []SheetColumn `json:"columns"`
+	// LastSubmissionID is the newest submission appended so far
+	LastSubmissionID int64 `json:"last_submission_id"`
+	// HeaderWritten is set once the row of column headers is appended
+	HeaderWritten bool       `json:"header_written"`
+	SyncedAt      *time.Time `json:"synced_at"`
+	// Error is why the last sync failed, empty once one succeeds
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetGoogleCredentialsContext returns a user's service account key, or nil
+// if they haven't added one
+func GetGoogleCredentialsContext(ctx context.Context, db *sql.DB, userID int64) (*GoogleCredentials, error) {
+	var creds GoogleCredentials
+	err := db.QueryRowContext(ctx,
+		"SELECT user_id, client_email, credentials, created_at FROM google_credentials WHERE user_id = ?",
+		userID,
+	).Scan(&creds.UserID, &creds.ClientEmail, &creds.Credentials, &creds.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if creds.Credentials, err = secrets.Open(creds.Credentials); err != nil {
+		return nil, fmt.Errorf("google credentials of user %d: %w", userID, err)
+	}
+	return &creds, nil
+}
+
+// GetGoogleCredentials is like GetGoogleCredentialsContext but uses context.Background
+func GetGoogleCredentials(db *sql.DB, userID int64) (*GoogleCredentials, error) {
+	return GetGoogleCredentialsContext(context.Background(), db, userID)
+}
+
+// SetGoogleCredentialsContext replaces a user's service account key
+func SetGoogleCredentialsContext(ctx context.Context, db *sql.DB, userID int64, clientEmail, credentials string) error {
+	sealed, err := secrets.Seal(credentials)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM google_credentials WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO google_credentials (user_id, client_email, credentials) VALUES (?, ?, ?)",
+		userID, clientEmail, sealed,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetGoogleCredentials is like SetGoogleCredentialsContext but uses context.Background
+func SetGoogleCredentials(db *sql.DB, userID int64, clientEmail, credentials string) error {
+	return SetGoogleCredentialsContext(context.Background(), db, userID, clientEmail, credentials)
+}
+
+// DeleteGoogleCredentialsContext removes a user's service account key
+func DeleteGoogleCredentialsContext(ctx context.Context, db *sql.DB, userID int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM google_credentials WHERE user_id = ?", userID)
+	return err
+}
+
+// DeleteGoogleCredentials is like DeleteGoogleCredentialsContext but uses context.Background
+func DeleteGoogleCredentials(db *sql.DB, userID int64) error {
+	return DeleteGoogleCredentialsContext(context.Background(), db, userID)
+}
+
+const formSheetColumns = "form_id, spreadsheet_id, sheet_name, mapping, last_submission_id, header_written, synced_at, error, created_at FROM form_sheets"
+
+// scanFormSheet reads a form sheet row, returning nil if there isn't one
+func scanFormSheet(row rowScanner) (*FormSheet, error) {
+	var sheet FormSheet
+	var mapping string
+	var syncedAt sql.NullTime
+	err := row.Scan(&sheet.FormID, &sheet.SpreadsheetID, &sheet.SheetName, &mapping, &sheet.LastSubmissionID, &sheet.HeaderWritten, &syncedAt, &sheet.Error, &sheet.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(mapping), &sheet.Columns); err != nil {
+		sheet.Columns = nil
+	}
+	if syncedAt.Valid {
+		sheet.SyncedAt = &syncedAt.Time
+	}
+	return &sheet, nil
+}
+
+// GetFormSheetContext returns the sheet a form syncs to, or nil if it
+// doesn't sync to one
+func GetFormSheetContext(ctx context.Context, db *sql.DB, formID int64) (*FormSheet, error) {
+	return scanFormSheet(db.QueryRowContext(ctx, "SELECT "+formSheetColumns+" WHERE form_id = ?", formID))
+}
+
+// GetFormSheet is like GetFormSheetContext but uses context.Background
+func GetFormSheet(db *sql.DB, formID int64) (*FormSheet, error) {
+	return GetFormSheetContext(context.Background(), db, formID)
+}
+
+// GetFormSheetsContext returns every form's sheet
+func GetFormSheetsContext(ctx context.Context, db *sql.DB) ([]FormSheet, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+formSheetColumns+" ORDER BY form_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sheets []FormSheet
+	for rows.Next() {
+		sheet, err := scanFormSheet(rows)
+		if err != nil {
+			return nil, err
+		}
+		sheets = append(sheets, *sheet)
+	}
+	return sheets, rows.Err()
+}
+
+// GetFormSheets is like GetFormSheetsContext but uses context.Background
+func GetFormSheets(db *sql.DB) ([]FormSheet, error) {
+	return GetFormSheetsContext(context.Background(), db)
+}
+
+// SaveFormSheetContext replaces the sheet a form syncs to, including how
+// far its sync has got
+func SaveFormSheetContext(ctx context.Context, db *sql.DB, sheet *FormSheet) error {
+	mapping, err := json.Marshal(sheet.Columns)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM form_sheets WHERE form_id = ?", sheet.FormID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO form_sheets (form_id, spreadsheet_id, sheet_name, mapping, last_submission_id, header_written) VALUES (?, ?, ?, ?, ?, ?)",
+		sheet.FormID, sheet.SpreadsheetID, sheet.SheetName, string(mapping), sheet.LastSubmissionID, sheet.HeaderWritten,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveFormSheet is like SaveFormSheetContext but uses context.Background
+func SaveFormSheet(db *sql.DB, sheet *FormSheet) error {
+	return SaveFormSheetContext(context.Background(), db, sheet)
+}
+
+// DeleteFormSheetContext stops a form syncing to its sheet
+func DeleteFormSheetContext(ctx context.Context, db *sql.DB, formID int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM form_sheets WHERE form_id = ?", formID)
+	return err
+}
+
+// DeleteFormSheet is like DeleteFormSheetContext but uses context.Background
+func DeleteFormSheet(db *sql.DB, formID int64) error {
+	return DeleteFormSheetContext(context.Background(), db, formID)
+}
+
+// RecordSheetSyncContext records a successful sync of a form's sheet up to
+// lastSubmissionID, clearing any error
+func RecordSheetSyncContext(ctx context.Context, db *sql.DB, formID, lastSubmissionID int64, headerWritten bool) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE form_sheets SET last_submission_id = ?, header_written = ?, synced_at = ?, error = '' WHERE form_id = ?",
+		lastSubmissionID, headerWritten, sqlTime(time.Now()), formID,
+	)
+	return err
+}
+
+// RecordSheetSync is like RecordSheetSyncContext but uses context.Background
+func RecordSheetSync(db *sql.DB, formID, lastSubmissionID int64, headerWritten bool) error {
+	return RecordSheetSyncContext(context.Background(), db, formID, lastSubmissionID, headerWritten)
+}
+
+// FailSheetSyncContext records why a sync of a form's sheet failed
+func FailSheetSyncContext(ctx context.Context, db *sql.DB, formID int64, message string) error {
+	_, err := db.ExecContext(ctx, "UPDATE form_sheets SET error = ? WHERE form_id = ?", message, formID)
+	return err
+}
+
+// FailSheetSync is like FailSheetSyncContext but uses context.Background
+func FailSheetSync(db *sql.DB, formID int64, message string) error {
+	return FailSheetSyncContext(context.Background(), db, formID, message)
+}
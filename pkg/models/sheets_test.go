@@ -0,0 +1,72 @@
+package models
+
+import "testing"
+
+func TestFormSheets(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "sheets@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "sheets", "example.com", "secret", "to@example.com")
+
+	if creds, err := GetGoogleCredentials(db, user.ID); err != nil || creds != nil {
+		t.Fatalf("Expected no key, got %+v, %v", creds, err)
+	}
+	SetGoogleCredentials(db, user.ID, "old@project.iam.gserviceaccount.com", `{"old":true}`)
+	if err := SetGoogleCredentials(db, user.ID, "sync@project.iam.gserviceaccount.com", `{"type":"service_account"}`); err != nil {
+		t.Fatalf("Failed to save key: %v", err)
+	}
+	creds, _ := GetGoogleCredentials(db, user.ID)
+	if creds == nil || creds.ClientEmail != "sync@project.iam.gserviceaccount.com" || creds.Credentials != `{"type":"service_account"}` {
+		t.Fatalf("Expected the replacement key, got %+v", creds)
+	}
+
+	sheet := &FormSheet{
+		FormID:        form.ID,
+		SpreadsheetID: "abc123",
+		SheetName:     "Leads",
+		Columns:       []SheetColumn{{Header: "Submitted", Field: "@created_at"}, {Header: "Name", Field: "name"}},
+	}
+	if err := SaveFormSheet(db, sheet); err != nil {
+		t.Fatalf("Failed to save sheet: %v", err)
+	}
+	saved, err := GetFormSheet(db, form.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to fetch sheet: %v", err)
+	}
+	if saved.SpreadsheetID != "abc123" || saved.SheetName != "Leads" || len(saved.Columns) != 2 || saved.Columns[1].Field != "name" {
+		t.Errorf("Expected the saved sheet, got %+v", saved)
+	}
+	if saved.HeaderWritten || saved.LastSubmissionID != 0 || saved.SyncedAt != nil {
+		t.Errorf("Expected an unsynced sheet, got %+v", saved)
+	}
+
+	FailSheetSync(db, form.ID, "permission denied")
+	if failed, _ := GetFormSheet(db, form.ID); failed.Error != "permission denied" {
+		t.Errorf("Expected the error to be recorded, got %q", failed.Error)
+	}
+	if err := RecordSheetSync(db, form.ID, 42, true); err != nil {
+		t.Fatalf("Failed to record sync: %v", err)
+	}
+	synced, _ := GetFormSheet(db, form.ID)
+	if synced.LastSubmissionID != 42 || !synced.HeaderWritten || synced.SyncedAt == nil || synced.Error != "" {
+		t.Errorf("Expected the sync to be recorded, got %+v", synced)
+	}
+
+	if all, _ := GetFormSheets(db); len(all) != 1 {
+		t.Errorf("Expected one sheet, got %d", len(all))
+	}
+
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	if deleted, _ := GetFormSheet(db, form.ID); deleted != nil {
+		t.Error("Expected the sheet to be deleted with the form")
+	}
+	if err := DeleteGoogleCredentials(db, user.ID); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if creds, _ := GetGoogleCredentials(db, user.ID); creds != nil {
+		t.Error("Expected the key to be deleted")
+	}
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Reasons a submission is held as spam
+const (
+	SpamReasonHoneypot  = "honeypot"
+	SpamReasonBlocklist = "blocklist"
+	SpamReasonManual    = "manual"
+	SpamReasonAkismet   = "akismet"
+	// SpamReasonTurnstileUnavailable holds submissions received while
+	// Turnstile couldn't be reached, for forms that accept them anyway
+	SpamReasonTurnstileUnavailable = "turnstile-unavailable"
+	// SpamReasonScore holds submissions whose combined spam score reached
+	// the form's flag threshold
+	SpamReasonScore = "score"
+)
+
+// SettingSpamBlocklist holds the terms that hold a submission as spam
+const SettingSpamBlocklist = "spam_blocklist"
+
+// ParseSpamBlocklist splits the spam blocklist setting into lowercase
+// terms, one per line or separated by commas
+func ParseSpamBlocklist(value string) []string {
+	terms := []string{}
+	for _, term := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// MatchSpamBlocklist returns the first blocklist term found in any of the
+// submitted values, ignoring case, or "" if none is
+func MatchSpamBlocklist(terms []string, values map[string]string) string {
+	for _, value := range values {
+		value = strings.ToLower(value)
+		for _, term := range terms {
+			if strings.Contains(value, term) {
+				return term
+			}
+		}
+	}
+	return ""
+}
+
+// CreateSpamSubmissionContext saves a submission held as spam. It stays
+// pending, as its notification email isn't sent unless it's marked as not
+// spam.
+func CreateSpamSubmissionContext(ctx context.Context, db *sql.DB, formID int64, ipAddress, userAgent, referrer string, submittedData json.RawMessage, reason string) (*Submission, error) {
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO submissions (form_id, ip_address, user_agent, referrer, submitted_data, spam_at, spam_reason) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		formID, ipAddress, userAgent, referrer, string(submittedData), sqlTime(time.Now()), reason,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetSubmissionByIDContext(ctx, db, id)
+}
+
+// CreateSpamSubmission is like CreateSpamSubmissionContext but uses context.Background
+func CreateSpamSubmission(db *sql.DB, formID int64, ipAddress, userAgent, referrer string, submittedData json.RawMessage, reason string) (*Submission, error) {
+	return CreateSpamSubmissionContext(context.Background(), db, formID, ipAddress, userAgent, referrer, submittedData, reason)
+}
+
+// SetSubmissionSpamScoreContext records how likely a submission is to be
+// spam, from 0 to 100
+func SetSubmissionSpamScoreContext(ctx context.Context, db *sql.DB, id int64, score int) error {
+	_, err := db.ExecContext(ctx, "UPDATE submissions SET spam_score = ? WHERE id = ?", score, id)
+	return err
+}
+
+// SetSubmissionSpamScore is like SetSubmissionSpamScoreContext but uses context.Background
+func SetSubmissionSpamScore(db *sql.DB, id int64, score int) error {
+	return SetSubmissionSpamScoreContext(context.Background(), db, id, score)
+}
+
+// SetSubmissionSpamSignalsContext records a submission's combined spam
+// score, from 0 to 100, along with the signals it was worked out from
+func SetSubmissionSpamSignalsContext(ctx context.Context, db *sql.DB, id int64, score int, signals json.RawMessage) error {
+	_, err := db.ExecContext(ctx, "UPDATE submissions SET spam_score = ?, spam_signals = ? WHERE id = ?", score, string(signals), id)
+	return err
+}
+
+// SetSubmissionSpamSignals is like SetSubmissionSpamSignalsContext but uses context.Background
+func SetSubmissionSpamSignals(db *sql.DB, id int64, score int, signals json.RawMessage) error {
+	return SetSubmissionSpamSignalsContext(context.Background(), db, id, score, signals)
+}
+
+// CountRecentSubmissionsByIPContext counts the submissions to a form from
+// an IP address since a time
+func CountRecentSubmissionsByIPContext(ctx context.Context, db *sql.DB, formID int64, ipAddress string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM submissions WHERE form_id = ? AND ip_address = ? AND created_at >= ?",
+		formID, ipAddress, sqlTime(since),
+	).Scan(&count)
+	return count, err
+}
+
+// CountRecentSubmissionsByIP is like CountRecentSubmissionsByIPContext but uses context.Background
+func CountRecentSubmissionsByIP(db *sql.DB, formID int64, ipAddress string, since time.Time) (int, error) {
+	return CountRecentSubmissionsByIPContext(context.Background(), db, formID, ipAddress, since)
+}
+
+// CountSpamSubmissionsByIPContext counts the submissions to any form from
+// an IP address that were held as spam since a time
+func CountSpamSubmissionsByIPContext(ctx context.Context, db *sql.DB, ipAddress string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM submissions WHERE ip_address = ? AND spam_at IS NOT NULL AND created_at >= ?",
+		ipAddress, sqlTime(since),
+	).Scan(&count)
+	return count, err
+}
+
+// CountSpamSubmissionsByIP is like CountSpamSubmissionsByIPContext but uses context.Background
+func CountSpamSubmissionsByIP(db *sql.DB, ipAddress string, since time.Time) (int, error) {
+	return CountSpamSubmissionsByIPContext(context.Background(), db, ipAddress, since)
+}
+
+// DeleteSpamSubmissionsContext deletes all of a form's submissions marked as
+// spam, along with their email records, notes and assignments, returning
+// how many were deleted
+func DeleteSpamSubmissionsContext(ctx context.Context, db *sql.DB, formID int64) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range submissionChildTables {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM "+table+" WHERE submission_id IN (SELECT id FROM submissions WHERE form_id = ? AND spam_at IS NOT NULL)",
+			formID,
+		); err != nil {
+			return 0, err
+		}
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM submissions WHERE form_id = ? AND spam_at IS NOT NULL", formID)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
+
+// DeleteSpamSubmissions is like DeleteSpamSubmissionsContext but uses context.Background
+func DeleteSpamSubmissions(db *sql.DB, formID int64) (int64, error) {
+	return DeleteSpamSubmissionsContext(context.Background(), db, formID)
+}
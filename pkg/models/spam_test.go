@@ -0,0 +1,151 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSpamBlocklist(t *testing.T) {
+	terms := ParseSpamBlocklist("Casino,  cheap pills\n\nspam@example.com ,")
+	if want := []string{"casino", "cheap pills", "spam@example.com"}; !reflect.DeepEqual(terms, want) {
+		t.Fatalf("Expected %v, got %v", want, terms)
+	}
+
+	if term := MatchSpamBlocklist(terms, map[string]string{"message": "Visit our CASINO today"}); term != "casino" {
+		t.Errorf("Expected casino to match, got %q", term)
+	}
+	if term := MatchSpamBlocklist(terms, map[string]string{"message": "Hello there"}); term != "" {
+		t.Errorf("Expected no match, got %q", term)
+	}
+	if term := MatchSpamBlocklist(nil, map[string]string{"message": "casino"}); term != "" {
+		t.Errorf("Expected an empty blocklist to match nothing, got %q", term)
+	}
+}
+
+func TestSpamQueue(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "spam@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "spam", "example.com", "secret", "to@example.com")
+
+	ham, _ := CreateSubmission(db, form.ID, "203.0.113.1", "Browser", []byte(`{"message":"hello"}`))
+	held, err := CreateSpamSubmission(db, form.ID, "203.0.113.2", "Bot", "", []byte(`{"_gotcha":"x"}`), SpamReasonHoneypot)
+	if err != nil {
+		t.Fatalf("Failed to create spam submission: %v", err)
+	}
+	if held.SpamAt == nil || held.SpamReason != SpamReasonHoneypot || held.Status != "pending" {
+		t.Fatalf("Expected a pending submission held by the honeypot, got %+v", held)
+	}
+
+	// Spam is listed apart from the other submissions
+	listed, _ := GetSubmissionsPage(db, form.ID, SubmissionFilter{}, 10, 0)
+	if len(listed) != 1 || listed[0].ID != ham.ID {
+		t.Errorf("Expected only the real submission to be listed, got %d", len(listed))
+	}
+	if count, _ := CountSubmissions(db, form.ID, SubmissionFilter{Spam: true}); count != 1 {
+		t.Errorf("Expected 1 spam submission, got %d", count)
+	}
+
+	// Marking a submission as spam by hand moves it to the queue
+	if err := SetSubmissionSpam(db, ham.ID, true); err != nil {
+		t.Fatalf("Failed to mark spam: %v", err)
+	}
+	marked, _ := GetSubmissionByID(db, ham.ID)
+	if marked.SpamReason != SpamReasonManual {
+		t.Errorf("Expected a manual spam reason, got %q", marked.SpamReason)
+	}
+	SetSubmissionSpam(db, ham.ID, false)
+	cleared, _ := GetSubmissionByID(db, ham.ID)
+	if cleared.SpamAt != nil || cleared.SpamReason != "" {
+		t.Errorf("Expected the spam mark to be cleared, got %+v", cleared)
+	}
+
+	CreateSubmissionNote(db, held.ID, user.ID, "Looks like a bot")
+	deleted, err := DeleteSpamSubmissions(db, form.ID)
+	if err != nil || deleted != 1 {
+		t.Fatalf("Expected 1 spam submission deleted, got %d (err %v)", deleted, err)
+	}
+	if remaining, _ := GetSubmissionByID(db, ham.ID); remaining == nil {
+		t.Error("Expected the real submission to be kept")
+	}
+	if gone, _ := GetSubmissionByID(db, held.ID); gone != nil {
+		t.Error("Expected the spam to be deleted")
+	}
+}
+
+func TestSpamScoreAndAkismet(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "akismet@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "akismet", "example.com", "secret", "to@example.com")
+	if form.AkismetEnabled {
+		t.Fatal("Expected Akismet to be off for new forms")
+	}
+	if err := SetFormAkismet(db, form.ID, true); err != nil {
+		t.Fatalf("Failed to turn on Akismet: %v", err)
+	}
+	if updated, _ := GetFormByID(db, form.ID); !updated.AkismetEnabled {
+		t.Error("Expected Akismet to be on")
+	}
+
+	submission, _ := CreateSubmission(db, form.ID, "203.0.113.1", "Browser", []byte(`{"message":"hello"}`))
+	if submission.SpamScore != nil {
+		t.Errorf("Expected no score until one is recorded, got %d", *submission.SpamScore)
+	}
+	if err := SetSubmissionSpamScore(db, submission.ID, 80); err != nil {
+		t.Fatalf("Failed to record score: %v", err)
+	}
+	if scored, _ := GetSubmissionByID(db, submission.ID); scored.SpamScore == nil || *scored.SpamScore != 80 {
+		t.Errorf("Expected a score of 80, got %v", scored.SpamScore)
+	}
+}
+
+func TestSpamSignalsAndThresholds(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "signals@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "signals", "example.com", "secret", "to@example.com")
+	if form.SpamFlagThreshold != 80 || form.SpamRejectThreshold != 0 {
+		t.Errorf("Expected thresholds of 80 and off for new forms, got %d and %d", form.SpamFlagThreshold, form.SpamRejectThreshold)
+	}
+	if err := SetFormSpamThresholds(db, form.ID, 50, 90); err != nil {
+		t.Fatalf("Failed to set thresholds: %v", err)
+	}
+	if updated, _ := GetFormByID(db, form.ID); updated.SpamFlagThreshold != 50 || updated.SpamRejectThreshold != 90 {
+		t.Errorf("Expected thresholds of 50 and 90, got %d and %d", updated.SpamFlagThreshold, updated.SpamRejectThreshold)
+	}
+	if err := SetFormSpamThresholds(db, form.ID, 101, 0); err == nil {
+		t.Error("Expected a threshold over 100 to be refused")
+	}
+
+	submission, _ := CreateSubmission(db, form.ID, "203.0.113.1", "Browser", []byte(`{"message":"hello"}`))
+	if err := SetSubmissionSpamSignals(db, submission.ID, 35, []byte(`{"recent_submissions":3}`)); err != nil {
+		t.Fatalf("Failed to record signals: %v", err)
+	}
+	scored, _ := GetSubmissionByID(db, submission.ID)
+	if scored.SpamScore == nil || *scored.SpamScore != 35 || string(scored.SpamSignals) != `{"recent_submissions":3}` {
+		t.Errorf("Expected a score of 35 with its signals, got %v %s", scored.SpamScore, scored.SpamSignals)
+	}
+
+	CreateSpamSubmission(db, form.ID, "203.0.113.1", "Browser", "", []byte(`{}`), SpamReasonManual)
+	CreateSubmission(db, form.ID, "203.0.113.2", "Browser", []byte(`{}`))
+	CreateBlockedAttempt(db, &form.ID, nil, "203.0.113.1", "spam score 90")
+
+	since := time.Now().Add(-time.Hour)
+	if count, err := CountRecentSubmissionsByIP(db, form.ID, "203.0.113.1", since); err != nil || count != 2 {
+		t.Errorf("Expected 2 recent submissions, got %d (%v)", count, err)
+	}
+	if count, err := CountSpamSubmissionsByIP(db, "203.0.113.1", since); err != nil || count != 1 {
+		t.Errorf("Expected 1 spam submission, got %d (%v)", count, err)
+	}
+	if count, err := CountBlockedAttemptsByIP(db, "203.0.113.1", since); err != nil || count != 1 {
+		t.Errorf("Expected 1 blocked attempt, got %d (%v)", count, err)
+	}
+	if count, _ := CountRecentSubmissionsByIP(db, form.ID, "203.0.113.1", time.Now().Add(time.Hour)); count != 0 {
+		t.Errorf("Expected no submissions after now, got %d", count)
+	}
+}
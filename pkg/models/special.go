@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SpecialFields names the submission fields that control how a submission
+// is handled rather than being part of it. The defaults match Formspree's,
+// so forms moved from it keep working unchanged.
+type SpecialFields struct {
+	// Subject replaces the subject of the submission's email
+	Subject string
+	// ReplyTo is the address replies to the submission's email go to
+	ReplyTo string
+	// CC lists more addresses to send the submission's email to
+	CC string
+	// Next is the page the sender is redirected to after submitting
+	Next string
+	// Honeypot is the hidden field that real visitors leave empty
+	Honeypot string
+}
+
+// DefaultSpecialFields are the special fields' names unless configured
+var DefaultSpecialFields = SpecialFields{
+	Subject:  "_subject",
+	ReplyTo:  "_replyto",
+	CC:       "_cc",
+	Next:     "_next",
+	Honeypot: HoneypotField,
+}
+
+// ParseSpecialFields renames special fields from "role=name" entries, such
+// as "next=_redirect", starting from the defaults. The roles are subject,
+// replyto, cc, next and honeypot.
+func ParseSpecialFields(entries []string) (SpecialFields, error) {
+	fields := DefaultSpecialFields
+	roles := map[string]*string{
+		"subject":  &fields.Subject,
+		"replyto":  &fields.ReplyTo,
+		"cc":       &fields.CC,
+		"next":     &fields.Next,
+		"honeypot": &fields.Honeypot,
+	}
+	for _, entry := range entries {
+		role, name, ok := strings.Cut(entry, "=")
+		role, name = strings.ToLower(strings.TrimSpace(role)), strings.TrimSpace(name)
+		field, known := roles[role]
+		if !ok || !known {
+			return fields, fmt.Errorf("%q isn't a special field, such as next=_redirect", entry)
+		}
+		if name == "" {
+			return fields, fmt.Errorf("%q doesn't name the %s field", entry, role)
+		}
+		*field = name
+	}
+
+	seen := map[string]bool{}
+	for _, name := range []string{fields.Subject, fields.ReplyTo, fields.CC, fields.Next, fields.Honeypot} {
+		if seen[name] {
+			return fields, fmt.Errorf("%q is used for more than one special field", name)
+		}
+		seen[name] = true
+	}
+	return fields, nil
+}
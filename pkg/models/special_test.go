@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestParseSpecialFields(t *testing.T) {
+	fields, err := ParseSpecialFields(nil)
+	if err != nil || fields != DefaultSpecialFields {
+		t.Errorf("Expected the defaults, got %+v (%v)", fields, err)
+	}
+
+	fields, err = ParseSpecialFields([]string{"next=_redirect", " Honeypot = website "})
+	if err != nil {
+		t.Fatalf("ParseSpecialFields failed: %v", err)
+	}
+	if fields.Next != "_redirect" || fields.Honeypot != "website" || fields.Subject != "_subject" {
+		t.Errorf("Expected next and the honeypot to be renamed, got %+v", fields)
+	}
+
+	for _, entries := range [][]string{{"redirect=_next"}, {"next"}, {"next="}, {"cc=_subject"}} {
+		if _, err := ParseSpecialFields(entries); err == nil {
+			t.Errorf("Expected %q to be refused", entries)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// sqlTimeFormat matches the CURRENT_TIMESTAMP format the timestamp columns
+// default to, so comparisons work on every engine including SQLite, which
+// stores timestamps as text
+const sqlTimeFormat = "2006-01-02 15:04:05"
+
+// sqlTime formats a time for comparison with a timestamp column
+func sqlTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeFormat)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// SubmitStatements holds the statements run on every form submission,
+// prepared once at startup instead of on each request
+type SubmitStatements struct {
+	getFormByKey           *sql.Stmt
+	createSubmission       *sql.Stmt
+	getSubmissionByID      *sql.Stmt
+	updateSubmissionStatus *sql.Stmt
+}
+
+// PrepareSubmitStatements prepares the submission statements against db
+func PrepareSubmitStatements(ctx context.Context, db *sql.DB) (*SubmitStatements, error) {
+	s := &SubmitStatements{}
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.getFormByKey, getFormByKeyQuery},
+		{&s.createSubmission, createSubmissionQuery},
+		{&s.getSubmissionByID, getSubmissionByIDQuery},
+		{&s.updateSubmissionStatus, updateSubmissionStatusQuery},
+	}
+
+	for _, st := range statements {
+		stmt, err := db.PrepareContext(ctx, st.query)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		*st.stmt = stmt
+	}
+
+	return s, nil
+}
+
+// GetFormByKey retrieves a form by its unique key, like GetFormByKeyContext
+func (s *SubmitStatements) GetFormByKey(ctx context.Context, formKey string) (*Form, error) {
+	return scanForm(s.getFormByKey.QueryRowContext(ctx, formKey))
+}
+
+// CreateSubmission creates a new form submission, like
+// CreateReferredSubmissionContext
+func (s *SubmitStatements) CreateSubmission(ctx context.Context, formID int64, ipAddress, userAgent, referrer string, submittedData json.RawMessage) (*Submission, error) {
+	result, err := s.createSubmission.ExecContext(ctx, formID, ipAddress, userAgent, referrer, string(submittedData))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubmission(s.getSubmissionByID.QueryRowContext(ctx, id))
+}
+
+// UpdateSubmissionStatus updates a submission's status, like
+// UpdateSubmissionStatusContext
+func (s *SubmitStatements) UpdateSubmissionStatus(ctx context.Context, id int64, status string) error {
+	_, err := s.updateSubmissionStatus.ExecContext(ctx, status, processedAt(status), id)
+	return err
+}
+
+// Close releases the prepared statements
+func (s *SubmitStatements) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, stmt := range []*sql.Stmt{s.getFormByKey, s.createSubmission, s.getSubmissionByID, s.updateSubmissionStatus} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
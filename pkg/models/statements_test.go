@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubmitStatements(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "user@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form := CreateTestForm(t, db, user.ID, "contact", "example.com", "turnstile_secret_456", "admin@example.com")
+
+	ctx := context.Background()
+	statements, err := PrepareSubmitStatements(ctx, db)
+	if err != nil {
+		t.Fatalf("Failed to prepare statements: %v", err)
+	}
+	defer statements.Close()
+
+	found, err := statements.GetFormByKey(ctx, form.FormKey)
+	if err != nil {
+		t.Fatalf("Failed to get form by key: %v", err)
+	}
+	if found == nil || found.ID != form.ID {
+		t.Fatalf("Expected form %d, got %+v", form.ID, found)
+	}
+
+	missing, err := statements.GetFormByKey(ctx, "no-such-key")
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil form for unknown key, got %+v (err %v)", missing, err)
+	}
+
+	// The statements are reused across calls
+	for i := 0; i < 3; i++ {
+		submission, err := statements.CreateSubmission(ctx, form.ID, "127.0.0.1", "test", "https://example.com/contact", []byte(`{"name":"Ada"}`))
+		if err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+		if submission.FormID != form.ID || string(submission.SubmittedData) != `{"name":"Ada"}` || submission.Status != "pending" || submission.Referrer != "https://example.com/contact" {
+			t.Errorf("Unexpected submission: %+v", submission)
+		}
+
+		if err := statements.UpdateSubmissionStatus(ctx, submission.ID, "processed"); err != nil {
+			t.Fatalf("Failed to update status: %v", err)
+		}
+		updated, err := GetSubmissionByID(db, submission.ID)
+		if err != nil {
+			t.Fatalf("Failed to get submission: %v", err)
+		}
+		if updated.Status != "processed" || updated.ProcessedAt == nil {
+			t.Errorf("Expected processed submission with processed_at, got %+v", updated)
+		}
+	}
+
+	if err := statements.Close(); err != nil {
+		t.Errorf("Failed to close statements: %v", err)
+	}
+	if err := (*SubmitStatements)(nil).Close(); err != nil {
+		t.Errorf("Expected closing nil statements to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DailyCount is the activity on one UTC day
+type DailyCount struct {
+	Day         time.Time `json:"day"`
+	Submissions int       `json:"submissions"`
+	Blocked     int       `json:"blocked"` // Submissions rejected by IP rules
+	Failed      int       `json:"failed"`  // Submissions whose email couldn't be sent
+}
+
+// GetDailyCountsContext counts a user's submissions, blocked attempts and
+// failed emails per day for the last days days, including today. A formID of 0 counts
+// every form the user owns, and a userID of 0 counts every user's forms
+// along with attempts blocked by global rules. Days without activity are
+// included with zero counts, oldest first.
+func GetDailyCountsContext(ctx context.Context, db *sql.DB, userID, formID int64, days int, now time.Time) ([]DailyCount, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1))
+
+	counts := make([]DailyCount, days)
+	for i := range counts {
+		counts[i].Day = since.AddDate(0, 0, i)
+	}
+
+	sources := []struct {
+		table  string
+		filter string
+		count  func(*DailyCount) *int
+	}{
+		{"submissions", "", func(c *DailyCount) *int { return &c.Submissions }},
+		{"blocked_attempts", "", func(c *DailyCount) *int { return &c.Blocked }},
+		{"submissions", " AND t.status = 'failed'", func(c *DailyCount) *int { return &c.Failed }},
+	}
+	for _, source := range sources {
+		query := "SELECT DATE(t.created_at), COUNT(*) FROM " + source.table + " t"
+		where := " WHERE t.created_at >= ?" + source.filter
+		args := []interface{}{sqlTime(since)}
+		if userID != 0 {
+			query += " JOIN forms f ON f.id = t.form_id"
+			where += " AND f.user_id = ?"
+			args = append(args, userID)
+		}
+		if formID != 0 {
+			where += " AND t.form_id = ?"
+			args = append(args, formID)
+		}
+		query += where + " GROUP BY DATE(t.created_at)"
+
+		byDay, err := countByDay(ctx, db, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for day, count := range byDay {
+			i := int(day.Sub(since).Hours() / 24)
+			if i < 0 || i >= days {
+				continue
+			}
+			*source.count(&counts[i]) = count
+		}
+	}
+
+	return counts, nil
+}
+
+// GetDailyCounts is like GetDailyCountsContext but uses context.Background
+func GetDailyCounts(db *sql.DB, userID, formID int64, days int, now time.Time) ([]DailyCount, error) {
+	return GetDailyCountsContext(context.Background(), db, userID, formID, days, now)
+}
+
+// InstanceStats are totals across every user, shown to administrators
+type InstanceStats struct {
+	Users             int   `json:"users"`
+	Forms             int   `json:"forms"`
+	Submissions       int   `json:"submissions"`
+	FailedSubmissions int   `json:"failed_submissions"` // Submissions whose email couldn't be sent
+	StorageBytes      int64 `json:"storage_bytes"`      // Submission data in the database
+	ArchiveBytes      int64 `json:"archive_bytes"`      // Archive files holding older submissions
+}
+
+// GetInstanceStatsContext returns totals across every user
+func GetInstanceStatsContext(ctx context.Context, db *sql.DB) (*InstanceStats, error) {
+	var stats InstanceStats
+	counts := []struct {
+		dest  interface{}
+		query string
+	}{
+		{&stats.Users, "SELECT COUNT(*) FROM users"},
+		{&stats.Forms, "SELECT COUNT(*) FROM forms"},
+		{&stats.Submissions, "SELECT COUNT(*) FROM submissions"},
+		{&stats.FailedSubmissions, "SELECT COUNT(*) FROM submissions WHERE status = 'failed'"},
+		{&stats.StorageBytes, "SELECT COALESCE(SUM(LENGTH(submitted_data)), 0) FROM submissions"},
+		{&stats.ArchiveBytes, "SELECT COALESCE(SUM(size), 0) FROM submission_archives"},
+	}
+	for _, count := range counts {
+		if err := db.QueryRowContext(ctx, count.query).Scan(count.dest); err != nil {
+			return nil, err
+		}
+	}
+	return &stats, nil
+}
+
+// GetInstanceStats is like GetInstanceStatsContext but uses context.Background
+func GetInstanceStats(db *sql.DB) (*InstanceStats, error) {
+	return GetInstanceStatsContext(context.Background(), db)
+}
+
+// countByDay runs a query returning (DATE(...), COUNT(*)) rows
+func countByDay(ctx context.Context, db *sql.DB, query string, args ...interface{}) (map[time.Time]int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[time.Time]int)
+	for rows.Next() {
+		var day sqlDate
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+		counts[day.Time] = count
+	}
+	return counts, rows.Err()
+}
+
+// SenderCount is how many submissions a form received from one IP address
+type SenderCount struct {
+	FormID    int64
+	IPAddress string
+	Count     int
+}
+
+// CountSubmissionsBySenderContext counts the submissions received from
+// since up to until by form and IP address, including those held as spam.
+// Submissions without an IP address are counted under "".
+func CountSubmissionsBySenderContext(ctx context.Context, db *sql.DB, since, until time.Time) ([]SenderCount, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT form_id, COALESCE(ip_address, ''), COUNT(*) FROM submissions WHERE created_at >= ? AND created_at < ? GROUP BY form_id, COALESCE(ip_address, '')",
+		sqlTime(since), sqlTime(until),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []SenderCount
+	for rows.Next() {
+		var c SenderCount
+		if err := rows.Scan(&c.FormID, &c.IPAddress, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// CountSubmissionsBySender is like CountSubmissionsBySenderContext but uses context.Background
+func CountSubmissionsBySender(db *sql.DB, since, until time.Time) ([]SenderCount, error) {
+	return CountSubmissionsBySenderContext(context.Background(), db, since, until)
+}
+
+// sqlDate scans the result of DATE(), which SQLite returns as text and
+// the other engines as a time
+type sqlDate struct {
+	time.Time
+}
+
+// Scan implements sql.Scanner
+func (d *sqlDate) Scan(value interface{}) error {
+	var err error
+	switch v := value.(type) {
+	case time.Time:
+		d.Time = v.UTC().Truncate(24 * time.Hour)
+	case string:
+		d.Time, err = time.Parse("2006-01-02", v)
+	case []byte:
+		d.Time, err = time.Parse("2006-01-02", string(v))
+	default:
+		err = fmt.Errorf("cannot scan %T into a date", value)
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetDailyCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "stats@example.com", "hashed_password")
+	other, _ := CreateUser(db, "other@example.com", "hashed_password")
+	first := CreateTestForm(t, db, user.ID, "first", "example.com", "secret", "to@example.com")
+	second := CreateTestForm(t, db, user.ID, "second", "example.com", "secret", "to@example.com")
+	otherForm := CreateTestForm(t, db, other.ID, "other", "example.com", "secret", "to@example.com")
+
+	now := time.Now().UTC()
+	insert := func(query string, formID int64, at time.Time) {
+		if _, err := db.Exec(query, formID, sqlTime(at)); err != nil {
+			t.Fatalf("Failed to insert row: %v", err)
+		}
+	}
+	submission := "INSERT INTO submissions (form_id, ip_address, user_agent, submitted_data, created_at) VALUES (?, '127.0.0.1', 'test', '{}', ?)"
+	failed := "INSERT INTO submissions (form_id, ip_address, user_agent, submitted_data, status, created_at) VALUES (?, '127.0.0.1', 'test', '{}', 'failed', ?)"
+	blocked := "INSERT INTO blocked_attempts (form_id, ip_address, reason, created_at) VALUES (?, '203.0.113.1', 'deny', ?)"
+	insert(submission, first.ID, now)
+	insert(submission, first.ID, now)
+	insert(submission, second.ID, now.AddDate(0, 0, -2))
+	insert(submission, first.ID, now.AddDate(0, 0, -40))
+	insert(submission, otherForm.ID, now)
+	insert(blocked, first.ID, now.AddDate(0, 0, -2))
+	insert(failed, first.ID, now.AddDate(0, 0, -1))
+
+	counts, err := GetDailyCounts(db, user.ID, 0, 30, now)
+	if err != nil {
+		t.Fatalf("Failed to get daily counts: %v", err)
+	}
+	if len(counts) != 30 {
+		t.Fatalf("Expected 30 days, got %d", len(counts))
+	}
+	if !counts[29].Day.Equal(now.Truncate(24 * time.Hour)) {
+		t.Errorf("Expected the last day to be today, got %v", counts[29].Day)
+	}
+	if counts[29].Submissions != 2 || counts[27].Submissions != 1 || counts[27].Blocked != 1 {
+		t.Errorf("Expected the user's activity on the right days, got %+v and %+v", counts[27], counts[29])
+	}
+	if counts[28].Submissions != 1 || counts[28].Failed != 1 || counts[29].Failed != 0 {
+		t.Errorf("Expected the failed email yesterday, got %+v and %+v", counts[28], counts[29])
+	}
+
+	total := 0
+	for _, count := range counts {
+		total += count.Submissions
+	}
+	if total != 4 {
+		t.Errorf("Expected 4 submissions in range, got %d", total)
+	}
+
+	counts, err = GetDailyCounts(db, user.ID, second.ID, 90, now)
+	if err != nil {
+		t.Fatalf("Failed to get daily counts: %v", err)
+	}
+	if len(counts) != 90 || counts[87].Submissions != 1 || counts[87].Blocked != 0 || counts[89].Submissions != 0 {
+		t.Errorf("Expected only the second form's submission, got %+v", counts[87:])
+	}
+}
+
+func TestGetInstanceStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "stats@example.com", "hashed_password")
+	other, _ := CreateUser(db, "other@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "first", "example.com", "secret", "to@example.com")
+	otherForm := CreateTestForm(t, db, other.ID, "other", "example.com", "secret", "to@example.com")
+
+	CreateSubmission(db, form.ID, "127.0.0.1", "test", []byte(`{"a":"b"}`))
+	failed, _ := CreateSubmission(db, otherForm.ID, "127.0.0.1", "test", []byte(`{}`))
+	UpdateSubmissionStatus(db, failed.ID, "failed")
+	if err := CreateBlockedAttempt(db, nil, nil, "203.0.113.1", "global deny"); err != nil {
+		t.Fatalf("Failed to record blocked attempt: %v", err)
+	}
+
+	stats, err := GetInstanceStats(db)
+	if err != nil {
+		t.Fatalf("Failed to get instance stats: %v", err)
+	}
+	if stats.Users != 2 || stats.Forms != 2 || stats.Submissions != 2 || stats.FailedSubmissions != 1 {
+		t.Errorf("Unexpected totals: %+v", stats)
+	}
+	if stats.StorageBytes != int64(len(`{"a":"b"}`)+len(`{}`)) {
+		t.Errorf("Expected the stored data size, got %d", stats.StorageBytes)
+	}
+
+	// A userID of 0 counts everyone, including globally blocked attempts
+	counts, err := GetDailyCounts(db, 0, 0, 7, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get daily counts: %v", err)
+	}
+	if today := counts[6]; today.Submissions != 2 || today.Blocked != 1 {
+		t.Errorf("Expected every user's activity today, got %+v", today)
+	}
+}
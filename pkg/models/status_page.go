@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// StatusPage is a form's shareable, read-only status page
+type StatusPage struct {
+	FormID    int64     `json:"form_id"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetStatusPageByFormIDContext returns a form's status page, or nil if it
+// doesn't have one
+func GetStatusPageByFormIDContext(ctx context.Context, db *sql.DB, formID int64) (*StatusPage, error) {
+	return scanStatusPage(db.QueryRowContext(ctx,
+		"SELECT form_id, token, created_at FROM form_status_pages WHERE form_id = ?",
+		formID,
+	))
+}
+
+// GetStatusPageByFormID is like GetStatusPageByFormIDContext but uses context.Background
+func GetStatusPageByFormID(db *sql.DB, formID int64) (*StatusPage, error) {
+	return GetStatusPageByFormIDContext(context.Background(), db, formID)
+}
+
+// GetStatusPageByTokenContext returns the status page with a token, or nil
+// if there isn't one
+func GetStatusPageByTokenContext(ctx context.Context, db *sql.DB, token string) (*StatusPage, error) {
+	return scanStatusPage(db.QueryRowContext(ctx,
+		"SELECT form_id, token, created_at FROM form_status_pages WHERE token = ?",
+		token,
+	))
+}
+
+// GetStatusPageByToken is like GetStatusPageByTokenContext but uses context.Background
+func GetStatusPageByToken(db *sql.DB, token string) (*StatusPage, error) {
+	return GetStatusPageByTokenContext(context.Background(), db, token)
+}
+
+// EnableStatusPageContext gives a form a status page at token, replacing
+// any it had so old links stop working
+func EnableStatusPageContext(ctx context.Context, db *sql.DB, formID int64, token string) (*StatusPage, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM form_status_pages WHERE form_id = ?", formID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO form_status_pages (form_id, token) VALUES (?, ?)",
+		formID, token,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetStatusPageByFormIDContext(ctx, db, formID)
+}
+
+// EnableStatusPage is like EnableStatusPageContext but uses context.Background
+func EnableStatusPage(db *sql.DB, formID int64, token string) (*StatusPage, error) {
+	return EnableStatusPageContext(context.Background(), db, formID, token)
+}
+
+// DisableStatusPageContext removes a form's status page
+func DisableStatusPageContext(ctx context.Context, db *sql.DB, formID int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM form_status_pages WHERE form_id = ?", formID)
+	return err
+}
+
+// DisableStatusPage is like DisableStatusPageContext but uses context.Background
+func DisableStatusPage(db *sql.DB, formID int64) error {
+	return DisableStatusPageContext(context.Background(), db, formID)
+}
+
+// scanStatusPage reads a status page row, returning nil if there isn't one
+func scanStatusPage(row rowScanner) (*StatusPage, error) {
+	var page StatusPage
+	err := row.Scan(&page.FormID, &page.Token, &page.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &page, nil
+}
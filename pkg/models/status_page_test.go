@@ -0,0 +1,49 @@
+package models
+
+import "testing"
+
+func TestStatusPages(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "status@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "status", "example.com", "secret", "to@example.com")
+
+	page, err := GetStatusPageByFormID(db, form.ID)
+	if err != nil || page != nil {
+		t.Fatalf("Expected no status page, got %v (err %v)", page, err)
+	}
+
+	page, err = EnableStatusPage(db, form.ID, "first-token")
+	if err != nil || page == nil || page.Token != "first-token" {
+		t.Fatalf("Expected a status page, got %v (err %v)", page, err)
+	}
+
+	// Enabling again replaces the link
+	if _, err := EnableStatusPage(db, form.ID, "second-token"); err != nil {
+		t.Fatalf("Failed to replace status page: %v", err)
+	}
+	if page, _ := GetStatusPageByToken(db, "first-token"); page != nil {
+		t.Error("Expected the old link to stop working")
+	}
+	page, err = GetStatusPageByToken(db, "second-token")
+	if err != nil || page == nil || page.FormID != form.ID {
+		t.Fatalf("Expected the new link to find the form, got %v (err %v)", page, err)
+	}
+
+	if err := DisableStatusPage(db, form.ID); err != nil {
+		t.Fatalf("Failed to disable status page: %v", err)
+	}
+	if page, _ := GetStatusPageByFormID(db, form.ID); page != nil {
+		t.Error("Expected the status page to be removed")
+	}
+
+	// Deleting a form removes its status page
+	EnableStatusPage(db, form.ID, "third-token")
+	if err := DeleteForm(db, form.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	if page, _ := GetStatusPageByToken(db, "third-token"); page != nil {
+		t.Error("Expected the status page to be deleted with the form")
+	}
+}
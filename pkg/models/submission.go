@@ -1,8 +1,11 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -12,18 +15,56 @@ type Submission struct {
 	FormID        int64           `json:"form_id"`
 	IPAddress     string          `json:"ip_address"`
 	UserAgent     string          `json:"user_agent"`
+	Referrer      string          `json:"referrer"`
 	SubmittedData json.RawMessage `json:"submitted_data"`
 	CreatedAt     time.Time       `json:"created_at"`
 	ProcessedAt   *time.Time      `json:"processed_at"`
 	Status        string          `json:"status"`
+	SpamAt        *time.Time      `json:"spam_at"`
+	// SpamReason is why the submission was held as spam, e.g. honeypot
+	SpamReason string `json:"spam_reason,omitempty"`
+	// SpamScore is how likely the submission is to be spam, from 0 to 100,
+	// or nil if it wasn't scored
+	SpamScore *int `json:"spam_score,omitempty"`
+	// SpamSignals are the signals behind the spam score, as JSON
+	SpamSignals json.RawMessage `json:"spam_signals,omitempty"`
+	// Country is the ISO code of the country the IP address is in, when
+	// a GeoIP database is configured
+	Country string `json:"country,omitempty"`
 }
 
-// CreateSubmission creates a new form submission
+// Queries used on the submission path, shared with SubmitStatements
+const (
+	createSubmissionQuery       = "INSERT INTO submissions (form_id, ip_address, user_agent, referrer, submitted_data) VALUES (?, ?, ?, ?, ?)"
+	getSubmissionByIDQuery      = "SELECT id, form_id, ip_address, user_agent, referrer, submitted_data, created_at, processed_at, status, spam_at, spam_reason, spam_score, spam_signals, country FROM submissions WHERE id = ?"
+	updateSubmissionStatusQuery = "UPDATE submissions SET status = ?, processed_at = ? WHERE id = ?"
+)
+
+// CreateSubmissionContext creates a new form submission
+func CreateSubmissionContext(ctx context.Context, db *sql.DB, formID int64, ipAddress, userAgent string, submittedData json.RawMessage) (*Submission, error) {
+	return CreateReferredSubmissionContext(ctx, db, formID, ipAddress, userAgent, "", submittedData)
+}
+
+// CreateSubmission is like CreateSubmissionContext but uses context.Background
 func CreateSubmission(db *sql.DB, formID int64, ipAddress, userAgent string, submittedData json.RawMessage) (*Submission, error) {
-	result, err := db.Exec(
-		"INSERT INTO submissions (form_id, ip_address, user_agent, submitted_data) VALUES (?, ?, ?, ?)",
-		formID, ipAddress, userAgent, string(submittedData),
-	)
+	return CreateSubmissionContext(context.Background(), db, formID, ipAddress, userAgent, submittedData)
+}
+
+// SetSubmissionCountryContext records the country a submission was sent from
+func SetSubmissionCountryContext(ctx context.Context, db *sql.DB, id int64, country string) error {
+	_, err := db.ExecContext(ctx, "UPDATE submissions SET country = ? WHERE id = ?", country, id)
+	return err
+}
+
+// SetSubmissionCountry is like SetSubmissionCountryContext but uses context.Background
+func SetSubmissionCountry(db *sql.DB, id int64, country string) error {
+	return SetSubmissionCountryContext(context.Background(), db, id, country)
+}
+
+// CreateReferredSubmissionContext creates a new form submission, recording
+// the page it was sent from
+func CreateReferredSubmissionContext(ctx context.Context, db *sql.DB, formID int64, ipAddress, userAgent, referrer string, submittedData json.RawMessage) (*Submission, error) {
+	result, err := db.ExecContext(ctx, createSubmissionQuery, formID, ipAddress, userAgent, referrer, string(submittedData))
 	if err != nil {
 		return nil, err
 	}
@@ -33,19 +74,28 @@ func CreateSubmission(db *sql.DB, formID int64, ipAddress, userAgent string, sub
 		return nil, err
 	}
 
-	return GetSubmissionByID(db, id)
+	return GetSubmissionByIDContext(ctx, db, id)
 }
 
-// GetSubmissionByID retrieves a submission by its ID
-func GetSubmissionByID(db *sql.DB, id int64) (*Submission, error) {
+// CreateReferredSubmission is like CreateReferredSubmissionContext but uses context.Background
+func CreateReferredSubmission(db *sql.DB, formID int64, ipAddress, userAgent, referrer string, submittedData json.RawMessage) (*Submission, error) {
+	return CreateReferredSubmissionContext(context.Background(), db, formID, ipAddress, userAgent, referrer, submittedData)
+}
+
+// GetSubmissionByIDContext retrieves a submission by its ID
+func GetSubmissionByIDContext(ctx context.Context, db *sql.DB, id int64) (*Submission, error) {
+	return scanSubmission(db.QueryRowContext(ctx, getSubmissionByIDQuery, id))
+}
+
+// scanSubmission reads a submission row, returning nil if there isn't one
+func scanSubmission(row rowScanner) (*Submission, error) {
 	var submission Submission
-	var processedAt sql.NullTime
+	var processedAt, spamAt sql.NullTime
+	var spamScore sql.NullInt64
+	var spamSignals sql.NullString
 	var submittedData string
 
-	err := db.QueryRow(
-		"SELECT id, form_id, ip_address, user_agent, submitted_data, created_at, processed_at, status FROM submissions WHERE id = ?",
-		id,
-	).Scan(&submission.ID, &submission.FormID, &submission.IPAddress, &submission.UserAgent, &submittedData, &submission.CreatedAt, &processedAt, &submission.Status)
+	err := row.Scan(&submission.ID, &submission.FormID, &submission.IPAddress, &submission.UserAgent, &submission.Referrer, &submittedData, &submission.CreatedAt, &processedAt, &submission.Status, &spamAt, &submission.SpamReason, &spamScore, &spamSignals, &submission.Country)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -61,16 +111,50 @@ func GetSubmissionByID(db *sql.DB, id int64) (*Submission, error) {
 	if processedAt.Valid {
 		submission.ProcessedAt = &processedAt.Time
 	}
+	if spamAt.Valid {
+		submission.SpamAt = &spamAt.Time
+	}
+	if spamScore.Valid {
+		score := int(spamScore.Int64)
+		submission.SpamScore = &score
+	}
+	if spamSignals.String != "" {
+		submission.SpamSignals = json.RawMessage(spamSignals.String)
+	}
 
 	return &submission, nil
 }
 
-// GetSubmissionsByFormID retrieves all submissions for a specific form
-func GetSubmissionsByFormID(db *sql.DB, formID int64) ([]Submission, error) {
-	rows, err := db.Query(
-		"SELECT id, form_id, ip_address, user_agent, submitted_data, created_at, processed_at, status FROM submissions WHERE form_id = ? ORDER BY created_at DESC",
+// GetSubmissionByID is like GetSubmissionByIDContext but uses context.Background
+func GetSubmissionByID(db *sql.DB, id int64) (*Submission, error) {
+	return GetSubmissionByIDContext(context.Background(), db, id)
+}
+
+// GetSubmissionForUserContext retrieves a submission by its ID if it was
+// sent to one of the user's forms, returning nil otherwise
+func GetSubmissionForUserContext(ctx context.Context, db *sql.DB, id, userID int64) (*Submission, error) {
+	return scanSubmission(db.QueryRowContext(ctx,
+		"SELECT s.id, s.form_id, s.ip_address, s.user_agent, s.referrer, s.submitted_data, s.created_at, s.processed_at, s.status, s.spam_at, s.spam_reason, s.spam_score, s.spam_signals, s.country FROM submissions s JOIN forms f ON f.id = s.form_id WHERE s.id = ? AND f.user_id = ?",
+		id, userID,
+	))
+}
+
+// GetSubmissionForUser is like GetSubmissionForUserContext but uses context.Background
+func GetSubmissionForUser(db *sql.DB, id, userID int64) (*Submission, error) {
+	return GetSubmissionForUserContext(context.Background(), db, id, userID)
+}
+
+// GetSubmissionsByFormIDContext retrieves all submissions for a specific form
+func GetSubmissionsByFormIDContext(ctx context.Context, db *sql.DB, formID int64) ([]Submission, error) {
+	return querySubmissions(ctx, db,
+		"SELECT id, form_id, ip_address, user_agent, referrer, submitted_data, created_at, processed_at, status, spam_at, spam_reason, spam_score, spam_signals, country FROM submissions WHERE form_id = ? ORDER BY created_at DESC",
 		formID,
 	)
+}
+
+// querySubmissions runs a query returning submission rows
+func querySubmissions(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]Submission, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -78,51 +162,340 @@ func GetSubmissionsByFormID(db *sql.DB, formID int64) ([]Submission, error) {
 
 	var submissions []Submission
 	for rows.Next() {
-		var submission Submission
-		var processedAt sql.NullTime
-		var submittedData string
-
-		if err := rows.Scan(&submission.ID, &submission.FormID, &submission.IPAddress, &submission.UserAgent, &submittedData, &submission.CreatedAt, &processedAt, &submission.Status); err != nil {
+		submission, err := scanSubmission(rows)
+		if err != nil {
 			return nil, err
 		}
+		submissions = append(submissions, *submission)
+	}
+
+	return submissions, rows.Err()
+}
+
+// GetSubmissionsByFormID is like GetSubmissionsByFormIDContext but uses context.Background
+func GetSubmissionsByFormID(db *sql.DB, formID int64) ([]Submission, error) {
+	return GetSubmissionsByFormIDContext(context.Background(), db, formID)
+}
 
-		// Convert string back to JSON raw message
-		submission.SubmittedData = json.RawMessage(submittedData)
+// SubmissionStatuses lists the delivery statuses a submission moves through
+var SubmissionStatuses = []string{"pending", "processed", "failed"}
 
-		// Handle nullable processed_at
-		if processedAt.Valid {
-			submission.ProcessedAt = &processedAt.Time
+// HoneypotField is a hidden form field that real visitors leave empty.
+// Submissions that fill it in are held as spam.
+const HoneypotField = "_gotcha"
+
+// SubmissionFilter narrows the submissions listed for a form. Zero values
+// don't filter, except that spam is only listed when Spam is set.
+type SubmissionFilter struct {
+	// Spam lists the submissions marked as spam instead of the others
+	Spam bool
+	// Status is the delivery status: pending, processed or failed
+	Status string
+	// InboxStatus is the status of the submission's assignment
+	InboxStatus string
+	// AssigneeID only includes submissions assigned to this user
+	AssigneeID int64
+	// From and To bound when submissions were received; To is exclusive
+	From time.Time
+	To   time.Time
+}
+
+// where builds the conditions selecting a form's submissions that match the
+// filter, for a query joining submissions s with submission_assignments a
+func (f SubmissionFilter) where(formID int64) (string, []interface{}) {
+	conditions := []string{"s.form_id = ?"}
+	args := []interface{}{formID}
+	if f.Spam {
+		conditions = append(conditions, "s.spam_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "s.spam_at IS NULL")
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "s.status = ?")
+		args = append(args, f.Status)
+	}
+	if f.InboxStatus != "" {
+		// Submissions without an assignment are new
+		conditions = append(conditions, "COALESCE(a.status, ?) = ?")
+		args = append(args, InboxStatusNew, f.InboxStatus)
+	}
+	if f.AssigneeID != 0 {
+		conditions = append(conditions, "a.assignee_id = ?")
+		args = append(args, f.AssigneeID)
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, "s.created_at >= ?")
+		args = append(args, sqlTime(f.From))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, "s.created_at < ?")
+		args = append(args, sqlTime(f.To))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetSubmissionsPageContext retrieves up to limit of a form's submissions
+// matching filter, newest first, skipping the first offset
+func GetSubmissionsPageContext(ctx context.Context, db *sql.DB, formID int64, filter SubmissionFilter, limit, offset int) ([]Submission, error) {
+	where, args := filter.where(formID)
+	return querySubmissions(ctx, db,
+		`SELECT s.id, s.form_id, s.ip_address, s.user_agent, s.referrer, s.submitted_data, s.created_at, s.processed_at, s.status, s.spam_at, s.spam_reason, s.spam_score, s.spam_signals, s.country
+		FROM submissions s LEFT JOIN submission_assignments a ON a.submission_id = s.id
+		WHERE `+where+`
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+}
+
+// GetSubmissionsPage is like GetSubmissionsPageContext but uses context.Background
+func GetSubmissionsPage(db *sql.DB, formID int64, filter SubmissionFilter, limit, offset int) ([]Submission, error) {
+	return GetSubmissionsPageContext(context.Background(), db, formID, filter, limit, offset)
+}
+
+// CountSubmissionsContext counts a form's submissions matching filter
+func CountSubmissionsContext(ctx context.Context, db *sql.DB, formID int64, filter SubmissionFilter) (int, error) {
+	where, args := filter.where(formID)
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM submissions s LEFT JOIN submission_assignments a ON a.submission_id = s.id WHERE "+where,
+		args...,
+	).Scan(&count)
+	return count, err
+}
+
+// CountSubmissions is like CountSubmissionsContext but uses context.Background
+func CountSubmissions(db *sql.DB, formID int64, filter SubmissionFilter) (int, error) {
+	return CountSubmissionsContext(context.Background(), db, formID, filter)
+}
+
+// GetSubmissionFieldNamesContext returns the names of the fields in a form's
+// most recent submissions, up to sample of them, in alphabetical order
+func GetSubmissionFieldNamesContext(ctx context.Context, db *sql.DB, formID int64, sample int) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT submitted_data FROM submissions WHERE form_id = ? ORDER BY created_at DESC, id DESC LIMIT ?",
+		formID, sample,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var submittedData string
+		if err := rows.Scan(&submittedData); err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(submittedData), &fields); err != nil {
+			// Skip data that isn't a JSON object rather than failing the page
+			continue
 		}
+		for name := range fields {
+			seen[name] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		submissions = append(submissions, submission)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names, nil
+}
 
-	return submissions, nil
+// GetSubmissionFieldNames is like GetSubmissionFieldNamesContext but uses context.Background
+func GetSubmissionFieldNames(db *sql.DB, formID int64, sample int) ([]string, error) {
+	return GetSubmissionFieldNamesContext(context.Background(), db, formID, sample)
 }
 
-// UpdateSubmissionStatus updates the status and processed_at timestamp of a submission
-func UpdateSubmissionStatus(db *sql.DB, id int64, status string) error {
-	var processedAt interface{}
+// UpdateSubmissionStatusContext updates the status and processed_at timestamp of a submission
+func UpdateSubmissionStatusContext(ctx context.Context, db *sql.DB, id int64, status string) error {
+	_, err := db.ExecContext(ctx, updateSubmissionStatusQuery, status, processedAt(status), id)
+	return err
+}
+
+// processedAt returns the processed_at value to store for a status
+func processedAt(status string) interface{} {
 	if status == "processed" {
-		processedAt = time.Now()
-	} else {
-		processedAt = nil
+		return time.Now()
 	}
+	return nil
+}
 
-	_, err := db.Exec(
-		"UPDATE submissions SET status = ?, processed_at = ? WHERE id = ?",
-		status, processedAt, id,
-	)
+// UpdateSubmissionStatus is like UpdateSubmissionStatusContext but uses context.Background
+func UpdateSubmissionStatus(db *sql.DB, id int64, status string) error {
+	return UpdateSubmissionStatusContext(context.Background(), db, id, status)
+}
+
+// SetSubmissionSpamContext marks a submission as spam, or clears the mark
+func SetSubmissionSpamContext(ctx context.Context, db *sql.DB, id int64, spam bool) error {
+	var spamAt interface{}
+	reason := ""
+	if spam {
+		spamAt = sqlTime(time.Now())
+		reason = SpamReasonManual
+	}
+	_, err := db.ExecContext(ctx, "UPDATE submissions SET spam_at = ?, spam_reason = ? WHERE id = ?", spamAt, reason, id)
 	return err
 }
 
-// GetSubmissionCountByFormID returns the number of submissions for a form
-func GetSubmissionCountByFormID(db *sql.DB, formID int64) (int, error) {
+// SetSubmissionSpam is like SetSubmissionSpamContext but uses context.Background
+func SetSubmissionSpam(db *sql.DB, id int64, spam bool) error {
+	return SetSubmissionSpamContext(context.Background(), db, id, spam)
+}
+
+// SetSubmissionCreatedAtContext changes when a submission was received,
+// e.g. to spread demo submissions over the past weeks
+func SetSubmissionCreatedAtContext(ctx context.Context, db *sql.DB, id int64, createdAt time.Time) error {
+	_, err := db.ExecContext(ctx, "UPDATE submissions SET created_at = ? WHERE id = ?", sqlTime(createdAt), id)
+	return err
+}
+
+// SetSubmissionCreatedAt is like SetSubmissionCreatedAtContext but uses context.Background
+func SetSubmissionCreatedAt(db *sql.DB, id int64, createdAt time.Time) error {
+	return SetSubmissionCreatedAtContext(context.Background(), db, id, createdAt)
+}
+
+// submissionChildTables hold rows that belong to a submission by its
+// submission_id, deleted along with it
+var submissionChildTables = []string{"submission_emails", "submission_notes", "submission_assignments", "notification_deliveries", "submission_files"}
+
+// DeleteSubmissionContext deletes a submission along with its email
+// records, notes, assignment, notification deliveries and file records.
+// Uploaded files are swept from their store later.
+func DeleteSubmissionContext(ctx context.Context, db *sql.DB, id int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range submissionChildTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE submission_id = ?", id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM submissions WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSubmission is like DeleteSubmissionContext but uses context.Background
+func DeleteSubmission(db *sql.DB, id int64) error {
+	return DeleteSubmissionContext(context.Background(), db, id)
+}
+
+// GetSubmissionCountByFormIDContext returns the number of submissions for a form
+func GetSubmissionCountByFormIDContext(ctx context.Context, db *sql.DB, formID int64) (int, error) {
 	var count int
-	err := db.QueryRow(
+	err := db.QueryRowContext(ctx,
 		"SELECT COUNT(*) FROM submissions WHERE form_id = ?",
 		formID,
 	).Scan(&count)
 
 	return count, err
-}
\ No newline at end of file
+}
+
+// GetSubmissionCountByFormID is like GetSubmissionCountByFormIDContext but uses context.Background
+func GetSubmissionCountByFormID(db *sql.DB, formID int64) (int, error) {
+	return GetSubmissionCountByFormIDContext(context.Background(), db, formID)
+}
+
+// GetSubmissionCountsByUserIDContext counts the submissions of every form a
+// user owns in a single query, keyed by form ID. Forms without submissions
+// are left out.
+func GetSubmissionCountsByUserIDContext(ctx context.Context, db *sql.DB, userID int64) (map[int64]int, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT s.form_id, COUNT(*)
+		FROM submissions s JOIN forms f ON f.id = s.form_id
+		WHERE f.user_id = ?
+		GROUP BY s.form_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var formID int64
+		var count int
+		if err := rows.Scan(&formID, &count); err != nil {
+			return nil, err
+		}
+		counts[formID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetSubmissionCountsByUserID is like GetSubmissionCountsByUserIDContext but uses context.Background
+func GetSubmissionCountsByUserID(db *sql.DB, userID int64) (map[int64]int, error) {
+	return GetSubmissionCountsByUserIDContext(context.Background(), db, userID)
+}
+
+// GetSubmissionsBeforeContext returns up to limit of a form's oldest
+// submissions created before the given time, in ID order
+func GetSubmissionsBeforeContext(ctx context.Context, db *sql.DB, formID int64, before time.Time, limit int) ([]Submission, error) {
+	return querySubmissions(ctx, db,
+		"SELECT id, form_id, ip_address, user_agent, referrer, submitted_data, created_at, processed_at, status, spam_at, spam_reason, spam_score, spam_signals, country FROM submissions WHERE form_id = ? AND created_at < ? ORDER BY id LIMIT ?",
+		formID, sqlTime(before), limit,
+	)
+}
+
+// GetSubmissionsBefore is like GetSubmissionsBeforeContext but uses context.Background
+func GetSubmissionsBefore(db *sql.DB, formID int64, before time.Time, limit int) ([]Submission, error) {
+	return GetSubmissionsBeforeContext(context.Background(), db, formID, before, limit)
+}
+
+// GetSubmissionsAfterIDContext returns up to limit of a form's submissions
+// that aren't spam with IDs after afterID, in ID order, so large exports can
+// read them a batch at a time
+func GetSubmissionsAfterIDContext(ctx context.Context, db *sql.DB, formID, afterID int64, limit int) ([]Submission, error) {
+	return querySubmissions(ctx, db,
+		"SELECT id, form_id, ip_address, user_agent, referrer, submitted_data, created_at, processed_at, status, spam_at, spam_reason, spam_score, spam_signals, country FROM submissions WHERE form_id = ? AND id > ? AND spam_at IS NULL ORDER BY id LIMIT ?",
+		formID, afterID, limit,
+	)
+}
+
+// GetSubmissionsAfterID is like GetSubmissionsAfterIDContext but uses context.Background
+func GetSubmissionsAfterID(db *sql.DB, formID, afterID int64, limit int) ([]Submission, error) {
+	return GetSubmissionsAfterIDContext(context.Background(), db, formID, afterID, limit)
+}
+
+// GetFormIDsWithSubmissionsBeforeContext returns the forms that have
+// submissions created before the given time
+func GetFormIDsWithSubmissionsBeforeContext(ctx context.Context, db *sql.DB, before time.Time) ([]int64, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT DISTINCT form_id FROM submissions WHERE created_at < ? ORDER BY form_id",
+		sqlTime(before),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var formIDs []int64
+	for rows.Next() {
+		var formID int64
+		if err := rows.Scan(&formID); err != nil {
+			return nil, err
+		}
+		formIDs = append(formIDs, formID)
+	}
+
+	return formIDs, rows.Err()
+}
+
+// GetFormIDsWithSubmissionsBefore is like GetFormIDsWithSubmissionsBeforeContext but uses context.Background
+func GetFormIDsWithSubmissionsBefore(db *sql.DB, before time.Time) ([]int64, error) {
+	return GetFormIDsWithSubmissionsBeforeContext(context.Background(), db, before)
+}
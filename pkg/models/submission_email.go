@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
@@ -14,9 +15,9 @@ type SubmissionEmail struct {
 	ErrorMessage  string     `json:"error_message"`
 }
 
-// CreateSubmissionEmail creates a new email tracking record
-func CreateSubmissionEmail(db *sql.DB, submissionID int64, status, errorMessage string) (*SubmissionEmail, error) {
-	result, err := db.Exec(
+// CreateSubmissionEmailContext creates a new email tracking record
+func CreateSubmissionEmailContext(ctx context.Context, db *sql.DB, submissionID int64, status, errorMessage string) (*SubmissionEmail, error) {
+	result, err := db.ExecContext(ctx,
 		"INSERT INTO submission_emails (submission_id, status, error_message) VALUES (?, ?, ?)",
 		submissionID, status, errorMessage,
 	)
@@ -29,13 +30,18 @@ func CreateSubmissionEmail(db *sql.DB, submissionID int64, status, errorMessage
 		return nil, err
 	}
 
-	return GetSubmissionEmailByID(db, id)
+	return GetSubmissionEmailByIDContext(ctx, db, id)
 }
 
-// GetSubmissionEmailByID retrieves an email record by its ID
-func GetSubmissionEmailByID(db *sql.DB, id int64) (*SubmissionEmail, error) {
+// CreateSubmissionEmail is like CreateSubmissionEmailContext but uses context.Background
+func CreateSubmissionEmail(db *sql.DB, submissionID int64, status, errorMessage string) (*SubmissionEmail, error) {
+	return CreateSubmissionEmailContext(context.Background(), db, submissionID, status, errorMessage)
+}
+
+// GetSubmissionEmailByIDContext retrieves an email record by its ID
+func GetSubmissionEmailByIDContext(ctx context.Context, db *sql.DB, id int64) (*SubmissionEmail, error) {
 	var email SubmissionEmail
-	err := db.QueryRow(
+	err := db.QueryRowContext(ctx,
 		"SELECT id, submission_id, sent_at, status, error_message FROM submission_emails WHERE id = ?",
 		id,
 	).Scan(&email.ID, &email.SubmissionID, &email.SentAt, &email.Status, &email.ErrorMessage)
@@ -50,10 +56,15 @@ func GetSubmissionEmailByID(db *sql.DB, id int64) (*SubmissionEmail, error) {
 	return &email, nil
 }
 
-// GetSubmissionEmailBySubmissionID retrieves the email record for a specific submission
-func GetSubmissionEmailBySubmissionID(db *sql.DB, submissionID int64) (*SubmissionEmail, error) {
+// GetSubmissionEmailByID is like GetSubmissionEmailByIDContext but uses context.Background
+func GetSubmissionEmailByID(db *sql.DB, id int64) (*SubmissionEmail, error) {
+	return GetSubmissionEmailByIDContext(context.Background(), db, id)
+}
+
+// GetSubmissionEmailBySubmissionIDContext retrieves the email record for a specific submission
+func GetSubmissionEmailBySubmissionIDContext(ctx context.Context, db *sql.DB, submissionID int64) (*SubmissionEmail, error) {
 	var email SubmissionEmail
-	err := db.QueryRow(
+	err := db.QueryRowContext(ctx,
 		"SELECT id, submission_id, sent_at, status, error_message FROM submission_emails WHERE submission_id = ?",
 		submissionID,
 	).Scan(&email.ID, &email.SubmissionID, &email.SentAt, &email.Status, &email.ErrorMessage)
@@ -68,11 +79,21 @@ func GetSubmissionEmailBySubmissionID(db *sql.DB, submissionID int64) (*Submissi
 	return &email, nil
 }
 
-// UpdateSubmissionEmailStatus updates the status of an email record
-func UpdateSubmissionEmailStatus(db *sql.DB, id int64, status, errorMessage string) error {
-	_, err := db.Exec(
+// GetSubmissionEmailBySubmissionID is like GetSubmissionEmailBySubmissionIDContext but uses context.Background
+func GetSubmissionEmailBySubmissionID(db *sql.DB, submissionID int64) (*SubmissionEmail, error) {
+	return GetSubmissionEmailBySubmissionIDContext(context.Background(), db, submissionID)
+}
+
+// UpdateSubmissionEmailStatusContext updates the status of an email record
+func UpdateSubmissionEmailStatusContext(ctx context.Context, db *sql.DB, id int64, status, errorMessage string) error {
+	_, err := db.ExecContext(ctx,
 		"UPDATE submission_emails SET status = ?, error_message = ? WHERE id = ?",
 		status, errorMessage, id,
 	)
 	return err
-}
\ No newline at end of file
+}
+
+// UpdateSubmissionEmailStatus is like UpdateSubmissionEmailStatusContext but uses context.Background
+func UpdateSubmissionEmailStatus(db *sql.DB, id int64, status, errorMessage string) error {
+	return UpdateSubmissionEmailStatusContext(context.Background(), db, id, status, errorMessage)
+}
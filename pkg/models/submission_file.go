@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SubmissionFile is a file uploaded with a submission. The file itself is
+// kept in the uploads store under StorageName, which is never shown to
+// anyone, and is downloaded through signed links.
+type SubmissionFile struct {
+	ID           int64 `json:"id"`
+	SubmissionID int64 `json:"submission_id"`
+	// Field is the name of the form field the file was uploaded with
+	Field       string    `json:"field"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageName string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const submissionFileColumns = "id, submission_id, field, filename, content_type, size, storage_name, created_at FROM submission_files"
+
+// scanSubmissionFile reads a submission file row, returning nil if there
+// isn't one
+func scanSubmissionFile(row rowScanner) (*SubmissionFile, error) {
+	var file SubmissionFile
+	err := row.Scan(&file.ID, &file.SubmissionID, &file.Field, &file.Filename, &file.ContentType, &file.Size, &file.StorageName, &file.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+// CreateSubmissionFileContext records a file uploaded with a submission
+func CreateSubmissionFileContext(ctx context.Context, db *sql.DB, file *SubmissionFile) error {
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO submission_files (submission_id, field, filename, content_type, size, storage_name) VALUES (?, ?, ?, ?, ?, ?)",
+		file.SubmissionID, file.Field, file.Filename, file.ContentType, file.Size, file.StorageName,
+	)
+	if err != nil {
+		return err
+	}
+	file.ID, err = result.LastInsertId()
+	return err
+}
+
+// CreateSubmissionFile is like CreateSubmissionFileContext but uses context.Background
+func CreateSubmissionFile(db *sql.DB, file *SubmissionFile) error {
+	return CreateSubmissionFileContext(context.Background(), db, file)
+}
+
+// GetSubmissionFilesContext returns the files uploaded with a submission,
+// in the order they were uploaded
+func GetSubmissionFilesContext(ctx context.Context, db *sql.DB, submissionID int64) ([]SubmissionFile, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+submissionFileColumns+" WHERE submission_id = ? ORDER BY id", submissionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []SubmissionFile
+	for rows.Next() {
+		file, err := scanSubmissionFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *file)
+	}
+	return files, rows.Err()
+}
+
+// GetSubmissionFiles is like GetSubmissionFilesContext but uses context.Background
+func GetSubmissionFiles(db *sql.DB, submissionID int64) ([]SubmissionFile, error) {
+	return GetSubmissionFilesContext(context.Background(), db, submissionID)
+}
+
+// GetSubmissionFileContext returns one of a submission's files, or nil if
+// the submission has no file with that ID
+func GetSubmissionFileContext(ctx context.Context, db *sql.DB, submissionID, id int64) (*SubmissionFile, error) {
+	return scanSubmissionFile(db.QueryRowContext(ctx, "SELECT "+submissionFileColumns+" WHERE id = ? AND submission_id = ?", id, submissionID))
+}
+
+// GetSubmissionFile is like GetSubmissionFileContext but uses context.Background
+func GetSubmissionFile(db *sql.DB, submissionID, id int64) (*SubmissionFile, error) {
+	return GetSubmissionFileContext(context.Background(), db, submissionID, id)
+}
+
+// SubmissionFileExistsContext reports whether a submission file is kept
+// under a storage name
+func SubmissionFileExistsContext(ctx context.Context, db *sql.DB, storageName string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM submission_files WHERE storage_name = ?", storageName).Scan(&count)
+	return count > 0, err
+}
+
+// SubmissionFileExists is like SubmissionFileExistsContext but uses context.Background
+func SubmissionFileExists(db *sql.DB, storageName string) (bool, error) {
+	return SubmissionFileExistsContext(context.Background(), db, storageName)
+}
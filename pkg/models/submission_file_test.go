@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestSubmissionFiles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "files@example.com", "hashed_password")
+	form, _ := CreateForm(db, user.ID, "contact", "example.com", "secret", "admin@example.com", "files_form_key")
+	submission, _ := CreateSubmission(db, form.ID, "127.0.0.1", "test", []byte(`{"cv":"cv.pdf"}`))
+
+	file := &SubmissionFile{SubmissionID: submission.ID, Field: "cv", Filename: "cv.pdf", ContentType: "application/pdf", Size: 42, StorageName: "upload-abc.pdf"}
+	if err := CreateSubmissionFile(db, file); err != nil {
+		t.Fatalf("Failed to record file: %v", err)
+	}
+	if file.ID == 0 {
+		t.Error("Expected the file's ID to be set")
+	}
+
+	files, err := GetSubmissionFiles(db, submission.ID)
+	if err != nil || len(files) != 1 || files[0].Filename != "cv.pdf" || files[0].Size != 42 {
+		t.Fatalf("Expected the submission's file, got %+v (%v)", files, err)
+	}
+	if found, err := GetSubmissionFile(db, submission.ID, file.ID); err != nil || found == nil || found.StorageName != "upload-abc.pdf" {
+		t.Errorf("Expected to find the file by ID, got %+v (%v)", found, err)
+	}
+	if found, err := GetSubmissionFile(db, submission.ID+1, file.ID); err != nil || found != nil {
+		t.Errorf("Expected no file for another submission, got %+v (%v)", found, err)
+	}
+
+	if exists, err := SubmissionFileExists(db, "upload-abc.pdf"); err != nil || !exists {
+		t.Errorf("Expected the stored file to be referenced (%v)", err)
+	}
+	if err := DeleteSubmission(db, submission.ID); err != nil {
+		t.Fatalf("Failed to delete submission: %v", err)
+	}
+	if exists, _ := SubmissionFileExists(db, "upload-abc.pdf"); exists {
+		t.Error("Expected the file's record to be deleted with its submission")
+	}
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreateSubmission(t *testing.T) {
@@ -111,6 +113,30 @@ func TestGetSubmissionByID(t *testing.T) {
 	}
 }
 
+func TestGetSubmissionForUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, _ := CreateUser(db, "owner@example.com", "hashed_password")
+	other, _ := CreateUser(db, "other@example.com", "hashed_password")
+	form := CreateTestForm(t, db, owner.ID, "contact", "example.com", "turnstile_secret_456", "admin@example.com")
+	submission, err := CreateSubmission(db, form.ID, "192.168.1.1", "Test Browser", json.RawMessage(`{"name": "Ann"}`))
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+
+	found, err := GetSubmissionForUser(db, submission.ID, owner.ID)
+	if err != nil || found == nil || found.FormID != form.ID || string(found.SubmittedData) != `{"name": "Ann"}` {
+		t.Fatalf("Expected the owner to get the submission, got %+v, %v", found, err)
+	}
+	if found, err := GetSubmissionForUser(db, submission.ID, other.ID); err != nil || found != nil {
+		t.Errorf("Expected nil for another user's submission, got %+v, %v", found, err)
+	}
+	if found, err := GetSubmissionForUser(db, 999, owner.ID); err != nil || found != nil {
+		t.Errorf("Expected nil for a missing submission, got %+v, %v", found, err)
+	}
+}
+
 func TestGetSubmissionsByFormID(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -303,4 +329,155 @@ func TestGetSubmissionCountByFormID(t *testing.T) {
 	if count != 0 {
 		t.Errorf("Expected 0 submissions for non-existent form, got %d", count)
 	}
-}
\ No newline at end of file
+}
+func TestGetSubmissionCountsByUserID(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "counts@example.com", "hashed_password")
+	other, _ := CreateUser(db, "other@example.com", "hashed_password")
+	busy := CreateTestForm(t, db, user.ID, "busy", "example.com", "secret", "to@example.com")
+	quiet := CreateTestForm(t, db, user.ID, "quiet", "example.com", "secret", "to@example.com")
+	foreign := CreateTestForm(t, db, other.ID, "foreign", "example.com", "secret", "to@example.com")
+	for _, formID := range []int64{busy.ID, busy.ID, foreign.ID} {
+		if _, err := CreateSubmission(db, formID, "127.0.0.1", "test", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+	}
+
+	counts, err := GetSubmissionCountsByUserID(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to count submissions: %v", err)
+	}
+	if counts[busy.ID] != 2 || counts[quiet.ID] != 0 || len(counts) != 1 {
+		t.Errorf("Expected 2 submissions for the busy form only, got %v", counts)
+	}
+}
+
+func TestGetSubmissionsPage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "filters@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "filters", "example.com", "secret", "to@example.com")
+	var submissions []*Submission
+	for _, data := range []string{`{"name":"a"}`, `{"email":"b@example.com"}`, `{"name":"c","phone":"1"}`} {
+		submission, err := CreateSubmission(db, form.ID, "127.0.0.1", "test", json.RawMessage(data))
+		if err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := SetAssignment(db, submissions[1].ID, &user.ID, InboxStatusDone); err != nil {
+		t.Fatalf("Failed to set assignment: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter SubmissionFilter
+		want   int
+	}{
+		{"no filter", SubmissionFilter{}, 3},
+		{"unassigned submissions are new", SubmissionFilter{InboxStatus: InboxStatusNew}, 2},
+		{"inbox status", SubmissionFilter{InboxStatus: InboxStatusDone}, 1},
+		{"assignee", SubmissionFilter{AssigneeID: user.ID}, 1},
+		{"delivery status", SubmissionFilter{Status: "processed"}, 0},
+		{"received before", SubmissionFilter{To: time.Now().Add(-time.Hour)}, 0},
+		{"received after", SubmissionFilter{From: time.Now().Add(-time.Hour)}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := CountSubmissions(db, form.ID, tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to count submissions: %v", err)
+			}
+			page, err := GetSubmissionsPage(db, form.ID, tt.filter, 10, 0)
+			if err != nil {
+				t.Fatalf("Failed to get submissions: %v", err)
+			}
+			if count != tt.want || len(page) != tt.want {
+				t.Errorf("Expected %d submissions, got a count of %d and %d listed", tt.want, count, len(page))
+			}
+		})
+	}
+
+	page, err := GetSubmissionsPage(db, form.ID, SubmissionFilter{}, 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to get submissions: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != submissions[0].ID {
+		t.Errorf("Expected the oldest submission on the last page, got %+v", page)
+	}
+
+	fields, err := GetSubmissionFieldNames(db, form.ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to get field names: %v", err)
+	}
+	if strings.Join(fields, ",") != "email,name,phone" {
+		t.Errorf("Expected every field in order, got %v", fields)
+	}
+
+	if columns, _ := GetSubmissionColumns(db, user.ID, form.ID); columns != nil {
+		t.Errorf("Expected no columns before they're picked, got %v", columns)
+	}
+	if err := SetSubmissionColumns(db, user.ID, form.ID, []string{"phone", "name"}); err != nil {
+		t.Fatalf("Failed to set columns: %v", err)
+	}
+	columns, err := GetSubmissionColumns(db, user.ID, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to get columns: %v", err)
+	}
+	if strings.Join(columns, ",") != "phone,name" {
+		t.Errorf("Expected the saved columns, got %v", columns)
+	}
+}
+
+func TestSubmissionSpamAndDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, _ := CreateUser(db, "user@example.com", "hashed_password")
+	form := CreateTestForm(t, db, user.ID, "contact", "example.com", "secret", "admin@example.com")
+
+	submission, err := CreateReferredSubmission(db, form.ID, "127.0.0.1", "test", "https://example.com/contact", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	if submission.Referrer != "https://example.com/contact" || submission.SpamAt != nil {
+		t.Errorf("Expected a referred submission not marked as spam, got %+v", submission)
+	}
+
+	if err := SetSubmissionSpam(db, submission.ID, true); err != nil {
+		t.Fatalf("Failed to mark spam: %v", err)
+	}
+	marked, _ := GetSubmissionByID(db, submission.ID)
+	if marked.SpamAt == nil {
+		t.Error("Expected the submission to be marked as spam")
+	}
+	if err := SetSubmissionSpam(db, submission.ID, false); err != nil {
+		t.Fatalf("Failed to clear spam: %v", err)
+	}
+	cleared, _ := GetSubmissionByID(db, submission.ID)
+	if cleared.SpamAt != nil {
+		t.Error("Expected the spam mark to be cleared")
+	}
+
+	if _, err := CreateSubmissionEmail(db, submission.ID, "sent", ""); err != nil {
+		t.Fatalf("Failed to create email record: %v", err)
+	}
+	if _, err := CreateSubmissionNote(db, submission.ID, user.ID, "Called back"); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if err := DeleteSubmission(db, submission.ID); err != nil {
+		t.Fatalf("Failed to delete submission: %v", err)
+	}
+	deleted, err := GetSubmissionByID(db, submission.ID)
+	if err != nil || deleted != nil {
+		t.Errorf("Expected the submission to be gone, got %+v (err %v)", deleted, err)
+	}
+	var remaining int
+	db.QueryRow("SELECT COUNT(*) FROM submission_emails WHERE submission_id = ?", submission.ID).Scan(&remaining)
+	if remaining != 0 {
+		t.Errorf("Expected the email records to be deleted, got %d", remaining)
+	}
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// MaxTagLength is the longest tag name kept; longer names are truncated
+const MaxTagLength = 50
+
+// Tag is a label a user attaches to their forms to organize them
+type Tag struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	FormCount int       `json:"form_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ParseTags splits a comma-separated list of tags, normalizing each to
+// lower case and dropping blanks and duplicates
+func ParseTags(input string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(input, ",") {
+		name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if runes := []rune(name); len(runes) > MaxTagLength {
+			name = strings.TrimSpace(string(runes[:MaxTagLength]))
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+// GetTagsByUserIDContext retrieves a user's tags in name order, with how
+// many forms use each
+func GetTagsByUserIDContext(ctx context.Context, db *sql.DB, userID int64) ([]Tag, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.id, t.user_id, t.name, COUNT(ft.form_id), t.created_at
+		FROM tags t LEFT JOIN form_tags ft ON ft.tag_id = t.id
+		WHERE t.user_id = ?
+		GROUP BY t.id, t.user_id, t.name, t.created_at
+		ORDER BY t.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.FormCount, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetTagsByUserID is like GetTagsByUserIDContext but uses context.Background
+func GetTagsByUserID(db *sql.DB, userID int64) ([]Tag, error) {
+	return GetTagsByUserIDContext(context.Background(), db, userID)
+}
+
+// GetFormTagsContext retrieves the names of a form's tags in name order
+func GetFormTagsContext(ctx context.Context, db *sql.DB, formID int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT t.name FROM tags t JOIN form_tags ft ON ft.tag_id = t.id WHERE ft.form_id = ? ORDER BY t.name",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetFormTags is like GetFormTagsContext but uses context.Background
+func GetFormTags(db *sql.DB, formID int64) ([]string, error) {
+	return GetFormTagsContext(context.Background(), db, formID)
+}
+
+// GetFormTagsByUserIDContext retrieves the tags of every form a user owns,
+// keyed by form ID, so form lists don't need a query per form
+func GetFormTagsByUserIDContext(ctx context.Context, db *sql.DB, userID int64) (map[int64][]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT ft.form_id, t.name FROM tags t JOIN form_tags ft ON ft.tag_id = t.id WHERE t.user_id = ? ORDER BY t.name",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var formID int64
+		var name string
+		if err := rows.Scan(&formID, &name); err != nil {
+			return nil, err
+		}
+		tags[formID] = append(tags[formID], name)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetFormTagsByUserID is like GetFormTagsByUserIDContext but uses context.Background
+func GetFormTagsByUserID(db *sql.DB, userID int64) (map[int64][]string, error) {
+	return GetFormTagsByUserIDContext(context.Background(), db, userID)
+}
+
+// SetFormTagsContext replaces a form's tags, creating any of the user's tags
+// that don't exist yet and removing the form's old tags no form uses any more
+func SetFormTagsContext(ctx context.Context, db *sql.DB, userID, formID int64, names []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	previous, err := formTagIDs(ctx, tx, formID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM form_tags WHERE form_id = ?", formID); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var tagID int64
+		err := tx.QueryRowContext(ctx,
+			"SELECT id FROM tags WHERE user_id = ? AND name = ?",
+			userID, name,
+		).Scan(&tagID)
+		if err == sql.ErrNoRows {
+			result, err := tx.ExecContext(ctx, "INSERT INTO tags (user_id, name) VALUES (?, ?)", userID, name)
+			if err != nil {
+				return err
+			}
+			if tagID, err = result.LastInsertId(); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO form_tags (form_id, tag_id) VALUES (?, ?)",
+			formID, tagID,
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := deleteUnusedTags(ctx, tx, previous); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetFormTags is like SetFormTagsContext but uses context.Background
+func SetFormTags(db *sql.DB, userID, formID int64, names []string) error {
+	return SetFormTagsContext(context.Background(), db, userID, formID, names)
+}
+
+// formTagIDs returns the IDs of a form's tags
+func formTagIDs(ctx context.Context, tx *sql.Tx, formID int64) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT tag_id FROM form_tags WHERE form_id = ?", formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteUnusedTags deletes the tags, out of tagIDs, that no form uses any
+// more. Only tags a form has just let go of are given, so the user's other
+// tags are left alone.
+func deleteUnusedTags(ctx context.Context, tx *sql.Tx, tagIDs []int64) error {
+	for _, id := range tagIDs {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM tags WHERE id = ? AND NOT EXISTS (SELECT 1 FROM form_tags WHERE tag_id = ?)",
+			id, id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetFormsByUserIDAndTagContext retrieves a user's forms that have a tag
+func GetFormsByUserIDAndTagContext(ctx context.Context, db *sql.DB, userID int64, tag string) ([]Form, error) {
+	return queryForms(ctx, db,
+		`SELECT f.id, f.user_id, f.name, f.domain, f.turnstile_secret, f.turnstile_site_key, f.forward_email, f.form_key, f.akismet_enabled, f.thread_by_sender, f.turnstile_fallback, f.spam_flag_threshold, f.spam_reject_threshold, f.allowed_countries, f.blocked_countries, f.email_attachment, f.hosted_page, f.allow_cc, f.created_at, f.updated_at
+		FROM forms f
+		JOIN form_tags ft ON ft.form_id = f.id
+		JOIN tags t ON t.id = ft.tag_id
+		WHERE f.user_id = ? AND t.name = ?
+		ORDER BY f.created_at DESC`,
+		userID, tag,
+	)
+}
+
+// GetFormsByUserIDAndTag is like GetFormsByUserIDAndTagContext but uses context.Background
+func GetFormsByUserIDAndTag(db *sql.DB, userID int64, tag string) ([]Form, error) {
+	return GetFormsByUserIDAndTagContext(context.Background(), db, userID, tag)
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := map[string][]string{
+		"":                             {},
+		"Client A, newsletter":         {"client a", "newsletter"},
+		" support ,,SUPPORT,  sales  ": {"support", "sales"},
+	}
+
+	for input, want := range tests {
+		if got := ParseTags(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseTags(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestSetFormTags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "tags@example.com", "hashed_password")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	contact, err := CreateForm(db, user.ID, "Contact", "a.example.com", "secret", "to@example.com", "tags-contact")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	signup, err := CreateForm(db, user.ID, "Signup", "b.example.com", "secret", "to@example.com", "tags-signup")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	if err := SetFormTags(db, user.ID, contact.ID, []string{"client-a", "support"}); err != nil {
+		t.Fatalf("Failed to set tags: %v", err)
+	}
+	if err := SetFormTags(db, user.ID, signup.ID, []string{"client-a"}); err != nil {
+		t.Fatalf("Failed to set tags: %v", err)
+	}
+
+	forms, err := GetFormsByUserIDAndTag(db, user.ID, "client-a")
+	if err != nil {
+		t.Fatalf("Failed to filter forms: %v", err)
+	}
+	if len(forms) != 2 {
+		t.Errorf("Expected 2 forms tagged client-a, got %d", len(forms))
+	}
+	forms, err = GetFormsByUserIDAndTag(db, user.ID, "support")
+	if err != nil {
+		t.Fatalf("Failed to filter forms: %v", err)
+	}
+	if len(forms) != 1 || forms[0].ID != contact.ID {
+		t.Errorf("Expected only the contact form tagged support, got %+v", forms)
+	}
+
+	byForm, err := GetFormTagsByUserID(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get tags by form: %v", err)
+	}
+	if !reflect.DeepEqual(byForm[contact.ID], []string{"client-a", "support"}) {
+		t.Errorf("Unexpected contact form tags %q", byForm[contact.ID])
+	}
+
+	// Removing the last use of a tag deletes it, but not the user's tags
+	// that aren't on any form yet
+	if _, err := db.Exec("INSERT INTO tags (user_id, name) VALUES (?, ?)", user.ID, "unused"); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := SetFormTags(db, user.ID, contact.ID, []string{"client-a"}); err != nil {
+		t.Fatalf("Failed to set tags: %v", err)
+	}
+	tags, err := GetTagsByUserID(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if len(tags) != 2 || tags[0].Name != "client-a" || tags[0].FormCount != 2 || tags[1].Name != "unused" {
+		t.Errorf("Expected client-a used by 2 forms and the unattached tag, got %+v", tags)
+	}
+
+	// Deleting a form removes its tags once no other form uses them
+	if err := DeleteForm(db, contact.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	if err := DeleteForm(db, signup.ID); err != nil {
+		t.Fatalf("Failed to delete form: %v", err)
+	}
+	tags, err = GetTagsByUserID(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if len(tags) != 1 || tags[0].Name != "unused" {
+		t.Errorf("Expected only the unattached tag left, got %+v", tags)
+	}
+}
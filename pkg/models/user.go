@@ -1,44 +1,128 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
 
+// User roles
+const (
+	// RoleAdmin can manage application-wide settings in addition to their own forms
+	RoleAdmin = "admin"
+	// RoleUser can manage their own forms and submissions
+	RoleUser = "user"
+)
+
+// User themes
+const (
+	// ThemeSystem follows the browser's light or dark preference
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+// Themes lists the themes a user can choose, in display order
+var Themes = []string{ThemeSystem, ThemeLight, ThemeDark}
+
 // User represents a user account in the system
 type User struct {
 	ID           int64     `json:"id"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	Theme        string    `json:"theme"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
-// CreateUser creates a new user in the database
+// CreateUserContext creates a new user in the database. The first user to register
+// becomes the administrator.
+func CreateUserContext(ctx context.Context, db *sql.DB, email, passwordHash string) (*User, error) {
+	return createUser(ctx, db, email, passwordHash, false)
+}
+
+// CreateUser is like CreateUserContext but uses context.Background
 func CreateUser(db *sql.DB, email, passwordHash string) (*User, error) {
-	result, err := db.Exec(
-		"INSERT INTO users (email, password_hash) VALUES (?, ?)",
-		email, passwordHash,
-	)
+	return CreateUserContext(context.Background(), db, email, passwordHash)
+}
+
+// CreateFirstUserContext creates the administrator while the database has no
+// users, returning nil if another account got there first
+func CreateFirstUserContext(ctx context.Context, db *sql.DB, email, passwordHash string) (*User, error) {
+	return createUser(ctx, db, email, passwordHash, true)
+}
+
+// CreateFirstUser is like CreateFirstUserContext but uses context.Background
+func CreateFirstUser(db *sql.DB, email, passwordHash string) (*User, error) {
+	return CreateFirstUserContext(context.Background(), db, email, passwordHash)
+}
+
+// createUser adds a user, as the administrator if there are no others. Sign-ups
+// lock the signup_lock row first, so they take turns on every database and
+// only one can find there are none; each statement in a transaction sees the
+// accounts committed before it ran. With firstOnly, nothing is created once
+// an account exists.
+func createUser(ctx context.Context, db *sql.DB, email, passwordHash string, firstOnly bool) (*User, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE signup_lock SET locked_at = CURRENT_TIMESTAMP WHERE id = 1"); err != nil {
+		return nil, err
+	}
+	var hasUsers bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users)").Scan(&hasUsers); err != nil {
+		return nil, err
+	}
+	if hasUsers && firstOnly {
+		return nil, nil
+	}
+	role := RoleAdmin
+	if hasUsers {
+		role = RoleUser
+	}
 
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO users (email, password_hash, role) VALUES (?, ?, ?)",
+		email, passwordHash, role,
+	)
+	if err != nil {
+		return nil, err
+	}
 	id, err := result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-	return GetUserByID(db, id)
+	return GetUserByIDContext(ctx, db, id)
 }
 
-// GetUserByID retrieves a user by their ID
-func GetUserByID(db *sql.DB, id int64) (*User, error) {
+// HasUsersContext reports whether any account exists. Until one does, the
+// instance hasn't been set up.
+func HasUsersContext(ctx context.Context, db *sql.DB) (bool, error) {
+	var hasUsers bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users)").Scan(&hasUsers)
+	return hasUsers, err
+}
+
+// HasUsers is like HasUsersContext but uses context.Background
+func HasUsers(db *sql.DB) (bool, error) {
+	return HasUsersContext(context.Background(), db)
+}
+
+// GetUserByIDContext retrieves a user by their ID
+func GetUserByIDContext(ctx context.Context, db *sql.DB, id int64) (*User, error) {
 	var user User
-	err := db.QueryRow(
-		"SELECT id, email, password_hash, created_at, updated_at FROM users WHERE id = ?",
+	err := db.QueryRowContext(ctx,
+		"SELECT id, email, password_hash, role, theme, created_at, updated_at FROM users WHERE id = ?",
 		id,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.Theme, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -50,13 +134,18 @@ func GetUserByID(db *sql.DB, id int64) (*User, error) {
 	return &user, nil
 }
 
-// GetUserByEmail retrieves a user by their email
-func GetUserByEmail(db *sql.DB, email string) (*User, error) {
+// GetUserByID is like GetUserByIDContext but uses context.Background
+func GetUserByID(db *sql.DB, id int64) (*User, error) {
+	return GetUserByIDContext(context.Background(), db, id)
+}
+
+// GetUserByEmailContext retrieves a user by their email
+func GetUserByEmailContext(ctx context.Context, db *sql.DB, email string) (*User, error) {
 	var user User
-	err := db.QueryRow(
-		"SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email = ?",
+	err := db.QueryRowContext(ctx,
+		"SELECT id, email, password_hash, role, theme, created_at, updated_at FROM users WHERE email = ?",
 		email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.Theme, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -68,13 +157,83 @@ func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 	return &user, nil
 }
 
-// UserExists checks if a user with the given email already exists
-func UserExists(db *sql.DB, email string) (bool, error) {
+// GetUserByEmail is like GetUserByEmailContext but uses context.Background
+func GetUserByEmail(db *sql.DB, email string) (*User, error) {
+	return GetUserByEmailContext(context.Background(), db, email)
+}
+
+// UserExistsContext checks if a user with the given email already exists
+func UserExistsContext(ctx context.Context, db *sql.DB, email string) (bool, error) {
 	var exists bool
-	err := db.QueryRow(
+	err := db.QueryRowContext(ctx,
 		"SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)",
 		email,
 	).Scan(&exists)
 
 	return exists, err
-}
\ No newline at end of file
+}
+
+// UserExists is like UserExistsContext but uses context.Background
+func UserExists(db *sql.DB, email string) (bool, error) {
+	return UserExistsContext(context.Background(), db, email)
+}
+
+// IsAdmin reports whether the user has the administrator role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// UpdateUserRoleContext changes a user's role
+func UpdateUserRoleContext(ctx context.Context, db *sql.DB, id int64, role string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE users SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		role, id,
+	)
+	return err
+}
+
+// UpdateUserRole is like UpdateUserRoleContext but uses context.Background
+func UpdateUserRole(db *sql.DB, id int64, role string) error {
+	return UpdateUserRoleContext(context.Background(), db, id, role)
+}
+
+// UpdateUserThemeContext changes a user's theme
+func UpdateUserThemeContext(ctx context.Context, db *sql.DB, id int64, theme string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE users SET theme = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		theme, id,
+	)
+	return err
+}
+
+// UpdateUserTheme is like UpdateUserThemeContext but uses context.Background
+func UpdateUserTheme(db *sql.DB, id int64, theme string) error {
+	return UpdateUserThemeContext(context.Background(), db, id, theme)
+}
+
+// GetAllUsersContext retrieves every user, oldest first
+func GetAllUsersContext(ctx context.Context, db *sql.DB) ([]User, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, email, password_hash, role, theme, created_at, updated_at FROM users ORDER BY id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.Theme, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// GetAllUsers is like GetAllUsersContext but uses context.Background
+func GetAllUsers(db *sql.DB) ([]User, error) {
+	return GetAllUsersContext(context.Background(), db)
+}
@@ -9,6 +9,51 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// testMigrations lists the migration files applied to test databases
+var testMigrations = []string{
+	"001_initial_schema.up.sql",
+	"002_app_settings.up.sql",
+	"003_update_form_schema.up.sql",
+	"005_ip_rules.up.sql",
+	"006_maintenance_mode.up.sql",
+	"007_user_roles.up.sql",
+	"008_query_indexes.up.sql",
+	"009_submission_archives.up.sql",
+	"010_user_quotas.up.sql",
+	"011_form_tags.up.sql",
+	"012_submission_inbox.up.sql",
+	"013_submission_columns.up.sql",
+	"014_user_theme.up.sql",
+	"015_submission_details.up.sql",
+	"016_setup_settings.up.sql",
+	"017_branding.up.sql",
+	"018_form_status_pages.up.sql",
+	"019_spam_queue.up.sql",
+	"020_export_jobs.up.sql",
+	"021_notification_channels.up.sql",
+	"022_google_sheets.up.sql",
+	"023_api_keys.up.sql",
+	"024_channel_events.up.sql",
+	"025_submission_files.up.sql",
+	"026_akismet.up.sql",
+	"027_submission_feeds.up.sql",
+	"028_email_threading.up.sql",
+	"029_auth_turnstile_settings.up.sql",
+	"030_turnstile_fallback.up.sql",
+	"031_turnstile_site_key.up.sql",
+	"032_spam_scoring.up.sql",
+	"033_geoip.up.sql",
+	"034_data_requests.up.sql",
+	"035_form_fields.up.sql",
+	"036_email_bounces.up.sql",
+	"037_notification_mutes.up.sql",
+	"038_form_routes.up.sql",
+	"039_email_attachment.up.sql",
+	"040_hosted_page.up.sql",
+	"041_form_cc.up.sql",
+	"042_signup_lock.up.sql",
+}
+
 func setupTestDB(t *testing.T) *sql.DB {
 	tempDir := t.TempDir()
 	dbPath := filepath.Join(tempDir, "test.db")
@@ -23,34 +68,16 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("Failed to enable foreign keys: %v", err)
 	}
 
-	// Run initial migration
-	migrationSQL, err := os.ReadFile("../../migrations/001_initial_schema.up.sql")
-	if err != nil {
-		t.Fatalf("Failed to read migration file: %v", err)
-	}
-
-	if _, err := db.Exec(string(migrationSQL)); err != nil {
-		t.Fatalf("Failed to execute migration: %v", err)
-	}
-
-	// Run app settings migration
-	migrationSQL, err = os.ReadFile("../../migrations/002_app_settings.up.sql")
-	if err != nil {
-		t.Fatalf("Failed to read migration file: %v", err)
-	}
-
-	if _, err := db.Exec(string(migrationSQL)); err != nil {
-		t.Fatalf("Failed to execute migration: %v", err)
-	}
-
-	// Run form schema update migration
-	migrationSQL, err = os.ReadFile("../../migrations/003_update_form_schema.up.sql")
-	if err != nil {
-		t.Fatalf("Failed to read migration file: %v", err)
-	}
+	// Run migrations in order
+	for _, file := range testMigrations {
+		migrationSQL, err := os.ReadFile("../../migrations/" + file)
+		if err != nil {
+			t.Fatalf("Failed to read migration file: %v", err)
+		}
 
-	if _, err := db.Exec(string(migrationSQL)); err != nil {
-		t.Fatalf("Failed to execute migration: %v", err)
+		if _, err := db.Exec(string(migrationSQL)); err != nil {
+			t.Fatalf("Failed to execute migration %s: %v", file, err)
+		}
 	}
 
 	return db
@@ -180,4 +207,67 @@ func TestUserExists(t *testing.T) {
 	if exists {
 		t.Error("Expected user to not exist")
 	}
-}
\ No newline at end of file
+}
+func TestUserRoles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	first, err := CreateUser(db, "first@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if !first.IsAdmin() {
+		t.Errorf("Expected first user to be an admin, got role %q", first.Role)
+	}
+
+	second, err := CreateUser(db, "second@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if second.Role != RoleUser {
+		t.Errorf("Expected second user to have role %q, got %q", RoleUser, second.Role)
+	}
+
+	if err := UpdateUserRole(db, second.ID, RoleAdmin); err != nil {
+		t.Fatalf("Failed to update role: %v", err)
+	}
+	updated, err := GetUserByID(db, second.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if !updated.IsAdmin() {
+		t.Errorf("Expected user to be promoted to admin, got role %q", updated.Role)
+	}
+
+	if err := UpdateUserRole(db, second.ID, "superuser"); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+}
+
+func TestUserTheme(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "theme@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if user.Theme != ThemeSystem {
+		t.Errorf("Expected new users to follow the system theme, got %q", user.Theme)
+	}
+
+	if err := UpdateUserTheme(db, user.ID, ThemeDark); err != nil {
+		t.Fatalf("Failed to update theme: %v", err)
+	}
+	updated, err := GetUserByID(db, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if updated.Theme != ThemeDark {
+		t.Errorf("Expected theme %q, got %q", ThemeDark, updated.Theme)
+	}
+
+	if err := UpdateUserTheme(db, user.ID, "purple"); err == nil {
+		t.Error("Expected error for unknown theme")
+	}
+}
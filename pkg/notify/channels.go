@@ -0,0 +1,647 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+	"staticsend/pkg/sanitize"
+	"staticsend/pkg/unsubscribe"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook's body, keyed with
+// the channel's secret, as "sha256=<hex>"
+const SignatureHeader = "X-Staticsend-Signature"
+
+// VersionedSignatureHeader is SignatureHeader followed by a secret's
+// version, such as X-Staticsend-Signature-V2. Webhooks are signed with the
+// current secret and, while it's still accepted after a rotation, the
+// previous one, so receivers can move to the new secret when they're ready.
+const VersionedSignatureHeader = SignatureHeader + "-V"
+
+// Settings a webhook channel keeps about its secret's rotation, besides
+// those entered for it
+const (
+	webhookSecretVersion   = "secret_version"
+	webhookPreviousSecret  = "previous_secret"
+	webhookPreviousVersion = "previous_version"
+	webhookPreviousExpires = "previous_expires"
+)
+
+// defaultClient makes channels' requests when Deps has no client
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// APIs of the chat and push services, replaced in tests
+var (
+	telegramAPI = "https://api.telegram.org"
+	pushoverAPI = "https://api.pushover.net/1/messages.json"
+)
+
+func init() {
+	Register(Kind{
+		Name:  "email",
+		Label: "Email",
+		Fields: []Field{
+			{Name: "to", Label: "Recipients", Placeholder: "team@example.com, alerts@example.com", Required: true},
+		},
+		New: newEmailChannel,
+	})
+	Register(Kind{
+		Name:  "webhook",
+		Label: "Webhook",
+		Fields: []Field{
+			{Name: "url", Label: "URL", Placeholder: "https://example.com/hooks/staticsend", Required: true},
+			{Name: "secret", Label: "Signing secret", Secret: true},
+		},
+		New: newWebhookChannel,
+	})
+	Register(Kind{
+		Name:  "slack",
+		Label: "Slack",
+		Fields: []Field{
+			{Name: "webhook_url", Label: "Incoming webhook URL", Placeholder: "https://hooks.slack.com/services/...", Required: true, Secret: true},
+		},
+		New: newChatWebhook("text", 0),
+	})
+	Register(Kind{
+		Name:  "discord",
+		Label: "Discord",
+		Fields: []Field{
+			{Name: "webhook_url", Label: "Webhook URL", Placeholder: "https://discord.com/api/webhooks/...", Required: true, Secret: true},
+		},
+		// Discord rejects messages over 2000 characters
+		New: newChatWebhook("content", 2000),
+	})
+	Register(Kind{
+		Name:  "mattermost",
+		Label: "Mattermost",
+		Fields: []Field{
+			{Name: "webhook_url", Label: "Incoming webhook URL", Placeholder: "https://chat.example.com/hooks/...", Required: true, Secret: true},
+		},
+		// Mattermost rejects posts over 16383 characters
+		New: newChatWebhook("text", 16383),
+	})
+	Register(Kind{
+		Name:  "teams",
+		Label: "Microsoft Teams",
+		Fields: []Field{
+			{Name: "webhook_url", Label: "Workflow or incoming webhook URL", Placeholder: "https://....logic.azure.com/workflows/...", Required: true, Secret: true},
+		},
+		New: newTeamsChannel,
+	})
+	Register(Kind{
+		Name:  "matrix",
+		Label: "Matrix",
+		Fields: []Field{
+			{Name: "homeserver", Label: "Homeserver", Placeholder: "https://matrix.org", Required: true},
+			{Name: "access_token", Label: "Access token", Required: true, Secret: true},
+			{Name: "room_id", Label: "Room ID", Placeholder: "!abcdefghijklmnop:matrix.org", Required: true},
+		},
+		New: newMatrixChannel,
+	})
+	Register(Kind{
+		Name:  "telegram",
+		Label: "Telegram",
+		Fields: []Field{
+			{Name: "bot_token", Label: "Bot token", Required: true, Secret: true},
+			{Name: "chat_id", Label: "Chat ID", Placeholder: "-1001234567890", Required: true},
+		},
+		New: newTelegramChannel,
+	})
+	Register(Kind{
+		Name:  "ntfy",
+		Label: "ntfy",
+		Fields: []Field{
+			{Name: "server", Label: "Server", Placeholder: "https://ntfy.sh"},
+			{Name: "topic", Label: "Topic", Required: true},
+			{Name: "token", Label: "Access token", Secret: true},
+			{Name: "priority", Label: "Priority", Placeholder: "1 (min) to 5 (urgent), 3 by default"},
+		},
+		New: newNtfyChannel,
+	})
+	Register(Kind{
+		Name:  "pushover",
+		Label: "Pushover",
+		Fields: []Field{
+			{Name: "app_token", Label: "Application token", Required: true, Secret: true},
+			{Name: "user_key", Label: "User or group key", Required: true, Secret: true},
+			{Name: "device", Label: "Device", Placeholder: "All devices"},
+			{Name: "priority", Label: "Priority", Placeholder: "-2 (lowest) to 1 (high), 0 by default"},
+		},
+		New: newPushoverChannel,
+	})
+}
+
+// httpClient returns the client channels make requests with
+func httpClient(deps Deps) *http.Client {
+	if deps.Client != nil {
+		return deps.Client
+	}
+	return defaultClient
+}
+
+// parseHTTPURL checks that a setting is an absolute http or https URL
+func parseHTTPURL(label, value string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%s must be an http or https URL", label)
+	}
+	return u.String(), nil
+}
+
+// statusError is a response from a channel's destination that wasn't a 2xx
+type statusError struct {
+	code   int
+	detail string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.code, e.detail)
+}
+
+// post sends body to target and fails with a *statusError unless the
+// response is a 2xx
+func post(ctx context.Context, client *http.Client, target, contentType string, body []byte, header http.Header) error {
+	return send(ctx, client, http.MethodPost, target, contentType, body, header)
+}
+
+// send is like post but with any method
+func send(ctx context.Context, client *http.Client, method, target, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "staticSend")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return &statusError{code: resp.StatusCode, detail: strings.TrimSpace(string(detail))}
+	}
+	return nil
+}
+
+// emailChannel emails messages to extra recipients, alongside the form's
+// forwarding address
+type emailChannel struct {
+	to      []string
+	service *email.EmailService
+	db      *database.Database
+	links   *unsubscribe.Links
+}
+
+func newEmailChannel(config map[string]string, deps Deps) (Channel, error) {
+	var to []string
+	for _, address := range strings.Split(config["to"], ",") {
+		if address = strings.TrimSpace(address); address == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(address); err != nil {
+			return nil, fmt.Errorf("invalid recipient %q", address)
+		}
+		to = append(to, address)
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("Recipients is required")
+	}
+	return &emailChannel{to: to, service: deps.Email, db: deps.DB, links: deps.Unsubscribe}, nil
+}
+
+func (c *emailChannel) Send(ctx context.Context, msg Message) error {
+	if c.service == nil {
+		return fmt.Errorf("email isn't configured")
+	}
+	to := c.to
+	if c.db != nil {
+		unmuted, err := models.UnmutedRecipientsContext(ctx, c.db.Connection, msg.FormID, c.to)
+		if err != nil {
+			return err
+		}
+		to = unmuted
+	}
+	job := email.EmailJob{
+		To:      to,
+		Subject: msg.Title(),
+		Body:    msg.Text(),
+		Thread:  email.FormThread(msg.FormID),
+	}
+	if len(to) == 0 {
+		// Everyone muted the form
+		return nil
+	}
+	if c.links == nil {
+		return c.service.SendJob(job)
+	}
+
+	// Each recipient gets an email with their own unsubscribe link
+	var errs []error
+	for _, address := range to {
+		personal := job
+		personal.To = []string{address}
+		email.AddUnsubscribe(&personal, c.links.URL(msg.FormID, address))
+		if err := c.service.SendJob(personal); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// webhookChannel posts messages as JSON, signed when it has a secret
+type webhookChannel struct {
+	url    string
+	secret WebhookSecret
+	client *http.Client
+}
+
+func newWebhookChannel(config map[string]string, deps Deps) (Channel, error) {
+	target, err := parseHTTPURL("URL", config["url"])
+	if err != nil {
+		return nil, err
+	}
+	return &webhookChannel{url: target, secret: ReadWebhookSecret(config), client: httpClient(deps)}, nil
+}
+
+func (c *webhookChannel) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if c.secret.Secret != "" {
+		signature := sign(c.secret.Secret, body)
+		header.Set(SignatureHeader, signature)
+		header.Set(VersionedSignatureHeader+strconv.Itoa(c.secret.Version), signature)
+	}
+	if c.secret.PreviousAccepted(time.Now()) {
+		header.Set(VersionedSignatureHeader+strconv.Itoa(c.secret.PreviousVersion), sign(c.secret.Previous, body))
+	}
+	return post(ctx, c.client, c.url, "application/json", body, header)
+}
+
+// sign returns the HMAC-SHA256 of a body as "sha256=<hex>"
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSecret is a webhook channel's signing secret, with the one it
+// replaced while that's still accepted
+type WebhookSecret struct {
+	Secret  string
+	Version int
+	// Previous is signed with too until PreviousExpires
+	Previous        string
+	PreviousVersion int
+	PreviousExpires time.Time
+}
+
+// ReadWebhookSecret returns the signing secret in a webhook channel's
+// settings. Secrets entered before rotation was added are version 1.
+func ReadWebhookSecret(config map[string]string) WebhookSecret {
+	secret := WebhookSecret{Secret: config["secret"], Version: 1, Previous: config[webhookPreviousSecret]}
+	if version, err := strconv.Atoi(config[webhookSecretVersion]); err == nil && version > 0 {
+		secret.Version = version
+	}
+	if secret.Previous != "" {
+		secret.PreviousVersion, _ = strconv.Atoi(config[webhookPreviousVersion])
+		secret.PreviousExpires, _ = time.Parse(time.RFC3339, config[webhookPreviousExpires])
+	}
+	return secret
+}
+
+// PreviousAccepted reports whether messages are still signed with the
+// previous secret
+func (s WebhookSecret) PreviousAccepted(now time.Time) bool {
+	return s.Previous != "" && now.Before(s.PreviousExpires)
+}
+
+// RotateWebhookSecret returns a webhook channel's settings with a new,
+// random signing secret, and the new secret. The one it replaces keeps
+// signing messages for grace, so receivers aren't cut off before they've
+// been given the new one. A channel without a secret just gets one.
+func RotateWebhookSecret(config map[string]string, grace time.Duration, now time.Time) (map[string]string, WebhookSecret, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, WebhookSecret{}, err
+	}
+	current := ReadWebhookSecret(config)
+
+	rotated := make(map[string]string, len(config)+4)
+	for key, value := range config {
+		rotated[key] = value
+	}
+	delete(rotated, webhookPreviousSecret)
+	delete(rotated, webhookPreviousVersion)
+	delete(rotated, webhookPreviousExpires)
+
+	secret := WebhookSecret{Secret: "whsec_" + hex.EncodeToString(random), Version: current.Version}
+	if current.Secret != "" {
+		secret.Version++
+		if grace > 0 {
+			secret.Previous = current.Secret
+			secret.PreviousVersion = current.Version
+			secret.PreviousExpires = now.Add(grace).UTC().Truncate(time.Second)
+			rotated[webhookPreviousSecret] = secret.Previous
+			rotated[webhookPreviousVersion] = strconv.Itoa(secret.PreviousVersion)
+			rotated[webhookPreviousExpires] = secret.PreviousExpires.Format(time.RFC3339)
+		}
+	}
+	rotated["secret"] = secret.Secret
+	rotated[webhookSecretVersion] = strconv.Itoa(secret.Version)
+	return rotated, secret, nil
+}
+
+// chatWebhook posts messages to a chat app's incoming webhook as a JSON
+// object with the text in one property
+type chatWebhook struct {
+	url      string
+	property string
+	limit    int
+	client   *http.Client
+}
+
+// newChatWebhook returns the constructor for a chat webhook putting the text
+// in property, cut to limit characters when limit isn't 0
+func newChatWebhook(property string, limit int) func(map[string]string, Deps) (Channel, error) {
+	return func(config map[string]string, deps Deps) (Channel, error) {
+		target, err := parseHTTPURL("Webhook URL", config["webhook_url"])
+		if err != nil {
+			return nil, err
+		}
+		return &chatWebhook{url: target, property: property, limit: limit, client: httpClient(deps)}, nil
+	}
+}
+
+func (c *chatWebhook) Send(ctx context.Context, msg Message) error {
+	text := truncate("*"+msg.Title()+"*\n"+msg.Text(), c.limit)
+	body, err := json.Marshal(map[string]string{c.property: text})
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.client, c.url, "application/json", body, nil)
+}
+
+// teamsChannel posts messages to a Microsoft Teams channel as an Adaptive
+// Card, which both Workflows webhooks and the older incoming webhooks accept
+type teamsChannel struct {
+	url    string
+	client *http.Client
+}
+
+func newTeamsChannel(config map[string]string, deps Deps) (Channel, error) {
+	target, err := parseHTTPURL("Webhook URL", config["webhook_url"])
+	if err != nil {
+		return nil, err
+	}
+	return &teamsChannel{url: target, client: httpClient(deps)}, nil
+}
+
+func (c *teamsChannel) Send(ctx context.Context, msg Message) error {
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []map[string]interface{}{
+			{"type": "TextBlock", "text": msg.Title(), "weight": "Bolder", "size": "Medium", "wrap": true},
+			// Teams rejects cards over about 28KB
+			{"type": "TextBlock", "text": truncate(msg.Text(), 20000), "wrap": true},
+		},
+	}
+	if msg.URL != "" {
+		card["actions"] = []map[string]string{
+			{"type": "Action.OpenUrl", "title": "View submissions", "url": msg.URL},
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.client, c.url, "application/json", body, nil)
+}
+
+// matrixChannel sends messages to a Matrix room as the user whose access
+// token it has
+type matrixChannel struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newMatrixChannel(config map[string]string, deps Deps) (Channel, error) {
+	homeserver, err := parseHTTPURL("Homeserver", config["homeserver"])
+	if err != nil {
+		return nil, err
+	}
+	token, roomID := strings.TrimSpace(config["access_token"]), strings.TrimSpace(config["room_id"])
+	if token == "" {
+		return nil, fmt.Errorf("Access token is required")
+	}
+	// Aliases like #room:server would need looking up first, so the room's
+	// ID, from its advanced settings, is asked for instead
+	if !strings.HasPrefix(roomID, "!") || !strings.Contains(roomID, ":") {
+		return nil, fmt.Errorf("Room ID must look like !abcdef:example.org")
+	}
+	return &matrixChannel{
+		url:    strings.TrimSuffix(homeserver, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/",
+		token:  token,
+		client: httpClient(deps),
+	}, nil
+}
+
+func (c *matrixChannel) Send(ctx context.Context, msg Message) error {
+	text := msg.Text()
+	body, err := json.Marshal(map[string]string{
+		"msgtype":        "m.text",
+		"body":           msg.Title() + "\n\n" + text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": "<strong>" + sanitize.HTML(msg.Title()) + "</strong><br><br>" + sanitize.HTMLWithBreaks(text),
+	})
+	if err != nil {
+		return err
+	}
+	// The transaction ID is the same for every attempt at a message, so the
+	// homeserver ignores retries of one that did arrive
+	sum := sha256.Sum256(append([]byte(msg.Event+msg.CreatedAt.String()+"\n"), body...))
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+	return send(ctx, c.client, http.MethodPut, c.url+hex.EncodeToString(sum[:16]), "application/json", body, header)
+}
+
+// telegramChannel sends messages from a bot to a chat
+type telegramChannel struct {
+	token  string
+	chatID string
+	client *http.Client
+}
+
+func newTelegramChannel(config map[string]string, deps Deps) (Channel, error) {
+	token, chatID := strings.TrimSpace(config["bot_token"]), strings.TrimSpace(config["chat_id"])
+	if token == "" || chatID == "" {
+		return nil, fmt.Errorf("Bot token and Chat ID are required")
+	}
+	return &telegramChannel{token: token, chatID: chatID, client: httpClient(deps)}, nil
+}
+
+func (c *telegramChannel) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": c.chatID,
+		// Telegram rejects messages over 4096 characters
+		"text": truncate(msg.Title()+"\n\n"+msg.Text(), 4096),
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.client, telegramAPI+"/bot"+c.token+"/sendMessage", "application/json", body, nil)
+}
+
+// ntfyChannel publishes messages to an ntfy topic
+type ntfyChannel struct {
+	url      string
+	token    string
+	priority string
+	client   *http.Client
+}
+
+func newNtfyChannel(config map[string]string, deps Deps) (Channel, error) {
+	server := config["server"]
+	if strings.TrimSpace(server) == "" {
+		server = "https://ntfy.sh"
+	}
+	server, err := parseHTTPURL("Server", server)
+	if err != nil {
+		return nil, err
+	}
+	topic := strings.TrimSpace(config["topic"])
+	if topic == "" || strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("Topic must be a single name")
+	}
+	priority, err := parsePriority(config["priority"], 1, 5)
+	if err != nil {
+		return nil, err
+	}
+	return &ntfyChannel{
+		url:      strings.TrimSuffix(server, "/") + "/" + url.PathEscape(topic),
+		token:    config["token"],
+		priority: priority,
+		client:   httpClient(deps),
+	}, nil
+}
+
+func (c *ntfyChannel) Send(ctx context.Context, msg Message) error {
+	header := http.Header{}
+	header.Set("Title", msg.Title())
+	if msg.URL != "" {
+		header.Set("Click", msg.URL)
+	}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.priority != "" {
+		header.Set("Priority", c.priority)
+	}
+	return post(ctx, c.client, c.url, "text/plain; charset=utf-8", []byte(msg.Text()), header)
+}
+
+// pushoverChannel pushes messages to a Pushover user's or group's devices
+type pushoverChannel struct {
+	appToken string
+	userKey  string
+	device   string
+	priority string
+	client   *http.Client
+}
+
+func newPushoverChannel(config map[string]string, deps Deps) (Channel, error) {
+	appToken, userKey := strings.TrimSpace(config["app_token"]), strings.TrimSpace(config["user_key"])
+	if appToken == "" || userKey == "" {
+		return nil, fmt.Errorf("Application token and User key are required")
+	}
+	// Priority 2 needs retry and expire settings for its acknowledgements,
+	// so emergency notifications aren't offered
+	priority, err := parsePriority(config["priority"], -2, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &pushoverChannel{
+		appToken: appToken,
+		userKey:  userKey,
+		device:   strings.TrimSpace(config["device"]),
+		priority: priority,
+		client:   httpClient(deps),
+	}, nil
+}
+
+func (c *pushoverChannel) Send(ctx context.Context, msg Message) error {
+	form := url.Values{
+		"token": {c.appToken},
+		"user":  {c.userKey},
+		// Pushover rejects titles over 250 characters and messages over 1024
+		"title":   {truncate(msg.Title(), 250)},
+		"message": {truncate(msg.Text(), 1024)},
+	}
+	if msg.URL != "" {
+		form.Set("url", msg.URL)
+		form.Set("url_title", "View submissions")
+	}
+	if c.device != "" {
+		form.Set("device", c.device)
+	}
+	if c.priority != "" {
+		form.Set("priority", c.priority)
+	}
+	return post(ctx, c.client, pushoverAPI, "application/x-www-form-urlencoded", []byte(form.Encode()), nil)
+}
+
+// parsePriority checks that a priority setting is a whole number from low
+// to high, returning it as text, or empty when it isn't set
+func parsePriority(value string, low, high int) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil || priority < low || priority > high {
+		return "", fmt.Errorf("Priority must be a number from %d to %d", low, high)
+	}
+	return strconv.Itoa(priority), nil
+}
+
+// truncate cuts text to at most limit characters, or leaves it alone when
+// limit is 0
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if limit == 0 || len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
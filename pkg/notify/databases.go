@@ -0,0 +1,255 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIs of the database apps, replaced in tests
+var (
+	airtableAPI = "https://api.airtable.com/v0"
+	notionAPI   = "https://api.notion.com/v1"
+)
+
+// notionVersion is the Notion API version requests are written for
+const notionVersion = "2022-06-28"
+
+// notionTextLimit is the most characters Notion takes in one text object
+const notionTextLimit = 2000
+
+func init() {
+	Register(Kind{
+		Name:  "airtable",
+		Label: "Airtable",
+		Fields: []Field{
+			{Name: "token", Label: "Personal access token", Required: true, Secret: true},
+			{Name: "base_id", Label: "Base ID", Placeholder: "appXXXXXXXXXXXXXX", Required: true},
+			{Name: "table", Label: "Table name or ID", Placeholder: "Submissions", Required: true},
+			{Name: "mapping", Label: "Field mapping", Placeholder: "Email = email\nMessage = message\nReceived = @created_at", Multiline: true},
+		},
+		New: newAirtableChannel,
+	})
+	Register(Kind{
+		Name:  "notion",
+		Label: "Notion",
+		Fields: []Field{
+			{Name: "token", Label: "Integration secret", Required: true, Secret: true},
+			{Name: "database_id", Label: "Database ID", Placeholder: "From the database's URL", Required: true},
+			{Name: "mapping", Label: "Property mapping", Placeholder: "Name = name\nEmail = email\nMessage = message", Multiline: true},
+		},
+		New: newNotionChannel,
+	})
+}
+
+// mappedField sends a message's field, or one of its properties such as
+// @created_at, to a field of a database app
+type mappedField struct {
+	target string
+	source string
+}
+
+// messageProperties are the parts of a message a mapping can send besides
+// its fields
+var messageProperties = map[string]func(Message) string{
+	"@id": func(m Message) string {
+		if m.SubmissionID == 0 {
+			return ""
+		}
+		return strconv.FormatInt(m.SubmissionID, 10)
+	},
+	"@created_at": func(m Message) string { return m.CreatedAt.UTC().Format(time.RFC3339) },
+	"@form":       func(m Message) string { return m.FormName },
+	"@url":        func(m Message) string { return m.URL },
+}
+
+// parseMapping reads a mapping written one field per line as
+// "Target = source", or just "source" to keep the field's name
+func parseMapping(text string) ([]mappedField, error) {
+	var fields []mappedField
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		target, source, ok := strings.Cut(line, "=")
+		if !ok {
+			source = target
+		}
+		target, source = strings.TrimSpace(target), strings.TrimSpace(source)
+		if target == "" || source == "" {
+			return nil, fmt.Errorf("mapping line %q needs a name on both sides", line)
+		}
+		if strings.HasPrefix(source, "@") && messageProperties[source] == nil {
+			return nil, fmt.Errorf("unknown submission property %s", source)
+		}
+		fields = append(fields, mappedField{target: target, source: source})
+	}
+	return fields, nil
+}
+
+// mappedValues returns the mapped values of a message, or every field
+// under its own name when there's no mapping
+func mappedValues(mapping []mappedField, msg Message) []mappedField {
+	if len(mapping) == 0 {
+		names := make([]string, 0, len(msg.Fields))
+		for name := range msg.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := make([]mappedField, len(names))
+		for i, name := range names {
+			values[i] = mappedField{target: name, source: msg.Fields[name]}
+		}
+		return values
+	}
+
+	values := make([]mappedField, len(mapping))
+	for i, field := range mapping {
+		value := msg.Fields[field.source]
+		if property := messageProperties[field.source]; property != nil {
+			value = property(msg)
+		}
+		values[i] = mappedField{target: field.target, source: value}
+	}
+	return values
+}
+
+// airtableChannel creates a record in an Airtable table for each message
+type airtableChannel struct {
+	url     string
+	token   string
+	mapping []mappedField
+	client  *http.Client
+}
+
+func newAirtableChannel(config map[string]string, deps Deps) (Channel, error) {
+	baseID, table := strings.TrimSpace(config["base_id"]), strings.TrimSpace(config["table"])
+	if !strings.HasPrefix(baseID, "app") {
+		return nil, fmt.Errorf("Base ID must start with app")
+	}
+	if table == "" || strings.TrimSpace(config["token"]) == "" {
+		return nil, fmt.Errorf("Personal access token and Table are required")
+	}
+	mapping, err := parseMapping(config["mapping"])
+	if err != nil {
+		return nil, err
+	}
+	return &airtableChannel{
+		url:     airtableAPI + "/" + url.PathEscape(baseID) + "/" + url.PathEscape(table),
+		token:   strings.TrimSpace(config["token"]),
+		mapping: mapping,
+		client:  httpClient(deps),
+	}, nil
+}
+
+func (c *airtableChannel) Send(ctx context.Context, msg Message) error {
+	fields := map[string]string{}
+	for _, value := range mappedValues(c.mapping, msg) {
+		if value.source != "" {
+			fields[value.target] = value.source
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"fields": fields}},
+		// Lets text go into number, date and select fields
+		"typecast": true,
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+	return post(ctx, c.client, c.url, "application/json", body, header)
+}
+
+// notionChannel creates a page in a Notion database for each message. The
+// first mapped property is the page's title and the rest are text; without
+// a mapping the Name property is the title, and tests put the message's
+// summary in the title. The page's content is the message's text either way.
+type notionChannel struct {
+	databaseID string
+	token      string
+	mapping    []mappedField
+	client     *http.Client
+}
+
+func newNotionChannel(config map[string]string, deps Deps) (Channel, error) {
+	databaseID := notionDatabaseID(config["database_id"])
+	if databaseID == "" || strings.TrimSpace(config["token"]) == "" {
+		return nil, fmt.Errorf("Integration secret and Database ID are required")
+	}
+	mapping, err := parseMapping(config["mapping"])
+	if err != nil {
+		return nil, err
+	}
+	return &notionChannel{
+		databaseID: databaseID,
+		token:      strings.TrimSpace(config["token"]),
+		mapping:    mapping,
+		client:     httpClient(deps),
+	}, nil
+}
+
+// notionDatabaseID returns the ID in a database's URL, or the input itself
+// when it isn't a URL
+func notionDatabaseID(input string) string {
+	input = strings.TrimSpace(input)
+	if u, err := url.Parse(input); err == nil && u.Host != "" {
+		segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+		last := segments[len(segments)-1]
+		// Database URLs end with the title, a dash and the ID
+		if i := strings.LastIndex(last, "-"); i >= 0 {
+			last = last[i+1:]
+		}
+		return last
+	}
+	return input
+}
+
+// notionText is a rich text array holding text, cut to Notion's limit
+func notionText(text string) []map[string]interface{} {
+	return []map[string]interface{}{{"text": map[string]string{"content": truncate(text, notionTextLimit)}}}
+}
+
+func (c *notionChannel) Send(ctx context.Context, msg Message) error {
+	properties := map[string]interface{}{}
+	if len(c.mapping) == 0 || msg.Event == EventTest {
+		title := "Name"
+		if len(c.mapping) > 0 {
+			title = c.mapping[0].target
+		}
+		properties[title] = map[string]interface{}{"title": notionText(msg.Title())}
+	} else {
+		for i, value := range mappedValues(c.mapping, msg) {
+			kind := "rich_text"
+			if i == 0 {
+				kind = "title"
+			}
+			properties[value.target] = map[string]interface{}{kind: notionText(value.source)}
+		}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"parent":     map[string]string{"database_id": c.databaseID},
+		"properties": properties,
+		"children": []map[string]interface{}{{
+			"object":    "block",
+			"type":      "paragraph",
+			"paragraph": map[string]interface{}{"rich_text": notionText(msg.Text())},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+	header.Set("Notion-Version", notionVersion)
+	return post(ctx, c.client, notionAPI+"/pages", "application/json", body, header)
+}
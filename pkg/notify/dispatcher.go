@@ -0,0 +1,271 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+	"staticsend/pkg/unsubscribe"
+)
+
+// sendTimeout bounds one attempt at a delivery
+const sendTimeout = 15 * time.Second
+
+// delivery is a queued message for one channel
+type delivery struct {
+	id      int64
+	channel models.NotificationChannel
+	msg     Message
+	retries int
+}
+
+// Dispatcher delivers notifications through forms' channels in the
+// background, retrying failures with backoff and logging every delivery
+type Dispatcher struct {
+	db         *database.Database
+	deps       Deps
+	baseURL    func() string
+	queue      chan delivery
+	maxRetries int
+	// backoff is how long to wait before a retry
+	backoff func(retries int) time.Duration
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewDispatcher creates a dispatcher with maxWorkers sending from a queue
+// of queueSize deliveries. Links in messages start with baseURL.
+func NewDispatcher(db *database.Database, emailService *email.EmailService, baseURL func() string, queueSize, maxWorkers, maxRetries int) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		db:         db,
+		deps:       Deps{Client: defaultClient, Email: emailService, DB: db},
+		baseURL:    baseURL,
+		queue:      make(chan delivery, queueSize),
+		maxRetries: maxRetries,
+		backoff: func(retries int) time.Duration {
+			return time.Duration(retries*retries) * time.Second
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for i := 0; i < maxWorkers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	return d
+}
+
+// SubmissionMessage builds the message announcing a new submission
+func (d *Dispatcher) SubmissionMessage(form *models.Form, submission *models.Submission) Message {
+	fields := map[string]string{}
+	var values map[string]interface{}
+	if err := json.Unmarshal(submission.SubmittedData, &values); err == nil {
+		for name, value := range values {
+			if text, ok := value.(string); ok {
+				fields[name] = text
+			} else {
+				data, _ := json.Marshal(value)
+				fields[name] = string(data)
+			}
+		}
+	}
+	return Message{
+		Event:        EventSubmissionCreated,
+		FormID:       form.ID,
+		FormName:     form.Name,
+		SubmissionID: submission.ID,
+		Fields:       fields,
+		CreatedAt:    submission.CreatedAt,
+		URL:          fmt.Sprintf("%s/forms/%d/submissions", d.baseURL(), form.ID),
+	}
+}
+
+// NotifySubmission queues a new submission's message for every enabled
+// channel of its form
+func (d *Dispatcher) NotifySubmission(ctx context.Context, form *models.Form, submission *models.Submission) error {
+	return d.Dispatch(ctx, form.ID, d.SubmissionMessage(form, submission))
+}
+
+// NotifySubmissionEvent queues a message about something that happened to
+// a submission, such as it being flagged as spam, saying why in detail
+func (d *Dispatcher) NotifySubmissionEvent(ctx context.Context, event string, form *models.Form, submission *models.Submission, detail string) error {
+	msg := d.SubmissionMessage(form, submission)
+	msg.Event = event
+	msg.Detail = detail
+	return d.Dispatch(ctx, form.ID, msg)
+}
+
+// FormMessage builds the message announcing a change to a form
+func (d *Dispatcher) FormMessage(event string, form *models.Form) Message {
+	return Message{
+		Event:     event,
+		FormID:    form.ID,
+		FormName:  form.Name,
+		CreatedAt: time.Now().UTC(),
+		URL:       fmt.Sprintf("%s/forms/%d/view", d.baseURL(), form.ID),
+	}
+}
+
+// NotifyForm queues a message about a change to a form for the enabled
+// channels of every form its owner has, as a new form has none of its own
+func (d *Dispatcher) NotifyForm(ctx context.Context, event string, form *models.Form) error {
+	channels, err := models.GetNotificationChannelsByUserIDContext(ctx, d.db.Connection, form.UserID, true)
+	if err != nil {
+		return err
+	}
+	return d.dispatch(ctx, channels, d.FormMessage(event, form))
+}
+
+// Dispatch queues a message for every enabled channel of a form subscribed
+// to its event, logging a pending delivery for each. Deliveries that don't
+// fit in the queue are logged as failed.
+func (d *Dispatcher) Dispatch(ctx context.Context, formID int64, msg Message) error {
+	channels, err := models.GetNotificationChannelsByFormIDContext(ctx, d.db.Connection, formID, true)
+	if err != nil {
+		return err
+	}
+	return d.dispatch(ctx, channels, msg)
+}
+
+// dispatch queues a message for the channels subscribed to its event
+func (d *Dispatcher) dispatch(ctx context.Context, channels []models.NotificationChannel, msg Message) error {
+	for _, channel := range channels {
+		if msg.Event != EventTest && !channel.Subscribes(msg.Event) {
+			continue
+		}
+		id, err := models.CreateNotificationDeliveryContext(ctx, d.db.Connection, channel.ID, msg.SubmissionID, msg.Event)
+		if err != nil {
+			return err
+		}
+		job := delivery{id: id, channel: channel, msg: msg}
+		select {
+		case d.queue <- job:
+		default:
+			log.Printf("Notification queue is full, dropping delivery %d", id)
+			d.record(job, models.DeliveryStatusFailed, "notification queue is full")
+		}
+	}
+	return nil
+}
+
+// Test sends a test message through a channel straight away, without
+// retries, and logs the delivery
+func (d *Dispatcher) Test(ctx context.Context, form *models.Form, channel *models.NotificationChannel) error {
+	msg := Message{
+		Event:     EventTest,
+		FormID:    form.ID,
+		FormName:  form.Name,
+		CreatedAt: time.Now().UTC(),
+		URL:       fmt.Sprintf("%s/forms/%d/submissions", d.baseURL(), form.ID),
+	}
+	id, err := models.CreateNotificationDeliveryContext(ctx, d.db.Connection, channel.ID, 0, msg.Event)
+	if err != nil {
+		return err
+	}
+
+	job := delivery{id: id, channel: *channel, msg: msg}
+	sendErr := d.send(ctx, job)
+	if sendErr != nil {
+		d.record(job, models.DeliveryStatusFailed, sendErr.Error())
+	} else {
+		d.record(job, models.DeliveryStatusSent, "")
+	}
+	return sendErr
+}
+
+// send makes one attempt at a delivery
+func (d *Dispatcher) send(ctx context.Context, job delivery) error {
+	channel, err := Build(job.channel.Type, job.channel.Config, d.deps)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return channel.Send(ctx, job.msg)
+}
+
+// record logs the outcome of an attempt at a delivery
+func (d *Dispatcher) record(job delivery, status, errorMsg string) {
+	if err := models.RecordDeliveryAttemptContext(context.Background(), d.db.Connection, job.id, status, errorMsg); err != nil {
+		log.Printf("Failed to record notification delivery %d: %v", job.id, err)
+	}
+}
+
+// worker sends queued deliveries until Shutdown
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case job := <-d.queue:
+			err := d.send(context.Background(), job)
+			switch {
+			case err == nil:
+				d.record(job, models.DeliveryStatusSent, "")
+			case errors.Is(err, ErrGone):
+				d.remove(job)
+			case job.retries < d.maxRetries:
+				d.record(job, models.DeliveryStatusPending, err.Error())
+				job.retries++
+				go d.retry(job)
+			default:
+				log.Printf("Failed to deliver notification %d through %s channel %d after %d retries: %v", job.id, job.channel.Type, job.channel.ID, d.maxRetries, err)
+				d.record(job, models.DeliveryStatusFailed, err.Error())
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// remove deletes the channel of a delivery whose destination is gone,
+// along with its delivery log
+func (d *Dispatcher) remove(job delivery) {
+	log.Printf("Removing %s channel %d: its destination is gone", job.channel.Type, job.channel.ID)
+	if err := models.DeleteNotificationChannelContext(context.Background(), d.db.Connection, job.channel.ID); err != nil {
+		log.Printf("Failed to remove notification channel %d: %v", job.channel.ID, err)
+		d.record(job, models.DeliveryStatusFailed, err.Error())
+	}
+}
+
+// retry queues a failed delivery again after a backoff
+func (d *Dispatcher) retry(job delivery) {
+	select {
+	case <-time.After(d.backoff(job.retries)):
+	case <-d.ctx.Done():
+		d.record(job, models.DeliveryStatusFailed, "cancelled at shutdown")
+		return
+	}
+
+	select {
+	case d.queue <- job:
+	case <-d.ctx.Done():
+		d.record(job, models.DeliveryStatusFailed, "cancelled at shutdown")
+	}
+}
+
+// SetUnsubscribeLinks gives each email channel recipient a link to mute
+// the form's notifications. It's set before notifications are sent.
+func (d *Dispatcher) SetUnsubscribeLinks(links *unsubscribe.Links) {
+	d.deps.Unsubscribe = links
+}
+
+// QueueSize returns the number of deliveries waiting to be sent
+func (d *Dispatcher) QueueSize() int {
+	return len(d.queue)
+}
+
+// Shutdown stops the workers, waiting for deliveries being sent to finish
+func (d *Dispatcher) Shutdown() {
+	d.cancel()
+	d.wg.Wait()
+}
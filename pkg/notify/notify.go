@@ -0,0 +1,218 @@
+// Package notify delivers notifications through the channels configured on
+// each form, such as webhooks, chat apps and push services. Channel types
+// are registered as Kinds, so adding an integration doesn't touch the
+// dispatcher or the settings pages.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/unsubscribe"
+)
+
+// Events a notification can be about
+const (
+	EventSubmissionCreated = "submission.created"
+	// EventSubmissionSpamFlagged is sent when a submission is caught as spam
+	// or marked as spam by hand
+	EventSubmissionSpamFlagged = "submission.spam_flagged"
+	// EventEmailFailed is sent when a submission couldn't be emailed to its
+	// form's recipient
+	EventEmailFailed = "email.failed"
+	// EventSubmissionSpike is sent when a form, or one sender, suddenly
+	// submits far more than usual, such as in a spam flood
+	EventSubmissionSpike = "submission.spike"
+	// EventFormCreated and EventFormUpdated are about the account's forms,
+	// so they go to the channels of any of the owner's forms
+	EventFormCreated = "form.created"
+	EventFormUpdated = "form.updated"
+	// EventTest is sent from a channel's settings to check it works
+	EventTest = "test"
+)
+
+// Event describes an event channels can subscribe to
+type Event struct {
+	Name  string
+	Label string
+}
+
+// Events lists the events channels can subscribe to, in the order they're
+// offered in a channel's settings
+var Events = []Event{
+	{Name: EventSubmissionCreated, Label: "New submissions"},
+	{Name: EventSubmissionSpamFlagged, Label: "Submissions flagged as spam"},
+	{Name: EventEmailFailed, Label: "Failed submission emails"},
+	{Name: EventSubmissionSpike, Label: "Spikes in submissions"},
+	{Name: EventFormCreated, Label: "Forms created"},
+	{Name: EventFormUpdated, Label: "Forms updated"},
+}
+
+// IsEvent reports whether channels can subscribe to an event
+func IsEvent(name string) bool {
+	for _, event := range Events {
+		if event.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is a notification delivered through a channel
+type Message struct {
+	Event        string            `json:"event"`
+	FormID       int64             `json:"form_id"`
+	FormName     string            `json:"form_name"`
+	SubmissionID int64             `json:"submission_id,omitempty"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	// Detail says why the event happened, such as the reason a submission
+	// was flagged as spam or the error sending an email
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// URL links to the submission, or the form, on the dashboard
+	URL string `json:"url,omitempty"`
+}
+
+// Title is a one-line summary of the message
+func (m Message) Title() string {
+	switch m.Event {
+	case EventTest:
+		return fmt.Sprintf("Test notification from %s", m.FormName)
+	case EventSubmissionSpamFlagged:
+		return fmt.Sprintf("Submission to %s flagged as spam", m.FormName)
+	case EventEmailFailed:
+		return fmt.Sprintf("Failed to email a submission to %s", m.FormName)
+	case EventSubmissionSpike:
+		return fmt.Sprintf("Spike in submissions to %s", m.FormName)
+	case EventFormCreated:
+		return fmt.Sprintf("Form %s was created", m.FormName)
+	case EventFormUpdated:
+		return fmt.Sprintf("Form %s was updated", m.FormName)
+	}
+	return fmt.Sprintf("New submission to %s", m.FormName)
+}
+
+// Text is the message body in plain text: the detail, if any, then one
+// field per line in alphabetical order
+func (m Message) Text() string {
+	if m.Event == EventTest {
+		return "This channel is set up to receive notifications of new submissions."
+	}
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	if m.Detail != "" {
+		fmt.Fprintf(&b, "%s\n", m.Detail)
+		if len(names) > 0 {
+			b.WriteString("\n")
+		}
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, m.Fields[name])
+	}
+	if m.URL != "" {
+		fmt.Fprintf(&b, "\n%s\n", m.URL)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Channel delivers messages to one destination
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Deps are the shared services channels are built with
+type Deps struct {
+	// Client makes channels' HTTP requests
+	Client *http.Client
+	// Email sends the email channel's messages
+	Email *email.EmailService
+	// DB, if set, finds the recipients who muted a form's notifications,
+	// who the email channel leaves out
+	DB *database.Database
+	// Unsubscribe, if set, gives each of the email channel's recipients
+	// their own link to mute the form's notifications
+	Unsubscribe *unsubscribe.Links
+}
+
+// Field is a setting of a channel type
+type Field struct {
+	Name        string
+	Label       string
+	Placeholder string
+	Required    bool
+	// Secret settings aren't shown again once saved
+	Secret bool
+	// Multiline settings are edited in a text area
+	Multiline bool
+}
+
+// Kind is a type of channel that can be added to a form
+type Kind struct {
+	Name   string
+	Label  string
+	Fields []Field
+	// Hidden kinds are added by integrations rather than from a form's
+	// settings, so they aren't offered there
+	Hidden bool
+	// New builds a channel from its settings, returning an error if they
+	// aren't valid
+	New func(config map[string]string, deps Deps) (Channel, error)
+}
+
+// kinds holds the registered channel types by name
+var kinds = map[string]*Kind{}
+
+// Register adds a channel type, replacing any with the same name
+func Register(kind Kind) {
+	kinds[kind.Name] = &kind
+}
+
+// Kinds returns the channel types that can be added from a form's
+// settings, sorted by label
+func Kinds() []*Kind {
+	list := make([]*Kind, 0, len(kinds))
+	for _, kind := range kinds {
+		if !kind.Hidden {
+			list = append(list, kind)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	return list
+}
+
+// KindByName returns a registered channel type, or nil if there isn't one
+func KindByName(name string) *Kind {
+	return kinds[name]
+}
+
+// Validate checks that config has the kind's required settings and that
+// a channel can be built from it
+func (k *Kind) Validate(config map[string]string) error {
+	for _, field := range k.Fields {
+		if field.Required && strings.TrimSpace(config[field.Name]) == "" {
+			return fmt.Errorf("%s is required", field.Label)
+		}
+	}
+	_, err := k.New(config, Deps{})
+	return err
+}
+
+// Build creates the channel for a configured type
+func Build(channelType string, config map[string]string, deps Deps) (Channel, error) {
+	kind := KindByName(channelType)
+	if kind == nil {
+		return nil, fmt.Errorf("unknown channel type %q", channelType)
+	}
+	return kind.New(config, deps)
+}
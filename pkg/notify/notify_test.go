@@ -0,0 +1,515 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+)
+
+// request is what a test server received
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+// recorder starts a server that records requests, answering with status
+func recorder(t *testing.T, status int) (*httptest.Server, chan request) {
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, path: r.URL.Path, header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+var testMessage = Message{
+	Event:        EventSubmissionCreated,
+	FormID:       1,
+	FormName:     "Contact",
+	SubmissionID: 7,
+	Fields:       map[string]string{"name": "Ann", "email": "ann@example.com"},
+	URL:          "https://forms.example.com/forms/1/submissions",
+}
+
+func TestMessageText(t *testing.T) {
+	want := "email: ann@example.com\nname: Ann\n\nhttps://forms.example.com/forms/1/submissions"
+	if got := testMessage.Text(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := testMessage.Title(); got != "New submission to Contact" {
+		t.Errorf("Unexpected title %q", got)
+	}
+
+	failed := testMessage
+	failed.Event = EventEmailFailed
+	failed.Detail = "connection refused"
+	if got := failed.Text(); !strings.HasPrefix(got, "connection refused\n\nemail: ann@example.com") {
+		t.Errorf("Expected the detail first, got %q", got)
+	}
+	if got := failed.Title(); got != "Failed to email a submission to Contact" {
+		t.Errorf("Unexpected title %q", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		kind   string
+		config map[string]string
+		valid  bool
+	}{
+		{"webhook", map[string]string{"url": "https://example.com/hook"}, true},
+		{"webhook", map[string]string{"url": "ftp://example.com"}, false},
+		{"webhook", map[string]string{}, false},
+		{"email", map[string]string{"to": "a@example.com, b@example.com"}, true},
+		{"email", map[string]string{"to": "not an address"}, false},
+		{"ntfy", map[string]string{"topic": "alerts"}, true},
+		{"ntfy", map[string]string{"topic": "a/b"}, false},
+		{"telegram", map[string]string{"bot_token": "123:abc"}, false},
+		{"ntfy", map[string]string{"topic": "alerts", "priority": "6"}, false},
+		{"pushover", map[string]string{"app_token": "a", "user_key": "u", "priority": "2"}, false},
+		{"pushover", map[string]string{"app_token": "a", "user_key": "u", "priority": "-1"}, true},
+		{"matrix", map[string]string{"homeserver": "https://matrix.org", "access_token": "t", "room_id": "!abc:matrix.org"}, true},
+		{"matrix", map[string]string{"homeserver": "https://matrix.org", "access_token": "t", "room_id": "#lobby:matrix.org"}, false},
+		{"teams", map[string]string{"webhook_url": "not a url"}, false},
+	}
+	for _, tt := range tests {
+		err := KindByName(tt.kind).Validate(tt.config)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s %v: expected valid=%v, got %v", tt.kind, tt.config, tt.valid, err)
+		}
+	}
+	if _, err := Build("carrier-pigeon", nil, Deps{}); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+}
+
+func TestChannels(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("webhook", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusNoContent)
+		channel, _ := Build("webhook", map[string]string{"url": server.URL + "/hook", "secret": "shh"}, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		var got Message
+		if err := json.Unmarshal([]byte(req.body), &got); err != nil || got.SubmissionID != 7 || got.Fields["name"] != "Ann" {
+			t.Errorf("Expected the message as JSON, got %s", req.body)
+		}
+		mac := hmac.New(sha256.New, []byte("shh"))
+		mac.Write([]byte(req.body))
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get(SignatureHeader) != want {
+			t.Errorf("Expected signature %q, got %q", want, req.header.Get(SignatureHeader))
+		}
+	})
+
+	t.Run("webhook rotated secret", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusNoContent)
+		config, secret, err := RotateWebhookSecret(map[string]string{"url": server.URL, "secret": "old"}, time.Hour, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to rotate: %v", err)
+		}
+		if secret.Version != 2 || secret.PreviousVersion != 1 || secret.Previous != "old" || config["secret"] == "old" {
+			t.Fatalf("Expected a new version 2 secret, got %+v", secret)
+		}
+		signature := func(key, body string) string {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write([]byte(body))
+			return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		}
+
+		channel, _ := Build("webhook", config, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		if req.header.Get(VersionedSignatureHeader+"2") != signature(secret.Secret, req.body) || req.header.Get(SignatureHeader) != signature(secret.Secret, req.body) {
+			t.Errorf("Expected the body signed with the new secret, got %v", req.header)
+		}
+		if req.header.Get(VersionedSignatureHeader+"1") != signature("old", req.body) {
+			t.Errorf("Expected the body signed with the old secret too, got %v", req.header)
+		}
+
+		// Once the grace window is over only the new secret signs
+		config[webhookPreviousExpires] = time.Now().Add(-time.Minute).Format(time.RFC3339)
+		channel, _ = Build("webhook", config, Deps{})
+		channel.Send(ctx, testMessage)
+		if req := <-requests; req.header.Get(VersionedSignatureHeader+"1") != "" || req.header.Get(VersionedSignatureHeader+"2") == "" {
+			t.Errorf("Expected only the new secret to sign, got %v", req.header)
+		}
+
+		// Rotating again replaces the old secret
+		_, again, _ := RotateWebhookSecret(config, time.Hour, time.Now())
+		if again.Version != 3 || again.Previous != secret.Secret {
+			t.Errorf("Expected version 3 replacing version 2, got %+v", again)
+		}
+	})
+
+	t.Run("discord", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		channel, _ := Build("discord", map[string]string{"webhook_url": server.URL}, Deps{})
+		long := testMessage
+		long.Fields = map[string]string{"message": strings.Repeat("x", 3000)}
+		if err := channel.Send(ctx, long); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		var body map[string]string
+		json.Unmarshal([]byte((<-requests).body), &body)
+		if n := len([]rune(body["content"])); n != 2000 || !strings.HasPrefix(body["content"], "*New submission to Contact*") {
+			t.Errorf("Expected content cut to 2000 characters, got %d", n)
+		}
+	})
+
+	t.Run("telegram", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		defer func(api string) { telegramAPI = api }(telegramAPI)
+		telegramAPI = server.URL
+		channel, _ := Build("telegram", map[string]string{"bot_token": "123:abc", "chat_id": "-100"}, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		if req.path != "/bot123:abc/sendMessage" || !strings.Contains(req.body, `"chat_id":"-100"`) {
+			t.Errorf("Unexpected request %s %s", req.path, req.body)
+		}
+	})
+
+	t.Run("ntfy", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		channel, _ := Build("ntfy", map[string]string{"server": server.URL, "topic": "alerts", "token": "tk", "priority": "4"}, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		if req.path != "/alerts" || req.header.Get("Title") != "New submission to Contact" || req.header.Get("Authorization") != "Bearer tk" {
+			t.Errorf("Unexpected request %s %v", req.path, req.header)
+		}
+		if req.header.Get("Click") != testMessage.URL || req.header.Get("Priority") != "4" || req.body != testMessage.Text() {
+			t.Errorf("Expected the link, priority and text, got %v %q", req.header, req.body)
+		}
+	})
+
+	t.Run("pushover", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		defer func(api string) { pushoverAPI = api }(pushoverAPI)
+		pushoverAPI = server.URL + "/1/messages.json"
+		channel, _ := Build("pushover", map[string]string{"app_token": "app", "user_key": "user", "priority": "1"}, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		form, _ := url.ParseQuery(req.body)
+		if form.Get("token") != "app" || form.Get("user") != "user" || form.Get("priority") != "1" || form.Get("device") != "" {
+			t.Errorf("Unexpected request %v", form)
+		}
+		if form.Get("title") != "New submission to Contact" || form.Get("url") != testMessage.URL {
+			t.Errorf("Expected the title and link, got %v", form)
+		}
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusAccepted)
+		channel, _ := Build("teams", map[string]string{"webhook_url": server.URL}, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		body := (<-requests).body
+		if !strings.Contains(body, `"contentType":"application/vnd.microsoft.card.adaptive"`) || !strings.Contains(body, `"text":"New submission to Contact"`) {
+			t.Errorf("Expected an Adaptive Card, got %s", body)
+		}
+		if !strings.Contains(body, `"type":"Action.OpenUrl"`) {
+			t.Errorf("Expected a link to the submissions, got %s", body)
+		}
+	})
+
+	t.Run("matrix", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		channel, _ := Build("matrix", map[string]string{"homeserver": server.URL, "access_token": "tk", "room_id": "!room:example.org"}, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		if req.method != http.MethodPut || !strings.HasPrefix(req.path, "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/") {
+			t.Errorf("Unexpected request %s %s", req.method, req.path)
+		}
+		var body map[string]string
+		json.Unmarshal([]byte(req.body), &body)
+		if req.header.Get("Authorization") != "Bearer tk" || body["msgtype"] != "m.text" || !strings.HasPrefix(body["formatted_body"], "<strong>New submission to Contact</strong>") {
+			t.Errorf("Unexpected message %v %v", req.header, body)
+		}
+
+		// Retries reuse the transaction ID so they aren't posted twice
+		channel.Send(ctx, testMessage)
+		if again := <-requests; again.path != req.path {
+			t.Errorf("Expected the same transaction for a retry, got %s and %s", req.path, again.path)
+		}
+	})
+
+	t.Run("rest hook", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		channel, _ := Build(RESTHookKind, map[string]string{"url": server.URL}, Deps{})
+		if err := channel.Send(ctx, testMessage); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		var payload map[string]interface{}
+		json.Unmarshal([]byte((<-requests).body), &payload)
+		if payload["id"] != float64(7) || payload["form_name"] != "Contact" || payload["email"] != "ann@example.com" {
+			t.Errorf("Expected the submission as a flat object, got %v", payload)
+		}
+		if payload := HookPayload(Message{Event: EventFormUpdated, FormID: 3, CreatedAt: time.Unix(100, 0)}); payload["id"] != "form.updated-3-100" {
+			t.Errorf("Expected an ID for the form event, got %v", payload["id"])
+		}
+
+		gone, _ := recorder(t, http.StatusGone)
+		channel, _ = Build(RESTHookKind, map[string]string{"url": gone.URL}, Deps{})
+		if err := channel.Send(ctx, testMessage); !errors.Is(err, ErrGone) {
+			t.Errorf("Expected ErrGone for status 410, got %v", err)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		server, _ := recorder(t, http.StatusBadRequest)
+		channel, _ := Build("slack", map[string]string{"webhook_url": server.URL}, Deps{})
+		if err := channel.Send(ctx, testMessage); err == nil || !strings.Contains(err.Error(), "400") {
+			t.Errorf("Expected an error for status 400, got %v", err)
+		}
+	})
+}
+
+func TestDispatcher(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "staticsend.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "notify-key")
+	submission, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"name":"Ann"}`))
+
+	// The first attempt at each delivery fails
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	hook, _ := models.CreateNotificationChannel(db.Connection, form.ID, "webhook", "Hook", map[string]string{"url": server.URL}, []string{EventSubmissionCreated})
+	disabled, _ := models.CreateNotificationChannel(db.Connection, form.ID, "webhook", "Off", map[string]string{"url": server.URL}, []string{EventSubmissionCreated})
+	models.UpdateNotificationChannel(db.Connection, disabled.ID, disabled.Name, disabled.Config, disabled.Events, false)
+
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25}, 10, 0, 0)
+	d := NewDispatcher(db, emailService, func() string { return "https://forms.example.com" }, 10, 2, 2)
+	d.backoff = func(int) time.Duration { return 0 }
+	defer d.Shutdown()
+
+	if err := d.NotifySubmission(context.Background(), form, submission); err != nil {
+		t.Fatalf("Failed to dispatch: %v", err)
+	}
+
+	var deliveries []models.NotificationDelivery
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		deliveries, _ = models.GetNotificationDeliveries(db.Connection, hook.ID, 10)
+		if len(deliveries) == 1 && deliveries[0].Status != models.DeliveryStatusPending {
+			break
+		}
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryStatusSent || deliveries[0].Attempts != 2 {
+		t.Fatalf("Expected the delivery sent on its retry, got %+v", deliveries)
+	}
+	if deliveries[0].SubmissionID != submission.ID || deliveries[0].Event != EventSubmissionCreated {
+		t.Errorf("Expected the submission's delivery, got %+v", deliveries[0])
+	}
+	if off, _ := models.GetNotificationDeliveries(db.Connection, disabled.ID, 10); len(off) != 0 {
+		t.Errorf("Expected nothing sent to the disabled channel, got %+v", off)
+	}
+
+	t.Run("test", func(t *testing.T) {
+		if err := d.Test(context.Background(), form, hook); err != nil {
+			t.Fatalf("Failed to send test: %v", err)
+		}
+		email, _ := models.CreateNotificationChannel(db.Connection, form.ID, "email", "Team", map[string]string{"to": "team@example.com"}, []string{EventSubmissionCreated})
+		if err := d.Test(context.Background(), form, email); err == nil {
+			t.Error("Expected the email channel's test to fail without a mail server")
+		}
+		logged, _ := models.GetNotificationDeliveries(db.Connection, email.ID, 10)
+		if len(logged) != 1 || logged[0].Status != models.DeliveryStatusFailed || logged[0].Event != EventTest {
+			t.Errorf("Expected the failed test to be logged, got %+v", logged)
+		}
+	})
+
+	t.Run("muted", func(t *testing.T) {
+		muted, _ := models.CreateNotificationChannel(db.Connection, form.ID, "email", "Sales", map[string]string{"to": "sales@example.com"}, []string{EventSubmissionCreated})
+		if err := models.MuteRecipient(db.Connection, form.ID, "Sales@Example.com"); err != nil {
+			t.Fatalf("Failed to mute: %v", err)
+		}
+		// Nothing is sent, so the missing mail server doesn't matter
+		if err := d.Test(context.Background(), form, muted); err != nil {
+			t.Errorf("Expected no email to a muted recipient, got %v", err)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		other, _ := models.CreateForm(db.Connection, user.ID, "Careers", "example.com", "secret", "to@example.com", "notify-events-key")
+		watcher, _ := models.CreateNotificationChannel(db.Connection, other.ID, "webhook", "Ops", map[string]string{"url": server.URL}, []string{EventFormCreated, EventEmailFailed})
+		before, _ := models.GetNotificationDeliveries(db.Connection, hook.ID, 10)
+
+		// Form events go to the channels of all the owner's forms
+		if err := d.NotifyForm(context.Background(), EventFormCreated, form); err != nil {
+			t.Fatalf("Failed to dispatch: %v", err)
+		}
+		if err := d.NotifySubmissionEvent(context.Background(), EventSubmissionSpamFlagged, form, submission, "Held as spam: honeypot"); err != nil {
+			t.Fatalf("Failed to dispatch: %v", err)
+		}
+		if err := d.NotifySubmissionEvent(context.Background(), EventEmailFailed, other, submission, "connection refused"); err != nil {
+			t.Fatalf("Failed to dispatch: %v", err)
+		}
+
+		var logged []models.NotificationDelivery
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			logged, _ = models.GetNotificationDeliveries(db.Connection, watcher.ID, 10)
+			if len(logged) == 2 && logged[0].Status == models.DeliveryStatusSent && logged[1].Status == models.DeliveryStatusSent {
+				break
+			}
+		}
+		events := map[string]bool{}
+		for _, delivery := range logged {
+			events[delivery.Event] = delivery.Status == models.DeliveryStatusSent
+		}
+		if len(logged) != 2 || !events[EventFormCreated] || !events[EventEmailFailed] {
+			t.Errorf("Expected the subscribed events sent, got %+v", logged)
+		}
+		if after, _ := models.GetNotificationDeliveries(db.Connection, hook.ID, 10); len(after) != len(before) {
+			t.Errorf("Expected nothing sent to a channel not subscribed to the events, got %+v", after)
+		}
+	})
+
+	t.Run("gone", func(t *testing.T) {
+		gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGone)
+		}))
+		defer gone.Close()
+		subscribed, _ := models.CreateNotificationChannel(db.Connection, form.ID, RESTHookKind, "Zapier", map[string]string{"url": gone.URL}, []string{EventSubmissionCreated})
+
+		if err := d.NotifySubmission(context.Background(), form, submission); err != nil {
+			t.Fatalf("Failed to dispatch: %v", err)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if channel, _ := models.GetNotificationChannelByID(db.Connection, subscribed.ID); channel == nil {
+				return
+			}
+		}
+		t.Error("Expected the channel to be removed once its hook was gone")
+	})
+}
+
+func TestDatabaseChannels(t *testing.T) {
+	ctx := context.Background()
+	msg := testMessage
+	msg.CreatedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("airtable", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		defer func(api string) { airtableAPI = api }(airtableAPI)
+		airtableAPI = server.URL
+
+		if err := KindByName("airtable").Validate(map[string]string{"token": "pat", "base_id": "bad", "table": "Leads"}); err == nil {
+			t.Error("Expected an error for a base ID without the app prefix")
+		}
+		channel, err := Build("airtable", map[string]string{
+			"token":   "pat",
+			"base_id": "appABC",
+			"table":   "Web Leads",
+			"mapping": "Email = email\nReceived = @created_at\nPhone = phone",
+		}, Deps{})
+		if err != nil {
+			t.Fatalf("Failed to build channel: %v", err)
+		}
+		if err := channel.Send(ctx, msg); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		if req.path != "/appABC/Web Leads" || req.header.Get("Authorization") != "Bearer pat" {
+			t.Errorf("Unexpected request %s %v", req.path, req.header)
+		}
+		var body struct {
+			Records []struct {
+				Fields map[string]string `json:"fields"`
+			} `json:"records"`
+			Typecast bool `json:"typecast"`
+		}
+		json.Unmarshal([]byte(req.body), &body)
+		want := map[string]string{"Email": "ann@example.com", "Received": "2026-01-02T03:04:05Z"}
+		if len(body.Records) != 1 || len(body.Records[0].Fields) != 2 || !body.Typecast {
+			t.Fatalf("Expected one record with the mapped fields, got %s", req.body)
+		}
+		for name, value := range want {
+			if body.Records[0].Fields[name] != value {
+				t.Errorf("Expected %s = %q, got %q", name, value, body.Records[0].Fields[name])
+			}
+		}
+	})
+
+	t.Run("notion", func(t *testing.T) {
+		server, requests := recorder(t, http.StatusOK)
+		defer func(api string) { notionAPI = api }(notionAPI)
+		notionAPI = server.URL
+
+		if err := KindByName("notion").Validate(map[string]string{"token": "secret", "database_id": "db", "mapping": "When = @when"}); err == nil {
+			t.Error("Expected an error for an unknown property")
+		}
+		channel, _ := Build("notion", map[string]string{
+			"token":       "secret",
+			"database_id": "https://www.notion.so/team/Leads-0123456789abcdef0123456789abcdef?v=1",
+			"mapping":     "Who = name\nEmail = email",
+		}, Deps{})
+		if err := channel.Send(ctx, msg); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		req := <-requests
+		if req.path != "/pages" || req.header.Get("Notion-Version") != notionVersion {
+			t.Errorf("Unexpected request %s %v", req.path, req.header)
+		}
+		var body struct {
+			Parent     map[string]string `json:"parent"`
+			Properties map[string]map[string][]struct {
+				Text struct {
+					Content string `json:"content"`
+				} `json:"text"`
+			} `json:"properties"`
+		}
+		json.Unmarshal([]byte(req.body), &body)
+		if body.Parent["database_id"] != "0123456789abcdef0123456789abcdef" {
+			t.Errorf("Expected the ID from the URL, got %v", body.Parent)
+		}
+		if title := body.Properties["Who"]["title"]; len(title) != 1 || title[0].Text.Content != "Ann" {
+			t.Errorf("Expected the first mapping as the title, got %s", req.body)
+		}
+		if email := body.Properties["Email"]["rich_text"]; len(email) != 1 || email[0].Text.Content != "ann@example.com" {
+			t.Errorf("Expected the email as text, got %s", req.body)
+		}
+	})
+}
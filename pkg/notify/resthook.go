@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RESTHookKind is the name of the channels automation tools such as Zapier
+// and Make subscribe through the hooks API
+const RESTHookKind = "rest_hook"
+
+// ErrGone is returned by channels whose destination has said it doesn't want
+// any more messages. The dispatcher removes the channel instead of retrying.
+var ErrGone = errors.New("destination is gone")
+
+func init() {
+	Register(Kind{
+		Name:  RESTHookKind,
+		Label: "Zapier / Make",
+		Fields: []Field{
+			{Name: "url", Label: "Hook URL", Required: true, Secret: true},
+		},
+		Hidden: true,
+		New:    newRESTHook,
+	})
+}
+
+// HookPayload flattens a message into the object REST hooks post and the
+// polling trigger lists, so automation tools offer each field on its own.
+// Fields named like a property of the submission don't replace it. Events
+// about a form rather than a submission get an ID made from the event, the
+// form and the time, as automation tools skip items with an ID they've seen.
+func HookPayload(msg Message) map[string]interface{} {
+	payload := make(map[string]interface{}, len(msg.Fields)+7)
+	for name, value := range msg.Fields {
+		payload[name] = value
+	}
+	if msg.SubmissionID != 0 {
+		payload["id"] = msg.SubmissionID
+	} else {
+		payload["id"] = fmt.Sprintf("%s-%d-%d", msg.Event, msg.FormID, msg.CreatedAt.Unix())
+	}
+	if msg.Detail != "" {
+		payload["detail"] = msg.Detail
+	}
+	payload["event"] = msg.Event
+	payload["form_id"] = msg.FormID
+	payload["form_name"] = msg.FormName
+	payload["created_at"] = msg.CreatedAt.UTC().Format(time.RFC3339)
+	payload["url"] = msg.URL
+	return payload
+}
+
+// restHook posts messages as flat JSON objects to a URL subscribed following
+// the REST Hooks convention, where a 410 Gone response unsubscribes it
+type restHook struct {
+	url    string
+	client *http.Client
+}
+
+func newRESTHook(config map[string]string, deps Deps) (Channel, error) {
+	target, err := parseHTTPURL("Hook URL", config["url"])
+	if err != nil {
+		return nil, err
+	}
+	return &restHook{url: target, client: httpClient(deps)}, nil
+}
+
+func (c *restHook) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(HookPayload(msg))
+	if err != nil {
+		return err
+	}
+	err = post(ctx, c.client, c.url, "application/json", body, nil)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusGone {
+		return ErrGone
+	}
+	return err
+}
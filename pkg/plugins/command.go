@@ -0,0 +1,92 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxCommandStderr caps how much of a failed command's stderr is logged
+const maxCommandStderr = 1024
+
+// Command is a hook that runs an external command for each submission.
+// The command is given the submission as JSON on stdin and may write a
+// CommandResponse as JSON to stdout; writing nothing leaves the submission
+// as it is. Exiting with an error is a failed hook, not a rejection.
+type Command struct {
+	// Args is the program and its arguments. They're split on spaces, not
+	// by a shell, so quoting isn't understood.
+	Args    []string
+	Timeout time.Duration
+}
+
+// CommandResponse is what a command hook writes to change or reject a
+// submission
+type CommandResponse struct {
+	// Fields, when set, replace the submission's fields
+	Fields map[string]string `json:"fields,omitempty"`
+	// Reject, when set, turns the submission away with this message
+	Reject string `json:"reject,omitempty"`
+}
+
+// NewCommand creates a hook running command, which is split on spaces
+func NewCommand(command string, timeout time.Duration) *Command {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Command{Args: strings.Fields(command), Timeout: timeout}
+}
+
+// Process runs the command on the submission
+func (c *Command) Process(ctx context.Context, submission *Submission) error {
+	if len(c.Args) == 0 {
+		return errors.New("no command")
+	}
+	input, err := json.Marshal(submission)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Children left holding the pipes open don't keep the submission waiting
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", c.Timeout)
+		}
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > maxCommandStderr {
+			message = message[:maxCommandStderr]
+		}
+		if message != "" {
+			return fmt.Errorf("%w: %s", err, message)
+		}
+		return err
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil
+	}
+	var response CommandResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if response.Reject != "" {
+		return Reject(response.Reject)
+	}
+	if response.Fields != nil {
+		submission.Fields = response.Fields
+	}
+	return nil
+}
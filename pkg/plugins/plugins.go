@@ -0,0 +1,143 @@
+// Package plugins lets bespoke business rules process each submission
+// before it's stored, without forking staticSend. A hook can change the
+// submitted fields, add fields of its own or reject the submission.
+//
+// Hooks are Go code compiled in, registering themselves with Register from
+// an init function of a package imported in cmd/staticsend/plugins.go, or
+// external commands named by SUBMISSION_HOOK_COMMANDS, which are given the
+// submission as JSON.
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout caps how long a command hook may take
+const DefaultTimeout = 5 * time.Second
+
+// Submission is a submission on its way to being stored, as hooks see it
+type Submission struct {
+	FormID    int64  `json:"form_id"`
+	FormName  string `json:"form_name"`
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	Referrer  string `json:"referrer"`
+	// Country is the sender's country code, or "" if it isn't known
+	Country string `json:"country"`
+	// Fields are the submitted values, which a hook may change, add to or
+	// remove from
+	Fields map[string]string `json:"fields"`
+}
+
+// Hook processes submissions before they're stored. Returning an error
+// made by Reject turns the submission away; any other error is logged and
+// the submission carries on, so a broken hook doesn't lose submissions.
+type Hook interface {
+	Process(ctx context.Context, submission *Submission) error
+}
+
+// HookFunc adapts a function to a Hook
+type HookFunc func(ctx context.Context, submission *Submission) error
+
+// Process calls f
+func (f HookFunc) Process(ctx context.Context, submission *Submission) error {
+	return f(ctx, submission)
+}
+
+// RejectError turns a submission away, telling the sender why
+type RejectError struct {
+	Message string
+}
+
+// Error returns the message for the sender
+func (e *RejectError) Error() string {
+	return e.Message
+}
+
+// Reject returns an error that turns a submission away with message
+func Reject(message string) error {
+	return &RejectError{Message: message}
+}
+
+// namedHook is a hook with the name it's logged by
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []namedHook
+)
+
+// Register adds a compiled in hook, run on every submission in the order
+// hooks were registered. It panics if the name is taken, as it's a
+// mistake made when building.
+func Register(name string, hook Hook) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, registered := range registry {
+		if registered.name == name {
+			panic(fmt.Sprintf("plugins: hook %q registered twice", name))
+		}
+	}
+	registry = append(registry, namedHook{name: name, hook: hook})
+}
+
+// Registered returns the names of the compiled in hooks
+func Registered() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, len(registry))
+	for i, registered := range registry {
+		names[i] = registered.name
+	}
+	return names
+}
+
+// Runner runs the hooks on each submission
+type Runner struct {
+	hooks []namedHook
+}
+
+// NewRunner creates a runner for the compiled in hooks, followed by a
+// hook for each command, each given up to timeout
+func NewRunner(commands []string, timeout time.Duration) *Runner {
+	registryMu.Lock()
+	hooks := append([]namedHook{}, registry...)
+	registryMu.Unlock()
+	for _, command := range commands {
+		hooks = append(hooks, namedHook{name: command, hook: NewCommand(command, timeout)})
+	}
+	return &Runner{hooks: hooks}
+}
+
+// Len returns how many hooks are run
+func (r *Runner) Len() int {
+	return len(r.hooks)
+}
+
+// Run runs each hook on the submission in turn, returning a *RejectError
+// if one turns it away. Other errors are logged and the hook skipped.
+func (r *Runner) Run(ctx context.Context, submission *Submission) error {
+	for _, hook := range r.hooks {
+		err := hook.hook.Process(ctx, submission)
+		if submission.Fields == nil {
+			submission.Fields = map[string]string{}
+		}
+		if err == nil {
+			continue
+		}
+		var rejection *RejectError
+		if errors.As(err, &rejection) {
+			return rejection
+		}
+		log.Printf("Submission hook %s failed on form %d, carrying on without it: %v", hook.name, submission.FormID, err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetRegistry empties the compiled in hooks for the test's duration
+func resetRegistry(t *testing.T) {
+	registryMu.Lock()
+	saved := registry
+	registry = nil
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = saved
+		registryMu.Unlock()
+	})
+}
+
+// script writes an executable shell script for a command hook
+func script(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("command hooks are tested with shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	return path
+}
+
+func TestRunner_CompiledHooks(t *testing.T) {
+	resetRegistry(t)
+	Register("uppercase", HookFunc(func(ctx context.Context, submission *Submission) error {
+		submission.Fields["name"] = strings.ToUpper(submission.Fields["name"])
+		return nil
+	}))
+	Register("broken", HookFunc(func(ctx context.Context, submission *Submission) error {
+		return errors.New("database down")
+	}))
+	Register("crm", HookFunc(func(ctx context.Context, submission *Submission) error {
+		submission.Fields["crm_id"] = "42"
+		return nil
+	}))
+
+	if got := Registered(); strings.Join(got, ",") != "uppercase,broken,crm" {
+		t.Errorf("Expected the hooks in the order registered, got %v", got)
+	}
+
+	submission := &Submission{FormID: 1, Fields: map[string]string{"name": "ada"}}
+	if err := NewRunner(nil, 0).Run(context.Background(), submission); err != nil {
+		t.Fatalf("Expected a failing hook to be skipped, got %v", err)
+	}
+	if submission.Fields["name"] != "ADA" || submission.Fields["crm_id"] != "42" {
+		t.Errorf("Expected the fields changed and enriched, got %v", submission.Fields)
+	}
+}
+
+func TestRunner_Reject(t *testing.T) {
+	resetRegistry(t)
+	Register("company-only", HookFunc(func(ctx context.Context, submission *Submission) error {
+		if !strings.HasSuffix(submission.Fields["email"], "@example.com") {
+			return Reject("Use your company email address")
+		}
+		return nil
+	}))
+	ran := false
+	Register("after", HookFunc(func(ctx context.Context, submission *Submission) error {
+		ran = true
+		return nil
+	}))
+
+	err := NewRunner(nil, 0).Run(context.Background(), &Submission{Fields: map[string]string{"email": "ada@gmail.com"}})
+	var rejection *RejectError
+	if !errors.As(err, &rejection) || rejection.Message != "Use your company email address" {
+		t.Errorf("Expected the submission to be rejected, got %v", err)
+	}
+	if ran {
+		t.Error("Expected no hooks to run after a rejection")
+	}
+}
+
+func TestRegister_Twice(t *testing.T) {
+	resetRegistry(t)
+	Register("crm", HookFunc(func(ctx context.Context, submission *Submission) error { return nil }))
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a name twice to panic")
+		}
+	}()
+	Register("crm", HookFunc(func(ctx context.Context, submission *Submission) error { return nil }))
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantReject string
+		wantFields map[string]string
+		wantErr    bool
+	}{
+		{
+			name:       "no output leaves the submission alone",
+			body:       "cat > /dev/null",
+			wantFields: map[string]string{"message": "hello"},
+		},
+		{
+			name:       "fields are replaced",
+			body:       `cat > /dev/null; echo '{"fields": {"message": "hello", "source": "hook"}}'`,
+			wantFields: map[string]string{"message": "hello", "source": "hook"},
+		},
+		{
+			name:       "rejected",
+			body:       `cat > /dev/null; echo '{"reject": "No thanks"}'`,
+			wantReject: "No thanks",
+		},
+		{
+			name:       "the submission is given on stdin",
+			body:       `grep -q '"form_id":7' || exit 3`,
+			wantFields: map[string]string{"message": "hello"},
+		},
+		{
+			name:    "failing",
+			body:    "echo broken >&2; exit 1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid output",
+			body:    "cat > /dev/null; echo not json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submission := &Submission{FormID: 7, Fields: map[string]string{"message": "hello"}}
+			err := NewCommand(script(t, tt.body), time.Second).Process(context.Background(), submission)
+			var rejection *RejectError
+			switch {
+			case tt.wantReject != "":
+				if !errors.As(err, &rejection) || rejection.Message != tt.wantReject {
+					t.Errorf("Expected rejection %q, got %v", tt.wantReject, err)
+				}
+			case tt.wantErr:
+				if err == nil || errors.As(err, &rejection) {
+					t.Errorf("Expected the hook to fail, got %v", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if len(submission.Fields) != len(tt.wantFields) {
+					t.Fatalf("Expected fields %v, got %v", tt.wantFields, submission.Fields)
+				}
+				for name, value := range tt.wantFields {
+					if submission.Fields[name] != value {
+						t.Errorf("Expected %s=%q, got %q", name, value, submission.Fields[name])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCommand_Timeout(t *testing.T) {
+	path := script(t, "sleep 5")
+	err := NewCommand(path, 50*time.Millisecond).Process(context.Background(), &Submission{Fields: map[string]string{}})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected the command to time out, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package redact
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Mask is the placeholder written in place of redacted values
+const Mask = "[REDACTED]"
+
+// DefaultKeys are the parameter names that are always treated as sensitive.
+// Matching is case-insensitive and also applies to keys that contain one of
+// these names (e.g. "new_password" or "x-auth-token").
+var DefaultKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"authorization",
+	"cookie",
+	"session",
+	"api_key",
+	"apikey",
+	"cf-turnstile-response",
+	"signature",
+	"address",
+}
+
+// Redactor masks sensitive values before they are written to logs
+type Redactor struct {
+	keys []string
+}
+
+// New creates a redactor for DefaultKeys plus any additional keys
+func New(extraKeys ...string) *Redactor {
+	keys := make([]string, 0, len(DefaultKeys)+len(extraKeys))
+	for _, key := range append(append([]string{}, DefaultKeys...), extraKeys...) {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return &Redactor{keys: keys}
+}
+
+// IsSensitive reports whether values for the given key should be redacted
+func (r *Redactor) IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns a copy of values with sensitive entries masked
+func (r *Redactor) Values(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for key, vals := range values {
+		if r.IsSensitive(key) {
+			masked := make([]string, len(vals))
+			for i := range vals {
+				masked[i] = Mask
+			}
+			redacted[key] = masked
+			continue
+		}
+		redacted[key] = append([]string(nil), vals...)
+	}
+	return redacted
+}
+
+// Map returns a copy of a flat key/value map with sensitive entries masked
+func (r *Redactor) Map(data map[string]string) map[string]string {
+	redacted := make(map[string]string, len(data))
+	for key, value := range data {
+		if r.IsSensitive(key) {
+			redacted[key] = Mask
+			continue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// URL returns a copy of u with sensitive query parameters masked
+func (r *Redactor) URL(u *url.URL) *url.URL {
+	if u == nil {
+		return nil
+	}
+	redacted := *u
+	if u.RawQuery != "" {
+		if query, err := url.ParseQuery(u.RawQuery); err == nil {
+			redacted.RawQuery = r.Values(query).Encode()
+		} else {
+			// Don't risk logging a query string we can't parse
+			redacted.RawQuery = Mask
+		}
+	}
+	if u.User != nil {
+		redacted.User = url.User(u.User.Username())
+	}
+	return &redacted
+}
+
+// Email masks the local part of an email address, keeping the first
+// character and the domain so log lines remain useful for debugging
+// (e.g. "jane@example.com" becomes "j***@example.com")
+func Email(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 {
+		if address == "" {
+			return ""
+		}
+		return "***"
+	}
+	return address[:1] + "***" + address[at:]
+}
+
+// Emails masks a list of email addresses and joins them with commas
+func Emails(addresses []string) string {
+	masked := make([]string, len(addresses))
+	for i, address := range addresses {
+		masked[i] = Email(strings.TrimSpace(address))
+	}
+	return strings.Join(masked, ",")
+}
@@ -0,0 +1,50 @@
+// Package sanitize prepares submitted values, which are stored exactly as
+// they were sent, for display in HTML: the dashboard, feeds and
+// notification channels that take HTML. Values are escaped when they're
+// rendered rather than when they're stored, so exports keep what was sent.
+package sanitize
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// newlines turns Windows and old Mac line endings into \n
+var newlines = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// Text returns a value with line endings made \n and invisible characters
+// that can disguise it removed: control characters other than tabs and
+// line breaks, and the Unicode controls that reorder text
+func Text(value string) string {
+	value = newlines.Replace(value)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r), isBidiControl(r):
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// isBidiControl reports whether r overrides or isolates the direction of
+// the text around it, which can make a value read differently from what
+// it holds
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// HTML returns a value as HTML text, with every character that means
+// something in HTML escaped, quotes included, so it's safe in elements
+// and quoted attributes alike
+func HTML(value string) string {
+	return html.EscapeString(Text(value))
+}
+
+// HTMLWithBreaks is like HTML but keeps the value's line breaks as <br>,
+// the only markup it allows
+func HTMLWithBreaks(value string) string {
+	return strings.ReplaceAll(HTML(value), "\n", "<br>")
+}
@@ -0,0 +1,69 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+// payloads are XSS attempts that must come out as text
+var payloads = []string{
+	`<script>alert(1)</script>`,
+	`"><img src=x onerror=alert(1)>`,
+	`' onmouseover='alert(1)`,
+	`<svg/onload=alert(1)>`,
+	`<a href="javascript:alert(1)">click</a>`,
+	"<scr\x00ipt>alert(1)</script>",
+	`<iframe srcdoc="&lt;script&gt;alert(1)&lt;/script&gt;">`,
+	"line one\n<b>line two</b>",
+}
+
+func TestHTML(t *testing.T) {
+	for _, payload := range payloads {
+		for name, got := range map[string]string{"HTML": HTML(payload), "HTMLWithBreaks": HTMLWithBreaks(payload)} {
+			if strings.ContainsAny(strings.ReplaceAll(got, "<br>", ""), `<>"'`) {
+				t.Errorf("%s(%q) = %q; want no markup or quotes", name, payload, got)
+			}
+		}
+	}
+
+	if got := HTML(`Tom & "Jerry" <3`); got != "Tom &amp; &#34;Jerry&#34; &lt;3" {
+		t.Errorf("Unexpected escaping %q", got)
+	}
+}
+
+func TestHTMLWithBreaks(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"one\ntwo", "one<br>two"},
+		{"one\r\ntwo\rthree", "one<br>two<br>three"},
+		{"<br>", "&lt;br&gt;"},
+		{"a\n\n<i>b</i>", "a<br><br>&lt;i&gt;b&lt;/i&gt;"},
+	}
+	for _, tt := range tests {
+		if got := HTMLWithBreaks(tt.value); got != tt.want {
+			t.Errorf("HTMLWithBreaks(%q) = %q; want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain text", "plain text"},
+		{"tab\there\r\nnext", "tab\there\nnext"},
+		{"null\x00byte\x1bescape", "nullbyteescape"},
+		// A right-to-left override makes "exe.pdf" read as "fdp.exe"
+		{"invoice\u202efdp.exe", "invoicefdp.exe"},
+		{"isolate\u2066d\u2069", "isolated"},
+		{"émoji 👋 and 日本語", "émoji 👋 and 日本語"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.value); got != tt.want {
+			t.Errorf("Text(%q) = %q; want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package secrets encrypts the third-party credentials kept in the database,
+// such as forms' Turnstile secret keys, so a copy of the database alone
+// doesn't give them away. Values are sealed when they're saved and opened
+// when they're read, with a master key kept outside the database.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// prefix marks a sealed value, telling it apart from one saved in plain
+// text before encryption, and names the scheme so it can change later
+const prefix = "enc:v1:"
+
+// ErrNoKey is returned opening a sealed value when no key is set
+var ErrNoKey = errors.New("secrets: value is encrypted but no key is set")
+
+// Box seals and opens values with AES-256-GCM
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox creates a box from a master key, which may be any length; the
+// cipher key is its SHA-256 hash
+func NewBox(key []byte) (*Box, error) {
+	if len(key) == 0 {
+		return nil, errors.New("secrets: the key is empty")
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts a value. Empty values stay empty, so an unset secret still
+// reads as unset.
+func (b *Box) Seal(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("secrets: failed to generate nonce: %w", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(value), nil)
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed value. Values saved before encryption are
+// returned as they are.
+func (b *Box) Open(stored string) (string, error) {
+	if !Sealed(stored) {
+		return stored, nil
+	}
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(stored, prefix))
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", errors.New("secrets: malformed encrypted value")
+	}
+	nonce, sealed := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	value, err := b.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", errors.New("secrets: failed to decrypt value; is the key the one it was encrypted with?")
+	}
+	return string(value), nil
+}
+
+// Sealed reports whether a stored value is encrypted
+func Sealed(stored string) bool {
+	return strings.HasPrefix(stored, prefix)
+}
+
+var (
+	mu         sync.RWMutex
+	defaultBox *Box
+)
+
+// SetDefault sets the box Seal and Open use, once at startup. Without one
+// values are saved in plain text.
+func SetDefault(box *Box) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultBox = box
+}
+
+// Enabled reports whether a default box is set
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultBox != nil
+}
+
+// Seal encrypts a value with the default box, or returns it unchanged
+// without one
+func Seal(value string) (string, error) {
+	mu.RLock()
+	box := defaultBox
+	mu.RUnlock()
+	if box == nil {
+		return value, nil
+	}
+	return box.Seal(value)
+}
+
+// Open decrypts a value with the default box. Plain values are returned as
+// they are, and sealed ones fail with ErrNoKey without a box.
+func Open(stored string) (string, error) {
+	mu.RLock()
+	box := defaultBox
+	mu.RUnlock()
+	if box == nil {
+		if Sealed(stored) {
+			return "", ErrNoKey
+		}
+		return stored, nil
+	}
+	return box.Open(stored)
+}
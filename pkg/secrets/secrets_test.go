@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBox(t *testing.T) {
+	box, err := NewBox([]byte("master key"))
+	if err != nil {
+		t.Fatalf("Failed to create box: %v", err)
+	}
+
+	sealed, err := box.Seal("0x4AAA-secret")
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	if !Sealed(sealed) || strings.Contains(sealed, "0x4AAA-secret") {
+		t.Fatalf("Expected an encrypted value, got %q", sealed)
+	}
+	if again, _ := box.Seal("0x4AAA-secret"); again == sealed {
+		t.Error("Expected each seal to use a new nonce")
+	}
+	if value, err := box.Open(sealed); err != nil || value != "0x4AAA-secret" {
+		t.Errorf("Expected the value back, got %q, %v", value, err)
+	}
+
+	if value, err := box.Open("saved before encryption"); err != nil || value != "saved before encryption" {
+		t.Errorf("Expected plain values as they are, got %q, %v", value, err)
+	}
+	if sealed, _ := box.Seal(""); sealed != "" {
+		t.Errorf("Expected empty values to stay empty, got %q", sealed)
+	}
+
+	other, _ := NewBox([]byte("another key"))
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("Expected another key not to open the value")
+	}
+	if _, err := box.Open(prefix + "not base64!"); err == nil {
+		t.Error("Expected a malformed value to fail")
+	}
+	if _, err := NewBox(nil); err == nil {
+		t.Error("Expected an empty key to be refused")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+
+	if value, _ := Seal("plain"); value != "plain" {
+		t.Errorf("Expected values in plain text without a key, got %q", value)
+	}
+
+	box, _ := NewBox([]byte("master key"))
+	SetDefault(box)
+	sealed, _ := Seal("secret")
+	if !Sealed(sealed) {
+		t.Fatalf("Expected the default box to seal, got %q", sealed)
+	}
+	if value, err := Open(sealed); err != nil || value != "secret" {
+		t.Errorf("Expected the value back, got %q, %v", value, err)
+	}
+
+	SetDefault(nil)
+	if _, err := Open(sealed); err != ErrNoKey {
+		t.Errorf("Expected ErrNoKey without a key, got %v", err)
+	}
+}
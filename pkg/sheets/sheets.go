@@ -0,0 +1,220 @@
+// Package sheets appends form submissions to Google Sheets. Each user adds
+// a Google Cloud service account key and shares their spreadsheets with the
+// service account, which writes the rows through the Sheets API.
+package sheets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// scope lets a service account edit the spreadsheets shared with it
+	scope = "https://www.googleapis.com/auth/spreadsheets"
+	// defaultTokenURI is where service accounts get access tokens when
+	// their key doesn't say
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+// sheetsAPI is the Google Sheets API, replaced in tests
+var sheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// Credentials is a Google service account key file
+type Credentials struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// ParseCredentials reads a service account key file as downloaded from
+// Google Cloud
+func ParseCredentials(data []byte) (*Credentials, error) {
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("key file isn't valid JSON")
+	}
+	if creds.Type != "service_account" || creds.ClientEmail == "" || creds.PrivateKey == "" {
+		return nil, fmt.Errorf("key file isn't a service account key")
+	}
+	if _, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey)); err != nil {
+		return nil, fmt.Errorf("key file's private key can't be read")
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = defaultTokenURI
+	}
+	return &creds, nil
+}
+
+// token is a cached access token
+type token struct {
+	value   string
+	expires time.Time
+}
+
+// Client calls the Sheets API as service accounts, caching their access
+// tokens until they expire
+type Client struct {
+	http *http.Client
+	now  func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]token
+}
+
+// NewClient creates a client making requests with httpClient
+func NewClient(httpClient *http.Client) *Client {
+	return &Client{
+		http:   httpClient,
+		now:    time.Now,
+		tokens: make(map[string]token),
+	}
+}
+
+// Append adds rows after the last row of a sheet, or of the spreadsheet's
+// first sheet when sheetName is empty. Values are stored as entered, so
+// submitted text is never run as a formula.
+func (c *Client) Append(ctx context.Context, creds *Credentials, spreadsheetID, sheetName string, rows [][]string) error {
+	accessToken, err := c.accessToken(ctx, creds)
+	if err != nil {
+		return err
+	}
+
+	cellRange := "A1"
+	if sheetName != "" {
+		cellRange = "'" + strings.ReplaceAll(sheetName, "'", "''") + "'!A1"
+	}
+	target := fmt.Sprintf("%s/%s/values/%s:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS",
+		sheetsAPI, url.PathEscape(spreadsheetID), url.PathEscape(cellRange))
+	body, err := json.Marshal(map[string]interface{}{"values": rows})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The token was revoked or the key replaced; get a new one next time
+		c.mu.Lock()
+		delete(c.tokens, creds.ClientEmail)
+		c.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apiError(resp)
+	}
+	return nil
+}
+
+// accessToken returns a token for a service account, fetching a new one
+// when there isn't one cached that lasts another minute
+func (c *Client) accessToken(ctx context.Context, creds *Credentials) (string, error) {
+	c.mu.Lock()
+	cached, ok := c.tokens[creds.ClientEmail]
+	c.mu.Unlock()
+	if ok && c.now().Add(time.Minute).Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key: %w", err)
+	}
+	now := c.now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   creds.ClientEmail,
+		"scope": scope,
+		"aud":   creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get access token: %w", apiError(resp))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.AccessToken == "" {
+		return "", fmt.Errorf("failed to read access token")
+	}
+
+	c.mu.Lock()
+	c.tokens[creds.ClientEmail] = token{
+		value:   result.AccessToken,
+		expires: now.Add(time.Duration(result.ExpiresIn) * time.Second),
+	}
+	c.mu.Unlock()
+	return result.AccessToken, nil
+}
+
+// apiError describes a failed response from Google, using the message in
+// its JSON error when there is one
+func apiError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.Unmarshal(data, &body)
+	switch {
+	case body.Error.Message != "":
+		return fmt.Errorf("status %d: %s", resp.StatusCode, body.Error.Message)
+	case body.Description != "":
+		return fmt.Errorf("status %d: %s", resp.StatusCode, body.Description)
+	}
+	return fmt.Errorf("status %d", resp.StatusCode)
+}
+
+// SpreadsheetID returns the ID in a spreadsheet's URL, or the input itself
+// when it isn't a URL
+func SpreadsheetID(input string) string {
+	input = strings.TrimSpace(input)
+	if _, rest, ok := strings.Cut(input, "/spreadsheets/d/"); ok {
+		id, _, _ := strings.Cut(rest, "/")
+		id, _, _ = strings.Cut(id, "?")
+		id, _, _ = strings.Cut(id, "#")
+		return id
+	}
+	return input
+}
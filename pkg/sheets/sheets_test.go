@@ -0,0 +1,215 @@
+package sheets
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+// fakeGoogle serves the token endpoint and the Sheets API's append
+type fakeGoogle struct {
+	*httptest.Server
+	mu      sync.Mutex
+	tokens  int
+	ranges  []string
+	rows    [][]string
+	failing bool
+}
+
+func newFakeGoogle(t *testing.T) *fakeGoogle {
+	g := &fakeGoogle{}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		switch {
+		case r.URL.Path == "/token":
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.FormValue("assertion") == "" {
+				http.Error(w, `{"error_description":"bad request"}`, http.StatusBadRequest)
+				return
+			}
+			g.tokens++
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": 3600})
+		case strings.HasSuffix(r.URL.Path, ":append"):
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if g.failing {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error":{"message":"The caller does not have permission"}}`))
+				return
+			}
+			var body struct {
+				Values [][]string `json:"values"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			g.ranges = append(g.ranges, r.URL.Path)
+			g.rows = append(g.rows, body.Values...)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(g.Close)
+
+	api := sheetsAPI
+	sheetsAPI = g.URL + "/v4/spreadsheets"
+	t.Cleanup(func() { sheetsAPI = api })
+	return g
+}
+
+// testKey returns a service account key file for the fake token endpoint
+func testKey(t *testing.T, tokenURI string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	data, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "sync@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURI,
+	})
+	return string(data)
+}
+
+func TestParseCredentials(t *testing.T) {
+	creds, err := ParseCredentials([]byte(testKey(t, "")))
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	if creds.ClientEmail != "sync@project.iam.gserviceaccount.com" || creds.TokenURI != defaultTokenURI {
+		t.Errorf("Unexpected credentials %+v", creds)
+	}
+	for _, bad := range []string{`not json`, `{"type":"authorized_user"}`, `{"type":"service_account","client_email":"a@b","private_key":"nope"}`} {
+		if _, err := ParseCredentials([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	columns, err := ParseColumns("Submitted = @created_at\n\nEmail Address = email\nmessage\n")
+	if err != nil {
+		t.Fatalf("Failed to parse columns: %v", err)
+	}
+	want := []models.SheetColumn{{Header: "Submitted", Field: "@created_at"}, {Header: "Email Address", Field: "email"}, {Header: "message", Field: "message"}}
+	if len(columns) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, columns)
+	}
+	for i := range want {
+		if columns[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], columns[i])
+		}
+	}
+	if got, _ := ParseColumns(FormatColumns(columns)); len(got) != 3 || got[1] != want[1] {
+		t.Errorf("Expected formatted columns to parse back, got %v", got)
+	}
+	for _, bad := range []string{"", "Name =", "When = @when"} {
+		if _, err := ParseColumns(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+
+	row := Row(columns, models.Submission{ID: 3, SubmittedData: []byte(`{"email":"a@example.com","message":["x","y"]}`)})
+	if row[1] != "a@example.com" || row[2] != `["x","y"]` {
+		t.Errorf("Unexpected row %q", row)
+	}
+}
+
+func TestSpreadsheetID(t *testing.T) {
+	for input, want := range map[string]string{
+		"https://docs.google.com/spreadsheets/d/1AbC_d-E/edit#gid=0": "1AbC_d-E",
+		"https://docs.google.com/spreadsheets/d/1AbC?usp=sharing":    "1AbC",
+		" 1AbC ": "1AbC",
+	} {
+		if got := SpreadsheetID(input); got != want {
+			t.Errorf("SpreadsheetID(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestSyncer(t *testing.T) {
+	google := newFakeGoogle(t)
+
+	db, err := database.Init(filepath.Join(t.TempDir(), "staticsend.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "sheets-key")
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"name":"Ann","_gotcha":""}`))
+	models.CreateSpamSubmission(db.Connection, form.ID, "127.0.0.1", "bot", "", []byte(`{"name":"Spam"}`), models.SpamReasonHoneypot)
+
+	columns, err := DefaultColumns(context.Background(), db, form.ID)
+	if err != nil || len(columns) != 2 || columns[1].Field != "name" {
+		t.Fatalf("Expected the form's fields as columns, got %v, %v", columns, err)
+	}
+	syncer := NewSyncer(db, NewClient(http.DefaultClient))
+	syncer.Save(context.Background(), &models.FormSheet{FormID: form.ID, SpreadsheetID: "sheet-1", SheetName: "Leads", Columns: columns})
+
+	// Without a key the sync fails and records why
+	if _, err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Failed to run sync: %v", err)
+	}
+	if sheet, _ := models.GetFormSheet(db.Connection, form.ID); !strings.Contains(sheet.Error, "no Google service account key") {
+		t.Errorf("Expected a missing key error, got %q", sheet.Error)
+	}
+
+	models.SetGoogleCredentials(db.Connection, user.ID, "sync@project.iam.gserviceaccount.com", testKey(t, google.URL+"/token"))
+	appended, err := syncer.Run(context.Background())
+	if err != nil || appended != 1 {
+		t.Fatalf("Expected one submission appended, got %d, %v", appended, err)
+	}
+	if len(google.rows) != 2 || google.rows[0][1] != "name" || google.rows[1][1] != "Ann" {
+		t.Fatalf("Expected the header and one row, got %v", google.rows)
+	}
+	if google.ranges[0] != "/v4/spreadsheets/sheet-1/values/'Leads'!A1:append" {
+		t.Errorf("Unexpected range %s", google.ranges[0])
+	}
+	sheet, _ := models.GetFormSheet(db.Connection, form.ID)
+	if !sheet.HeaderWritten || sheet.LastSubmissionID == 0 || sheet.Error != "" || sheet.SyncedAt == nil {
+		t.Errorf("Expected the sync to be recorded, got %+v", sheet)
+	}
+
+	// Only new submissions are appended, with the cached token
+	if appended, _ := syncer.Run(context.Background()); appended != 0 {
+		t.Errorf("Expected nothing new to append, got %d", appended)
+	}
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{"name":"Bob"}`))
+	google.failing = true
+	syncer.Run(context.Background())
+	if sheet, _ := models.GetFormSheet(db.Connection, form.ID); !strings.Contains(sheet.Error, "does not have permission") {
+		t.Errorf("Expected Google's error to be recorded, got %q", sheet.Error)
+	}
+	google.failing = false
+	if appended, _ := syncer.Run(context.Background()); appended != 1 || google.rows[len(google.rows)-1][1] != "Bob" {
+		t.Errorf("Expected the failed submission to be appended on the next run, got %d", appended)
+	}
+	if google.tokens != 1 {
+		t.Errorf("Expected one access token, got %d", google.tokens)
+	}
+
+	// A backfill appends everything again, starting with the header
+	google.rows = nil
+	if err := syncer.Backfill(context.Background(), form.ID); err != nil {
+		t.Fatalf("Failed to start backfill: %v", err)
+	}
+	if appended, _ := syncer.Run(context.Background()); appended != 2 || len(google.rows) != 3 || google.rows[0][0] != "Submitted" {
+		t.Errorf("Expected the header and both submissions, got %v", google.rows)
+	}
+}
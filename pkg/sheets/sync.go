@@ -0,0 +1,295 @@
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+const (
+	// batchSize caps the rows appended in one request
+	batchSize = 500
+	// fieldSample is how many recent submissions default columns come from
+	fieldSample = 100
+	// maxErrorLength caps the sync error kept for a form
+	maxErrorLength = 1000
+)
+
+// Properties of a submission that columns can hold besides its fields
+var properties = map[string]func(models.Submission) string{
+	"@id":         func(s models.Submission) string { return strconv.FormatInt(s.ID, 10) },
+	"@created_at": func(s models.Submission) string { return s.CreatedAt.UTC().Format(time.RFC3339) },
+	"@ip_address": func(s models.Submission) string { return s.IPAddress },
+	"@user_agent": func(s models.Submission) string { return s.UserAgent },
+	"@referrer":   func(s models.Submission) string { return s.Referrer },
+}
+
+// DefaultColumns maps a form's fields to columns: when each submission was
+// made, then every field in its recent submissions in alphabetical order
+func DefaultColumns(ctx context.Context, db *database.Database, formID int64) ([]models.SheetColumn, error) {
+	names, err := models.GetSubmissionFieldNamesContext(ctx, db.Connection, formID, fieldSample)
+	if err != nil {
+		return nil, err
+	}
+	columns := []models.SheetColumn{{Header: "Submitted", Field: "@created_at"}}
+	for _, name := range names {
+		if name != models.HoneypotField {
+			columns = append(columns, models.SheetColumn{Header: name, Field: name})
+		}
+	}
+	return columns, nil
+}
+
+// ParseColumns reads a column mapping written one column per line as
+// "Header = field", or just "field" to use the field's name as its header
+func ParseColumns(text string) ([]models.SheetColumn, error) {
+	var columns []models.SheetColumn
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		header, field, ok := strings.Cut(line, "=")
+		if !ok {
+			field = header
+		}
+		header, field = strings.TrimSpace(header), strings.TrimSpace(field)
+		if field == "" {
+			return nil, fmt.Errorf("column %q has no field", line)
+		}
+		if strings.HasPrefix(field, "@") && properties[field] == nil {
+			return nil, fmt.Errorf("unknown submission property %s", field)
+		}
+		if header == "" {
+			header = field
+		}
+		columns = append(columns, models.SheetColumn{Header: header, Field: field})
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("at least one column is required")
+	}
+	return columns, nil
+}
+
+// FormatColumns writes a column mapping in the form ParseColumns reads
+func FormatColumns(columns []models.SheetColumn) string {
+	lines := make([]string, len(columns))
+	for i, column := range columns {
+		lines[i] = column.Header + " = " + column.Field
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Row returns a submission's values for each column
+func Row(columns []models.SheetColumn, submission models.Submission) []string {
+	var raw map[string]json.RawMessage
+	json.Unmarshal(submission.SubmittedData, &raw)
+
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		if property := properties[column.Field]; property != nil {
+			row[i] = property(submission)
+			continue
+		}
+		value, ok := raw[column.Field]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, &row[i]); err != nil {
+			// Values that aren't strings, such as checkbox lists, go in as JSON
+			row[i] = string(value)
+		}
+	}
+	return row
+}
+
+// headers returns the header row for a column mapping
+func headers(columns []models.SheetColumn) []string {
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		row[i] = column.Header
+	}
+	return row
+}
+
+// Syncer appends forms' new submissions to their Google Sheets in the
+// background. Each form's sheet remembers the last submission appended, so
+// a failed sync picks up where it stopped on the next run.
+type Syncer struct {
+	db     *database.Database
+	client *Client
+
+	// mu ensures only one run happens at a time, and that changes to a
+	// form's sheet don't race with its sync
+	mu   sync.Mutex
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSyncer creates a syncer writing through client
+func NewSyncer(db *database.Database, client *Client) *Syncer {
+	return &Syncer{
+		db:     db,
+		client: client,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Wake asks the worker started by Start to sync now rather than on its
+// next tick
+func (s *Syncer) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Save replaces the sheet a form syncs to
+func (s *Syncer) Save(ctx context.Context, sheet *models.FormSheet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return models.SaveFormSheetContext(ctx, s.db.Connection, sheet)
+}
+
+// Backfill makes the next sync append every submission to a form's sheet
+// again, starting with the header row
+func (s *Syncer) Backfill(ctx context.Context, formID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sheet, err := models.GetFormSheetContext(ctx, s.db.Connection, formID)
+	if err != nil || sheet == nil {
+		return err
+	}
+	sheet.LastSubmissionID = 0
+	sheet.HeaderWritten = false
+	return models.SaveFormSheetContext(ctx, s.db.Connection, sheet)
+}
+
+// Run syncs every form's sheet, returning how many submissions it appended
+func (s *Syncer) Run(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sheets, err := models.GetFormSheetsContext(ctx, s.db.Connection)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sheets: %w", err)
+	}
+
+	appended := 0
+	for i := range sheets {
+		n, err := s.sync(ctx, &sheets[i])
+		appended += n
+		if err != nil {
+			log.Printf("Google Sheets sync of form %d failed: %v", sheets[i].FormID, err)
+			message := err.Error()
+			if len(message) > maxErrorLength {
+				message = message[:maxErrorLength]
+			}
+			if err := models.FailSheetSyncContext(ctx, s.db.Connection, sheets[i].FormID, message); err != nil {
+				return appended, fmt.Errorf("failed to record sync error: %w", err)
+			}
+		}
+	}
+	return appended, nil
+}
+
+// sync appends a form's submissions after the last one synced, a batch at
+// a time, recording progress after each batch
+func (s *Syncer) sync(ctx context.Context, sheet *models.FormSheet) (int, error) {
+	form, err := models.GetFormByIDContext(ctx, s.db.Connection, sheet.FormID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load form: %w", err)
+	}
+	if form == nil {
+		return 0, fmt.Errorf("form not found")
+	}
+	stored, err := models.GetGoogleCredentialsContext(ctx, s.db.Connection, form.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load service account key: %w", err)
+	}
+	if stored == nil {
+		return 0, fmt.Errorf("no Google service account key has been added")
+	}
+	creds, err := ParseCredentials([]byte(stored.Credentials))
+	if err != nil {
+		return 0, err
+	}
+
+	appended := 0
+	for {
+		submissions, err := models.GetSubmissionsAfterIDContext(ctx, s.db.Connection, sheet.FormID, sheet.LastSubmissionID, batchSize)
+		if err != nil {
+			return appended, fmt.Errorf("failed to read submissions: %w", err)
+		}
+		if len(submissions) == 0 && sheet.HeaderWritten {
+			return appended, nil
+		}
+
+		var rows [][]string
+		if !sheet.HeaderWritten {
+			rows = append(rows, headers(sheet.Columns))
+		}
+		for _, submission := range submissions {
+			rows = append(rows, Row(sheet.Columns, submission))
+		}
+		if err := s.client.Append(ctx, creds, sheet.SpreadsheetID, sheet.SheetName, rows); err != nil {
+			return appended, err
+		}
+
+		if len(submissions) > 0 {
+			sheet.LastSubmissionID = submissions[len(submissions)-1].ID
+		}
+		sheet.HeaderWritten = true
+		appended += len(submissions)
+		if err := models.RecordSheetSyncContext(ctx, s.db.Connection, sheet.FormID, sheet.LastSubmissionID, true); err != nil {
+			return appended, fmt.Errorf("failed to record sync: %w", err)
+		}
+		if len(submissions) < batchSize {
+			return appended, nil
+		}
+	}
+}
+
+// Start syncs every interval, and whenever woken, until Stop is called
+func (s *Syncer) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+			if _, err := s.Run(context.Background()); err != nil {
+				log.Printf("Scheduled Google Sheets sync failed: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop stops the worker started by Start, waiting for a sync in progress
+func (s *Syncer) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
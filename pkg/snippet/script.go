@@ -0,0 +1,143 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+)
+
+// ScriptPath is where the form script is served
+const ScriptPath = "/static/js/staticsend.js"
+
+// SubmitPath is the submission endpoint, followed by a form's key
+const SubmitPath = "/api/v1/submit/"
+
+// ScriptOptions controls the generated form script
+type ScriptOptions struct {
+	Version string // Build the script came from, noted in its header
+}
+
+// scriptTemplate upgrades forms posting to the server to submit with
+// fetch(), showing the outcome beside the form. The server's address is
+// taken from the script's own URL, so it works behind any base path.
+var scriptTemplate = template.Must(template.New("script").Funcs(template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}).Parse(`/*! staticSend form script {{.Version}} */
+(function () {
+  "use strict";
+  var script = document.currentScript;
+  if (!script || !window.fetch || !window.FormData) {
+    return;
+  }
+  var base = script.src.split("?")[0].slice(0, -{{len .ScriptPath}});
+  var submitURL = base + {{json .SubmitPath}};
+  var turnstileAPI = "https://challenges.cloudflare.com/turnstile/v0/api.js";
+
+  function message(form, text, failed) {
+    var box = form.querySelector("[data-staticsend-message]");
+    if (!box) {
+      box = document.createElement("p");
+      box.setAttribute("data-staticsend-message", "");
+      form.appendChild(box);
+    }
+    box.setAttribute("role", failed ? "alert" : "status");
+    box.className = failed ? "staticsend-error" : "staticsend-success";
+    box.textContent = text;
+  }
+
+  function resetTurnstile(form) {
+    var widget = form.querySelector(".cf-turnstile");
+    if (widget && window.turnstile) {
+      window.turnstile.reset(widget);
+    }
+  }
+
+  function upgrade(form) {
+    if (form.staticsend) {
+      return;
+    }
+    form.staticsend = true;
+    // Forms with a widget need Turnstile's script, which the page may
+    // not load itself
+    if (form.querySelector(".cf-turnstile") && !window.turnstile &&
+        !document.querySelector('script[src^="' + turnstileAPI + '"]')) {
+      var api = document.createElement("script");
+      api.src = turnstileAPI;
+      api.async = true;
+      document.head.appendChild(api);
+    }
+
+    form.addEventListener("submit", function (event) {
+      event.preventDefault();
+      var data = new FormData(form);
+      if (form.querySelector(".cf-turnstile") && !data.get("cf-turnstile-response")) {
+        message(form, form.getAttribute("data-verify") || "Please complete the verification first.", true);
+        return;
+      }
+      var button = form.querySelector("[type=submit]");
+      if (button) {
+        button.disabled = true;
+      }
+      fetch(form.action, { method: "POST", body: data, headers: { Accept: "application/json" } })
+        .then(function (response) {
+          return response.json().catch(function () {
+            return {};
+          }).then(function (body) {
+            if (!response.ok) {
+              throw { shown: body.message || "Sorry, something went wrong. Please try again." };
+            }
+            if (body.redirect) {
+              window.location.assign(body.redirect);
+              return;
+            }
+            form.reset();
+            message(form, form.getAttribute("data-success") || "Thanks, your message has been sent.", false);
+          });
+        })
+        .catch(function (err) {
+          // Network errors' messages aren't meant for visitors
+          message(form, err.shown || "Sorry, the form couldn't be sent. Please try again.", true);
+        })
+        .then(function () {
+          resetTurnstile(form);
+          if (button) {
+            button.disabled = false;
+          }
+        });
+    });
+  }
+
+  function upgradeAll() {
+    var forms = document.querySelectorAll("form[data-staticsend], form[action]");
+    for (var i = 0; i < forms.length; i++) {
+      if (forms[i].hasAttribute("data-staticsend") || forms[i].action.indexOf(submitURL) === 0) {
+        upgrade(forms[i]);
+      }
+    }
+  }
+
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", upgradeAll);
+  } else {
+    upgradeAll();
+  }
+})();
+`))
+
+// Script returns the form script, which pages include to have their forms
+// submit without leaving the page
+func Script(opts ScriptOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	err := scriptTemplate.Execute(&buf, struct {
+		ScriptOptions
+		ScriptPath string
+		SubmitPath string
+	}{opts, ScriptPath, SubmitPath})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,178 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"staticsend/pkg/models"
+)
+
+// SiteKeyPlaceholder is used in place of a Turnstile site key that isn't known
+const SiteKeyPlaceholder = "YOUR_TURNSTILE_SITE_KEY"
+
+// DefaultFields are the fields offered before a form has any submissions
+var DefaultFields = []string{"name", "email", "message"}
+
+// Options controls the generated snippet
+type Options struct {
+	Endpoint string   // URL the form posts to
+	SiteKey  string   // Turnstile site key, SiteKeyPlaceholder when empty
+	Honeypot string   // Name of a hidden field real visitors leave empty
+	Fields   []string // Field names to include, DefaultFields when empty
+	Inputs   []Field  // Inputs to include, overriding Fields when set
+	Script   bool     // Submit with fetch() instead of navigating away
+	// ScriptURL is the form script's URL, used with Script
+	ScriptURL string
+}
+
+// Field is an input in the generated form
+type Field struct {
+	Name      string
+	Label     string
+	Type      string // Input type, or "textarea"
+	Required  bool
+	MaxLength int // No limit when 0
+}
+
+// requiredPercent is the share of submissions a field must be filled in on
+// for Suggest to make it required
+const requiredPercent = 95
+
+// lengthStep is what suggested maximum lengths are rounded up to
+const lengthStep = 50
+
+var snippetTemplate = template.Must(template.New("snippet").Parse(`<form action="{{.Endpoint}}" method="POST">
+{{- range .Fields}}
+  <label for="{{.Name}}">{{.Label}}</label>
+{{- if eq .Type "textarea"}}
+  <textarea id="{{.Name}}" name="{{.Name}}"{{if .MaxLength}} maxlength="{{.MaxLength}}"{{end}}{{if .Required}} required{{end}}></textarea>
+{{- else}}
+  <input type="{{.Type}}" id="{{.Name}}" name="{{.Name}}"{{if .MaxLength}} maxlength="{{.MaxLength}}"{{end}}{{if .Required}} required{{end}}>
+{{- end}}
+{{- end}}
+{{- if .Honeypot}}
+
+  <!-- Leave this field empty; it catches bots that fill in every input -->
+  <input type="text" name="{{.Honeypot}}" tabindex="-1" autocomplete="off" style="display:none">
+{{- end}}
+
+  <div class="cf-turnstile" data-sitekey="{{.SiteKey}}"></div>
+  <button type="submit">Send</button>
+</form>
+<script src="https://challenges.cloudflare.com/turnstile/v0/api.js" async defer></script>
+{{- if .Script}}
+<script src="{{.ScriptURL}}" defer></script>
+{{- end}}
+`))
+
+// Generate returns HTML for a form that posts to opts.Endpoint
+func Generate(opts Options) (string, error) {
+	if opts.SiteKey == "" {
+		opts.SiteKey = SiteKeyPlaceholder
+	}
+	fields := opts.Inputs
+	if len(fields) == 0 {
+		names := opts.Fields
+		if len(names) == 0 {
+			names = DefaultFields
+		}
+		fields = make([]Field, 0, len(names))
+		for _, name := range names {
+			fields = append(fields, NewField(name))
+		}
+	}
+
+	var buf bytes.Buffer
+	err := snippetTemplate.Execute(&buf, struct {
+		Options
+		Fields []Field
+	}{opts, fields})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// NewField guesses a label and input type from a field name. The field is
+// required.
+func NewField(name string) Field {
+	lower := strings.ToLower(name)
+	field := Field{Name: name, Label: label(name), Type: "text", Required: true}
+	switch {
+	case strings.Contains(lower, "email"):
+		field.Type = "email"
+	case strings.Contains(lower, "phone"), lower == "tel":
+		field.Type = "tel"
+	case strings.Contains(lower, "url"), strings.Contains(lower, "website"):
+		field.Type = "url"
+	case strings.Contains(lower, "message"), strings.Contains(lower, "comment"), strings.Contains(lower, "details"):
+		field.Type = "textarea"
+	}
+	return field
+}
+
+// Suggest makes an input for a field from what a form has received in it,
+// out of submissions cataloged. The input's type follows the values' type.
+// It's required if nearly every submission filled it in, and text gets a
+// maximum length with room to spare over the longest value.
+func Suggest(field models.FormField, submissions int) Field {
+	suggested := Field{Name: field.Name, Label: label(field.Name), Type: "text"}
+	switch field.Type() {
+	case models.FieldTypeEmail:
+		suggested.Type = "email"
+	case models.FieldTypeURL:
+		suggested.Type = "url"
+	case models.FieldTypePhone:
+		suggested.Type = "tel"
+	case models.FieldTypeNumber:
+		suggested.Type = "number"
+	case models.FieldTypeDate:
+		suggested.Type = "date"
+	case models.FieldTypeCheckbox:
+		suggested.Type = "checkbox"
+	case models.FieldTypeLongText:
+		suggested.Type = "textarea"
+	}
+	if suggested.Type == "text" || suggested.Type == "textarea" {
+		suggested.MaxLength = roundUp(field.MaxLength*3/2, lengthStep)
+	}
+	// An unticked checkbox isn't sent, so it's never required
+	suggested.Required = suggested.Type != "checkbox" && submissions > 0 &&
+		field.SubmissionCount*100 >= submissions*requiredPercent
+	return suggested
+}
+
+// Attributes describes the input's type and validation as HTML attributes
+func (f Field) Attributes() string {
+	attributes := fmt.Sprintf(`type="%s"`, f.Type)
+	if f.Type == "textarea" {
+		attributes = "textarea"
+	}
+	if f.MaxLength > 0 {
+		attributes += fmt.Sprintf(` maxlength="%d"`, f.MaxLength)
+	}
+	if f.Required {
+		attributes += " required"
+	}
+	return attributes
+}
+
+// roundUp rounds n up to a multiple of step, and at least step
+func roundUp(n, step int) int {
+	if n <= step {
+		return step
+	}
+	return (n + step - 1) / step * step
+}
+
+// label turns a field name such as "first_name" into "First name"
+func label(name string) string {
+	words := strings.Fields(strings.NewReplacer("_", " ", "-", " ", ".", " ").Replace(name))
+	if len(words) == 0 {
+		return name
+	}
+	text := strings.ToLower(strings.Join(words, " "))
+	return strings.ToUpper(text[:1]) + text[1:]
+}
@@ -0,0 +1,175 @@
+package snippet
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/models"
+)
+
+func TestGenerate(t *testing.T) {
+	code, err := Generate(Options{
+		Endpoint: "https://forms.example.com/api/v1/submit/abc123",
+		Honeypot: "_gotcha",
+		Fields:   []string{"email", "first_name", `"><script>`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate snippet: %v", err)
+	}
+
+	for _, want := range []string{
+		`action="https://forms.example.com/api/v1/submit/abc123"`,
+		`<input type="email" id="email" name="email" required>`,
+		`<label for="first_name">First name</label>`,
+		`name="_gotcha"`,
+		`data-sitekey="` + SiteKeyPlaceholder + `"`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected snippet to contain %q, got:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, `"><script>`) {
+		t.Errorf("Expected field names to be escaped, got:\n%s", code)
+	}
+	if strings.Contains(code, "staticsend.js") {
+		t.Errorf("Expected no script unless asked for, got:\n%s", code)
+	}
+}
+
+func TestGenerateDefaults(t *testing.T) {
+	code, err := Generate(Options{Endpoint: "/submit", SiteKey: "0x4AAA", Script: true, ScriptURL: "https://forms.example.com/static/js/staticsend.js?v=abc"})
+	if err != nil {
+		t.Fatalf("Failed to generate snippet: %v", err)
+	}
+
+	for _, want := range []string{
+		`<textarea id="message" name="message" required></textarea>`,
+		`data-sitekey="0x4AAA"`,
+		`<script src="https://forms.example.com/static/js/staticsend.js?v=abc" defer></script>`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected snippet to contain %q, got:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "display:none") {
+		t.Errorf("Expected no honeypot field unless asked for, got:\n%s", code)
+	}
+}
+
+func TestScript(t *testing.T) {
+	script, err := Script(ScriptOptions{Version: "v1.2.0"})
+	if err != nil {
+		t.Fatalf("Failed to generate script: %v", err)
+	}
+	code := string(script)
+	for _, want := range []string{
+		"/*! staticSend form script v1.2.0 */",
+		// The server's address is the script's URL without its path
+		fmt.Sprintf(".slice(0, -%d)", len(ScriptPath)),
+		`var submitURL = base + "/api/v1/submit/";`,
+		"body.redirect",
+		"cf-turnstile-response",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected the script to contain %q, got:\n%s", want, code)
+		}
+	}
+}
+
+func TestNewField(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantLabel string
+		wantType  string
+	}{
+		{"email", "Email", "email"},
+		{"reply-email", "Reply email", "email"},
+		{"phone_number", "Phone number", "tel"},
+		{"website", "Website", "url"},
+		{"Message", "Message", "textarea"},
+		{"company", "Company", "text"},
+	}
+	for _, tt := range tests {
+		field := NewField(tt.name)
+		if field.Label != tt.wantLabel || field.Type != tt.wantType {
+			t.Errorf("NewField(%q) = %q, %q; want %q, %q", tt.name, field.Label, field.Type, tt.wantLabel, tt.wantType)
+		}
+	}
+}
+
+func TestGenerateInputs(t *testing.T) {
+	code, err := Generate(Options{
+		Endpoint: "/submit",
+		Fields:   []string{"ignored"},
+		Inputs: []Field{
+			{Name: "email", Label: "Email", Type: "email", Required: true},
+			{Name: "notes", Label: "Notes", Type: "textarea", MaxLength: 500},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate snippet: %v", err)
+	}
+
+	for _, want := range []string{
+		`<input type="email" id="email" name="email" required>`,
+		`<textarea id="notes" name="notes" maxlength="500"></textarea>`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected snippet to contain %q, got:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "ignored") {
+		t.Errorf("Expected the inputs to override the field names, got:\n%s", code)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name  string
+		field models.FormField
+		want  Field
+	}{
+		{
+			"email in every submission",
+			models.FormField{Name: "email", SubmissionCount: 20, Types: map[string]int{models.FieldTypeEmail: 20}, MaxLength: 30},
+			Field{Name: "email", Label: "Email", Type: "email", Required: true},
+		},
+		{
+			"text in most submissions",
+			models.FormField{Name: "company_name", SubmissionCount: 15, Types: map[string]int{models.FieldTypeText: 15}, MaxLength: 40},
+			Field{Name: "company_name", Label: "Company name", Type: "text", MaxLength: 100},
+		},
+		{
+			"long text",
+			models.FormField{Name: "message", SubmissionCount: 19, Types: map[string]int{models.FieldTypeLongText: 19}, MaxLength: 10},
+			Field{Name: "message", Label: "Message", Type: "textarea", Required: true, MaxLength: 50},
+		},
+		{
+			"checkbox",
+			models.FormField{Name: "subscribe", SubmissionCount: 20, Types: map[string]int{models.FieldTypeCheckbox: 20}, MaxLength: 2},
+			Field{Name: "subscribe", Label: "Subscribe", Type: "checkbox"},
+		},
+	}
+	for _, tt := range tests {
+		if got := Suggest(tt.field, 20); got != tt.want {
+			t.Errorf("%s: Suggest() = %+v; want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFieldAttributes(t *testing.T) {
+	tests := []struct {
+		field Field
+		want  string
+	}{
+		{Field{Type: "email", Required: true}, `type="email" required`},
+		{Field{Type: "textarea", MaxLength: 300}, `textarea maxlength="300"`},
+		{Field{Type: "text"}, `type="text"`},
+	}
+	for _, tt := range tests {
+		if got := tt.field.Attributes(); got != tt.want {
+			t.Errorf("Attributes() = %q; want %q", got, tt.want)
+		}
+	}
+}
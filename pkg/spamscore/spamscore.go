@@ -0,0 +1,117 @@
+// Package spamscore combines what's known about a submission, from Akismet,
+// Turnstile and the sender's history, into one spam score from 0 to 100. A
+// form's thresholds then decide whether the submission is accepted, flagged
+// as spam or rejected.
+package spamscore
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// VelocityWindow is how far back the sender's submissions to the form
+	// are counted
+	VelocityWindow = 10 * time.Minute
+
+	// ReputationWindow is how far back the sender's spam and blocked
+	// attempts are counted
+	ReputationWindow = 30 * 24 * time.Hour
+)
+
+// What each signal adds to the score
+const (
+	blocklistWeight        = 60
+	hostnameMismatchWeight = 40
+	burstWeight            = 20 // 3 or more recent submissions
+	floodWeight            = 40 // 10 or more recent submissions
+	spamHistoryWeight      = 15 // per earlier submission held as spam
+	maxSpamHistoryWeight   = 45
+	blockedWeight          = 25
+)
+
+// Signals are what's known about a submission when it's scored. They're
+// stored with the submission as JSON, to show why it scored as it did.
+type Signals struct {
+	// Akismet is Akismet's score, when the form has Akismet turned on
+	Akismet *int `json:"akismet,omitempty"`
+	// TurnstileHostname, TurnstileAction and TurnstileCData are what
+	// Turnstile reported about the challenge the token came from
+	TurnstileHostname string `json:"turnstile_hostname,omitempty"`
+	TurnstileAction   string `json:"turnstile_action,omitempty"`
+	TurnstileCData    string `json:"turnstile_cdata,omitempty"`
+	// HostnameMismatch is set when the challenge was solved on a site other
+	// than the form's domain
+	HostnameMismatch bool `json:"hostname_mismatch,omitempty"`
+	// BlocklistTerm is the spam blocklist term the submission contains
+	BlocklistTerm string `json:"blocklist_term,omitempty"`
+	// RecentSubmissions counts the sender's submissions to the form in the
+	// last VelocityWindow
+	RecentSubmissions int `json:"recent_submissions"`
+	// SpamSubmissions and BlockedAttempts count the sender's submissions
+	// held as spam and attempts blocked in the last ReputationWindow
+	SpamSubmissions int `json:"spam_submissions"`
+	BlockedAttempts int `json:"blocked_attempts"`
+}
+
+// Score combines the signals into a score from 0 to 100. Akismet's score
+// is the starting point, and each other signal adds to it.
+func (s Signals) Score() int {
+	score := 0
+	if s.Akismet != nil {
+		score = *s.Akismet
+	}
+	if s.BlocklistTerm != "" {
+		score += blocklistWeight
+	}
+	if s.HostnameMismatch {
+		score += hostnameMismatchWeight
+	}
+	switch {
+	case s.RecentSubmissions >= 10:
+		score += floodWeight
+	case s.RecentSubmissions >= 3:
+		score += burstWeight
+	}
+	score += min(s.SpamSubmissions*spamHistoryWeight, maxSpamHistoryWeight)
+	if s.BlockedAttempts > 0 {
+		score += blockedWeight
+	}
+	return max(0, min(score, 100))
+}
+
+// HostnameMatches reports whether Turnstile's hostname is the form's domain
+// or one of its subdomains. Either being unknown counts as a match.
+func HostnameMatches(hostname, domain string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	domain = strings.ToLower(strings.TrimPrefix(strings.TrimSuffix(domain, "."), "www."))
+	if hostname == "" || domain == "" {
+		return true
+	}
+	return hostname == domain || strings.HasSuffix(hostname, "."+domain)
+}
+
+// Action is what happens to a submission
+type Action int
+
+const (
+	// Accept saves and forwards the submission
+	Accept Action = iota
+	// Flag holds the submission as spam for review
+	Flag
+	// Reject refuses the submission without saving it
+	Reject
+)
+
+// Decide returns the action for a score given a form's thresholds. A
+// threshold of 0 turns it off.
+func Decide(score, flagAt, rejectAt int) Action {
+	switch {
+	case rejectAt > 0 && score >= rejectAt:
+		return Reject
+	case flagAt > 0 && score >= flagAt:
+		return Flag
+	default:
+		return Accept
+	}
+}
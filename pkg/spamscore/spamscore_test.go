@@ -0,0 +1,69 @@
+package spamscore
+
+import "testing"
+
+func TestSignals_Score(t *testing.T) {
+	akismet := func(score int) *int { return &score }
+	tests := []struct {
+		name    string
+		signals Signals
+		want    int
+	}{
+		{"nothing known", Signals{}, 0},
+		{"akismet alone", Signals{Akismet: akismet(80)}, 80},
+		{"blocklist", Signals{BlocklistTerm: "casino"}, 60},
+		{"solved elsewhere", Signals{HostnameMismatch: true}, 40},
+		{"burst", Signals{RecentSubmissions: 3}, 20},
+		{"flood", Signals{RecentSubmissions: 12}, 40},
+		{"spam history is capped", Signals{SpamSubmissions: 10}, 45},
+		{"blocked before", Signals{BlockedAttempts: 2}, 25},
+		{"capped at 100", Signals{Akismet: akismet(80), BlocklistTerm: "casino"}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signals.Score(); got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHostnameMatches(t *testing.T) {
+	tests := []struct {
+		hostname, domain string
+		want             bool
+	}{
+		{"example.com", "example.com", true},
+		{"www.example.com", "example.com", true},
+		{"example.com", "www.example.com", true},
+		{"Blog.Example.com", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"attacker.net", "example.com", false},
+		{"", "example.com", true},
+		{"example.com", "", true},
+	}
+	for _, tt := range tests {
+		if got := HostnameMatches(tt.hostname, tt.domain); got != tt.want {
+			t.Errorf("HostnameMatches(%q, %q) = %v, expected %v", tt.hostname, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		score, flagAt, rejectAt int
+		want                    Action
+	}{
+		{10, 80, 0, Accept},
+		{80, 80, 0, Flag},
+		{100, 80, 0, Flag},
+		{95, 80, 95, Reject},
+		{100, 0, 0, Accept},
+		{50, 0, 50, Reject},
+	}
+	for _, tt := range tests {
+		if got := Decide(tt.score, tt.flagAt, tt.rejectAt); got != tt.want {
+			t.Errorf("Decide(%d, %d, %d) = %v, expected %v", tt.score, tt.flagAt, tt.rejectAt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore keeps files in a directory on disk
+type LocalStore struct {
+	dir string
+	// route is the path the store's download links start with, and secret
+	// signs them
+	route  string
+	secret []byte
+	now    func() time.Time
+}
+
+// NewLocalStore creates a store that writes files to dir, creating it if
+// needed. Download links start with route, where the store must be served,
+// and are signed with secret; without them the store can't make links.
+func NewLocalStore(dir, route string, secret []byte) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStore{dir: dir, route: strings.TrimSuffix(route, "/"), secret: secret, now: time.Now}, nil
+}
+
+// Put writes the file to a temporary file and renames it into place, so a
+// failed write never leaves a truncated file behind
+func (s *LocalStore) Put(ctx context.Context, name string, r io.Reader) error {
+	tmp, err := os.CreateTemp(s.dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
+
+// Open opens a file for reading
+func (s *LocalStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, filepath.Base(name)))
+}
+
+// List returns the files in the directory
+func (s *LocalStore) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		// Skip directories and the temporary files of writes in progress
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// Delete removes a file
+func (s *LocalStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(s.dir, filepath.Base(name)))
+}
+
+// Route returns the path the store's download links start with, or "" if
+// it doesn't make links
+func (s *LocalStore) Route() string {
+	if len(s.secret) == 0 {
+		return ""
+	}
+	return s.route
+}
+
+// SignedURL returns a path under the store's route that ServeHTTP serves
+// until ttl has passed
+func (s *LocalStore) SignedURL(ctx context.Context, name, filename string, ttl time.Duration) (string, error) {
+	if s.Route() == "" {
+		return "", ErrNoLinks
+	}
+	name = filepath.Base(name)
+	expires := s.now().Add(ttl).Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"filename":  {filename},
+		"signature": {s.sign(name, filename, expires)},
+	}
+	return s.route + "/" + url.PathEscape(name) + "?" + query.Encode(), nil
+}
+
+// sign returns the signature of a file's download link
+func (s *LocalStore) sign(name, filename string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "storage:%s/%s:%s:%d", s.route, name, filename, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP downloads the file named by the last part of the path to
+// anyone with a link from SignedURL that hasn't expired
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+	query := r.URL.Query()
+	filename := query.Get("filename")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if s.Route() == "" || err != nil || s.now().Unix() >= expires ||
+		!hmac.Equal([]byte(query.Get("signature")), []byte(s.sign(name, filename, expires))) {
+		http.Error(w, "This download link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	if filename == "" {
+		filename = name
+	}
+	w.Header().Set("Content-Type", contentType(filename))
+	w.Header().Set("Content-Disposition", disposition(filename))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// String describes the store
+func (s *LocalStore) String() string {
+	return s.dir
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/files/uploads/", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "upload-1.txt", strings.NewReader("hello")); err != nil {
+		t.Fatalf("Failed to put file: %v", err)
+	}
+	objects, err := store.List(ctx)
+	if err != nil || len(objects) != 1 || objects[0].Name != "upload-1.txt" || objects[0].Size != 5 {
+		t.Fatalf("Expected the stored file, got %+v (%v)", objects, err)
+	}
+	file, err := store.Open(ctx, "../"+objects[0].Name)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if string(content) != "hello" {
+		t.Errorf("Expected the file's content, got %q", content)
+	}
+	if err := store.Delete(ctx, "upload-1.txt"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if objects, _ := store.List(ctx); len(objects) != 0 {
+		t.Errorf("Expected no files left, got %+v", objects)
+	}
+}
+
+func TestLocalStore_SignedURL(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/files/uploads", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+	store.Put(ctx, "upload-1.html", strings.NewReader("<script>alert(1)</script>"))
+
+	link, err := store.SignedURL(ctx, "upload-1.html", "page.html", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to sign link: %v", err)
+	}
+	if !strings.HasPrefix(link, "/files/uploads/upload-1.html?") {
+		t.Fatalf("Expected a link under the store's route, got %s", link)
+	}
+
+	serve := func(link string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		store.ServeHTTP(rr, httptest.NewRequest("GET", link, nil))
+		return rr
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		rr := serve(link)
+		if rr.Code != http.StatusOK || rr.Body.String() != "<script>alert(1)</script>" {
+			t.Fatalf("Expected the file, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename=page.html` {
+			t.Errorf("Expected the file to download as page.html, got %q", got)
+		}
+		if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Error("Expected browsers not to sniff the file's type")
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		for param, value := range map[string]string{"filename": "other.html", "expires": "9999999999", "signature": strings.Repeat("0", 64)} {
+			tampered, _ := url.Parse(link)
+			query := tampered.Query()
+			query.Set(param, value)
+			tampered.RawQuery = query.Encode()
+			if rr := serve(tampered.String()); rr.Code != http.StatusForbidden {
+				t.Errorf("Expected status 403 with a changed %s, got %d", param, rr.Code)
+			}
+		}
+		other := strings.Replace(link, "upload-1.html", "upload-2.html", 1)
+		if rr := serve(other); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for another file, got %d", rr.Code)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		store.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+		defer func() { store.now = time.Now }()
+		if rr := serve(link); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for an expired link, got %d", rr.Code)
+		}
+	})
+}
+
+func TestLocalStore_NoLinks(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/files/uploads", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := store.SignedURL(context.Background(), "upload-1.txt", "a.txt", time.Minute); err != ErrNoLinks {
+		t.Errorf("Expected ErrNoLinks without a secret, got %v", err)
+	}
+	if store.Route() != "" {
+		t.Errorf("Expected no route without a secret, got %q", store.Route())
+	}
+}
+
+func TestNew(t *testing.T) {
+	store, err := New(Config{Secret: []byte("secret")}, t.TempDir(), "exports/", "/files/exports")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if local, ok := store.(*LocalStore); !ok || local.Route() != "/files/exports" {
+		t.Errorf("Expected a local store serving /files/exports, got %#v", store)
+	}
+	if _, err := New(Config{Backend: "ftp"}, t.TempDir(), "", ""); err == nil {
+		t.Error("Expected an unknown backend to be rejected")
+	}
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures an S3-compatible bucket, such as AWS S3 or MinIO
+type S3Config struct {
+	// Endpoint is the host of the S3 API, e.g. s3.amazonaws.com or a MinIO server
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to file names, e.g. "staticsend/"
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Insecure uses plain HTTP, for local test servers
+	Insecure bool
+}
+
+// S3Store keeps files in an S3-compatible bucket
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store creates a store for the configured bucket
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &S3Store{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+// Put uploads the file; the size is unknown, so it is sent in parts
+func (s *S3Store) Put(ctx context.Context, name string, r io.Reader) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+name, r, -1, minio.PutObjectOptions{
+		ContentType: contentType(name),
+	})
+	return err
+}
+
+// Open downloads an object
+func (s *S3Store) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.prefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy, so check the object exists before handing it back
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+// List returns the objects under the prefix
+func (s *S3Store) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		name := strings.TrimPrefix(object.Key, s.prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+		objects = append(objects, Object{Name: name, Size: object.Size, CreatedAt: object.LastModified})
+	}
+	return objects, nil
+}
+
+// Delete removes an object
+func (s *S3Store) Delete(ctx context.Context, name string) error {
+	return s.client.RemoveObject(ctx, s.bucket, s.prefix+name, minio.RemoveObjectOptions{})
+}
+
+// SignedURL returns a presigned link that downloads an object straight
+// from the bucket until ttl has passed
+func (s *S3Store) SignedURL(ctx context.Context, name, filename string, ttl time.Duration) (string, error) {
+	params := url.Values{}
+	if filename != "" {
+		params.Set("response-content-disposition", disposition(filename))
+		params.Set("response-content-type", contentType(filename))
+	}
+	link, err := s.client.PresignedGetObject(ctx, s.bucket, s.prefix+name, ttl, params)
+	if err != nil {
+		return "", err
+	}
+	return link.String(), nil
+}
+
+// String describes the store
+func (s *S3Store) String() string {
+	return "s3://" + s.bucket + "/" + s.prefix
+}
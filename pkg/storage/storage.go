@@ -0,0 +1,82 @@
+// Package storage keeps files such as backups, exports and uploads in a
+// local directory or an S3-compatible bucket, and makes signed links that
+// download them for a limited time.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"time"
+)
+
+// ErrNoLinks is returned by SignedURL when a store hasn't been given a route
+// to serve its downloads from
+var ErrNoLinks = errors.New("store can't make download links")
+
+// Object describes a stored file
+type Object struct {
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// Store keeps files
+type Store interface {
+	// Put stores a file read from r
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Open reads a stored file
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the stored files, in any order
+	List(ctx context.Context) ([]Object, error)
+	// Delete removes a stored file
+	Delete(ctx context.Context, name string) error
+	// SignedURL returns a link that downloads a file, saved as filename,
+	// until ttl has passed
+	SignedURL(ctx context.Context, name, filename string, ttl time.Duration) (string, error)
+	// String describes the store for logs
+	String() string
+}
+
+// Config picks where files are kept
+type Config struct {
+	// Backend is "s3" for a bucket, or "local" for directories on disk
+	Backend string
+	S3      S3Config
+	// Secret signs the download links of local stores
+	Secret []byte
+}
+
+// New returns the store for one kind of file: under prefix in the bucket,
+// or in dir on disk, serving downloads from route
+func New(cfg Config, dir, prefix, route string) (Store, error) {
+	switch cfg.Backend {
+	case "s3":
+		s3 := cfg.S3
+		s3.Prefix += prefix
+		return NewS3Store(s3)
+	case "", "local":
+		return NewLocalStore(dir, route, cfg.Secret)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (expected local or s3)", cfg.Backend)
+	}
+}
+
+// contentType guesses a file's type from its name
+func contentType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// disposition is the Content-Disposition that saves a download as filename
+func disposition(filename string) string {
+	if value := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return "attachment"
+}
@@ -0,0 +1,101 @@
+// Package subjectdata answers data subject requests, such as those made
+// under the GDPR, by gathering everything a visitor sent to forms into one
+// ZIP file they can be given.
+package subjectdata
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+// submission is a submission as written to the ZIP file
+type submission struct {
+	Form        string          `json:"form"`
+	SubmittedAt time.Time       `json:"submitted_at"`
+	IPAddress   string          `json:"ip_address,omitempty"`
+	UserAgent   string          `json:"user_agent,omitempty"`
+	Referrer    string          `json:"referrer,omitempty"`
+	Country     string          `json:"country,omitempty"`
+	Fields      json.RawMessage `json:"fields"`
+	Files       []file          `json:"files,omitempty"`
+}
+
+// file is an uploaded file, with where it is in the ZIP file, or "" if it
+// couldn't be read from its store
+type file struct {
+	Field    string `json:"field"`
+	Filename string `json:"filename"`
+	Path     string `json:"path,omitempty"`
+}
+
+// WriteZIP writes a ZIP file holding submissions.json, which lists the
+// submissions with their forms and sender details, and the files uploaded
+// with them under files/. files may be nil if uploads aren't kept.
+func WriteZIP(ctx context.Context, db *database.Database, files storage.Store, submissions []models.SubjectSubmission, w io.Writer) error {
+	archive := zip.NewWriter(w)
+	entries := make([]submission, len(submissions))
+	for i, s := range submissions {
+		entries[i] = submission{
+			Form:        s.FormName,
+			SubmittedAt: s.CreatedAt,
+			IPAddress:   s.IPAddress,
+			UserAgent:   s.UserAgent,
+			Referrer:    s.Referrer,
+			Country:     s.Country,
+			Fields:      s.SubmittedData,
+		}
+		uploads, err := models.GetSubmissionFilesContext(ctx, db.Connection, s.ID)
+		if err != nil {
+			return err
+		}
+		for _, upload := range uploads {
+			entry := file{Field: upload.Field, Filename: upload.Filename}
+			if files != nil {
+				// The base name keeps a filename like ../x inside files/
+				name := fmt.Sprintf("files/%d/%d-%s", s.ID, upload.ID, path.Base("/"+upload.Filename))
+				if err := copyFile(ctx, archive, files, upload.StorageName, name, upload.CreatedAt); err != nil {
+					log.Printf("Failed to add file %d to a data export: %v", upload.ID, err)
+				} else {
+					entry.Path = name
+				}
+			}
+			entries[i].Files = append(entries[i].Files, entry)
+		}
+	}
+
+	out, err := archive.CreateHeader(&zip.FileHeader{Name: "submissions.json", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// copyFile copies a file from store into the ZIP file
+func copyFile(ctx context.Context, archive *zip.Writer, store storage.Store, storageName, name string, modified time.Time) error {
+	in, err := store.Open(ctx, storageName)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,69 @@
+package subjectdata
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+func TestWriteZIP(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	store, err := storage.NewLocalStore(t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Careers", "example.com", "secret", "to@example.com", "subject-key")
+	sent, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.1", "Browser", json.RawMessage(`{"email":"jo@example.com","cv":"cv.pdf"}`))
+	store.Put(ctx, "upload-1.pdf", strings.NewReader("%PDF"))
+	models.CreateSubmissionFile(db.Connection, &models.SubmissionFile{SubmissionID: sent.ID, Field: "cv", Filename: "../cv.pdf", StorageName: "upload-1.pdf"})
+	models.CreateSubmissionFile(db.Connection, &models.SubmissionFile{SubmissionID: sent.ID, Field: "photo", Filename: "me.jpg", StorageName: "missing.jpg"})
+
+	submissions, _ := models.FindSubmissionsByEmail(db.Connection, 0, "jo@example.com")
+	var out bytes.Buffer
+	if err := WriteZIP(ctx, db, store, submissions, &out); err != nil {
+		t.Fatalf("WriteZIP failed: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("Invalid ZIP file: %v", err)
+	}
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		b, _ := io.ReadAll(r)
+		r.Close()
+		contents[f.Name] = string(b)
+	}
+
+	var entries []submission
+	if err := json.Unmarshal([]byte(contents["submissions.json"]), &entries); err != nil {
+		t.Fatalf("Invalid submissions.json: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Form != "Careers" || entries[0].IPAddress != "203.0.113.1" || len(entries[0].Files) != 2 {
+		t.Fatalf("Expected the submission with its files, got %+v", entries)
+	}
+	cv, photo := entries[0].Files[0], entries[0].Files[1]
+	if !strings.HasPrefix(cv.Path, "files/") || strings.Contains(cv.Path, "..") || contents[cv.Path] != "%PDF" {
+		t.Errorf("Expected the CV in the ZIP file, got %q", cv.Path)
+	}
+	if photo.Path != "" || photo.Filename != "me.jpg" {
+		t.Errorf("Expected the missing photo to be listed without a path, got %+v", photo)
+	}
+}
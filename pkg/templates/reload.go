@@ -0,0 +1,124 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"time"
+)
+
+// DefaultWatchInterval is how often templates are checked for changes in
+// development
+const DefaultWatchInterval = time.Second
+
+// diagnosticTemplate is shown in place of a page in development mode when
+// its templates can't be parsed or executed
+var diagnosticTemplate = template.Must(template.New("diagnostic").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Template error - staticSend</title>
+</head>
+<body style="font-family: sans-serif; margin: 2rem; color: #111827;">
+    <h1 style="color: #b91c1c;">Template error</h1>
+    <p>Rendering <code>{{.Name}}</code> failed. Fix the template and reload the page.</p>
+    <pre style="background: #f3f4f6; padding: 1rem; white-space: pre-wrap;">{{.Err}}</pre>
+</body>
+</html>
+`))
+
+// renderDiagnostic writes the diagnostic page for a template error, with a
+// 500 status when w is an HTTP response
+func renderDiagnostic(w io.Writer, name string, err error) error {
+	if rw, ok := w.(http.ResponseWriter); ok {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.WriteHeader(http.StatusInternalServerError)
+	}
+	return diagnosticTemplate.Execute(w, struct {
+		Name string
+		Err  string
+	}{name, err.Error()})
+}
+
+// StartWatching reloads the templates whenever a file changes, checking
+// every interval. It's meant for development with templates on disk.
+func (tm *TemplateManager) StartWatching(interval time.Duration) {
+	tm.stop = make(chan struct{})
+	tm.done = make(chan struct{})
+
+	go func() {
+		defer close(tm.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-tm.stop:
+				return
+			case <-ticker.C:
+				if _, err := tm.reloadIfChanged(); err != nil {
+					log.Printf("Templates not reloaded: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// StopWatching stops watching the templates for changes
+func (tm *TemplateManager) StopWatching() {
+	if tm.stop == nil {
+		return
+	}
+	close(tm.stop)
+	<-tm.done
+	tm.stop = nil
+}
+
+// reloadIfChanged reloads the templates if any file has changed since they
+// were last loaded, reporting whether it did
+func (tm *TemplateManager) reloadIfChanged() (bool, error) {
+	fingerprint, err := tm.fingerprintFiles()
+	if err != nil {
+		return false, err
+	}
+	if fingerprint == tm.fingerprint {
+		return false, nil
+	}
+	tm.fingerprint = fingerprint
+
+	if err := tm.loadTemplates(); err != nil {
+		return true, err
+	}
+	log.Printf("Reloaded templates")
+	return true, nil
+}
+
+// fingerprintFiles summarises the names, sizes and modification times of
+// the template files
+func (tm *TemplateManager) fingerprintFiles() (string, error) {
+	hash := sha256.New()
+	err := fs.WalkDir(tm.files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".html" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -1,16 +1,25 @@
 package templates
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
 	"os"
-	"path/filepath"
+	"path"
+	"sort"
 	"strings"
 	"sync"
 
+	"staticsend"
+	"staticsend/pkg/assets"
+	"staticsend/pkg/auth"
 	"staticsend/pkg/models"
+	"staticsend/pkg/sanitize"
+	"staticsend/pkg/version"
 )
 
 // TemplateData holds data for template rendering
@@ -26,26 +35,73 @@ type TemplateData struct {
 	AuthTurnstilePublicKey string      // Turnstile public key for auth pages
 }
 
+// Theme returns the signed-in user's theme, or models.ThemeSystem when no
+// one is signed in
+func (d TemplateData) Theme() string {
+	if d.User == nil || d.User.Theme == "" {
+		return models.ThemeSystem
+	}
+	return d.User.Theme
+}
+
 // DashboardStats holds statistics for the dashboard
 type DashboardStats struct {
 	FormCount       int
 	SubmissionCount int
+	// Quota and Usage are nil when no usage limits apply to the user
+	Quota *models.Quota
+	Usage *models.Usage
+}
+
+// Branding is how the pages present the instance: its name, description,
+// logo and the colour of buttons and links
+type Branding struct {
+	Title        string
+	Description  string
+	LogoURL      string // Empty without an uploaded logo
+	PrimaryColor string // Empty keeps the default colour
 }
 
+// DefaultBranding is used until the branding settings are loaded
+var DefaultBranding = Branding{Title: "staticSend"}
+
 // TemplateManager handles template parsing and rendering
 type TemplateManager struct {
+	files     fs.FS
 	templates map[string]*template.Template
+	loadErr   error // Error from the last load, whose templates weren't used
 	mu        sync.RWMutex
 	baseURL   string
+	basePath  string
+	branding  Branding
+	assetURL  func(name string) string
+	dev       bool
+
+	// Watching for changes in development
+	fingerprint string
+	stop        chan struct{}
+	done        chan struct{}
 }
 
-// NewTemplateManager creates a new template manager
+// NewTemplateManager creates a new template manager using the templates
+// embedded in the binary
 func NewTemplateManager() *TemplateManager {
+	return NewTemplateManagerFS(staticsend.TemplatesFS())
+}
+
+// NewTemplateManagerFS creates a new template manager that loads templates
+// from fsys, e.g. os.DirFS to use templates from a directory on disk.
+// Templates that fail to parse are reported by Err.
+func NewTemplateManagerFS(fsys fs.FS) *TemplateManager {
 	tm := &TemplateManager{
+		files:     fsys,
 		templates: make(map[string]*template.Template),
-		baseURL:   getBaseURL(),
+		baseURL:   defaultBaseURL,
+		branding:  DefaultBranding,
+		assetURL:  assets.DefaultURL,
 	}
 	tm.loadTemplates()
+	tm.fingerprint, _ = tm.fingerprintFiles()
 	return tm
 }
 
@@ -59,79 +115,247 @@ func (tm *TemplateManager) templateFuncMap() template.FuncMap {
 			}
 			return data, nil
 		},
-		"baseURL": func() string {
-			return tm.baseURL
+		"baseURL":  tm.BaseURL,
+		"basePath": tm.BasePath,
+		"branding": tm.Branding,
+		"version":  version.Get,
+		"asset": func(name string) string {
+			return tm.BasePath() + tm.assetURL(name)
+		},
+		"can": func(user *models.User, permission string) bool {
+			return auth.HasPermission(user, auth.Permission(permission))
 		},
+		"themes": func() []string {
+			return models.Themes
+		},
+		"formatBytes": formatBytes,
+		"join":        strings.Join,
+		"clean":       clean,
+		"breaks":      breaks,
+	}
+}
+
+// clean prepares a submitted value to be shown as text, which the
+// template then escapes. Values that aren't text, such as lists, are shown
+// as fmt prints them.
+func clean(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return sanitize.Text(v)
+	default:
+		return sanitize.Text(fmt.Sprint(v))
+	}
+}
+
+// breaks escapes a submitted value, keeping its line breaks as <br>
+func breaks(value string) template.HTML {
+	return template.HTML(sanitize.HTMLWithBreaks(value))
+}
+
+// formatBytes formats a byte count for display, e.g. 1.5 MB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
-// loadTemplates loads all templates from the templates directory
-func (tm *TemplateManager) loadTemplates() {
+// BaseURL returns the URL the application is served from, including the
+// base path, without a trailing slash
+func (tm *TemplateManager) BaseURL() string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	if tm.basePath != "" && !strings.HasSuffix(tm.baseURL, tm.basePath) {
+		return tm.baseURL + tm.basePath
+	}
+	return tm.baseURL
+}
+
+// SetBaseURL changes the URL the application is served from, e.g. to the
+// one saved in the base_url setting
+func (tm *TemplateManager) SetBaseURL(url string) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	tm.baseURL = strings.TrimSuffix(url, "/")
+}
 
-	// Get current working directory
-	cwd, err := os.Getwd()
+// BasePath returns the path prefix the application is served under, or ""
+// when it's served from the root. Templates start links with it.
+func (tm *TemplateManager) BasePath() string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.basePath
+}
+
+// SetBasePath changes the path prefix the application is served under
+func (tm *TemplateManager) SetBasePath(prefix string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.basePath = strings.TrimSuffix(prefix, "/")
+}
+
+// Branding returns the branding the pages are rendered with
+func (tm *TemplateManager) Branding() Branding {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.branding
+}
+
+// SetBranding changes the branding the pages are rendered with. An empty
+// title keeps the default.
+func (tm *TemplateManager) SetBranding(branding Branding) {
+	if branding.Title == "" {
+		branding.Title = DefaultBranding.Title
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.branding = branding
+}
+
+// SetAssetURLFunc sets the function used by the asset template helper to
+// build static file URLs, typically one that appends a content hash
+func (tm *TemplateManager) SetAssetURLFunc(fn func(name string) string) {
+	tm.assetURL = fn
+}
+
+// loadTemplates loads all templates from the template file system. If any
+// fail to parse, the templates loaded before are kept and the error is
+// remembered for Err and Render.
+func (tm *TemplateManager) loadTemplates() error {
+	templates, err := tm.parseTemplates()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.loadErr = err
 	if err != nil {
-		log.Printf("Error getting working directory: %v", err)
-		return
+		log.Printf("Error loading templates: %v", err)
+		return err
 	}
+	tm.templates = templates
+	return nil
+}
+
+// parseTemplates parses every template in the template file system
+func (tm *TemplateManager) parseTemplates() (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
 
 	// Parse base template first with functions
-	basePath := filepath.Join(cwd, "templates", "base.html")
-	baseTmpl := template.Must(template.New("base.html").Funcs(tm.templateFuncMap()).ParseFiles(basePath))
+	baseTmpl, err := template.New("base.html").Funcs(tm.templateFuncMap()).ParseFS(tm.files, "base.html")
+	if err != nil {
+		return nil, err
+	}
 
 	// Walk through all template files
-	templatesDir := filepath.Join(cwd, "templates")
-	
-	err = filepath.Walk(templatesDir, func(path string, info os.FileInfo, err error) error {
+	err = fs.WalkDir(tm.files, ".", func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if d.IsDir() || path.Ext(name) != ".html" || name == "base.html" {
+			return nil
+		}
 
-		if !info.IsDir() && filepath.Ext(path) == ".html" && path != basePath {
-			// Use relative path from templates directory as key
-			relPath, _ := filepath.Rel(templatesDir, path)
-			
-			// Check if this is a partial (in partials directory)
-			if filepath.Dir(relPath) == "partials" {
-				// For partials, parse without base template but with functions
-				tmpl := template.Must(template.New(filepath.Base(path)).Funcs(tm.templateFuncMap()).ParseFiles(path))
-				tm.templates[relPath] = tmpl
-			} else {
-				// For full pages, use base template wrapper with functions
-				tmpl := template.Must(baseTmpl.Clone())
-				tmpl = template.Must(tmpl.Funcs(tm.templateFuncMap()).ParseFiles(path))
-				tm.templates[relPath] = tmpl
+		var tmpl *template.Template
+		if path.Dir(name) == "partials" {
+			// For partials, parse without base template but with functions
+			tmpl, err = template.New(path.Base(name)).Funcs(tm.templateFuncMap()).ParseFS(tm.files, name)
+		} else {
+			// For full pages, use base template wrapper with functions
+			tmpl, err = baseTmpl.Clone()
+			if err == nil {
+				tmpl, err = tmpl.Funcs(tm.templateFuncMap()).ParseFS(tm.files, name)
 			}
 		}
+		if err != nil {
+			return err
+		}
+		templates[name] = tmpl
 		return nil
 	})
-
 	if err != nil {
-		log.Printf("Error loading templates: %v", err)
+		return nil, err
+	}
+	return templates, nil
+}
+
+// Reload parses the templates again, keeping the current ones if any fail
+// to parse
+func (tm *TemplateManager) Reload() error {
+	return tm.loadTemplates()
+}
+
+// Names returns the names of the loaded templates, sorted
+func (tm *TemplateManager) Names() []string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	names := make([]string, 0, len(tm.templates))
+	for name := range tm.templates {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+// Err returns the error from the last time the templates were loaded, or
+// nil if they loaded successfully
+func (tm *TemplateManager) Err() error {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.loadErr
+}
+
+// SetDevMode turns development mode on or off. In development mode
+// template errors are rendered as a diagnostic page in place of the page.
+func (tm *TemplateManager) SetDevMode(dev bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.dev = dev
 }
 
 // Render renders a template with the given data
 func (tm *TemplateManager) Render(w io.Writer, name string, data TemplateData) error {
 	tm.mu.RLock()
 	tmpl, exists := tm.templates[name]
+	loadErr, dev := tm.loadErr, tm.dev
 	tm.mu.RUnlock()
 
-	if !exists {
+	if !exists && loadErr == nil {
 		// Try to reload templates if not found
-		tm.loadTemplates()
+		loadErr = tm.loadTemplates()
 		tm.mu.RLock()
 		tmpl, exists = tm.templates[name]
 		tm.mu.RUnlock()
+	}
 
-		if !exists {
-			return os.ErrNotExist
+	if dev && loadErr != nil {
+		return renderDiagnostic(w, name, loadErr)
+	}
+	if !exists {
+		if loadErr != nil {
+			return fmt.Errorf("template %s: %w", name, loadErr)
 		}
+		return os.ErrNotExist
 	}
 
-	return tmpl.Execute(w, data)
+	if !dev {
+		return tmpl.Execute(w, data)
+	}
+
+	// Buffer the page so a failure part way through can be replaced by
+	// the diagnostic page
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return renderDiagnostic(w, name, err)
+	}
+	_, err := buf.WriteTo(w)
+	return err
 }
 
 // DefaultTemplateData creates default template data with common values
@@ -146,13 +370,6 @@ func DefaultTemplateData() TemplateData {
 	}
 }
 
-// getBaseURL determines the base URL for the application
-func getBaseURL() string {
-	// Try to get from environment variable
-	if envURL := os.Getenv("STATICSEND_BASE_URL"); envURL != "" {
-		return strings.TrimSuffix(envURL, "/")
-	}
-	
-	// For development, use localhost with default port
-	return "http://localhost:8080"
-}
\ No newline at end of file
+// defaultBaseURL is the base URL until the configuration or the setup
+// wizard gives one
+const defaultBaseURL = "http://localhost:8080"
\ No newline at end of file
@@ -1,7 +1,16 @@
 package templates
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+
+	"staticsend/pkg/models"
 )
 
 func TestTemplateData_Fields(t *testing.T) {
@@ -72,3 +81,180 @@ func TestDashboardStats(t *testing.T) {
 		t.Errorf("Expected SubmissionCount 10, got %d", stats.SubmissionCount)
 	}
 }
+
+func TestNewTemplateManager_UsesEmbeddedTemplates(t *testing.T) {
+	// The package directory has no templates/ folder, so these can only
+	// come from the files embedded in the binary
+	tm := NewTemplateManager()
+
+	var buf bytes.Buffer
+	if err := tm.Render(&buf, "auth/login.html", DefaultTemplateData()); err != nil {
+		t.Fatalf("Failed to render embedded template: %v", err)
+	}
+	if !strings.Contains(buf.String(), "<html") {
+		t.Error("Expected the page to be wrapped in the base template")
+	}
+}
+
+func TestNewTemplateManagerFS_Override(t *testing.T) {
+	files := fstest.MapFS{
+		"base.html":          {Data: []byte(`<html>{{template "content" .}}</html>`)},
+		"custom.html":        {Data: []byte(`{{define "content"}}Custom {{.Title}}{{end}}`)},
+		"partials/note.html": {Data: []byte(`Note {{.Title}}`)},
+	}
+	tm := NewTemplateManagerFS(files)
+
+	var page, partial bytes.Buffer
+	if err := tm.Render(&page, "custom.html", TemplateData{Title: "page"}); err != nil {
+		t.Fatalf("Failed to render page: %v", err)
+	}
+	if page.String() != "<html>Custom page</html>" {
+		t.Errorf("Unexpected page output %q", page.String())
+	}
+
+	if err := tm.Render(&partial, "partials/note.html", TemplateData{Title: "partial"}); err != nil {
+		t.Fatalf("Failed to render partial: %v", err)
+	}
+	if partial.String() != "Note partial" {
+		t.Errorf("Unexpected partial output %q", partial.String())
+	}
+}
+
+func TestTemplateManager_BasePath(t *testing.T) {
+	files := fstest.MapFS{
+		"base.html":         {Data: []byte(`{{template "content" .}}`)},
+		"partials/nav.html": {Data: []byte(`<a href="{{basePath}}/dashboard">{{baseURL}}</a> <script src="{{asset "js/app.js"}}"></script>`)},
+	}
+	tm := NewTemplateManagerFS(files)
+	tm.SetBasePath("/forms/")
+
+	var out bytes.Buffer
+	if err := tm.Render(&out, "partials/nav.html", TemplateData{}); err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	expected := `<a href="/forms/dashboard">http://localhost:8080/forms</a> <script src="/forms/static/js/app.js"></script>`
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	// A base URL that already includes the path isn't given it twice
+	tm.SetBaseURL("https://example.com/forms/")
+	if tm.BaseURL() != "https://example.com/forms" {
+		t.Errorf("Expected the base path once, got %q", tm.BaseURL())
+	}
+}
+
+func TestTemplateData_Theme(t *testing.T) {
+	tm := NewTemplateManager()
+	tests := []struct {
+		name string
+		user *models.User
+		want string
+	}{
+		{"signed out", nil, `<html lang="en" class="" data-theme="system">`},
+		{"system", &models.User{Email: "a@example.com", Theme: models.ThemeSystem}, `<html lang="en" class="" data-theme="system">`},
+		{"dark", &models.User{Email: "a@example.com", Theme: models.ThemeDark}, `<html lang="en" class="dark" data-theme="dark">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := DefaultTemplateData()
+			data.User = tt.user
+
+			var buf bytes.Buffer
+			if err := tm.Render(&buf, "auth/login.html", data); err != nil {
+				t.Fatalf("Failed to render page: %v", err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("Expected %s in the page", tt.want)
+			}
+			// Only the system theme needs the browser's preference
+			if got := strings.Contains(buf.String(), "prefers-color-scheme"); got != (data.Theme() == models.ThemeSystem) {
+				t.Errorf("Unexpected prefers-color-scheme script for theme %q", data.Theme())
+			}
+		})
+	}
+}
+
+func TestNewTemplateManagerFS_ParseError(t *testing.T) {
+	files := fstest.MapFS{
+		"base.html":   {Data: []byte(`<html>{{template "content" .}}</html>`)},
+		"broken.html": {Data: []byte(`{{define "content"}}{{if}}{{end}}`)},
+	}
+	tm := NewTemplateManagerFS(files)
+	if tm.Err() == nil {
+		t.Fatal("Expected the parse error to be reported")
+	}
+
+	var buf bytes.Buffer
+	if err := tm.Render(&buf, "broken.html", DefaultTemplateData()); err == nil {
+		t.Error("Expected rendering to fail")
+	}
+
+	// Development mode shows the error instead
+	tm.SetDevMode(true)
+	rr := httptest.NewRecorder()
+	if err := tm.Render(rr, "broken.html", DefaultTemplateData()); err != nil {
+		t.Fatalf("Failed to render diagnostic page: %v", err)
+	}
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "Template error") || !strings.Contains(rr.Body.String(), "broken.html") {
+		t.Errorf("Expected a diagnostic page, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestTemplateManager_ExecuteErrorInDevMode(t *testing.T) {
+	files := fstest.MapFS{
+		"base.html": {Data: []byte(`<html>{{template "content" .}}</html>`)},
+		"page.html": {Data: []byte(`{{define "content"}}{{.Missing}}{{end}}`)},
+	}
+	tm := NewTemplateManagerFS(files)
+	tm.SetDevMode(true)
+
+	rr := httptest.NewRecorder()
+	if err := tm.Render(rr, "page.html", DefaultTemplateData()); err != nil {
+		t.Fatalf("Failed to render diagnostic page: %v", err)
+	}
+	if strings.Contains(rr.Body.String(), "<html><") || !strings.Contains(rr.Body.String(), "Missing") {
+		t.Errorf("Expected only the diagnostic page, got %s", rr.Body.String())
+	}
+}
+
+func TestTemplateManager_ReloadIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write template: %v", err)
+		}
+	}
+	write("base.html", `<html>{{template "content" .}}</html>`)
+	write("page.html", `{{define "content"}}First{{end}}`)
+	tm := NewTemplateManagerFS(os.DirFS(dir))
+
+	render := func() string {
+		var buf bytes.Buffer
+		if err := tm.Render(&buf, "page.html", DefaultTemplateData()); err != nil {
+			t.Fatalf("Failed to render page: %v", err)
+		}
+		return buf.String()
+	}
+
+	if reloaded, err := tm.reloadIfChanged(); reloaded || err != nil {
+		t.Errorf("Expected no reload before a change, got %v, %v", reloaded, err)
+	}
+
+	write("page.html", `{{define "content"}}Second version{{end}}`)
+	if reloaded, err := tm.reloadIfChanged(); !reloaded || err != nil {
+		t.Fatalf("Expected a reload after a change, got %v, %v", reloaded, err)
+	}
+	if got := render(); got != "<html>Second version</html>" {
+		t.Errorf("Expected the new template, got %q", got)
+	}
+
+	// A broken edit keeps the last good templates
+	write("page.html", `{{define "content"}}{{if}}{{end}}`)
+	if _, err := tm.reloadIfChanged(); err == nil {
+		t.Fatal("Expected the parse error to be returned")
+	}
+	if got := render(); got != "<html>Second version</html>" {
+		t.Errorf("Expected the last good template, got %q", got)
+	}
+}
@@ -0,0 +1,113 @@
+// Package transfer moves forms between staticSend instances, such as from a
+// test instance to production or when merging installs. A form is exported
+// as one JSON file holding its settings, tags, IP rules, routing rules,
+// submissions and the records of their emails, and imported as a new form.
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/utils"
+)
+
+// Version is the version of the export format written
+const Version = 1
+
+// File is the contents of an export
+type File struct {
+	// Version is the export format's version, for imports to refuse files
+	// written by a newer staticSend
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	models.FormBundle
+}
+
+// Export writes everything about a form as JSON
+func Export(ctx context.Context, db *database.Database, formID int64, w io.Writer) error {
+	bundle, err := models.GetFormBundleContext(ctx, db.Connection, formID)
+	if err != nil {
+		return err
+	}
+	if bundle == nil {
+		return fmt.Errorf("form %d not found", formID)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(File{Version: Version, ExportedAt: time.Now().UTC(), FormBundle: *bundle})
+}
+
+// Read reads an export, checking it's one this version can import
+func Read(r io.Reader) (*File, error) {
+	var file File
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid export: %w", err)
+	}
+	if file.Version < 1 || file.Version > Version {
+		return nil, fmt.Errorf("unsupported export version %d", file.Version)
+	}
+	if file.Form.Name == "" {
+		return nil, errors.New("the export has no form")
+	}
+	if !models.ValidEmailAttachment(file.Form.EmailAttachment) {
+		return nil, fmt.Errorf("the form has an unknown email attachment %q", file.Form.EmailAttachment)
+	}
+	for i, route := range file.Routes {
+		if !models.ValidRouteOperator(route.Operator) {
+			return nil, fmt.Errorf("routing rule %d has an unknown operator %q", i+1, route.Operator)
+		}
+	}
+	for i, submission := range file.Submissions {
+		var data map[string]interface{}
+		if err := json.Unmarshal(submission.SubmittedData, &data); err != nil || data == nil {
+			return nil, fmt.Errorf("submission %d has invalid data", i+1)
+		}
+	}
+	return &file, nil
+}
+
+// Import creates a form for userID from an export. The form keeps its key,
+// so sites posting to it work unchanged, unless another form on this
+// instance has it. It's renamed like a copy if the user already has a form
+// with its name.
+func Import(ctx context.Context, db *database.Database, userID int64, file *File) (*models.Form, error) {
+	conn := db.Connection
+	formKey := file.Form.FormKey
+	existing, err := models.GetFormByKeyContext(ctx, conn, formKey)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil || formKey == "" {
+		if formKey, err = utils.GenerateFormKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	name := file.Form.Name
+	exists, err := models.FormExistsContext(ctx, conn, userID, name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if name, err = models.CopyNameContext(ctx, conn, userID, name); err != nil {
+			return nil, err
+		}
+	}
+
+	form, err := models.CreateFormFromBundleContext(ctx, conn, userID, name, formKey, &file.FormBundle)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Tags) > 0 {
+		if err := models.SetFormTagsContext(ctx, conn, userID, form.ID, file.Tags); err != nil {
+			return nil, err
+		}
+	}
+	return form, nil
+}
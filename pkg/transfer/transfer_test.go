@@ -0,0 +1,114 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func openDB(t *testing.T) *database.Database {
+	t.Helper()
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := openDB(t)
+	user, _ := models.CreateUser(source.Connection, "test@example.com", "hash")
+	form, err := models.CreateForm(source.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "transfer-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	models.SetFormTags(source.Connection, user.ID, form.ID, []string{"website"})
+	models.SetFormSpamThresholds(source.Connection, form.ID, 60, 90)
+	models.CreateIPRule(source.Connection, &form.ID, "192.0.2.0/24", "deny", "scrapers")
+	models.CreateFormRoute(source.Connection, form.ID, "department", models.RouteEquals, "sales", "sales@example.com")
+
+	sent, _ := models.CreateSubmission(source.Connection, form.ID, "203.0.113.1", "Browser", json.RawMessage(`{"message":"hello"}`))
+	models.UpdateSubmissionStatus(source.Connection, sent.ID, "processed")
+	models.SetSubmissionCreatedAt(source.Connection, sent.ID, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	models.CreateSubmissionEmail(source.Connection, sent.ID, "failed", "mailbox full")
+	spam, _ := models.CreateSpamSubmission(source.Connection, form.ID, "198.51.100.7", "Bot", "", json.RawMessage(`{"message":"buy now"}`), models.SpamReasonHoneypot)
+	models.SetSubmissionSpamSignals(source.Connection, spam.ID, 85, json.RawMessage(`{"blocklist_term":"buy"}`))
+
+	var export bytes.Buffer
+	if err := Export(ctx, source, form.ID, &export); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	file, err := Read(&export)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	target := openDB(t)
+	owner, _ := models.CreateUser(target.Connection, "owner@example.com", "hash")
+	imported, err := Import(ctx, target, owner.ID, file)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported.FormKey != "transfer-key" || imported.Name != "Contact" || imported.UserID != owner.ID {
+		t.Errorf("Expected the form to keep its key and name, got %+v", imported)
+	}
+	if imported.SpamFlagThreshold != 60 || imported.SpamRejectThreshold != 90 || imported.TurnstileSecret != "secret" {
+		t.Errorf("Expected the form's settings, got %+v", imported)
+	}
+
+	bundle, err := models.GetFormBundle(target.Connection, imported.ID)
+	if err != nil {
+		t.Fatalf("Failed to read the imported form: %v", err)
+	}
+	if len(bundle.Tags) != 1 || bundle.Tags[0] != "website" || len(bundle.IPRules) != 1 || bundle.IPRules[0].Note != "scrapers" {
+		t.Errorf("Expected the tags and IP rules, got %v and %+v", bundle.Tags, bundle.IPRules)
+	}
+	if len(bundle.Routes) != 1 || bundle.Routes[0].Recipient != "sales@example.com" {
+		t.Errorf("Expected the routing rules, got %+v", bundle.Routes)
+	}
+	if len(bundle.Submissions) != 2 {
+		t.Fatalf("Expected 2 submissions, got %d", len(bundle.Submissions))
+	}
+	first, second := bundle.Submissions[0], bundle.Submissions[1]
+	if !first.CreatedAt.Equal(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)) || first.Status != "processed" || first.ProcessedAt == nil {
+		t.Errorf("Expected the processed submission with its time, got %+v", first.Submission)
+	}
+	if len(first.Emails) != 1 || first.Emails[0].Status != "failed" || first.Emails[0].ErrorMessage != "mailbox full" {
+		t.Errorf("Expected the email record, got %+v", first.Emails)
+	}
+	if second.SpamAt == nil || second.SpamReason != models.SpamReasonHoneypot || second.SpamScore == nil || *second.SpamScore != 85 {
+		t.Errorf("Expected the spam submission with its score, got %+v", second.Submission)
+	}
+
+	// A second import can't reuse the key, and is named like a copy
+	again, err := Import(ctx, target, owner.ID, file)
+	if err != nil {
+		t.Fatalf("Second import failed: %v", err)
+	}
+	if again.FormKey == "transfer-key" || again.Name != "Contact copy" {
+		t.Errorf("Expected a new key and name, got %q and %q", again.FormKey, again.Name)
+	}
+}
+
+func TestRead(t *testing.T) {
+	for name, export := range map[string]string{
+		"not JSON":     "form,submissions",
+		"newer":        `{"version": 2, "form": {"name": "Contact"}}`,
+		"no version":   `{"form": {"name": "Contact"}}`,
+		"no form":      `{"version": 1}`,
+		"invalid data": `{"version": 1, "form": {"name": "Contact"}, "submissions": [{"submitted_data": "{"}]}`,
+	} {
+		if _, err := Read(strings.NewReader(export)); err == nil {
+			t.Errorf("Expected an export that's %s to be refused", name)
+		}
+	}
+}
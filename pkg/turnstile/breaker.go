@@ -0,0 +1,72 @@
+package turnstile
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is how many verifications in a row must fail
+	// to reach Cloudflare before the breaker opens
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is how long the breaker stays open before
+	// verification is tried again
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrUnavailable is returned without a request to Cloudflare while the
+// breaker is open
+var ErrUnavailable = errors.New("turnstile verification is unavailable")
+
+// Breaker stops sending verifications to Cloudflare while it can't be
+// reached, so each submission fails at once instead of waiting for a
+// timeout. Once the cooldown passes verification is tried again, and one
+// more failure opens the breaker for another cooldown.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewBreaker creates a breaker that opens for cooldown after threshold
+// failures in a row
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Open reports whether verifications are being refused
+func (b *Breaker) Open() bool {
+	return !b.allow(time.Now())
+}
+
+// allow reports whether a verification can be sent
+func (b *Breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+// record counts a verification's outcome. Cancelled requests don't count,
+// as the client went away rather than Cloudflare.
+func (b *Breaker) record(err error, now time.Time) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
@@ -0,0 +1,106 @@
+package turnstile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long verified tokens are remembered. Cloudflare's
+	// tokens expire after five minutes, so one seen again within that time is
+	// a replay, and after it Cloudflare rejects the token anyway.
+	DefaultCacheTTL = 5 * time.Minute
+
+	// ErrorTimeoutOrDuplicate is the error code for a token that has expired
+	// or was already verified
+	ErrorTimeoutOrDuplicate = "timeout-or-duplicate"
+
+	// DummyToken is the token widgets give with Cloudflare's testing keys. It
+	// is the same every time, so it is never treated as a replay.
+	DummyToken = "XXXX.DUMMY.TOKEN.XXXX"
+)
+
+// Cache remembers the tokens verified recently. Tokens are single use, so a
+// token seen again is answered without asking Cloudflare: with the same
+// failure if it failed, or as a duplicate if it passed or is still being
+// verified. The cache is per process; Cloudflare still catches a replay sent
+// to another instance.
+type Cache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	nextPrune time.Time
+}
+
+type cacheEntry struct {
+	// response is nil while the token is being verified
+	response *VerificationResponse
+	expires  time.Time
+}
+
+// NewCache creates a cache that remembers tokens for ttl
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+// Len returns the number of tokens remembered
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// claim marks the token as being verified. If it was seen recently, claim
+// returns the response to give instead and false.
+func (c *Cache) claim(key string, now time.Time) (*VerificationResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.nextPrune) {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextPrune = now.Add(c.ttl)
+	}
+
+	if entry, ok := c.entries[key]; ok && !now.After(entry.expires) {
+		if entry.response != nil && !entry.response.Success {
+			failure := *entry.response
+			return &failure, false
+		}
+		return &VerificationResponse{ErrorCodes: []string{ErrorTimeoutOrDuplicate}}, false
+	}
+
+	c.entries[key] = cacheEntry{expires: now.Add(c.ttl)}
+	return nil, true
+}
+
+// store records the token's result
+func (c *Cache) store(key string, response *VerificationResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		entry.response = response
+		c.entries[key] = entry
+	}
+}
+
+// release forgets a token whose verification didn't complete, e.g. when
+// Cloudflare couldn't be reached, so it can be tried again
+func (c *Cache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// cacheKey identifies a token verified against an endpoint with a secret,
+// hashed so the cache doesn't hold tokens or secrets
+func cacheKey(verifyURL, secretKey, token string) string {
+	sum := sha256.Sum256([]byte(verifyURL + "\x00" + secretKey + "\x00" + token))
+	return hex.EncodeToString(sum[:])
+}
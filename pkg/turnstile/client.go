@@ -0,0 +1,64 @@
+package turnstile
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Client verifies tokens for any secret key over one HTTP client, so
+// connections to Cloudflare are reused across submissions and sign ins. Its
+// validators share a cache of recent tokens and a circuit breaker.
+type Client struct {
+	verifyURL  string
+	httpClient *http.Client
+	cache      *Cache
+	breaker    *Breaker
+}
+
+// DefaultClient is used by NewValidator and by handlers not given a client
+var DefaultClient = NewClient("", DefaultTimeout)
+
+// NewClient creates a client sending verifications to verifyURL, or
+// DefaultVerifyURL if it's empty, which time out after timeout
+func NewClient(verifyURL string, timeout time.Duration) *Client {
+	if verifyURL == "" {
+		verifyURL = DefaultVerifyURL
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{
+		verifyURL:  verifyURL,
+		httpClient: &http.Client{Timeout: timeout},
+		cache:      NewCache(DefaultCacheTTL),
+		breaker:    NewBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+	}
+}
+
+// Validator returns a validator for secretKey using the client's
+// connections, cache and breaker
+func (c *Client) Validator(secretKey string) *Validator {
+	return &Validator{
+		secretKey:  secretKey,
+		verifyURL:  c.verifyURL,
+		httpClient: c.httpClient,
+		cache:      c.cache,
+		breaker:    c.breaker,
+	}
+}
+
+// Verify validates a token against secretKey, like Validator.Verify
+func (c *Client) Verify(ctx context.Context, secretKey, token, remoteIP string) (*VerificationResponse, error) {
+	return c.Validator(secretKey).Verify(ctx, token, remoteIP)
+}
+
+// Timeout returns how long a verification can take
+func (c *Client) Timeout() time.Duration {
+	return c.httpClient.Timeout
+}
+
+// Breaker returns the client's circuit breaker
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
@@ -34,17 +34,14 @@ type Validator struct {
 	secretKey  string
 	verifyURL  string
 	httpClient *http.Client
+	cache      *Cache
+	breaker    *Breaker
 }
 
-// NewValidator creates a new Turnstile validator
+// NewValidator creates a Turnstile validator using DefaultClient's
+// connections, cache and breaker
 func NewValidator(secretKey string) *Validator {
-	return &Validator{
-		secretKey: secretKey,
-		verifyURL: DefaultVerifyURL,
-		httpClient: &http.Client{
-			Timeout: DefaultTimeout,
-		},
-	}
+	return DefaultClient.Validator(secretKey)
 }
 
 // WithVerifyURL sets a custom verification URL (for testing)
@@ -59,7 +56,23 @@ func (v *Validator) WithHTTPClient(client *http.Client) *Validator {
 	return v
 }
 
-// Verify validates a Turnstile token with optional remote IP
+// WithCache sets the cache of recently verified tokens, shared by the
+// client's validators by default. A nil cache sends every token to Cloudflare.
+func (v *Validator) WithCache(cache *Cache) *Validator {
+	v.cache = cache
+	return v
+}
+
+// WithBreaker sets the circuit breaker, shared by the client's validators
+// by default. A nil breaker always sends verifications.
+func (v *Validator) WithBreaker(breaker *Breaker) *Validator {
+	v.breaker = breaker
+	return v
+}
+
+// Verify validates a Turnstile token with optional remote IP. A token seen
+// recently is answered from the cache without a request to Cloudflare, and
+// ErrUnavailable is returned while the breaker is open.
 func (v *Validator) Verify(ctx context.Context, token, remoteIP string) (*VerificationResponse, error) {
 	if token == "" {
 		return &VerificationResponse{
@@ -67,7 +80,39 @@ func (v *Validator) Verify(ctx context.Context, token, remoteIP string) (*Verifi
 			ErrorCodes: []string{"missing-input-response"},
 		}, nil
 	}
+	if v.cache == nil || token == DummyToken {
+		return v.guardedVerify(ctx, token, remoteIP)
+	}
 
+	key := cacheKey(v.verifyURL, v.secretKey, token)
+	if cached, ok := v.cache.claim(key, time.Now()); !ok {
+		return cached, nil
+	}
+	response, err := v.guardedVerify(ctx, token, remoteIP)
+	if err != nil {
+		v.cache.release(key)
+		return nil, err
+	}
+	v.cache.store(key, response)
+	return response, nil
+}
+
+// guardedVerify verifies the token unless the breaker is open, and records
+// whether Cloudflare could be reached
+func (v *Validator) guardedVerify(ctx context.Context, token, remoteIP string) (*VerificationResponse, error) {
+	if v.breaker == nil {
+		return v.verify(ctx, token, remoteIP)
+	}
+	if !v.breaker.allow(time.Now()) {
+		return nil, ErrUnavailable
+	}
+	response, err := v.verify(ctx, token, remoteIP)
+	v.breaker.record(err, time.Now())
+	return response, err
+}
+
+// verify asks Cloudflare whether the token is valid
+func (v *Validator) verify(ctx context.Context, token, remoteIP string) (*VerificationResponse, error) {
 	// Prepare form data
 	form := url.Values{}
 	form.Add("secret", v.secretKey)
@@ -110,6 +155,12 @@ func (vr *VerificationResponse) IsValid() bool {
 	return vr.Success
 }
 
+// IsDuplicate reports whether the token was rejected for having expired or
+// already been used, usually a replayed submission
+func (vr *VerificationResponse) IsDuplicate() bool {
+	return vr.HasError(ErrorTimeoutOrDuplicate)
+}
+
 // HasError checks if the verification response contains specific error codes
 func (vr *VerificationResponse) HasError(errorCode string) bool {
 	for _, code := range vr.ErrorCodes {
@@ -3,6 +3,7 @@ package turnstile
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -155,7 +156,7 @@ func TestValidator_Verify_EmptyToken(t *testing.T) {
 
 func TestValidator_Verify_NetworkError(t *testing.T) {
 	// Create validator with invalid URL to simulate network error
-	validator := NewValidator("test-secret").WithVerifyURL("https://invalid-domain-that-does-not-exist-12345.com")
+	validator := NewValidator("test-secret").WithVerifyURL("https://invalid-domain-that-does-not-exist-12345.com").WithBreaker(nil)
 
 	ctx := context.Background()
 	_, err := validator.Verify(ctx, "test-token", "")
@@ -173,7 +174,7 @@ func TestValidator_Verify_InvalidJSONResponse(t *testing.T) {
 	}))
 	defer server.Close()
 
-	validator := NewValidator("test-secret").WithVerifyURL(server.URL)
+	validator := NewValidator("test-secret").WithVerifyURL(server.URL).WithBreaker(nil)
 
 	ctx := context.Background()
 	_, err := validator.Verify(ctx, "test-token", "")
@@ -248,9 +249,8 @@ func TestValidator_Verify_Timeout(t *testing.T) {
 	defer server.Close()
 
 	// Create validator with very short timeout
-	validator := NewValidator("test-secret")
-	validator.httpClient.Timeout = 10 * time.Millisecond
-	validator = validator.WithVerifyURL(server.URL)
+	validator := NewValidator("test-secret").WithHTTPClient(&http.Client{Timeout: 10 * time.Millisecond})
+	validator = validator.WithVerifyURL(server.URL).WithBreaker(nil)
 
 	ctx := context.Background()
 	_, err := validator.Verify(ctx, "test-token", "")
@@ -286,3 +286,169 @@ func TestValidator_Verify_ContextCancellation(t *testing.T) {
 		t.Error("Expected context cancellation error")
 	}
 }
+
+func TestValidator_Verify_Cache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		response := VerificationResponse{Success: r.Form.Get("response") == "good-token"}
+		if !response.Success {
+			response.ErrorCodes = []string{"invalid-input-response"}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	cache := NewCache(time.Minute)
+	verify := func(token string) *VerificationResponse {
+		t.Helper()
+		response, err := NewValidator("test-secret").WithVerifyURL(server.URL).WithCache(cache).Verify(context.Background(), token, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return response
+	}
+
+	if response := verify("good-token"); !response.Success {
+		t.Fatal("Expected the first use of a token to pass")
+	}
+	if response := verify("good-token"); response.Success || !response.IsDuplicate() {
+		t.Errorf("Expected a replayed token to be a duplicate, got %+v", response)
+	}
+	if response := verify("bad-token"); response.Success || !response.HasError("invalid-input-response") {
+		t.Errorf("Expected an invalid token to fail, got %+v", response)
+	}
+	if response := verify("bad-token"); !response.HasError("invalid-input-response") {
+		t.Errorf("Expected the cached failure for a repeated invalid token, got %+v", response)
+	}
+	if requests != 2 {
+		t.Errorf("Expected repeated tokens to be answered from the cache, got %d requests", requests)
+	}
+
+	verify(DummyToken)
+	verify(DummyToken)
+	if requests != 4 {
+		t.Errorf("Expected the testing dummy token never to be cached, got %d requests", requests)
+	}
+}
+
+func TestValidator_Verify_CacheReleasesErrors(t *testing.T) {
+	cache := NewCache(time.Minute)
+	validator := NewValidator("test-secret").WithVerifyURL("http://127.0.0.1:1").WithCache(cache).WithBreaker(nil)
+	if _, err := validator.Verify(context.Background(), "token", ""); err == nil {
+		t.Fatal("Expected an unreachable endpoint to fail")
+	}
+	if cache.Len() != 0 {
+		t.Error("Expected a token that couldn't be verified to be retried")
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	cache := NewCache(time.Minute)
+	now := time.Now()
+	if _, ok := cache.claim("key", now); !ok {
+		t.Fatal("Expected a new token to be claimed")
+	}
+	cache.store("key", &VerificationResponse{Success: true})
+	if response, ok := cache.claim("key", now.Add(30*time.Second)); ok || !response.IsDuplicate() {
+		t.Errorf("Expected a duplicate within the TTL, got %+v", response)
+	}
+	if _, ok := cache.claim("other", now.Add(30*time.Second)); !ok {
+		t.Fatal("Expected another token to be claimed")
+	}
+	if _, ok := cache.claim("key", now.Add(2*time.Minute)); !ok {
+		t.Error("Expected an expired token to be forgotten")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Expected expired tokens to be pruned, got %d", cache.Len())
+	}
+}
+
+func TestValidator_Verify_Breaker(t *testing.T) {
+	up := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(VerificationResponse{Success: true})
+	}))
+	defer server.Close()
+
+	breaker := NewBreaker(2, time.Hour)
+	verify := func(token string) error {
+		_, err := NewValidator("test-secret").WithVerifyURL(server.URL).WithCache(nil).WithBreaker(breaker).Verify(context.Background(), token, "")
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := verify("token"); err == nil || errors.Is(err, ErrUnavailable) {
+			t.Fatalf("Expected the failure from Cloudflare, got %v", err)
+		}
+	}
+	if !breaker.Open() {
+		t.Fatal("Expected the breaker to open after two failures")
+	}
+	up = true
+	if err := verify("token"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable while the breaker is open, got %v", err)
+	}
+
+	// After the cooldown a success closes it, and a failure reopens it
+	breaker.openUntil = time.Time{}
+	if err := verify("token"); err != nil {
+		t.Errorf("Expected verification to be tried after the cooldown, got %v", err)
+	}
+	up = false
+	verify("token")
+	if breaker.Open() {
+		t.Error("Expected a success to reset the failure count")
+	}
+}
+
+func TestBreaker_IgnoresCancellation(t *testing.T) {
+	breaker := NewBreaker(1, time.Hour)
+	breaker.record(context.Canceled, time.Now())
+	if breaker.Open() {
+		t.Error("Expected a cancelled request not to open the breaker")
+	}
+	breaker.record(context.DeadlineExceeded, time.Now())
+	if !breaker.Open() {
+		t.Error("Expected a timeout to open the breaker")
+	}
+}
+
+func TestClient(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		json.NewEncoder(w).Encode(VerificationResponse{Success: r.Form.Get("secret") == "form-secret"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, time.Second)
+	if client.Timeout() != time.Second {
+		t.Errorf("Expected a 1s timeout, got %v", client.Timeout())
+	}
+	first, second := client.Validator("form-secret"), client.Validator("other-secret")
+	if first.httpClient != second.httpClient || first.cache != second.cache || first.breaker != second.breaker {
+		t.Error("Expected validators to share the client's HTTP client, cache and breaker")
+	}
+
+	if response, err := client.Verify(context.Background(), "form-secret", "token", ""); err != nil || !response.Success {
+		t.Errorf("Expected the form's secret to pass, got %+v (%v)", response, err)
+	}
+	// The same token checked with another secret is a separate verification
+	if response, err := client.Verify(context.Background(), "other-secret", "token", ""); err != nil || response.Success || response.IsDuplicate() {
+		t.Errorf("Expected another secret to fail at Cloudflare, got %+v (%v)", response, err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+
+	if defaults := NewClient("", 0); defaults.verifyURL != DefaultVerifyURL || defaults.Timeout() != DefaultTimeout {
+		t.Errorf("Expected the defaults, got %s and %v", defaults.verifyURL, defaults.Timeout())
+	}
+}
@@ -0,0 +1,54 @@
+// Package unsubscribe signs the links in notification emails that let a
+// recipient mute a form's notifications without signing in, and checks them
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Links makes and checks unsubscribe links. Links don't expire, so the
+// one in an old email still works.
+type Links struct {
+	secret  []byte
+	baseURL func() string
+}
+
+// NewLinks creates links signed with secret, starting with baseURL
+func NewLinks(secret []byte, baseURL func() string) *Links {
+	return &Links{secret: secret, baseURL: baseURL}
+}
+
+// URL returns the link address uses to mute a form's notifications
+func (l *Links) URL(formID int64, address string) string {
+	address = normalize(address)
+	query := url.Values{
+		"form":      {strconv.FormatInt(formID, 10)},
+		"address":   {address},
+		"signature": {l.sign(formID, address)},
+	}
+	return fmt.Sprintf("%s/unsubscribe?%s", l.baseURL(), query.Encode())
+}
+
+// Verify reports whether signature is a link's for the form and address
+func (l *Links) Verify(formID int64, address, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(l.sign(formID, normalize(address))))
+}
+
+// sign returns the signature of a form and address's link
+func (l *Links) sign(formID int64, address string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(mac, "unsubscribe:%d:%s", formID, address)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// normalize returns the form addresses are signed in, which is how they're
+// stored when muted
+func normalize(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
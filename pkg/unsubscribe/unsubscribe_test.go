@@ -0,0 +1,33 @@
+package unsubscribe
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	links := NewLinks([]byte("secret"), func() string { return "https://forms.example.com" })
+
+	link, err := url.Parse(links.URL(7, "Team@Example.com"))
+	if err != nil {
+		t.Fatalf("Failed to parse link: %v", err)
+	}
+	if link.Host != "forms.example.com" || link.Path != "/unsubscribe" {
+		t.Errorf("Expected a link to the unsubscribe page, got %s", link)
+	}
+	query := link.Query()
+	if query.Get("form") != "7" || query.Get("address") != "team@example.com" {
+		t.Errorf("Expected the form and address in the link, got %v", query)
+	}
+
+	signature := query.Get("signature")
+	if !links.Verify(7, "team@example.com", signature) || !links.Verify(7, "TEAM@example.com", signature) {
+		t.Error("Expected the link's signature to verify")
+	}
+	if links.Verify(8, "team@example.com", signature) || links.Verify(7, "other@example.com", signature) {
+		t.Error("Expected the signature to only verify for its form and address")
+	}
+	if NewLinks([]byte("other"), nil).Verify(7, "team@example.com", signature) {
+		t.Error("Expected another secret's signature not to verify")
+	}
+}
@@ -0,0 +1,294 @@
+// Package uploads keeps files uploaded with form submissions in a
+// storage.Store under random names, so they're only ever downloaded
+// through signed links, and sweeps away files no submission refers to.
+// Links for notification emails are signed by the app and last longer
+// than the store's own, which they redirect to.
+package uploads
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+const (
+	// namePrefix identifies uploaded files so other files in the same
+	// directory or bucket are never swept
+	namePrefix = "upload-"
+	// orphanAge is how long a file without a record is kept before it's
+	// swept, long enough for the submission it came with to be saved
+	orphanAge = time.Hour
+	// maxFilenameLength caps the filename recorded for a file
+	maxFilenameLength = 255
+)
+
+// extension matches the file extensions kept on stored names, which let
+// buckets guess a file's type
+var extension = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+// File is an uploaded file that has been stored but not yet recorded
+type File struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Size        int64
+	// Name is the file's name in the store
+	Name string
+}
+
+// Uploader stores uploaded files and sweeps away the ones no submission
+// refers to
+type Uploader struct {
+	db     *database.Database
+	store  storage.Store
+	secret []byte
+	now    func() time.Time
+
+	// mu ensures only one sweep happens at a time
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewUploader creates an uploader keeping files in store, signing download
+// links with secret
+func NewUploader(db *database.Database, store storage.Store, secret []byte) *Uploader {
+	return &Uploader{
+		db:     db,
+		store:  store,
+		secret: secret,
+		now:    time.Now,
+	}
+}
+
+// Store returns where uploaded files are kept
+func (u *Uploader) Store() storage.Store {
+	return u.store
+}
+
+// Put stores every file in a multipart form, in field order. If one fails
+// the files already stored are deleted.
+func (u *Uploader) Put(ctx context.Context, form *multipart.Form) ([]File, error) {
+	if form == nil {
+		return nil, nil
+	}
+	fields := make([]string, 0, len(form.File))
+	for field := range form.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var files []File
+	for _, field := range fields {
+		for _, header := range form.File[field] {
+			// Browsers send an empty part for file inputs left empty
+			if header.Filename == "" && header.Size == 0 {
+				continue
+			}
+			file, err := u.put(ctx, field, header)
+			if err != nil {
+				u.discard(files)
+				return nil, err
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// put stores one uploaded file under a new random name
+func (u *Uploader) put(ctx context.Context, field string, header *multipart.FileHeader) (File, error) {
+	filename := filepath.Base(strings.ReplaceAll(header.Filename, `\`, "/"))
+	if filename == "." || filename == "/" {
+		filename = field
+	}
+	if len(filename) > maxFilenameLength {
+		filename = filename[len(filename)-maxFilenameLength:]
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return File{}, err
+	}
+	name := namePrefix + hex.EncodeToString(random)
+	if ext := strings.ToLower(path.Ext(filename)); extension.MatchString(ext) {
+		name += ext
+	}
+
+	body, err := header.Open()
+	if err != nil {
+		return File{}, fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer body.Close()
+	if err := u.store.Put(ctx, name, body); err != nil {
+		return File{}, fmt.Errorf("failed to store upload: %w", err)
+	}
+	return File{
+		Field:       field,
+		Filename:    filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Size:        header.Size,
+		Name:        name,
+	}, nil
+}
+
+// discard deletes stored files that won't be recorded. Files it can't
+// delete are swept later.
+func (u *Uploader) discard(files []File) {
+	for _, file := range files {
+		if err := u.store.Delete(context.Background(), file.Name); err != nil {
+			log.Printf("Failed to delete upload %s: %v", file.Name, err)
+		}
+	}
+}
+
+// Record saves the records of stored files as belonging to a submission
+func (u *Uploader) Record(ctx context.Context, submissionID int64, files []File) error {
+	for _, file := range files {
+		err := models.CreateSubmissionFileContext(ctx, u.db.Connection, &models.SubmissionFile{
+			SubmissionID: submissionID,
+			Field:        file.Field,
+			Filename:     file.Filename,
+			ContentType:  file.ContentType,
+			Size:         file.Size,
+			StorageName:  file.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record upload: %w", err)
+		}
+	}
+	return nil
+}
+
+// DownloadPath returns the signed path a submission's file is downloaded
+// from until expires
+func (u *Uploader) DownloadPath(file models.SubmissionFile, expires time.Time) string {
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {u.sign(file.SubmissionID, file.ID, expires.Unix())},
+	}
+	return fmt.Sprintf("/uploads/%d/%d?%s", file.SubmissionID, file.ID, query.Encode())
+}
+
+// Verify reports whether a download link's signature is valid for the
+// file and the link hasn't expired
+func (u *Uploader) Verify(submissionID, fileID int64, expires, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || u.now().Unix() >= expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(u.sign(submissionID, fileID, expiresAt)))
+}
+
+// sign returns the signature of a file's download link
+func (u *Uploader) sign(submissionID, fileID, expires int64) string {
+	mac := hmac.New(sha256.New, u.secret)
+	fmt.Fprintf(mac, "upload:%d:%d:%d", submissionID, fileID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EmailLinks returns links to a submission's files for its notification
+// email, which work for ttl. Paths are made absolute with baseURL.
+func (u *Uploader) EmailLinks(ctx context.Context, submissionID int64, ttl time.Duration, baseURL string) ([]email.FileLink, error) {
+	files, err := models.GetSubmissionFilesContext(ctx, u.db.Connection, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	expires := u.now().Add(ttl)
+	links := make([]email.FileLink, 0, len(files))
+	for _, file := range files {
+		links = append(links, email.FileLink{
+			Field:    file.Field,
+			Filename: file.Filename,
+			URL:      baseURL + u.DownloadPath(file, expires),
+		})
+	}
+	return links, nil
+}
+
+// Sweep deletes uploaded files that no submission refers to, left behind
+// by deleted submissions or failed saves, returning how many it deleted
+func (u *Uploader) Sweep(ctx context.Context) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	objects, err := u.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := u.now().Add(-orphanAge)
+	deleted := 0
+	for _, object := range objects {
+		if !strings.HasPrefix(object.Name, namePrefix) || object.CreatedAt.After(cutoff) {
+			continue
+		}
+		exists, err := models.SubmissionFileExistsContext(ctx, u.db.Connection, object.Name)
+		if err != nil {
+			return deleted, err
+		}
+		if exists {
+			continue
+		}
+		if err := u.store.Delete(ctx, object.Name); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Start sweeps unreferenced files every interval until Stop is called
+func (u *Uploader) Start(interval time.Duration) {
+	u.stop = make(chan struct{})
+	u.done = make(chan struct{})
+
+	go func() {
+		defer close(u.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-ticker.C:
+				deleted, err := u.Sweep(context.Background())
+				if err != nil {
+					log.Printf("Sweeping uploads failed: %v", err)
+				}
+				if deleted > 0 {
+					log.Printf("Deleted %d unreferenced uploads from %s", deleted, u.store)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops sweeping, waiting for a running sweep to finish
+func (u *Uploader) Stop() {
+	if u.stop == nil {
+		return
+	}
+	close(u.stop)
+	<-u.done
+	u.stop = nil
+}
@@ -0,0 +1,171 @@
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+// multipartForm builds a parsed form with a text field and the given files,
+// keyed by filename
+func multipartForm(t *testing.T, files map[string]string) *multipart.Form {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("name", "Ada")
+	for filename, content := range files {
+		part, err := writer.CreateFormFile("attachment", filename)
+		if err != nil {
+			t.Fatalf("Failed to create file part: %v", err)
+		}
+		part.Write([]byte(content))
+	}
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("Failed to read form: %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form
+}
+
+func TestUploader(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "staticsend.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	dir := filepath.Join(t.TempDir(), "uploads")
+	store, err := storage.NewLocalStore(dir, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	u := NewUploader(db, store, []byte("secret"))
+	ctx := context.Background()
+
+	user, _ := models.CreateUser(db.Connection, "uploads@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Jobs", "example.com", "secret", "jobs@example.com", "uploads_form_key")
+	submission, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{}`))
+
+	files, err := u.Put(ctx, multipartForm(t, map[string]string{`C:\Users\ada\CV.PDF`: "resume"}))
+	if err != nil {
+		t.Fatalf("Failed to store uploads: %v", err)
+	}
+	if len(files) != 1 || files[0].Field != "attachment" || files[0].Filename != "CV.PDF" || files[0].Size != 6 {
+		t.Fatalf("Expected the uploaded file, got %+v", files)
+	}
+	if !strings.HasPrefix(files[0].Name, namePrefix) || !strings.HasSuffix(files[0].Name, ".pdf") || strings.Contains(files[0].Name, "CV") {
+		t.Errorf("Expected a random stored name keeping the extension, got %q", files[0].Name)
+	}
+	file, err := store.Open(ctx, files[0].Name)
+	if err != nil {
+		t.Fatalf("Failed to open stored upload: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if string(content) != "resume" {
+		t.Errorf("Expected the upload's content, got %q", content)
+	}
+
+	if err := u.Record(ctx, submission.ID, files); err != nil {
+		t.Fatalf("Failed to record uploads: %v", err)
+	}
+	if recorded, _ := models.GetSubmissionFiles(db.Connection, submission.ID); len(recorded) != 1 || recorded[0].StorageName != files[0].Name {
+		t.Errorf("Expected the upload to be recorded, got %+v", recorded)
+	}
+
+	// A file that was stored but never recorded
+	orphans, err := u.Put(ctx, multipartForm(t, map[string]string{"spam.exe": "junk"}))
+	if err != nil {
+		t.Fatalf("Failed to store uploads: %v", err)
+	}
+
+	if deleted, err := u.Sweep(ctx); err != nil || deleted != 0 {
+		t.Errorf("Expected recent files to be kept, deleted %d (%v)", deleted, err)
+	}
+	u.now = func() time.Time { return time.Now().Add(2 * orphanAge) }
+	if deleted, err := u.Sweep(ctx); err != nil || deleted != 1 {
+		t.Fatalf("Expected the unrecorded file to be swept, deleted %d (%v)", deleted, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, orphans[0].Name)); !os.IsNotExist(err) {
+		t.Error("Expected the unrecorded file to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, files[0].Name)); err != nil {
+		t.Errorf("Expected the recorded file to be kept: %v", err)
+	}
+
+	// Deleting the submission leaves its file to the next sweep
+	if err := models.DeleteSubmission(db.Connection, submission.ID); err != nil {
+		t.Fatalf("Failed to delete submission: %v", err)
+	}
+	if deleted, err := u.Sweep(ctx); err != nil || deleted != 1 {
+		t.Errorf("Expected the deleted submission's file to be swept, deleted %d (%v)", deleted, err)
+	}
+}
+
+func TestUploader_EmptyFileInput(t *testing.T) {
+	u := NewUploader(nil, nil, nil)
+	files, err := u.Put(context.Background(), multipartForm(t, map[string]string{"": ""}))
+	if err != nil || len(files) != 0 {
+		t.Errorf("Expected an empty file input to be skipped, got %+v (%v)", files, err)
+	}
+}
+
+func TestUploader_EmailLinks(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "staticsend.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	u := NewUploader(db, nil, []byte("secret"))
+	now := time.Unix(1700000000, 0)
+	u.now = func() time.Time { return now }
+
+	user, _ := models.CreateUser(db.Connection, "links@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Jobs", "example.com", "secret", "jobs@example.com", "links_form_key")
+	submission, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{}`))
+	models.CreateSubmissionFile(db.Connection, &models.SubmissionFile{
+		SubmissionID: submission.ID, Field: "cv", Filename: "CV.pdf", Size: 6, StorageName: "upload-abc.pdf",
+	})
+
+	links, err := u.EmailLinks(context.Background(), submission.ID, time.Hour, "https://forms.example.com")
+	if err != nil {
+		t.Fatalf("Failed to make links: %v", err)
+	}
+	if len(links) != 1 || links[0].Field != "cv" || links[0].Filename != "CV.pdf" {
+		t.Fatalf("Expected a link to the file, got %+v", links)
+	}
+	link, err := url.Parse(links[0].URL)
+	if err != nil || link.Host != "forms.example.com" || strings.Contains(link.Path, "upload-abc") {
+		t.Fatalf("Expected an absolute link that doesn't give away the stored name, got %q", links[0].URL)
+	}
+
+	var submissionID, fileID int64
+	fmt.Sscanf(link.Path, "/uploads/%d/%d", &submissionID, &fileID)
+	expires, signature := link.Query().Get("expires"), link.Query().Get("signature")
+	if !u.Verify(submissionID, fileID, expires, signature) {
+		t.Error("Expected the link to verify")
+	}
+	if u.Verify(submissionID, fileID+1, expires, signature) {
+		t.Error("Expected the link not to verify for another file")
+	}
+	u.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if u.Verify(submissionID, fileID, expires, signature) {
+		t.Error("Expected the link to expire")
+	}
+}
@@ -0,0 +1,297 @@
+// Package velocity watches how fast forms receive submissions and alerts
+// their owners to sudden spikes, such as a spam flood, by email and through
+// the forms' notification channels, rather than leaving them to notice when
+// the inbox fills up.
+package velocity
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+)
+
+const (
+	// DefaultWindow is the period recent submissions are counted over
+	DefaultWindow = 10 * time.Minute
+	// DefaultBaseline is how far back before the window the usual rate is
+	// measured
+	DefaultBaseline = 24 * time.Hour
+	// DefaultFactor is how many times the usual rate makes a spike
+	DefaultFactor = 10
+	// DefaultMinSubmissions is the fewest submissions in the window that
+	// can be a spike, so a quiet form's second submission isn't one
+	DefaultMinSubmissions = 10
+	// DefaultCooldown is how long after an alert a form, or sender, isn't
+	// alerted about again
+	DefaultCooldown = time.Hour
+)
+
+// Spike is a form, or one sender to it, submitting far more than usual
+type Spike struct {
+	FormID int64
+	// IPAddress is set when the spike is one sender's rather than the
+	// whole form's
+	IPAddress string
+	// Count is the number of submissions in the window
+	Count int
+	// Usual is the average number of submissions per window over the
+	// baseline
+	Usual float64
+	// TopIPAddress and TopCount are the sender of most of a form's
+	// submissions in the window
+	TopIPAddress string
+	TopCount     int
+}
+
+// Analyzer looks for spikes in submissions and alerts the forms' owners
+type Analyzer struct {
+	db           *database.Database
+	emailService *email.EmailService
+	notifier     *notify.Dispatcher
+	baseURL      func() string
+	now          func() time.Time
+
+	Window         time.Duration
+	Baseline       time.Duration
+	Factor         int
+	MinSubmissions int
+	Cooldown       time.Duration
+
+	// mu ensures only one check runs at a time and guards alerted
+	mu sync.Mutex
+	// alerted holds when each form, or form and sender, was last alerted
+	alerted map[alertKey]time.Time
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type alertKey struct {
+	formID    int64
+	ipAddress string
+}
+
+// NewAnalyzer creates an analyzer with the default thresholds. Alerts are
+// emailed to forms' owners with emailService and sent through their
+// channels with notifier; either may be nil. Links in them start with
+// baseURL.
+func NewAnalyzer(db *database.Database, emailService *email.EmailService, notifier *notify.Dispatcher, baseURL func() string) *Analyzer {
+	return &Analyzer{
+		db:             db,
+		emailService:   emailService,
+		notifier:       notifier,
+		baseURL:        baseURL,
+		now:            time.Now,
+		Window:         DefaultWindow,
+		Baseline:       DefaultBaseline,
+		Factor:         DefaultFactor,
+		MinSubmissions: DefaultMinSubmissions,
+		Cooldown:       DefaultCooldown,
+		alerted:        map[alertKey]time.Time{},
+	}
+}
+
+// Check finds the spikes in the last window and alerts the owners of those
+// not alerted about within the cooldown, returning the spikes alerted
+func (a *Analyzer) Check(ctx context.Context) ([]Spike, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	windowStart := now.Add(-a.Window)
+	recent, err := models.CountSubmissionsBySenderContext(ctx, a.db.Connection, windowStart, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recent submissions: %w", err)
+	}
+	if len(recent) == 0 {
+		return nil, nil
+	}
+	earlier, err := models.CountSubmissionsBySenderContext(ctx, a.db.Connection, windowStart.Add(-a.Baseline), windowStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count earlier submissions: %w", err)
+	}
+
+	var alerts []Spike
+	for _, spike := range a.findSpikes(recent, earlier) {
+		key := alertKey{spike.FormID, spike.IPAddress}
+		if last, ok := a.alerted[key]; ok && now.Sub(last) < a.Cooldown {
+			continue
+		}
+		if err := a.alert(ctx, spike); err != nil {
+			log.Printf("Failed to alert the owner of form %d about a spike in submissions: %v", spike.FormID, err)
+			continue
+		}
+		a.alerted[key] = now
+		alerts = append(alerts, spike)
+	}
+
+	for key, last := range a.alerted {
+		if now.Sub(last) >= a.Cooldown {
+			delete(a.alerted, key)
+		}
+	}
+	return alerts, nil
+}
+
+// findSpikes compares the counts in the window with those over the
+// baseline. A sender's spike is only reported when its form as a whole
+// didn't spike, which already names the busiest sender.
+func (a *Analyzer) findSpikes(recent, earlier []models.SenderCount) []Spike {
+	windows := float64(a.Baseline) / float64(a.Window)
+	usualByForm := map[int64]float64{}
+	usualBySender := map[alertKey]float64{}
+	for _, c := range earlier {
+		usualByForm[c.FormID] += float64(c.Count) / windows
+		usualBySender[alertKey{c.FormID, c.IPAddress}] += float64(c.Count) / windows
+	}
+
+	forms := map[int64]*Spike{}
+	for _, c := range recent {
+		form := forms[c.FormID]
+		if form == nil {
+			form = &Spike{FormID: c.FormID, Usual: usualByForm[c.FormID]}
+			forms[c.FormID] = form
+		}
+		form.Count += c.Count
+		if c.Count > form.TopCount {
+			form.TopIPAddress, form.TopCount = c.IPAddress, c.Count
+		}
+	}
+
+	var spikes []Spike
+	for _, form := range forms {
+		if a.isSpike(form.Count, form.Usual) {
+			spikes = append(spikes, *form)
+		}
+	}
+	for _, c := range recent {
+		if c.IPAddress == "" || a.isSpike(forms[c.FormID].Count, forms[c.FormID].Usual) {
+			continue
+		}
+		usual := usualBySender[alertKey{c.FormID, c.IPAddress}]
+		if a.isSpike(c.Count, usual) {
+			spikes = append(spikes, Spike{FormID: c.FormID, IPAddress: c.IPAddress, Count: c.Count, Usual: usual})
+		}
+	}
+	sort.Slice(spikes, func(i, j int) bool {
+		if spikes[i].FormID != spikes[j].FormID {
+			return spikes[i].FormID < spikes[j].FormID
+		}
+		return spikes[i].IPAddress < spikes[j].IPAddress
+	})
+	return spikes
+}
+
+// isSpike reports whether count is at least Factor times the usual number,
+// counting a usual rate below one submission per window as one
+func (a *Analyzer) isSpike(count int, usual float64) bool {
+	return count >= a.MinSubmissions && float64(count) >= float64(a.Factor)*max(usual, 1)
+}
+
+// alert emails a spike's form's owner and sends it through the form's
+// channels
+func (a *Analyzer) alert(ctx context.Context, spike Spike) error {
+	form, err := models.GetFormByIDContext(ctx, a.db.Connection, spike.FormID)
+	if err != nil {
+		return err
+	}
+	if form == nil {
+		return nil
+	}
+
+	detail := fmt.Sprintf("%d submissions in the last %s, against about %.1f usually.", spike.Count, describe(a.Window), spike.Usual)
+	if spike.IPAddress != "" {
+		detail = fmt.Sprintf("%d submissions from %s in the last %s, against about %.1f usually.", spike.Count, spike.IPAddress, describe(a.Window), spike.Usual)
+	} else if spike.TopIPAddress != "" {
+		detail += fmt.Sprintf(" %d came from %s.", spike.TopCount, spike.TopIPAddress)
+	}
+	url := fmt.Sprintf("%s/forms/%d/submissions", a.baseURL(), form.ID)
+
+	if a.notifier != nil {
+		msg := a.notifier.FormMessage(notify.EventSubmissionSpike, form)
+		msg.Detail = detail
+		msg.URL = url
+		msg.Fields = map[string]string{
+			"submissions": strconv.Itoa(spike.Count),
+			"usual":       strconv.FormatFloat(spike.Usual, 'f', 1, 64),
+		}
+		if spike.IPAddress != "" {
+			msg.Fields["ip_address"] = spike.IPAddress
+		}
+		if err := a.notifier.Dispatch(ctx, form.ID, msg); err != nil {
+			log.Printf("Failed to queue spike notifications for form %d: %v", form.ID, err)
+		}
+	}
+
+	if a.emailService == nil {
+		return nil
+	}
+	user, err := models.GetUserByIDContext(ctx, a.db.Connection, form.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %d not found", form.UserID)
+	}
+	subject := fmt.Sprintf("Spike in submissions to %s", form.Name)
+	body := fmt.Sprintf("%s\n\nIf it's spam, you can block the sender with an IP rule or lower the form's spam thresholds.\n\n%s\n", detail, url)
+	// Sent ahead of the notifications the spike is queueing
+	return a.emailService.Enqueue(email.EmailJob{To: []string{user.Email}, Subject: subject, Body: body, Priority: email.PriorityHigh})
+}
+
+// describe writes a window as words, such as "10 minutes"
+func describe(window time.Duration) string {
+	if window%time.Minute != 0 {
+		return window.String()
+	}
+	if minutes := int(window / time.Minute); minutes != 1 {
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return "minute"
+}
+
+// Start checks for spikes every interval until Stop is called
+func (a *Analyzer) Start(interval time.Duration) {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-ticker.C:
+				spikes, err := a.Check(context.Background())
+				if err != nil {
+					log.Printf("Submission velocity check failed: %v", err)
+					continue
+				}
+				for _, spike := range spikes {
+					log.Printf("Alerted the owner of form %d to %d submissions in the last %s", spike.FormID, spike.Count, describe(a.Window))
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops checking for spikes, waiting for a running check to finish
+func (a *Analyzer) Stop() {
+	if a.stop == nil {
+		return
+	}
+	close(a.stop)
+	<-a.done
+	a.stop = nil
+}
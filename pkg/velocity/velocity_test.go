@@ -0,0 +1,93 @@
+package velocity
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+)
+
+func TestAnalyzer(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	quiet, err := models.CreateForm(db.Connection, user.ID, "Quiet", "example.com", "secret", "to@example.com", "quiet-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	busy, err := models.CreateForm(db.Connection, user.ID, "Busy", "example.com", "secret", "to@example.com", "busy-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	submit := func(formID int64, ip string, at time.Time, count int) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			if _, err := db.Connection.Exec(
+				"INSERT INTO submissions (form_id, ip_address, submitted_data, created_at) VALUES (?, ?, '{}', ?)",
+				formID, ip, at.UTC().Format("2006-01-02 15:04:05"),
+			); err != nil {
+				t.Fatalf("Failed to insert submission: %v", err)
+			}
+		}
+	}
+
+	// The quiet form usually gets a couple of submissions a day, and now
+	// has a flood from one address
+	submit(quiet.ID, "198.51.100.1", now.Add(-6*time.Hour), 2)
+	submit(quiet.ID, "203.0.113.9", now.Add(-5*time.Minute), 12)
+	submit(quiet.ID, "198.51.100.2", now.Add(-4*time.Minute), 1)
+
+	// The busy form usually gets about 30 every 10 minutes, which one
+	// address is now outdoing on its own without spiking the form
+	for hour := 1; hour <= 24; hour++ {
+		submit(busy.ID, "", now.Add(-time.Duration(hour)*time.Hour), 180)
+	}
+	submit(busy.ID, "", now.Add(-3*time.Minute), 20)
+	submit(busy.ID, "192.0.2.7", now.Add(-2*time.Minute), 15)
+
+	analyzer := NewAnalyzer(db, nil, nil, func() string { return "https://forms.example.com" })
+	analyzer.now = func() time.Time { return now }
+
+	spikes, err := analyzer.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(spikes) != 2 {
+		t.Fatalf("Expected 2 spikes, got %+v", spikes)
+	}
+	if s := spikes[0]; s.FormID != quiet.ID || s.IPAddress != "" || s.Count != 13 || s.TopIPAddress != "203.0.113.9" || s.TopCount != 12 {
+		t.Errorf("Expected the quiet form to spike with 13 submissions, mostly from 203.0.113.9, got %+v", s)
+	}
+	if s := spikes[1]; s.FormID != busy.ID || s.IPAddress != "192.0.2.7" || s.Count != 15 {
+		t.Errorf("Expected 192.0.2.7 to spike on the busy form, got %+v", s)
+	}
+
+	if spikes, _ := analyzer.Check(context.Background()); len(spikes) != 0 {
+		t.Errorf("Expected no alerts again within the cooldown, got %+v", spikes)
+	}
+	analyzer.now = func() time.Time { return now.Add(analyzer.Cooldown) }
+	if spikes, _ := analyzer.Check(context.Background()); len(spikes) != 0 {
+		t.Errorf("Expected no spikes once the window has passed, got %+v", spikes)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	for window, want := range map[time.Duration]string{
+		10 * time.Minute: "10 minutes",
+		time.Minute:      "minute",
+		90 * time.Second: "1m30s",
+	} {
+		if got := describe(window); got != want {
+			t.Errorf("describe(%s) = %q, want %q", window, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package version reports which build of staticSend is running. Release
+// builds set the variables with the linker:
+//
+//	go build -ldflags "-X staticsend/pkg/version.Version=v1.2.0 \
+//	  -X staticsend/pkg/version.Commit=$(git rev-parse HEAD) \
+//	  -X staticsend/pkg/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/staticsend
+//
+// Other builds fall back to the commit and time Go records from git.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X at build time
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the running build's details
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	if info.Commit != "" && info.Date != "" {
+		return info
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// ShortCommit returns the first 12 characters of the commit
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 12 {
+		return i.Commit[:12]
+	}
+	return i.Commit
+}
+
+// String describes the build on one line, e.g. for logs and -version
+func (i Info) String() string {
+	s := "staticSend " + i.Version
+	if i.Commit != "" {
+		s += " (" + i.ShortCommit()
+		if i.Date != "" {
+			s += ", built " + i.Date
+		}
+		s += ")"
+	}
+	return fmt.Sprintf("%s %s", s, i.GoVersion)
+}
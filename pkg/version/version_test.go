@@ -0,0 +1,31 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInfo_String(t *testing.T) {
+	info := Info{Version: "v1.2.0", Commit: "0123456789abcdef", Date: "2026-01-02T03:04:05Z", GoVersion: "go1.24.0"}
+	if got, want := info.String(), "staticSend v1.2.0 (0123456789ab, built 2026-01-02T03:04:05Z) go1.24.0"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	info = Info{Version: "dev", GoVersion: "go1.24.0"}
+	if got := info.String(); got != "staticSend dev go1.24.0" {
+		t.Errorf("Expected no commit for a build without one, got %q", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, Date = v, c, d }(Version, Commit, Date)
+	Version, Commit, Date = "v1.2.0", "abc", "today"
+
+	info := Get()
+	if info.Version != "v1.2.0" || info.Commit != "abc" || info.Date != "today" {
+		t.Errorf("Expected the linked values, got %+v", info)
+	}
+	if !strings.HasPrefix(info.GoVersion, "go") {
+		t.Errorf("Expected the Go version, got %q", info.GoVersion)
+	}
+}
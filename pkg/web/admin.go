@@ -0,0 +1,146 @@
+package web
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/debug"
+	"staticsend/pkg/email"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+const (
+	// adminStatsDays is how many days the admin overview charts
+	adminStatsDays = 30
+	// adminRecentEvents is how many recent blocked attempts it lists
+	adminRecentEvents = 10
+)
+
+// AdminHandler shows administrators instance-wide activity
+type AdminHandler struct {
+	DB           *database.Database
+	Templates    *templates.TemplateManager
+	EmailService *email.EmailService
+	// Limiters, when set, describes the rate limiters for the diagnostics
+	// page
+	Limiters func() []debug.LimiterStatus
+	// Profiling is whether the profiler is served under /debug/pprof/
+	Profiling bool
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(db *database.Database, tm *templates.TemplateManager, emailService *email.EmailService) *AdminHandler {
+	return &AdminHandler{
+		DB:           db,
+		Templates:    tm,
+		EmailService: emailService,
+	}
+}
+
+// Overview renders totals across every user, daily submissions, the email
+// queue and recent blocked attempts
+func (h *AdminHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	stats, err := models.GetInstanceStatsContext(r.Context(), h.DB.Connection)
+	if err != nil {
+		http.Error(w, "Failed to fetch stats", http.StatusInternalServerError)
+		return
+	}
+	counts, err := models.GetDailyCountsContext(r.Context(), h.DB.Connection, 0, 0, adminStatsDays, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch stats", http.StatusInternalServerError)
+		return
+	}
+	events, err := models.GetRecentBlockedAttemptsContext(r.Context(), h.DB.Connection, adminRecentEvents)
+	if err != nil {
+		http.Error(w, "Failed to fetch recent events", http.StatusInternalServerError)
+		return
+	}
+
+	submissions := make([]int, len(counts))
+	blocked := make([]int, len(counts))
+	scale := 1
+	for i, count := range counts {
+		submissions[i] = count.Submissions
+		blocked[i] = count.Blocked
+		scale = max(scale, count.Submissions, count.Blocked)
+	}
+
+	data := map[string]interface{}{
+		"Stats":           stats,
+		"Days":            adminStatsDays,
+		"Daily":           counts,
+		"Today":           counts[len(counts)-1],
+		"Peak":            scale,
+		"Width":           chartWidth,
+		"Height":          chartHeight,
+		"SubmissionsArea": chartArea(submissions, scale),
+		"BlockedLine":     chartLine(blocked, scale),
+		"Events":          events,
+	}
+	if h.EmailService != nil {
+		data["QueueDepth"] = h.EmailService.QueueSize()
+		data["QueueCapacity"] = h.EmailService.QueueCapacity()
+		data["EmailStats"] = h.EmailService.Stats()
+		data["EmailFallback"] = h.EmailService.UsingFallback()
+		data["EmailFailovers"] = h.EmailService.Failovers()
+	}
+
+	if err := h.Templates.Render(w, "admin/index.html", templates.TemplateData{
+		Title:      "Admin - staticSend",
+		User:       user,
+		ShowHeader: true,
+		Data:       data,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// Debug renders runtime diagnostics for working out what a production
+// instance is doing: goroutines, memory, the email queue, the rate limiters
+// and the database connection pool
+func (h *AdminHandler) Debug(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	data := map[string]interface{}{
+		"GoVersion":  runtime.Version(),
+		"Goroutines": runtime.NumGoroutine(),
+		"HeapBytes":  int64(memory.HeapAlloc),
+		"SysBytes":   int64(memory.Sys),
+		"GCCycles":   memory.NumGC,
+		"Pool":       h.DB.Connection.Stats(),
+		"Dialect":    h.DB.Dialect,
+		"Profiling":  h.Profiling,
+	}
+	if h.EmailService != nil {
+		data["QueueDepth"] = h.EmailService.QueueSize()
+		data["QueueCapacity"] = h.EmailService.QueueCapacity()
+	}
+	if h.Limiters != nil {
+		data["Limiters"] = h.Limiters()
+	}
+
+	if err := h.Templates.Render(w, "admin/debug.html", templates.TemplateData{
+		Title:      "Diagnostics - staticSend",
+		User:       user,
+		ShowHeader: true,
+		Data:       data,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
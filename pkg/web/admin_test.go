@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/debug"
+	"staticsend/pkg/email"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestAdminHandler_Overview(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	admin, _ := models.CreateUser(db.Connection, "admin@example.com", "hash")
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "admin-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	submission, _ := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", []byte(`{}`))
+	models.UpdateSubmissionStatus(db.Connection, submission.ID, "failed")
+	if err := models.CreateBlockedAttempt(db.Connection, &form.ID, nil, "203.0.113.9", "deny rule"); err != nil {
+		t.Fatalf("Failed to record blocked attempt: %v", err)
+	}
+
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25}, 50, 0, 0)
+	handler := NewAdminHandler(db, templates.NewTemplateManager(), emailService)
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserKey, admin))
+	rr := httptest.NewRecorder()
+	handler.Overview(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"Email failures", "/ 50 waiting", "Blocked <code>203.0.113.9</code> on form 1: deny rule", "1 today"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the overview", want)
+		}
+	}
+}
+
+func TestAdminHandler_Debug(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	admin, _ := models.CreateUser(db.Connection, "admin@example.com", "hash")
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25}, 50, 0, 0)
+	handler := NewAdminHandler(db, templates.NewTemplateManager(), emailService)
+	handler.Limiters = func() []debug.LimiterStatus {
+		return []debug.LimiterStatus{
+			{Name: "login", Rate: "12s", Burst: 5, Buckets: 3},
+			{Name: "submit", Rate: "1m0s", Burst: 10, Buckets: -1},
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/admin/debug", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserKey, admin))
+	rr := httptest.NewRecorder()
+	handler.Debug(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"Goroutines", "/ 50", "Database pool", "login", "in Redis", "STATICSEND_DEBUG=true"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q on the diagnostics page", want)
+		}
+	}
+
+	// With debugging on, the page links to the profiler
+	handler.Profiling = true
+	rr = httptest.NewRecorder()
+	handler.Debug(rr, req)
+	if !strings.Contains(rr.Body.String(), "/debug/pprof/") {
+		t.Error("Expected a link to the profiler")
+	}
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// APIKeysHandler manages the current user's API keys, which connect tools
+// such as Zapier and Make to their forms
+type APIKeysHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+}
+
+// NewAPIKeysHandler creates a new API keys handler
+func NewAPIKeysHandler(db *database.Database, tm *templates.TemplateManager) *APIKeysHandler {
+	return &APIKeysHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// APIKeys renders the current user's API keys
+func (h *APIKeysHandler) APIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.render(w, r, user, "", "", "")
+}
+
+// CreateAPIKey adds an API key for the current user, showing it this once
+func (h *APIKeysHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		h.render(w, r, user, "", "", "Name is required")
+		return
+	}
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		h.render(w, r, user, "", "", "Failed to generate key")
+		return
+	}
+	if _, err := models.CreateAPIKeyContext(r.Context(), h.DB.Connection, user.ID, name, prefix, hash); err != nil {
+		h.render(w, r, user, "", "", "Failed to save key")
+		return
+	}
+	h.render(w, r, user, key, "Key created. Copy it now: it won't be shown again.", "")
+}
+
+// DeleteAPIKey revokes one of the current user's API keys
+func (h *APIKeysHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid key ID", http.StatusBadRequest)
+		return
+	}
+	deleted, err := models.DeleteAPIKeyContext(r.Context(), h.DB.Connection, user.ID, id)
+	if err != nil {
+		h.render(w, r, user, "", "", "Failed to revoke key")
+		return
+	}
+	if !deleted {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	h.render(w, r, user, "", "Key revoked", "")
+}
+
+// render renders the API keys partial, with a newly created key if there is one
+func (h *APIKeysHandler) render(w http.ResponseWriter, r *http.Request, user *models.User, newKey, message, errorMsg string) {
+	keys, err := models.GetAPIKeysByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch API keys", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Templates.Render(w, "partials/api_keys.html", templates.TemplateData{
+		Title: "API Keys",
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Keys":    keys,
+			"NewKey":  newKey,
+			"Message": message,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestAPIKeysHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	handler := NewAPIKeysHandler(db, templates.NewTemplateManager())
+
+	serve := func(h http.HandlerFunc, user *models.User, method string, values url.Values, keyID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/account/api-keys", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("keyID", strconv.FormatInt(keyID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	if rr := serve(handler.APIKeys, owner, "GET", nil, 0); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "No API keys yet") {
+		t.Fatalf("Expected no keys, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(handler.CreateAPIKey, owner, "POST", url.Values{"name": {" "}}, 0); !strings.Contains(rr.Body.String(), "Name is required") {
+		t.Errorf("Expected a name to be required, got %s", rr.Body.String())
+	}
+
+	rr := serve(handler.CreateAPIKey, owner, "POST", url.Values{"name": {"Zapier"}}, 0)
+	key := regexp.MustCompile(`value="(ss_[^"]+)"`).FindStringSubmatch(rr.Body.String())
+	if key == nil {
+		t.Fatalf("Expected the new key to be shown, got %s", rr.Body.String())
+	}
+	stored, _ := models.GetAPIKeyByHash(db.Connection, auth.HashAPIKey(key[1]))
+	if stored == nil || stored.UserID != owner.ID || stored.Name != "Zapier" {
+		t.Fatalf("Expected the key stored by its hash, got %+v", stored)
+	}
+	if rr := serve(handler.APIKeys, owner, "GET", nil, 0); strings.Contains(rr.Body.String(), key[1]) {
+		t.Error("Expected the key not to be shown again")
+	}
+
+	if rr := serve(handler.DeleteAPIKey, other, "DELETE", nil, stored.ID); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected another user not to revoke the key, got %d", rr.Code)
+	}
+	if rr := serve(handler.DeleteAPIKey, owner, "DELETE", nil, stored.ID); !strings.Contains(rr.Body.String(), "Key revoked") {
+		t.Errorf("Expected the key to be revoked, got %s", rr.Body.String())
+	}
+	if keys, _ := models.GetAPIKeysByUserID(db.Connection, owner.ID); len(keys) != 0 {
+		t.Errorf("Expected no keys left, got %+v", keys)
+	}
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/archive"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+)
+
+// ArchivesHandler exports submissions that have been moved to archive files
+type ArchivesHandler struct {
+	DB       *database.Database
+	Archiver *archive.Archiver
+}
+
+// NewArchivesHandler creates a new archives handler
+func NewArchivesHandler(db *database.Database, archiver *archive.Archiver) *ArchivesHandler {
+	return &ArchivesHandler{
+		DB:       db,
+		Archiver: archiver,
+	}
+}
+
+// ExportArchive streams a form's archived submissions as JSON Lines
+func (h *ArchivesHandler) ExportArchive(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	formID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		return
+	}
+	if form == nil {
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="form-%d-archive.jsonl"`, form.ID))
+	if err := h.Archiver.Export(r.Context(), w, form.ID); err != nil {
+		// The response has already started, so the download is cut short
+		log.Printf("Failed to export archived submissions for form %d: %v", form.ID, err)
+	}
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/archive"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+)
+
+func TestArchivesHandler_ExportArchive(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "export-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	if _, err := db.Connection.Exec(
+		"INSERT INTO submissions (form_id, ip_address, user_agent, submitted_data, created_at) VALUES (?, ?, ?, ?, ?)",
+		form.ID, "127.0.0.1", "test", `{"name":"Ada"}`, "2020-01-01 00:00:00",
+	); err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+
+	store, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "archives"), "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	archiver := archive.NewArchiver(db, store, 24*time.Hour)
+	if _, err := archiver.Run(context.Background()); err != nil {
+		t.Fatalf("Archival failed: %v", err)
+	}
+	handler := NewArchivesHandler(db, archiver)
+
+	export := func(user *models.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/forms/archive", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		handler.ExportArchive(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	rr := export(owner)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"submitted_data":{"name":"Ada"}`) {
+		t.Errorf("Expected the archived submission in the export, got %s", rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Expected JSON Lines content type, got %q", got)
+	}
+
+	if rr := export(other); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+	}
+}
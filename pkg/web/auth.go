@@ -1,11 +1,15 @@
 package web
 
 import (
-	"context"
 	"net/http"
+	"strings"
 
 	"staticsend/pkg/auth"
+	"staticsend/pkg/clientip"
 	"staticsend/pkg/database"
+	"staticsend/pkg/events"
+	"staticsend/pkg/livesettings"
+	"staticsend/pkg/middleware"
 	"staticsend/pkg/models"
 	"staticsend/pkg/templates"
 	"staticsend/pkg/turnstile"
@@ -18,6 +22,16 @@ type WebAuthHandler struct {
 	Templates              *templates.TemplateManager
 	AuthTurnstilePublicKey string
 	AuthTurnstileSecretKey string
+	// Settings, when set, replaces the Turnstile keys with those saved on
+	// the settings page
+	Settings *livesettings.Store
+	// Turnstile verifies sign in tokens, turnstile.DefaultClient when not set
+	Turnstile *turnstile.Client
+	// AccountLimiter, when set, throttles sign in attempts for each email
+	// address, slowing credential stuffing spread across many IPs
+	AccountLimiter middleware.LimiterStore
+	// Events is told about accounts being registered, when set
+	Events *events.Bus
 }
 
 // NewWebAuthHandler creates a new web auth handler
@@ -31,6 +45,23 @@ func NewWebAuthHandler(db *database.Database, secretKey []byte, tm *templates.Te
 	}
 }
 
+// turnstileClient returns the client that verifies Turnstile tokens
+func (h *WebAuthHandler) turnstileClient() *turnstile.Client {
+	if h.Turnstile == nil {
+		return turnstile.DefaultClient
+	}
+	return h.Turnstile
+}
+
+// turnstileKeys returns the Turnstile keys in use for the sign in pages
+func (h *WebAuthHandler) turnstileKeys() (publicKey, secretKey string) {
+	if h.Settings == nil {
+		return h.AuthTurnstilePublicKey, h.AuthTurnstileSecretKey
+	}
+	settings := h.Settings.Current()
+	return settings.TurnstilePublicKey, settings.TurnstileSecretKey
+}
+
 // RegisterForm handles form-based user registration
 func (h *WebAuthHandler) RegisterForm(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
@@ -39,7 +70,7 @@ func (h *WebAuthHandler) RegisterForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if registration is enabled
-	enabled, err := models.IsRegistrationEnabled(h.DB.Connection)
+	enabled, err := models.IsRegistrationEnabledContext(r.Context(), h.DB.Connection)
 	if err != nil {
 		h.renderRegisterPage(w, "Internal server error")
 		return
@@ -59,16 +90,14 @@ func (h *WebAuthHandler) RegisterForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate Turnstile token if configured
-	if h.AuthTurnstileSecretKey != "" {
+	if _, turnstileSecretKey := h.turnstileKeys(); turnstileSecretKey != "" {
 		turnstileToken := r.FormValue("cf-turnstile-response")
 		if turnstileToken == "" {
 			h.renderRegisterPage(w, "Bot protection verification required")
 			return
 		}
 
-		validator := turnstile.NewValidator(h.AuthTurnstileSecretKey)
-		ctx := context.Background()
-		response, err := validator.Verify(ctx, turnstileToken, r.RemoteAddr)
+		response, err := h.turnstileClient().Verify(r.Context(), turnstileSecretKey, turnstileToken, clientip.FromRequest(r))
 		if err != nil {
 			h.renderRegisterPage(w, "Bot protection verification failed")
 			return
@@ -81,7 +110,7 @@ func (h *WebAuthHandler) RegisterForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if user already exists
-	exists, err := models.UserExists(h.DB.Connection, email)
+	exists, err := models.UserExistsContext(r.Context(), h.DB.Connection, email)
 	if err != nil {
 		h.renderRegisterPage(w, "Internal server error")
 		return
@@ -99,11 +128,14 @@ func (h *WebAuthHandler) RegisterForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create user
-	user, err := models.CreateUser(h.DB.Connection, email, passwordHash)
+	user, err := models.CreateUserContext(r.Context(), h.DB.Connection, email, passwordHash)
 	if err != nil {
 		h.renderRegisterPage(w, "Failed to create user")
 		return
 	}
+	if h.Events != nil {
+		h.Events.Publish(events.Event{Name: events.UserRegistered, User: user})
+	}
 
 	// Generate JWT token
 	token, err := auth.GenerateToken(user, h.SecretKey)
@@ -113,13 +145,7 @@ func (h *WebAuthHandler) RegisterForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set token as cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-	})
+	http.SetCookie(w, auth.SessionCookie(r, token))
 
 	// Use HX-Redirect for HTMX to properly handle the redirect
 	w.Header().Set("HX-Redirect", "/dashboard")
@@ -141,17 +167,23 @@ func (h *WebAuthHandler) LoginForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Throttle attempts on the account before anything is checked, so
+	// guesses are slowed whether or not they're right. The page is rendered
+	// as usual, since HTMX doesn't swap in error responses.
+	if h.AccountLimiter != nil && h.AccountLimiter.Limit(strings.ToLower(strings.TrimSpace(email))) {
+		h.renderLoginPage(w, "Too many sign in attempts for this account, try again in a few minutes")
+		return
+	}
+
 	// Validate Turnstile token if configured
-	if h.AuthTurnstileSecretKey != "" {
+	if _, turnstileSecretKey := h.turnstileKeys(); turnstileSecretKey != "" {
 		turnstileToken := r.FormValue("cf-turnstile-response")
 		if turnstileToken == "" {
 			h.renderLoginPage(w, "Bot protection verification required")
 			return
 		}
 
-		validator := turnstile.NewValidator(h.AuthTurnstileSecretKey)
-		ctx := context.Background()
-		response, err := validator.Verify(ctx, turnstileToken, r.RemoteAddr)
+		response, err := h.turnstileClient().Verify(r.Context(), turnstileSecretKey, turnstileToken, clientip.FromRequest(r))
 		if err != nil {
 			h.renderLoginPage(w, "Bot protection verification failed")
 			return
@@ -164,7 +196,7 @@ func (h *WebAuthHandler) LoginForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user by email
-	user, err := models.GetUserByEmail(h.DB.Connection, email)
+	user, err := models.GetUserByEmailContext(r.Context(), h.DB.Connection, email)
 	if err != nil {
 		h.renderLoginPage(w, "Internal server error")
 		return
@@ -188,13 +220,7 @@ func (h *WebAuthHandler) LoginForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set token as cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-	})
+	http.SetCookie(w, auth.SessionCookie(r, token))
 
 	// Use HX-Redirect for HTMX to properly handle the redirect
 	w.Header().Set("HX-Redirect", "/dashboard")
@@ -202,11 +228,12 @@ func (h *WebAuthHandler) LoginForm(w http.ResponseWriter, r *http.Request) {
 
 // renderRegisterPage renders the registration page with an optional error
 func (h *WebAuthHandler) renderRegisterPage(w http.ResponseWriter, errorMsg string) {
+	turnstilePublicKey, _ := h.turnstileKeys()
 	data := templates.TemplateData{
 		Title:                  "Register - staticSend",
 		Error:                  errorMsg,
 		ShowHeader:             false,
-		AuthTurnstilePublicKey: h.AuthTurnstilePublicKey,
+		AuthTurnstilePublicKey: turnstilePublicKey,
 	}
 	
 	h.Templates.Render(w, "auth/register.html", data)
@@ -214,11 +241,12 @@ func (h *WebAuthHandler) renderRegisterPage(w http.ResponseWriter, errorMsg stri
 
 // renderLoginPage renders the login page with an optional error
 func (h *WebAuthHandler) renderLoginPage(w http.ResponseWriter, errorMsg string) {
+	turnstilePublicKey, _ := h.turnstileKeys()
 	data := templates.TemplateData{
 		Title:                  "Login - staticSend",
 		Error:                  errorMsg,
 		ShowHeader:             false,
-		AuthTurnstilePublicKey: h.AuthTurnstilePublicKey,
+		AuthTurnstilePublicKey: turnstilePublicKey,
 	}
 	
 	h.Templates.Render(w, "auth/login.html", data)
@@ -227,14 +255,7 @@ func (h *WebAuthHandler) renderLoginPage(w http.ResponseWriter, errorMsg string)
 // Logout handles user logout
 func (h *WebAuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	// Clear the auth cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false,
-		MaxAge:   -1, // Immediately expire the cookie
-	})
+	http.SetCookie(w, auth.ExpiredSessionCookie(r))
 
 	// Redirect to login page
 	http.Redirect(w, r, "/login", http.StatusFound)
@@ -1,9 +1,17 @@
 package web
 
 import (
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"staticsend/pkg/auth"
 	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
 	"staticsend/pkg/templates"
 )
 
@@ -50,3 +58,51 @@ func TestWebAuthHandler_WithTurnstileKeys(t *testing.T) {
 		t.Errorf("Expected AuthTurnstileSecretKey 'test-secret-key', got '%s'", handler.AuthTurnstileSecretKey)
 	}
 }
+
+func TestWebAuthHandler_LoginThrottlesAccount(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := auth.HashPassword("correct-password")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if _, err := models.CreateUser(db.Connection, "owner@example.com", hash); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	handler := NewWebAuthHandler(db, []byte("test-secret"), templates.NewTemplateManager(), "", "")
+	handler.AccountLimiter = middleware.NewRateLimiter(time.Hour, 2)
+
+	login := func(email, password, ip string) *httptest.ResponseRecorder {
+		form := url.Values{"email": {email}, "password": {password}}
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip
+		rr := httptest.NewRecorder()
+		handler.LoginForm(rr, req)
+		return rr
+	}
+
+	// Guesses from different IPs count against the same account
+	for _, ip := range []string{"203.0.113.1:1234", "203.0.113.2:1234"} {
+		if body := login("owner@example.com", "wrong", ip).Body.String(); !strings.Contains(body, "Invalid email or password") {
+			t.Fatalf("Expected a wrong password to be refused, got %q", body)
+		}
+	}
+	rr := login("Owner@Example.com", "correct-password", "203.0.113.3:1234")
+	if !strings.Contains(rr.Body.String(), "Too many sign in attempts") {
+		t.Errorf("Expected the account to be throttled, got %q", rr.Body.String())
+	}
+	if rr.Header().Get("HX-Redirect") != "" {
+		t.Error("Expected a throttled sign in not to succeed")
+	}
+
+	// Other accounts are unaffected
+	if body := login("other@example.com", "wrong", "203.0.113.1:1234").Body.String(); !strings.Contains(body, "Invalid email or password") {
+		t.Errorf("Expected another account not to be throttled, got %q", body)
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/backup"
+	"staticsend/pkg/templates"
+)
+
+// BackupsHandler lists database backups and takes them on demand
+type BackupsHandler struct {
+	// Backups is nil when the database engine can't be backed up
+	Backups   *backup.Manager
+	Templates *templates.TemplateManager
+	// LinkTTL is how long the store's links that downloads redirect to last
+	LinkTTL time.Duration
+}
+
+// NewBackupsHandler creates a new backups handler
+func NewBackupsHandler(backups *backup.Manager, tm *templates.TemplateManager) *BackupsHandler {
+	return &BackupsHandler{
+		Backups:   backups,
+		Templates: tm,
+		LinkTTL:   defaultLinkTTL,
+	}
+}
+
+// ListBackups renders the backups partial
+func (h *BackupsHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "", "")
+}
+
+// BackupNow takes a backup immediately
+func (h *BackupsHandler) BackupNow(w http.ResponseWriter, r *http.Request) {
+	if h.Backups == nil {
+		h.render(w, r, backup.ErrUnsupported.Error(), "")
+		return
+	}
+
+	created, err := h.Backups.Run(r.Context())
+	if err != nil {
+		log.Printf("Manual backup failed: %v", err)
+		errorMsg := "Backup failed"
+		if errors.Is(err, backup.ErrUnsupported) {
+			errorMsg = err.Error()
+		}
+		h.render(w, r, errorMsg, "")
+		return
+	}
+
+	h.render(w, r, "", "Created backup "+created.Name)
+}
+
+// Download redirects to a signed link that downloads a backup
+func (h *BackupsHandler) Download(w http.ResponseWriter, r *http.Request) {
+	if h.Backups == nil {
+		http.Error(w, backup.ErrUnsupported.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Only files listed as backups can be downloaded
+	name := chi.URLParam(r, "name")
+	backups, err := h.Backups.List(r.Context())
+	if err != nil {
+		log.Printf("Failed to list backups: %v", err)
+		http.Error(w, "Failed to list backups", http.StatusInternalServerError)
+		return
+	}
+	found := false
+	for _, b := range backups {
+		found = found || b.Name == name
+	}
+	if !found {
+		http.Error(w, "Backup not found", http.StatusNotFound)
+		return
+	}
+
+	link, err := h.Backups.Store().SignedURL(r.Context(), name, name, h.LinkTTL)
+	if err != nil {
+		log.Printf("Failed to sign a link to backup %s: %v", name, err)
+		http.Error(w, "This store can't make download links", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, link, http.StatusFound)
+}
+
+// render renders the backups partial with the stored backups
+func (h *BackupsHandler) render(w http.ResponseWriter, r *http.Request, errorMsg, flash string) {
+	data := map[string]interface{}{
+		"Enabled": h.Backups != nil,
+	}
+	if h.Backups != nil {
+		backups, err := h.Backups.List(r.Context())
+		if err != nil {
+			log.Printf("Failed to list backups: %v", err)
+			if errorMsg == "" {
+				errorMsg = "Failed to list backups"
+			}
+		}
+		data["Backups"] = backups
+		data["Store"] = h.Backups.Store().String()
+	}
+
+	if err := h.Templates.Render(w, "partials/backups.html", templates.TemplateData{
+		Title: "Backups",
+		Error: errorMsg,
+		Flash: flash,
+		Data:  data,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
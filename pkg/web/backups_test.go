@@ -0,0 +1,97 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/backup"
+	"staticsend/pkg/database"
+	"staticsend/pkg/storage"
+	"staticsend/pkg/templates"
+)
+
+func TestBackupsHandler_BackupNow(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	store, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "backups"), "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create backup store: %v", err)
+	}
+	handler := NewBackupsHandler(backup.NewManager(db, store, 3), templates.NewTemplateManager())
+
+	rr := httptest.NewRecorder()
+	handler.BackupNow(rr, httptest.NewRequest("POST", "/settings/backups", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "Created backup staticsend-") {
+		t.Errorf("Expected confirmation of the new backup, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ListBackups(rr, httptest.NewRequest("GET", "/settings/backups", nil))
+	if !strings.Contains(rr.Body.String(), ".db.gz") {
+		t.Error("Expected the backup to be listed")
+	}
+}
+
+func TestBackupsHandler_Download(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	store, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "backups"), "/files/backups", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create backup store: %v", err)
+	}
+	manager := backup.NewManager(db, store, 3)
+	created, err := manager.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+	handler := NewBackupsHandler(manager, templates.NewTemplateManager())
+
+	download := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/settings/backups/"+name+"/download", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("name", name)
+		rr := httptest.NewRecorder()
+		handler.Download(rr, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		return rr
+	}
+
+	rr := download(created.Name)
+	if rr.Code != http.StatusFound || !strings.HasPrefix(rr.Header().Get("Location"), "/files/backups/"+created.Name+"?") {
+		t.Fatalf("Expected a redirect to a signed link, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	served := httptest.NewRecorder()
+	store.ServeHTTP(served, httptest.NewRequest("GET", rr.Header().Get("Location"), nil))
+	if served.Code != http.StatusOK || served.Body.Len() == 0 {
+		t.Errorf("Expected the link to download the backup, got %d", served.Code)
+	}
+
+	if rr := download("staticsend.db"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a file that isn't a backup, got %d", rr.Code)
+	}
+}
+
+func TestBackupsHandler_Unsupported(t *testing.T) {
+	handler := NewBackupsHandler(nil, templates.NewTemplateManager())
+
+	rr := httptest.NewRecorder()
+	handler.BackupNow(rr, httptest.NewRequest("POST", "/settings/backups", nil))
+	if !strings.Contains(rr.Body.String(), "only supported for SQLite") {
+		t.Errorf("Expected unsupported message, got %s", rr.Body.String())
+	}
+}
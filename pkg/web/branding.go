@@ -0,0 +1,165 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// maxLogoSize is the largest logo that can be uploaded
+const maxLogoSize = 512 * 1024
+
+// logoContentTypes are the image types accepted as a logo
+var logoContentTypes = map[string]bool{
+	"image/png":     true,
+	"image/jpeg":    true,
+	"image/gif":     true,
+	"image/webp":    true,
+	"image/svg+xml": true,
+}
+
+// colorPattern matches a hex colour such as #2563eb
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// LoadBranding applies the branding settings to the pages
+func LoadBranding(ctx context.Context, db *database.Database, tm *templates.TemplateManager) error {
+	branding, err := models.GetBrandingContext(ctx, db.Connection)
+	if err != nil {
+		return err
+	}
+
+	loaded := templates.Branding{
+		Title:       branding.Title,
+		Description: branding.Description,
+	}
+	if colorPattern.MatchString(branding.PrimaryColor) {
+		loaded.PrimaryColor = branding.PrimaryColor
+	}
+	// The upload time in the URL lets browsers cache the logo until it changes
+	if branding.LogoUpdatedAt != nil {
+		loaded.LogoURL = fmt.Sprintf("/branding/logo?v=%d", branding.LogoUpdatedAt.Unix())
+	}
+	tm.SetBranding(loaded)
+	return nil
+}
+
+// BrandingHandler serves and manages the uploaded logo
+type BrandingHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+}
+
+// NewBrandingHandler creates a new branding handler
+func NewBrandingHandler(db *database.Database, tm *templates.TemplateManager) *BrandingHandler {
+	return &BrandingHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// Logo serves the uploaded logo
+func (h *BrandingHandler) Logo(w http.ResponseWriter, r *http.Request) {
+	logo, err := models.GetSiteAssetContext(r.Context(), h.DB.Connection, models.SiteAssetLogo)
+	if err != nil {
+		http.Error(w, "Failed to fetch logo", http.StatusInternalServerError)
+		return
+	}
+	if logo == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", logo.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// SVG logos can contain scripts, which mustn't run if the logo is opened directly
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", logo.UpdatedAt, bytes.NewReader(logo.Data))
+}
+
+// ShowLogo renders the logo section of the settings page
+func (h *BrandingHandler) ShowLogo(w http.ResponseWriter, r *http.Request) {
+	h.render(w, "", "")
+}
+
+// UploadLogo replaces the logo with an uploaded image
+func (h *BrandingHandler) UploadLogo(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLogoSize+64*1024)
+	file, header, err := r.FormFile("logo")
+	if err != nil {
+		h.render(w, "Choose an image of up to 512 KB", "")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxLogoSize+1))
+	if err != nil {
+		h.render(w, "Failed to read the image", "")
+		return
+	}
+	if len(data) > maxLogoSize {
+		h.render(w, "The logo can be at most 512 KB", "")
+		return
+	}
+
+	contentType := logoContentType(header.Filename, data)
+	if !logoContentTypes[contentType] {
+		h.render(w, "The logo must be a PNG, JPEG, GIF, WebP or SVG image", "")
+		return
+	}
+
+	if err := models.SaveSiteAssetContext(r.Context(), h.DB.Connection, models.SiteAssetLogo, contentType, data); err != nil {
+		h.render(w, "Failed to save the logo", "")
+		return
+	}
+	if err := LoadBranding(r.Context(), h.DB, h.Templates); err != nil {
+		h.render(w, "Failed to apply the logo", "")
+		return
+	}
+	h.render(w, "", "Logo uploaded. Reload the page to see it in the header.")
+}
+
+// DeleteLogo removes the logo, so the site title is shown instead
+func (h *BrandingHandler) DeleteLogo(w http.ResponseWriter, r *http.Request) {
+	if err := models.DeleteSiteAssetContext(r.Context(), h.DB.Connection, models.SiteAssetLogo); err != nil {
+		h.render(w, "Failed to remove the logo", "")
+		return
+	}
+	if err := LoadBranding(r.Context(), h.DB, h.Templates); err != nil {
+		h.render(w, "Failed to apply the change", "")
+		return
+	}
+	h.render(w, "", "Logo removed")
+}
+
+// render renders the logo section of the settings page
+func (h *BrandingHandler) render(w http.ResponseWriter, errorMsg, flash string) {
+	if err := h.Templates.Render(w, "partials/logo.html", templates.TemplateData{
+		Title: "Logo",
+		Error: errorMsg,
+		Flash: flash,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// logoContentType returns the type of an uploaded image. SVG files are
+// text, so they're recognised by name and content rather than sniffed.
+func logoContentType(filename string, data []byte) string {
+	if strings.HasSuffix(strings.ToLower(filename), ".svg") && bytes.Contains(data, []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return http.DetectContentType(data)
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestBrandingHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	tm := templates.NewTemplateManager()
+	handler := NewBrandingHandler(db, tm)
+
+	upload := func(filename string, data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("logo", filename)
+		part.Write(data)
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/settings/logo", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.UploadLogo(rr, req)
+		return rr
+	}
+
+	t.Run("rejects other files", func(t *testing.T) {
+		rr := upload("notes.txt", []byte("not an image"))
+		if !strings.Contains(rr.Body.String(), "must be a PNG, JPEG, GIF, WebP or SVG image") {
+			t.Errorf("Expected a text file to be rejected, got %s", rr.Body.String())
+		}
+		rr = upload("huge.png", append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, maxLogoSize)...))
+		if !strings.Contains(rr.Body.String(), "at most 512 KB") {
+			t.Errorf("Expected a large file to be rejected, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("upload and serve", func(t *testing.T) {
+		logo := []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`)
+		rr := upload("logo.svg", logo)
+		if !strings.Contains(rr.Body.String(), "Logo uploaded") {
+			t.Fatalf("Expected the logo to be uploaded, got %s", rr.Body.String())
+		}
+		if !strings.HasPrefix(tm.Branding().LogoURL, "/branding/logo?v=") {
+			t.Errorf("Expected the logo to be applied, got %q", tm.Branding().LogoURL)
+		}
+
+		rr = httptest.NewRecorder()
+		handler.Logo(rr, httptest.NewRequest("GET", tm.Branding().LogoURL, nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != string(logo) {
+			t.Fatalf("Expected the logo, got %d", rr.Code)
+		}
+		if rr.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(rr.Header().Get("Content-Security-Policy"), "sandbox") {
+			t.Errorf("Unexpected headers: %v", rr.Header())
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.DeleteLogo(rr, httptest.NewRequest("DELETE", "/settings/logo", nil))
+		if !strings.Contains(rr.Body.String(), "No logo uploaded") || tm.Branding().LogoURL != "" {
+			t.Errorf("Expected the logo to be removed")
+		}
+
+		rr = httptest.NewRecorder()
+		handler.Logo(rr, httptest.NewRequest("GET", "/branding/logo", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 without a logo, got %d", rr.Code)
+		}
+	})
+}
+
+func TestLoadBranding(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	models.UpdateAppSetting(db.Connection, models.SettingSiteTitle, "Acme Forms")
+	models.UpdateAppSetting(db.Connection, models.SettingSiteDescription, "Contact forms for Acme")
+	models.UpdateAppSetting(db.Connection, models.SettingPrimaryColor, "#ff6600")
+
+	tm := templates.NewTemplateManager()
+	if err := LoadBranding(context.Background(), db, tm); err != nil {
+		t.Fatalf("Failed to load branding: %v", err)
+	}
+
+	var page bytes.Buffer
+	if err := tm.Render(&page, "auth/login.html", templates.TemplateData{Title: "Login"}); err != nil {
+		t.Fatalf("Failed to render login page: %v", err)
+	}
+	for _, want := range []string{"<title>Acme Forms - Login</title>", `content="Contact forms for Acme"`, "Sign in to Acme Forms", "background-color: #ff6600"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("Expected %q in the login page", want)
+		}
+	}
+
+	// Colours that aren't hex codes are ignored rather than written into CSS
+	models.UpdateAppSetting(db.Connection, models.SettingPrimaryColor, "red; } body { display: none")
+	LoadBranding(context.Background(), db, tm)
+	if tm.Branding().PrimaryColor != "" {
+		t.Errorf("Expected an invalid colour to be ignored, got %q", tm.Branding().PrimaryColor)
+	}
+}
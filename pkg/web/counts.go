@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"staticsend/pkg/events"
+	"staticsend/pkg/models"
+)
+
+// submissionCountTTL is how long a user's submission counts are reused. The
+// dashboard and each page of forms it loads share one count query, at the
+// cost of counts lagging new submissions by up to this long.
+const submissionCountTTL = 30 * time.Second
+
+// submissionCounts caches each user's submission counts per form
+type submissionCounts struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[int64]submissionCountEntry
+}
+
+type submissionCountEntry struct {
+	counts  map[int64]int
+	expires time.Time
+}
+
+func newSubmissionCounts(ttl time.Duration) *submissionCounts {
+	return &submissionCounts{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[int64]submissionCountEntry),
+	}
+}
+
+// get returns the user's submission counts keyed by form ID, counting them
+// again once the cached counts have expired
+func (c *submissionCounts) get(ctx context.Context, db *sql.DB, userID int64) (map[int64]int, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.counts, nil
+	}
+
+	counts, err := models.GetSubmissionCountsByUserIDContext(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	// Drop expired entries so users who stop visiting don't stay cached
+	for id, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[userID] = submissionCountEntry{counts: counts, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return counts, nil
+}
+
+// forget drops the user's cached counts, so they're counted again next time
+func (c *submissionCounts) forget(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+// ForgetCounts drops the cached submission counts of the owner of an
+// event's form, so the dashboard shows new submissions and deleted forms
+// straight away. It's subscribed to submission.created and form.deleted.
+func (h *WebHandler) ForgetCounts(ctx context.Context, event events.Event) {
+	if event.Form != nil {
+		h.counts.forget(event.Form.UserID)
+	}
+}
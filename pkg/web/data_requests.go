@@ -0,0 +1,214 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+	"staticsend/pkg/subjectdata"
+	"staticsend/pkg/templates"
+)
+
+// dataRequestHistory is how many recent data subject requests are listed
+const dataRequestHistory = 20
+
+// DataRequestsHandler lets administrators answer data subject requests:
+// finding the submissions holding a visitor's email address, then exporting
+// them as a ZIP file or redacting or deleting them. Every export and
+// erasure is recorded.
+type DataRequestsHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+	// Files is where uploaded files are kept, so exports include them. Nil
+	// if uploads aren't kept.
+	Files storage.Store
+}
+
+// NewDataRequestsHandler creates a new data requests handler
+func NewDataRequestsHandler(db *database.Database, tm *templates.TemplateManager) *DataRequestsHandler {
+	return &DataRequestsHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// dataRequest is a search for a visitor's submissions, read from a form
+type dataRequest struct {
+	Email string
+	// OwnerID is the account whose forms are searched, or 0 for every
+	// account's
+	OwnerID    int64
+	OwnerEmail string
+}
+
+// ShowDataRequests renders the data requests partial
+func (h *DataRequestsHandler) ShowDataRequests(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, nil, nil, "", "")
+}
+
+// FindSubmissions lists the submissions holding an email address
+func (h *DataRequestsHandler) FindSubmissions(w http.ResponseWriter, r *http.Request) {
+	request, message := h.parse(r)
+	if message != "" {
+		h.render(w, r, request, nil, message, "")
+		return
+	}
+	submissions, err := models.FindSubmissionsByEmailContext(r.Context(), h.DB.Connection, request.OwnerID, request.Email)
+	if err != nil {
+		h.render(w, r, request, nil, "Failed to search submissions", "")
+		return
+	}
+	if submissions == nil {
+		// An empty list, rather than nil, says nothing was found
+		submissions = []models.SubjectSubmission{}
+	}
+	h.render(w, r, request, submissions, "", "")
+}
+
+// ExportSubmissions downloads the submissions holding an email address,
+// and the files uploaded with them, as a ZIP file
+func (h *DataRequestsHandler) ExportSubmissions(w http.ResponseWriter, r *http.Request) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	request, message := h.parse(r)
+	if message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	submissions, err := models.FindSubmissionsByEmailContext(r.Context(), h.DB.Connection, request.OwnerID, request.Email)
+	if err != nil {
+		http.Error(w, "Failed to search submissions", http.StatusInternalServerError)
+		return
+	}
+	if len(submissions) == 0 {
+		http.Error(w, "No submissions hold that email address", http.StatusNotFound)
+		return
+	}
+
+	// The export is recorded before it's written, so none goes unrecorded
+	if _, err := models.CreateDataRequestContext(r.Context(), h.DB.Connection, &models.DataRequest{
+		Email:      request.Email,
+		Action:     models.DataRequestExport,
+		OwnerEmail: request.OwnerEmail,
+		AdminEmail: admin.Email,
+	}, len(submissions)); err != nil {
+		http.Error(w, "Failed to record the request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="data-request-%s.zip"`, time.Now().UTC().Format("20060102-150405")))
+	if err := subjectdata.WriteZIP(r.Context(), h.DB, h.Files, submissions, w); err != nil {
+		// Headers have been sent, so the download is cut short
+		log.Printf("Failed to write a data export: %v", err)
+	}
+}
+
+// EraseSubmissions redacts or deletes the submissions holding an email
+// address, as the action field says
+func (h *DataRequestsHandler) EraseSubmissions(w http.ResponseWriter, r *http.Request) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	request, message := h.parse(r)
+	if message != "" {
+		h.render(w, r, request, nil, message, "")
+		return
+	}
+	action := r.FormValue("action")
+	if action != models.DataRequestRedact && action != models.DataRequestDelete {
+		h.render(w, r, request, nil, "Choose to redact or delete the submissions", "")
+		return
+	}
+
+	submissions, err := models.FindSubmissionsByEmailContext(r.Context(), h.DB.Connection, request.OwnerID, request.Email)
+	if err != nil {
+		h.render(w, r, request, nil, "Failed to search submissions", "")
+		return
+	}
+	if len(submissions) == 0 {
+		h.render(w, r, request, nil, "No submissions hold that email address", "")
+		return
+	}
+	if _, err := models.EraseSubmissionsContext(r.Context(), h.DB.Connection, &models.DataRequest{
+		Email:      request.Email,
+		Action:     action,
+		OwnerEmail: request.OwnerEmail,
+		AdminEmail: admin.Email,
+	}, submissions); err != nil {
+		log.Printf("Failed to %s submissions for a data request: %v", action, err)
+		h.render(w, r, request, submissions, "Failed to erase the submissions", "")
+		return
+	}
+
+	flash := fmt.Sprintf("Deleted %d submissions", len(submissions))
+	if action == models.DataRequestRedact {
+		flash = fmt.Sprintf("Redacted %d submissions", len(submissions))
+	}
+	h.render(w, r, &dataRequest{}, nil, "", flash)
+}
+
+// parse reads the email address and account to search from the form,
+// returning why they're invalid if they are
+func (h *DataRequestsHandler) parse(r *http.Request) (*dataRequest, string) {
+	request := &dataRequest{Email: strings.TrimSpace(r.FormValue("email"))}
+	if address, err := mail.ParseAddress(request.Email); err != nil || address.Address != request.Email {
+		return request, "Enter a visitor's email address, such as visitor@example.com"
+	}
+	if owner := r.FormValue("owner_id"); owner != "" && owner != "0" {
+		id, err := strconv.ParseInt(owner, 10, 64)
+		if err != nil {
+			return request, "Invalid account"
+		}
+		user, err := models.GetUserByIDContext(r.Context(), h.DB.Connection, id)
+		if err != nil || user == nil {
+			return request, "Invalid account"
+		}
+		request.OwnerID, request.OwnerEmail = user.ID, user.Email
+	}
+	return request, ""
+}
+
+// render renders the data requests partial with the search, the
+// submissions it found and the recent requests
+func (h *DataRequestsHandler) render(w http.ResponseWriter, r *http.Request, request *dataRequest, submissions []models.SubjectSubmission, errorMsg, flash string) {
+	if request == nil {
+		request = &dataRequest{}
+	}
+	users, err := models.GetAllUsersContext(r.Context(), h.DB.Connection)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to load accounts"
+	}
+	history, err := models.GetDataRequestsContext(r.Context(), h.DB.Connection, dataRequestHistory)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to load recent requests"
+	}
+
+	if err := h.Templates.Render(w, "partials/data_requests.html", templates.TemplateData{
+		Title: "Data Requests",
+		Error: errorMsg,
+		Flash: flash,
+		Data: map[string]interface{}{
+			"Request":     request,
+			"Searched":    submissions != nil,
+			"Submissions": submissions,
+			"Users":       users,
+			"History":     history,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
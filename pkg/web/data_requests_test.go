@@ -0,0 +1,66 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestDataRequestsHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	admin, _ := models.CreateUser(db.Connection, "admin@example.com", "hash")
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "gdpr-key")
+	submission, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.1", "Browser", []byte(`{"email":"jo@example.com"}`))
+	handler := NewDataRequestsHandler(db, templates.NewTemplateManager())
+
+	post := func(fn http.HandlerFunc, values url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		fn(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, admin)))
+		return rr
+	}
+	search := url.Values{"email": {"jo@example.com"}, "owner_id": {strconv.FormatInt(owner.ID, 10)}}
+
+	if rr := post(handler.FindSubmissions, url.Values{"email": {"Jo <jo@example.com>"}}); !strings.Contains(rr.Body.String(), "Enter a visitor&#39;s email address") {
+		t.Errorf("Expected a display name to be refused, got %s", rr.Body.String())
+	}
+	if rr := post(handler.FindSubmissions, search); !strings.Contains(rr.Body.String(), "1 submissions hold") {
+		t.Errorf("Expected the submission to be found, got %s", rr.Body.String())
+	}
+
+	rr := post(handler.ExportSubmissions, search)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/zip" || !strings.HasPrefix(rr.Body.String(), "PK") {
+		t.Fatalf("Expected a ZIP file, got %d: %v", rr.Code, rr.Header())
+	}
+
+	erase := url.Values{"email": search["email"], "owner_id": search["owner_id"], "action": {"delete"}}
+	if rr := post(handler.EraseSubmissions, erase); !strings.Contains(rr.Body.String(), "Deleted 1 submissions") {
+		t.Fatalf("Expected the submission to be deleted, got %s", rr.Body.String())
+	}
+	if deleted, _ := models.GetSubmissionByID(db.Connection, submission.ID); deleted != nil {
+		t.Error("Expected the submission to be deleted")
+	}
+
+	requests, _ := models.GetDataRequests(db.Connection, 10)
+	if len(requests) != 2 || requests[1].Action != models.DataRequestExport || requests[0].OwnerEmail != owner.Email || requests[0].AdminEmail != admin.Email {
+		t.Errorf("Expected the export and deletion to be recorded, got %+v", requests)
+	}
+}
@@ -0,0 +1,21 @@
+package web
+
+import (
+	"net/http"
+
+	"staticsend/pkg/models"
+)
+
+// ClearEmailBounce forgets that mail to a form's forward address was
+// failing, once its owner has fixed the mailbox, and shows the form again
+func (h *WebHandler) ClearEmailBounce(w http.ResponseWriter, r *http.Request) {
+	_, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+	if err := models.ClearEmailBounceContext(r.Context(), h.DB.Connection, form.ForwardEmail); err != nil {
+		http.Error(w, "Failed to clear the warning", http.StatusInternalServerError)
+		return
+	}
+	h.ViewFormModal(w, r)
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestWebHandler_EmailBounceWarning(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "Inbox@Example.com", "bounce-key")
+	models.CreateForm(db.Connection, owner.ID, "Quote", "example.com", "secret", "sales@example.com", "quote-key")
+	if err := models.RecordEmailBounce(db.Connection, "inbox@example.com", models.BounceKindBounce, "ses", "550 5.1.1 mailbox unavailable", time.Now()); err != nil {
+		t.Fatalf("Failed to record bounce: %v", err)
+	}
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(h http.HandlerFunc, user *models.User, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	rr := serve(handler.DashboardForms, owner, "GET")
+	if strings.Count(rr.Body.String(), "Notification emails are failing") != 1 {
+		t.Errorf("Expected only the bouncing form flagged on the dashboard, got:\n%s", rr.Body.String())
+	}
+
+	rr = serve(handler.ViewFormModal, owner, "GET")
+	body := rr.Body.String()
+	if !strings.Contains(body, "Notification emails to this address are bouncing") || !strings.Contains(body, "550 5.1.1 mailbox unavailable") {
+		t.Errorf("Expected the bounce explained on the form, got:\n%s", body)
+	}
+
+	if rr := serve(handler.ClearEmailBounce, other, "POST"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected another user to be refused, got %d", rr.Code)
+	}
+	rr = serve(handler.ClearEmailBounce, owner, "POST")
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "are bouncing") {
+		t.Errorf("Expected the warning cleared, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	if bounce, _ := models.GetEmailBounce(db.Connection, "inbox@example.com"); bounce != nil {
+		t.Errorf("Expected the bounce forgotten, got %+v", bounce)
+	}
+}
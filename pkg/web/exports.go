@@ -0,0 +1,185 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/export"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+	"staticsend/pkg/templates"
+)
+
+// exportsListed caps the exports shown on the dashboard and submissions page
+const exportsListed = 10
+
+// defaultLinkTTL is how long the download links handlers redirect to last,
+// unless a handler's LinkTTL says otherwise
+const defaultLinkTTL = 5 * time.Minute
+
+// ExportsHandler queues exports of submissions and serves the finished files
+type ExportsHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+	Exporter  *export.Exporter
+	// LinkTTL is how long the store's links that downloads redirect to last
+	LinkTTL time.Duration
+}
+
+// NewExportsHandler creates a new exports handler
+func NewExportsHandler(db *database.Database, tm *templates.TemplateManager, exporter *export.Exporter) *ExportsHandler {
+	return &ExportsHandler{
+		DB:        db,
+		Templates: tm,
+		Exporter:  exporter,
+		LinkTTL:   defaultLinkTTL,
+	}
+}
+
+// CreateExport queues an export of a form's submissions in the format
+// posted and renders the form's exports
+func (h *ExportsHandler) CreateExport(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	format := r.FormValue("format")
+	if !models.IsValidExportFormat(format) {
+		http.Error(w, "Invalid export format", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.CreateExportJobContext(r.Context(), h.DB.Connection, user.ID, form.ID, format); err != nil {
+		h.renderExports(w, r, user, form.ID, "", "Failed to queue export")
+		return
+	}
+	h.Exporter.Wake()
+	h.renderExports(w, r, user, form.ID, "Export queued. We'll email you a download link when it's ready.", "")
+}
+
+// FormExports renders a form's recent exports
+func (h *ExportsHandler) FormExports(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+	h.renderExports(w, r, user, form.ID, "", "")
+}
+
+// DashboardExports renders the user's recent exports of all their forms
+func (h *ExportsHandler) DashboardExports(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.renderExports(w, r, user, 0, "", "")
+}
+
+// renderExports renders the exports partial for one form, or every form
+// when formID is 0. It polls while any export is still being written.
+func (h *ExportsHandler) renderExports(w http.ResponseWriter, r *http.Request, user *models.User, formID int64, message, errorMsg string) {
+	jobs, err := models.GetExportJobsByUserIDContext(r.Context(), h.DB.Connection, user.ID, formID, exportsListed)
+	if err != nil {
+		http.Error(w, "Failed to fetch exports", http.StatusInternalServerError)
+		return
+	}
+
+	active := false
+	links := make(map[int64]string, len(jobs))
+	for i := range jobs {
+		switch jobs[i].Status {
+		case models.ExportStatusPending, models.ExportStatusRunning:
+			active = true
+		case models.ExportStatusDone:
+			links[jobs[i].ID] = h.Exporter.DownloadPath(&jobs[i])
+		}
+	}
+
+	url := "/dashboard/exports"
+	if formID != 0 {
+		url = fmt.Sprintf("/forms/%d/exports", formID)
+	}
+
+	if err := h.Templates.Render(w, "partials/exports.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Jobs":     jobs,
+			"Links":    links,
+			"Active":   active,
+			"URL":      url,
+			"AllForms": formID == 0,
+			"Message":  message,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// Download serves a finished export to anyone with a valid signed link, so
+// links in emails work without signing in
+func (h *ExportsHandler) Download(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+	if !h.Exporter.Verify(jobID, r.URL.Query().Get("expires"), r.URL.Query().Get("signature")) {
+		http.Error(w, "This download link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	job, err := models.GetExportJobByIDContext(r.Context(), h.DB.Connection, jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch export", http.StatusInternalServerError)
+		return
+	}
+	if job == nil || job.Status != models.ExportStatusDone || job.Expired(time.Now()) {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+
+	// Stores that make their own links serve the file, straight from the
+	// bucket for S3, so large exports never pass through the app
+	filename := fmt.Sprintf("form-%d-submissions.%s", job.FormID, job.Format)
+	link, err := h.Exporter.Store().SignedURL(r.Context(), job.FileName, filename, h.LinkTTL)
+	if err == nil {
+		http.Redirect(w, r, link, http.StatusFound)
+		return
+	}
+	if !errors.Is(err, storage.ErrNoLinks) {
+		log.Printf("Failed to sign a link to export %s: %v", job.FileName, err)
+		http.Error(w, "Failed to download export", http.StatusInternalServerError)
+		return
+	}
+
+	file, err := h.Exporter.Store().Open(r.Context(), job.FileName)
+	if err != nil {
+		log.Printf("Failed to open export %s: %v", job.FileName, err)
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	contentType := "text/csv; charset=utf-8"
+	if job.Format == models.ExportFormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(job.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if _, err := io.Copy(w, file); err != nil {
+		// The response has already started, so the download is cut short
+		log.Printf("Failed to send export %s: %v", job.FileName, err)
+	}
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/export"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+	"staticsend/pkg/templates"
+)
+
+func TestExports(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "export-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	models.CreateSubmission(db.Connection, form.ID, "203.0.113.9", "Browser", json.RawMessage(`{"message":"hello"}`))
+
+	dir := filepath.Join(t.TempDir(), "exports")
+	store, err := storage.NewLocalStore(dir, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	// No workers, so queued emails are never sent
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25}, 10, 0, 0)
+	exporter := export.NewExporter(db, store, emailService, []byte("secret"), time.Hour, func() string { return "" })
+	handler := NewExportsHandler(db, templates.NewTemplateManager(), exporter)
+
+	serve := func(h http.HandlerFunc, user *models.User, method, target, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey, user)
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+	formID := strconv.FormatInt(form.ID, 10)
+
+	t.Run("queue", func(t *testing.T) {
+		if rr := serve(handler.CreateExport, owner, "POST", "/?format=xml", formID); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for an unknown format, got %d", rr.Code)
+		}
+		if rr := serve(handler.CreateExport, other, "POST", "/?format=csv", formID); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+		}
+
+		rr := serve(handler.CreateExport, owner, "POST", "/?format=csv", formID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		body := rr.Body.String()
+		for _, want := range []string{"Export queued", "Queued", `hx-trigger="every 3s"`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %q in the exports", want)
+			}
+		}
+		if jobs, _ := models.GetExportJobsByUserID(db.Connection, other.ID, 0, 10); len(jobs) != 0 {
+			t.Errorf("Expected no exports for the other user, got %d", len(jobs))
+		}
+	})
+
+	if _, err := exporter.Run(context.Background()); err != nil {
+		t.Fatalf("Failed to run exports: %v", err)
+	}
+	jobs, _ := models.GetExportJobsByUserID(db.Connection, owner.ID, form.ID, 10)
+	link := exporter.DownloadPath(&jobs[0])
+
+	t.Run("list", func(t *testing.T) {
+		rr := serve(handler.DashboardExports, owner, "GET", "/", "")
+		body := rr.Body.String()
+		if !strings.Contains(body, "Contact") || !strings.Contains(body, "1 submissions") {
+			t.Errorf("Expected the finished export on the dashboard, got %s", body)
+		}
+		if strings.Contains(body, "every 3s") {
+			t.Error("Expected no polling once every export is finished")
+		}
+		if !strings.Contains(body, strings.ReplaceAll(link, "&", "&amp;")) {
+			t.Error("Expected a download link")
+		}
+	})
+
+	t.Run("download", func(t *testing.T) {
+		rr := serve(handler.Download, nil, "GET", link, strconv.FormatInt(jobs[0].ID, 10))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+			t.Errorf("Expected a CSV download, got %q", got)
+		}
+		if !strings.Contains(rr.Body.String(), "hello") {
+			t.Error("Expected the submission in the download")
+		}
+
+		tampered, _ := url.Parse(link)
+		query := tampered.Query()
+		query.Set("signature", strings.Repeat("0", 64))
+		tampered.RawQuery = query.Encode()
+		if rr := serve(handler.Download, nil, "GET", tampered.String(), strconv.FormatInt(jobs[0].ID, 10)); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for a bad signature, got %d", rr.Code)
+		}
+	})
+
+	t.Run("store link", func(t *testing.T) {
+		// Stores that make links serve downloads themselves
+		linked, err := storage.NewLocalStore(dir, "/files/exports", []byte("secret"))
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		handler := NewExportsHandler(db, templates.NewTemplateManager(),
+			export.NewExporter(db, linked, nil, []byte("secret"), time.Hour, func() string { return "" }))
+
+		rr := serve(handler.Download, nil, "GET", link, strconv.FormatInt(jobs[0].ID, 10))
+		if rr.Code != http.StatusFound || !strings.HasPrefix(rr.Header().Get("Location"), "/files/exports/export-") {
+			t.Fatalf("Expected a redirect to the store's link, got %d %q", rr.Code, rr.Header().Get("Location"))
+		}
+		download := httptest.NewRecorder()
+		linked.ServeHTTP(download, httptest.NewRequest("GET", rr.Header().Get("Location"), nil))
+		if download.Code != http.StatusOK || !strings.Contains(download.Body.String(), "hello") {
+			t.Errorf("Expected the store to serve the export, got %d: %s", download.Code, download.Body.String())
+		}
+		if got := download.Header().Get("Content-Disposition"); !strings.Contains(got, "form-"+formID+"-submissions.csv") {
+			t.Errorf("Expected the export's download name, got %q", got)
+		}
+	})
+}
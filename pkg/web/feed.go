@@ -0,0 +1,281 @@
+package web
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/models"
+	"staticsend/pkg/sanitize"
+	"staticsend/pkg/templates"
+	"staticsend/pkg/utils"
+)
+
+// feedSize is how many of a form's latest submissions its feed lists
+const feedSize = 50
+
+// feedEntry is a submission in a form's feed, whichever format it's read in
+type feedEntry struct {
+	ID      string
+	Title   string
+	Link    string
+	Text    string
+	Created time.Time
+}
+
+// atomFeed is a feed in the Atom format, RFC 4287
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// rssFeed is a feed in the RSS 2.0 format
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// AtomFeed serves a form's latest submissions as an Atom feed to anyone
+// with its token
+func (h *WebHandler) AtomFeed(w http.ResponseWriter, r *http.Request) {
+	form, link, entries, ok := h.feedEntries(w, r)
+	if !ok {
+		return
+	}
+
+	feed := atomFeed{
+		Title:   form.Name + " submissions",
+		ID:      link,
+		Updated: form.CreatedAt.UTC().Format(time.RFC3339),
+		Author:  "staticSend",
+		Link:    atomLink{Href: link},
+	}
+	if len(entries) > 0 {
+		feed.Updated = entries[0].Created.UTC().Format(time.RFC3339)
+	}
+	for _, entry := range entries {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   entry.Title,
+			ID:      entry.ID,
+			Updated: entry.Created.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: entry.Link},
+			Content: atomContent{Type: "text", Text: entry.Text},
+		})
+	}
+	writeFeed(w, "application/atom+xml; charset=utf-8", feed)
+}
+
+// RSSFeed serves a form's latest submissions as an RSS feed to anyone with
+// its token
+func (h *WebHandler) RSSFeed(w http.ResponseWriter, r *http.Request) {
+	form, link, entries, ok := h.feedEntries(w, r)
+	if !ok {
+		return
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       form.Name + " submissions",
+			Link:        link,
+			Description: "The latest submissions to " + form.Name,
+		},
+	}
+	if len(entries) > 0 {
+		feed.Channel.LastBuildDate = entries[0].Created.UTC().Format(time.RFC1123Z)
+	}
+	for _, entry := range entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{ID: entry.ID},
+			PubDate:     entry.Created.UTC().Format(time.RFC1123Z),
+			Description: sanitize.HTMLWithBreaks(entry.Text),
+		})
+	}
+	writeFeed(w, "application/rss+xml; charset=utf-8", feed)
+}
+
+// feedEntries loads the form whose feed token is in the URL and its latest
+// submissions, newest first, with the link to its submissions page. It
+// writes an error response and returns false if there's no such feed.
+func (h *WebHandler) feedEntries(w http.ResponseWriter, r *http.Request) (*models.Form, string, []feedEntry, bool) {
+	feed, err := models.GetFeedByTokenContext(r.Context(), h.DB.Connection, chi.URLParam(r, "token"))
+	if err != nil {
+		http.Error(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+	if feed == nil {
+		http.NotFound(w, r)
+		return nil, "", nil, false
+	}
+
+	form, err := models.GetFormByIDContext(r.Context(), h.DB.Connection, feed.FormID)
+	if err != nil || form == nil {
+		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+	submissions, err := models.GetSubmissionsPageContext(r.Context(), h.DB.Connection, form.ID, models.SubmissionFilter{}, feedSize, 0)
+	if err != nil {
+		http.Error(w, "Failed to fetch submissions", http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+
+	link := fmt.Sprintf("%s/forms/%d/submissions", h.TemplateManager.BaseURL(), form.ID)
+	entries := make([]feedEntry, 0, len(submissions))
+	for i := range submissions {
+		submission := &submissions[i]
+		// Entries are still listed for data that can't be read
+		fields, _ := submissionFields(submission.SubmittedData)
+
+		values := make(map[string]string, len(fields))
+		var text strings.Builder
+		for _, field := range fields {
+			if field.Name == models.HoneypotField {
+				continue
+			}
+			values[field.Name] = strings.Join(field.Values, ", ")
+			fmt.Fprintf(&text, "%s: %s\n", field.Name, values[field.Name])
+		}
+		title := fmt.Sprintf("Submission #%d", submission.ID)
+		if values["name"] != "" {
+			title = "Submission from " + values["name"]
+		} else if values["email"] != "" {
+			title = "Submission from " + values["email"]
+		}
+
+		entries = append(entries, feedEntry{
+			ID:      fmt.Sprintf("%s/%d", link, submission.ID),
+			Title:   title,
+			Link:    link,
+			Text:    strings.TrimSuffix(text.String(), "\n"),
+			Created: submission.CreatedAt,
+		})
+	}
+	return form, link, entries, true
+}
+
+// writeFeed writes a feed as XML
+func writeFeed(w http.ResponseWriter, contentType string, feed interface{}) {
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to render feed", http.StatusInternalServerError)
+		return
+	}
+	// Feeds hold submitted data, so they're kept out of search engines and
+	// shared caches
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// FeedSettings renders whether a form has a submissions feed, with its
+// links
+func (h *WebHandler) FeedSettings(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+	h.renderFeedSettings(w, r, user, form, "")
+}
+
+// EnableFeed gives a form a submissions feed at a new token
+func (h *WebHandler) EnableFeed(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := utils.GenerateFormKey()
+	if err != nil {
+		h.renderFeedSettings(w, r, user, form, "Failed to generate a link")
+		return
+	}
+	if _, err := models.EnableFeedContext(r.Context(), h.DB.Connection, form.ID, token); err != nil {
+		h.renderFeedSettings(w, r, user, form, "Failed to create the feed")
+		return
+	}
+	h.renderFeedSettings(w, r, user, form, "")
+}
+
+// DisableFeed removes a form's submissions feed
+func (h *WebHandler) DisableFeed(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DisableFeedContext(r.Context(), h.DB.Connection, form.ID); err != nil {
+		h.renderFeedSettings(w, r, user, form, "Failed to turn off the feed")
+		return
+	}
+	h.renderFeedSettings(w, r, user, form, "")
+}
+
+// renderFeedSettings renders the feed section of the form modal
+func (h *WebHandler) renderFeedSettings(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, errorMsg string) {
+	feed, err := models.GetFeedByFormIDContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to fetch feed"
+	}
+
+	if err := h.TemplateManager.Render(w, "partials/feed.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Form": form,
+			"Feed": feed,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
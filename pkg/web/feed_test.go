@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestFeed(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "feed-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	first, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", []byte(`{"name":"Ann","email":"ann@example.com","message":"Hi <there>"}`))
+	spam, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", []byte(`{"message":"buy now"}`))
+	models.SetSubmissionSpam(db.Connection, spam.ID, true)
+
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(h http.HandlerFunc, user *models.User, method, param, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add(param, value)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey, user)
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+	formID := strconv.FormatInt(form.ID, 10)
+
+	if rr := serve(handler.EnableFeed, other, "POST", "id", formID); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user, got %d", rr.Code)
+	}
+	rr := serve(handler.EnableFeed, owner, "POST", "id", formID)
+	feed, _ := models.GetFeedByFormID(db.Connection, form.ID)
+	if feed == nil {
+		t.Fatal("Expected the feed to be created")
+	}
+	if !strings.Contains(rr.Body.String(), "/feeds/"+feed.Token+"/atom") {
+		t.Errorf("Expected the feed's links in the response")
+	}
+
+	t.Run("atom", func(t *testing.T) {
+		rr := serve(handler.AtomFeed, nil, "GET", "token", feed.Token)
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/atom+xml") {
+			t.Fatalf("Expected an Atom feed, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+		}
+		var got atomFeed
+		if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Failed to parse feed: %v", err)
+		}
+		if len(got.Entries) != 1 {
+			t.Fatalf("Expected the submission without the spam, got %d entries", len(got.Entries))
+		}
+		entry := got.Entries[0]
+		if entry.Title != "Submission from Ann" || !strings.Contains(entry.Content.Text, "message: Hi <there>") {
+			t.Errorf("Unexpected entry %+v", entry)
+		}
+		if !strings.HasSuffix(entry.ID, "/forms/"+formID+"/submissions/"+strconv.FormatInt(first.ID, 10)) {
+			t.Errorf("Expected an ID per submission, got %s", entry.ID)
+		}
+	})
+
+	t.Run("rss", func(t *testing.T) {
+		rr := serve(handler.RSSFeed, nil, "GET", "token", feed.Token)
+		var got rssFeed
+		if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Failed to parse feed: %v", err)
+		}
+		if len(got.Channel.Items) != 1 || !strings.Contains(got.Channel.Items[0].Description, "Hi &lt;there&gt;<br>") {
+			t.Errorf("Expected the submission as HTML, got %+v", got.Channel.Items)
+		}
+	})
+
+	t.Run("turn off", func(t *testing.T) {
+		if rr := serve(handler.AtomFeed, nil, "GET", "token", "unknown"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for an unknown token, got %d", rr.Code)
+		}
+		serve(handler.DisableFeed, owner, "DELETE", "id", formID)
+		if rr := serve(handler.RSSFeed, nil, "GET", "token", feed.Token); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 once turned off, got %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+
+	"staticsend/pkg/models"
+	"staticsend/pkg/snippet"
+	"staticsend/pkg/templates"
+)
+
+// catalogField is a row of a form's field catalog, with the input suggested
+// for it
+type catalogField struct {
+	models.FormField
+	// Percent is the share of submissions the field was filled in on
+	Percent int
+	Input   snippet.Field
+}
+
+// FormFields renders the fields a form has received, how often and with
+// what type of value, and the input and validation suggested for each
+func (h *WebHandler) FormFields(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	catalog, err := models.GetFieldCatalogContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to load fields", http.StatusInternalServerError)
+		return
+	}
+	fields := make([]catalogField, 0, len(catalog.Fields))
+	for _, field := range catalog.Fields {
+		row := catalogField{FormField: field, Input: snippet.Suggest(field, catalog.Submissions)}
+		if catalog.Submissions > 0 {
+			row.Percent = field.SubmissionCount * 100 / catalog.Submissions
+		}
+		fields = append(fields, row)
+	}
+
+	if err := h.TemplateManager.Render(w, "partials/form_fields.html", templates.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Form":        form,
+			"Submissions": catalog.Submissions,
+			"Fields":      fields,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
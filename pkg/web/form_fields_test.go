@@ -0,0 +1,77 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestWebHandler_FormFields(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "fields-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(user *models.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/forms/1/fields", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		handler.FormFields(rr, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx)))
+		return rr
+	}
+
+	rr := serve(user)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "No fields yet") {
+		t.Fatalf("Expected no fields, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	for _, data := range []string{
+		`{"email":"jo@example.com","phone":"+61 2 9876 5432"}`,
+		`{"email":"sam@example.com"}`,
+	} {
+		submission, err := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(data))
+		if err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+		if err := models.RecordFormFields(db.Connection, form.ID, submission.SubmittedData, submission.CreatedAt); err != nil {
+			t.Fatalf("Failed to catalog fields: %v", err)
+		}
+	}
+
+	body := serve(user).Body.String()
+	for _, want := range []string{
+		"2 submissions",
+		"email", "100%", "type=&#34;email&#34; required",
+		"phone", "50%", "type=&#34;tel&#34;",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the fields to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	if rr := serve(other); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// FormCreated renders a new form's dashboard row for HTMX to add to the top
+// of the forms table, closing the modal it was created from
+func (h *WebHandler) FormCreated(w http.ResponseWriter, r *http.Request, form *models.Form, message string) {
+	w.Header().Set("HX-Retarget", "#forms-body")
+	w.Header().Set("HX-Reswap", "afterbegin")
+	w.Header().Set("HX-Trigger", "closeModal")
+	h.renderFormRow(w, r, form, message, http.StatusCreated)
+}
+
+// FormUpdated renders a changed form's dashboard row in place of the old one
+func (h *WebHandler) FormUpdated(w http.ResponseWriter, r *http.Request, form *models.Form, message string) {
+	w.Header().Set("HX-Retarget", fmt.Sprintf("#form-row-%d", form.ID))
+	w.Header().Set("HX-Reswap", "outerHTML")
+	w.Header().Set("HX-Trigger", "closeModal")
+	h.renderFormRow(w, r, form, message, http.StatusOK)
+}
+
+// FormDeleted removes a deleted form's row from the dashboard
+func (h *WebHandler) FormDeleted(w http.ResponseWriter, r *http.Request, form *models.Form, message string) {
+	w.Header().Set("HX-Retarget", fmt.Sprintf("#form-row-%d", form.ID))
+	w.Header().Set("HX-Reswap", "delete")
+	h.renderFormRow(w, r, nil, message, http.StatusOK)
+}
+
+// renderFormRow renders a form's dashboard row, or nothing when form is nil,
+// along with out-of-band updates to the flash message and form count
+func (h *WebHandler) renderFormRow(w http.ResponseWriter, r *http.Request, form *models.Form, message string, status int) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var forms []*models.Form
+	if form != nil {
+		count, err := models.GetSubmissionCountByFormIDContext(r.Context(), h.DB.Connection, form.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch submission count", http.StatusInternalServerError)
+			return
+		}
+		tags, err := models.GetFormTagsContext(r.Context(), h.DB.Connection, form.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+			return
+		}
+		emailBounce, err := models.GetEmailBounceContext(r.Context(), h.DB.Connection, form.ForwardEmail)
+		if err != nil {
+			http.Error(w, "Failed to fetch email bounces", http.StatusInternalServerError)
+			return
+		}
+		form.SubmissionCount = count
+		form.Tags = tags
+		form.EmailBounce = emailBounce
+		forms = []*models.Form{form}
+	}
+
+	formCount, err := models.GetFormCountByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch form count", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	if err := h.TemplateManager.Render(w, "partials/dashboard_forms.html", templates.TemplateData{
+		User:  user,
+		Flash: message,
+		Forms: forms,
+		Data: map[string]interface{}{
+			"Page":      0,
+			"Changed":   true,
+			"FormCount": formCount,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
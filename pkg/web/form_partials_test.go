@@ -0,0 +1,110 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/api"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestFormPartials(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	formHandler := api.NewFormHandler(db, NewWebHandler(db, templates.NewTemplateManager(), ""))
+
+	serve := func(h http.HandlerFunc, method, id string, values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+	values := url.Values{
+		"name":             {"Contact"},
+		"domain":           {"example.com"},
+		"turnstile_secret": {"secret"},
+		"forward_email":    {"to@example.com"},
+	}
+
+	var formID string
+	t.Run("create adds a row", func(t *testing.T) {
+		rr := serve(formHandler.CreateForm, "POST", "", values)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+		forms, _ := models.GetFormsByUserID(db.Connection, user.ID)
+		if len(forms) != 1 {
+			t.Fatalf("Expected 1 form, got %d", len(forms))
+		}
+		formID = strconv.FormatInt(forms[0].ID, 10)
+
+		if got := rr.Header().Get("HX-Retarget"); got != "#forms-body" {
+			t.Errorf("Expected the row to be added to the forms table, got %q", got)
+		}
+		if rr.Header().Get("HX-Redirect") != "" {
+			t.Error("Expected no redirect")
+		}
+		body := rr.Body.String()
+		for _, want := range []string{`id="form-row-` + formID + `"`, `id="forms-empty" hx-swap-oob="delete"`, `Form &#34;Contact&#34; created`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected the response to contain %q", want)
+			}
+		}
+		if !strings.Contains(body, `id="form-count" hx-swap-oob="true" class="text-3xl font-bold text-gray-900">1</p>`) {
+			t.Error("Expected the form count to be updated")
+		}
+	})
+
+	t.Run("edit replaces the row", func(t *testing.T) {
+		values.Set("name", "Support")
+		rr := serve(formHandler.UpdateForm, "PUT", formID, values)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("HX-Retarget"); got != "#form-row-"+formID {
+			t.Errorf("Expected the form's row to be replaced, got %q", got)
+		}
+		if !strings.Contains(rr.Body.String(), "Support") {
+			t.Error("Expected the row to show the new name")
+		}
+	})
+
+	t.Run("delete removes the row", func(t *testing.T) {
+		rr := serve(formHandler.DeleteForm, "DELETE", formID, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("HX-Reswap"); got != "delete" {
+			t.Errorf("Expected the row to be deleted, got %q", got)
+		}
+		body := rr.Body.String()
+		if strings.Contains(body, fmt.Sprintf(`id="form-row-%s"`, formID)) {
+			t.Error("Expected no row in the response")
+		}
+		if !strings.Contains(body, "created any forms yet") {
+			t.Error("Expected the empty state to be shown")
+		}
+	})
+}
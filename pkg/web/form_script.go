@@ -0,0 +1,53 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"staticsend/pkg/snippet"
+)
+
+// FormScriptHandler serves the script that makes forms on other sites
+// submit without leaving the page. It's generated once, at startup.
+type FormScriptHandler struct {
+	content []byte
+	hash    string
+	modTime time.Time
+}
+
+// NewFormScriptHandler generates the form script for a build
+func NewFormScriptHandler(version string) (*FormScriptHandler, error) {
+	content, err := snippet.Script(snippet.ScriptOptions{Version: version})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	return &FormScriptHandler{
+		content: content,
+		hash:    hex.EncodeToString(sum[:])[:16],
+		modTime: time.Now(),
+	}, nil
+}
+
+// ServeHTTP serves the script. URLs with the current ?v= are cached for
+// good; others are checked against the ETag, as pages embedding the plain
+// URL should get a new build's script.
+func (h *FormScriptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("ETag", `"`+h.hash+`"`)
+	if r.URL.Query().Get("v") == h.hash {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, no-cache")
+	}
+	http.ServeContent(w, r, "staticsend.js", h.modTime, bytes.NewReader(h.content))
+}
+
+// URL returns the script's path with its version, for pages on other sites
+// to load it from
+func (h *FormScriptHandler) URL() string {
+	return snippet.ScriptPath + "?v=" + h.hash
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormScriptHandler(t *testing.T) {
+	h, err := NewFormScriptHandler("v1.2.0")
+	if err != nil {
+		t.Fatalf("Failed to generate the script: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/static/js/staticsend.js", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "staticSend form script v1.2.0") {
+		t.Fatalf("Expected the script, got %d %s", rr.Code, rr.Body.String())
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/javascript") || rr.Header().Get("Cache-Control") != "public, no-cache" {
+		t.Errorf("Expected JavaScript checked before reuse, got %v", rr.Header())
+	}
+
+	// The versioned URL is cached for good
+	if !strings.HasPrefix(h.URL(), "/static/js/staticsend.js?v=") {
+		t.Fatalf("Expected a versioned URL, got %q", h.URL())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", h.URL(), nil))
+	if !strings.Contains(rr.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("Expected the versioned script cached for good, got %q", rr.Header().Get("Cache-Control"))
+	}
+
+	r := httptest.NewRequest("GET", "/static/js/staticsend.js", nil)
+	r.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for the same script, got %d", rr.Code)
+	}
+}
@@ -1,41 +1,72 @@
 package web
 
 import (
-	"database/sql"
+	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/livesettings"
+	"staticsend/pkg/flash"
+	"staticsend/pkg/httperr"
 	"staticsend/pkg/middleware"
 	"staticsend/pkg/models"
 	"staticsend/pkg/templates"
+	"staticsend/pkg/unsubscribe"
 )
 
 // WebHandler handles web page requests
 type WebHandler struct {
-	DB                     *sql.DB
+	DB                     *database.Database
 	TemplateManager        *templates.TemplateManager
 	AuthTurnstilePublicKey string
+	// Settings, when set, replaces the Turnstile site key with the one
+	// saved on the settings page
+	Settings *livesettings.Store
+	// Honeypot is the honeypot field added to forms' code
+	Honeypot string
+	// FormScript versions the form script's URL in forms' code
+	FormScript *FormScriptHandler
+	// Unsubscribe checks the links notification emails' recipients mute
+	// forms with; without it the links aren't found
+	UnsubscribeLinks *unsubscribe.Links
+
+	counts *submissionCounts
 }
 
 // NewWebHandler creates a new web handler
-func NewWebHandler(db *sql.DB, tm *templates.TemplateManager, authTurnstilePublicKey string) *WebHandler {
+func NewWebHandler(db *database.Database, tm *templates.TemplateManager, authTurnstilePublicKey string) *WebHandler {
 	return &WebHandler{
 		DB:                     db,
 		TemplateManager:        tm,
 		AuthTurnstilePublicKey: authTurnstilePublicKey,
+		Honeypot:               models.HoneypotField,
+		counts:                 newSubmissionCounts(submissionCountTTL),
 	}
 }
 
 
 
+// turnstilePublicKey returns the Turnstile site key in use for the sign in
+// pages
+func (h *WebHandler) turnstilePublicKey() string {
+	if h.Settings == nil {
+		return h.AuthTurnstilePublicKey
+	}
+	return h.Settings.Current().TurnstilePublicKey
+}
+
 // LoginPage renders the login page
 func (h *WebHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
 	data := templates.TemplateData{
 		Title:                  "Login - staticSend",
 		ShowHeader:             false,
-		AuthTurnstilePublicKey: h.AuthTurnstilePublicKey,
+		AuthTurnstilePublicKey: h.turnstilePublicKey(),
 	}
 	
 	if err := h.TemplateManager.Render(w, "auth/login.html", data); err != nil {
@@ -48,7 +79,7 @@ func (h *WebHandler) RegisterPage(w http.ResponseWriter, r *http.Request) {
 	data := templates.TemplateData{
 		Title:                  "Register - staticSend",
 		ShowHeader:             false,
-		AuthTurnstilePublicKey: h.AuthTurnstilePublicKey,
+		AuthTurnstilePublicKey: h.turnstilePublicKey(),
 	}
 	
 	if err := h.TemplateManager.Render(w, "auth/register.html", data); err != nil {
@@ -64,41 +95,115 @@ func (h *WebHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch user's forms from database
-	forms, err := models.GetFormsByUserID(h.DB, user.ID)
+	formCount, err := models.GetFormCountByUserIDContext(r.Context(), h.DB.Connection, user.ID)
 	if err != nil {
 		http.Error(w, "Failed to fetch forms", http.StatusInternalServerError)
 		return
 	}
+	tags, err := models.GetTagsByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+		return
+	}
 
-	// Convert to pointer slice for template
-	formPtrs := make([]*models.Form, len(forms))
-	for i := range forms {
-		formPtrs[i] = &forms[i]
+	// The form count covers every form; the list only includes forms with
+	// the selected tag and is loaded a page at a time by DashboardForms.
+	// Submission charts are loaded by ActivityStats.
+	data := templates.DefaultTemplateData()
+	data.Title = "Dashboard - staticSend"
+	data.User = user
+	data.Flash = flash.Pop(w, r)
+	data.Stats.FormCount = formCount
+	data.Data = map[string]interface{}{
+		"Tags": tags,
+		"Tag":  strings.ToLower(r.URL.Query().Get("tag")),
 	}
 
-	// Get submission count for each form
-	for _, form := range formPtrs {
-		count, err := models.GetSubmissionCountByFormID(h.DB, form.ID)
-		if err == nil {
-			form.SubmissionCount = count
+	// Show usage counters when the user has limits
+	quota, err := models.GetUserQuotaContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch usage limits", http.StatusInternalServerError)
+		return
+	}
+	if !quota.Unlimited() {
+		usage, err := models.GetUsageContext(r.Context(), h.DB.Connection, user.ID, time.Now())
+		if err != nil {
+			http.Error(w, "Failed to fetch usage", http.StatusInternalServerError)
+			return
 		}
+		data.Stats.Quota = quota
+		data.Stats.Usage = usage
 	}
 
-	// Get total submission count
-	totalSubmissions := 0
-	for _, form := range formPtrs {
-		totalSubmissions += form.SubmissionCount
+	if err := h.TemplateManager.Render(w, "dashboard/index.html", data); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
+}
 
-	data := templates.DefaultTemplateData()
-	data.Title = "Dashboard - staticSend"
-	data.User = user
-	data.Forms = formPtrs
-	data.Stats.FormCount = len(formPtrs)
-	data.Stats.SubmissionCount = totalSubmissions
+// dashboardPageSize is how many forms each page of the dashboard list loads
+const dashboardPageSize = 25
 
-	if err := h.TemplateManager.Render(w, "dashboard/index.html", data); err != nil {
+// DashboardForms renders a page of the dashboard's form list. Each page ends
+// with a row that loads the next one when it scrolls into view.
+func (h *WebHandler) DashboardForms(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	tag := strings.ToLower(r.URL.Query().Get("tag"))
+
+	// Fetch one extra form to tell whether there's another page
+	forms, err := models.GetFormsPageByUserIDContext(r.Context(), h.DB.Connection, user.ID, tag, dashboardPageSize+1, (page-1)*dashboardPageSize)
+	if err != nil {
+		http.Error(w, "Failed to fetch forms", http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(forms) > dashboardPageSize
+	if hasMore {
+		forms = forms[:dashboardPageSize]
+	}
+
+	counts, err := h.counts.get(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch submission counts", http.StatusInternalServerError)
+		return
+	}
+	formTags, err := models.GetFormTagsByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+		return
+	}
+	emailBounces, err := models.GetEmailBouncesByUserIDContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch email bounces", http.StatusInternalServerError)
+		return
+	}
+
+	formPtrs := make([]*models.Form, len(forms))
+	for i := range forms {
+		formPtrs[i] = &forms[i]
+		formPtrs[i].SubmissionCount = counts[forms[i].ID]
+		formPtrs[i].Tags = formTags[forms[i].ID]
+		formPtrs[i].EmailBounce = emailBounces[forms[i].ID]
+	}
+
+	data := templates.TemplateData{
+		User:  user,
+		Forms: formPtrs,
+		Data: map[string]interface{}{
+			"Tag":      tag,
+			"Page":     page,
+			"NextPage": page + 1,
+			"HasMore":  hasMore,
+		},
+	}
+	if err := h.TemplateManager.Render(w, "partials/dashboard_forms.html", data); err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
 }
@@ -135,28 +240,40 @@ func (h *WebHandler) ViewFormModal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch form from database
-	form, err := models.GetFormByID(h.DB, formID)
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
 	if err != nil {
 		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
 		return
 	}
 	if form == nil {
-		http.Error(w, "Form not found", http.StatusNotFound)
-		return
-	}
-
-	// Verify user owns this form
-	if form.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
 		return
 	}
 
 	// Get submission count
-	count, err := models.GetSubmissionCountByFormID(h.DB, form.ID)
+	count, err := models.GetSubmissionCountByFormIDContext(r.Context(), h.DB.Connection, form.ID)
 	if err == nil {
 		form.SubmissionCount = count
 	}
 
+	form.Tags, err = models.GetFormTagsContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+		return
+	}
+
+	form.EmailBounce, err = models.GetEmailBounceContext(r.Context(), h.DB.Connection, form.ForwardEmail)
+	if err != nil {
+		http.Error(w, "Failed to fetch email bounces", http.StatusInternalServerError)
+		return
+	}
+
+	form.MutedRecipients, err = models.GetMutedRecipientsContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch muted recipients", http.StatusInternalServerError)
+		return
+	}
+
 	data := templates.TemplateData{
 		Title: "View Form - " + form.Name,
 		Data:  form,
@@ -183,19 +300,19 @@ func (h *WebHandler) EditFormModal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch form from database
-	form, err := models.GetFormByID(h.DB, formID)
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
 	if err != nil {
 		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
 		return
 	}
 	if form == nil {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
 		return
 	}
 
-	// Verify user owns this form
-	if form.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	form.Tags, err = models.GetFormTagsContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
 		return
 	}
 
@@ -225,41 +342,54 @@ func (h *WebHandler) FormSubmissions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch form from database
-	form, err := models.GetFormByID(h.DB, formID)
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
 	if err != nil {
 		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
 		return
 	}
 	if form == nil {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
 		return
 	}
 
-	// Verify user owns this form
-	if form.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+	// Get submission count
+	count, err := models.GetSubmissionCountByFormIDContext(r.Context(), h.DB.Connection, form.ID)
+	if err == nil {
+		form.SubmissionCount = count
 	}
 
-	// Get submissions for this form
-	submissions, err := models.GetSubmissionsByFormID(h.DB, form.ID)
+	// Spam isn't listed with the other submissions
+	spamCount, err := models.CountSubmissionsContext(r.Context(), h.DB.Connection, form.ID, models.SubmissionFilter{Spam: true})
 	if err != nil {
-		http.Error(w, "Failed to fetch submissions", http.StatusInternalServerError)
-		return
+		log.Printf("Failed to count spam: %v", err)
 	}
 
-	// Get submission count
-	count, err := models.GetSubmissionCountByFormID(h.DB, form.ID)
-	if err == nil {
-		form.SubmissionCount = count
+	// Older submissions may have been moved to archive files
+	archivedCount, err := models.GetArchivedSubmissionCountContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		log.Printf("Failed to count archived submissions: %v", err)
+	}
+
+	code, err := h.formCode(r.Context(), form, false)
+	if err != nil {
+		log.Printf("Failed to generate form code: %v", err)
 	}
 
+	// The table is loaded separately, starting with the filters in the URL
+	query := submissionsQuery(r.URL.Query())
 	data := templates.DefaultTemplateData()
 	data.Title = "Submissions - " + form.Name + " - staticSend"
 	data.User = user
+	data.Flash = flash.Pop(w, r)
 	data.Data = map[string]interface{}{
-		"Form":        form,
-		"Submissions": submissions,
+		"Form":          form,
+		"ArchivedCount": archivedCount,
+		"SpamCount":     spamCount,
+		"Code":          code,
+		"Statuses":      models.SubmissionStatuses,
+		"InboxStatuses": models.InboxStatuses,
+		"Filters":       query,
+		"TableURL":      template.URL(fmt.Sprintf("/forms/%d/submissions/table?%s", form.ID, query.Encode())),
 	}
 
 	if err := h.TemplateManager.Render(w, "submissions/index.html", data); err != nil {
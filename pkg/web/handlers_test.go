@@ -1,8 +1,19 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"staticsend/pkg/database"
+	"staticsend/pkg/flash"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
 	"staticsend/pkg/templates"
 )
 
@@ -15,7 +26,7 @@ func TestWebHandler_NewWebHandler(t *testing.T) {
 	tm := &templates.TemplateManager{}
 
 	// Create handler
-	handler := NewWebHandler(db, tm, "test-public-key")
+	handler := NewWebHandler(&database.Database{Connection: db}, tm, "test-public-key")
 
 	if handler == nil {
 		t.Error("NewWebHandler should not return nil")
@@ -39,9 +50,219 @@ func TestWebHandler_WithoutTurnstile(t *testing.T) {
 	tm := &templates.TemplateManager{}
 
 	// Create handler without Turnstile key
-	handler := NewWebHandler(db, tm, "")
+	handler := NewWebHandler(&database.Database{Connection: db}, tm, "")
 
 	if handler.AuthTurnstilePublicKey != "" {
 		t.Errorf("Expected empty AuthTurnstilePublicKey, got '%s'", handler.AuthTurnstilePublicKey)
 	}
 }
+
+func TestWebHandler_DashboardTagFilter(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	tagged, _ := models.CreateForm(db.Connection, user.ID, "Tagged Form", "example.com", "secret", "to@example.com", "tagged-key")
+	models.CreateForm(db.Connection, user.ID, "Untagged Form", "example.com", "secret", "to@example.com", "untagged-key")
+	if err := models.SetFormTags(db.Connection, user.ID, tagged.ID, []string{"client-a"}); err != nil {
+		t.Fatalf("Failed to set tags: %v", err)
+	}
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	req := httptest.NewRequest("GET", "/dashboard?tag=Client-A", nil)
+	rr := httptest.NewRecorder()
+	handler.Dashboard(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "/dashboard/forms?page=1&tag=client-a") {
+		t.Error("Expected the form list to be loaded with the tag filter")
+	}
+	if !strings.Contains(body, "client-a (1)") {
+		t.Error("Expected the tag filter to be shown")
+	}
+
+	req = httptest.NewRequest("GET", "/dashboard/forms?tag=client-a", nil)
+	rr = httptest.NewRecorder()
+	handler.DashboardForms(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user)))
+	body = rr.Body.String()
+	if !strings.Contains(body, "Tagged Form") || strings.Contains(body, "Untagged Form") {
+		t.Error("Expected only the tagged form to be listed")
+	}
+}
+
+func TestWebHandler_DashboardFormsPagination(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	for i := 0; i < dashboardPageSize+1; i++ {
+		form, err := models.CreateForm(db.Connection, user.ID, fmt.Sprintf("Form %d", i), "example.com", "secret", "to@example.com", fmt.Sprintf("key-%d", i))
+		if err != nil {
+			t.Fatalf("Failed to create form: %v", err)
+		}
+		if i == 0 {
+			models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+		}
+	}
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	page := func(query string) string {
+		req := httptest.NewRequest("GET", "/dashboard/forms"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.DashboardForms(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	first := page("")
+	if got := strings.Count(first, "/submissions\""); got != dashboardPageSize {
+		t.Errorf("Expected %d forms on the first page, got %d", dashboardPageSize, got)
+	}
+	if !strings.Contains(first, `hx-get="/dashboard/forms?page=2"`) {
+		t.Error("Expected the first page to load the next one")
+	}
+
+	// Forms are newest first, so the oldest form and its submission are last
+	second := page("?page=2")
+	if !strings.Contains(second, "Form 0") || strings.Contains(second, "page=3") {
+		t.Errorf("Expected the last page to hold the oldest form, got %s", second)
+	}
+	if !strings.Contains(second, `<td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">1</td>`) {
+		t.Error("Expected the oldest form's submission to be counted")
+	}
+}
+
+func TestWebHandler_ActivityStats(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "stats-key")
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	models.CreateBlockedAttempt(db.Connection, &form.ID, nil, "203.0.113.1", "deny")
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(user *models.User, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/dashboard/stats"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.ActivityStats(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user)))
+		return rr
+	}
+
+	rr := serve(user, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, ">3</dd>") || !strings.Contains(body, ">1</dd>") || !strings.Contains(body, "25.0%") {
+		t.Errorf("Expected the totals and spam ratio, got %s", body)
+	}
+	if !strings.Contains(body, "last 30 days") || !strings.Contains(body, `hx-get="/dashboard/stats?days=90"`) {
+		t.Error("Expected 30 days by default with a link to 90")
+	}
+
+	body = serve(user, fmt.Sprintf("?form=%d&days=90", form.ID)).Body.String()
+	if !strings.Contains(body, "last 90 days") || !strings.Contains(body, fmt.Sprintf("form=%d&amp;days=30", form.ID)) {
+		t.Error("Expected the form's stats over 90 days")
+	}
+
+	if rr := serve(user, "?days=7"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unsupported range, got %d", rr.Code)
+	}
+	if rr := serve(other, fmt.Sprintf("?form=%d", form.ID)); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+	}
+}
+
+func TestChartPaths(t *testing.T) {
+	if got := chartLine([]int{0, 2, 1}, 2); got != "M0.0,60.0 L150.0,0.0 L300.0,30.0" {
+		t.Errorf("Unexpected line %q", got)
+	}
+	if got := chartArea([]int{1}, 1); got != "M0,60 L0.0,0.0 L300,60 Z" {
+		t.Errorf("Unexpected area %q", got)
+	}
+}
+
+func TestWebHandler_UpdateTheme(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	update := func(theme string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/account/theme", strings.NewReader("theme="+theme))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handler.UpdateTheme(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user)))
+		return rr
+	}
+
+	rr := update(models.ThemeDark)
+	if rr.Code != http.StatusOK || rr.Header().Get("HX-Refresh") != "true" {
+		t.Fatalf("Expected the page to be refreshed, got %d", rr.Code)
+	}
+	updated, _ := models.GetUserByID(db.Connection, user.ID)
+	if updated.Theme != models.ThemeDark {
+		t.Errorf("Expected theme %q, got %q", models.ThemeDark, updated.Theme)
+	}
+
+	// Pages come out dark for the user
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	rr = httptest.NewRecorder()
+	handler.Dashboard(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, updated)))
+	if !strings.Contains(rr.Body.String(), `class="dark"`) || !strings.Contains(rr.Body.String(), `<option value="dark" selected>`) {
+		t.Error("Expected the dashboard to be rendered with the dark theme")
+	}
+
+	if rr := update("purple"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown theme, got %d", rr.Code)
+	}
+}
+
+func TestWebHandler_DashboardFlash(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	set := httptest.NewRecorder()
+	flash.Set(set, "Settings saved")
+
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	for _, cookie := range set.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	handler.Dashboard(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user)))
+	if !strings.Contains(rr.Body.String(), "Settings saved") {
+		t.Error("Expected the flash message on the dashboard")
+	}
+	if cookies := rr.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("Expected the flash message to be cleared, got %+v", cookies)
+	}
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/models"
+	"staticsend/pkg/snippet"
+	"staticsend/pkg/templates"
+)
+
+// HostedForm renders a form's hosted page: the form's fields, the honeypot
+// and a Turnstile widget, submitted by the form script. Forms without their
+// hosted page turned on aren't found.
+func (h *WebHandler) HostedForm(w http.ResponseWriter, r *http.Request) {
+	form, err := models.GetFormByKeyContext(r.Context(), h.DB.Connection, chi.URLParam(r, "formKey"))
+	if err != nil {
+		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		return
+	}
+	if form == nil || !form.HostedPage {
+		http.NotFound(w, r)
+		return
+	}
+
+	inputs, err := h.formInputs(r.Context(), form)
+	if err != nil {
+		log.Printf("Failed to find fields for hosted form %d: %v", form.ID, err)
+		http.Error(w, "Failed to load form", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.TemplateManager.Render(w, "hosted/index.html", templates.TemplateData{
+		Title: form.Name,
+		Data: map[string]interface{}{
+			"FormName":  form.Name,
+			"Action":    snippet.SubmitPath + form.FormKey,
+			"Inputs":    inputs,
+			"Honeypot":  h.Honeypot,
+			"SiteKey":   form.TurnstileSiteKey,
+			"ScriptURL": h.formScriptURL(),
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
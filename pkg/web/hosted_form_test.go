@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestHostedForm(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Client Contact", "example.com", "secret", "to@example.com", "hosted-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+	handler.Honeypot = "_gotcha"
+	serve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/f/"+key, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("formKey", key)
+		rr := httptest.NewRecorder()
+		handler.HostedForm(rr, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		return rr
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		if rr := serve("hosted-key"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 before the page is turned on, got %d", rr.Code)
+		}
+		if rr := serve("missing-key"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for an unknown form, got %d", rr.Code)
+		}
+	})
+
+	models.SetFormHostedPage(db.Connection, form.ID, true)
+
+	t.Run("without a site key", func(t *testing.T) {
+		rr := serve("hosted-key")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		body := rr.Body.String()
+		if strings.Contains(body, "<form action") || !strings.Contains(body, "taking submissions yet") {
+			t.Errorf("Expected no form without a Turnstile site key")
+		}
+	})
+
+	models.SetFormTurnstileSiteKey(db.Connection, form.ID, "0x4AAAAAAA")
+
+	t.Run("default fields", func(t *testing.T) {
+		body := serve("hosted-key").Body.String()
+		for _, want := range []string{
+			"Client Contact",
+			`action="/api/v1/submit/hosted-key"`,
+			`type="email" id="field-email" name="email"`,
+			`<textarea id="field-message" name="message"`,
+			`name="_gotcha"`,
+			`data-sitekey="0x4AAAAAAA"`,
+			"/static/js/staticsend.js",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %q in the page", want)
+			}
+		}
+		if strings.Contains(body, "secret") {
+			t.Error("Expected the Turnstile secret to stay off the page")
+		}
+	})
+
+	t.Run("cataloged fields", func(t *testing.T) {
+		data := []byte(`{"company":"Acme","phone":"+64 21 555 0101"}`)
+		models.CreateSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", data)
+		if err := models.RecordFormFields(db.Connection, form.ID, data, time.Now()); err != nil {
+			t.Fatalf("Failed to catalog fields: %v", err)
+		}
+		body := serve("hosted-key").Body.String()
+		if !strings.Contains(body, `name="company"`) || !strings.Contains(body, `type="tel" id="field-phone"`) {
+			t.Errorf("Expected inputs for the cataloged fields")
+		}
+		if strings.Contains(body, `name="message"`) {
+			t.Errorf("Expected the default fields to be replaced")
+		}
+	})
+}
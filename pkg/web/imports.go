@@ -0,0 +1,125 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/importer"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// maxImportSize caps an uploaded export of submissions
+const maxImportSize = 32 << 20
+
+// ImportsHandler imports submissions exported from Formspree or Netlify
+// Forms into a form
+type ImportsHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+}
+
+// NewImportsHandler creates a new imports handler
+func NewImportsHandler(db *database.Database, tm *templates.TemplateManager) *ImportsHandler {
+	return &ImportsHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// ImportSubmissions imports the submissions in an uploaded CSV or JSON
+// export into a form, renaming fields as the posted mapping says, one
+// "from=to" per line. Nothing is imported if any submission can't be read.
+func (h *ImportsHandler) ImportSubmissions(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.renderImport(w, user, "", fmt.Sprintf("Exports are limited to %d MB", maxImportSize>>20))
+			return
+		}
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		h.renderImport(w, user, "", "Choose an exported CSV or JSON file")
+		return
+	}
+	defer file.Close()
+
+	mapping, err := importer.ParseMapping(strings.Split(r.FormValue("mapping"), "\n"))
+	if err != nil {
+		h.renderImport(w, user, "", err.Error())
+		return
+	}
+	submissions, err := importer.Read(file, mapping)
+	if err != nil {
+		h.renderImport(w, user, "", "Couldn't read the export: "+err.Error())
+		return
+	}
+	if len(submissions) == 0 {
+		h.renderImport(w, user, "", "The export has no submissions")
+		return
+	}
+
+	size := int64(0)
+	for _, submission := range submissions {
+		size += int64(len(submission.SubmittedData))
+	}
+	if over, err := overStorage(r.Context(), h.DB, form.UserID, size); err != nil {
+		http.Error(w, "Failed to check usage", http.StatusInternalServerError)
+		return
+	} else if over {
+		h.renderImport(w, user, "", "Importing these submissions would go over your storage limit")
+		return
+	}
+
+	if err := models.ImportSubmissionsContext(r.Context(), h.DB.Connection, form.ID, submissions); err != nil {
+		log.Printf("Failed to import submissions into form %d: %v", form.ID, err)
+		h.renderImport(w, user, "", "Failed to import submissions")
+		return
+	}
+	h.renderImport(w, user, fmt.Sprintf("Imported %d submissions.", len(submissions)), "")
+}
+
+// overStorage reports whether storing more bytes of submissions would take
+// a user over their storage limit. Monthly submission limits don't apply to
+// imports, since the submissions were received elsewhere.
+func overStorage(ctx context.Context, db *database.Database, userID, more int64) (bool, error) {
+	quota, err := models.GetUserQuotaContext(ctx, db.Connection, userID)
+	if err != nil || quota.MaxStorageBytes <= 0 {
+		return false, err
+	}
+	usage, err := models.GetUsageContext(ctx, db.Connection, userID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return usage.StorageBytes+more > quota.MaxStorageBytes, nil
+}
+
+// renderImport renders the result of an import
+func (h *ImportsHandler) renderImport(w http.ResponseWriter, user *models.User, message, errorMsg string) {
+	if err := h.Templates.Render(w, "partials/import_result.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Message": message,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestImportSubmissions(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "import-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	handler := NewImportsHandler(db, templates.NewTemplateManager())
+
+	upload := func(user *models.User, export, mapping string) *httptest.ResponseRecorder {
+		t.Helper()
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", "export.csv")
+		part.Write([]byte(export))
+		writer.WriteField("mapping", mapping)
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		handler.ImportSubmissions(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	export := "Date,Email Address,message\n2024-03-01 09:30:00,ada@example.com,Hello\n2024-03-02 10:00:00,grace@example.org,Hi\n"
+	if rr := upload(other, export, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+	}
+	if rr := upload(owner, "email\nada@example.com\n", ""); !strings.Contains(rr.Body.String(), "no date") {
+		t.Errorf("Expected an export without dates to be refused, got %s", rr.Body.String())
+	}
+
+	rr := upload(owner, export, "Email Address=email\r\n")
+	if !strings.Contains(rr.Body.String(), "Imported 2 submissions") {
+		t.Fatalf("Expected 2 submissions to be imported, got %d: %s", rr.Code, rr.Body.String())
+	}
+	submissions, _ := models.GetSubmissionsByFormID(db.Connection, form.ID)
+	if len(submissions) != 2 || !strings.Contains(string(submissions[0].SubmittedData), `"email":"grace@example.org"`) {
+		t.Errorf("Expected the renamed submissions, newest first, got %+v", submissions)
+	}
+
+	// Imports count towards the storage limit
+	limit := int64(10)
+	models.SetQuotaOverride(db.Connection, owner.ID, models.QuotaOverride{MaxStorageBytes: &limit})
+	if rr := upload(owner, export, ""); !strings.Contains(rr.Body.String(), "storage limit") {
+		t.Errorf("Expected the import to be refused over the storage limit, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,251 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// maxNoteLength caps the length of a submission note in characters
+const maxNoteLength = 5000
+
+// InboxHandler handles notes on and assignment of submissions, which turn a
+// form's submissions into a shared inbox
+type InboxHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+}
+
+// NewInboxHandler creates a new inbox handler
+func NewInboxHandler(db *database.Database, tm *templates.TemplateManager) *InboxHandler {
+	return &InboxHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// SubmissionInbox renders the notes and assignment partial for a submission
+func (h *InboxHandler) SubmissionInbox(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+	h.render(w, r, user, form, submission, "")
+}
+
+// UpdateAssignment sets who is handling a submission and its status
+func (h *InboxHandler) UpdateAssignment(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, user, form, submission, "Invalid form data")
+		return
+	}
+
+	status := r.FormValue("status")
+	if !models.IsValidInboxStatus(status) {
+		h.render(w, r, user, form, submission, "Invalid status")
+		return
+	}
+
+	var assigneeID *int64
+	if value := r.FormValue("assignee_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			h.render(w, r, user, form, submission, "Invalid assignee")
+			return
+		}
+		assignees, err := formAssignees(r.Context(), h.DB, form)
+		if err != nil {
+			h.render(w, r, user, form, submission, "Failed to fetch assignee")
+			return
+		}
+		for i := range assignees {
+			if assignees[i].ID == id {
+				assigneeID = &assignees[i].ID
+			}
+		}
+		if assigneeID == nil {
+			h.render(w, r, user, form, submission, "Assignee not found")
+			return
+		}
+	}
+
+	if err := models.SetAssignmentContext(r.Context(), h.DB.Connection, submission.ID, assigneeID, status); err != nil {
+		h.render(w, r, user, form, submission, "Failed to update assignment")
+		return
+	}
+
+	h.render(w, r, user, form, submission, "")
+}
+
+// CreateNote adds an internal note to a submission
+func (h *InboxHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, user, form, submission, "Invalid form data")
+		return
+	}
+
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		h.render(w, r, user, form, submission, "Note can't be empty")
+		return
+	}
+	if len([]rune(body)) > maxNoteLength {
+		h.render(w, r, user, form, submission, "Note is too long")
+		return
+	}
+
+	if _, err := models.CreateSubmissionNoteContext(r.Context(), h.DB.Connection, submission.ID, user.ID, body); err != nil {
+		h.render(w, r, user, form, submission, "Failed to add note")
+		return
+	}
+
+	h.render(w, r, user, form, submission, "")
+}
+
+// DeleteNote deletes a note. Only the note's author can delete it.
+func (h *InboxHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	noteID, err := strconv.ParseInt(chi.URLParam(r, "noteID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		return
+	}
+
+	note, err := models.GetSubmissionNoteByIDContext(r.Context(), h.DB.Connection, noteID)
+	if err != nil {
+		http.Error(w, "Failed to fetch note", http.StatusInternalServerError)
+		return
+	}
+	if note == nil || note.SubmissionID != submission.ID {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	if note.UserID == nil || *note.UserID != user.ID {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := models.DeleteSubmissionNoteContext(r.Context(), h.DB.Connection, note.ID); err != nil {
+		h.render(w, r, user, form, submission, "Failed to delete note")
+		return
+	}
+
+	h.render(w, r, user, form, submission, "")
+}
+
+// ownedSubmission loads the form and submission in the URL, checking that
+// the submission belongs to the form and that the user owns the form. It
+// writes an error response and returns false if not.
+func ownedSubmission(w http.ResponseWriter, r *http.Request, db *database.Database) (*models.User, *models.Form, *models.Submission, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return nil, nil, nil, false
+	}
+
+	formID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
+		return nil, nil, nil, false
+	}
+	submissionID, err := strconv.ParseInt(chi.URLParam(r, "submissionID"), 10, 64)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid submission ID")
+		return nil, nil, nil, false
+	}
+
+	form, err := models.GetFormByIDForUserContext(r.Context(), db.Connection, formID, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
+		return nil, nil, nil, false
+	}
+	if form == nil {
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return nil, nil, nil, false
+	}
+
+	submission, err := models.GetSubmissionForUserContext(r.Context(), db.Connection, submissionID, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch submission")
+		return nil, nil, nil, false
+	}
+	if submission == nil || submission.FormID != form.ID {
+		httperr.Write(w, r, http.StatusNotFound, httperr.NotFound, "Submission not found")
+		return nil, nil, nil, false
+	}
+
+	return user, form, submission, true
+}
+
+// formAssignees returns the users a form's submissions can be assigned to:
+// those who can open them, which is only the form's owner
+func formAssignees(ctx context.Context, db *database.Database, form *models.Form) ([]models.User, error) {
+	owner, err := models.GetUserByIDContext(ctx, db.Connection, form.UserID)
+	if err != nil || owner == nil {
+		return nil, err
+	}
+	return []models.User{*owner}, nil
+}
+
+// render renders the notes and assignment partial for a submission
+func (h *InboxHandler) render(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, submission *models.Submission, errorMsg string) {
+	assignment, err := models.GetAssignmentContext(r.Context(), h.DB.Connection, submission.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch assignment", http.StatusInternalServerError)
+		return
+	}
+	notes, err := models.GetSubmissionNotesContext(r.Context(), h.DB.Connection, submission.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch notes", http.StatusInternalServerError)
+		return
+	}
+	users, err := formAssignees(r.Context(), h.DB, form)
+	if err != nil {
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	// Templates can't compare pointers with IDs, so pass 0 for unassigned
+	var assigneeID int64
+	if assignment.AssigneeID != nil {
+		assigneeID = *assignment.AssigneeID
+	}
+
+	if err := h.Templates.Render(w, "partials/submission_inbox.html", templates.TemplateData{
+		Title: "Notes",
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Submission": submission,
+			"Assignment": assignment,
+			"AssigneeID": assigneeID,
+			"Notes":      notes,
+			"Users":      users,
+			"Statuses":   models.InboxStatuses,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
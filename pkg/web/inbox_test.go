@@ -0,0 +1,138 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestInboxHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, err := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	teammate, err := models.CreateUser(db.Connection, "teammate@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "inbox-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	submission, err := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	handler := NewInboxHandler(db, templates.NewTemplateManager())
+
+	serve := func(h http.HandlerFunc, user *models.User, method string, values url.Values, noteID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/forms/1/submissions/1", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		rctx.URLParams.Add("submissionID", strconv.FormatInt(submission.ID, 10))
+		rctx.URLParams.Add("noteID", strconv.FormatInt(noteID, 10))
+		ctx := context.WithValue(req.Context(), middleware.UserKey, user)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	t.Run("assign", func(t *testing.T) {
+		rr := serve(handler.UpdateAssignment, owner, "POST", url.Values{
+			"status":      {models.InboxStatusInProgress},
+			"assignee_id": {strconv.FormatInt(owner.ID, 10)},
+		}, 0)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		assignment, err := models.GetAssignment(db.Connection, submission.ID)
+		if err != nil {
+			t.Fatalf("Failed to get assignment: %v", err)
+		}
+		if assignment.Status != models.InboxStatusInProgress || assignment.AssigneeID == nil || *assignment.AssigneeID != owner.ID {
+			t.Errorf("Expected the submission in progress with the owner, got %+v", assignment)
+		}
+		// Other accounts can't open the form, so aren't offered or accepted
+		if strings.Contains(rr.Body.String(), "teammate@example.com") {
+			t.Error("Expected other tenants' emails to be left out of the assignees")
+		}
+		rr = serve(handler.UpdateAssignment, owner, "POST", url.Values{
+			"status":      {models.InboxStatusInProgress},
+			"assignee_id": {strconv.FormatInt(teammate.ID, 10)},
+		}, 0)
+		if !strings.Contains(rr.Body.String(), "Assignee not found") {
+			t.Errorf("Expected another tenant's user to be rejected, got %s", rr.Body.String())
+		}
+		if assignment, _ := models.GetAssignment(db.Connection, submission.ID); assignment.AssigneeID == nil || *assignment.AssigneeID != owner.ID {
+			t.Errorf("Expected the assignment to be unchanged, got %+v", assignment)
+		}
+
+		rr = serve(handler.UpdateAssignment, owner, "POST", url.Values{"status": {"archived"}}, 0)
+		if !strings.Contains(rr.Body.String(), "Invalid status") {
+			t.Errorf("Expected a validation error, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("notes", func(t *testing.T) {
+		rr := serve(handler.CreateNote, owner, "POST", url.Values{"body": {"  Called them back  "}}, 0)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), "Called them back") {
+			t.Errorf("Expected the note in the response, got %s", rr.Body.String())
+		}
+
+		rr = serve(handler.CreateNote, owner, "POST", url.Values{"body": {"   "}}, 0)
+		if !strings.Contains(rr.Body.String(), "Note can&#39;t be empty") {
+			t.Errorf("Expected a validation error, got %s", rr.Body.String())
+		}
+
+		notes, err := models.GetSubmissionNotes(db.Connection, submission.ID)
+		if err != nil {
+			t.Fatalf("Failed to get notes: %v", err)
+		}
+		if len(notes) != 1 || notes[0].Body != "Called them back" {
+			t.Fatalf("Expected one trimmed note, got %+v", notes)
+		}
+
+		// Only the author can delete a note
+		rr = serve(handler.DeleteNote, teammate, "DELETE", nil, notes[0].ID)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user, got %d", rr.Code)
+		}
+		rr = serve(handler.DeleteNote, owner, "DELETE", nil, notes[0].ID)
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", rr.Code)
+		}
+		if note, _ := models.GetSubmissionNoteByID(db.Connection, notes[0].ID); note != nil {
+			t.Error("Expected the note to be deleted")
+		}
+	})
+
+	t.Run("other users' forms", func(t *testing.T) {
+		rr := serve(handler.SubmissionInbox, teammate, "GET", nil, 0)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"staticsend/pkg/integrity"
+	"staticsend/pkg/templates"
+)
+
+// IntegrityHandler shows and repairs orphaned rows in the database
+type IntegrityHandler struct {
+	Checker   *integrity.Checker
+	Templates *templates.TemplateManager
+}
+
+// NewIntegrityHandler creates a new integrity handler
+func NewIntegrityHandler(checker *integrity.Checker, tm *templates.TemplateManager) *IntegrityHandler {
+	return &IntegrityHandler{
+		Checker:   checker,
+		Templates: tm,
+	}
+}
+
+// ShowIntegrity renders the integrity partial with the last check's report
+func (h *IntegrityHandler) ShowIntegrity(w http.ResponseWriter, r *http.Request) {
+	h.render(w, h.Checker.Last(), "", "")
+}
+
+// CheckNow checks the database immediately
+func (h *IntegrityHandler) CheckNow(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Checker.Check(r.Context())
+	if err != nil {
+		log.Printf("Integrity check failed: %v", err)
+		h.render(w, h.Checker.Last(), "Integrity check failed", "")
+		return
+	}
+
+	flash := "No orphaned rows found"
+	if orphans := report.Orphans(); orphans > 0 {
+		flash = fmt.Sprintf("Found %d orphaned rows", orphans)
+	}
+	h.render(w, report, "", flash)
+}
+
+// RepairNow deletes or clears orphaned rows immediately
+func (h *IntegrityHandler) RepairNow(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Checker.Repair(r.Context())
+	if err != nil {
+		log.Printf("Integrity repair failed: %v", err)
+		h.render(w, h.Checker.Last(), "Repair failed", "")
+		return
+	}
+
+	h.render(w, report, "", fmt.Sprintf("Repaired %d orphaned rows", report.Orphans()))
+}
+
+// render renders the integrity partial
+func (h *IntegrityHandler) render(w http.ResponseWriter, report *integrity.Report, errorMsg, flash string) {
+	if err := h.Templates.Render(w, "partials/integrity.html", templates.TemplateData{
+		Title: "Database Integrity",
+		Error: errorMsg,
+		Flash: flash,
+		Data: map[string]interface{}{
+			"Report": report,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/integrity"
+	"staticsend/pkg/templates"
+)
+
+func TestIntegrityHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	handler := NewIntegrityHandler(integrity.NewChecker(db, false), templates.NewTemplateManager())
+
+	rr := httptest.NewRecorder()
+	handler.ShowIntegrity(rr, httptest.NewRequest("GET", "/settings/integrity", nil))
+	if !strings.Contains(rr.Body.String(), "No check has run yet") {
+		t.Errorf("Expected no report before a check, got %s", rr.Body.String())
+	}
+
+	// Leave an email record behind for a submission that doesn't exist
+	for _, query := range []string{
+		"PRAGMA foreign_keys = OFF",
+		"INSERT INTO submission_emails (submission_id, status) VALUES (42, 'sent')",
+		"PRAGMA foreign_keys = ON",
+	} {
+		if _, err := db.Connection.Exec(query); err != nil {
+			t.Fatalf("Failed to run %q: %v", query, err)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	handler.CheckNow(rr, httptest.NewRequest("POST", "/settings/integrity/check", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "Found 1 orphaned rows") || !strings.Contains(body, "submission_emails.submission_id") {
+		t.Errorf("Expected the orphaned email record to be reported, got %s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.RepairNow(rr, httptest.NewRequest("POST", "/settings/integrity/repair", nil))
+	if !strings.Contains(rr.Body.String(), "Repaired 1 orphaned rows") {
+		t.Errorf("Expected confirmation of the repair, got %s", rr.Body.String())
+	}
+}
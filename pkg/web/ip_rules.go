@@ -0,0 +1,235 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/ipfilter"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// blockedAttemptsLimit is how many recent blocked attempts are shown
+const blockedAttemptsLimit = 20
+
+// IPRulesHandler handles management of global and per-form IP rules
+type IPRulesHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+}
+
+// NewIPRulesHandler creates a new IP rules handler
+func NewIPRulesHandler(db *database.Database, tm *templates.TemplateManager) *IPRulesHandler {
+	return &IPRulesHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// GlobalIPRules renders the global IP rules partial
+func (h *IPRulesHandler) GlobalIPRules(w http.ResponseWriter, r *http.Request) {
+	h.renderGlobalRules(w, r, "")
+}
+
+// CreateGlobalIPRule adds a rule that applies to every form
+func (h *IPRulesHandler) CreateGlobalIPRule(w http.ResponseWriter, r *http.Request) {
+	if errMsg := h.createRule(r, nil); errMsg != "" {
+		h.renderGlobalRules(w, r, errMsg)
+		return
+	}
+	h.renderGlobalRules(w, r, "")
+}
+
+// DeleteGlobalIPRule removes a global rule
+func (h *IPRulesHandler) DeleteGlobalIPRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ruleFromURL(w, r)
+	if !ok {
+		return
+	}
+	if rule.FormID != nil {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+
+	if err := models.DeleteIPRuleContext(r.Context(), h.DB.Connection, rule.ID); err != nil {
+		h.renderGlobalRules(w, r, "Failed to delete rule")
+		return
+	}
+	h.renderGlobalRules(w, r, "")
+}
+
+// FormIPRules renders the IP rules partial for a form
+func (h *IPRulesHandler) FormIPRules(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.formFromURL(w, r)
+	if !ok {
+		return
+	}
+	h.renderFormRules(w, r, form, "")
+}
+
+// CreateFormIPRule adds a rule to a form
+func (h *IPRulesHandler) CreateFormIPRule(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.formFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if errMsg := h.createRule(r, &form.ID); errMsg != "" {
+		h.renderFormRules(w, r, form, errMsg)
+		return
+	}
+	h.renderFormRules(w, r, form, "")
+}
+
+// DeleteFormIPRule removes a rule from a form
+func (h *IPRulesHandler) DeleteFormIPRule(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.formFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	rule, ok := h.ruleFromURL(w, r)
+	if !ok {
+		return
+	}
+	if rule.FormID == nil || *rule.FormID != form.ID {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+
+	if err := models.DeleteIPRuleContext(r.Context(), h.DB.Connection, rule.ID); err != nil {
+		h.renderFormRules(w, r, form, "Failed to delete rule")
+		return
+	}
+	h.renderFormRules(w, r, form, "")
+}
+
+// createRule validates the submitted rule and stores it, returning an error
+// message for the user when something is wrong
+func (h *IPRulesHandler) createRule(r *http.Request, formID *int64) string {
+	if err := r.ParseForm(); err != nil {
+		return "Invalid form data"
+	}
+
+	cidr, err := ipfilter.NormalizeCIDR(r.FormValue("cidr"))
+	if err != nil {
+		return err.Error()
+	}
+
+	action := r.FormValue("action")
+	if err := ipfilter.ValidateAction(action); err != nil {
+		return "Action must be allow or deny"
+	}
+
+	if _, err := models.CreateIPRuleContext(r.Context(), h.DB.Connection, formID, cidr, action, r.FormValue("note")); err != nil {
+		return "Failed to save rule"
+	}
+	return ""
+}
+
+// formFromURL loads the form from the URL and checks that the current user owns it
+func (h *IPRulesHandler) formFromURL(w http.ResponseWriter, r *http.Request) (*models.Form, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	formID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	form, err := models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		return nil, false
+	}
+	if form == nil {
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return nil, false
+	}
+
+	return form, true
+}
+
+// ruleFromURL loads the rule referenced by the URL
+func (h *IPRulesHandler) ruleFromURL(w http.ResponseWriter, r *http.Request) (*models.IPRule, bool) {
+	ruleID, err := strconv.ParseInt(chi.URLParam(r, "ruleID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	rule, err := models.GetIPRuleByIDContext(r.Context(), h.DB.Connection, ruleID)
+	if err != nil {
+		http.Error(w, "Failed to fetch rule", http.StatusInternalServerError)
+		return nil, false
+	}
+	if rule == nil {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// renderGlobalRules renders the global rules partial with recent blocked attempts
+func (h *IPRulesHandler) renderGlobalRules(w http.ResponseWriter, r *http.Request, errorMsg string) {
+	rules, err := models.GetGlobalIPRulesContext(r.Context(), h.DB.Connection)
+	if err != nil {
+		errorMsg = "Failed to load IP rules"
+	}
+	blocked, err := models.GetRecentBlockedAttemptsContext(r.Context(), h.DB.Connection, blockedAttemptsLimit)
+	if err != nil {
+		errorMsg = "Failed to load blocked attempts"
+	}
+
+	h.render(w, errorMsg, map[string]interface{}{
+		"Heading":  "Global IP Rules",
+		"Endpoint": "/settings/ip-rules",
+		"Target":   "#ip-rules",
+		"Rules":    rules,
+		"Blocked":  blocked,
+	})
+}
+
+// renderFormRules renders the rules partial for a single form
+func (h *IPRulesHandler) renderFormRules(w http.ResponseWriter, r *http.Request, form *models.Form, errorMsg string) {
+	rules, err := models.GetIPRulesByFormIDContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		errorMsg = "Failed to load IP rules"
+	}
+	blocked, err := models.GetBlockedAttemptsByFormIDContext(r.Context(), h.DB.Connection, form.ID, blockedAttemptsLimit)
+	if err != nil {
+		errorMsg = "Failed to load blocked attempts"
+	}
+
+	h.render(w, errorMsg, map[string]interface{}{
+		"Heading":  "IP Rules - " + form.Name,
+		"Endpoint": fmt.Sprintf("/forms/%d/ip-rules", form.ID),
+		"Target":   "#modal-content",
+		"Form":     form,
+		"Rules":    rules,
+		"Blocked":  blocked,
+	})
+}
+
+// render renders the IP rules partial
+func (h *IPRulesHandler) render(w http.ResponseWriter, errorMsg string, data map[string]interface{}) {
+	tmplData := templates.TemplateData{
+		Title: data["Heading"].(string),
+		Error: errorMsg,
+		Data:  data,
+	}
+
+	if err := h.Templates.Render(w, "partials/ip_rules.html", tmplData); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,311 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+	"staticsend/pkg/templates"
+)
+
+// deliveriesListed is how many recent deliveries are shown per channel
+const deliveriesListed = 5
+
+// secretGrace is how long a webhook's previous signing secret can be kept
+// signing messages after a rotation
+type secretGrace struct {
+	Label    string
+	Duration time.Duration
+}
+
+// secretGraces lists the grace windows offered, the default first
+var secretGraces = []secretGrace{
+	{Label: "1 day", Duration: 24 * time.Hour},
+	{Label: "1 hour", Duration: time.Hour},
+	{Label: "1 week", Duration: 7 * 24 * time.Hour},
+	{Label: "None", Duration: 0},
+}
+
+// NotificationsHandler manages the notification channels of forms
+type NotificationsHandler struct {
+	DB         *database.Database
+	Templates  *templates.TemplateManager
+	Dispatcher *notify.Dispatcher
+}
+
+// NewNotificationsHandler creates a new notifications handler
+func NewNotificationsHandler(db *database.Database, tm *templates.TemplateManager, dispatcher *notify.Dispatcher) *NotificationsHandler {
+	return &NotificationsHandler{
+		DB:         db,
+		Templates:  tm,
+		Dispatcher: dispatcher,
+	}
+}
+
+// FormChannels renders a form's notification channels, with the settings
+// of the channel type picked in the query for adding another
+func (h *NotificationsHandler) FormChannels(w http.ResponseWriter, r *http.Request) {
+	_, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+	h.render(w, r, form, "", "")
+}
+
+// CreateChannel adds a channel of the posted type to a form
+func (h *NotificationsHandler) CreateChannel(w http.ResponseWriter, r *http.Request) {
+	_, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, form, "", "Invalid form data")
+		return
+	}
+
+	kind := notify.KindByName(r.FormValue("type"))
+	if kind == nil || kind.Hidden {
+		h.render(w, r, form, "", "Pick a channel type")
+		return
+	}
+	config := make(map[string]string, len(kind.Fields))
+	for _, field := range kind.Fields {
+		if value := strings.TrimSpace(r.FormValue("config_" + field.Name)); value != "" {
+			config[field.Name] = value
+		}
+	}
+	if err := kind.Validate(config); err != nil {
+		h.render(w, r, form, "", err.Error())
+		return
+	}
+	events, errorMsg := postedEvents(r)
+	if errorMsg != "" {
+		h.render(w, r, form, "", errorMsg)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = kind.Label
+	}
+	if _, err := models.CreateNotificationChannelContext(r.Context(), h.DB.Connection, form.ID, kind.Name, name, config, events); err != nil {
+		h.render(w, r, form, "", "Failed to save channel")
+		return
+	}
+	h.render(w, r, form, kind.Label+" channel added", "")
+}
+
+// ToggleChannel enables or disables a channel
+func (h *NotificationsHandler) ToggleChannel(w http.ResponseWriter, r *http.Request) {
+	form, channel, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	enabled := !channel.Enabled
+	if err := models.UpdateNotificationChannelContext(r.Context(), h.DB.Connection, channel.ID, channel.Name, channel.Config, channel.Events, enabled); err != nil {
+		h.render(w, r, form, "", "Failed to update channel")
+		return
+	}
+	message := channel.Name + " disabled"
+	if enabled {
+		message = channel.Name + " enabled"
+	}
+	h.render(w, r, form, message, "")
+}
+
+// UpdateChannelEvents sets the events a channel is sent
+func (h *NotificationsHandler) UpdateChannelEvents(w http.ResponseWriter, r *http.Request) {
+	form, channel, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, form, "", "Invalid form data")
+		return
+	}
+	events, errorMsg := postedEvents(r)
+	if errorMsg != "" {
+		h.render(w, r, form, "", errorMsg)
+		return
+	}
+
+	if err := models.UpdateNotificationChannelContext(r.Context(), h.DB.Connection, channel.ID, channel.Name, channel.Config, events, channel.Enabled); err != nil {
+		h.render(w, r, form, "", "Failed to update channel")
+		return
+	}
+	h.render(w, r, form, channel.Name+" events saved", "")
+}
+
+// postedEvents returns the events checked for a channel, or a message
+// saying what's wrong with them
+func postedEvents(r *http.Request) ([]string, string) {
+	events := r.Form["events"]
+	if len(events) == 0 {
+		return nil, "Pick at least one event"
+	}
+	for _, event := range events {
+		if !notify.IsEvent(event) {
+			return nil, "Unknown event " + event
+		}
+	}
+	return events, ""
+}
+
+// TestChannel sends a test notification through a channel
+func (h *NotificationsHandler) TestChannel(w http.ResponseWriter, r *http.Request) {
+	form, channel, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Dispatcher.Test(r.Context(), form, channel); err != nil {
+		h.render(w, r, form, "", "Test notification failed: "+err.Error())
+		return
+	}
+	h.render(w, r, form, "Test notification sent to "+channel.Name, "")
+}
+
+// RotateSecret gives a webhook channel a new signing secret, shown once,
+// keeping the old one signing messages for the posted grace window
+func (h *NotificationsHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	form, channel, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+	if channel.Type != "webhook" {
+		h.render(w, r, form, "", "Only webhooks have a signing secret")
+		return
+	}
+	grace, err := time.ParseDuration(r.FormValue("grace"))
+	if err != nil || !offeredGrace(grace) {
+		h.render(w, r, form, "", "Pick how long the old secret stays valid")
+		return
+	}
+
+	config, secret, err := notify.RotateWebhookSecret(channel.Config, grace, time.Now())
+	if err != nil {
+		h.render(w, r, form, "", "Failed to generate a secret")
+		return
+	}
+	if err := models.UpdateNotificationChannelContext(r.Context(), h.DB.Connection, channel.ID, channel.Name, config, channel.Events, channel.Enabled); err != nil {
+		h.render(w, r, form, "", "Failed to update channel")
+		return
+	}
+
+	message := channel.Name + " signing secret v" + strconv.Itoa(secret.Version) + ": " + secret.Secret + " (copy it now, it won't be shown again)."
+	if secret.Previous != "" {
+		message += " Messages are also signed with v" + strconv.Itoa(secret.PreviousVersion) + " until " + secret.PreviousExpires.Format("Jan 2, 2006 3:04 PM MST") + "."
+	}
+	h.render(w, r, form, message, "")
+}
+
+// offeredGrace reports whether a grace window is one of those offered
+func offeredGrace(grace time.Duration) bool {
+	for _, offered := range secretGraces {
+		if offered.Duration == grace {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteChannel removes a channel and its delivery log
+func (h *NotificationsHandler) DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	form, channel, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteNotificationChannelContext(r.Context(), h.DB.Connection, channel.ID); err != nil {
+		h.render(w, r, form, "", "Failed to delete channel")
+		return
+	}
+	h.render(w, r, form, channel.Name+" removed", "")
+}
+
+// ownedChannel loads the channel in the URL, checking it belongs to a form
+// the current user owns
+func (h *NotificationsHandler) ownedChannel(w http.ResponseWriter, r *http.Request) (*models.Form, *models.NotificationChannel, bool) {
+	_, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return nil, nil, false
+	}
+
+	channelID, err := strconv.ParseInt(chi.URLParam(r, "channelID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid channel ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	channel, err := models.GetNotificationChannelByIDContext(r.Context(), h.DB.Connection, channelID)
+	if err != nil {
+		http.Error(w, "Failed to fetch channel", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if channel == nil || channel.FormID != form.ID {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return form, channel, true
+}
+
+// render renders the channels partial for a form
+func (h *NotificationsHandler) render(w http.ResponseWriter, r *http.Request, form *models.Form, message, errorMsg string) {
+	channels, err := models.GetNotificationChannelsByFormIDContext(r.Context(), h.DB.Connection, form.ID, false)
+	if err != nil {
+		http.Error(w, "Failed to fetch channels", http.StatusInternalServerError)
+		return
+	}
+	deliveries := make(map[int64][]models.NotificationDelivery, len(channels))
+	for _, channel := range channels {
+		list, err := models.GetNotificationDeliveriesContext(r.Context(), h.DB.Connection, channel.ID, deliveriesListed)
+		if err != nil {
+			http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+			return
+		}
+		deliveries[channel.ID] = list
+	}
+
+	// Channels can be of hidden kinds, such as those Zapier subscribes
+	kindsByName := make(map[string]*notify.Kind, len(channels))
+	webhookSecrets := make(map[int64]*notify.WebhookSecret)
+	for _, channel := range channels {
+		if kind := notify.KindByName(channel.Type); kind != nil {
+			kindsByName[kind.Name] = kind
+		}
+		if channel.Type == "webhook" {
+			secret := notify.ReadWebhookSecret(channel.Config)
+			webhookSecrets[channel.ID] = &secret
+		}
+	}
+	kinds := notify.Kinds()
+	selected := notify.KindByName(r.FormValue("type"))
+	if (selected == nil || selected.Hidden) && len(kinds) > 0 {
+		selected = kinds[0]
+	}
+
+	if err := h.Templates.Render(w, "partials/notification_channels.html", templates.TemplateData{
+		Title: "Notifications - " + form.Name,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Form":        form,
+			"Channels":    channels,
+			"Deliveries":  deliveries,
+			"Kinds":       kinds,
+			"Events":      notify.Events,
+			"KindsByName": kindsByName,
+			"Selected":    selected,
+			"Message":     message,
+			"Secrets":     webhookSecrets,
+			"Graces":      secretGraces,
+			"Now":         time.Now(),
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
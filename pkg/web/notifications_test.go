@@ -0,0 +1,177 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+	"staticsend/pkg/templates"
+)
+
+func TestNotificationChannelsHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "channels-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+
+	hooks := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hooks++ }))
+	defer server.Close()
+
+	dispatcher := notify.NewDispatcher(db, nil, func() string { return "" }, 10, 0, 0)
+	handler := NewNotificationsHandler(db, templates.NewTemplateManager(), dispatcher)
+
+	serve := func(h http.HandlerFunc, user *models.User, method, target string, values url.Values, channelID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		rctx.URLParams.Add("channelID", strconv.FormatInt(channelID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	t.Run("list", func(t *testing.T) {
+		rr := serve(handler.FormChannels, owner, "GET", "/?type=telegram", nil, 0)
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || !strings.Contains(body, "No notification channels configured") {
+			t.Fatalf("Expected an empty list, got %d: %s", rr.Code, body)
+		}
+		if !strings.Contains(body, `name="config_bot_token"`) || strings.Contains(body, `name="config_url"`) {
+			t.Error("Expected the settings of the picked type")
+		}
+		if body := serve(handler.FormChannels, owner, "GET", "/?type=airtable", nil, 0).Body.String(); !strings.Contains(body, `<textarea id="channel-mapping" name="config_mapping"`) {
+			t.Error("Expected a text area for the field mapping")
+		}
+		if rr := serve(handler.FormChannels, other, "GET", "/", nil, 0); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		rr := serve(handler.CreateChannel, owner, "POST", "/", url.Values{"type": {"webhook"}, "config_url": {"not a url"}}, 0)
+		if !strings.Contains(rr.Body.String(), "URL must be an http or https URL") {
+			t.Errorf("Expected a validation error, got %s", rr.Body.String())
+		}
+		rr = serve(handler.CreateChannel, owner, "POST", "/", url.Values{"type": {"webhook"}, "config_url": {server.URL}}, 0)
+		if !strings.Contains(rr.Body.String(), "Pick at least one event") {
+			t.Errorf("Expected an error without events, got %s", rr.Body.String())
+		}
+
+		rr = serve(handler.CreateChannel, owner, "POST", "/", url.Values{
+			"type":          {"webhook"},
+			"name":          {"Zapier"},
+			"config_url":    {server.URL},
+			"config_secret": {"top-secret"},
+			"events":        {notify.EventSubmissionCreated, notify.EventEmailFailed},
+		}, 0)
+		body := rr.Body.String()
+		if !strings.Contains(body, "Webhook channel added") || !strings.Contains(body, "Zapier") {
+			t.Errorf("Expected the new channel, got %s", body)
+		}
+		if strings.Contains(body, "top-secret") {
+			t.Error("Expected the secret to be hidden")
+		}
+	})
+
+	channels, _ := models.GetNotificationChannelsByFormID(db.Connection, form.ID, false)
+	if len(channels) != 1 {
+		t.Fatalf("Expected one channel, got %d", len(channels))
+	}
+	channel := channels[0]
+	if !channel.Subscribes(notify.EventEmailFailed) || channel.Subscribes(notify.EventFormCreated) {
+		t.Errorf("Expected the events checked, got %v", channel.Events)
+	}
+
+	t.Run("test", func(t *testing.T) {
+		rr := serve(handler.TestChannel, owner, "POST", "/", nil, channel.ID)
+		if !strings.Contains(rr.Body.String(), "Test notification sent to Zapier") || hooks != 1 {
+			t.Errorf("Expected the test to be sent, got %s", rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), ">sent<") {
+			t.Error("Expected the delivery in the log")
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		rr := serve(handler.UpdateChannelEvents, owner, "POST", "/", url.Values{"events": {"form.deleted"}}, channel.ID)
+		if !strings.Contains(rr.Body.String(), "Unknown event form.deleted") {
+			t.Errorf("Expected an unknown event error, got %s", rr.Body.String())
+		}
+		rr = serve(handler.UpdateChannelEvents, owner, "POST", "/", url.Values{"events": {notify.EventFormCreated, notify.EventFormUpdated}}, channel.ID)
+		if !strings.Contains(rr.Body.String(), "Zapier events saved") {
+			t.Errorf("Expected the events saved, got %s", rr.Body.String())
+		}
+		updated, _ := models.GetNotificationChannelByID(db.Connection, channel.ID)
+		if updated.Subscribes(notify.EventSubmissionCreated) || !updated.Subscribes(notify.EventFormUpdated) {
+			t.Errorf("Expected the new events, got %v", updated.Events)
+		}
+	})
+
+	t.Run("toggle", func(t *testing.T) {
+		rr := serve(handler.ToggleChannel, owner, "POST", "/", nil, channel.ID)
+		if !strings.Contains(rr.Body.String(), "Zapier disabled") {
+			t.Errorf("Expected the channel disabled, got %s", rr.Body.String())
+		}
+		if updated, _ := models.GetNotificationChannelByID(db.Connection, channel.ID); updated.Enabled || !updated.Subscribes(notify.EventFormUpdated) {
+			t.Error("Expected the channel to be disabled, keeping its events")
+		}
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		rr := serve(handler.RotateSecret, owner, "POST", "/", url.Values{"grace": {"5m"}}, channel.ID)
+		if !strings.Contains(rr.Body.String(), "Pick how long the old secret stays valid") {
+			t.Errorf("Expected a grace window that isn't offered refused, got %s", rr.Body.String())
+		}
+		rr = serve(handler.RotateSecret, owner, "POST", "/", url.Values{"grace": {"24h0m0s"}}, channel.ID)
+		body := rr.Body.String()
+		if !strings.Contains(body, "Zapier signing secret v2: whsec_") || !strings.Contains(body, "also signed with v1 until") {
+			t.Errorf("Expected the new secret shown once, got %s", body)
+		}
+		rotated, _ := models.GetNotificationChannelByID(db.Connection, channel.ID)
+		secret := notify.ReadWebhookSecret(rotated.Config)
+		if secret.Version != 2 || secret.Previous != "top-secret" || !strings.Contains(body, secret.Secret) {
+			t.Errorf("Expected the secret rotated, got %+v", secret)
+		}
+		if body := serve(handler.FormChannels, owner, "GET", "/", nil, 0).Body.String(); strings.Contains(body, secret.Secret) || !strings.Contains(body, "Signing secret v2; v1 also signed with until") {
+			t.Errorf("Expected the secret hidden afterwards, with its rotation, got %s", body)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if rr := serve(handler.DeleteChannel, other, "DELETE", "/", nil, channel.ID); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user's channel, got %d", rr.Code)
+		}
+		if rr := serve(handler.DeleteChannel, owner, "DELETE", "/", nil, channel.ID+100); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for a missing channel, got %d", rr.Code)
+		}
+		rr := serve(handler.DeleteChannel, owner, "DELETE", "/", nil, channel.ID)
+		if !strings.Contains(rr.Body.String(), "Zapier removed") {
+			t.Errorf("Expected the channel removed, got %s", rr.Body.String())
+		}
+		if deleted, _ := models.GetNotificationChannelByID(db.Connection, channel.ID); deleted != nil {
+			t.Error("Expected the channel to be deleted")
+		}
+	})
+}
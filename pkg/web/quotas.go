@@ -0,0 +1,163 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// QuotasHandler lets administrators see each user's usage and override
+// their limits
+type QuotasHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+}
+
+// NewQuotasHandler creates a new quotas handler
+func NewQuotasHandler(db *database.Database, tm *templates.TemplateManager) *QuotasHandler {
+	return &QuotasHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// userQuota is one row of the quotas partial. The override fields hold the
+// values shown in the inputs, empty when the user has the default.
+type userQuota struct {
+	User                  models.User
+	Quota                 *models.Quota
+	Usage                 *models.Usage
+	MaxForms              string
+	MaxMonthlySubmissions string
+	MaxStorageMB          string
+}
+
+// ListQuotas renders the quotas partial
+func (h *QuotasHandler) ListQuotas(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "", "")
+}
+
+// UpdateQuota sets a user's limit overrides. Blank limits fall back to the
+// instance-wide defaults.
+func (h *QuotasHandler) UpdateQuota(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByIDContext(r.Context(), h.DB.Connection, userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, "Invalid form data", "")
+		return
+	}
+
+	var override models.QuotaOverride
+	var ok bool
+	if override.MaxForms, ok = parseLimit(r.FormValue("max_forms")); !ok {
+		h.render(w, r, "Limits must be whole numbers of 0 or more", "")
+		return
+	}
+	if override.MaxMonthlySubmissions, ok = parseLimit(r.FormValue("max_monthly_submissions")); !ok {
+		h.render(w, r, "Limits must be whole numbers of 0 or more", "")
+		return
+	}
+	maxStorageMB, ok := parseLimit(r.FormValue("max_storage_mb"))
+	if !ok {
+		h.render(w, r, "Limits must be whole numbers of 0 or more", "")
+		return
+	}
+	if maxStorageMB != nil {
+		bytes := int64(*maxStorageMB) * 1024 * 1024
+		override.MaxStorageBytes = &bytes
+	}
+
+	if err := models.SetQuotaOverrideContext(r.Context(), h.DB.Connection, user.ID, override); err != nil {
+		h.render(w, r, "Failed to update limits", "")
+		return
+	}
+
+	h.render(w, r, "", "Updated limits for "+user.Email)
+}
+
+// parseLimit parses a limit input. A blank input means no override and
+// reports ok with a nil limit.
+func parseLimit(value string) (*int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, false
+	}
+	return &n, true
+}
+
+// render renders the quotas partial with every user's limits and usage
+func (h *QuotasHandler) render(w http.ResponseWriter, r *http.Request, errorMsg, flash string) {
+	rows, err := h.userQuotas(r)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to load usage limits"
+	}
+
+	if err := h.Templates.Render(w, "partials/quotas.html", templates.TemplateData{
+		Title: "Usage Limits",
+		Error: errorMsg,
+		Flash: flash,
+		Data:  rows,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// userQuotas loads the limits, overrides and usage of every user
+func (h *QuotasHandler) userQuotas(r *http.Request) ([]userQuota, error) {
+	users, err := models.GetAllUsersContext(r.Context(), h.DB.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rows := make([]userQuota, 0, len(users))
+	for _, user := range users {
+		row := userQuota{User: user}
+		if row.Quota, err = models.GetUserQuotaContext(r.Context(), h.DB.Connection, user.ID); err != nil {
+			return nil, err
+		}
+		if row.Usage, err = models.GetUsageContext(r.Context(), h.DB.Connection, user.ID, now); err != nil {
+			return nil, err
+		}
+		override, err := models.GetQuotaOverrideContext(r.Context(), h.DB.Connection, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if override.MaxForms != nil {
+			row.MaxForms = strconv.Itoa(*override.MaxForms)
+		}
+		if override.MaxMonthlySubmissions != nil {
+			row.MaxMonthlySubmissions = strconv.Itoa(*override.MaxMonthlySubmissions)
+		}
+		if override.MaxStorageBytes != nil {
+			row.MaxStorageMB = fmt.Sprint(*override.MaxStorageBytes / (1024 * 1024))
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
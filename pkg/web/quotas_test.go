@@ -0,0 +1,105 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestQuotasHandler_UpdateQuota(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, err := models.CreateUser(db.Connection, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	handler := NewQuotasHandler(db, templates.NewTemplateManager())
+
+	update := func(values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/settings/quotas", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("userID", strconv.FormatInt(user.ID, 10))
+		rr := httptest.NewRecorder()
+		handler.UpdateQuota(rr, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		return rr
+	}
+
+	rr := update(url.Values{"max_forms": {"2"}, "max_monthly_submissions": {""}, "max_storage_mb": {"5"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "Updated limits for user@example.com") {
+		t.Errorf("Expected confirmation, got %s", rr.Body.String())
+	}
+
+	override, err := models.GetQuotaOverride(db.Connection, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get override: %v", err)
+	}
+	if override.MaxForms == nil || *override.MaxForms != 2 {
+		t.Errorf("Expected a form limit of 2, got %v", override.MaxForms)
+	}
+	if override.MaxMonthlySubmissions != nil {
+		t.Errorf("Expected a blank submission limit to use the default, got %v", *override.MaxMonthlySubmissions)
+	}
+	if override.MaxStorageBytes == nil || *override.MaxStorageBytes != 5*1024*1024 {
+		t.Errorf("Expected a storage limit of 5 MB, got %v", override.MaxStorageBytes)
+	}
+
+	rr = update(url.Values{"max_forms": {"-1"}})
+	if !strings.Contains(rr.Body.String(), "Limits must be whole numbers") {
+		t.Errorf("Expected a validation error, got %s", rr.Body.String())
+	}
+}
+
+func TestWebHandler_DashboardUsage(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, err := models.CreateUser(db.Connection, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	dashboard := func() string {
+		req := httptest.NewRequest("GET", "/dashboard", nil)
+		rr := httptest.NewRecorder()
+		handler.Dashboard(rr, req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	if strings.Contains(dashboard(), "Submissions this month") {
+		t.Error("Expected no usage counters without limits")
+	}
+
+	if err := models.UpdateAppSetting(db.Connection, models.SettingDefaultMaxForms, "5"); err != nil {
+		t.Fatalf("Failed to update setting: %v", err)
+	}
+	body := dashboard()
+	if !strings.Contains(body, "Submissions this month") || !strings.Contains(body, "0 / 5") {
+		t.Errorf("Expected usage counters against the form limit, got %s", body)
+	}
+}
@@ -0,0 +1,138 @@
+package web
+
+import (
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// routeOperatorLabels is how the routing rule operators read on the page
+var routeOperatorLabels = map[string]string{
+	models.RouteEquals:    "is",
+	models.RouteNotEquals: "is not",
+	models.RouteContains:  "contains",
+}
+
+// FormRoutes renders a form's routing rules, which email submissions to
+// another address when a field matches
+func (h *WebHandler) FormRoutes(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+	h.renderRoutes(w, r, user, form, "")
+}
+
+// CreateFormRoute adds a routing rule after a form's others
+func (h *WebHandler) CreateFormRoute(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	field := strings.TrimSpace(r.FormValue("field"))
+	operator := r.FormValue("operator")
+	address, err := mail.ParseAddress(r.FormValue("recipient"))
+	switch {
+	case field == "":
+		h.renderRoutes(w, r, user, form, "Choose the field the rule checks")
+		return
+	case !models.ValidRouteOperator(operator):
+		h.renderRoutes(w, r, user, form, "Choose how the field is compared")
+		return
+	case err != nil:
+		h.renderRoutes(w, r, user, form, "Enter a valid email address to send to")
+		return
+	}
+
+	if _, err := models.CreateFormRouteContext(r.Context(), h.DB.Connection, form.ID, field, operator, strings.TrimSpace(r.FormValue("value")), address.Address); err != nil {
+		h.renderRoutes(w, r, user, form, "Failed to save the rule")
+		return
+	}
+	h.renderRoutes(w, r, user, form, "")
+}
+
+// MoveFormRoute moves a routing rule up or down, to be tried before or
+// after its neighbour
+func (h *WebHandler) MoveFormRoute(w http.ResponseWriter, r *http.Request) {
+	user, form, route, ok := h.ownedRoute(w, r)
+	if !ok {
+		return
+	}
+	if err := models.MoveFormRouteContext(r.Context(), h.DB.Connection, route, r.FormValue("direction") == "up"); err != nil {
+		h.renderRoutes(w, r, user, form, "Failed to move the rule")
+		return
+	}
+	h.renderRoutes(w, r, user, form, "")
+}
+
+// DeleteFormRoute removes a routing rule from a form
+func (h *WebHandler) DeleteFormRoute(w http.ResponseWriter, r *http.Request) {
+	user, form, route, ok := h.ownedRoute(w, r)
+	if !ok {
+		return
+	}
+	if err := models.DeleteFormRouteContext(r.Context(), h.DB.Connection, route.ID); err != nil {
+		h.renderRoutes(w, r, user, form, "Failed to delete the rule")
+		return
+	}
+	h.renderRoutes(w, r, user, form, "")
+}
+
+// ownedRoute loads the routing rule in the URL, checking it belongs to a
+// form the current user owns
+func (h *WebHandler) ownedRoute(w http.ResponseWriter, r *http.Request) (*models.User, *models.Form, *models.FormRoute, bool) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	routeID, err := strconv.ParseInt(chi.URLParam(r, "routeID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+	route, err := models.GetFormRouteByIDContext(r.Context(), h.DB.Connection, routeID)
+	if err != nil {
+		http.Error(w, "Failed to fetch rule", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+	if route == nil || route.FormID != form.ID {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	return user, form, route, true
+}
+
+// renderRoutes renders the routing rules section of the form modal, with
+// the fields the form has received to choose from
+func (h *WebHandler) renderRoutes(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, errorMsg string) {
+	routes, err := models.GetFormRoutesContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to load routing rules"
+	}
+	var fields []string
+	if catalog, err := models.GetFieldCatalogContext(r.Context(), h.DB.Connection, form.ID); err == nil {
+		fields = catalog.Names()
+	}
+
+	if err := h.TemplateManager.Render(w, "partials/form_routes.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Form":      form,
+			"Routes":    routes,
+			"Last":      len(routes) - 1,
+			"Fields":    fields,
+			"Operators": models.RouteOperators,
+			"Labels":    routeOperatorLabels,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestWebHandler_FormRoutes(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "support@example.com", "routing-key")
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(h http.HandlerFunc, user *models.User, method, routeID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		rctx.URLParams.Add("routeID", routeID)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	if rr := serve(handler.CreateFormRoute, other, "POST", "", "field=department&operator=equals&value=sales&recipient=sales%40example.com"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected another user to be refused, got %d", rr.Code)
+	}
+	rr := serve(handler.CreateFormRoute, owner, "POST", "", "field=department&operator=equals&value=sales&recipient=not+an+address")
+	if !strings.Contains(rr.Body.String(), "Enter a valid email address") {
+		t.Errorf("Expected the address refused, got:\n%s", rr.Body.String())
+	}
+
+	for _, body := range []string{
+		"field=department&operator=equals&value=sales&recipient=Sales+%3Csales%40example.com%3E",
+		"field=message&operator=contains&value=urgent&recipient=oncall%40example.com",
+	} {
+		if rr := serve(handler.CreateFormRoute, owner, "POST", "", body); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to add rule: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	routes, _ := models.GetFormRoutes(db.Connection, form.ID)
+	if len(routes) != 2 || routes[0].Recipient != "sales@example.com" || routes[1].Operator != models.RouteContains {
+		t.Fatalf("Expected both rules saved in order, got %+v", routes)
+	}
+
+	rr = serve(handler.FormRoutes, owner, "GET", "", "")
+	if body := rr.Body.String(); !strings.Contains(body, "department</span> is") || !strings.Contains(body, "oncall@example.com") {
+		t.Errorf("Expected the rules listed, got:\n%s", body)
+	}
+
+	urgent := strconv.FormatInt(routes[1].ID, 10)
+	if rr := serve(handler.MoveFormRoute, owner, "POST", urgent, "direction=up"); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to move rule: %d", rr.Code)
+	}
+	if moved, _ := models.GetFormRoutes(db.Connection, form.ID); moved[0].ID != routes[1].ID {
+		t.Errorf("Expected the urgent rule first, got %+v", moved)
+	}
+
+	otherForm, _ := models.CreateForm(db.Connection, other.ID, "Quote", "example.com", "secret", "quotes@example.com", "other-routing-key")
+	foreign, _ := models.CreateFormRoute(db.Connection, otherForm.ID, "topic", models.RouteEquals, "x", "x@example.com")
+	if rr := serve(handler.DeleteFormRoute, owner, "DELETE", strconv.FormatInt(foreign.ID, 10), ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected another form's rule not found, got %d", rr.Code)
+	}
+	if rr := serve(handler.DeleteFormRoute, owner, "DELETE", urgent, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete rule: %d", rr.Code)
+	}
+	if left, _ := models.GetFormRoutes(db.Connection, form.ID); len(left) != 1 || left[0].ID != routes[0].ID {
+		t.Errorf("Expected only the sales rule left, got %+v", left)
+	}
+}
@@ -1,10 +1,16 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"staticsend/pkg/database"
+	"staticsend/pkg/flash"
+	"staticsend/pkg/livesettings"
 	"staticsend/pkg/models"
 	"staticsend/pkg/templates"
 )
@@ -13,6 +19,9 @@ import (
 type SettingsHandler struct {
 	DB        *database.Database
 	Templates *templates.TemplateManager
+	// Settings, when set, is reloaded after saving so the Turnstile keys
+	// and base URL apply at once
+	Settings *livesettings.Store
 }
 
 // NewSettingsHandler creates a new settings handler
@@ -25,13 +34,22 @@ func NewSettingsHandler(db *database.Database, tm *templates.TemplateManager) *S
 
 // SettingsPage renders the settings page
 func (h *SettingsHandler) SettingsPage(w http.ResponseWriter, r *http.Request) {
-	settings, err := models.GetAllAppSettings(h.DB.Connection)
+	settings, err := models.GetAllAppSettingsContext(r.Context(), h.DB.Connection)
 	if err != nil {
 		h.renderSettingsPage(w, "Failed to load settings", nil)
 		return
 	}
 
-	h.renderSettingsPage(w, "", settings)
+	// SMTP settings are edited on the email setup page, which never shows
+	// the password
+	shown := settings[:0]
+	for _, setting := range settings {
+		if !strings.HasPrefix(setting.Key, "smtp_") {
+			shown = append(shown, setting)
+		}
+	}
+
+	h.renderSettingsPage(w, "", shown)
 }
 
 // UpdateSettings handles updating application settings
@@ -41,37 +59,129 @@ func (h *SettingsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Handle checkbox settings specifically - registration_enabled
+	// Handle checkbox settings
 	// The hidden field ensures we always get a value ("false" when unchecked, "true" when checked)
-	if registrationEnabled := r.FormValue("registration_enabled"); registrationEnabled != "" {
-		if err := models.UpdateAppSetting(h.DB.Connection, "registration_enabled", registrationEnabled); err != nil {
+	if registrationEnabled := checkboxValue(r, "registration_enabled"); registrationEnabled != "" {
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, "registration_enabled", registrationEnabled); err != nil {
 			h.renderSettingsPage(w, "Failed to update registration setting", nil)
 			return
 		}
 	}
 
+	if maintenanceMode := checkboxValue(r, "maintenance_mode"); maintenanceMode != "" {
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, "maintenance_mode", maintenanceMode); err != nil {
+			h.renderSettingsPage(w, "Failed to update maintenance mode", nil)
+			return
+		}
+	}
+
 	// Handle text settings - only update if provided
 	if siteTitle := r.FormValue("site_title"); siteTitle != "" {
-		if err := models.UpdateAppSetting(h.DB.Connection, "site_title", siteTitle); err != nil {
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, "site_title", siteTitle); err != nil {
 			h.renderSettingsPage(w, "Failed to update site title", nil)
 			return
 		}
 	}
 
 	if siteDescription := r.FormValue("site_description"); siteDescription != "" {
-		if err := models.UpdateAppSetting(h.DB.Connection, "site_description", siteDescription); err != nil {
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, "site_description", siteDescription); err != nil {
 			h.renderSettingsPage(w, "Failed to update site description", nil)
 			return
 		}
 	}
 
+	if primaryColor := strings.TrimSpace(r.FormValue(models.SettingPrimaryColor)); primaryColor != "" {
+		if !colorPattern.MatchString(primaryColor) {
+			h.renderSettingsPage(w, "Primary color must be a hex color like #2563eb", nil)
+			return
+		}
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, models.SettingPrimaryColor, primaryColor); err != nil {
+			h.renderSettingsPage(w, "Failed to update primary color", nil)
+			return
+		}
+	}
+
+	if maintenanceMessage := r.FormValue("maintenance_message"); maintenanceMessage != "" {
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, "maintenance_message", maintenanceMessage); err != nil {
+			h.renderSettingsPage(w, "Failed to update maintenance message", nil)
+			return
+		}
+	}
+
+	if baseURL := strings.TrimSuffix(strings.TrimSpace(r.FormValue(models.SettingBaseURL)), "/"); baseURL != "" {
+		if !validBaseURL(baseURL) {
+			h.renderSettingsPage(w, "Base URL must be an http or https URL", nil)
+			return
+		}
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, models.SettingBaseURL, baseURL); err != nil {
+			h.renderSettingsPage(w, "Failed to update base URL", nil)
+			return
+		}
+		h.Templates.SetBaseURL(baseURL)
+	}
+
+	// The Turnstile keys work as a pair: clearing the site key turns the
+	// check off, and the secret is only replaced when a new one is given
+	if _, ok := r.Form[models.SettingTurnstilePublicKey]; ok {
+		publicKey := strings.TrimSpace(r.FormValue(models.SettingTurnstilePublicKey))
+		secretKey := strings.TrimSpace(r.FormValue(models.SettingTurnstileSecretKey))
+		if publicKey != "" && secretKey == "" {
+			saved, err := models.GetAppSettingValueContext(r.Context(), h.DB.Connection, models.SettingTurnstileSecretKey)
+			if err != nil {
+				h.renderSettingsPage(w, "Failed to update Turnstile keys", nil)
+				return
+			}
+			if saved == "" {
+				h.renderSettingsPage(w, "Turnstile needs both a site key and a secret key", nil)
+				return
+			}
+		}
+		if publicKey == "" {
+			secretKey = ""
+		}
+		if publicKey == "" || secretKey != "" {
+			if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, models.SettingTurnstileSecretKey, secretKey); err != nil {
+				h.renderSettingsPage(w, "Failed to update Turnstile keys", nil)
+				return
+			}
+		}
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, models.SettingTurnstilePublicKey, publicKey); err != nil {
+			h.renderSettingsPage(w, "Failed to update Turnstile keys", nil)
+			return
+		}
+	}
+
+	// Handle default quota settings - only update if provided
+	for _, key := range []string{models.SettingDefaultMaxForms, models.SettingDefaultMaxMonthlySubmissions, models.SettingDefaultMaxStorageMB} {
+		value := strings.TrimSpace(r.FormValue(key))
+		if value == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			h.renderSettingsPage(w, "Default limits must be whole numbers of 0 or more", nil)
+			return
+		}
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, key, value); err != nil {
+			h.renderSettingsPage(w, "Failed to update default limits", nil)
+			return
+		}
+	}
+
+	// The site title, description and colour are shown on every page
+	if err := LoadBranding(r.Context(), h.DB, h.Templates); err != nil {
+		h.renderSettingsPage(w, "Failed to apply branding", nil)
+		return
+	}
+	reloadSettings(r.Context(), h.Settings)
+
 	// Redirect back to dashboard after saving
+	flash.Set(w, "Settings saved")
 	w.Header().Set("HX-Redirect", "/dashboard")
 }
 
 // GetRegistrationStatus returns the current registration status as JSON
 func (h *SettingsHandler) GetRegistrationStatus(w http.ResponseWriter, r *http.Request) {
-	enabled, err := models.IsRegistrationEnabled(h.DB.Connection)
+	enabled, err := models.IsRegistrationEnabledContext(r.Context(), h.DB.Connection)
 	if err != nil {
 		http.Error(w, "Failed to get registration status", http.StatusInternalServerError)
 		return
@@ -82,6 +192,27 @@ func (h *SettingsHandler) GetRegistrationStatus(w http.ResponseWriter, r *http.R
 	json.NewEncoder(w).Encode(response)
 }
 
+// reloadSettings applies settings just saved, when there's a live settings
+// store. Other instances pick them up on their next reload.
+func reloadSettings(ctx context.Context, settings *livesettings.Store) {
+	if settings == nil {
+		return
+	}
+	if err := settings.Reload(ctx); err != nil {
+		log.Printf("Failed to reload settings: %v", err)
+	}
+}
+
+// checkboxValue returns the submitted value of a checkbox that is paired with
+// a hidden "false" input. When checked both values are sent, so the last wins.
+func checkboxValue(r *http.Request, key string) string {
+	values := r.Form[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
 // renderSettingsPage renders the settings page with an optional error
 func (h *SettingsHandler) renderSettingsPage(w http.ResponseWriter, errorMsg string, settings []models.AppSetting) {
 	data := templates.TemplateData{
@@ -0,0 +1,317 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"staticsend/pkg/auth"
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/events"
+	"staticsend/pkg/flash"
+	"staticsend/pkg/livesettings"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// SetupHandler runs the first-run setup wizard: creating the administrator
+// account, configuring email and setting the base URL
+type SetupHandler struct {
+	DB           *database.Database
+	SecretKey    []byte
+	Templates    *templates.TemplateManager
+	EmailService *email.EmailService
+	// Settings, when set, is reloaded after saving
+	Settings *livesettings.Store
+	// Events is told about the first account being created, when set
+	Events *events.Bus
+}
+
+// NewSetupHandler creates a new setup handler
+func NewSetupHandler(db *database.Database, secretKey []byte, tm *templates.TemplateManager, emailService *email.EmailService) *SetupHandler {
+	return &SetupHandler{
+		DB:           db,
+		SecretKey:    secretKey,
+		Templates:    tm,
+		EmailService: emailService,
+	}
+}
+
+// ApplyEmailSettings switches an email service to SMTP settings saved in
+// the database, keeping its timeout
+func ApplyEmailSettings(emailService *email.EmailService, settings *models.EmailSettings) {
+	config := emailService.Config()
+	config.Host = settings.Host
+	config.Port = settings.Port
+	config.Username = settings.Username
+	config.Password = settings.Password
+	config.From = settings.From
+	config.UseTLS = settings.UseTLS
+	emailService.SetConfig(config)
+}
+
+// SetupPage renders the account step while the database has no users
+func (h *SetupHandler) SetupPage(w http.ResponseWriter, r *http.Request) {
+	hasUsers, err := models.HasUsersContext(r.Context(), h.DB.Connection)
+	if err != nil {
+		http.Error(w, "Failed to check setup", http.StatusInternalServerError)
+		return
+	}
+	if hasUsers {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	h.render(w, nil, "account", "", "", nil)
+}
+
+// CreateAccount creates the administrator account and signs it in. It only
+// works while the database has no users.
+func (h *SetupHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.render(w, nil, "account", "", "Invalid form data", nil)
+		return
+	}
+
+	hasUsers, err := models.HasUsersContext(r.Context(), h.DB.Connection)
+	if err != nil {
+		h.render(w, nil, "account", "", "Internal server error", nil)
+		return
+	}
+	if hasUsers {
+		http.Error(w, "Setup has already been completed", http.StatusForbidden)
+		return
+	}
+
+	emailAddress := strings.TrimSpace(r.FormValue("email"))
+	password := r.FormValue("password")
+	if emailAddress == "" || password == "" {
+		h.render(w, nil, "account", "", "Email and password are required", nil)
+		return
+	}
+	if password != r.FormValue("confirm_password") {
+		h.render(w, nil, "account", "", "Passwords don't match", nil)
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		h.render(w, nil, "account", "", "Failed to process password", nil)
+		return
+	}
+
+	// The first user is made an administrator. Another setup may have
+	// finished since the check above, in which case this one has lost.
+	user, err := models.CreateFirstUserContext(r.Context(), h.DB.Connection, emailAddress, passwordHash)
+	if err != nil {
+		h.render(w, nil, "account", "", "Failed to create user", nil)
+		return
+	}
+	if user == nil {
+		http.Error(w, "Setup has already been completed", http.StatusForbidden)
+		return
+	}
+	if h.Events != nil {
+		h.Events.Publish(events.Event{Name: events.UserRegistered, User: user})
+	}
+
+	token, err := auth.GenerateToken(user, h.SecretKey)
+	if err != nil {
+		h.render(w, nil, "account", "", "Failed to generate token", nil)
+		return
+	}
+
+	http.SetCookie(w, auth.SessionCookie(r, token))
+
+	w.Header().Set("HX-Redirect", "/setup/email")
+}
+
+// EmailPage renders the email step, filled in with the SMTP settings in use
+func (h *SetupHandler) EmailPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.render(w, user, "email", "", "", h.emailSettings())
+}
+
+// SaveEmail sends a test email with the submitted SMTP settings when
+// action=test, and otherwise saves them and moves on to the site step. A
+// blank password keeps the one in use.
+func (h *SetupHandler) SaveEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.render(w, user, "email", "", "Invalid form data", h.emailSettings())
+		return
+	}
+
+	settings := &models.EmailSettings{
+		Host:     strings.TrimSpace(r.FormValue("host")),
+		Username: strings.TrimSpace(r.FormValue("username")),
+		Password: r.FormValue("password"),
+		From:     strings.TrimSpace(r.FormValue("from")),
+		UseTLS:   checkboxValue(r, "use_tls") == "true",
+	}
+	if settings.Password == "" && h.EmailService != nil {
+		settings.Password = h.EmailService.Config().Password
+	}
+
+	port, err := strconv.Atoi(strings.TrimSpace(r.FormValue("port")))
+	if err != nil || port < 1 || port > 65535 {
+		h.render(w, user, "email", "", "Port must be a number between 1 and 65535", settings)
+		return
+	}
+	settings.Port = port
+	if settings.Host == "" || settings.From == "" {
+		h.render(w, user, "email", "", "SMTP host and from address are required", settings)
+		return
+	}
+
+	if r.FormValue("action") == "test" {
+		config := email.EmailConfig{
+			Host:     settings.Host,
+			Port:     settings.Port,
+			Username: settings.Username,
+			Password: settings.Password,
+			From:     settings.From,
+			UseTLS:   settings.UseTLS,
+		}
+		if h.EmailService != nil {
+			config.Timeout = h.EmailService.Config().Timeout
+			config.Proxy = h.EmailService.Config().Proxy
+			config.HeloName = h.EmailService.Config().HeloName
+			config.EnvelopeFrom = h.EmailService.Config().EnvelopeFrom
+			config.DKIM = h.EmailService.Config().DKIM
+		}
+		if err := email.SendTest(config, user.Email); err != nil {
+			h.render(w, user, "email", "", "Test email failed: "+err.Error(), settings)
+			return
+		}
+		h.render(w, user, "email", "Test email sent to "+user.Email, "", settings)
+		return
+	}
+
+	if err := models.SaveEmailSettingsContext(r.Context(), h.DB.Connection, settings); err != nil {
+		h.render(w, user, "email", "", "Failed to save email settings", settings)
+		return
+	}
+	if h.EmailService != nil {
+		ApplyEmailSettings(h.EmailService, settings)
+	}
+	reloadSettings(r.Context(), h.Settings)
+
+	w.Header().Set("HX-Redirect", "/setup/site")
+}
+
+// SitePage renders the site step
+func (h *SetupHandler) SitePage(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.render(w, user, "site", "", "", map[string]interface{}{
+		"BaseURL":             h.Templates.BaseURL(),
+		"DisableRegistration": true,
+	})
+}
+
+// SaveSite saves the base URL and whether open registration stays enabled,
+// then finishes setup
+func (h *SetupHandler) SaveSite(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.render(w, user, "site", "", "Invalid form data", nil)
+		return
+	}
+
+	baseURL := strings.TrimSuffix(strings.TrimSpace(r.FormValue("base_url")), "/")
+	disableRegistration := checkboxValue(r, "disable_registration") == "true"
+	data := map[string]interface{}{
+		"BaseURL":             baseURL,
+		"DisableRegistration": disableRegistration,
+	}
+
+	if baseURL != "" {
+		if !validBaseURL(baseURL) {
+			h.render(w, user, "site", "", "Base URL must be an http or https URL", data)
+			return
+		}
+		if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, models.SettingBaseURL, baseURL); err != nil {
+			h.render(w, user, "site", "", "Failed to save base URL", data)
+			return
+		}
+		h.Templates.SetBaseURL(baseURL)
+		reloadSettings(r.Context(), h.Settings)
+	}
+
+	if err := models.UpdateAppSettingContext(r.Context(), h.DB.Connection, "registration_enabled", strconv.FormatBool(!disableRegistration)); err != nil {
+		h.render(w, user, "site", "", "Failed to update registration setting", data)
+		return
+	}
+
+	flash.Set(w, "Setup complete")
+	w.Header().Set("HX-Redirect", "/dashboard")
+}
+
+// validBaseURL reports whether a base URL is an absolute http or https URL
+func validBaseURL(baseURL string) bool {
+	parsed, err := url.Parse(baseURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// emailSettings returns the SMTP settings in use, without the password
+func (h *SetupHandler) emailSettings() *models.EmailSettings {
+	settings := &models.EmailSettings{Port: 587, UseTLS: true}
+	if h.EmailService == nil {
+		return settings
+	}
+	config := h.EmailService.Config()
+	if config.Host != "" {
+		settings.Host = config.Host
+		settings.Port = config.Port
+		settings.Username = config.Username
+		settings.From = config.From
+		settings.UseTLS = config.UseTLS
+	}
+	return settings
+}
+
+// render renders a step of the setup wizard
+func (h *SetupHandler) render(w http.ResponseWriter, user *models.User, step, message, errorMsg string, values interface{}) {
+	// The password is never sent back to the browser
+	if settings, ok := values.(*models.EmailSettings); ok {
+		copied := *settings
+		copied.Password = ""
+		values = &copied
+	}
+
+	if err := h.Templates.Render(w, "setup/index.html", templates.TemplateData{
+		Title: "Setup - staticSend",
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Step":    step,
+			"Message": message,
+			"Values":  values,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestSetupHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// No workers, so queued emails are never sent
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25, Password: "env-secret"}, 10, 0, 0)
+	tm := templates.NewTemplateManager()
+	handler := NewSetupHandler(db, []byte("test-secret"), tm, emailService)
+
+	serve := func(h http.HandlerFunc, user *models.User, method string, values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/setup", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user))
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	var admin *models.User
+	t.Run("account", func(t *testing.T) {
+		rr := serve(handler.SetupPage, nil, "GET", nil)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Create account") {
+			t.Fatalf("Expected the account step, got %d", rr.Code)
+		}
+
+		rr = serve(handler.CreateAccount, nil, "POST", url.Values{"email": {"admin@example.com"}, "password": {"secret"}, "confirm_password": {"other"}})
+		if !strings.Contains(rr.Body.String(), "Passwords don&#39;t match") {
+			t.Errorf("Expected mismatched passwords to be rejected")
+		}
+
+		rr = serve(handler.CreateAccount, nil, "POST", url.Values{"email": {"admin@example.com"}, "password": {"secret"}, "confirm_password": {"secret"}})
+		if rr.Header().Get("HX-Redirect") != "/setup/email" {
+			t.Fatalf("Expected a redirect to the email step, got %q: %s", rr.Header().Get("HX-Redirect"), rr.Body.String())
+		}
+		if len(rr.Result().Cookies()) == 0 || rr.Result().Cookies()[0].Name != "auth_token" {
+			t.Error("Expected the new account to be signed in")
+		}
+		admin, _ = models.GetUserByEmail(db.Connection, "admin@example.com")
+		if admin == nil || !admin.IsAdmin() {
+			t.Fatalf("Expected an administrator account, got %+v", admin)
+		}
+
+		rr = serve(handler.CreateAccount, nil, "POST", url.Values{"email": {"second@example.com"}, "password": {"secret"}, "confirm_password": {"secret"}})
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected a second setup account to be refused, got %d", rr.Code)
+		}
+		rr = serve(handler.SetupPage, nil, "GET", nil)
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("Expected setup to redirect once an account exists, got %d", rr.Code)
+		}
+	})
+
+	t.Run("email", func(t *testing.T) {
+		rr := serve(handler.EmailPage, admin, "GET", nil)
+		if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "env-secret") {
+			t.Fatalf("Expected the email step without the password, got %d", rr.Code)
+		}
+
+		rr = serve(handler.SaveEmail, admin, "POST", url.Values{"host": {"smtp.example.com"}, "port": {"bad"}, "from": {"forms@example.com"}})
+		if !strings.Contains(rr.Body.String(), "Port must be a number") {
+			t.Errorf("Expected an invalid port to be rejected")
+		}
+
+		rr = serve(handler.SaveEmail, admin, "POST", url.Values{
+			"action":   {"save"},
+			"host":     {"smtp.example.com"},
+			"port":     {"2525"},
+			"username": {"mailer"},
+			"from":     {"forms@example.com"},
+			"use_tls":  {"false", "true"},
+		})
+		if rr.Header().Get("HX-Redirect") != "/setup/site" {
+			t.Fatalf("Expected a redirect to the site step, got %q", rr.Header().Get("HX-Redirect"))
+		}
+
+		saved, err := models.GetEmailSettings(db.Connection)
+		if err != nil || saved == nil {
+			t.Fatalf("Expected saved email settings, got %v (err %v)", saved, err)
+		}
+		if saved.Host != "smtp.example.com" || saved.Port != 2525 || !saved.UseTLS || saved.Password != "env-secret" {
+			t.Errorf("Unexpected saved settings: %+v", saved)
+		}
+		if config := emailService.Config(); config.Host != "smtp.example.com" || config.Username != "mailer" {
+			t.Errorf("Expected the email service to use the saved settings, got %+v", config)
+		}
+	})
+
+	t.Run("site", func(t *testing.T) {
+		rr := serve(handler.SaveSite, admin, "POST", url.Values{"base_url": {"forms.example.com"}})
+		if !strings.Contains(rr.Body.String(), "Base URL must be an http or https URL") {
+			t.Errorf("Expected a base URL without a scheme to be rejected")
+		}
+
+		rr = serve(handler.SaveSite, admin, "POST", url.Values{"base_url": {"https://forms.example.com/"}, "disable_registration": {"false", "true"}})
+		if rr.Header().Get("HX-Redirect") != "/dashboard" {
+			t.Fatalf("Expected a redirect to the dashboard, got %q", rr.Header().Get("HX-Redirect"))
+		}
+		if tm.BaseURL() != "https://forms.example.com" {
+			t.Errorf("Expected the base URL to be applied, got %q", tm.BaseURL())
+		}
+		if saved, _ := models.GetAppSettingValue(db.Connection, models.SettingBaseURL); saved != "https://forms.example.com" {
+			t.Errorf("Expected the base URL to be saved, got %q", saved)
+		}
+		if enabled, _ := models.IsRegistrationEnabled(db.Connection); enabled {
+			t.Error("Expected open registration to be disabled")
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/sheets"
+	"staticsend/pkg/templates"
+)
+
+// maxKeyFileSize caps the service account key files accepted
+const maxKeyFileSize = 64 << 10
+
+// SheetsHandler manages syncing forms' submissions to Google Sheets
+type SheetsHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+	Syncer    *sheets.Syncer
+}
+
+// NewSheetsHandler creates a new Google Sheets handler
+func NewSheetsHandler(db *database.Database, tm *templates.TemplateManager, syncer *sheets.Syncer) *SheetsHandler {
+	return &SheetsHandler{
+		DB:        db,
+		Templates: tm,
+		Syncer:    syncer,
+	}
+}
+
+// FormSheet renders a form's Google Sheet settings
+func (h *SheetsHandler) FormSheet(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+	h.render(w, r, user, form, "", "")
+}
+
+// SaveSheet sets the spreadsheet a form syncs to and its columns, which
+// default to the form's fields when left empty. Changing the spreadsheet
+// or sheet starts the sync again from the first submission.
+func (h *SheetsHandler) SaveSheet(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	spreadsheetID := sheets.SpreadsheetID(r.FormValue("spreadsheet"))
+	if spreadsheetID == "" {
+		h.render(w, r, user, form, "", "Spreadsheet URL or ID is required")
+		return
+	}
+	var columns []models.SheetColumn
+	var err error
+	if strings.TrimSpace(r.FormValue("columns")) == "" {
+		columns, err = sheets.DefaultColumns(r.Context(), h.DB, form.ID)
+	} else {
+		columns, err = sheets.ParseColumns(r.FormValue("columns"))
+	}
+	if err != nil {
+		h.render(w, r, user, form, "", "Invalid columns: "+err.Error())
+		return
+	}
+
+	sheet := &models.FormSheet{
+		FormID:        form.ID,
+		SpreadsheetID: spreadsheetID,
+		SheetName:     strings.TrimSpace(r.FormValue("sheet_name")),
+		Columns:       columns,
+	}
+	existing, err := models.GetFormSheetContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		h.render(w, r, user, form, "", "Failed to fetch sheet")
+		return
+	}
+	if existing != nil && existing.SpreadsheetID == sheet.SpreadsheetID && existing.SheetName == sheet.SheetName {
+		sheet.LastSubmissionID = existing.LastSubmissionID
+		sheet.HeaderWritten = existing.HeaderWritten
+	}
+	if err := h.Syncer.Save(r.Context(), sheet); err != nil {
+		h.render(w, r, user, form, "", "Failed to save sheet")
+		return
+	}
+	h.Syncer.Wake()
+	h.render(w, r, user, form, "Sheet saved", "")
+}
+
+// Backfill appends every submission to a form's sheet again
+func (h *SheetsHandler) Backfill(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := h.Syncer.Backfill(r.Context(), form.ID); err != nil {
+		h.render(w, r, user, form, "", "Failed to start backfill")
+		return
+	}
+	h.Syncer.Wake()
+	h.render(w, r, user, form, "Backfill started: every submission will be appended", "")
+}
+
+// RemoveSheet stops a form syncing to its sheet, leaving the sheet as it is
+func (h *SheetsHandler) RemoveSheet(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteFormSheetContext(r.Context(), h.DB.Connection, form.ID); err != nil {
+		h.render(w, r, user, form, "", "Failed to remove sheet")
+		return
+	}
+	h.render(w, r, user, form, "Sheet disconnected", "")
+}
+
+// SaveCredentials stores the current user's service account key, pasted or
+// uploaded as the JSON file from Google Cloud
+func (h *SheetsHandler) SaveCredentials(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxKeyFileSize+4096)
+	key := strings.TrimSpace(r.FormValue("credentials"))
+	if file, _, err := r.FormFile("key_file"); err == nil {
+		data, err := io.ReadAll(io.LimitReader(file, maxKeyFileSize))
+		file.Close()
+		if err != nil {
+			h.render(w, r, user, form, "", "Failed to read key file")
+			return
+		}
+		key = strings.TrimSpace(string(data))
+	}
+	if key == "" {
+		h.render(w, r, user, form, "", "Paste or upload a service account key")
+		return
+	}
+
+	creds, err := sheets.ParseCredentials([]byte(key))
+	if err != nil {
+		h.render(w, r, user, form, "", "Invalid key: "+err.Error())
+		return
+	}
+	if err := models.SetGoogleCredentialsContext(r.Context(), h.DB.Connection, user.ID, creds.ClientEmail, key); err != nil {
+		h.render(w, r, user, form, "", "Failed to save key")
+		return
+	}
+	h.Syncer.Wake()
+	h.render(w, r, user, form, "Service account key saved", "")
+}
+
+// DeleteCredentials removes the current user's service account key, which
+// stops all of their forms syncing
+func (h *SheetsHandler) DeleteCredentials(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteGoogleCredentialsContext(r.Context(), h.DB.Connection, user.ID); err != nil {
+		h.render(w, r, user, form, "", "Failed to remove key")
+		return
+	}
+	h.render(w, r, user, form, "Service account key removed", "")
+}
+
+// render renders the Google Sheet settings partial for a form
+func (h *SheetsHandler) render(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, message, errorMsg string) {
+	creds, err := models.GetGoogleCredentialsContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch service account key", http.StatusInternalServerError)
+		return
+	}
+	sheet, err := models.GetFormSheetContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch sheet", http.StatusInternalServerError)
+		return
+	}
+
+	columns := ""
+	if sheet != nil {
+		columns = sheets.FormatColumns(sheet.Columns)
+	} else if defaults, err := sheets.DefaultColumns(r.Context(), h.DB, form.ID); err == nil {
+		columns = sheets.FormatColumns(defaults)
+	}
+
+	if err := h.Templates.Render(w, "partials/form_sheet.html", templates.TemplateData{
+		Title: "Google Sheets - " + form.Name,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Form":        form,
+			"Credentials": creds,
+			"Sheet":       sheet,
+			"Columns":     columns,
+			"Message":     message,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
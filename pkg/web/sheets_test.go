@@ -0,0 +1,116 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/sheets"
+	"staticsend/pkg/templates"
+)
+
+func TestSheetsHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "sheets-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	models.CreateSubmission(db.Connection, form.ID, "203.0.113.9", "Browser", []byte(`{"email":"a@example.com"}`))
+
+	handler := NewSheetsHandler(db, templates.NewTemplateManager(), sheets.NewSyncer(db, sheets.NewClient(http.DefaultClient)))
+	serve := func(h http.HandlerFunc, user *models.User, method string, values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	t.Run("settings", func(t *testing.T) {
+		rr := serve(handler.FormSheet, owner, "GET", nil)
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || !strings.Contains(body, "Submitted = @created_at\nemail = email") {
+			t.Errorf("Expected the default columns, got %d: %s", rr.Code, body)
+		}
+		if !strings.Contains(body, "Save Key") {
+			t.Error("Expected a form to add a key")
+		}
+		if rr := serve(handler.FormSheet, other, "GET", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+		}
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		rr := serve(handler.SaveCredentials, owner, "POST", url.Values{"credentials": {`{"type":"authorized_user"}`}})
+		if !strings.Contains(rr.Body.String(), "isn&#39;t a service account key") {
+			t.Errorf("Expected the key to be rejected, got %s", rr.Body.String())
+		}
+		if creds, _ := models.GetGoogleCredentials(db.Connection, owner.ID); creds != nil {
+			t.Error("Expected no key to be saved")
+		}
+	})
+
+	t.Run("save", func(t *testing.T) {
+		if rr := serve(handler.SaveSheet, owner, "POST", url.Values{"spreadsheet": {"x"}, "columns": {"When = @when"}}); !strings.Contains(rr.Body.String(), "Invalid columns") {
+			t.Errorf("Expected a column error, got %s", rr.Body.String())
+		}
+
+		rr := serve(handler.SaveSheet, owner, "POST", url.Values{
+			"spreadsheet": {"https://docs.google.com/spreadsheets/d/abc123/edit"},
+			"sheet_name":  {"Leads"},
+			"columns":     {"Email = email"},
+		})
+		if !strings.Contains(rr.Body.String(), "Sheet saved") {
+			t.Fatalf("Expected the sheet saved, got %s", rr.Body.String())
+		}
+		sheet, _ := models.GetFormSheet(db.Connection, form.ID)
+		if sheet == nil || sheet.SpreadsheetID != "abc123" || sheet.SheetName != "Leads" || len(sheet.Columns) != 1 {
+			t.Fatalf("Expected the sheet to be stored, got %+v", sheet)
+		}
+
+		// Changing only the columns keeps the sync's progress
+		models.RecordSheetSync(db.Connection, form.ID, 9, true)
+		serve(handler.SaveSheet, owner, "POST", url.Values{"spreadsheet": {"abc123"}, "sheet_name": {"Leads"}, "columns": {"email"}})
+		if sheet, _ := models.GetFormSheet(db.Connection, form.ID); sheet.LastSubmissionID != 9 || !sheet.HeaderWritten {
+			t.Errorf("Expected the progress to be kept, got %+v", sheet)
+		}
+
+		rr = serve(handler.Backfill, owner, "POST", nil)
+		if !strings.Contains(rr.Body.String(), "Backfill started") {
+			t.Errorf("Expected the backfill started, got %s", rr.Body.String())
+		}
+		if sheet, _ := models.GetFormSheet(db.Connection, form.ID); sheet.LastSubmissionID != 0 || sheet.HeaderWritten {
+			t.Errorf("Expected the progress to be reset, got %+v", sheet)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		rr := serve(handler.RemoveSheet, owner, "DELETE", nil)
+		if !strings.Contains(rr.Body.String(), "Sheet disconnected") {
+			t.Errorf("Expected the sheet disconnected, got %s", rr.Body.String())
+		}
+		if sheet, _ := models.GetFormSheet(db.Connection, form.ID); sheet != nil {
+			t.Error("Expected the sheet to be removed")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"staticsend/pkg/models"
+	"staticsend/pkg/snippet"
+	"staticsend/pkg/templates"
+)
+
+// FormCode renders ready-to-paste HTML for a form. The fields are the ones
+// the form has received so far, and ?script=1 adds the form script, which
+// submits it without leaving the page.
+func (h *WebHandler) FormCode(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	script := r.URL.Query().Get("script") == "1"
+	code, err := h.formCode(r.Context(), form, script)
+	if err != nil {
+		log.Printf("Failed to generate form code: %v", err)
+		http.Error(w, "Failed to generate code", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.TemplateManager.Render(w, "partials/form_code.html", templates.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Form":   form,
+			"Code":   code,
+			"Script": script,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// formCode generates the HTML snippet for a form from the fields it has
+// received so far
+func (h *WebHandler) formCode(ctx context.Context, form *models.Form, script bool) (string, error) {
+	opts := snippet.Options{
+		Endpoint: h.TemplateManager.BaseURL() + snippet.SubmitPath + form.FormKey,
+		SiteKey:  form.TurnstileSiteKey,
+		Honeypot: h.Honeypot,
+		Script:   script,
+	}
+	if script {
+		opts.ScriptURL = h.TemplateManager.BaseURL() + h.formScriptURL()
+	}
+
+	inputs, err := h.formInputs(ctx, form)
+	if err != nil {
+		return "", err
+	}
+	opts.Inputs = inputs
+	return snippet.Generate(opts)
+}
+
+// formInputs returns the inputs for a form's fields. They're suggested from
+// the form's field catalog; a form without one gets inputs guessed from a
+// sample of its submissions, or the default fields before it has any.
+func (h *WebHandler) formInputs(ctx context.Context, form *models.Form) ([]snippet.Field, error) {
+	catalog, err := models.GetFieldCatalogContext(ctx, h.DB.Connection, form.ID)
+	if err != nil {
+		return nil, err
+	}
+	var inputs []snippet.Field
+	// Special fields like the honeypot are added separately
+	for _, field := range catalog.Fields {
+		if !strings.HasPrefix(field.Name, "_") {
+			inputs = append(inputs, snippet.Suggest(field, catalog.Submissions))
+		}
+	}
+	if len(inputs) > 0 {
+		return inputs, nil
+	}
+
+	names, err := models.GetSubmissionFieldNamesContext(ctx, h.DB.Connection, form.ID, fieldNameSample)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "_") {
+			inputs = append(inputs, snippet.NewField(name))
+		}
+	}
+	if len(inputs) > 0 {
+		return inputs, nil
+	}
+	for _, name := range snippet.DefaultFields {
+		inputs = append(inputs, snippet.NewField(name))
+	}
+	return inputs, nil
+}
+
+// formScriptURL returns the form script's path, with its version when the
+// script is served
+func (h *WebHandler) formScriptURL() string {
+	if h.FormScript == nil {
+		return snippet.ScriptPath
+	}
+	return h.FormScript.URL()
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/snippet"
+	"staticsend/pkg/templates"
+)
+
+func TestWebHandler_FormCode(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "code-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(user *models.User, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/forms/1/code?"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		handler.FormCode(rr, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx)))
+		return rr
+	}
+
+	// Forms without submissions get the default fields
+	rr := serve(user, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "/api/v1/submit/code-key") || !strings.Contains(body, "name=&#34;message&#34;") {
+		t.Errorf("Expected the form's endpoint and default fields, got:\n%s", body)
+	}
+	if !strings.Contains(body, models.HoneypotField) || strings.Contains(body, "staticsend.js") {
+		t.Error("Expected the honeypot field and no script")
+	}
+	if !strings.Contains(body, snippet.SiteKeyPlaceholder) {
+		t.Error("Expected the site key placeholder for a form without a site key")
+	}
+
+	// Afterwards the fields come from real submissions
+	if _, err := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(`{"company":"Acme","_gotcha":""}`)); err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	body = serve(user, "script=1").Body.String()
+	if !strings.Contains(body, "name=&#34;company&#34;") || strings.Contains(body, "name=&#34;message&#34;") {
+		t.Error("Expected the submitted fields instead of the defaults")
+	}
+	if !strings.Contains(body, "/static/js/staticsend.js") {
+		t.Error("Expected the form script")
+	}
+
+	// The form's site key replaces the placeholder
+	if err := models.SetFormTurnstileSiteKey(db.Connection, form.ID, "0x4AAAA-site-key"); err != nil {
+		t.Fatalf("Failed to set site key: %v", err)
+	}
+	if body := serve(user, "").Body.String(); !strings.Contains(body, "0x4AAAA-site-key") || strings.Contains(body, snippet.SiteKeyPlaceholder) {
+		t.Error("Expected the form's site key in the code")
+	}
+
+	// Once the form's fields are cataloged, the inputs are suggested from it
+	data := json.RawMessage(`{"company":"Initech","email":"jo@example.com"}`)
+	submission, err := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", data)
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	if err := models.RecordFormFields(db.Connection, form.ID, data, submission.CreatedAt); err != nil {
+		t.Fatalf("Failed to catalog fields: %v", err)
+	}
+	body = serve(user, "").Body.String()
+	if !strings.Contains(body, "type=&#34;email&#34; id=&#34;email&#34; name=&#34;email&#34;&gt;") {
+		t.Errorf("Expected an optional email input, got:\n%s", body)
+	}
+	if !strings.Contains(body, "name=&#34;company&#34; maxlength=&#34;50&#34; required&gt;") {
+		t.Errorf("Expected a required company input, got:\n%s", body)
+	}
+
+	if rr := serve(other, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+	}
+}
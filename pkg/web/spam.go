@@ -0,0 +1,176 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"staticsend/pkg/flash"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+	"staticsend/pkg/templates"
+)
+
+// SpamQueue renders the page listing a form's submissions held as spam
+func (h *SubmissionDetailHandler) SpamQueue(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	data := templates.DefaultTemplateData()
+	data.Title = "Spam - " + form.Name + " - staticSend"
+	data.User = user
+	data.Flash = flash.Pop(w, r)
+	data.Data = map[string]interface{}{
+		"Form": form,
+	}
+
+	if err := h.Templates.Render(w, "submissions/spam.html", data); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// SpamTable renders a page of a form's spam queue
+func (h *SubmissionDetailHandler) SpamTable(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+	h.renderSpam(w, r, user, form, "", "")
+}
+
+// NotSpam clears a submission's spam mark, sending its notification email
+// if it hasn't been sent, and renders the spam queue without it
+func (h *SubmissionDetailHandler) NotSpam(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := models.SetSubmissionSpamContext(r.Context(), h.DB.Connection, submission.ID, false); err != nil {
+		h.renderSpam(w, r, user, form, "", "Failed to update submission")
+		return
+	}
+	h.akismetFeedback(form, submission, false)
+
+	message, errorMsg := "Marked as not spam", ""
+	if submission.Status != "processed" {
+		message, errorMsg = h.notify(r.Context(), form, submission)
+		if errorMsg == "" {
+			message = "Marked as not spam. " + message
+		}
+	}
+	if submission.SpamAt != nil {
+		h.notifyChannels(notify.EventSubmissionCreated, form, submission, "")
+	}
+	h.renderSpam(w, r, user, form, message, errorMsg)
+}
+
+// DeleteAllSpam deletes every submission in a form's spam queue
+func (h *SubmissionDetailHandler) DeleteAllSpam(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	deleted, err := models.DeleteSpamSubmissionsContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		h.renderSpam(w, r, user, form, "", "Failed to delete spam")
+		return
+	}
+	h.renderSpam(w, r, user, form, fmt.Sprintf("Deleted %d spam submissions", deleted), "")
+}
+
+// ShowAkismet renders whether a form's submissions are checked with Akismet
+func (h *SubmissionDetailHandler) ShowAkismet(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+	h.renderAkismet(w, user, form, "", "")
+}
+
+// UpdateAkismet turns checking a form's submissions with Akismet on or off
+func (h *SubmissionDetailHandler) UpdateAkismet(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+	if h.Akismet == nil {
+		h.renderAkismet(w, user, form, "", "Akismet isn't configured")
+		return
+	}
+
+	enabled := r.FormValue("enabled") != ""
+	if err := models.SetFormAkismetContext(r.Context(), h.DB.Connection, form.ID, enabled); err != nil {
+		h.renderAkismet(w, user, form, "", "Failed to update form")
+		return
+	}
+	form.AkismetEnabled = enabled
+	message := "New submissions are checked with Akismet"
+	if !enabled {
+		message = "New submissions aren't checked with Akismet"
+	}
+	h.renderAkismet(w, user, form, message, "")
+}
+
+// renderAkismet renders the Akismet partial
+func (h *SubmissionDetailHandler) renderAkismet(w http.ResponseWriter, user *models.User, form *models.Form, message, errorMsg string) {
+	if err := h.Templates.Render(w, "partials/akismet.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Form":       form,
+			"Configured": h.Akismet != nil,
+			"Message":    message,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// renderSpam renders the spam queue partial for the page in the query
+func (h *SubmissionDetailHandler) renderSpam(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, message, errorMsg string) {
+	filter := models.SubmissionFilter{Spam: true}
+	total, err := models.CountSubmissionsContext(r.Context(), h.DB.Connection, form.ID, filter)
+	if err != nil {
+		http.Error(w, "Failed to count spam", http.StatusInternalServerError)
+		return
+	}
+	totalPages := max((total+submissionsPageSize-1)/submissionsPageSize, 1)
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	page = min(page, totalPages)
+
+	submissions, err := models.GetSubmissionsPageContext(r.Context(), h.DB.Connection, form.ID, filter, submissionsPageSize, (page-1)*submissionsPageSize)
+	if err != nil {
+		http.Error(w, "Failed to fetch spam", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Form":        form,
+		"Submissions": submissions,
+		"Total":       total,
+		"Page":        page,
+		"TotalPages":  totalPages,
+		"Message":     message,
+	}
+	if page > 1 {
+		data["PrevPage"] = page - 1
+	}
+	if page < totalPages {
+		data["NextPage"] = page + 1
+	}
+
+	if err := h.Templates.Render(w, "partials/spam_queue.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data:  data,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
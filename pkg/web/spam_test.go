@@ -0,0 +1,191 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/akismet"
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestSpamQueue(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "spam-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	held, _ := models.CreateSpamSubmission(db.Connection, form.ID, "203.0.113.7", "Bot", "", json.RawMessage(`{"message":"cheap pills"}`), models.SpamReasonBlocklist)
+	models.CreateSpamSubmission(db.Connection, form.ID, "203.0.113.8", "Bot", "", json.RawMessage(`{"_gotcha":"x"}`), models.SpamReasonHoneypot)
+	models.CreateSubmission(db.Connection, form.ID, "203.0.113.9", "Browser", json.RawMessage(`{"message":"hello"}`))
+
+	// No workers, so queued emails are never sent
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25}, 10, 0, 0)
+	handler := NewSubmissionDetailHandler(db, templates.NewTemplateManager(), emailService)
+
+	serve := func(h http.HandlerFunc, user *models.User, method string, submissionID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		rctx.URLParams.Add("submissionID", strconv.FormatInt(submissionID, 10))
+		ctx := context.WithValue(req.Context(), middleware.UserKey, user)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	t.Run("list", func(t *testing.T) {
+		rr := serve(handler.SpamTable, owner, "GET", 0)
+		body := rr.Body.String()
+		for _, want := range []string{"2 spam submissions", "blocklist", "honeypot", "cheap pills"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %q in the queue", want)
+			}
+		}
+		if strings.Contains(body, "hello") {
+			t.Error("Expected submissions that aren't spam to be left out")
+		}
+
+		rr = serve(handler.SpamTable, other, "GET", 0)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user, got %d", rr.Code)
+		}
+	})
+
+	t.Run("not spam", func(t *testing.T) {
+		rr := serve(handler.NotSpam, owner, "POST", held.ID)
+		if !strings.Contains(rr.Body.String(), "Email queued for to@example.com") {
+			t.Fatalf("Expected the email to be sent, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if emailService.QueueSize() != 1 {
+			t.Errorf("Expected one queued email, got %d", emailService.QueueSize())
+		}
+		released, _ := models.GetSubmissionByID(db.Connection, held.ID)
+		if released.SpamAt != nil || released.Status != "processed" {
+			t.Errorf("Expected a processed submission that isn't spam, got %+v", released)
+		}
+	})
+
+	t.Run("delete all", func(t *testing.T) {
+		rr := serve(handler.DeleteAllSpam, owner, "DELETE", 0)
+		if !strings.Contains(rr.Body.String(), "Deleted 1 spam submissions") {
+			t.Fatalf("Expected the remaining spam to be deleted, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if count, _ := models.GetSubmissionCountByFormID(db.Connection, form.ID); count != 2 {
+			t.Errorf("Expected 2 submissions left, got %d", count)
+		}
+	})
+}
+
+// akismetRecorder answers Akismet's API, sending the paths it's asked for
+// down a channel
+type akismetRecorder chan string
+
+func (rec akismetRecorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec <- r.URL.Path
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("Thanks for making the web a better place.")),
+		Request:    r,
+	}, nil
+}
+
+func TestAkismet(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "akismet-key")
+	caught, _ := models.CreateSpamSubmission(db.Connection, form.ID, "203.0.113.7", "Bot", "", json.RawMessage(`{"message":"hi"}`), models.SpamReasonAkismet)
+	models.SetSubmissionSpamScore(db.Connection, caught.ID, akismet.ScoreSpam)
+	unscored, _ := models.CreateSpamSubmission(db.Connection, form.ID, "203.0.113.8", "Bot", "", json.RawMessage(`{"message":"hi"}`), models.SpamReasonBlocklist)
+	passed, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.9", "Browser", json.RawMessage(`{"message":"buy now"}`))
+	models.SetSubmissionSpamScore(db.Connection, passed.ID, akismet.ScoreHam)
+
+	requests := make(akismetRecorder, 10)
+	handler := NewSubmissionDetailHandler(db, templates.NewTemplateManager(), nil)
+
+	serve := func(h http.HandlerFunc, method string, submissionID int64, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		rctx.URLParams.Add("submissionID", strconv.FormatInt(submissionID, 10))
+		ctx := context.WithValue(req.Context(), middleware.UserKey, owner)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	if rr := serve(handler.ShowAkismet, "GET", 0, ""); !strings.Contains(rr.Body.String(), "AKISMET_API_KEY") {
+		t.Errorf("Expected a hint to configure Akismet, got %s", rr.Body.String())
+	}
+	if rr := serve(handler.UpdateAkismet, "POST", 0, "enabled=1"); !strings.Contains(rr.Body.String(), "configured") {
+		t.Errorf("Expected Akismet to stay off without a key, got %s", rr.Body.String())
+	}
+
+	handler.Akismet = akismet.NewClient("key", &http.Client{Transport: requests})
+	rr := serve(handler.UpdateAkismet, "POST", 0, "enabled=1")
+	if !strings.Contains(rr.Body.String(), "checked") || !strings.Contains(rr.Body.String(), "are checked with Akismet") {
+		t.Errorf("Expected Akismet to be turned on, got %s", rr.Body.String())
+	}
+	if updated, _ := models.GetFormByID(db.Connection, form.ID); !updated.AkismetEnabled {
+		t.Fatal("Expected the form to have Akismet on")
+	}
+
+	// Releasing spam Akismet caught tells it the submission was ham
+	serve(handler.NotSpam, "POST", caught.ID, "")
+	select {
+	case path := <-requests:
+		if path != "/1.1/submit-ham" {
+			t.Errorf("Expected ham to be submitted, got %s", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Akismet to be told about the released submission")
+	}
+
+	// Submissions Akismet didn't score aren't sent back to it
+	serve(handler.NotSpam, "POST", unscored.ID, "")
+	serve(handler.MarkSpam, "POST", unscored.ID, "")
+	select {
+	case path := <-requests:
+		t.Errorf("Expected no feedback for an unscored submission, got %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Marking a submission Akismet passed as spam tells it what it missed
+	serve(handler.MarkSpam, "POST", passed.ID, "")
+	select {
+	case path := <-requests:
+		if path != "/1.1/submit-spam" {
+			t.Errorf("Expected spam to be submitted, got %s", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Akismet to be told about the missed spam")
+	}
+}
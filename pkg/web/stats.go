@@ -0,0 +1,158 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// statsRanges are the numbers of days the activity charts can cover
+var statsRanges = []int{30, 90}
+
+// Chart sizes in SVG user units; the charts are stretched to fit
+const (
+	chartWidth  = 300
+	chartHeight = 60
+)
+
+// parseStatsDays reads the days query parameter, defaulting to the shortest
+// range. It returns 0 for ranges that aren't offered.
+func parseStatsDays(r *http.Request) int {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return statsRanges[0]
+	}
+	days, _ := strconv.Atoi(value)
+	for _, allowed := range statsRanges {
+		if days == allowed {
+			return days
+		}
+	}
+	return 0
+}
+
+// ActivityStats renders charts of daily submissions and blocked attempts
+// for the user's forms, or for one form with ?form=
+func (h *WebHandler) ActivityStats(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	days := parseStatsDays(r)
+	if days == 0 {
+		http.Error(w, "Invalid range", http.StatusBadRequest)
+		return
+	}
+
+	var form *models.Form
+	if value := r.URL.Query().Get("form"); value != "" {
+		formID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid form ID", http.StatusBadRequest)
+			return
+		}
+		form, err = models.GetFormByIDForUserContext(r.Context(), h.DB.Connection, formID, user.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+			return
+		}
+		if form == nil {
+			httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+			return
+		}
+	}
+
+	var formID int64
+	if form != nil {
+		formID = form.ID
+	}
+	counts, err := models.GetDailyCountsContext(r.Context(), h.DB.Connection, user.ID, formID, days, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch stats", http.StatusInternalServerError)
+		return
+	}
+
+	submissions := make([]int, len(counts))
+	blocked := make([]int, len(counts))
+	var totalSubmissions, totalBlocked int
+	// Both series share a scale so the blocked line reads against the area
+	scale := 1
+	for i, count := range counts {
+		submissions[i] = count.Submissions
+		blocked[i] = count.Blocked
+		totalSubmissions += count.Submissions
+		totalBlocked += count.Blocked
+		scale = max(scale, count.Submissions, count.Blocked)
+	}
+	spamRatio := 0.0
+	if attempts := totalSubmissions + totalBlocked; attempts > 0 {
+		spamRatio = float64(totalBlocked) / float64(attempts) * 100
+	}
+
+	query := "/dashboard/stats?days="
+	if form != nil {
+		query = fmt.Sprintf("/dashboard/stats?form=%d&days=", form.ID)
+	}
+	if err := h.TemplateManager.Render(w, "partials/activity_stats.html", templates.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Form":             form,
+			"Days":             days,
+			"Ranges":           statsRanges,
+			"URL":              query,
+			"From":             counts[0].Day,
+			"To":               counts[len(counts)-1].Day,
+			"TotalSubmissions": totalSubmissions,
+			"TotalBlocked":     totalBlocked,
+			"SpamRatio":        spamRatio,
+			"Peak":             scale,
+			"Width":            chartWidth,
+			"Height":           chartHeight,
+			"SubmissionsArea":  chartArea(submissions, scale),
+			"BlockedLine":      chartLine(blocked, scale),
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// chartPoints scales values to chart coordinates, left to right, with
+// scale at the top of the chart
+func chartPoints(values []int, scale int) []string {
+	points := make([]string, len(values))
+	step := float64(chartWidth)
+	if len(values) > 1 {
+		step = float64(chartWidth) / float64(len(values)-1)
+	}
+	for i, value := range values {
+		x := float64(i) * step
+		y := chartHeight - float64(value)/float64(scale)*chartHeight
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return points
+}
+
+// chartLine returns an SVG path drawing values as a line
+func chartLine(values []int, scale int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return "M" + strings.Join(chartPoints(values, scale), " L")
+}
+
+// chartArea returns an SVG path filling the area under values
+func chartArea(values []int, scale int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("M0,%d L%s L%d,%d Z", chartHeight, strings.Join(chartPoints(values, scale), " L"), chartWidth, chartHeight)
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+	"staticsend/pkg/utils"
+)
+
+// statusPageDays is how many days a form's public status page covers
+const statusPageDays = 90
+
+// statusDay is one day on a public status page. A day is down when an
+// email for a submission that day couldn't be sent.
+type statusDay struct {
+	Day         time.Time
+	Submissions int
+	Failed      int
+	Down        bool
+}
+
+// StatusPage renders a form's public status page: daily submission counts
+// and how many days its submissions were delivered, without any submitted
+// data
+func (h *WebHandler) StatusPage(w http.ResponseWriter, r *http.Request) {
+	page, err := models.GetStatusPageByTokenContext(r.Context(), h.DB.Connection, chi.URLParam(r, "token"))
+	if err != nil {
+		http.Error(w, "Failed to fetch status page", http.StatusInternalServerError)
+		return
+	}
+	if page == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	form, err := models.GetFormByIDContext(r.Context(), h.DB.Connection, page.FormID)
+	if err != nil || form == nil {
+		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		return
+	}
+	counts, err := models.GetDailyCountsContext(r.Context(), h.DB.Connection, form.UserID, form.ID, statusPageDays, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to fetch stats", http.StatusInternalServerError)
+		return
+	}
+
+	days := make([]statusDay, len(counts))
+	submissions := make([]int, len(counts))
+	var total, failed, downDays int
+	scale := 1
+	for i, count := range counts {
+		days[i] = statusDay{
+			Day:         count.Day,
+			Submissions: count.Submissions,
+			Failed:      count.Failed,
+			Down:        count.Failed > 0,
+		}
+		submissions[i] = count.Submissions
+		total += count.Submissions
+		failed += count.Failed
+		if count.Failed > 0 {
+			downDays++
+		}
+		scale = max(scale, count.Submissions)
+	}
+	delivered := 100.0
+	if total > 0 {
+		delivered = float64(total-failed) / float64(total) * 100
+	}
+
+	// Search engines shouldn't index pages shared for reporting
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if err := h.TemplateManager.Render(w, "status/index.html", templates.TemplateData{
+		Title: form.Name + " status",
+		Data: map[string]interface{}{
+			"FormName":        form.Name,
+			"Days":            statusPageDays,
+			"Daily":           days,
+			"Today":           days[len(days)-1],
+			"Total":           total,
+			"Delivered":       delivered,
+			"Uptime":          float64(statusPageDays-downDays) / float64(statusPageDays) * 100,
+			"Peak":            scale,
+			"Width":           chartWidth,
+			"Height":          chartHeight,
+			"SubmissionsArea": chartArea(submissions, scale),
+			"From":            counts[0].Day,
+			"To":              counts[len(counts)-1].Day,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// StatusPageSettings renders whether a form's status page is shared, with
+// its link
+func (h *WebHandler) StatusPageSettings(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+	h.renderStatusPageSettings(w, r, user, form, "")
+}
+
+// EnableStatusPage shares a form's status page at a new link
+func (h *WebHandler) EnableStatusPage(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := utils.GenerateFormKey()
+	if err != nil {
+		h.renderStatusPageSettings(w, r, user, form, "Failed to generate a link")
+		return
+	}
+	if _, err := models.EnableStatusPageContext(r.Context(), h.DB.Connection, form.ID, token); err != nil {
+		h.renderStatusPageSettings(w, r, user, form, "Failed to share the status page")
+		return
+	}
+	h.renderStatusPageSettings(w, r, user, form, "")
+}
+
+// DisableStatusPage stops sharing a form's status page
+func (h *WebHandler) DisableStatusPage(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DisableStatusPageContext(r.Context(), h.DB.Connection, form.ID); err != nil {
+		h.renderStatusPageSettings(w, r, user, form, "Failed to stop sharing the status page")
+		return
+	}
+	h.renderStatusPageSettings(w, r, user, form, "")
+}
+
+// renderStatusPageSettings renders the status page section of the form modal
+func (h *WebHandler) renderStatusPageSettings(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, errorMsg string) {
+	page, err := models.GetStatusPageByFormIDContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to fetch status page"
+	}
+
+	if err := h.TemplateManager.Render(w, "partials/status_page.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data: map[string]interface{}{
+			"Form":       form,
+			"StatusPage": page,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
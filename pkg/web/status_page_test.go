@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestStatusPage(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Client Contact", "example.com", "secret", "to@example.com", "status-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	models.CreateSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", []byte(`{"email":"visitor@example.com"}`))
+	failed, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", []byte(`{}`))
+	models.UpdateSubmissionStatus(db.Connection, failed.ID, "failed")
+
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(h http.HandlerFunc, user *models.User, method, param, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add(param, value)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey, user)
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+	formID := strconv.FormatInt(form.ID, 10)
+
+	t.Run("only the owner can share", func(t *testing.T) {
+		rr := serve(handler.EnableStatusPage, other, "POST", "id", formID)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user, got %d", rr.Code)
+		}
+		rr = serve(handler.StatusPageSettings, owner, "GET", "id", formID)
+		if !strings.Contains(rr.Body.String(), "Not shared") {
+			t.Errorf("Expected the status page to start unshared")
+		}
+	})
+
+	var token string
+	t.Run("share", func(t *testing.T) {
+		rr := serve(handler.EnableStatusPage, owner, "POST", "id", formID)
+		page, _ := models.GetStatusPageByFormID(db.Connection, form.ID)
+		if page == nil {
+			t.Fatal("Expected the status page to be shared")
+		}
+		token = page.Token
+		if !strings.Contains(rr.Body.String(), "/status/"+token) {
+			t.Errorf("Expected the link in the response")
+		}
+	})
+
+	t.Run("public page", func(t *testing.T) {
+		rr := serve(handler.StatusPage, nil, "GET", "token", token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		body := rr.Body.String()
+		for _, want := range []string{"Client Contact", "Some emails failed today", "50.0%"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %q on the status page", want)
+			}
+		}
+		for _, private := range []string{"visitor@example.com", "203.0.113.7", "to@example.com", "status-key"} {
+			if strings.Contains(body, private) {
+				t.Errorf("Expected %q not to be shown", private)
+			}
+		}
+
+		rr = serve(handler.StatusPage, nil, "GET", "token", "unknown")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for an unknown link, got %d", rr.Code)
+		}
+	})
+
+	t.Run("stop sharing", func(t *testing.T) {
+		serve(handler.DisableStatusPage, owner, "DELETE", "id", formID)
+		rr := serve(handler.StatusPage, nil, "GET", "token", token)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 once unshared, got %d", rr.Code)
+		}
+	})
+}
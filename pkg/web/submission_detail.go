@@ -0,0 +1,411 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/akismet"
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/flash"
+	"staticsend/pkg/models"
+	"staticsend/pkg/notify"
+	"staticsend/pkg/storage"
+	"staticsend/pkg/templates"
+	"staticsend/pkg/unsubscribe"
+	"staticsend/pkg/uploads"
+)
+
+// akismetFeedbackTimeout caps how long telling Akismet about a
+// reclassified submission takes
+const akismetFeedbackTimeout = 10 * time.Second
+
+// longFieldLength is the length past which a field value is shown as a
+// block of text rather than inline
+const longFieldLength = 80
+
+// submissionField is a submitted field formatted for display. Fields sent
+// more than once, like checkboxes, have several values.
+type submissionField struct {
+	Name   string
+	Values []string
+	Long   bool
+}
+
+// submissionFields formats a submission's data for display, sorted by
+// field name
+func submissionFields(data json.RawMessage) ([]submissionField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	fields := make([]submissionField, 0, len(values))
+	for name, value := range values {
+		field := submissionField{Name: name}
+		if list, ok := value.([]interface{}); ok {
+			for _, item := range list {
+				field.Values = append(field.Values, fieldValue(item))
+			}
+		} else {
+			field.Values = []string{fieldValue(value)}
+		}
+		for _, value := range field.Values {
+			if len(value) > longFieldLength || strings.Contains(value, "\n") {
+				field.Long = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields, nil
+}
+
+// submittedValues returns a submission's fields as sent, with the values of
+// fields sent more than once joined, and without the honeypot field
+func submittedValues(submission *models.Submission) (map[string]string, error) {
+	fields, err := submissionFields(submission.SubmittedData)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		if field.Name != models.HoneypotField {
+			values[field.Name] = strings.Join(field.Values, ", ")
+		}
+	}
+	return values, nil
+}
+
+// fieldValue formats a single submitted value
+func fieldValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]interface{}:
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// SubmissionDetailHandler shows a single submission and handles the actions
+// taken on it
+type SubmissionDetailHandler struct {
+	DB           *database.Database
+	Templates    *templates.TemplateManager
+	EmailService *email.EmailService
+	// Notifier delivers submissions released from spam to the form's
+	// notification channels, when set
+	Notifier *notify.Dispatcher
+	// Files is where files uploaded with submissions are kept, when set
+	Files storage.Store
+	// LinkTTL is how long the links that file downloads redirect to last
+	LinkTTL time.Duration
+	// Uploads signs the links to files in resent notification emails,
+	// when set
+	Uploads *uploads.Uploader
+	// FileLinkTTL is how long those links work; 0 leaves them out
+	FileLinkTTL time.Duration
+	// Akismet is told about submissions it checked that owners reclassify,
+	// when set
+	Akismet *akismet.Client
+	// Unsubscribe gives the forward address a link in resent emails to
+	// mute the form's notifications, when set
+	Unsubscribe *unsubscribe.Links
+}
+
+// NewSubmissionDetailHandler creates a new submission detail handler
+func NewSubmissionDetailHandler(db *database.Database, tm *templates.TemplateManager, emailService *email.EmailService) *SubmissionDetailHandler {
+	return &SubmissionDetailHandler{
+		DB:           db,
+		Templates:    tm,
+		EmailService: emailService,
+		LinkTTL:      defaultLinkTTL,
+	}
+}
+
+// ViewSubmission renders the modal for a submission
+func (h *SubmissionDetailHandler) ViewSubmission(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+	h.render(w, r, user, form, submission, "", "")
+}
+
+// DownloadFile redirects the owner to a signed link that downloads a file
+// uploaded with a submission. Files are never served from a fixed path.
+func (h *SubmissionDetailHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	_, _, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+	if h.Files == nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "fileID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	file, err := models.GetSubmissionFileContext(r.Context(), h.DB.Connection, submission.ID, fileID)
+	if err != nil {
+		http.Error(w, "Failed to fetch file", http.StatusInternalServerError)
+		return
+	}
+	if file == nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	link, err := h.Files.SignedURL(r.Context(), file.StorageName, file.Filename, h.LinkTTL)
+	if err != nil {
+		log.Printf("Failed to sign a link to file %d: %v", file.ID, err)
+		http.Error(w, "Failed to download file", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, link, http.StatusFound)
+}
+
+// MarkSpam marks a submission as spam, or clears the mark when spam=0.
+// Clearing it sends the notification email if it hasn't been sent.
+func (h *SubmissionDetailHandler) MarkSpam(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	spam := r.FormValue("spam") != "0"
+	if err := models.SetSubmissionSpamContext(r.Context(), h.DB.Connection, submission.ID, spam); err != nil {
+		h.render(w, r, user, form, submission, "", "Failed to update submission")
+		return
+	}
+
+	h.akismetFeedback(form, submission, spam)
+	message, errorMsg := "Marked as spam", ""
+	if spam && submission.SpamAt == nil {
+		h.notifyChannels(notify.EventSubmissionSpamFlagged, form, submission, "Marked as spam by hand")
+	}
+	if !spam {
+		message = "Marked as not spam"
+		if submission.Status != "processed" {
+			message, errorMsg = h.notify(r.Context(), form, submission)
+		}
+		if submission.SpamAt != nil {
+			h.notifyChannels(notify.EventSubmissionCreated, form, submission, "")
+		}
+	}
+
+	submission, err := models.GetSubmissionByIDContext(r.Context(), h.DB.Connection, submission.ID)
+	if err != nil || submission == nil {
+		http.Error(w, "Failed to fetch submission", http.StatusInternalServerError)
+		return
+	}
+	h.render(w, r, user, form, submission, message, errorMsg)
+}
+
+// ResendEmail queues the notification email for a submission again
+func (h *SubmissionDetailHandler) ResendEmail(w http.ResponseWriter, r *http.Request) {
+	user, form, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	message, errorMsg := h.notify(r.Context(), form, submission)
+
+	submission, err := models.GetSubmissionByIDContext(r.Context(), h.DB.Connection, submission.ID)
+	if err != nil || submission == nil {
+		http.Error(w, "Failed to fetch submission", http.StatusInternalServerError)
+		return
+	}
+	h.render(w, r, user, form, submission, message, errorMsg)
+}
+
+// notify queues the notification email for a submission to the recipient
+// its form's routing rules pick, and records whether it was queued in its
+// status, like on submission, telling the form's channels if it fails. It returns the message or error to show.
+func (h *SubmissionDetailHandler) notify(ctx context.Context, form *models.Form, submission *models.Submission) (string, string) {
+	if h.EmailService == nil {
+		return "", "Email isn't configured"
+	}
+
+	formData, err := submittedValues(submission)
+	if err != nil {
+		return "", "Failed to read submission"
+	}
+
+	to, err := models.RoutedRecipientContext(ctx, h.DB.Connection, form, formData)
+	if err != nil {
+		return "", "Failed to read the form's routing rules"
+	}
+	unmuted, err := models.UnmutedRecipientsContext(ctx, h.DB.Connection, form.ID, []string{to})
+	if err != nil {
+		return "", "Failed to check the form's muted recipients"
+	}
+	if len(unmuted) == 0 {
+		return "", to + " muted this form's notifications"
+	}
+
+	if err := models.UpdateSubmissionStatusContext(ctx, h.DB.Connection, submission.ID, "processed"); err != nil {
+		return "", "Failed to update submission"
+	}
+	job := email.FormSubmissionJob([]string{to}, formData)
+	job.Thread = email.SubmissionThread(form.ID, form.ThreadBySender, formData)
+	h.addFileLinks(ctx, &job, submission.ID)
+	record := email.SubmissionRecord{ID: submission.ID, FormID: form.ID, CreatedAt: submission.CreatedAt, Fields: formData}
+	if err := email.AttachSubmission(&job, form.EmailAttachment, record); err != nil {
+		return "", "Failed to attach the submission"
+	}
+	if h.Unsubscribe != nil {
+		email.AddUnsubscribe(&job, h.Unsubscribe.URL(form.ID, to))
+	}
+	job.OnFailure = func(err error) { h.emailFailed(form, submission, err) }
+	if err := h.EmailService.Enqueue(job); err != nil {
+		log.Printf("Failed to queue email for submission %d: %v", submission.ID, err)
+		h.emailFailed(form, submission, err)
+		return "", "Failed to queue email"
+	}
+	return "Email queued for " + to, ""
+}
+
+// addFileLinks lists signed links to a submission's files in its email,
+// when they're turned on, like on submission
+func (h *SubmissionDetailHandler) addFileLinks(ctx context.Context, job *email.EmailJob, submissionID int64) {
+	if h.Uploads == nil || h.FileLinkTTL <= 0 {
+		return
+	}
+	links, err := h.Uploads.EmailLinks(ctx, submissionID, h.FileLinkTTL, h.Templates.BaseURL())
+	if err != nil {
+		log.Printf("Failed to link the files of submission %d in its email: %v", submissionID, err)
+		return
+	}
+	email.AddFileLinks(job, links)
+}
+
+// emailFailed marks a submission whose email couldn't be queued or sent as
+// failed, and tells the form's channels
+func (h *SubmissionDetailHandler) emailFailed(form *models.Form, submission *models.Submission, sendErr error) {
+	if err := models.UpdateSubmissionStatusContext(context.Background(), h.DB.Connection, submission.ID, "failed"); err != nil {
+		log.Printf("Failed to update submission %d: %v", submission.ID, err)
+	}
+	h.notifyChannels(notify.EventEmailFailed, form, submission, "Email to the form's recipient failed: "+sendErr.Error())
+}
+
+// notifyChannels queues an event about a submission for the form's
+// notification channels, such as a submission released from spam, which
+// they skipped while it was held
+func (h *SubmissionDetailHandler) notifyChannels(event string, form *models.Form, submission *models.Submission, detail string) {
+	if h.Notifier == nil {
+		return
+	}
+	if err := h.Notifier.NotifySubmissionEvent(context.Background(), event, form, submission, detail); err != nil {
+		log.Printf("Failed to queue notifications for submission %d: %v", submission.ID, err)
+	}
+}
+
+// akismetFeedback tells Akismet in the background when an owner disagrees
+// with how it scored a submission, so it learns from the mistake
+func (h *SubmissionDetailHandler) akismetFeedback(form *models.Form, submission *models.Submission, spam bool) {
+	if h.Akismet == nil || !form.AkismetEnabled || submission.SpamScore == nil {
+		return
+	}
+	if spam == (*submission.SpamScore >= akismet.ScoreSpam) {
+		return
+	}
+	formData, err := submittedValues(submission)
+	if err != nil {
+		log.Printf("Failed to read submission %d for Akismet: %v", submission.ID, err)
+		return
+	}
+	comment := akismet.NewComment(form.Domain, formData, submission.IPAddress, submission.UserAgent, submission.Referrer)
+	comment.CreatedAt = submission.CreatedAt
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), akismetFeedbackTimeout)
+		defer cancel()
+		submit := h.Akismet.SubmitHam
+		if spam {
+			submit = h.Akismet.SubmitSpam
+		}
+		if err := submit(ctx, comment); err != nil {
+			log.Printf("Failed to tell Akismet about submission %d: %v", submission.ID, err)
+		}
+	}()
+}
+
+// DeleteSubmission deletes a submission and reloads the submissions page
+func (h *SubmissionDetailHandler) DeleteSubmission(w http.ResponseWriter, r *http.Request) {
+	_, _, submission, ok := ownedSubmission(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteSubmissionContext(r.Context(), h.DB.Connection, submission.ID); err != nil {
+		http.Error(w, "Failed to delete submission", http.StatusInternalServerError)
+		return
+	}
+
+	flash.Set(w, "Submission deleted")
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// render renders the submission modal
+func (h *SubmissionDetailHandler) render(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, submission *models.Submission, message, errorMsg string) {
+	data, err := h.detail(r.Context(), form, submission)
+	if err != nil {
+		http.Error(w, "Failed to fetch submission", http.StatusInternalServerError)
+		return
+	}
+	data["Message"] = message
+
+	if err := h.Templates.Render(w, "partials/submission_modal.html", templates.TemplateData{
+		Title: "Submission",
+		User:  user,
+		Error: errorMsg,
+		Data:  data,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// detail gathers what the submission modal shows
+func (h *SubmissionDetailHandler) detail(ctx context.Context, form *models.Form, submission *models.Submission) (map[string]interface{}, error) {
+	fields, err := submissionFields(submission.SubmittedData)
+	if err != nil {
+		return nil, err
+	}
+	// Not every delivery has an email record, so this may be nil
+	emailRecord, err := models.GetSubmissionEmailBySubmissionIDContext(ctx, h.DB.Connection, submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := models.GetSubmissionFilesContext(ctx, h.DB.Connection, submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"Form":        form,
+		"Submission":  submission,
+		"Fields":      fields,
+		"Files":       files,
+		"EmailRecord": emailRecord,
+	}, nil
+}
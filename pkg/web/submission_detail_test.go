@@ -0,0 +1,265 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/email"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+	"staticsend/pkg/templates"
+)
+
+func TestSubmissionFields(t *testing.T) {
+	fields, err := submissionFields(json.RawMessage(`{"name":"Ada","topics":["billing","support"],"age":36,"message":"Line one\nLine two"}`))
+	if err != nil {
+		t.Fatalf("Failed to format fields: %v", err)
+	}
+	if len(fields) != 4 || fields[0].Name != "age" || fields[3].Name != "topics" {
+		t.Fatalf("Expected the fields sorted by name, got %+v", fields)
+	}
+	if fields[0].Values[0] != "36" {
+		t.Errorf("Expected numbers as written, got %q", fields[0].Values[0])
+	}
+	if !fields[1].Long || fields[2].Long {
+		t.Errorf("Expected only the multi-line message to be long, got %+v", fields)
+	}
+	if len(fields[3].Values) != 2 || fields[3].Values[1] != "support" {
+		t.Errorf("Expected each array value, got %+v", fields[3].Values)
+	}
+}
+
+func TestSubmissionDetailHandler(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "detail-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	submission, err := models.CreateReferredSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", "https://example.com/contact",
+		json.RawMessage(`{"name":"Ada","topics":["billing","support"]}`))
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+
+	// No workers, so queued emails are never sent
+	emailService := email.NewEmailService(email.EmailConfig{Host: "localhost", Port: 25}, 10, 0, 0)
+	handler := NewSubmissionDetailHandler(db, templates.NewTemplateManager(), emailService)
+
+	serve := func(h http.HandlerFunc, user *models.User, method string, values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/forms/1/submissions/1", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		rctx.URLParams.Add("submissionID", strconv.FormatInt(submission.ID, 10))
+		ctx := context.WithValue(req.Context(), middleware.UserKey, user)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	t.Run("view", func(t *testing.T) {
+		rr := serve(handler.ViewSubmission, owner, "GET", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		for _, want := range []string{"Ada", "<li>billing</li>", "203.0.113.7", "Test Browser", "https://example.com/contact", "pending"} {
+			if !strings.Contains(rr.Body.String(), want) {
+				t.Errorf("Expected %q in the modal", want)
+			}
+		}
+
+		rr = serve(handler.ViewSubmission, other, "GET", nil)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for another user, got %d", rr.Code)
+		}
+	})
+
+	t.Run("mark spam", func(t *testing.T) {
+		rr := serve(handler.MarkSpam, owner, "POST", nil)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Not spam") {
+			t.Fatalf("Expected the modal with a not spam action, got %d", rr.Code)
+		}
+		marked, _ := models.GetSubmissionByID(db.Connection, submission.ID)
+		if marked.SpamAt == nil {
+			t.Error("Expected the submission to be marked as spam")
+		}
+
+		serve(handler.MarkSpam, owner, "POST", url.Values{"spam": {"0"}})
+		cleared, _ := models.GetSubmissionByID(db.Connection, submission.ID)
+		if cleared.SpamAt != nil {
+			t.Error("Expected the spam mark to be cleared")
+		}
+		// The submission was still pending, so clearing the mark sends its email
+		if emailService.QueueSize() != 1 || cleared.Status != "processed" {
+			t.Errorf("Expected the email to be queued, got %d queued and status %s", emailService.QueueSize(), cleared.Status)
+		}
+	})
+
+	t.Run("resend", func(t *testing.T) {
+		rr := serve(handler.ResendEmail, owner, "POST", nil)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Email queued for to@example.com") {
+			t.Fatalf("Expected the email to be queued, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if emailService.QueueSize() != 2 {
+			t.Errorf("Expected a second queued email, got %d", emailService.QueueSize())
+		}
+		resent, _ := models.GetSubmissionByID(db.Connection, submission.ID)
+		if resent.Status != "processed" {
+			t.Errorf("Expected the submission to be processed, got %s", resent.Status)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		rr := serve(handler.DeleteSubmission, other, "DELETE", nil)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("Expected status 404 for another user, got %d", rr.Code)
+		}
+
+		rr = serve(handler.DeleteSubmission, owner, "DELETE", nil)
+		if rr.Code != http.StatusOK || rr.Header().Get("HX-Refresh") != "true" {
+			t.Fatalf("Expected a refresh, got %d", rr.Code)
+		}
+		deleted, err := models.GetSubmissionByID(db.Connection, submission.ID)
+		if err != nil || deleted != nil {
+			t.Errorf("Expected the submission to be deleted, got %+v (err %v)", deleted, err)
+		}
+	})
+}
+
+func TestSubmissionDetailHandler_DownloadFile(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Jobs", "example.com", "secret", "to@example.com", "files-key")
+	submission, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", json.RawMessage(`{"cv":"cv.pdf"}`))
+
+	store, err := storage.NewLocalStore(t.TempDir(), "/files/uploads", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.Put(context.Background(), "upload-abc.pdf", strings.NewReader("resume"))
+	file := &models.SubmissionFile{SubmissionID: submission.ID, Field: "cv", Filename: "cv.pdf", Size: 6, StorageName: "upload-abc.pdf"}
+	if err := models.CreateSubmissionFile(db.Connection, file); err != nil {
+		t.Fatalf("Failed to record file: %v", err)
+	}
+
+	handler := NewSubmissionDetailHandler(db, templates.NewTemplateManager(), nil)
+	handler.Files = store
+
+	serve := func(h http.HandlerFunc, user *models.User, fileID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/forms/1/submissions/1/files/1", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		rctx.URLParams.Add("submissionID", strconv.FormatInt(submission.ID, 10))
+		rctx.URLParams.Add("fileID", fileID)
+		ctx := context.WithValue(req.Context(), middleware.UserKey, user)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+	fileID := strconv.FormatInt(file.ID, 10)
+
+	rr := serve(handler.ViewSubmission, owner, "")
+	if !strings.Contains(rr.Body.String(), "cv.pdf") || !strings.Contains(rr.Body.String(), "/files/"+fileID) {
+		t.Errorf("Expected the file to be listed, got %s", rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "upload-abc") {
+		t.Error("Expected the file's stored name to stay hidden")
+	}
+
+	rr = serve(handler.DownloadFile, owner, fileID)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect, got %d: %s", rr.Code, rr.Body.String())
+	}
+	link := rr.Header().Get("Location")
+	if !strings.HasPrefix(link, "/files/uploads/upload-abc.pdf?") {
+		t.Fatalf("Expected a signed link to the stored file, got %s", link)
+	}
+	download := httptest.NewRecorder()
+	store.ServeHTTP(download, httptest.NewRequest("GET", link, nil))
+	if download.Code != http.StatusOK || download.Body.String() != "resume" {
+		t.Errorf("Expected the signed link to download the file, got %d: %s", download.Code, download.Body.String())
+	}
+
+	if rr := serve(handler.DownloadFile, other, fileID); rr.Code == http.StatusFound {
+		t.Error("Expected another user not to get a link")
+	}
+	if rr := serve(handler.DownloadFile, owner, "999"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown file, got %d", rr.Code)
+	}
+}
+
+func TestSubmissionDetailHandler_EscapesSubmittedValues(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "xss-key")
+	data, _ := json.Marshal(map[string]interface{}{
+		"name":                  `"><img src=x onerror=alert(1)>`,
+		"message":               "Hi <script>alert(1)</script>\r\nline two",
+		"topics":                []string{"<b>bold</b>", "fine"},
+		"<svg/onload=alert(1)>": "field name",
+		"file\u202efdp.exe":     "reversed",
+	})
+	submission, err := models.CreateReferredSubmission(db.Connection, form.ID, "203.0.113.7", "<script>alert('ua')</script>", `javascript:alert(1)"><script>`, data)
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+
+	handler := NewSubmissionDetailHandler(db, templates.NewTemplateManager(), nil)
+	req := httptest.NewRequest("GET", "/forms/1/submissions/1", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+	rctx.URLParams.Add("submissionID", strconv.FormatInt(submission.ID, 10))
+	ctx := context.WithValue(req.Context(), middleware.UserKey, owner)
+	rr := httptest.NewRecorder()
+	handler.ViewSubmission(rr, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	body := rr.Body.String()
+	for _, unsafe := range []string{"<script", "<img", "<svg", "<b>", "\u202e"} {
+		if strings.Contains(body, unsafe) {
+			t.Errorf("Expected %q to be escaped or removed, got:\n%s", unsafe, body)
+		}
+	}
+	for _, want := range []string{
+		"&lt;script&gt;alert(1)&lt;/script&gt;<br>line two",
+		"&#34;&gt;&lt;img src=x onerror=alert(1)&gt;",
+		"&lt;b&gt;bold&lt;/b&gt;",
+		"filefdp.exe",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the page to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/httperr"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+const (
+	// submissionsPageSize is how many submissions each page of the table shows
+	submissionsPageSize = 25
+	// fieldNameSample is how many recent submissions are read to find the
+	// fields offered in the column picker
+	fieldNameSample = 200
+	// defaultColumnCount is how many fields are shown before a user picks
+	defaultColumnCount = 3
+)
+
+// submissionFilterParams are the query parameters that filter the table
+var submissionFilterParams = []string{"status", "inbox", "assignee", "from", "to"}
+
+// preferredColumns are shown by default when a form has these fields
+var preferredColumns = []string{"name", "email", "subject", "message"}
+
+// submissionsQuery keeps only the filter parameters of a query, dropping
+// blanks, so they can be carried between the page and the table
+func submissionsQuery(values url.Values) url.Values {
+	query := url.Values{}
+	for _, param := range submissionFilterParams {
+		if value := values.Get(param); value != "" {
+			query.Set(param, value)
+		}
+	}
+	return query
+}
+
+// parseSubmissionFilter reads the table's filters. Dates are whole days in
+// UTC, with both ends included.
+func parseSubmissionFilter(values url.Values, userID int64) (models.SubmissionFilter, error) {
+	filter := models.SubmissionFilter{}
+	if status := values.Get("status"); status != "" {
+		if !slices.Contains(models.SubmissionStatuses, status) {
+			return filter, fmt.Errorf("invalid status %q", status)
+		}
+		filter.Status = status
+	}
+	if inbox := values.Get("inbox"); inbox != "" {
+		if !models.IsValidInboxStatus(inbox) {
+			return filter, fmt.Errorf("invalid inbox status %q", inbox)
+		}
+		filter.InboxStatus = inbox
+	}
+	if values.Get("assignee") == "me" {
+		filter.AssigneeID = userID
+	}
+	if from := values.Get("from"); from != "" {
+		day, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date %q", from)
+		}
+		filter.From = day
+	}
+	if to := values.Get("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date %q", to)
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// defaultColumns picks the fields shown before a user chooses their own,
+// preferring common contact form fields
+func defaultColumns(fields []string) []string {
+	columns := []string{}
+	for _, name := range preferredColumns {
+		if len(columns) < defaultColumnCount && slices.Contains(fields, name) {
+			columns = append(columns, name)
+		}
+	}
+	for _, name := range fields {
+		if len(columns) < defaultColumnCount && !slices.Contains(columns, name) {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// SubmissionsTable renders a page of a form's submissions matching the
+// filters in the query
+func (h *WebHandler) SubmissionsTable(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+	h.renderSubmissionsTable(w, r, user, form, r.URL.Query())
+}
+
+// UpdateSubmissionColumns saves which fields the user shows as columns of a
+// form's submissions and renders the table with them
+func (h *WebHandler) UpdateSubmissionColumns(w http.ResponseWriter, r *http.Request) {
+	user, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	// Columns are stored in the order the fields are offered
+	fields, err := models.GetSubmissionFieldNamesContext(r.Context(), h.DB.Connection, form.ID, fieldNameSample)
+	if err != nil {
+		http.Error(w, "Failed to fetch fields", http.StatusInternalServerError)
+		return
+	}
+	columns := []string{}
+	for _, name := range fields {
+		if slices.Contains(r.Form["columns"], name) {
+			columns = append(columns, name)
+		}
+	}
+
+	if err := models.SetSubmissionColumnsContext(r.Context(), h.DB.Connection, user.ID, form.ID, columns); err != nil {
+		http.Error(w, "Failed to save columns", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderSubmissionsTable(w, r, user, form, r.Form)
+}
+
+// renderSubmissionsTable renders the submissions table partial for the
+// filters and page in values
+func (h *WebHandler) renderSubmissionsTable(w http.ResponseWriter, r *http.Request, user *models.User, form *models.Form, values url.Values) {
+	filter, err := parseSubmissionFilter(values, user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := strconv.Atoi(values.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	total, err := models.CountSubmissionsContext(r.Context(), h.DB.Connection, form.ID, filter)
+	if err != nil {
+		http.Error(w, "Failed to count submissions", http.StatusInternalServerError)
+		return
+	}
+	totalPages := (total + submissionsPageSize - 1) / submissionsPageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+
+	submissions, err := models.GetSubmissionsPageContext(r.Context(), h.DB.Connection, form.ID, filter, submissionsPageSize, (page-1)*submissionsPageSize)
+	if err != nil {
+		http.Error(w, "Failed to fetch submissions", http.StatusInternalServerError)
+		return
+	}
+
+	fields, err := models.GetSubmissionFieldNamesContext(r.Context(), h.DB.Connection, form.ID, fieldNameSample)
+	if err != nil {
+		http.Error(w, "Failed to fetch fields", http.StatusInternalServerError)
+		return
+	}
+	columns, err := models.GetSubmissionColumnsContext(r.Context(), h.DB.Connection, user.ID, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch columns", http.StatusInternalServerError)
+		return
+	}
+	if columns == nil {
+		columns = defaultColumns(fields)
+	}
+
+	assignments, err := models.GetAssignmentsByFormIDContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch assignments", http.StatusInternalServerError)
+		return
+	}
+	noteCounts, err := models.GetSubmissionNoteCountsByFormIDContext(r.Context(), h.DB.Connection, form.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch notes", http.StatusInternalServerError)
+		return
+	}
+
+	// Page links keep the current filters
+	query := submissionsQuery(values)
+	filtered := len(query) > 0
+	pageURL := func(page int) template.URL {
+		query.Set("page", strconv.Itoa(page))
+		return template.URL(fmt.Sprintf("/forms/%d/submissions/table?%s", form.ID, query.Encode()))
+	}
+	data := map[string]interface{}{
+		"Form":        form,
+		"Submissions": submissions,
+		"Fields":      fields,
+		"Columns":     columns,
+		"ColumnSpan":  len(columns) + 4,
+		"Assignments": assignments,
+		"NoteCounts":  noteCounts,
+		"Total":       total,
+		"Filtered":    filtered,
+		"Page":        page,
+		"TotalPages":  totalPages,
+	}
+	if page > 1 {
+		data["PrevURL"] = pageURL(page - 1)
+	}
+	if page < totalPages {
+		data["NextURL"] = pageURL(page + 1)
+	}
+
+	if err := h.TemplateManager.Render(w, "partials/submissions_table.html", templates.TemplateData{
+		User: user,
+		Data: data,
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// ownedForm loads the form in the URL from the handler's database
+func (h *WebHandler) ownedForm(w http.ResponseWriter, r *http.Request) (*models.User, *models.Form, bool) {
+	return ownedForm(w, r, h.DB)
+}
+
+// ownedForm loads the form in the URL if the user owns it; other users'
+// forms aren't found, like missing ones. It writes an error response and
+// returns false if not.
+func ownedForm(w http.ResponseWriter, r *http.Request, db *database.Database) (*models.User, *models.Form, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		httperr.Write(w, r, http.StatusUnauthorized, httperr.Unauthorized, "Unauthorized")
+		return nil, nil, false
+	}
+
+	formID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httperr.Write(w, r, http.StatusBadRequest, httperr.ValidationError, "Invalid form ID")
+		return nil, nil, false
+	}
+
+	form, err := models.GetFormByIDForUserContext(r.Context(), db.Connection, formID, user.ID)
+	if err != nil {
+		httperr.Write(w, r, http.StatusInternalServerError, httperr.Internal, "Failed to fetch form")
+		return nil, nil, false
+	}
+	if form == nil {
+		httperr.Write(w, r, http.StatusNotFound, httperr.FormNotFound, "Form not found")
+		return nil, nil, false
+	}
+
+	return user, form, true
+}
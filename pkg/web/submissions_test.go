@@ -0,0 +1,95 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestWebHandler_SubmissionsTable(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := models.CreateUser(db.Connection, "user@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, user.ID, "Contact", "example.com", "secret", "to@example.com", "table-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	for i := 0; i < submissionsPageSize+1; i++ {
+		data := fmt.Sprintf(`{"name":"Sender %d","email":"sender%d@example.com","company":"Acme"}`, i, i)
+		submission, err := models.CreateSubmission(db.Connection, form.ID, "127.0.0.1", "test", json.RawMessage(data))
+		if err != nil {
+			t.Fatalf("Failed to create submission: %v", err)
+		}
+		if i == 0 {
+			models.UpdateSubmissionStatus(db.Connection, submission.ID, "failed")
+		}
+	}
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+
+	serve := func(h http.HandlerFunc, method, query string, values url.Values) string {
+		req := httptest.NewRequest(method, "/forms/1/submissions/table?"+query, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	// The first page holds the newest submissions and links to the next
+	body := serve(handler.SubmissionsTable, "GET", "", nil)
+	if !strings.Contains(body, "Page 1 of 2") || !strings.Contains(body, "page=2") {
+		t.Error("Expected the first of two pages")
+	}
+	if !strings.Contains(body, "Sender 25") || strings.Contains(body, "Sender 0<") {
+		t.Error("Expected the newest submissions on the first page")
+	}
+	// Common fields are shown by default
+	if !strings.Contains(body, ">email</th>") || !strings.Contains(body, ">name</th>") || !strings.Contains(body, ">company</th>") {
+		t.Error("Expected the form's fields as the default columns")
+	}
+
+	body = serve(handler.SubmissionsTable, "GET", "status=failed", nil)
+	if !strings.Contains(body, "1 matching submissions") || !strings.Contains(body, "Sender 0") {
+		t.Error("Expected only the failed submission")
+	}
+
+	body = serve(handler.SubmissionsTable, "GET", "from=2000-01-01&to=2000-01-31", nil)
+	if !strings.Contains(body, "No matching submissions") {
+		t.Error("Expected no submissions outside the date range")
+	}
+
+	// Column choices are saved for the user and keep the filters
+	body = serve(handler.UpdateSubmissionColumns, "POST", "", url.Values{"columns": {"company", "unknown"}, "status": {"failed"}})
+	if !strings.Contains(body, ">company</th>") || strings.Contains(body, ">email</th>") || !strings.Contains(body, "1 matching submissions") {
+		t.Error("Expected only the chosen column with the filters kept")
+	}
+	columns, err := models.GetSubmissionColumns(db.Connection, user.ID, form.ID)
+	if err != nil {
+		t.Fatalf("Failed to get columns: %v", err)
+	}
+	if len(columns) != 1 || columns[0] != "company" {
+		t.Errorf("Expected only known fields to be saved, got %v", columns)
+	}
+}
@@ -9,6 +9,49 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// testMigrations lists the migration files applied to test databases
+var testMigrations = []string{
+	"001_initial_schema.up.sql",
+	"002_app_settings.up.sql",
+	"005_ip_rules.up.sql",
+	"006_maintenance_mode.up.sql",
+	"007_user_roles.up.sql",
+	"009_submission_archives.up.sql",
+	"010_user_quotas.up.sql",
+	"011_form_tags.up.sql",
+	"012_submission_inbox.up.sql",
+	"013_submission_columns.up.sql",
+	"014_user_theme.up.sql",
+	"015_submission_details.up.sql",
+	"016_setup_settings.up.sql",
+	"017_branding.up.sql",
+	"018_form_status_pages.up.sql",
+	"019_spam_queue.up.sql",
+	"020_export_jobs.up.sql",
+	"021_notification_channels.up.sql",
+	"022_google_sheets.up.sql",
+	"023_api_keys.up.sql",
+	"024_channel_events.up.sql",
+	"025_submission_files.up.sql",
+	"026_akismet.up.sql",
+	"027_submission_feeds.up.sql",
+	"028_email_threading.up.sql",
+	"029_auth_turnstile_settings.up.sql",
+	"030_turnstile_fallback.up.sql",
+	"031_turnstile_site_key.up.sql",
+	"032_spam_scoring.up.sql",
+	"033_geoip.up.sql",
+	"034_data_requests.up.sql",
+	"035_form_fields.up.sql",
+	"036_email_bounces.up.sql",
+	"037_notification_mutes.up.sql",
+	"038_form_routes.up.sql",
+	"039_email_attachment.up.sql",
+	"040_hosted_page.up.sql",
+	"041_form_cc.up.sql",
+	"042_signup_lock.up.sql",
+}
+
 func setupTestDB(t *testing.T) *sql.DB {
 	tempDir := t.TempDir()
 	dbPath := filepath.Join(tempDir, "test.db")
@@ -23,24 +66,15 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("Failed to enable foreign keys: %v", err)
 	}
 
-	// Run initial migration
-	migrationSQL, err := os.ReadFile("../../migrations/001_initial_schema.up.sql")
-	if err != nil {
-		t.Fatalf("Failed to read migration file: %v", err)
-	}
-
-	if _, err := db.Exec(string(migrationSQL)); err != nil {
-		t.Fatalf("Failed to execute migration: %v", err)
-	}
-
-	// Run app settings migration
-	migrationSQL, err = os.ReadFile("../../migrations/002_app_settings.up.sql")
-	if err != nil {
-		t.Fatalf("Failed to read app settings migration file: %v", err)
-	}
+	for _, file := range testMigrations {
+		migrationSQL, err := os.ReadFile("../../migrations/" + file)
+		if err != nil {
+			t.Fatalf("Failed to read migration file: %v", err)
+		}
 
-	if _, err := db.Exec(string(migrationSQL)); err != nil {
-		t.Fatalf("Failed to execute app settings migration: %v", err)
+		if _, err := db.Exec(string(migrationSQL)); err != nil {
+			t.Fatalf("Failed to execute migration %s: %v", file, err)
+		}
 	}
 
 	return db
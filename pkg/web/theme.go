@@ -0,0 +1,33 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+)
+
+// UpdateTheme saves the signed-in user's theme and reloads the page so it
+// is rendered with the new theme
+func (h *WebHandler) UpdateTheme(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	theme := r.FormValue("theme")
+	if !slices.Contains(models.Themes, theme) {
+		http.Error(w, "Invalid theme", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UpdateUserThemeContext(r.Context(), h.DB.Connection, user.ID, theme); err != nil {
+		http.Error(w, "Failed to save theme", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"staticsend/pkg/database"
+	"staticsend/pkg/flash"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+	"staticsend/pkg/transfer"
+)
+
+// maxTransferSize caps an uploaded form export
+const maxTransferSize = 64 << 20
+
+// TransfersHandler moves forms between staticSend instances: it downloads
+// everything about a form as JSON, and imports those files as new forms
+type TransfersHandler struct {
+	DB        *database.Database
+	Templates *templates.TemplateManager
+}
+
+// NewTransfersHandler creates a new transfers handler
+func NewTransfersHandler(db *database.Database, tm *templates.TemplateManager) *TransfersHandler {
+	return &TransfersHandler{
+		DB:        db,
+		Templates: tm,
+	}
+}
+
+// ExportForm downloads a form's settings, tags, IP rules, routing rules,
+// submissions and email records as JSON
+func (h *TransfersHandler) ExportForm(w http.ResponseWriter, r *http.Request) {
+	_, form, ok := ownedForm(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="form-%s-%s.json"`, form.FormKey, time.Now().UTC().Format("20060102")))
+	if err := transfer.Export(r.Context(), h.DB, form.ID, w); err != nil {
+		// Headers may have been sent, so the download is cut short
+		log.Printf("Failed to export form %d: %v", form.ID, err)
+		http.Error(w, "Failed to export form", http.StatusInternalServerError)
+	}
+}
+
+// ImportFormModal renders the modal to import a form exported from another
+// instance
+func (h *TransfersHandler) ImportFormModal(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.Templates.Render(w, "partials/import_form_modal.html", templates.TemplateData{User: user}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// ImportForm creates a form from an uploaded export, then returns to the
+// dashboard. Problems with the file are shown in the modal.
+func (h *TransfersHandler) ImportForm(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxTransferSize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.renderError(w, user, fmt.Sprintf("Exports are limited to %d MB", maxTransferSize>>20))
+			return
+		}
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	upload, _, err := r.FormFile("file")
+	if err != nil {
+		h.renderError(w, user, "Choose a form exported from staticSend")
+		return
+	}
+	defer upload.Close()
+	file, err := transfer.Read(upload)
+	if err != nil {
+		h.renderError(w, user, "Couldn't read the export: "+err.Error())
+		return
+	}
+
+	if message, err := h.checkLimits(r, user, file); err != nil {
+		http.Error(w, "Failed to check usage limits", http.StatusInternalServerError)
+		return
+	} else if message != "" {
+		h.renderError(w, user, message)
+		return
+	}
+
+	form, err := transfer.Import(r.Context(), h.DB, user.ID, file)
+	if err != nil {
+		log.Printf("Failed to import form %q: %v", file.Form.Name, err)
+		h.renderError(w, user, "Failed to import form")
+		return
+	}
+
+	message := fmt.Sprintf("Form %q imported with %d submissions", form.Name, len(file.Submissions))
+	if form.FormKey != file.Form.FormKey {
+		message += ". Its form key was taken, so update your site to post to the new one"
+	}
+	flash.Set(w, message)
+	w.Header().Set("HX-Redirect", "/dashboard")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkLimits returns why importing a form would take the user over their
+// form or storage limit, or "" if it wouldn't
+func (h *TransfersHandler) checkLimits(r *http.Request, user *models.User, file *transfer.File) (string, error) {
+	quota, err := models.GetUserQuotaContext(r.Context(), h.DB.Connection, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if quota.MaxForms > 0 {
+		usage, err := models.GetUsageContext(r.Context(), h.DB.Connection, user.ID, time.Now())
+		if err != nil {
+			return "", err
+		}
+		if quota.FormsExceeded(usage) {
+			return fmt.Sprintf("You have reached your limit of %d forms", quota.MaxForms), nil
+		}
+	}
+
+	size := int64(0)
+	for _, submission := range file.Submissions {
+		size += int64(len(submission.SubmittedData))
+	}
+	over, err := overStorage(r.Context(), h.DB, user.ID, size)
+	if err != nil || !over {
+		return "", err
+	}
+	return "Importing this form would go over your storage limit", nil
+}
+
+// renderError shows why an import failed in the modal
+func (h *TransfersHandler) renderError(w http.ResponseWriter, user *models.User, errorMsg string) {
+	if err := h.Templates.Render(w, "partials/import_result.html", templates.TemplateData{
+		User:  user,
+		Error: errorMsg,
+		Data:  map[string]interface{}{},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
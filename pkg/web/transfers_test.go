@@ -0,0 +1,88 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+func TestExportImportForm(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	other, _ := models.CreateUser(db.Connection, "other@example.com", "hash")
+	form, err := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "to@example.com", "transfer-key")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	models.CreateSubmission(db.Connection, form.ID, "203.0.113.1", "Browser", []byte(`{"message":"hello"}`))
+	handler := NewTransfersHandler(db, templates.NewTemplateManager())
+
+	export := func(user *models.User) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, user)
+		rr := httptest.NewRecorder()
+		handler.ExportForm(rr, req.WithContext(ctx))
+		return rr
+	}
+	if rr := export(other); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's form, got %d", rr.Code)
+	}
+	rr := export(owner)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Header().Get("Content-Disposition"), "form-transfer-key-") {
+		t.Fatalf("Expected the export to download, got %d: %v", rr.Code, rr.Header())
+	}
+
+	upload := func(user *models.User, contents []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", "form.json")
+		part.Write(contents)
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserKey, user))
+		rr := httptest.NewRecorder()
+		handler.ImportForm(rr, req)
+		return rr
+	}
+	if rr := upload(other, []byte(`{"version": 99}`)); !strings.Contains(rr.Body.String(), "unsupported export version") {
+		t.Errorf("Expected a newer export to be refused, got %s", rr.Body.String())
+	}
+
+	// The key is taken on this instance, so the import gets a new one
+	imported := upload(other, rr.Body.Bytes())
+	if imported.Code != http.StatusCreated || imported.Header().Get("HX-Redirect") != "/dashboard" {
+		t.Fatalf("Expected the import to return to the dashboard, got %d: %s", imported.Code, imported.Body.String())
+	}
+	forms, _ := models.GetFormsByUserID(db.Connection, other.ID)
+	if len(forms) != 1 || forms[0].FormKey == form.FormKey {
+		t.Fatalf("Expected one form with a new key, got %+v", forms)
+	}
+	submissions, _ := models.GetSubmissionsByFormID(db.Connection, forms[0].ID)
+	if len(submissions) != 1 {
+		t.Errorf("Expected the submission to be imported, got %d", len(submissions))
+	}
+}
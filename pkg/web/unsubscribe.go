@@ -0,0 +1,100 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+)
+
+// UnsubscribePage asks a notification email's recipient to confirm muting
+// the form's notifications, from the link in the email. It doesn't mute on
+// its own, since mail scanners follow links.
+func (h *WebHandler) UnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	form, address, ok := h.unsubscribeLink(w, r)
+	if !ok {
+		return
+	}
+	unmuted, err := models.UnmutedRecipientsContext(r.Context(), h.DB.Connection, form.ID, []string{address})
+	if err != nil {
+		http.Error(w, "Failed to fetch muted recipients", http.StatusInternalServerError)
+		return
+	}
+	h.renderUnsubscribe(w, r, form, address, len(unmuted) == 0)
+}
+
+// Unsubscribe mutes a form's notifications for the recipient of the link,
+// confirmed on the page or posted by a mail client's one-click unsubscribe
+func (h *WebHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	form, address, ok := h.unsubscribeLink(w, r)
+	if !ok {
+		return
+	}
+	if err := models.MuteRecipientContext(r.Context(), h.DB.Connection, form.ID, address); err != nil {
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	// Mail clients unsubscribing in one click don't show the page
+	if r.PostFormValue("List-Unsubscribe") == "One-Click" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	h.renderUnsubscribe(w, r, form, address, true)
+}
+
+// UnmuteRecipient sends a form's notifications to a recipient that muted
+// them again, and shows the form again
+func (h *WebHandler) UnmuteRecipient(w http.ResponseWriter, r *http.Request) {
+	_, form, ok := h.ownedForm(w, r)
+	if !ok {
+		return
+	}
+	if err := models.UnmuteRecipientContext(r.Context(), h.DB.Connection, form.ID, r.FormValue("address")); err != nil {
+		http.Error(w, "Failed to unmute the recipient", http.StatusInternalServerError)
+		return
+	}
+	h.ViewFormModal(w, r)
+}
+
+// unsubscribeLink returns the form and address of a signed unsubscribe
+// link, from its query or the confirmation form. Links that aren't signed,
+// or whose form was deleted, are not found.
+func (h *WebHandler) unsubscribeLink(w http.ResponseWriter, r *http.Request) (*models.Form, string, bool) {
+	formID, err := strconv.ParseInt(r.FormValue("form"), 10, 64)
+	address := r.FormValue("address")
+	if h.UnsubscribeLinks == nil || err != nil || address == "" || !h.UnsubscribeLinks.Verify(formID, address, r.FormValue("signature")) {
+		http.Error(w, "Unsubscribe link not found", http.StatusNotFound)
+		return nil, "", false
+	}
+
+	form, err := models.GetFormByIDContext(r.Context(), h.DB.Connection, formID)
+	if err != nil {
+		http.Error(w, "Failed to fetch form", http.StatusInternalServerError)
+		return nil, "", false
+	}
+	if form == nil {
+		http.Error(w, "Unsubscribe link not found", http.StatusNotFound)
+		return nil, "", false
+	}
+	return form, address, true
+}
+
+// renderUnsubscribe renders the unsubscribe page, confirming the address
+// is muted or asking it to confirm
+func (h *WebHandler) renderUnsubscribe(w http.ResponseWriter, r *http.Request, form *models.Form, address string, muted bool) {
+	// The page is only reached from links in emails
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if err := h.TemplateManager.Render(w, "unsubscribe.html", templates.TemplateData{
+		Title: "Unsubscribe - " + form.Name,
+		Data: map[string]interface{}{
+			"FormID":    form.ID,
+			"FormName":  form.Name,
+			"Address":   address,
+			"Signature": r.FormValue("signature"),
+			"Muted":     muted,
+		},
+	}); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	}
+}
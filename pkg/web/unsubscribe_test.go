@@ -0,0 +1,94 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/middleware"
+	"staticsend/pkg/models"
+	"staticsend/pkg/templates"
+	"staticsend/pkg/unsubscribe"
+)
+
+func TestWebHandler_Unsubscribe(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Contact", "example.com", "secret", "inbox@example.com", "unsubscribe-key")
+	links := unsubscribe.NewLinks([]byte("secret"), func() string { return "https://forms.example.com" })
+	handler := NewWebHandler(db, templates.NewTemplateManager(), "")
+	handler.UnsubscribeLinks = links
+
+	link, _ := url.Parse(links.URL(form.ID, "inbox@example.com"))
+	serve := func(h http.HandlerFunc, method, query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/unsubscribe?"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	forged := link.Query()
+	forged.Set("address", "other@example.com")
+	if rr := serve(handler.Unsubscribe, "POST", forged.Encode(), ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a forged link not found, got %d", rr.Code)
+	}
+
+	// Following the link only asks to confirm
+	rr := serve(handler.UnsubscribePage, "GET", link.RawQuery, "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Stop these emails?") {
+		t.Errorf("Expected to be asked to confirm, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	if mutes, _ := models.GetMutedRecipients(db.Connection, form.ID); len(mutes) != 0 {
+		t.Fatalf("Expected nothing muted before confirming, got %+v", mutes)
+	}
+
+	rr = serve(handler.Unsubscribe, "POST", "", link.RawQuery)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Unsubscribed") {
+		t.Errorf("Expected the address unsubscribed, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	// Mail clients unsubscribe in one click, posting to the link
+	rr = serve(handler.Unsubscribe, "POST", link.RawQuery, "List-Unsubscribe=One-Click")
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 {
+		t.Errorf("Expected a one-click unsubscribe accepted, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	mutes, _ := models.GetMutedRecipients(db.Connection, form.ID)
+	if len(mutes) != 1 || mutes[0].Address != "inbox@example.com" {
+		t.Fatalf("Expected the address muted once, got %+v", mutes)
+	}
+
+	// The owner sees who muted the form, and can unmute them
+	showForm := func(h http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", strconv.FormatInt(form.ID, 10))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.UserKey, owner)
+		rr := httptest.NewRecorder()
+		h(rr, req.WithContext(ctx))
+		return rr
+	}
+	if rr := showForm(handler.ViewFormModal, "GET", ""); !strings.Contains(rr.Body.String(), "unsubscribed from this form's notification emails") {
+		t.Errorf("Expected the muted address listed on the form, got:\n%s", rr.Body.String())
+	}
+	rr = showForm(handler.UnmuteRecipient, "POST", "address=inbox%40example.com")
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "unsubscribed from this form") {
+		t.Errorf("Expected the address unmuted, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	if mutes, _ := models.GetMutedRecipients(db.Connection, form.ID); len(mutes) != 0 {
+		t.Errorf("Expected nothing muted, got %+v", mutes)
+	}
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/uploads"
+)
+
+// UploadsHandler serves files uploaded with submissions to anyone with a
+// signed link from a notification email
+type UploadsHandler struct {
+	DB      *database.Database
+	Uploads *uploads.Uploader
+	// LinkTTL is how long the store's links that downloads redirect to last
+	LinkTTL time.Duration
+}
+
+// NewUploadsHandler creates a new uploads handler
+func NewUploadsHandler(db *database.Database, uploader *uploads.Uploader) *UploadsHandler {
+	return &UploadsHandler{
+		DB:      db,
+		Uploads: uploader,
+		LinkTTL: defaultLinkTTL,
+	}
+}
+
+// Download redirects a valid signed link to a short-lived link from the
+// store, so links in emails work without signing in
+func (h *UploadsHandler) Download(w http.ResponseWriter, r *http.Request) {
+	submissionID, err := strconv.ParseInt(chi.URLParam(r, "submissionID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid submission ID", http.StatusBadRequest)
+		return
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "fileID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	if !h.Uploads.Verify(submissionID, fileID, r.URL.Query().Get("expires"), r.URL.Query().Get("signature")) {
+		http.Error(w, "This download link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	file, err := models.GetSubmissionFileContext(r.Context(), h.DB.Connection, submissionID, fileID)
+	if err != nil {
+		http.Error(w, "Failed to fetch file", http.StatusInternalServerError)
+		return
+	}
+	if file == nil {
+		// The submission was deleted or redacted since the link was sent
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	link, err := h.Uploads.Store().SignedURL(r.Context(), file.StorageName, file.Filename, h.LinkTTL)
+	if err != nil {
+		log.Printf("Failed to sign a link to file %d: %v", file.ID, err)
+		http.Error(w, "Failed to download file", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, link, http.StatusFound)
+}
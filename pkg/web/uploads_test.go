@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"staticsend/pkg/database"
+	"staticsend/pkg/models"
+	"staticsend/pkg/storage"
+	"staticsend/pkg/uploads"
+)
+
+func TestUploadsHandler_Download(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	owner, _ := models.CreateUser(db.Connection, "owner@example.com", "hash")
+	form, _ := models.CreateForm(db.Connection, owner.ID, "Jobs", "example.com", "secret", "to@example.com", "emailed-files-key")
+	submission, _ := models.CreateSubmission(db.Connection, form.ID, "203.0.113.7", "Test Browser", json.RawMessage(`{"cv":"cv.pdf"}`))
+
+	store, err := storage.NewLocalStore(t.TempDir(), "/files/uploads", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.Put(context.Background(), "upload-abc.pdf", strings.NewReader("resume"))
+	file := &models.SubmissionFile{SubmissionID: submission.ID, Field: "cv", Filename: "cv.pdf", Size: 6, StorageName: "upload-abc.pdf"}
+	if err := models.CreateSubmissionFile(db.Connection, file); err != nil {
+		t.Fatalf("Failed to record file: %v", err)
+	}
+
+	uploader := uploads.NewUploader(db, store, []byte("secret"))
+	handler := NewUploadsHandler(db, uploader)
+
+	// Links work without signing in
+	serve := func(link string) *httptest.ResponseRecorder {
+		parsed, _ := url.Parse(link)
+		parts := strings.Split(strings.TrimPrefix(parsed.Path, "/uploads/"), "/")
+		req := httptest.NewRequest("GET", link, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("submissionID", parts[0])
+		rctx.URLParams.Add("fileID", parts[1])
+		rr := httptest.NewRecorder()
+		handler.Download(rr, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		return rr
+	}
+
+	link := uploader.DownloadPath(*file, time.Now().Add(time.Hour))
+	rr := serve(link)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect, got %d: %s", rr.Code, rr.Body.String())
+	}
+	download := httptest.NewRecorder()
+	store.ServeHTTP(download, httptest.NewRequest("GET", rr.Header().Get("Location"), nil))
+	if download.Code != http.StatusOK || download.Body.String() != "resume" {
+		t.Errorf("Expected the link to download the file, got %d: %s", download.Code, download.Body.String())
+	}
+
+	tampered := strings.Replace(link, "/uploads/"+strconv.FormatInt(submission.ID, 10)+"/", "/uploads/999/", 1)
+	if rr := serve(tampered); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another submission, got %d", rr.Code)
+	}
+	if rr := serve(uploader.DownloadPath(*file, time.Now().Add(-time.Minute))); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for an expired link, got %d", rr.Code)
+	}
+
+	// Links stop working once the file is gone
+	if err := models.DeleteSubmission(db.Connection, submission.ID); err != nil {
+		t.Fatalf("Failed to delete submission: %v", err)
+	}
+	if rr := serve(link); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 once the submission is deleted, got %d", rr.Code)
+	}
+}